@@ -112,6 +112,18 @@ func main() {
 
 	go app.Run()
 
+	if audit := app.AuditLog(); audit != nil {
+		rc := make(chan os.Signal, 1)
+		signal.Notify(rc, syscall.SIGUSR1)
+		go func() {
+			for range rc {
+				if err := audit.Rotate(); err != nil {
+					log.Printf("rotate audit log error %s", err.Error())
+				}
+			}
+		}()
+	}
+
 	<-sc
 
 	println("ledis-server is closing")
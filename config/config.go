@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"strings"
 	"sync"
 
 	"fmt"
@@ -24,6 +25,16 @@ const (
 
 	DefaultDataDir string = "./var"
 
+	// ExpiryEager deletes the expired data in the background.
+	ExpiryEager string = "eager"
+	// ExpiryLazy hides the expired data on read and deletes it then.
+	ExpiryLazy string = "lazy"
+	// ExpiryBoth does both.
+	ExpiryBoth string = "both"
+
+	// DefaultMaxMemoryPolicy is the redis default eviction policy.
+	DefaultMaxMemoryPolicy string = "noeviction"
+
 	KB int = 1024
 	MB int = KB * 1024
 	GB int = MB * 1024
@@ -66,6 +77,14 @@ type RocksDBConfig struct {
 	MaxManifestFileSize            int  `toml:"max_manifest_file_size"`
 }
 
+type BadgerConfig struct {
+	CacheSize int `toml:"cache_size"`
+	// the value log GC runs every this many seconds, 0 disables it
+	GCInterval int `toml:"gc_interval"`
+	// a value log file is rewritten if at least this ratio of it is garbage
+	GCDiscardRatio float64 `toml:"gc_discard_ratio"`
+}
+
 type LMDBConfig struct {
 	MapSize int  `toml:"map_size"`
 	NoSync  bool `toml:"nosync"`
@@ -80,10 +99,38 @@ type ReplicationConfig struct {
 	StoreName        string `toml:"store_name"`
 	MaxLogFileSize   int64  `toml:"max_log_file_size"`
 	MaxLogFileNum    int    `toml:"max_log_file_num"`
+	MaxLogTotalSize  int64  `toml:"max_log_total_size"`
 	SyncLog          int    `toml:"sync_log"`
 	Compression      bool   `toml:"compression"`
+	Checksum         bool   `toml:"checksum"`
 	UseMmap          bool   `toml:"use_mmap"`
 	MasterPassword   string `toml:"master_password"`
+	SlaveTimeout     int    `toml:"slave_timeout"`
+	// ThrottleBytes limits the bytes per second sent to each slave by
+	// FULLSYNC and SYNC, 0 means no limit.
+	ThrottleBytes int64 `toml:"throttle_bytes"`
+	// HeartbeatInterval is the longest a SYNC waits for new logs in seconds,
+	// the empty reply tells the slave that the master is alive.
+	HeartbeatInterval int `toml:"heartbeat_interval"`
+	// HeartbeatTimeout closes a replication connection silent for so many
+	// seconds, on the master if the slave sends no SYNC, on the slave if the
+	// master does not reply. 0 means never.
+	HeartbeatTimeout int `toml:"heartbeat_timeout"`
+	// SafePromotion fails SLAVEOF NO ONE while any log received is not
+	// applied, instead of discarding the logs.
+	SafePromotion bool `toml:"safe_promotion"`
+
+	MasterTLS            bool   `toml:"master_tls"`
+	MasterTLSCA          string `toml:"master_tls_ca"`
+	MasterTLSCertificate string `toml:"master_tls_certificate"`
+	MasterTLSKey         string `toml:"master_tls_key"`
+}
+
+// SentinelConfig discovers the master from the sentinels, in place of SlaveOf.
+type SentinelConfig struct {
+	Addrs      []string `toml:"addrs"`
+	MasterName string   `toml:"master_name"`
+	Password   string   `toml:"password"`
 }
 
 type SnapshotConfig struct {
@@ -95,6 +142,17 @@ type TLS struct {
 	Enabled     bool   `toml:"enabled"`
 	Certificate string `toml:"certificate"`
 	Key         string `toml:"key"`
+	ClientCA    string `toml:"client_ca"`
+}
+
+// HttpRateLimitConfig limits the requests of each user to the /cmd HTTP API
+// with a token bucket.
+type HttpRateLimitConfig struct {
+	// the requests per second, 0 means no limit
+	Rate  int `toml:"rate"`
+	Burst int `toml:"burst"`
+	// the rates of the users overriding Rate, the default user is "default"
+	Users map[string]int `toml:"users"`
 }
 
 type AuthMethod func(c *Config, password string) bool
@@ -114,6 +172,10 @@ type Config struct {
 
 	AddrUnixSocketPerm string `toml:"addr_unixsocketperm"`
 
+	// UnixSocket is a unix socket path listened in addition to Addr, with
+	// the permissions of AddrUnixSocketPerm
+	UnixSocket string `toml:"unixsocket"`
+
 	HttpAddr string `toml:"http_addr"`
 
 	SlaveOf string `toml:"slaveof"`
@@ -130,24 +192,83 @@ type Config struct {
 
 	LevelDB LevelDBConfig `toml:"leveldb"`
 	RocksDB RocksDBConfig `toml:"rocksdb"`
+	Badger  BadgerConfig  `toml:"badger"`
 
 	LMDB LMDBConfig `toml:"lmdb"`
 
 	AccessLog string `toml:"access_log"`
 
+	// AuditLog is the file of the audit records, one JSON line per command
+	// which changes the data or the server state.
+	AuditLog       string `toml:"audit_log"`
+	AuditReads     bool   `toml:"audit_reads"`
+	AuditLogValues bool   `toml:"audit_log_values"`
+
 	UseReplication bool              `toml:"use_replication"`
 	Replication    ReplicationConfig `toml:"replication"`
 
+	Sentinel SentinelConfig `toml:"sentinel"`
+
 	Snapshot SnapshotConfig `toml:"snapshot"`
 
 	ConnReadBufferSize    int `toml:"conn_read_buffer_size"`
 	ConnWriteBufferSize   int `toml:"conn_write_buffer_size"`
 	ConnKeepaliveInterval int `toml:"conn_keepalive_interval"`
 
-	TTLCheckInterval int `toml:"ttl_check_interval"`
+	TTLCheckInterval int    `toml:"ttl_check_interval"`
+	ExpiryMode       string `toml:"expiry_mode"`
+
+	// ExpireNotifyEnabled publishes the expired keys to the pub/sub
+	// channels __keyevent@<db>__:expired and __keyspace@<db>__:<key>.
+	ExpireNotifyEnabled bool `toml:"expire_notify_enabled"`
+
+	// MaxMemory is the size of the store in bytes over which the keys are
+	// evicted by MaxMemoryPolicy, 0 means no limit. The size is the store
+	// files on disk, or the heap for the memory store.
+	MaxMemory int64 `toml:"maxmemory"`
+
+	// the policy also selects whether OBJECT reports the idle time (LRU)
+	// or the access frequency (LFU)
+	MaxMemoryPolicy string `toml:"maxmemory_policy"`
+
+	// EvictionSamples is how many keys are sampled to evict the best one
+	EvictionSamples int `toml:"maxmemory_samples"`
+
+	// the access counter of LFU is decremented every this many minutes a
+	// key is not read, 0 means never
+	LFUDecayTime int `toml:"lfu_decay_time"`
+
+	// a hash of at most this many fields, none bigger than this many bytes,
+	// is stored in one value, the ziplist encoding. The other data types
+	// are always stored in the same format, OBJECT ENCODING reports a list,
+	// set or zset within the limits as "listpack" like redis
+	ZiplistMaxEntries   int `toml:"ziplist_max_entries"`
+	ZiplistMaxValueSize int `toml:"ziplist_max_value_size"`
+
+	// the small hashes are stored in the ziplist encoding, which an older
+	// ledisdb can not read, so it is off by default. When off the hashes in
+	// the ziplist encoding are still read, and their next write stores
+	// them with a store key for each field again
+	HashZiplist bool `toml:"hash_ziplist"`
+
+	// SCRIPT KILL stops a script only after it has run this many milliseconds
+	LuaTimeLimit int `toml:"lua_time_limit"`
+
+	// the milliseconds a command may run, 0 means no limit
+	CommandTimeout int `toml:"command_timeout"`
+
+	// the commands running over this many microseconds are kept in the slow
+	// log, 0 keeps all, a negative value none
+	SlowlogLogSlowerThan int `toml:"slowlog_log_slower_than"`
+	SlowlogMaxLen        int `toml:"slowlog_max_len"`
+
+	// DEBUG is for the tests only, DEBUG SLEEP can block a connection
+	DebugCommandsEnabled bool `toml:"debug_commands_enabled"`
 
 	//tls config
 	TLS TLS `toml:"tls"`
+
+	HttpRateLimit HttpRateLimitConfig `toml:"http_rate_limit"`
 }
 
 func NewConfigWithFile(fileName string) (*Config, error) {
@@ -210,6 +331,10 @@ func NewConfigDefault() *Config {
 	cfg.Replication.UseMmap = true
 	cfg.Snapshot.MaxNum = 1
 
+	cfg.SlowlogLogSlowerThan = 10000
+
+	cfg.LFUDecayTime = 1
+
 	cfg.RocksDB.EnableStatistics = false
 	cfg.RocksDB.UseFsync = false
 	cfg.RocksDB.DisableAutoCompactions = false
@@ -233,12 +358,50 @@ func (cfg *Config) adjust() {
 
 	cfg.RocksDB.adjust()
 
+	cfg.Badger.adjust()
+
 	cfg.Replication.ExpiredLogDays = getDefault(7, cfg.Replication.ExpiredLogDays)
 	cfg.Replication.MaxLogFileNum = getDefault(50, cfg.Replication.MaxLogFileNum)
+	cfg.Replication.SlaveTimeout = getDefault(60, cfg.Replication.SlaveTimeout)
+	cfg.Replication.HeartbeatInterval = getDefault(1, cfg.Replication.HeartbeatInterval)
+	if cfg.Replication.HeartbeatTimeout < 0 {
+		cfg.Replication.HeartbeatTimeout = 0
+	}
 	cfg.ConnReadBufferSize = getDefault(4*KB, cfg.ConnReadBufferSize)
 	cfg.ConnWriteBufferSize = getDefault(4*KB, cfg.ConnWriteBufferSize)
 	cfg.TTLCheckInterval = getDefault(1, cfg.TTLCheckInterval)
+	cfg.LuaTimeLimit = getDefault(5000, cfg.LuaTimeLimit)
+	cfg.SlowlogMaxLen = getDefault(128, cfg.SlowlogMaxLen)
+	cfg.ZiplistMaxEntries = getDefault(128, cfg.ZiplistMaxEntries)
+	cfg.ZiplistMaxValueSize = getDefault(64, cfg.ZiplistMaxValueSize)
+	cfg.EvictionSamples = getDefault(5, cfg.EvictionSamples)
 	cfg.Databases = getDefault(16, cfg.Databases)
+
+	switch cfg.ExpiryMode = strings.ToLower(cfg.ExpiryMode); cfg.ExpiryMode {
+	case ExpiryLazy, ExpiryBoth:
+	default:
+		cfg.ExpiryMode = ExpiryEager
+	}
+
+	if cfg.MaxMemoryPolicy = strings.ToLower(cfg.MaxMemoryPolicy); len(cfg.MaxMemoryPolicy) == 0 {
+		cfg.MaxMemoryPolicy = DefaultMaxMemoryPolicy
+	}
+}
+
+// LazyExpiry reports whether the expired data is checked on read.
+func (cfg *Config) LazyExpiry() bool {
+	return cfg.ExpiryMode == ExpiryLazy || cfg.ExpiryMode == ExpiryBoth
+}
+
+// LFUPolicy reports whether the access frequency is tracked instead of the
+// idle time, like redis does for the allkeys-lfu and volatile-lfu policies.
+func (cfg *Config) LFUPolicy() bool {
+	return strings.HasSuffix(cfg.MaxMemoryPolicy, "-lfu")
+}
+
+// EagerExpiry reports whether the expired data is deleted in the background.
+func (cfg *Config) EagerExpiry() bool {
+	return cfg.ExpiryMode != ExpiryLazy
 }
 
 func (cfg *LevelDBConfig) adjust() {
@@ -249,6 +412,13 @@ func (cfg *LevelDBConfig) adjust() {
 	cfg.MaxFileSize = getDefault(32*MB, cfg.MaxFileSize)
 }
 
+func (cfg *BadgerConfig) adjust() {
+	cfg.CacheSize = getDefault(256*MB, cfg.CacheSize)
+	if cfg.GCDiscardRatio <= 0 || cfg.GCDiscardRatio >= 1 {
+		cfg.GCDiscardRatio = 0.5
+	}
+}
+
 func (cfg *RocksDBConfig) adjust() {
 	cfg.CacheSize = getDefault(4*MB, cfg.CacheSize)
 	cfg.BlockSize = getDefault(4*KB, cfg.BlockSize)
@@ -302,6 +472,20 @@ func (cfg *Config) Rewrite() error {
 	return cfg.DumpFile(cfg.FileName)
 }
 
+// Update runs f with the config locked, to change the parameters at runtime.
+func (cfg *Config) Update(f func()) {
+	cfg.m.Lock()
+	f()
+	cfg.m.Unlock()
+}
+
+// View runs f with the config locked for reading.
+func (cfg *Config) View(f func()) {
+	cfg.m.RLock()
+	f()
+	cfg.m.RUnlock()
+}
+
 func (cfg *Config) GetReadonly() bool {
 	cfg.m.RLock()
 	b := cfg.Readonly
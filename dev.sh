@@ -70,6 +70,11 @@ if [ -f $ROCKSDB_DIR/include/rocksdb/c.h ]; then
     GO_BUILD_TAGS="$GO_BUILD_TAGS rocksdb"
 fi
 
+# badger is pure go, build it with WITH_BADGER=1
+if [[ "$WITH_BADGER" == "1" ]]; then
+    GO_BUILD_TAGS="$GO_BUILD_TAGS badger"
+fi
+
 export CGO_CFLAGS
 export CGO_CXXFLAGS
 export CGO_LDFLAGS
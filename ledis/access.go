@@ -0,0 +1,233 @@
+package ledis
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	errLFUPolicy    = errors.New("an LFU maxmemory policy is not selected, access frequency not tracked")
+	errNotLFUPolicy = errors.New("an LFU maxmemory policy is selected, idle time not tracked")
+)
+
+const (
+	// like redis lfu-log-factor
+	lfuLogFactor = 10
+	// the counter of a new key, so it is not evicted before it can be used
+	lfuInitVal = 5
+
+	// when more keys are tracked, a random part of them is dropped
+	maxAccessKeys = 1 << 20
+)
+
+type accessEntry struct {
+	last    int64 // unix time in seconds
+	counter uint8
+	// read since the last decay of the counter
+	touched bool
+}
+
+// accessTracker keeps the last read time and the LFU counter of the keys in
+// memory instead of the stored values, so a read does not cost a write. The
+// data is lost on restart, then the keys look like they were last read at
+// the start time.
+type accessTracker struct {
+	sync.Mutex
+
+	start int64
+	keys  map[string]*accessEntry
+
+	// the unix time in nanoseconds until which a key is not evicted
+	noEvictKeys map[string]int64
+}
+
+func newAccessTracker() *accessTracker {
+	a := new(accessTracker)
+	a.start = time.Now().Unix()
+	a.keys = make(map[string]*accessEntry)
+	a.noEvictKeys = make(map[string]int64)
+	return a
+}
+
+// lfuIncr increases the counter logarithmically like a Morris counter, the
+// bigger the counter is, the less likely it is increased.
+func lfuIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return counter
+	}
+
+	base := 0.0
+	if counter > lfuInitVal {
+		base = float64(counter - lfuInitVal)
+	}
+
+	if rand.Float64() < 1.0/(base*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+func (a *accessTracker) touch(key []byte) {
+	now := time.Now().Unix()
+
+	a.Lock()
+	e, ok := a.keys[string(key)]
+	if !ok {
+		if len(a.keys) >= maxAccessKeys {
+			a.shrink()
+		}
+		e = &accessEntry{last: now, counter: lfuInitVal}
+		a.keys[string(key)] = e
+	}
+
+	e.counter = lfuIncr(e.counter)
+	e.last = now
+	e.touched = true
+	a.Unlock()
+}
+
+// decay decrements the counters of the keys not read since the last decay.
+func (a *accessTracker) decay() {
+	a.Lock()
+	for _, e := range a.keys {
+		if !e.touched && e.counter > 0 {
+			e.counter--
+		}
+		e.touched = false
+	}
+	a.Unlock()
+}
+
+// shrink drops a quarter of the keys, the map order is random.
+func (a *accessTracker) shrink() {
+	n := len(a.keys) / 4
+	for k := range a.keys {
+		if n--; n < 0 {
+			break
+		}
+		delete(a.keys, k)
+	}
+}
+
+func (a *accessTracker) entry(key []byte) accessEntry {
+	a.Lock()
+	defer a.Unlock()
+
+	if e, ok := a.keys[string(key)]; ok {
+		return *e
+	}
+	return accessEntry{last: a.start, counter: lfuInitVal}
+}
+
+func (a *accessTracker) reset() {
+	a.Lock()
+	a.keys = make(map[string]*accessEntry)
+	a.noEvictKeys = make(map[string]int64)
+	a.Unlock()
+}
+
+// noEvict keeps key from eviction until the unix time in nanoseconds.
+func (a *accessTracker) noEvict(key []byte, until int64) {
+	a.Lock()
+	if len(a.noEvictKeys) >= maxAccessKeys {
+		now := time.Now().UnixNano()
+		for k, t := range a.noEvictKeys {
+			if t <= now {
+				delete(a.noEvictKeys, k)
+			}
+		}
+	}
+	a.noEvictKeys[string(key)] = until
+	a.Unlock()
+}
+
+// noEvicted reports whether key is kept from eviction at now.
+func (a *accessTracker) noEvicted(key []byte, now time.Time) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	until, ok := a.noEvictKeys[string(key)]
+	if ok && until <= now.UnixNano() {
+		delete(a.noEvictKeys, string(key))
+		return false
+	}
+	return ok
+}
+
+// rawKeyExists reports whether key exists as any data type without touching
+// it, so looking at the access data does not change it.
+func (db *DB) rawKeyExists(key []byte) (bool, error) {
+	for _, dataType := range expireTypes {
+		var ek []byte
+		switch dataType {
+		case KVType:
+			ek = db.encodeKVKey(key)
+		case HashType:
+			ek = db.hEncodeSizeKey(key)
+		case ListType:
+			ek = db.lEncodeMetaKey(key)
+		case SetType:
+			ek = db.sEncodeSizeKey(key)
+		case ZSetType:
+			ek = db.zEncodeSizeKey(key)
+		case HLLType:
+			ek = db.hllEncodeKey(key)
+		case StreamType:
+			ek = db.xEncodeMetaKey(key)
+		}
+
+		if v, err := db.bucket.Get(ek); err != nil {
+			return false, err
+		} else if v == nil {
+			continue
+		}
+
+		when, err := Int64(db.bucket.Get(db.expEncodeMetaKey(dataType, key)))
+		if err != nil {
+			return false, err
+		} else if when == 0 || expireTimeMs(when) > nowMs() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ObjectIdleTime returns the seconds since key was last read, or -1 if key
+// does not exist. The access time is tracked in memory, so a key not read
+// since the start counts from the start time.
+func (db *DB) ObjectIdleTime(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	} else if db.l.cfg.LFUPolicy() {
+		return 0, errNotLFUPolicy
+	}
+
+	if ok, err := db.rawKeyExists(key); err != nil || !ok {
+		return -1, err
+	}
+
+	idle := time.Now().Unix() - db.access.entry(key).last
+	if idle < 0 {
+		idle = 0
+	}
+	return idle, nil
+}
+
+// ObjectFreq returns the logarithmic access counter of key like redis LFU,
+// or -1 if key does not exist. It needs an LFU maxmemory_policy.
+func (db *DB) ObjectFreq(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	} else if !db.l.cfg.LFUPolicy() {
+		return 0, errLFUPolicy
+	}
+
+	if ok, err := db.rawKeyExists(key); err != nil || !ok {
+		return -1, err
+	}
+
+	return int64(db.access.entry(key).counter), nil
+}
@@ -0,0 +1,151 @@
+package ledis
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLFUCounter(t *testing.T) {
+	if c := lfuIncr(255); c != 255 {
+		t.Fatal(c)
+	}
+
+	var c uint8
+	for i := 0; i < 1000; i++ {
+		c = lfuIncr(c)
+	}
+	// the counter grows logarithmically, not once per access
+	if c <= lfuInitVal || c >= 100 {
+		t.Fatal(c)
+	}
+}
+
+func TestAccessTrackerShrink(t *testing.T) {
+	a := newAccessTracker()
+	for i := 0; i < 100; i++ {
+		a.touch([]byte(fmt.Sprintf("key%d", i)))
+	}
+
+	a.shrink()
+	if n := len(a.keys); n != 75 {
+		t.Fatal(n)
+	}
+
+	a.reset()
+	if e := a.entry([]byte("key1")); e.last != a.start || e.counter != lfuInitVal {
+		t.Fatal(e)
+	}
+}
+
+func TestObjectIdleTimeFreq(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("test_object_access")
+	db.Set(key, []byte("v"))
+	db.Get(key)
+
+	db.access.Lock()
+	db.access.keys[string(key)].last -= 100
+	db.access.Unlock()
+
+	if n, err := db.ObjectIdleTime(key); err != nil {
+		t.Fatal(err)
+	} else if n < 100 {
+		t.Fatal(n)
+	}
+
+	// looking at the idle time is not an access
+	if n, err := db.ObjectIdleTime(key); err != nil {
+		t.Fatal(err)
+	} else if n < 100 {
+		t.Fatal(n)
+	}
+
+	db.Get(key)
+	if n, err := db.ObjectIdleTime(key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.ObjectIdleTime([]byte("test_object_access_none")); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.ObjectFreq(key); err != errLFUPolicy {
+		t.Fatal(err)
+	}
+
+	policy := db.l.cfg.MaxMemoryPolicy
+	db.l.cfg.Update(func() { db.l.cfg.MaxMemoryPolicy = "allkeys-lfu" })
+	defer db.l.cfg.Update(func() { db.l.cfg.MaxMemoryPolicy = policy })
+
+	if _, err := db.ObjectIdleTime(key); err != errNotLFUPolicy {
+		t.Fatal(err)
+	}
+
+	if n, err := db.ObjectFreq(key); err != nil {
+		t.Fatal(err)
+	} else if n < lfuInitVal {
+		t.Fatal(n)
+	}
+}
+
+func TestNoTouch(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("test_no_touch")
+	db.Set(key, []byte("v"))
+	db.Get(key)
+
+	db.access.Lock()
+	db.access.keys[string(key)].last -= 100
+	db.access.Unlock()
+
+	db.WithNoTouch().Get(key)
+	if n, err := db.ObjectIdleTime(key); err != nil {
+		t.Fatal(err)
+	} else if n < 100 {
+		t.Fatal(n)
+	}
+
+	db.Get(key)
+	if n, err := db.ObjectIdleTime(key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func benchmarkSequentialGet(b *testing.B, get func(key []byte) ([]byte, error)) {
+	db := getTestDB()
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("bench_access_key_%d", i))
+		db.Set(keys[i], []byte("value"))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := get(keys[i%len(keys)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSequentialGet(b *testing.B) {
+	db := getTestDB()
+	benchmarkSequentialGet(b, db.Get)
+}
+
+// BenchmarkSequentialGetUntracked is the lookup of Get without the access
+// tracking, to compare with BenchmarkSequentialGet.
+func BenchmarkSequentialGetUntracked(b *testing.B) {
+	db := getTestDB()
+	benchmarkSequentialGet(b, func(key []byte) ([]byte, error) {
+		return db.bucket.Get(db.encodeKVKey(key))
+	})
+}
@@ -0,0 +1,197 @@
+package ledis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+var errACLUserName = errors.New("invalid acl user name")
+var errACLPassword = errors.New("invalid acl password")
+
+// aclKeyPrefix is the prefix of the ACL users in the store. It has the
+// index MaxDatabases which no database can select, so the users are out of
+// all the databases and FLUSHALL keeps them.
+var aclKeyPrefix = func() []byte {
+	buf := make([]byte, binary.MaxVarintLen64+1)
+	n := binary.PutUvarint(buf, uint64(MaxDatabases))
+	buf[n] = MetaType
+	return buf[0 : n+1]
+}()
+
+func aclEncodeKey(name string) []byte {
+	return append(append([]byte(nil), aclKeyPrefix...), name...)
+}
+
+// ACLUser is a user of the access control list, it is read only.
+type ACLUser struct {
+	Name string
+	// Password is the sha256 of the password.
+	Password []byte
+	// Commands are the glob style patterns of the allowed commands.
+	Commands []string
+	// Keys are the glob style patterns of the allowed keys.
+	Keys []string
+}
+
+// CheckPassword reports whether password is the password of the user.
+func (u *ACLUser) CheckPassword(password string) bool {
+	h := sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(h[:], u.Password) == 1
+}
+
+// AllowCommand reports whether the user can run cmd.
+func (u *ACLUser) AllowCommand(cmd string) bool {
+	cmd = strings.ToLower(cmd)
+	for _, pattern := range u.Commands {
+		if MatchPattern(pattern, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowKey reports whether the user can access key.
+func (u *ACLUser) AllowKey(key []byte) bool {
+	for _, pattern := range u.Keys {
+		if MatchPattern(pattern, string(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadACL reads the ACL users from the store into memory.
+func (l *Ledis) loadACL() error {
+	users := make(map[string]*ACLUser)
+
+	max := append(append([]byte(nil), aclKeyPrefix[:len(aclKeyPrefix)-1]...), MetaType+1)
+	it := l.ldb.RangeIterator(aclKeyPrefix, max, store.RangeROpen)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		u := new(ACLUser)
+		if err := json.Unmarshal(it.Value(), u); err != nil {
+			return err
+		}
+		users[u.Name] = u
+	}
+
+	l.aclLock.Lock()
+	l.aclUsers = users
+	l.aclLock.Unlock()
+	return nil
+}
+
+// ACLAddUser creates the user, or replaces the password and the rules of
+// the existing one. The user can run the commands matching commandRules on
+// the keys matching keyPatterns, both are glob style patterns. It must not
+// run in a Multi, it read locks the write lock which the Multi holds, so it
+// would wait for the Multi forever.
+func (l *Ledis) ACLAddUser(username, password string, commandRules, keyPatterns []string) error {
+	if len(username) == 0 || len(username) > MaxKeySize {
+		return errACLUserName
+	} else if len(password) == 0 {
+		return errACLPassword
+	}
+
+	h := sha256.Sum256([]byte(password))
+	u := &ACLUser{
+		Name:     username,
+		Password: h[:],
+		Keys:     append([]string{}, keyPatterns...),
+	}
+	for _, rule := range commandRules {
+		u.Commands = append(u.Commands, strings.ToLower(rule))
+	}
+
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	l.wLock.RLock()
+	defer l.wLock.RUnlock()
+
+	l.aclLock.Lock()
+	defer l.aclLock.Unlock()
+
+	wb := l.ldb.NewWriteBatch()
+	defer wb.Rollback()
+
+	wb.Put(aclEncodeKey(username), data)
+	if err := l.aclCommit(wb); err != nil {
+		return err
+	}
+
+	l.aclUsers[username] = u
+	return nil
+}
+
+// ACLDelUser deletes the user, it returns false if the user does not exist.
+// It must not run in a Multi like ACLAddUser.
+func (l *Ledis) ACLDelUser(username string) (bool, error) {
+	l.wLock.RLock()
+	defer l.wLock.RUnlock()
+
+	l.aclLock.Lock()
+	defer l.aclLock.Unlock()
+
+	if _, ok := l.aclUsers[username]; !ok {
+		return false, nil
+	}
+
+	wb := l.ldb.NewWriteBatch()
+	defer wb.Rollback()
+
+	wb.Delete(aclEncodeKey(username))
+	if err := l.aclCommit(wb); err != nil {
+		return false, err
+	}
+
+	delete(l.aclUsers, username)
+	return true, nil
+}
+
+// aclCommit commits the users in wb with the replication log like the
+// writes of the databases, so the slaves have the same users.
+func (l *Ledis) aclCommit(wb *store.WriteBatch) error {
+	if l.cfg.GetReadonly() {
+		return ErrWriteInROnly
+	}
+
+	_, err := l.handleCommit(wb, wb, 0)
+	return err
+}
+
+// ACLGetUser returns the user, or nil if it does not exist.
+func (l *Ledis) ACLGetUser(username string) *ACLUser {
+	l.aclLock.RLock()
+	u := l.aclUsers[username]
+	l.aclLock.RUnlock()
+	return u
+}
+
+// ACLUsers returns the names of all the users in order.
+func (l *Ledis) ACLUsers() []string {
+	l.aclLock.RLock()
+	names := make([]string, 0, len(l.aclUsers))
+	for name := range l.aclUsers {
+		names = append(names, name)
+	}
+	l.aclLock.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+func isACLKey(key []byte) bool {
+	return bytes.HasPrefix(key, aclKeyPrefix)
+}
@@ -0,0 +1,136 @@
+package ledis
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestACL(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_ledis_acl"
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.ACLAddUser("", "pass", nil, nil); err != errACLUserName {
+		t.Fatal(err)
+	} else if err := l.ACLAddUser("alice", "", nil, nil); err != errACLPassword {
+		t.Fatal(err)
+	}
+
+	if err := l.ACLAddUser("alice", "pass", []string{"GET", "h*"}, []string{"user:*"}); err != nil {
+		t.Fatal(err)
+	} else if err := l.ACLAddUser("bob", "pass", []string{"*"}, []string{"*"}); err != nil {
+		t.Fatal(err)
+	}
+
+	u := l.ACLGetUser("alice")
+	if u == nil {
+		t.Fatal("must exist")
+	} else if !u.CheckPassword("pass") || u.CheckPassword("Pass") {
+		t.Fatal("invalid password check")
+	} else if !u.AllowCommand("get") || !u.AllowCommand("HSET") || u.AllowCommand("set") {
+		t.Fatal("invalid command check")
+	} else if !u.AllowKey([]byte("user:1")) || u.AllowKey([]byte("order:1")) {
+		t.Fatal("invalid key check")
+	}
+
+	// the users are out of the databases
+	db, _ := l.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	if err := l.FlushAll(); err != nil {
+		t.Fatal(err)
+	} else if n, _ := db.DBSize(); n != 0 {
+		t.Fatal(n)
+	} else if names := l.ACLUsers(); len(names) != 2 {
+		t.Fatal(names)
+	}
+
+	if ok, err := l.ACLDelUser("bob"); err != nil || !ok {
+		t.Fatal(ok, err)
+	} else if ok, err := l.ACLDelUser("bob"); err != nil || ok {
+		t.Fatal(ok, err)
+	}
+
+	l.Close()
+
+	if l, err = Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if names := l.ACLUsers(); len(names) != 1 || names[0] != "alice" {
+		t.Fatal(names)
+	} else if u := l.ACLGetUser("alice"); u == nil || !u.CheckPassword("pass") || !u.AllowKey([]byte("user:2")) {
+		t.Fatal(u)
+	}
+}
+
+func TestACLReplication(t *testing.T) {
+	cfgM := config.NewConfigDefault()
+	cfgM.DataDir = "/tmp/test_acl_repl/master"
+	cfgM.UseReplication = true
+	os.RemoveAll(cfgM.DataDir)
+
+	master, err := Open(cfgM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	cfgS := config.NewConfigDefault()
+	cfgS.DataDir = "/tmp/test_acl_repl/slave"
+	cfgS.UseReplication = true
+	cfgS.Readonly = true
+	os.RemoveAll(cfgS.DataDir)
+	defer os.RemoveAll("/tmp/test_acl_repl")
+
+	slave, err := Open(cfgS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+
+	if err := slave.ACLAddUser("alice", "pass", nil, nil); err != ErrWriteInROnly {
+		t.Fatal(err)
+	}
+
+	replicate := func() {
+		var buf bytes.Buffer
+		if id, err := slave.r.LastLogID(); err != nil {
+			t.Fatal(err)
+		} else if _, _, err = master.ReadLogsTo(id+1, &buf); err != nil {
+			t.Fatal(err)
+		} else if err = slave.StoreLogsFromReader(&buf); err != nil {
+			t.Fatal(err)
+		}
+		slave.WaitReplication()
+	}
+
+	if err := master.ACLAddUser("alice", "pass", []string{"*"}, []string{"*"}); err != nil {
+		t.Fatal(err)
+	} else if id, err := master.r.LastCommitID(); err != nil || id != 1 {
+		t.Fatal(id, err)
+	}
+
+	replicate()
+	if u := slave.ACLGetUser("alice"); u == nil || !u.CheckPassword("pass") {
+		t.Fatal(u)
+	}
+
+	if ok, err := master.ACLDelUser("alice"); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+
+	replicate()
+	if u := slave.ACLGetUser("alice"); u != nil {
+		t.Fatal(u)
+	}
+}
@@ -2,8 +2,8 @@ package ledis
 
 import (
 	"sync"
+	"time"
 
-	"github.com/siddontang/go/log"
 	"github.com/siddontang/ledisdb/rpl"
 	"github.com/siddontang/ledisdb/store"
 )
@@ -15,15 +15,52 @@ type batch struct {
 
 	sync.Locker
 
+	// the event of the deletes, set by the ttl checker and the evictor,
+	// EventDel if empty
+	delEvent string
+
+	// the writes of the Multi which the commits move to, nil if not in one
+	multi *multiWrites
+
+	// opts of the DB from WithCommitOptions, and the log of the last commit
+	// whose slaves are waited for in Unlock, 0 if none
+	opts   CommitOptions
+	waitID uint64
+
 	//	tx *Tx
 }
 
 func (b *batch) Commit() error {
 	if b.l.cfg.GetReadonly() {
 		return ErrWriteInROnly
+	} else if b.multi != nil {
+		return b.multi.add(b)
+	}
+
+	var items []store.BatchItem
+	if b.l.nm.watched() || b.l.wm.watched() {
+		items, _ = b.WriteBatch.BatchData().Items()
+	}
+
+	var ns []Notification
+	if b.l.nm.watched() {
+		ns = b.l.nm.decode(items, b.delEvent)
+	}
+
+	id, err := b.l.handleCommit(b.WriteBatch, b.WriteBatch, 0)
+	if err != nil {
+		return err
+	}
+
+	if b.opts.WaitReplicas > 0 {
+		b.waitID = id
 	}
 
-	return b.l.handleCommit(b.WriteBatch, b.WriteBatch)
+	if b.l.wm.watched() {
+		b.l.wm.touch(items)
+	}
+	b.l.nm.publish(ns)
+	return nil
 
 	// if b.tx == nil {
 	// 	return b.l.handleCommit(b.WriteBatch, b.WriteBatch)
@@ -43,7 +80,15 @@ func (b *batch) Lock() {
 
 func (b *batch) Unlock() {
 	b.WriteBatch.Rollback()
+
+	// wait without the lock, the other writes go on
+	id := b.waitID
+	b.waitID = 0
 	b.Locker.Unlock()
+
+	if id > 0 {
+		b.l.waitReplicas(id, b.opts)
+	}
 }
 
 func (b *batch) Put(key []byte, value []byte) {
@@ -75,11 +120,12 @@ func (l *dbBatchLocker) Unlock() {
 // func (l *txBatchLocker) Lock()   {}
 // func (l *txBatchLocker) Unlock() {}
 
-// type multiBatchLocker struct {
-// }
+// multiBatchLocker locks nothing, the Multi holds the write lock.
+type multiBatchLocker struct {
+}
 
-// func (l *multiBatchLocker) Lock()   {}
-// func (l *multiBatchLocker) Unlock() {}
+func (l *multiBatchLocker) Lock()   {}
+func (l *multiBatchLocker) Unlock() {}
 
 func (l *Ledis) newBatch(wb *store.WriteBatch, locker sync.Locker) *batch {
 	b := new(batch)
@@ -99,17 +145,24 @@ type commitDataGetter interface {
 	Data() []byte
 }
 
-func (l *Ledis) handleCommit(g commitDataGetter, c commiter) error {
+// handleCommit commits c with its replication log, it returns the id of the
+// log, 0 without replication.
+func (l *Ledis) handleCommit(g commitDataGetter, c commiter, createTime uint32) (uint64, error) {
 	l.commitLock.Lock()
 
+	if createTime == 0 {
+		createTime = uint32(time.Now().Unix())
+	}
+
+	var id uint64
 	var err error
 	if l.r != nil {
 		var rl *rpl.Log
-		if rl, err = l.r.Log(g.Data()); err != nil {
+		if rl, err = l.r.LogWithTime(g.Data(), createTime); err != nil {
 			l.commitLock.Unlock()
 
-			log.Fatalf("write wal error %s", err.Error())
-			return err
+			l.logger.Error("write wal failed", rpl.EventType("log"), rpl.Err(err))
+			return 0, err
 		}
 
 		l.propagate(rl)
@@ -117,23 +170,27 @@ func (l *Ledis) handleCommit(g commitDataGetter, c commiter) error {
 		if err = c.Commit(); err != nil {
 			l.commitLock.Unlock()
 
-			log.Fatalf("commit error %s", err.Error())
+			l.logger.Error("commit log failed", rpl.LogPos(rl.ID), rpl.EventType("commit"), rpl.Err(err))
 			l.noticeReplication()
-			return err
+			return 0, err
 		}
 
 		if err = l.r.UpdateCommitID(rl.ID); err != nil {
 			l.commitLock.Unlock()
 
-			log.Fatalf("update commit id error %s", err.Error())
+			l.logger.Error("update commit id failed", rpl.LogPos(rl.ID), rpl.EventType("commit"), rpl.Err(err))
 			l.noticeReplication()
-			return err
+			return 0, err
 		}
+		id = rl.ID
 	} else {
 		err = c.Commit()
 	}
 
 	l.commitLock.Unlock()
 
-	return err
+	if err == nil {
+		l.changes.Add(1)
+	}
+	return id, err
 }
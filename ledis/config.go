@@ -0,0 +1,215 @@
+package ledis
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+// configSetter checks a parameter value set at runtime and applies it, nil
+// funcs mean no check or nothing to apply, the value is read when used.
+type configSetter struct {
+	check func(v reflect.Value) error
+	apply func(l *Ledis)
+}
+
+func checkPositive(v reflect.Value) error {
+	if v.Int() <= 0 {
+		return fmt.Errorf("must be positive")
+	}
+	return nil
+}
+
+func checkNonNegative(v reflect.Value) error {
+	if v.Int() < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	return nil
+}
+
+var maxMemoryPolicies = []string{
+	"noeviction",
+	"allkeys-lru", "volatile-lru",
+	"allkeys-lfu", "volatile-lfu",
+	"allkeys-random", "volatile-random",
+	"volatile-ttl",
+}
+
+func checkMaxMemoryPolicy(v reflect.Value) error {
+	for _, p := range maxMemoryPolicies {
+		if v.String() == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(maxMemoryPolicies, ", "))
+}
+
+// configSetters are the parameters which can be set at runtime, the others
+// are only used at start.
+var configSetters = map[string]configSetter{
+	"lua_time_limit":          {check: checkPositive},
+	"command_timeout":         {check: checkNonNegative},
+	"slowlog_log_slower_than": {},
+	"audit_reads":             {},
+	"audit_log_values":        {},
+	"maxmemory":               {check: checkNonNegative},
+	"maxmemory_policy":        {check: checkMaxMemoryPolicy},
+	"maxmemory_samples":       {check: checkPositive},
+	"lfu_decay_time":          {check: checkNonNegative},
+	"conn_keepalive_interval": {check: checkNonNegative},
+	"ziplist_max_entries":     {check: checkPositive},
+	"ziplist_max_value_size":  {check: checkPositive},
+	"hash_ziplist":            {},
+	"ttl_check_interval": {check: checkPositive, apply: func(l *Ledis) {
+		// the ttl checker computes its next check with the new interval
+		AsyncNotify(l.ttlWakeCh)
+	}},
+	"replication.sync":                {},
+	"replication.wait_sync_time":      {check: checkNonNegative},
+	"replication.wait_max_slave_acks": {check: checkNonNegative},
+	"replication.expired_log_days":    {check: checkPositive},
+	"replication.slave_timeout":       {check: checkPositive},
+	"replication.throttle_bytes":      {check: checkNonNegative},
+	"replication.heartbeat_interval":  {check: checkPositive},
+	"replication.heartbeat_timeout":   {check: checkNonNegative},
+	"replication.safe_promotion":      {},
+}
+
+// configStore binds the config parameter names to the config fields, a name
+// is the toml key, prefixed by the table like "replication.sync".
+type configStore struct {
+	names  []string
+	fields map[string]reflect.Value
+}
+
+func newConfigStore(cfg *config.Config) *configStore {
+	s := &configStore{fields: make(map[string]reflect.Value)}
+	s.bind(reflect.ValueOf(cfg).Elem(), "")
+	return s
+}
+
+func (s *configStore) bind(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name := f.Tag.Get("toml")
+		if len(name) == 0 || name == "-" || len(f.PkgPath) > 0 {
+			continue
+		}
+		name = prefix + name
+
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			s.bind(v.Field(i), name+".")
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s.names = append(s.names, name)
+			s.fields[name] = v.Field(i)
+		}
+	}
+}
+
+// configName accepts the redis style names like "slowlog-log-slower-than".
+func configName(name string) string {
+	return strings.Replace(strings.ToLower(name), "-", "_", -1)
+}
+
+func formatConfigValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.String:
+		return v.String()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	default:
+		return strconv.FormatInt(v.Int(), 10)
+	}
+}
+
+// parseConfigValue parses value into a new value of the type of v.
+func parseConfigValue(v reflect.Value, value string) (reflect.Value, error) {
+	nv := reflect.New(v.Type()).Elem()
+
+	switch v.Kind() {
+	case reflect.Bool:
+		switch strings.ToLower(value) {
+		case "true", "yes", "1":
+			nv.SetBool(true)
+		case "false", "no", "0":
+		default:
+			return nv, fmt.Errorf("must be a boolean")
+		}
+	case reflect.String:
+		nv.SetString(strings.ToLower(value))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return nv, fmt.Errorf("must be an unsigned integer")
+		}
+		nv.SetUint(n)
+	default:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return nv, fmt.Errorf("must be an integer")
+		}
+		nv.SetInt(n)
+	}
+
+	return nv, nil
+}
+
+// ConfigGet returns the config parameters matching the glob style pattern.
+func (l *Ledis) ConfigGet(pattern string) (map[string]string, error) {
+	pattern = configName(pattern)
+
+	m := make(map[string]string)
+	l.cfg.View(func() {
+		for _, name := range l.cs.names {
+			if MatchPattern(pattern, name) {
+				m[name] = formatConfigValue(l.cs.fields[name])
+			}
+		}
+	})
+	return m, nil
+}
+
+// ConfigSet checks and sets the config parameter at runtime. The config
+// file is rewritten if the config is loaded from a file.
+func (l *Ledis) ConfigSet(name string, value string) error {
+	name = configName(name)
+
+	v, ok := l.cs.fields[name]
+	if !ok {
+		return fmt.Errorf("unknown config parameter %s", name)
+	}
+
+	setter, ok := configSetters[name]
+	if !ok {
+		return fmt.Errorf("config parameter %s can not be set at runtime", name)
+	}
+
+	nv, err := parseConfigValue(v, value)
+	if err == nil && setter.check != nil {
+		err = setter.check(nv)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s, %s", value, name, err.Error())
+	}
+
+	l.cfg.Update(func() { v.Set(nv) })
+
+	if setter.apply != nil {
+		setter.apply(l)
+	}
+
+	if len(l.cfg.FileName) > 0 {
+		return l.cfg.Rewrite()
+	}
+	return nil
+}
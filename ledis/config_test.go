@@ -0,0 +1,75 @@
+package ledis
+
+import (
+	"os"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestConfigGetSet(t *testing.T) {
+	l := getTestDB().l
+
+	if m, err := l.ConfigGet("replication.wait_*"); err != nil {
+		t.Fatal(err)
+	} else if len(m) != 2 {
+		t.Fatal(m)
+	} else if _, ok := m["replication.wait_sync_time"]; !ok {
+		t.Fatal(m)
+	}
+
+	timeout := l.cfg.CommandTimeout
+	defer l.cfg.Update(func() { l.cfg.CommandTimeout = timeout })
+
+	if err := l.ConfigSet("Command-Timeout", "100"); err != nil {
+		t.Fatal(err)
+	} else if l.cfg.CommandTimeout != 100 {
+		t.Fatal(l.cfg.CommandTimeout)
+	}
+
+	if m, _ := l.ConfigGet("command-timeout"); m["command_timeout"] != "100" {
+		t.Fatal(m)
+	}
+
+	for _, args := range [][2]string{
+		{"no_such_param", "1"},
+		{"databases", "2"},
+		{"command_timeout", "a"},
+		{"command_timeout", "-1"},
+		{"maxmemory_policy", "lru"},
+	} {
+		if err := l.ConfigSet(args[0], args[1]); err == nil {
+			t.Fatalf("must error, %v", args)
+		}
+	}
+
+	if err := l.ConfigSet("replication.sync", "yes"); err != nil {
+		t.Fatal(err)
+	} else if !l.cfg.Replication.Sync {
+		t.Fatal("must be set")
+	}
+	l.cfg.Update(func() { l.cfg.Replication.Sync = false })
+}
+
+func TestConfigSetRewrite(t *testing.T) {
+	fileName := "/tmp/test_config_rewrite.toml"
+	os.Remove(fileName)
+
+	l := getTestDB().l
+	l.cfg.Update(func() { l.cfg.FileName = fileName })
+	defer l.cfg.Update(func() { l.cfg.FileName = "" })
+
+	interval := l.cfg.TTLCheckInterval
+	defer l.cfg.Update(func() { l.cfg.TTLCheckInterval = interval })
+
+	if err := l.ConfigSet("ttl_check_interval", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.NewConfigWithFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	} else if cfg.TTLCheckInterval != 2 {
+		t.Fatal(cfg.TTLCheckInterval)
+	}
+}
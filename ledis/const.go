@@ -17,6 +17,8 @@ const (
 	HASH
 	SET
 	ZSET
+	HLL
+	STREAM
 )
 
 func (d DataType) String() string {
@@ -31,6 +33,10 @@ func (d DataType) String() string {
 		return SetName
 	case ZSET:
 		return ZSetName
+	case HLL:
+		return HLLName
+	case STREAM:
+		return StreamName
 	default:
 		return "unknown"
 	}
@@ -38,11 +44,13 @@ func (d DataType) String() string {
 
 // For different type name
 const (
-	KVName   = "KV"
-	ListName = "LIST"
-	HashName = "HASH"
-	SetName  = "SET"
-	ZSetName = "ZSET"
+	KVName     = "KV"
+	ListName   = "LIST"
+	HashName   = "HASH"
+	SetName    = "SET"
+	ZSetName   = "ZSET"
+	HLLName    = "HLL"
+	StreamName = "STREAM"
 )
 
 // for backend store
@@ -60,6 +68,14 @@ const (
 	// BitMetaType byte = 10
 	SetType   byte = 11
 	SSizeType byte = 12
+	HLLType   byte = 13
+
+	StreamType     byte = 14
+	StreamMetaType byte = 15
+
+	// HZipType is a small hash in the ziplist encoding, all the fields in
+	// one value
+	HZipType byte = 16
 
 	maxDataType byte = 100
 
@@ -86,10 +102,14 @@ var TypeName = map[byte]string{
 	ZScoreType: "zscore",
 	// BitType:     "bit",
 	// BitMetaType: "bitmeta",
-	SetType:     "set",
-	SSizeType:   "ssize",
-	ExpTimeType: "exptime",
-	ExpMetaType: "expmeta",
+	SetType:        "set",
+	SSizeType:      "ssize",
+	HLLType:        "hll",
+	StreamType:     "stream",
+	StreamMetaType: "streammeta",
+	HZipType:       "hzip",
+	ExpTimeType:    "exptime",
+	ExpMetaType:    "expmeta",
 }
 
 const (
@@ -133,6 +153,8 @@ var (
 	ErrWriteInROnly  = errors.New("write not support in readonly mode")
 	ErrRplInRDWR     = errors.New("replication not support in read write mode")
 	ErrRplNotSupport = errors.New("replication not support")
+	ErrBusyKey       = errors.New("BUSYKEY Target key name already exists.")
+	ErrNoSuchKey     = errors.New("no such key")
 )
 
 // const (
@@ -0,0 +1,401 @@
+package ledis
+
+import (
+	"errors"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+var errCopySameKey = errors.New("source and destination keys are the same")
+var errMoveSameDB = errors.New("source and destination databases are the same")
+
+// CopyOptions controls what Copy duplicates besides the data.
+type CopyOptions struct {
+	// WithTTL copies the expire time of the source to the destination.
+	WithTTL bool
+}
+
+// Copy copies all the data types of src to dst in one batch, src is kept.
+// If dst exists as any data type, Copy returns false unless replace is
+// true, in which case every data type of dst is removed first.
+func (db *DB) Copy(src []byte, dst []byte, replace bool, opts CopyOptions) (bool, error) {
+	if err := checkKeySize(src); err != nil {
+		return false, err
+	} else if err := checkKeySize(dst); err != nil {
+		return false, err
+	} else if string(src) == string(dst) {
+		return false, errCopySameKey
+	}
+
+	t := db.newExclusiveBatch()
+	t.Lock()
+	defer t.Unlock()
+
+	for _, dataType := range expireTypes {
+		if err := db.expireOnWrite(t, dataType, src, dst); err != nil {
+			return false, err
+		}
+	}
+
+	var srcTypes []byte
+	var dstTypes []byte
+	for _, dataType := range expireTypes {
+		if n, err := db.keyExists(dataType, src); err != nil {
+			return false, err
+		} else if n == 1 {
+			srcTypes = append(srcTypes, dataType)
+		}
+
+		if n, err := db.keyExists(dataType, dst); err != nil {
+			return false, err
+		} else if n == 1 {
+			dstTypes = append(dstTypes, dataType)
+		}
+	}
+
+	if len(srcTypes) == 0 || (len(dstTypes) > 0 && !replace) {
+		return false, nil
+	}
+
+	for _, dataType := range dstTypes {
+		db.ttlChecker.cbs[dataType](t, dst)
+		if _, err := db.rmExpire(t, dataType, dst); err != nil {
+			return false, err
+		}
+	}
+
+	for _, dataType := range srcTypes {
+		if err := db.copyType(t, dataType, src, db, dst); err != nil {
+			return false, err
+		}
+
+		if !opts.WithTTL {
+			continue
+		}
+
+		when, err := Int64(db.bucket.Get(db.expEncodeMetaKey(dataType, src)))
+		if err != nil {
+			return false, err
+		} else if when > 0 {
+			db.expireAt(t, dataType, dst, when)
+		}
+	}
+
+	if err := t.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Move moves all the data types of key with the TTL to the database index in
+// one batch. Move returns false and changes nothing if key does not exist,
+// or exists as any data type in the destination database.
+func (db *DB) Move(key []byte, index int) (bool, error) {
+	if err := checkKeySize(key); err != nil {
+		return false, err
+	} else if index == db.Index() {
+		return false, errMoveSameDB
+	}
+
+	to, err := db.l.Select(index)
+	if err != nil {
+		return false, err
+	}
+
+	t := db.newExclusiveBatch()
+	t.Lock()
+	defer t.Unlock()
+
+	for _, dataType := range expireTypes {
+		if err := db.expireOnWrite(t, dataType, key); err != nil {
+			return false, err
+		} else if err = to.expireOnWrite(t, dataType, key); err != nil {
+			return false, err
+		}
+	}
+
+	var srcTypes []byte
+	for _, dataType := range expireTypes {
+		if n, err := to.keyExists(dataType, key); err != nil {
+			return false, err
+		} else if n == 1 {
+			return false, nil
+		}
+
+		if n, err := db.keyExists(dataType, key); err != nil {
+			return false, err
+		} else if n == 1 {
+			srcTypes = append(srcTypes, dataType)
+		}
+	}
+
+	if len(srcTypes) == 0 {
+		return false, nil
+	}
+
+	for _, dataType := range srcTypes {
+		if err := db.copyType(t, dataType, key, to, key); err != nil {
+			return false, err
+		}
+
+		when, err := Int64(db.bucket.Get(db.expEncodeMetaKey(dataType, key)))
+		if err != nil {
+			return false, err
+		} else if when > 0 {
+			to.expireAt(t, dataType, key, when)
+		}
+
+		db.ttlChecker.cbs[dataType](t, key)
+		if _, err := db.rmExpire(t, dataType, key); err != nil {
+			return false, err
+		}
+	}
+
+	if err := t.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Rename renames src to dst with the TTL for all the data types in one
+// batch, every data type of dst is removed first. Rename returns
+// ErrNoSuchKey if src does not exist.
+func (db *DB) Rename(src []byte, dst []byte) error {
+	_, err := db.rename(src, dst, false)
+	return err
+}
+
+// RenameNX is Rename but it returns false and changes nothing if dst exists
+// as any data type.
+func (db *DB) RenameNX(src []byte, dst []byte) (bool, error) {
+	return db.rename(src, dst, true)
+}
+
+func (db *DB) rename(src []byte, dst []byte, nx bool) (bool, error) {
+	if err := checkKeySize(src); err != nil {
+		return false, err
+	} else if err := checkKeySize(dst); err != nil {
+		return false, err
+	}
+
+	t := db.newExclusiveBatch()
+	t.Lock()
+	defer t.Unlock()
+
+	for _, dataType := range expireTypes {
+		if err := db.expireOnWrite(t, dataType, src, dst); err != nil {
+			return false, err
+		}
+	}
+
+	var srcTypes []byte
+	var dstTypes []byte
+	for _, dataType := range expireTypes {
+		if n, err := db.keyExists(dataType, src); err != nil {
+			return false, err
+		} else if n == 1 {
+			srcTypes = append(srcTypes, dataType)
+		}
+
+		if n, err := db.keyExists(dataType, dst); err != nil {
+			return false, err
+		} else if n == 1 {
+			dstTypes = append(dstTypes, dataType)
+		}
+	}
+
+	if len(srcTypes) == 0 {
+		return false, ErrNoSuchKey
+	} else if string(src) == string(dst) {
+		return !nx, nil
+	} else if len(dstTypes) > 0 && nx {
+		return false, nil
+	}
+
+	for _, dataType := range dstTypes {
+		db.ttlChecker.cbs[dataType](t, dst)
+		if _, err := db.rmExpire(t, dataType, dst); err != nil {
+			return false, err
+		}
+	}
+
+	for _, dataType := range srcTypes {
+		if err := db.copyType(t, dataType, src, db, dst); err != nil {
+			return false, err
+		}
+
+		when, err := Int64(db.bucket.Get(db.expEncodeMetaKey(dataType, src)))
+		if err != nil {
+			return false, err
+		} else if when > 0 {
+			db.expireAt(t, dataType, dst, when)
+		}
+
+		db.ttlChecker.cbs[dataType](t, src)
+		if _, err := db.rmExpire(t, dataType, src); err != nil {
+			return false, err
+		}
+	}
+
+	if err := t.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// copyType copies the dataType of src to dst in the database to, which can
+// be db itself.
+func (db *DB) copyType(t *batch, dataType byte, src []byte, to *DB, dst []byte) error {
+	switch dataType {
+	case KVType:
+		return db.copyKey(t, db.encodeKVKey(src), to.encodeKVKey(dst))
+	case HLLType:
+		return db.copyKey(t, db.hllEncodeKey(src), to.hllEncodeKey(dst))
+	case HashType:
+		return db.hCopy(t, src, to, dst)
+	case ListType:
+		return db.lCopy(t, src, to, dst)
+	case SetType:
+		return db.sCopy(t, src, to, dst)
+	case ZSetType:
+		return db.zCopy(t, src, to, dst)
+	case StreamType:
+		return db.xCopy(t, src, to, dst)
+	default:
+		return errExpType
+	}
+}
+
+func (db *DB) copyKey(t *batch, srcKey []byte, dstKey []byte) error {
+	v, err := db.bucket.Get(srcKey)
+	if err != nil {
+		return err
+	} else if v != nil {
+		t.Put(dstKey, v)
+	}
+	return nil
+}
+
+// copyRange copies every key in [min, max] with the key mapped by f.
+func (db *DB) copyRange(t *batch, min []byte, max []byte, rangeType uint8, f func(ek []byte, v []byte) ([]byte, error)) error {
+	it := db.bucket.RangeLimitIterator(min, max, rangeType, 0, -1)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		v := it.Value()
+		ek, err := f(it.RawKey(), v)
+		if err != nil {
+			return err
+		}
+		t.Put(ek, v)
+	}
+
+	return nil
+}
+
+func (db *DB) hCopy(t *batch, src []byte, to *DB, dst []byte) error {
+	err := db.copyRange(t, db.hEncodeStartKey(src), db.hEncodeStopKey(src), store.RangeROpen,
+		func(ek []byte, v []byte) ([]byte, error) {
+			_, field, err := db.hDecodeHashKey(ek)
+			if err != nil {
+				return nil, err
+			}
+			return to.hEncodeHashKey(dst, field), nil
+		})
+	if err != nil {
+		return err
+	} else if err = db.copyKey(t, db.hEncodeZipKey(src), to.hEncodeZipKey(dst)); err != nil {
+		return err
+	}
+
+	return db.copyKey(t, db.hEncodeSizeKey(src), to.hEncodeSizeKey(dst))
+}
+
+func (db *DB) lCopy(t *batch, src []byte, to *DB, dst []byte) error {
+	headSeq, tailSeq, _, err := db.lGetMeta(nil, db.lEncodeMetaKey(src))
+	if err != nil {
+		return err
+	}
+
+	err = db.copyRange(t, db.lEncodeListKey(src, headSeq), db.lEncodeListKey(src, tailSeq), store.RangeClose,
+		func(ek []byte, v []byte) ([]byte, error) {
+			_, seq, err := db.lDecodeListKey(ek)
+			if err != nil {
+				return nil, err
+			}
+			return to.lEncodeListKey(dst, seq), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return db.copyKey(t, db.lEncodeMetaKey(src), to.lEncodeMetaKey(dst))
+}
+
+func (db *DB) sCopy(t *batch, src []byte, to *DB, dst []byte) error {
+	err := db.copyRange(t, db.sEncodeStartKey(src), db.sEncodeStopKey(src), store.RangeROpen,
+		func(ek []byte, v []byte) ([]byte, error) {
+			_, member, err := db.sDecodeSetKey(ek)
+			if err != nil {
+				return nil, err
+			}
+			return to.sEncodeSetKey(dst, member), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return db.copyKey(t, db.sEncodeSizeKey(src), to.sEncodeSizeKey(dst))
+}
+
+func (db *DB) zCopy(t *batch, src []byte, to *DB, dst []byte) error {
+	err := db.copyRange(t, db.zEncodeStartSetKey(src), db.zEncodeStopSetKey(src), store.RangeROpen,
+		func(ek []byte, v []byte) ([]byte, error) {
+			_, member, err := db.zDecodeSetKey(ek)
+			if err != nil {
+				return nil, err
+			}
+
+			score, err := Int64(v, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			t.Put(to.zEncodeScoreKey(dst, member, score), []byte{})
+			return to.zEncodeSetKey(dst, member), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return db.copyKey(t, db.zEncodeSizeKey(src), to.zEncodeSizeKey(dst))
+}
+
+func (db *DB) xCopy(t *batch, src []byte, to *DB, dst []byte) error {
+	err := db.copyRange(t, db.xEncodeEntryKey(src, streamIDMin), db.xEncodeEntryKey(src, streamIDMax), store.RangeClose,
+		func(ek []byte, v []byte) ([]byte, error) {
+			_, id, err := db.xDecodeEntryKey(ek)
+			if err != nil {
+				return nil, err
+			}
+			return to.xEncodeEntryKey(dst, id), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	// the consumer groups and their pending entries
+	srcPrefix, dstPrefix := db.xEncodeKeyPrefix(src), to.xEncodeKeyPrefix(dst)
+	err = db.copyRange(t, append(srcPrefix, streamGroupSep), store.PrefixEnd(srcPrefix), store.RangeROpen,
+		func(ek []byte, v []byte) ([]byte, error) {
+			return append(append([]byte(nil), dstPrefix...), ek[len(srcPrefix):]...), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return db.copyKey(t, db.xEncodeMetaKey(src), to.xEncodeMetaKey(dst))
+}
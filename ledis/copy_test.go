@@ -0,0 +1,253 @@
+package ledis
+
+import (
+	"testing"
+)
+
+func TestDBCopy(t *testing.T) {
+	db := getTestDB()
+
+	src := []byte("testdb_copy_src")
+	dst := []byte("testdb_copy_dst")
+
+	db.Del(src, dst)
+	db.HClear(src)
+	db.HClear(dst)
+	db.LClear(src)
+	db.ZClear(src)
+	db.SClear(src)
+	db.XClear(src)
+	db.LClear(dst)
+	db.ZClear(dst)
+	db.SClear(dst)
+	db.XClear(dst)
+
+	if ok, err := db.Copy(src, dst, false, CopyOptions{}); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not copy a missing key")
+	}
+
+	if _, err := db.Copy(src, src, false, CopyOptions{}); err != errCopySameKey {
+		t.Fatal(err)
+	}
+
+	db.Set(src, []byte("v"))
+	db.HSet(src, []byte("f"), []byte("v"))
+	db.RPush(src, []byte("1"), []byte("2"), []byte("3"))
+	db.SAdd(src, []byte("a"), []byte("b"))
+	db.ZAdd(src, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")})
+	db.XAdd(src, "1-1", FVPair{[]byte("f"), []byte("v")})
+	db.Expire(src, 100)
+
+	if ok, err := db.Copy(src, dst, false, CopyOptions{WithTTL: true}); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("must copy")
+	}
+
+	if v, _ := db.Get(dst); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+	if n, _ := db.TTL(dst); n <= 0 {
+		t.Fatal(n)
+	}
+	if v, _ := db.HGet(dst, []byte("f")); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+	if v, _ := db.LRange(dst, 0, -1); len(v) != 3 || string(v[2]) != "3" {
+		t.Fatal(v)
+	}
+	if n, _ := db.SCard(dst); n != 2 {
+		t.Fatal(n)
+	}
+	if v, _ := db.ZRangeByScore(dst, 2, 2, 0, -1); len(v) != 1 || string(v[0].Member) != "b" {
+		t.Fatal(v)
+	}
+	if n, _ := db.XLen(dst); n != 1 {
+		t.Fatal(n)
+	}
+
+	// the copy is independent of the source
+	db.RPush(dst, []byte("4"))
+	if n, _ := db.LLen(src); n != 3 {
+		t.Fatal(n)
+	}
+
+	if ok, err := db.Copy(src, dst, false, CopyOptions{}); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not overwrite")
+	}
+
+	db.HClear(src)
+	db.LClear(src)
+	db.SClear(src)
+	db.ZClear(src)
+	db.XClear(src)
+
+	if ok, err := db.Copy(src, dst, true, CopyOptions{}); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("must copy")
+	}
+
+	if n, _ := db.TTL(dst); n != -1 {
+		t.Fatal(n)
+	}
+	if n, _ := db.LKeyExists(dst); n != 0 {
+		t.Fatal(n)
+	}
+	if n, _ := db.ZCard(dst); n != 0 {
+		t.Fatal(n)
+	}
+	if v, _ := db.Get(dst); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+}
+
+func TestDBMove(t *testing.T) {
+	db := getTestDB()
+	to, _ := db.l.Select(3)
+
+	key := []byte("testdb_move")
+
+	db.Del(key)
+	db.ZClear(key)
+	to.Del(key)
+	to.HClear(key)
+	to.ZClear(key)
+
+	if ok, err := db.Move(key, 3); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not move a missing key")
+	}
+
+	if _, err := db.Move(key, 0); err != errMoveSameDB {
+		t.Fatal(err)
+	}
+
+	db.Set(key, []byte("v"))
+	db.ZAdd(key, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")})
+	db.Expire(key, 100)
+	db.ZExpire(key, 100)
+
+	// a failed move leaves both databases unchanged
+	to.HSet(key, []byte("f"), []byte("v"))
+	if ok, err := db.Move(key, 3); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not move to an existing key")
+	}
+
+	if v, _ := db.Get(key); string(v) != "v" {
+		t.Fatal(string(v))
+	} else if n, _ := db.ZCard(key); n != 2 {
+		t.Fatal(n)
+	} else if n, _ := to.HLen(key); n != 1 {
+		t.Fatal(n)
+	} else if n, _ := to.Exists(key); n != 0 {
+		t.Fatal(n)
+	}
+
+	to.HClear(key)
+	if ok, err := db.Move(key, 3); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("must move")
+	}
+
+	if n, _ := db.Exists(key); n != 0 {
+		t.Fatal(n)
+	} else if n, _ := db.ZCard(key); n != 0 {
+		t.Fatal(n)
+	} else if n, _ := db.TTL(key); n != -1 {
+		t.Fatal(n)
+	} else if n, _ := db.ZTTL(key); n != -1 {
+		t.Fatal(n)
+	}
+
+	if v, _ := to.Get(key); string(v) != "v" {
+		t.Fatal(string(v))
+	} else if v, _ := to.ZRangeByScore(key, 2, 2, 0, -1); len(v) != 1 || string(v[0].Member) != "b" {
+		t.Fatal(v)
+	} else if n, _ := to.TTL(key); n <= 0 {
+		t.Fatal(n)
+	} else if n, _ := to.ZTTL(key); n <= 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestDBRename(t *testing.T) {
+	db := getTestDB()
+
+	src := []byte("testdb_rename_src")
+	dst := []byte("testdb_rename_dst")
+
+	for _, key := range [][]byte{src, dst} {
+		db.Del(key)
+		db.HClear(key)
+		db.LClear(key)
+		db.SClear(key)
+	}
+
+	if err := db.Rename(src, dst); err != ErrNoSuchKey {
+		t.Fatal(err)
+	}
+
+	db.Set(src, []byte("v"))
+	db.LPush(src, []byte("a"), []byte("b"))
+	db.LExpire(src, 100)
+	db.HSet(dst, []byte("f"), []byte("v"))
+	db.SAdd(dst, []byte("m"))
+
+	if ok, err := db.RenameNX(src, dst); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not rename to an existing key")
+	} else if n, _ := db.LLen(src); n != 2 {
+		t.Fatal(n)
+	}
+
+	if ok, err := db.RenameNX(src, src); err != nil || ok {
+		t.Fatal(ok, err)
+	} else if err := db.Rename(src, src); err != nil {
+		t.Fatal(err)
+	} else if n, _ := db.LLen(src); n != 2 {
+		t.Fatal(n)
+	}
+
+	// every data type of dst is replaced
+	if err := db.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := db.Exists(src); n != 0 {
+		t.Fatal(n)
+	} else if n, _ := db.LLen(src); n != 0 {
+		t.Fatal(n)
+	} else if n, _ := db.LTTL(src); n != -1 {
+		t.Fatal(n)
+	}
+
+	if v, _ := db.Get(dst); string(v) != "v" {
+		t.Fatal(string(v))
+	} else if v, _ := db.LRange(dst, 0, -1); len(v) != 2 || string(v[0]) != "b" {
+		t.Fatal(v)
+	} else if n, _ := db.LTTL(dst); n <= 0 {
+		t.Fatal(n)
+	} else if n, _ := db.TTL(dst); n != -1 {
+		t.Fatal(n)
+	} else if n, _ := db.HLen(dst); n != 0 {
+		t.Fatal(n)
+	} else if n, _ := db.SCard(dst); n != 0 {
+		t.Fatal(n)
+	}
+
+	if ok, err := db.RenameNX(dst, src); err != nil || !ok {
+		t.Fatal(ok, err)
+	} else if v, _ := db.Get(src); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+}
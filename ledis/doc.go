@@ -13,6 +13,11 @@
 // cfg is a Config instance which contains configuration for ledis use,
 // like DataDir (root directory for ledis working to store data).
 //
+// The replication logs by github.com/siddontang/go/log, WithLogger gives it
+// another Logger, like NopLogger for no output:
+//
+//  l := ledis.Open(cfg, ledis.WithLogger(ledis.NopLogger{}))
+//
 // After you create a ledis instance, you can select a DB to store you data:
 //
 //  db, _ := l.Select(0)
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"io"
 	"os"
+	"time"
 
 	"github.com/siddontang/go/snappy"
 	"github.com/siddontang/ledisdb/store"
@@ -59,6 +60,9 @@ func (l *Ledis) Dump(w io.Writer) error {
 	}
 	defer snap.Close()
 
+	l.changes.Set(0)
+	l.lastSave.Set(time.Now().Unix())
+
 	l.wLock.Unlock()
 
 	wb := bufio.NewWriterSize(w, 4096)
@@ -210,6 +214,11 @@ func (l *Ledis) LoadDump(r io.Reader) (*DumpHead, error) {
 	deKeyBuf = nil
 	deValueBuf = nil
 
+	// the dump may have the ACL users
+	if err = l.loadACL(); err != nil {
+		return nil, err
+	}
+
 	if l.r != nil {
 		if err := l.r.UpdateCommitID(h.CommitID); err != nil {
 			return nil, err
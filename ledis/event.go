@@ -49,6 +49,13 @@ func formatEventKey(buf []byte, k []byte) ([]byte, error) {
 			return nil, err
 		}
 
+		buf = strconv.AppendQuote(buf, hack.String(key))
+	case HZipType:
+		key, err := db.hDecodeZipKey(k)
+		if err != nil {
+			return nil, err
+		}
+
 		buf = strconv.AppendQuote(buf, hack.String(key))
 	case ListType:
 		key, seq, err := db.lDecodeListKey(k)
@@ -107,6 +114,42 @@ func formatEventKey(buf []byte, k []byte) ([]byte, error) {
 			return nil, err
 		}
 
+		buf = strconv.AppendQuote(buf, hack.String(key))
+	case HLLType:
+		key, err := db.hllDecodeKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = strconv.AppendQuote(buf, hack.String(key))
+	case StreamType:
+		key, id, err := db.xDecodeEntryKey(k)
+		if err == nil {
+			buf = strconv.AppendQuote(buf, hack.String(key))
+			buf = append(buf, ' ')
+			buf = append(buf, id.String()...)
+			break
+		}
+
+		// a consumer group or its pending entry
+		key, group, id, pending, err := db.xDecodeGroupItemKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		buf = strconv.AppendQuote(buf, hack.String(key))
+		buf = append(buf, " group "...)
+		buf = strconv.AppendQuote(buf, hack.String(group))
+		if pending {
+			buf = append(buf, ' ')
+			buf = append(buf, id.String()...)
+		}
+	case StreamMetaType:
+		key, err := db.xDecodeMetaKey(k)
+		if err != nil {
+			return nil, err
+		}
+
 		buf = strconv.AppendQuote(buf, hack.String(key))
 	case ExpTimeType:
 		tp, key, t, err := db.expDecodeTimeKey(k)
@@ -0,0 +1,318 @@
+package ledis
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/ledisdb/store"
+)
+
+const (
+	evictCheckInterval = time.Second
+
+	// at most this many keys are evicted before the store is compacted and
+	// its size checked again, the deleted data only frees the space after
+	// the compaction
+	maxEvictKeys = 128
+
+	// CLIENT NO-EVICT keeps the keys of a command from eviction this long
+	noEvictTime = time.Second
+)
+
+// evictTypes are the data types which are evicted, with the store type of
+// their keys.
+var evictTypes = []struct {
+	dataType  byte
+	storeType byte
+}{
+	{KVType, KVType},
+	{ListType, LMetaType},
+	{HashType, HSizeType},
+	{SetType, SSizeType},
+	{ZSetType, ZSizeType},
+	{HLLType, HLLType},
+	{StreamType, StreamMetaType},
+}
+
+type evictCandidate struct {
+	db       *DB
+	dataType byte
+	key      []byte
+	// the expire time in milliseconds, 0 if the key has no ttl
+	when int64
+	// the best candidate has the smallest score
+	score int64
+}
+
+// UsedMemory returns the size of the store last checked for maxmemory, the
+// store files on disk, or the heap for the memory store.
+func (l *Ledis) UsedMemory() int64 {
+	return l.usedMemory.Get()
+}
+
+// EvictedKeys returns how many keys are evicted over maxmemory.
+func (l *Ledis) EvictedKeys() int64 {
+	return l.evictedKeys.Get()
+}
+
+func (l *Ledis) onEvict() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(evictCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.checkMaxMemory()
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// checkMaxMemory evicts the keys by maxmemory_policy if the store is bigger
+// than maxmemory, up to maxEvictKeys each time.
+func (l *Ledis) checkMaxMemory() {
+	size, err := l.ldb.Size()
+	if err != nil {
+		log.Errorf("get store size error %s", err.Error())
+		return
+	}
+	l.usedMemory.Set(size)
+
+	var maxMemory int64
+	var policy string
+	var samples int
+	l.cfg.View(func() {
+		maxMemory = l.cfg.MaxMemory
+		policy = l.cfg.MaxMemoryPolicy
+		samples = l.cfg.EvictionSamples
+	})
+
+	if maxMemory <= 0 || size <= maxMemory || policy == "noeviction" || l.IsReadOnly() {
+		return
+	}
+
+	n := 0
+	for ; n < maxEvictKeys; n++ {
+		c, err := l.evictCandidate(policy, samples)
+		if err != nil {
+			log.Errorf("sample eviction keys error %s", err.Error())
+			break
+		} else if c == nil {
+			break
+		}
+
+		if err = c.db.evict(c.dataType, c.key); err != nil {
+			log.Errorf("evict %s key %q error %s", TypeName[c.dataType], c.key, err.Error())
+			break
+		}
+	}
+
+	if n == 0 {
+		return
+	}
+	l.evictedKeys.Add(int64(n))
+
+	if err = l.CompactStore(); err != nil {
+		log.Errorf("compact store after eviction error %s", err.Error())
+	} else if size, err = l.ldb.Size(); err == nil {
+		l.usedMemory.Set(size)
+	}
+}
+
+// evictCandidate samples the keys of the open databases and returns the
+// best one to evict by policy, nil if there is none.
+func (l *Ledis) evictCandidate(policy string, samples int) (*evictCandidate, error) {
+	l.dbLock.Lock()
+	dbs := make([]*DB, 0, len(l.dbs))
+	for _, db := range l.dbs {
+		dbs = append(dbs, db)
+	}
+	l.dbLock.Unlock()
+
+	if len(dbs) == 0 {
+		return nil, nil
+	}
+
+	volatile := strings.HasPrefix(policy, "volatile-")
+	if strings.HasSuffix(policy, "-lfu") {
+		return l.lfuEvictCandidate(dbs, samples, volatile)
+	}
+
+	now := time.Now()
+
+	var best *evictCandidate
+	for i := 0; i < samples; i++ {
+		// the first database with any key from a random one
+		var c *evictCandidate
+		for j, start := 0, rand.Intn(len(dbs)); j < len(dbs) && c == nil; j++ {
+			var err error
+			if c, err = dbs[(start+j)%len(dbs)].sampleEvictKey(volatile); err != nil {
+				return nil, err
+			}
+		}
+
+		if c == nil {
+			break
+		} else if c.db.access.noEvicted(c.key, now) {
+			continue
+		}
+
+		c.score = c.db.evictScore(policy, c, now)
+		if best == nil || c.score < best.score {
+			best = c
+		}
+
+		if strings.HasSuffix(policy, "-random") {
+			break
+		}
+	}
+	return best, nil
+}
+
+// evictScore returns the score of c by policy, the smaller the better to
+// evict: the least recently used or the nearest to expire. The LFU policies
+// pick their keys with lfuEvictCandidate.
+func (db *DB) evictScore(policy string, c *evictCandidate, now time.Time) int64 {
+	switch {
+	case policy == "volatile-ttl":
+		return c.when
+	case strings.HasSuffix(policy, "-lru"):
+		return db.access.entry(c.key).last
+	default:
+		return 0
+	}
+}
+
+// sampleEvictKey returns a random key of db, or a random key with a ttl if
+// volatile, nil if there is none. The data type is chosen first, the first
+// one with any key from a random one.
+func (db *DB) sampleEvictKey(volatile bool) (*evictCandidate, error) {
+	if volatile {
+		mk, v := db.sampleRawKey(db.expEncodeMetaKey(NoneType, nil), db.expEncodeMetaKey(maxDataType, nil))
+		if mk == nil {
+			return nil, nil
+		}
+
+		dataType, key, err := db.expDecodeMetaKey(mk)
+		if err != nil {
+			return nil, err
+		}
+
+		when, err := Int64(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &evictCandidate{db: db, dataType: dataType, key: key, when: expireTimeMs(when)}, nil
+	}
+
+	start := rand.Intn(len(evictTypes))
+	for i := range evictTypes {
+		tp := evictTypes[(start+i)%len(evictTypes)]
+
+		minKey, maxKey, err := db.buildScanKeyRange(tp.storeType, nil, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if ek, _ := db.sampleRawKey(minKey, maxKey); ek != nil {
+			key, err := db.decodeScanKey(tp.storeType, ek)
+			if err != nil {
+				return nil, err
+			}
+			return &evictCandidate{db: db, dataType: tp.dataType, key: key}, nil
+		}
+	}
+	return nil, nil
+}
+
+// sampleRawKey returns the key and the value of a random entry in the open
+// range (minKey, maxKey), nil if the range is empty. It seeks to a random key
+// between the first and the last one, so the keys after the bigger gaps are
+// more likely, but the keys of a common prefix are still spread.
+func (db *DB) sampleRawKey(minKey []byte, maxKey []byte) ([]byte, []byte) {
+	it := db.bucket.RangeLimitIterator(minKey, maxKey, store.RangeOpen, 0, 1)
+	if !it.Valid() {
+		it.Close()
+		return nil, nil
+	}
+	first, firstValue := it.Key(), it.Value()
+	it.Close()
+
+	it = db.bucket.RevRangeLimitIterator(minKey, maxKey, store.RangeOpen, 0, 1)
+	last := it.Key()
+	it.Close()
+
+	it = db.bucket.RangeLimitIterator(randomKeyBetween(first, last), maxKey, store.RangeROpen, 0, 1)
+	defer it.Close()
+
+	// wrap around to the first key
+	if !it.Valid() {
+		return first, firstValue
+	}
+	return it.Key(), it.Value()
+}
+
+// randomKeyBetween returns a random key whose byte after the common prefix
+// of lo and hi is between theirs, lo <= hi.
+func randomKeyBetween(lo []byte, hi []byte) []byte {
+	p := 0
+	for p < len(lo) && p < len(hi) && lo[p] == hi[p] {
+		p++
+	}
+	if p == len(hi) {
+		return hi
+	}
+
+	min := 0
+	if p < len(lo) {
+		min = int(lo[p])
+	}
+
+	k := make([]byte, p+1+8)
+	copy(k, hi[:p])
+	k[p] = byte(min + rand.Intn(int(hi[p])-min+1))
+	rand.Read(k[p+1:])
+	return k
+}
+
+// evict deletes key of dataType with its ttl, the deletes are notified as
+// evicted.
+func (db *DB) evict(dataType byte, key []byte) error {
+	t := db.ttlChecker.txs[dataType]
+	cb := db.ttlChecker.cbs[dataType]
+	if cb == nil {
+		return errDataType
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	cb(t, key)
+
+	mk := db.expEncodeMetaKey(dataType, key)
+	if when, err := Int64(db.bucket.Get(mk)); err != nil {
+		return err
+	} else if when != 0 {
+		t.Delete(db.expEncodeTimeKey(dataType, key, when))
+		t.Delete(mk)
+	}
+
+	t.delEvent = EventEvicted
+	err := t.Commit()
+	t.delEvent = ""
+	return err
+}
+
+// NoEvict keeps the keys from eviction for a while, they are used by a
+// client after CLIENT NO-EVICT ON.
+func (db *DB) NoEvict(keys ...[]byte) {
+	until := time.Now().Add(noEvictTime).UnixNano()
+	for _, key := range keys {
+		db.access.noEvict(key, until)
+	}
+}
@@ -0,0 +1,125 @@
+package ledis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestEvictCandidate(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_ledis_evict"
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+	for _, key := range []string{"a", "b", "c"} {
+		db.Set([]byte(key), []byte("v"))
+		db.Get([]byte(key))
+	}
+	db.HSet([]byte("d"), []byte("f"), []byte("v"))
+	db.HGet([]byte("d"), []byte("f"))
+	db.Expire([]byte("b"), 100)
+	db.HExpire([]byte("d"), 10)
+
+	db.access.Lock()
+	db.access.keys["c"].last -= 100
+	db.access.keys["d"].last -= 50
+	db.access.Unlock()
+
+	for _, test := range []struct {
+		policy string
+		key    string
+	}{
+		{"allkeys-lru", "c"},
+		{"volatile-lru", "d"},
+		{"volatile-ttl", "d"},
+	} {
+		if c, err := l.evictCandidate(test.policy, 100); err != nil {
+			t.Fatal(err)
+		} else if c == nil || string(c.key) != test.key {
+			t.Fatal(test.policy, c)
+		}
+	}
+
+	db.NoEvict([]byte("c"))
+	if c, err := l.evictCandidate("allkeys-lru", 100); err != nil {
+		t.Fatal(err)
+	} else if c == nil || string(c.key) == "c" {
+		t.Fatal(c)
+	}
+
+	if c, err := l.evictCandidate("allkeys-random", 5); err != nil || c == nil {
+		t.Fatal(c, err)
+	}
+}
+
+func TestCheckMaxMemory(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_ledis_maxmemory"
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+	db.Set([]byte("a"), []byte("v"))
+	db.LPush([]byte("b"), []byte("v"))
+	db.Set([]byte("c"), []byte("v"))
+	db.Expire([]byte("c"), 100)
+
+	ch, cancel := l.Subscribe("__keyevent@0__:evicted")
+	defer cancel()
+
+	// no limit
+	l.checkMaxMemory()
+	if n := l.EvictedKeys(); n != 0 {
+		t.Fatal(n)
+	} else if l.UsedMemory() <= 0 {
+		t.Fatal(l.UsedMemory())
+	}
+
+	cfg.Update(func() {
+		cfg.MaxMemory = 1
+		cfg.MaxMemoryPolicy = "volatile-lru"
+	})
+	l.checkMaxMemory()
+	if n := l.EvictedKeys(); n != 1 {
+		t.Fatal(n)
+	} else if n, _ := db.Exists([]byte("c")); n != 0 {
+		t.Fatal(n)
+	} else if v, _ := db.bucket.Get(db.expEncodeMetaKey(KVType, []byte("c"))); v != nil {
+		t.Fatal("ttl not deleted")
+	}
+
+	select {
+	case n := <-ch:
+		if string(n.Key) != "c" {
+			t.Fatal(n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no evicted event")
+	}
+
+	cfg.Update(func() { cfg.MaxMemoryPolicy = "allkeys-lfu" })
+	l.checkMaxMemory()
+	if n := l.EvictedKeys(); n != 3 {
+		t.Fatal(n)
+	} else if n, _ := db.Exists([]byte("a")); n != 0 {
+		t.Fatal(n)
+	} else if n, _ := db.LLen([]byte("b")); n != 0 {
+		t.Fatal(n)
+	}
+}
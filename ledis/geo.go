@@ -0,0 +1,286 @@
+package ledis
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+/*
+	The geo data is saved in the zset, like Redis, the score is the
+	52 bits geohash which interleaves 26 bits latitude and 26 bits longitude.
+*/
+
+const (
+	geoStepMax = 26
+
+	geoLatMin = -85.05112878
+	geoLatMax = 85.05112878
+	geoLonMin = -180.0
+	geoLonMax = 180.0
+
+	// earth radius used by Redis
+	geoEarthRadius = 6372797.560856
+)
+
+var errGeoCoord = errors.New("invalid longitude, latitude pair")
+var errGeoUnit = errors.New("unsupported unit, must be m, km, ft or mi")
+
+// GeoMember is a named location.
+type GeoMember struct {
+	Name []byte
+	Lat  float64
+	Lon  float64
+}
+
+// GeoRadiusOptions are the options for GeoRadius.
+type GeoRadiusOptions struct {
+	// Count limits the results if greater than 0.
+	Count int
+	// Sort is 1 for ascending distance, -1 for descending, 0 for unsorted,
+	// but ascending if Count is set.
+	Sort int
+}
+
+// GeoResult is a location found by GeoRadius.
+type GeoResult struct {
+	GeoMember
+
+	// Dist is the distance to the center in the query unit.
+	Dist float64
+	// Hash is the geohash score.
+	Hash int64
+}
+
+// geoUnit returns the meters of the unit.
+func geoUnit(unit string) (float64, error) {
+	switch unit {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	default:
+		return 0, errGeoUnit
+	}
+}
+
+func checkGeoCoord(lat float64, lon float64) error {
+	if lat < geoLatMin || lat > geoLatMax || lon < geoLonMin || lon > geoLonMax {
+		return errGeoCoord
+	}
+	return nil
+}
+
+// spread moves the low 32 bits to the even bits.
+func geoSpread(v uint64) uint64 {
+	v &= 0xFFFFFFFF
+	v = (v | v<<16) & 0x0000FFFF0000FFFF
+	v = (v | v<<8) & 0x00FF00FF00FF00FF
+	v = (v | v<<4) & 0x0F0F0F0F0F0F0F0F
+	v = (v | v<<2) & 0x3333333333333333
+	v = (v | v<<1) & 0x5555555555555555
+	return v
+}
+
+// squash moves the even bits to the low 32 bits.
+func geoSquash(v uint64) uint64 {
+	v &= 0x5555555555555555
+	v = (v | v>>1) & 0x3333333333333333
+	v = (v | v>>2) & 0x0F0F0F0F0F0F0F0F
+	v = (v | v>>4) & 0x00FF00FF00FF00FF
+	v = (v | v>>8) & 0x0000FFFF0000FFFF
+	v = (v | v>>16) & 0x00000000FFFFFFFF
+	return v
+}
+
+// geoEncode returns the geohash with step bits for each of latitude and longitude.
+func geoEncode(lat float64, lon float64, step uint) uint64 {
+	latOffset := (lat - geoLatMin) / (geoLatMax - geoLatMin)
+	lonOffset := (lon - geoLonMin) / (geoLonMax - geoLonMin)
+
+	n := float64(uint64(1) << step)
+	latBits := uint64(latOffset * n)
+	lonBits := uint64(lonOffset * n)
+
+	// the max coordinate belongs to the last cell
+	if latBits >= uint64(n) {
+		latBits = uint64(n) - 1
+	}
+	if lonBits >= uint64(n) {
+		lonBits = uint64(n) - 1
+	}
+
+	return geoSpread(latBits) | geoSpread(lonBits)<<1
+}
+
+// geoDecode returns the center of the geohash cell.
+func geoDecode(hash uint64, step uint) (lat float64, lon float64) {
+	n := float64(uint64(1) << step)
+
+	latBits := float64(geoSquash(hash))
+	lonBits := float64(geoSquash(hash >> 1))
+
+	lat = geoLatMin + (latBits+0.5)/n*(geoLatMax-geoLatMin)
+	lon = geoLonMin + (lonBits+0.5)/n*(geoLonMax-geoLonMin)
+	return
+}
+
+func geoRad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+// geoDistance returns the distance in meters with the haversine formula.
+func geoDistance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	lat1r := geoRad(lat1)
+	lat2r := geoRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin(geoRad(lon2-lon1) / 2)
+	return 2 * geoEarthRadius * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+// GeoAdd adds the locations, it returns the number of new members.
+func (db *DB) GeoAdd(key []byte, members ...GeoMember) (int64, error) {
+	args := make([]ScorePair, len(members))
+	for i, m := range members {
+		if err := checkGeoCoord(m.Lat, m.Lon); err != nil {
+			return 0, err
+		}
+
+		args[i] = ScorePair{Score: int64(geoEncode(m.Lat, m.Lon, geoStepMax)), Member: m.Name}
+	}
+
+	return db.ZAdd(key, args...)
+}
+
+// GeoPos returns the locations of the members, nil if the member does not exist.
+func (db *DB) GeoPos(key []byte, members ...[]byte) ([]*GeoMember, error) {
+	pos := make([]*GeoMember, len(members))
+	for i, m := range members {
+		score, err := db.ZScore(key, m)
+		if err == ErrScoreMiss {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		lat, lon := geoDecode(uint64(score), geoStepMax)
+		pos[i] = &GeoMember{Name: m, Lat: lat, Lon: lon}
+	}
+
+	return pos, nil
+}
+
+// GeoDist returns the distance between two members in unit, m, km, ft or mi,
+// ErrScoreMiss is returned if any member does not exist.
+func (db *DB) GeoDist(key []byte, member1 []byte, member2 []byte, unit string) (float64, error) {
+	u, err := geoUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+
+	pos, err := db.GeoPos(key, member1, member2)
+	if err != nil {
+		return 0, err
+	} else if pos[0] == nil || pos[1] == nil {
+		return 0, ErrScoreMiss
+	}
+
+	return geoDistance(pos[0].Lat, pos[0].Lon, pos[1].Lat, pos[1].Lon) / u, nil
+}
+
+// GeoRadius returns the members within the radius in unit of the center.
+func (db *DB) GeoRadius(key []byte, lat float64, lon float64, radius float64, unit string, opts GeoRadiusOptions) ([]GeoResult, error) {
+	u, err := geoUnit(unit)
+	if err != nil {
+		return nil, err
+	} else if err = checkGeoCoord(lat, lon); err != nil {
+		return nil, err
+	} else if radius < 0 {
+		return nil, errGeoCoord
+	}
+
+	meters := radius * u
+
+	// find the step whose cell is not smaller than the radius, so the
+	// center cell and its 8 neighbors cover the whole circle
+	latDelta := meters / geoEarthRadius * 180 / math.Pi
+	lonDelta := 360.0
+	if c := math.Cos(geoRad(lat)); c > 0 {
+		lonDelta = math.Min(latDelta/c, 360)
+	}
+
+	step := uint(geoStepMax)
+	for step > 1 {
+		n := float64(uint64(1) << step)
+		if (geoLatMax-geoLatMin)/n >= latDelta && (geoLonMax-geoLonMin)/n >= lonDelta {
+			break
+		}
+		step--
+	}
+
+	n := float64(uint64(1) << step)
+	latStep := (geoLatMax - geoLatMin) / n
+	lonStep := (geoLonMax - geoLonMin) / n
+
+	cells := make(map[uint64]struct{}, 9)
+	for _, dLat := range []float64{-1, 0, 1} {
+		for _, dLon := range []float64{-1, 0, 1} {
+			cLat := lat + dLat*latStep
+			if cLat < geoLatMin || cLat > geoLatMax {
+				continue
+			}
+
+			cLon := lon + dLon*lonStep
+			if cLon < geoLonMin {
+				cLon += 360
+			} else if cLon > geoLonMax {
+				cLon -= 360
+			}
+
+			cells[geoEncode(cLat, cLon, step)] = struct{}{}
+		}
+	}
+
+	shift := 2 * (geoStepMax - step)
+
+	var res []GeoResult
+	for cell := range cells {
+		min := int64(cell << shift)
+		max := int64((cell+1)<<shift) - 1
+
+		pairs, err := db.ZRangeByScore(key, min, max, 0, -1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pairs {
+			pLat, pLon := geoDecode(uint64(p.Score), geoStepMax)
+			d := geoDistance(lat, lon, pLat, pLon)
+			if d > meters {
+				continue
+			}
+
+			res = append(res, GeoResult{
+				GeoMember: GeoMember{Name: p.Member, Lat: pLat, Lon: pLon},
+				Dist:      d / u,
+				Hash:      p.Score,
+			})
+		}
+	}
+
+	if opts.Sort > 0 || (opts.Sort == 0 && opts.Count > 0) {
+		sort.Slice(res, func(i, j int) bool { return res[i].Dist < res[j].Dist })
+	} else if opts.Sort < 0 {
+		sort.Slice(res, func(i, j int) bool { return res[i].Dist > res[j].Dist })
+	}
+
+	if opts.Count > 0 && len(res) > opts.Count {
+		res = res[:opts.Count]
+	}
+
+	return res, nil
+}
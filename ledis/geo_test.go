@@ -0,0 +1,80 @@
+package ledis
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeoHash(t *testing.T) {
+	for _, c := range [][2]float64{{0, 0}, {37.502669, 15.087269}, {-85.05112878, -180}, {85.05112878, 180}} {
+		lat, lon := geoDecode(geoEncode(c[0], c[1], geoStepMax), geoStepMax)
+		if math.Abs(lat-c[0]) > 1e-5 || math.Abs(lon-c[1]) > 1e-5 {
+			t.Fatal(c, lat, lon)
+		}
+	}
+
+	// the same score as Redis GEOADD
+	if h := geoEncode(37.502669, 15.087269, geoStepMax); h != 3479447370796909 {
+		t.Fatal(h)
+	}
+}
+
+func TestDBGeo(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_geo_a")
+
+	if n, err := db.GeoAdd(key,
+		GeoMember{[]byte("Palermo"), 38.115556, 13.361389},
+		GeoMember{[]byte("Catania"), 37.502669, 15.087269}); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.GeoAdd(key, GeoMember{[]byte("bad"), 90, 0}); err == nil {
+		t.Fatal("must error")
+	}
+
+	if d, err := db.GeoDist(key, []byte("Palermo"), []byte("Catania"), "km"); err != nil {
+		t.Fatal(err)
+	} else if math.Abs(d-166.2742) > 0.001 {
+		t.Fatal(d)
+	}
+
+	if _, err := db.GeoDist(key, []byte("Palermo"), []byte("Rome"), "km"); err != ErrScoreMiss {
+		t.Fatal(err)
+	}
+
+	if pos, err := db.GeoPos(key, []byte("Palermo"), []byte("Rome")); err != nil {
+		t.Fatal(err)
+	} else if pos[1] != nil {
+		t.Fatal(pos[1])
+	} else if math.Abs(pos[0].Lat-38.115556) > 1e-5 || math.Abs(pos[0].Lon-13.361389) > 1e-5 {
+		t.Fatal(pos[0])
+	}
+
+	if res, err := db.GeoRadius(key, 37, 15, 200, "km", GeoRadiusOptions{Sort: 1}); err != nil {
+		t.Fatal(err)
+	} else if len(res) != 2 {
+		t.Fatal(len(res))
+	} else if string(res[0].Name) != "Catania" || math.Abs(res[0].Dist-56.4413) > 0.001 {
+		t.Fatal(string(res[0].Name), res[0].Dist)
+	} else if string(res[1].Name) != "Palermo" || math.Abs(res[1].Dist-190.4424) > 0.001 {
+		t.Fatal(string(res[1].Name), res[1].Dist)
+	}
+
+	if res, err := db.GeoRadius(key, 37, 15, 100, "km", GeoRadiusOptions{}); err != nil {
+		t.Fatal(err)
+	} else if len(res) != 1 || string(res[0].Name) != "Catania" {
+		t.Fatal(res)
+	}
+
+	if res, err := db.GeoRadius(key, 37, 15, 200, "km", GeoRadiusOptions{Count: 1, Sort: -1}); err != nil {
+		t.Fatal(err)
+	} else if len(res) != 1 || string(res[0].Name) != "Palermo" {
+		t.Fatal(res)
+	}
+
+	db.ZClear(key)
+}
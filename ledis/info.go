@@ -0,0 +1,95 @@
+package ledis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+// Info returns the metrics kept by ledis in section, named like the fields
+// of redis INFO: "memory", "persistence", "replication" and "keyspace", or
+// all of them for "all" or "". The other sections are empty, a server fills
+// in its own.
+func (db *DB) Info(section string) (map[string]string, error) {
+	l := db.l
+	all := len(section) == 0 || section == "all"
+
+	m := make(map[string]string)
+	if all || section == "memory" {
+		m["used_memory"] = strconv.FormatInt(l.UsedMemory(), 10)
+		m["evicted_keys"] = strconv.FormatInt(l.EvictedKeys(), 10)
+	}
+
+	if all || section == "persistence" {
+		m["rdb_changes_since_last_save"] = strconv.FormatInt(l.changes.Get(), 10)
+		m["rdb_last_save_time"] = strconv.FormatInt(l.lastSave.Get(), 10)
+	}
+
+	if all || section == "replication" {
+		var offset uint64
+		if s, err := l.ReplicationStat(); err == nil {
+			offset = s.LastID
+			if offset < s.CommitID {
+				offset = s.CommitID
+			}
+		}
+		m["master_replid"] = l.ReplicationID()
+		m["master_repl_offset"] = strconv.FormatUint(offset, 10)
+	}
+
+	if all || section == "keyspace" {
+		for i := 0; i < l.cfg.Databases; i++ {
+			d, err := l.Select(i)
+			if err != nil {
+				return nil, err
+			}
+
+			keys, expires, avgTTL, err := d.keyspaceStat()
+			if err != nil {
+				return nil, err
+			} else if keys > 0 {
+				m[fmt.Sprintf("db%d", i)] = fmt.Sprintf("keys=%d,expires=%d,avg_ttl=%d", keys, expires, avgTTL)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// keyspaceStat returns the number of the keys of db, how many have a ttl,
+// and their average ttl in milliseconds.
+func (db *DB) keyspaceStat() (keys int64, expires int64, avgTTL int64, err error) {
+	if keys, err = db.DBSize(); err != nil || keys == 0 {
+		return
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	var total int64
+	it := db.bucket.RangeLimitIterator(db.expEncodeMetaKey(NoneType, nil), db.expEncodeMetaKey(maxDataType, nil), store.RangeOpen, 0, -1)
+	defer it.Close()
+
+	for steps := 1; it.Valid(); it.Next() {
+		if db.scanCanceled(steps) {
+			return 0, 0, 0, db.ctx.Err()
+		}
+		steps++
+
+		when, err := Int64(it.RawValue(), nil)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		expires++
+		if ttl := expireTimeMs(when) - now; ttl > 0 {
+			total += ttl
+		}
+	}
+
+	if expires > 0 {
+		avgTTL = total / expires
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package ledis
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestDBInfo(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_ledis_info"
+	cfg.UseReplication = true
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+	db.Expire([]byte("b"), 100)
+
+	db2, _ := l.Select(2)
+	db2.HSet([]byte("a"), []byte("f"), []byte("v"))
+
+	m, err := db.Info("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"used_memory", "rdb_last_save_time", "master_replid"} {
+		if _, ok := m[key]; !ok {
+			t.Fatal(key, m)
+		}
+	}
+
+	if v := m["rdb_changes_since_last_save"]; v != "4" {
+		t.Fatal(v)
+	} else if v := m["master_repl_offset"]; v != "4" {
+		t.Fatal(v)
+	} else if v := m["db0"]; !strings.HasPrefix(v, "keys=2,expires=1,avg_ttl=") || strings.HasSuffix(v, "avg_ttl=0") {
+		t.Fatal(v)
+	} else if v := m["db2"]; v != "keys=1,expires=0,avg_ttl=0" {
+		t.Fatal(v)
+	} else if _, ok := m["db1"]; ok {
+		t.Fatal(m)
+	}
+
+	// a save resets the changes
+	if err = l.Dump(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ = db.Info("persistence"); m["rdb_changes_since_last_save"] != "0" {
+		t.Fatal(m)
+	} else if _, ok := m["db0"]; ok {
+		t.Fatal(m)
+	}
+}
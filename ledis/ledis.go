@@ -10,6 +10,7 @@ import (
 
 	"github.com/siddontang/go/filelock"
 	"github.com/siddontang/go/log"
+	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/rpl"
 	"github.com/siddontang/ledisdb/store"
@@ -33,6 +34,9 @@ type Ledis struct {
 	rbatch  *store.WriteBatch
 	rDoneCh chan struct{}
 	rhs     []NewLogEventHandler
+	rfilter ReplicationFilter
+	rwaiter ReplicaWaiter
+	logger  Logger
 
 	wLock      sync.RWMutex //allow one write at same time
 	commitLock sync.Mutex   //allow one write commit at same time
@@ -41,10 +45,34 @@ type Ledis struct {
 
 	ttlCheckers  []*ttlChecker
 	ttlCheckerCh chan *ttlChecker
+	ttlWakeCh    chan struct{}
+
+	expireCbLock sync.Mutex
+	expireCbs    []*expireCallback
+	expireCh     chan expireEvent
+
+	lazyExpiry   bool
+	lazyExpireCh chan lazyExpireEvent
+
+	nm *NotificationManager
+	wm *watchManager
+
+	cs *configStore
+
+	aclLock  sync.RWMutex
+	aclUsers map[string]*ACLUser
+
+	// updated by the maxmemory check
+	usedMemory  sync2.AtomicInt64
+	evictedKeys sync2.AtomicInt64
+
+	// the commits since the last Dump, and its unix time
+	changes  sync2.AtomicInt64
+	lastSave sync2.AtomicInt64
 }
 
 // Open opens the Ledis with a config.
-func Open(cfg *config.Config) (*Ledis, error) {
+func Open(cfg *config.Config, opts ...Option) (*Ledis, error) {
 	if len(cfg.DataDir) == 0 {
 		cfg.DataDir = config.DefaultDataDir
 	}
@@ -61,19 +89,31 @@ func Open(cfg *config.Config) (*Ledis, error) {
 
 	l := new(Ledis)
 	l.cfg = cfg
+	l.logger = rpl.GoLogger{}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.cs = newConfigStore(cfg)
 
 	if l.lock, err = filelock.Lock(path.Join(cfg.DataDir, "LOCK")); err != nil {
 		return nil, err
 	}
 
 	l.quit = make(chan struct{})
+	l.nm = newNotificationManager()
+	l.wm = newWatchManager()
+	l.lastSave.Set(time.Now().Unix())
 
 	if l.ldb, err = store.Open(cfg); err != nil {
 		return nil, err
 	}
 
+	if err = l.loadACL(); err != nil {
+		return nil, err
+	}
+
 	if cfg.UseReplication {
-		if l.r, err = rpl.NewReplication(cfg); err != nil {
+		if l.r, err = rpl.NewReplication(cfg, l.logger); err != nil {
 			return nil, err
 		}
 
@@ -93,8 +133,26 @@ func Open(cfg *config.Config) (*Ledis, error) {
 
 	l.dbs = make(map[int]*DB, 16)
 
+	l.expireCh = make(chan expireEvent, expireEventQueueSize)
+	l.wg.Add(1)
+	go l.onExpired()
+
+	if l.lazyExpiry = cfg.LazyExpiry(); l.lazyExpiry {
+		l.lazyExpireCh = make(chan lazyExpireEvent, expireEventQueueSize)
+		l.wg.Add(1)
+		go l.onLazyExpired()
+	}
+
 	l.checkTTL()
 
+	if size, err := l.ldb.Size(); err == nil {
+		l.usedMemory.Set(size)
+	}
+	l.wg.Add(1)
+	go l.onEvict()
+	l.wg.Add(1)
+	go l.onLFUDecay()
+
 	return l, nil
 }
 
@@ -133,9 +191,11 @@ func (l *Ledis) Select(index int) (*DB, error) {
 	db = l.newDB(index)
 	l.dbs[index] = db
 
-	go func(db *DB) {
-		l.ttlCheckerCh <- db.ttlChecker
-	}(db)
+	if l.cfg.EagerExpiry() {
+		go func(db *DB) {
+			l.ttlCheckerCh <- db.ttlChecker
+		}(db)
+	}
 
 	return db, nil
 }
@@ -159,6 +219,10 @@ func (l *Ledis) flushAll() error {
 
 	n := 0
 	for ; it.Valid(); it.Next() {
+		if isACLKey(it.RawKey()) {
+			continue
+		}
+
 		n++
 		if n == 10000 {
 			if err := w.Commit(); err != nil {
@@ -175,6 +239,8 @@ func (l *Ledis) flushAll() error {
 		return err
 	}
 
+	l.wm.touchAll()
+
 	if l.r != nil {
 		if err := l.r.Clear(); err != nil {
 			log.Fatalf("flush all replication clear error: %s", err.Error())
@@ -200,31 +266,49 @@ func (l *Ledis) IsReadOnly() bool {
 func (l *Ledis) checkTTL() {
 	l.ttlCheckers = make([]*ttlChecker, 0, 16)
 	l.ttlCheckerCh = make(chan *ttlChecker, 16)
+	l.ttlWakeCh = make(chan struct{}, 1)
 
 	if l.cfg.TTLCheckInterval == 0 {
 		l.cfg.TTLCheckInterval = 1
 	}
 
+	if !l.cfg.EagerExpiry() {
+		// the expired data is only deleted on read
+		return
+	}
+
 	l.wg.Add(1)
 	go func() {
 		defer l.wg.Done()
 
-		tick := time.NewTicker(time.Duration(l.cfg.TTLCheckInterval) * time.Second)
-		defer tick.Stop()
+		interval := l.ttlCheckInterval()
+
+		// check every interval, or earlier if some data expire before it
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
 
 		for {
+			// the interval can be changed by ConfigSet
+			interval = l.ttlCheckInterval()
+
 			select {
-			case <-tick.C:
+			case <-timer.C:
 				if l.IsReadOnly() {
+					timer.Reset(interval)
 					break
 				}
 
 				for _, c := range l.ttlCheckers {
 					c.check()
 				}
+
+				timer.Reset(l.nextTTLCheckDelay(interval))
+			case <-l.ttlWakeCh:
+				l.resetTTLTimer(timer, interval)
 			case c := <-l.ttlCheckerCh:
 				l.ttlCheckers = append(l.ttlCheckers, c)
 				c.check()
+				l.resetTTLTimer(timer, interval)
 			case <-l.quit:
 				return
 			}
@@ -234,6 +318,39 @@ func (l *Ledis) checkTTL() {
 
 }
 
+func (l *Ledis) ttlCheckInterval() time.Duration {
+	var n int
+	l.cfg.View(func() { n = l.cfg.TTLCheckInterval })
+	return time.Duration(n) * time.Second
+}
+
+func (l *Ledis) resetTTLTimer(timer *time.Timer, interval time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(l.nextTTLCheckDelay(interval))
+}
+
+func (l *Ledis) nextTTLCheckDelay(interval time.Duration) time.Duration {
+	now := nowMs()
+
+	d := interval
+	for _, c := range l.ttlCheckers {
+		if n := time.Duration(c.nextCheckTime()-now) * time.Millisecond; n < d {
+			d = n
+		}
+	}
+
+	if d < time.Millisecond {
+		d = time.Millisecond
+	}
+
+	return d
+}
+
 // StoreStat returns the statistics.
 func (l *Ledis) StoreStat() *store.Stat {
 	return l.ldb.Stat()
@@ -246,3 +363,22 @@ func (l *Ledis) CompactStore() error {
 
 	return l.ldb.Compact()
 }
+
+// the longest wait of Reload for the iterators of the reads
+const reloadTimeout = 10 * time.Second
+
+// Reload closes the store and opens it again with the same config, so the
+// data must survive it like a restart. The writes wait until it is done.
+func (l *Ledis) Reload() error {
+	l.wLock.Lock()
+	defer l.wLock.Unlock()
+
+	// no write batch is committing now
+	l.commitLock.Lock()
+	defer l.commitLock.Unlock()
+
+	if err := l.ldb.Reopen(reloadTimeout); err != nil {
+		return err
+	}
+	return l.loadACL()
+}
@@ -2,10 +2,12 @@ package ledis
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"sync"
 
+	"github.com/siddontang/go/log"
 	"github.com/siddontang/ledisdb/store"
 )
 
@@ -45,12 +47,32 @@ type DB struct {
 	zsetBatch *batch
 	//	binBatch  *batch
 	setBatch *batch
+	hllBatch *batch
+
+	streamBatch *batch
 
 	// status uint8
 
 	ttlChecker *ttlChecker
 
 	lbkeys *lBlockKeys
+	xbkeys *lBlockKeys
+	zbkeys *lBlockKeys
+
+	access *accessTracker
+	lfu    lfuSampler
+
+	// the writes of the Multi on db, nil otherwise
+	multi *multiWrites
+
+	// ctx stops the long scans when done, nil means never
+	ctx context.Context
+
+	// the reads do not change the access data of OBJECT IDLETIME and FREQ
+	noTouch bool
+
+	// set by WithCommitOptions
+	commitOpts CommitOptions
 }
 
 func (l *Ledis) newDB(index int) *DB {
@@ -71,8 +93,15 @@ func (l *Ledis) newDB(index int) *DB {
 	d.zsetBatch = d.newBatch()
 	// d.binBatch = d.newBatch()
 	d.setBatch = d.newBatch()
+	d.hllBatch = d.newBatch()
+	d.streamBatch = d.newBatch()
 
 	d.lbkeys = newLBlockKeys()
+	d.xbkeys = newLBlockKeys()
+	d.zbkeys = newLBlockKeys()
+
+	d.access = newAccessTracker()
+	d.lfu = lfuSampler{d}
 
 	d.ttlChecker = d.newTTLChecker()
 
@@ -123,6 +152,8 @@ func (db *DB) newTTLChecker() *ttlChecker {
 	c.register(ZSetType, db.zsetBatch, db.zDelete)
 	//		c.register(BitType, db.binBatch, db.bDelete)
 	c.register(SetType, db.setBatch, db.sDelete)
+	c.register(HLLType, db.hllBatch, db.hllDelete)
+	c.register(StreamType, db.streamBatch, db.xDelete)
 
 	return c
 }
@@ -131,6 +162,48 @@ func (db *DB) newBatch() *batch {
 	return db.l.newBatch(db.bucket.NewWriteBatch(), &dbBatchLocker{l: &sync.Mutex{}, wrLock: &db.l.wLock})
 }
 
+// newExclusiveBatch returns a batch which blocks all the other writes.
+func (db *DB) newExclusiveBatch() *batch {
+	if db.multi != nil {
+		return db.newMultiBatch()
+	}
+	t := db.l.newBatch(db.bucket.NewWriteBatch(), &db.l.wLock)
+	t.opts = db.commitOpts
+	return t
+}
+
+// WithContext returns a copy of db whose scans stop when ctx is done, then
+// they return the data scanned so far and the error of ctx.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	d := *db
+	d.ctx = ctx
+	return &d
+}
+
+// WithCommitOptions returns a copy of db whose writes commit with opts, it
+// shares the locks of the writes with db.
+func (db *DB) WithCommitOptions(opts CommitOptions) *DB {
+	d := *db
+	d.commitOpts = opts
+
+	for _, t := range []**batch{&d.kvBatch, &d.listBatch, &d.hashBatch,
+		&d.zsetBatch, &d.setBatch, &d.hllBatch, &d.streamBatch} {
+		nt := d.l.newBatch(d.bucket.NewWriteBatch(), (*t).Locker)
+		nt.multi = (*t).multi
+		nt.opts = opts
+		*t = nt
+	}
+	return &d
+}
+
+// WithNoTouch returns a copy of db whose reads do not change the idle time
+// and the access frequency of the keys.
+func (db *DB) WithNoTouch() *DB {
+	d := *db
+	d.noTouch = true
+	return &d
+}
+
 // Index gets the index of database.
 func (db *DB) Index() int {
 	return int(db.index)
@@ -147,7 +220,9 @@ func (db *DB) FlushAll() (drop int64, err error) {
 		db.lFlush,
 		db.hFlush,
 		db.zFlush,
-		db.sFlush}
+		db.sFlush,
+		db.hllFlush,
+		db.xFlush}
 
 	for _, flush := range all {
 		n, e := flush()
@@ -159,9 +234,96 @@ func (db *DB) FlushAll() (drop int64, err error) {
 		drop += n
 	}
 
+	db.access.reset()
 	return
 }
 
+// FlushDBAsync flushes db like FlushAll in a goroutine, it returns once the
+// writes of db are blocked. A write after it waits until its data type is
+// flushed, so it is never flushed itself, but the reads see the keys not
+// deleted yet. The keys of a database have its index as the prefix, which
+// can not be switched to a new one without rewriting them. In a Multi it
+// flushes at once.
+func (db *DB) FlushDBAsync() error {
+	flushes := []struct {
+		t        *batch
+		dataType byte
+	}{
+		{db.kvBatch, KVType},
+		{db.listBatch, ListType},
+		{db.hashBatch, HashType},
+		{db.zsetBatch, ZSetType},
+		{db.setBatch, SetType},
+		{db.hllBatch, HLLType},
+		{db.streamBatch, StreamType},
+	}
+
+	lockers := make([]*dbBatchLocker, len(flushes))
+	for i, f := range flushes {
+		l, ok := f.t.Locker.(*dbBatchLocker)
+		if !ok {
+			_, err := db.FlushAll()
+			return err
+		}
+		lockers[i] = l
+	}
+
+	// the write lock is read locked once for all the batches, a second read
+	// lock may wait for a waiting writer forever
+	db.l.wLock.RLock()
+	for _, l := range lockers {
+		l.l.Lock()
+	}
+
+	db.l.wg.Add(1)
+	go func() {
+		defer db.l.wg.Done()
+		defer db.l.wLock.RUnlock()
+
+		var drop int64
+		for i, f := range flushes {
+			n, err := db.flushType(f.t, f.dataType)
+			if err != nil {
+				log.Errorf("flush db %d %s error %s", db.index, TypeName[f.dataType], err.Error())
+			}
+			drop += n
+
+			f.t.WriteBatch.Rollback()
+			lockers[i].l.Unlock()
+		}
+
+		db.access.reset()
+		log.Infof("flush db %d async, %d keys deleted", db.index, drop)
+	}()
+	return nil
+}
+
+// DBSize returns the number of the keys in db. The data types have their own
+// keyspaces, so a key of two data types counts twice. The expired keys not
+// deleted yet are counted like redis.
+func (db *DB) DBSize() (int64, error) {
+	var n int64
+	for _, metaDataType := range []byte{KVType, LMetaType, HSizeType, ZSizeType, SSizeType, HLLType, StreamMetaType} {
+		minKey, maxKey, err := db.buildScanKeyRange(metaDataType, nil, false)
+		if err != nil {
+			return 0, err
+		}
+
+		it := db.buildScanIterator(minKey, maxKey, false, false)
+		for steps := 1; it.Valid(); it.Next() {
+			if db.scanCanceled(steps) {
+				it.Close()
+				return n, db.ctx.Err()
+			}
+			steps++
+			n++
+		}
+		it.Close()
+	}
+
+	return n, nil
+}
+
 func (db *DB) flushType(t *batch, dataType byte) (drop int64, err error) {
 	var deleteFunc func(t *batch, key []byte) int64
 	var metaDataType byte
@@ -184,6 +346,12 @@ func (db *DB) flushType(t *batch, dataType byte) (drop int64, err error) {
 	case SetType:
 		deleteFunc = db.sDelete
 		metaDataType = SSizeType
+	case HLLType:
+		deleteFunc = db.hllDelete
+		metaDataType = HLLType
+	case StreamType:
+		deleteFunc = db.xDelete
+		metaDataType = StreamMetaType
 	default:
 		return 0, fmt.Errorf("invalid data type: %s", TypeName[dataType])
 	}
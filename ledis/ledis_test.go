@@ -1,9 +1,11 @@
 package ledis
 
 import (
+	"fmt"
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/siddontang/ledisdb/config"
 )
@@ -156,3 +158,68 @@ func TestFlush(t *testing.T) {
 		t.Fatal(zcnt)
 	}
 }
+
+func TestFlushDBAsync(t *testing.T) {
+	getTestDB()
+	db, _ := testLedis.Select(3)
+	db.FlushAll()
+
+	for i := 0; i < 3000; i++ {
+		db.Set([]byte(fmt.Sprintf("flush_async_%d", i)), []byte("1"))
+	}
+	db.HSet([]byte("flush_async_h"), []byte("f"), []byte("v"))
+	db.ZAdd([]byte("flush_async_z"), ScorePair{1, []byte("m")})
+
+	if err := db.FlushDBAsync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the write waits for the flush of the kv keys, and is kept
+	key := []byte("flush_async_new")
+	if err := db.Set(key, []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if n, _ := db.DBSize(); n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n, err := db.DBSize(); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if v, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "2" {
+		t.Fatal(string(v))
+	}
+	db.FlushAll()
+}
+
+func TestDBSize(t *testing.T) {
+	db, _ := testLedis.Select(2)
+	db.FlushAll()
+
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+	db.LPush([]byte("a"), []byte("1"))
+	db.HSet([]byte("h"), []byte("f"), []byte("v"))
+	db.SAdd([]byte("s"), []byte("m1"), []byte("m2"))
+	db.ZAdd([]byte("z"), ScorePair{1, []byte("m")})
+
+	if n, err := db.DBSize(); err != nil {
+		t.Fatal(err)
+	} else if n != 6 {
+		t.Fatal(n)
+	}
+
+	db.FlushAll()
+	if n, _ := db.DBSize(); n != 0 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,160 @@
+package ledis
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/log"
+)
+
+// LFUSample is a key sampled for the LFU eviction, with its access counter
+// like OBJECT FREQ.
+type LFUSample struct {
+	Key  []byte
+	Type string
+	Freq uint8
+
+	dataType byte
+	// the unix time in seconds of the last access
+	last int64
+}
+
+// lfuSampler samples the keys of a db by their access frequency.
+type lfuSampler struct {
+	db *DB
+}
+
+// Sample returns the n keys of the lowest access frequency, the least
+// recently read first of the same one, from a random sample of
+// maxmemory_samples keys, at least n. Only the keys with a ttl are sampled
+// for the volatile-lfu policy, and the keys kept by CLIENT NO-EVICT are
+// skipped.
+func (s lfuSampler) Sample(n int) []LFUSample {
+	var policy string
+	var samples int
+	s.db.l.cfg.View(func() {
+		policy = s.db.l.cfg.MaxMemoryPolicy
+		samples = s.db.l.cfg.EvictionSamples
+	})
+	if samples < n {
+		samples = n
+	}
+
+	res, err := s.sample(samples, strings.HasPrefix(policy, "volatile-"))
+	if err != nil {
+		log.Errorf("sample lfu keys error %s", err.Error())
+	}
+
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// sample returns the distinct keys of count random samples sorted by the
+// access frequency.
+func (s lfuSampler) sample(count int, volatile bool) ([]LFUSample, error) {
+	now := time.Now()
+
+	res := make([]LFUSample, 0, count)
+	seen := make(map[string]bool, count)
+	for i := 0; i < count; i++ {
+		c, err := s.db.sampleEvictKey(volatile)
+		if err != nil {
+			return res, err
+		} else if c == nil {
+			break
+		} else if s.db.access.noEvicted(c.key, now) {
+			continue
+		}
+
+		id := string(c.dataType) + string(c.key)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		e := s.db.access.entry(c.key)
+		res = append(res, LFUSample{
+			Key:      c.key,
+			Type:     redisTypeNames[c.dataType],
+			Freq:     e.counter,
+			dataType: c.dataType,
+			last:     e.last,
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Freq != res[j].Freq {
+			return res[i].Freq < res[j].Freq
+		}
+		return res[i].last < res[j].last
+	})
+	return res, nil
+}
+
+// lfuEvictCandidate returns the key of the lowest access frequency from
+// samples keys of each database, nil if there is none.
+func (l *Ledis) lfuEvictCandidate(dbs []*DB, samples int, volatile bool) (*evictCandidate, error) {
+	var best *evictCandidate
+	for _, db := range dbs {
+		res, err := db.lfu.sample(samples, volatile)
+		if err != nil {
+			return nil, err
+		} else if len(res) == 0 {
+			continue
+		}
+
+		// the older one of the same counter
+		c := &evictCandidate{db: db, dataType: res[0].dataType, key: res[0].Key}
+		c.score = int64(res[0].Freq)<<32 | res[0].last
+		if best == nil || c.score < best.score {
+			best = c
+		}
+	}
+	return best, nil
+}
+
+// onLFUDecay decays the access counters of the open databases every
+// lfu_decay_time minutes.
+func (l *Ledis) onLFUDecay() {
+	defer l.wg.Done()
+
+	for {
+		var minutes int
+		l.cfg.View(func() {
+			minutes = l.cfg.LFUDecayTime
+		})
+
+		// 0 never decays, the config is checked again a minute later
+		d := time.Minute
+		if minutes > 0 {
+			d = time.Duration(minutes) * time.Minute
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+			if minutes > 0 {
+				l.decayLFU()
+			}
+		case <-l.quit:
+			t.Stop()
+			return
+		}
+	}
+}
+
+func (l *Ledis) decayLFU() {
+	l.dbLock.Lock()
+	dbs := make([]*DB, 0, len(l.dbs))
+	for _, db := range l.dbs {
+		dbs = append(dbs, db)
+	}
+	l.dbLock.Unlock()
+
+	for _, db := range dbs {
+		db.access.decay()
+	}
+}
@@ -0,0 +1,178 @@
+package ledis
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestLFUDecay(t *testing.T) {
+	a := newAccessTracker()
+	a.touch([]byte("read"))
+	a.touch([]byte("idle"))
+
+	a.Lock()
+	a.keys["read"].counter = 10
+	a.keys["idle"].counter = 10
+	a.Unlock()
+
+	// both are read since the start
+	a.decay()
+	a.touch([]byte("read"))
+	a.decay()
+
+	if e := a.entry([]byte("read")); e.counter < 10 {
+		t.Fatal(e)
+	} else if e = a.entry([]byte("idle")); e.counter != 9 {
+		t.Fatal(e)
+	}
+
+	for i := 0; i < 20; i++ {
+		a.decay()
+	}
+	if e := a.entry([]byte("idle")); e.counter != 0 {
+		t.Fatal(e)
+	}
+}
+
+func TestLFUSample(t *testing.T) {
+	getTestDB()
+	db, _ := testLedis.Select(6)
+	db.FlushAll()
+	defer db.FlushAll()
+
+	for i, key := range []string{"lfu_hot", "lfu_warm", "lfu_cold"} {
+		db.Set([]byte(key), []byte("v"))
+		db.Get([]byte(key))
+
+		db.access.Lock()
+		db.access.keys[key].counter = uint8(30 - 10*i)
+		db.access.Unlock()
+	}
+	db.HSet([]byte("lfu_hash"), []byte("f"), []byte("v"))
+	db.HGet([]byte("lfu_hash"), []byte("f"))
+
+	db.access.Lock()
+	db.access.keys["lfu_hash"].counter = 40
+	db.access.Unlock()
+
+	res, err := db.lfu.sample(200, false)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(res) != 4 {
+		t.Fatal(res)
+	}
+	for i, key := range []string{"lfu_cold", "lfu_warm", "lfu_hot", "lfu_hash"} {
+		if string(res[i].Key) != key || res[i].Freq != uint8(10+10*i) {
+			t.Fatal(i, res[i])
+		}
+	}
+	if res[3].Type != "hash" {
+		t.Fatal(res[3])
+	}
+
+	if res := db.lfu.Sample(1); len(res) != 1 {
+		t.Fatal(res)
+	}
+
+	// only the keys with a ttl for volatile-lfu
+	db.Expire([]byte("lfu_hot"), 100)
+	if res, err = db.lfu.sample(20, true); err != nil {
+		t.Fatal(err)
+	} else if len(res) != 1 || string(res[0].Key) != "lfu_hot" {
+		t.Fatal(res)
+	}
+
+	db.NoEvict([]byte("lfu_hot"))
+	if res, err = db.lfu.sample(20, true); err != nil {
+		t.Fatal(err)
+	} else if len(res) != 0 {
+		t.Fatal(res)
+	}
+}
+
+// zipfHitRate reads the keys of a working set by a zipf distribution from a
+// db of at most cacheSize keys, where a missed key is set after evicting one
+// by policy like checkMaxMemory, and returns the rate of the reads which hit.
+func zipfHitRate(tb testing.TB, policy string, keys int, cacheSize int, reads int) float64 {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_ledis_lfu"
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, uint64(keys-1))
+
+	size, hits := 0, 0
+	for i := 0; i < reads; i++ {
+		// a decay every ten reads of the cache size, like a minute passes,
+		// and a compaction like after the evictions of checkMaxMemory
+		if i > 0 && i%(10*cacheSize) == 0 {
+			l.decayLFU()
+			if err := l.CompactStore(); err != nil {
+				tb.Fatal(err)
+			}
+		}
+
+		key := []byte(strconv.FormatUint(z.Uint64(), 10))
+		if v, err := db.Get(key); err != nil {
+			tb.Fatal(err)
+		} else if v != nil {
+			hits++
+		} else {
+			if size >= cacheSize {
+				c, err := l.evictCandidate(policy, cfg.EvictionSamples)
+				if err != nil || c == nil {
+					tb.Fatal(c, err)
+				} else if err = c.db.evict(c.dataType, c.key); err != nil {
+					tb.Fatal(err)
+				}
+				size--
+			}
+
+			if err = db.Set(key, []byte("v")); err != nil {
+				tb.Fatal(err)
+			}
+			size++
+		}
+
+		// the access time in seconds is too coarse for LRU here
+		db.access.Lock()
+		db.access.keys[string(key)].last = int64(i)
+		db.access.Unlock()
+	}
+
+	return float64(hits) / float64(reads)
+}
+
+func TestLFUEvictionHitRate(t *testing.T) {
+	lru := zipfHitRate(t, "allkeys-lru", 1000, 100, 6000)
+	lfu := zipfHitRate(t, "allkeys-lfu", 1000, 100, 6000)
+	if lfu <= lru {
+		t.Fatal(lfu, lru)
+	}
+}
+
+// BenchmarkZipfEviction reports the hit rate of each policy with a cache 10%
+// the size of the working set, for LFU to compare with LRU.
+func BenchmarkZipfEviction(b *testing.B) {
+	for _, policy := range []string{"allkeys-lru", "allkeys-lfu"} {
+		b.Run(policy, func(b *testing.B) {
+			var rate float64
+			for i := 0; i < b.N; i++ {
+				rate += zipfHitRate(b, policy, 1000, 100, 6000)
+			}
+			b.ReportMetric(100*rate/float64(b.N), "hit%")
+		})
+	}
+}
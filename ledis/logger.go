@@ -0,0 +1,30 @@
+package ledis
+
+import (
+	"github.com/siddontang/ledisdb/rpl"
+)
+
+// Logger logs the replication, see rpl.Logger.
+type Logger = rpl.Logger
+
+// Field is a structured field of a log message, see rpl.Field.
+type Field = rpl.Field
+
+// NopLogger logs nothing, for the tests which want no output.
+type NopLogger = rpl.NopLogger
+
+// Option is an option of Open.
+type Option func(l *Ledis)
+
+// WithLogger sets the logger of the replication, the default one logs by
+// github.com/siddontang/go/log.
+func WithLogger(logger Logger) Option {
+	return func(l *Ledis) {
+		l.logger = logger
+	}
+}
+
+// Logger returns the logger of the replication.
+func (l *Ledis) Logger() Logger {
+	return l.logger
+}
@@ -86,22 +86,46 @@ func (db *DB) ZDump(key []byte) ([]byte, error) {
 	return rdb.Dump(o)
 }
 
-// Restore restores a key into database.
-func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
+func dumpDataType(d interface{}) (byte, error) {
+	switch d.(type) {
+	case rdb.String:
+		return KVType, nil
+	case rdb.Hash:
+		return HashType, nil
+	case rdb.List:
+		return ListType, nil
+	case rdb.ZSet:
+		return ZSetType, nil
+	case rdb.Set:
+		return SetType, nil
+	default:
+		return 0, fmt.Errorf("invalid data type %T", d)
+	}
+}
+
+// Restore restores a key into database, ttl is in milliseconds.
+// If key exists as the data type of the dump, Restore returns ErrBusyKey
+// unless replace is true, then the old value is deleted first.
+func (db *DB) Restore(key []byte, ttl int64, data []byte, replace bool) error {
 	d, err := rdb.DecodeDump(data)
 	if err != nil {
 		return err
 	}
 
-	//ttl is milliseconds, but we only support seconds
-	//later may support milliseconds
-	if ttl > 0 {
-		ttl = ttl / 1e3
-		if ttl == 0 {
-			ttl = 1
+	dataType, err := dumpDataType(d)
+	if err != nil {
+		return err
+	}
+
+	if !replace {
+		if n, err := db.keyExists(dataType, key); err != nil {
+			return err
+		} else if n == 1 {
+			return ErrBusyKey
 		}
 	}
 
+	//ttl is milliseconds
 	switch value := d.(type) {
 	case rdb.String:
 		if _, err = db.Del(key); err != nil {
@@ -113,7 +137,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.Expire(key, ttl); err != nil {
+			if _, err = db.PExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -133,7 +157,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.HExpire(key, ttl); err != nil {
+			if _, err = db.HPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -148,7 +172,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.LExpire(key, ttl); err != nil {
+			if _, err = db.LPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -168,7 +192,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.ZExpire(key, ttl); err != nil {
+			if _, err = db.ZPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -183,7 +207,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.SExpire(key, ttl); err != nil {
+			if _, err = db.SPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -40,7 +40,7 @@ func TestMigrate(t *testing.T) {
 
 	if data, err := db1.Dump(key); err != nil {
 		t.Fatal(err)
-	} else if err := db2.Restore(key, 0, data); err != nil {
+	} else if err := db2.Restore(key, 0, data, false); err != nil {
 		t.Fatal(err)
 	}
 
@@ -48,7 +48,7 @@ func TestMigrate(t *testing.T) {
 
 	if data, err := db1.LDump(lkey); err != nil {
 		t.Fatal(err)
-	} else if err := db2.Restore(lkey, 0, data); err != nil {
+	} else if err := db2.Restore(lkey, 0, data, false); err != nil {
 		t.Fatal(err)
 	}
 
@@ -56,7 +56,7 @@ func TestMigrate(t *testing.T) {
 
 	if data, err := db1.SDump(skey); err != nil {
 		t.Fatal(err)
-	} else if err := db2.Restore(skey, 0, data); err != nil {
+	} else if err := db2.Restore(skey, 0, data, false); err != nil {
 		t.Fatal(err)
 	}
 
@@ -64,7 +64,7 @@ func TestMigrate(t *testing.T) {
 
 	if data, err := db1.HDump(hkey); err != nil {
 		t.Fatal(err)
-	} else if err := db2.Restore(hkey, 0, data); err != nil {
+	} else if err := db2.Restore(hkey, 0, data, false); err != nil {
 		t.Fatal(err)
 	}
 
@@ -72,11 +72,25 @@ func TestMigrate(t *testing.T) {
 
 	if data, err := db1.ZDump(zkey); err != nil {
 		t.Fatal(err)
-	} else if err := db2.Restore(zkey, 0, data); err != nil {
+	} else if err := db2.Restore(zkey, 0, data, false); err != nil {
 		t.Fatal(err)
 	}
 
 	if err := checkLedisEqual(l1, l2); err != nil {
 		t.Fatal(err)
 	}
+
+	db1.Set(key, []byte("2"))
+	data, err := db1.Dump(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db2.Restore(key, 0, data, false); err != ErrBusyKey {
+		t.Fatal(err)
+	} else if err := db2.Restore(key, 0, data, true); err != nil {
+		t.Fatal(err)
+	} else if v, _ := db2.Get(key); string(v) != "2" {
+		t.Fatal(string(v))
+	}
 }
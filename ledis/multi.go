@@ -0,0 +1,130 @@
+package ledis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+var errMultiInMulti = errors.New("can not begin a multi in a multi")
+
+// Multi runs commands on a DB exclusively, every other write blocks until
+// Close. The writes of the commands are kept in memory, the later commands
+// read the earlier writes, and Close commits them all in one batch with one
+// replication log, so the other readers see all of them or none. Unlike a
+// transaction it can not roll back, a command failing does not undo the
+// others.
+//
+// A Multi must not call anything which takes the write lock itself, like
+// Ledis FlushAll or a blocking pop waiting for another writer.
+type Multi struct {
+	*DB
+}
+
+// multiWrites are the writes of a Multi not committed yet.
+type multiWrites struct {
+	*store.Overlay
+
+	// the create time of the replication log
+	createTime uint32
+
+	// the notifications published after the commit
+	ns []Notification
+}
+
+// Multi begins a Multi on db, it waits for the running writes.
+func (db *DB) Multi() (*Multi, error) {
+	if db.multi != nil {
+		return nil, errMultiInMulti
+	}
+
+	db.l.wLock.Lock()
+
+	// share everything but the batches and the reads with db
+	d := new(DB)
+	*d = *db
+	d.multi = &multiWrites{
+		Overlay:    store.NewOverlay(db.sdb),
+		createTime: uint32(time.Now().Unix()),
+	}
+	d.bucket = d.multi
+
+	d.kvBatch = d.newMultiBatch()
+	d.listBatch = d.newMultiBatch()
+	d.hashBatch = d.newMultiBatch()
+	d.zsetBatch = d.newMultiBatch()
+	d.setBatch = d.newMultiBatch()
+	d.hllBatch = d.newMultiBatch()
+	d.streamBatch = d.newMultiBatch()
+
+	// the deletes of the ttl are in the Multi too
+	d.ttlChecker = d.newTTLChecker()
+
+	return &Multi{d}, nil
+}
+
+func (db *DB) newMultiBatch() *batch {
+	t := db.l.newBatch(db.bucket.NewWriteBatch(), &multiBatchLocker{})
+	t.multi = db.multi
+	return t
+}
+
+// add moves the writes of t to the Multi.
+func (w *multiWrites) add(t *batch) error {
+	items, err := t.WriteBatch.BatchData().Items()
+	if err != nil {
+		return err
+	}
+
+	if t.l.nm.watched() {
+		w.ns = append(w.ns, t.l.nm.decode(items, t.delEvent)...)
+	}
+
+	w.Apply(items)
+	return t.WriteBatch.Rollback()
+}
+
+// Close commits the writes and ends the Multi, the DB of the Multi can not
+// be used after. None of the writes is committed if it returns an error.
+func (m *Multi) Close() error {
+	id, err := m.commit()
+	m.l.wLock.Unlock()
+
+	if id > 0 && m.commitOpts.WaitReplicas > 0 {
+		m.l.waitReplicas(id, m.commitOpts)
+	}
+	return err
+}
+
+func (m *Multi) commit() (uint64, error) {
+	items := m.multi.Items()
+	if len(items) == 0 {
+		return 0, nil
+	} else if m.l.cfg.GetReadonly() {
+		// the server became a slave in the Multi
+		return 0, ErrWriteInROnly
+	}
+
+	wb := m.sdb.NewWriteBatch()
+	defer wb.Rollback()
+
+	for _, item := range items {
+		if item.Value == nil {
+			wb.Delete(item.Key)
+		} else {
+			wb.Put(item.Key, item.Value)
+		}
+	}
+
+	id, err := m.l.handleCommit(wb, wb, m.multi.createTime)
+	if err != nil {
+		return 0, err
+	}
+
+	if m.l.wm.watched() {
+		m.l.wm.touch(items)
+	}
+	m.l.nm.publish(m.multi.ns)
+	return id, nil
+}
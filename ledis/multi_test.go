@@ -0,0 +1,129 @@
+package ledis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMulti(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("test_multi_1")
+	key2 := []byte("test_multi_2")
+	key3 := []byte("test_multi_3")
+	db.Del(key1, key2)
+	db.LClear(key3)
+	db.ZClear(key3)
+	db.RPush(key3, []byte("0"))
+
+	m, err := db.Multi()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Multi(); err != errMultiInMulti {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		db.Set(key1, []byte("outside"))
+		close(done)
+	}()
+
+	if n, err := m.Incr(key1); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	// the later commands read the earlier writes
+	if n, err := m.Incr(key1); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	// the exclusive batches do not wait for the write lock again
+	if ok, err := m.Copy(key1, key2, false, CopyOptions{}); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("copy failed")
+	}
+
+	if _, err := m.MExpire([][]byte{key2}, time.Minute); err != nil {
+		t.Fatal(err)
+	} else if ok, err := m.ExpireAtIf(KV, key1, time.Now().Add(time.Minute), ExpireNX); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+
+	// the iterators read the earlier writes with the committed data
+	m.RPush(key3, []byte("1"), []byte("2"), []byte("3"))
+	m.LPop(key3)
+	if vs, err := m.LRange(key3, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(vs) != 3 || string(vs[0]) != "1" || string(vs[2]) != "3" {
+		t.Fatal(vs)
+	}
+
+	m.ZAdd(key3, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")}, ScorePair{3, []byte("c")})
+	m.ZRem(key3, []byte("b"))
+	if ps, err := m.ZRange(key3, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(ps) != 2 || string(ps[0].Member) != "a" || string(ps[1].Member) != "c" {
+		t.Fatal(ps)
+	}
+
+	// the other readers see nothing before close
+	if n, err := db.LLen(key3); err != nil || n != 1 {
+		t.Fatal(n, err)
+	} else if n, err = db.ZCard(key3); err != nil || n != 0 {
+		t.Fatal(n, err)
+	} else if v, err := db.Get(key2); err != nil || v != nil {
+		t.Fatal(v, err)
+	}
+
+	select {
+	case <-done:
+		t.Fatal("other writes must wait for the multi")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	m.Close()
+	<-done
+
+	if v, err := db.Get(key1); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "outside" {
+		t.Fatal(string(v))
+	}
+
+	if v, err := db.Get(key2); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "2" {
+		t.Fatal(string(v))
+	}
+
+	if n, err := db.LLen(key3); err != nil || n != 3 {
+		t.Fatal(n, err)
+	} else if n, err = db.ZCard(key3); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	db.LClear(key3)
+	db.ZClear(key3)
+
+	// nothing is committed if the commit fails
+	m, err = db.Multi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Set(key3, []byte("1"))
+	db.l.cfg.SetReadonly(true)
+	err = m.Close()
+	db.l.cfg.SetReadonly(false)
+	if err != ErrWriteInROnly {
+		t.Fatal(err)
+	} else if v, err := db.Get(key3); err != nil || v != nil {
+		t.Fatal(v, err)
+	}
+}
@@ -0,0 +1,326 @@
+package ledis
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/ledisdb/store"
+)
+
+// Keyspace notification events.
+const (
+	// EventSet means a value of the key is written.
+	EventSet = "set"
+	// EventDel means a value of the key is removed.
+	EventDel = "del"
+	// EventExpire means a ttl is set for the key.
+	EventExpire = "expire"
+	// EventExpired means the key is removed by the ttl checker.
+	EventExpired = "expired"
+	// EventEvicted means the key is removed over maxmemory.
+	EventEvicted = "evicted"
+)
+
+const notificationBufferSize = 1024
+
+// errNotDataItem means a batch item is not a data or ttl item of a key.
+var errNotDataItem = errors.New("not a data item")
+
+// Notification is a keyspace event.
+//
+// Each event is published to two channels:
+//
+//	__keyspace@<db>__:<key>
+//	__keyevent@<db>__:<event>
+//
+// Channel is the one matched by the subscription pattern.
+type Notification struct {
+	Channel   string
+	Event     string
+	DB        int
+	Type      string
+	Key       []byte
+	Timestamp time.Time
+}
+
+// CancelFunc cancels a subscription and closes its channel.
+type CancelFunc func()
+
+type subscriber struct {
+	pattern string
+	ch      chan Notification
+}
+
+// NotificationManager fans out the keyspace events to the subscribers.
+// Every subscriber has a ring buffer, if it is full, the oldest event is
+// dropped, so a slow subscriber never blocks the writers.
+type NotificationManager struct {
+	m    sync.RWMutex
+	subs map[*subscriber]struct{}
+
+	n int32
+}
+
+func newNotificationManager() *NotificationManager {
+	m := new(NotificationManager)
+	m.subs = make(map[*subscriber]struct{})
+	return m
+}
+
+// Subscribe subscribes the channels matching the glob style pattern.
+func (m *NotificationManager) Subscribe(pattern string) (<-chan Notification, CancelFunc) {
+	s := &subscriber{pattern: pattern, ch: make(chan Notification, notificationBufferSize)}
+
+	m.m.Lock()
+	m.subs[s] = struct{}{}
+	atomic.StoreInt32(&m.n, int32(len(m.subs)))
+	m.m.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			m.m.Lock()
+			delete(m.subs, s)
+			atomic.StoreInt32(&m.n, int32(len(m.subs)))
+			close(s.ch)
+			m.m.Unlock()
+		})
+	}
+
+	return s.ch, cancel
+}
+
+func (m *NotificationManager) watched() bool {
+	return atomic.LoadInt32(&m.n) > 0
+}
+
+func (m *NotificationManager) publish(ns []Notification) {
+	if len(ns) == 0 {
+		return
+	}
+
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	for _, n := range ns {
+		keyspace := fmt.Sprintf("__keyspace@%d__:%s", n.DB, n.Key)
+		keyevent := fmt.Sprintf("__keyevent@%d__:%s", n.DB, n.Event)
+
+		for s := range m.subs {
+			if MatchPattern(s.pattern, keyspace) {
+				n.Channel = keyspace
+			} else if MatchPattern(s.pattern, keyevent) {
+				n.Channel = keyevent
+			} else {
+				continue
+			}
+
+			select {
+			case s.ch <- n:
+			default:
+				// drop the oldest one
+				select {
+				case <-s.ch:
+				default:
+				}
+				select {
+				case s.ch <- n:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// decode builds the notifications for the batch items, only one event is
+// built for the same key and event in one batch. The deletes are delEvent,
+// EventDel if it is empty.
+func (m *NotificationManager) decode(items []store.BatchItem, delEvent string) []Notification {
+	now := time.Now()
+
+	var ns []Notification
+	seen := make(map[string]struct{})
+
+	db := new(DB)
+	for _, item := range items {
+		index, tp, dataType, key, err := decodeItemKey(db, item)
+		if err != nil {
+			continue
+		} else if tp == ExpMetaType && item.Value == nil {
+			// ttl removed, the data events cover it
+			continue
+		}
+
+		var event string
+		switch {
+		case tp == ExpMetaType:
+			event = EventExpire
+		case item.Value != nil:
+			event = EventSet
+		case len(delEvent) > 0:
+			event = delEvent
+		default:
+			event = EventDel
+		}
+
+		id := fmt.Sprintf("%d %d %s %s", index, dataType, event, hack.String(key))
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		ns = append(ns, Notification{
+			Event:     event,
+			DB:        index,
+			Type:      typeNames[dataType],
+			Key:       append([]byte(nil), key...),
+			Timestamp: now,
+		})
+	}
+
+	return ns
+}
+
+// decodeItemKey decodes the db index, the store type, the data type and the
+// user key of a batch item, db is only used for decoding.
+func decodeItemKey(db *DB, item store.BatchItem) (index int, tp byte, dataType byte, key []byte, err error) {
+	var pos int
+	if index, pos, err = decodeDBIndex(item.Key); err != nil {
+		return
+	} else if pos >= len(item.Key) {
+		err = errKeySize
+		return
+	}
+	db.setIndex(index)
+
+	tp = item.Key[pos]
+	dataType = tp
+	switch tp {
+	case KVType:
+		key, err = db.decodeKVKey(item.Key)
+	case HashType:
+		key, _, err = db.hDecodeHashKey(item.Key)
+	case HZipType:
+		dataType = HashType
+		key, err = db.hDecodeZipKey(item.Key)
+	case ListType:
+		key, _, err = db.lDecodeListKey(item.Key)
+	case SetType:
+		key, _, err = db.sDecodeSetKey(item.Key)
+	case ZSetType:
+		key, _, err = db.zDecodeSetKey(item.Key)
+	case HLLType:
+		key, err = db.hllDecodeKey(item.Key)
+	case StreamType:
+		key, _, err = db.xDecodeEntryKey(item.Key)
+	case ExpMetaType:
+		dataType, key, err = db.expDecodeMetaKey(item.Key)
+	default:
+		err = errNotDataItem
+	}
+	return
+}
+
+// typeNames maps the store data type to the public type name.
+var typeNames = map[byte]string{
+	KVType:     KVName,
+	HashType:   HashName,
+	ListType:   ListName,
+	SetType:    SetName,
+	ZSetType:   ZSetName,
+	HLLType:    HLLName,
+	StreamType: StreamName,
+}
+
+// Subscribe subscribes the keyspace notifications matching the glob style
+// pattern, like "__keyevent@0__:set" or "__keyspace@0__:mykey".
+func (l *Ledis) Subscribe(pattern string) (<-chan Notification, CancelFunc) {
+	return l.nm.Subscribe(pattern)
+}
+
+// MatchPattern reports whether s matches the glob style pattern,
+// supporting '*', '?', '[...]' and '\' escaping.
+func MatchPattern(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if MatchPattern(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				if pattern[0] == '\\' && len(pattern) > 1 {
+					pattern = pattern[1:]
+				}
+
+				if len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']' {
+					lo, hi := pattern[0], pattern[2]
+					if lo > hi {
+						lo, hi = hi, lo
+					}
+					if s[0] >= lo && s[0] <= hi {
+						match = true
+					}
+					pattern = pattern[3:]
+				} else {
+					if pattern[0] == s[0] {
+						match = true
+					}
+					pattern = pattern[1:]
+				}
+			}
+
+			if match == not {
+				return false
+			}
+
+			if len(pattern) == 0 {
+				// no closing ']'
+				s = s[1:]
+				continue
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+
+	return len(s) == 0
+}
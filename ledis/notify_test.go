@@ -0,0 +1,101 @@
+package ledis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchPattern(t *testing.T) {
+	table := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"*", "", true},
+		{"*", "a/b", true},
+		{"a*", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a[bc]d", "acd", true},
+		{"a[^bc]d", "acd", false},
+		{"a[a-c]d", "abd", true},
+		{"a\\*", "a*", true},
+		{"a\\*", "ab", false},
+		{"__keyevent@0__:*", "__keyevent@0__:set", true},
+	}
+
+	for _, v := range table {
+		if MatchPattern(v.pattern, v.s) != v.match {
+			t.Fatalf("%q %q must be %v", v.pattern, v.s, v.match)
+		}
+	}
+}
+
+func recvNotification(t *testing.T, ch <-chan Notification, timeout time.Duration) Notification {
+	select {
+	case n := <-ch:
+		return n
+	case <-time.After(timeout):
+		t.Fatal("wait notification timeout")
+	}
+	return Notification{}
+}
+
+func TestNotification(t *testing.T) {
+	getTestDB()
+	db, _ := testLedis.Select(100)
+
+	ch, cancel := testLedis.Subscribe("__keyspace@100__:notify_*")
+	defer cancel()
+
+	evCh, evCancel := testLedis.Subscribe("__keyevent@100__:expired")
+	defer evCancel()
+
+	key := []byte("notify_a")
+
+	db.Set(key, []byte("1"))
+	if n := recvNotification(t, ch, time.Second); n.Event != EventSet || string(n.Key) != "notify_a" || n.Type != KVName {
+		t.Fatal(n)
+	} else if n.Channel != "__keyspace@100__:notify_a" {
+		t.Fatal(n.Channel)
+	}
+
+	db.HSet(key, []byte("f"), []byte("1"))
+	if n := recvNotification(t, ch, time.Second); n.Event != EventSet || n.Type != HashName {
+		t.Fatal(n)
+	}
+
+	db.Del(key)
+	if n := recvNotification(t, ch, time.Second); n.Event != EventDel || n.Type != KVName {
+		t.Fatal(n)
+	}
+
+	db.HClear(key)
+	if n := recvNotification(t, ch, time.Second); n.Event != EventDel || n.Type != HashName {
+		t.Fatal(n)
+	}
+
+	db.Set(key, []byte("1"))
+	recvNotification(t, ch, time.Second)
+
+	db.Expire(key, 1)
+	if n := recvNotification(t, ch, time.Second); n.Event != EventExpire || n.Type != KVName {
+		t.Fatal(n)
+	}
+
+	if n := recvNotification(t, evCh, 5*time.Second); n.Event != EventExpired || string(n.Key) != "notify_a" {
+		t.Fatal(n)
+	} else if n.Channel != "__keyevent@100__:expired" {
+		t.Fatal(n.Channel)
+	}
+
+	cancel()
+	if _, ok := <-ch; ok {
+		// the expired event may be buffered before cancel
+		if _, ok = <-ch; ok {
+			t.Fatal("channel must be closed")
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package ledis
+
+import (
+	"bytes"
+	"compress/zlib"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/siddontang/ledisdb/store"
+	"github.com/siddontang/rdb"
+)
+
+// The limits redis uses by default to pick the compact encodings.
+const (
+	objEmbstrMaxLen      = 44
+	objIntsetMaxEntries  = 512
+	objIntMaxLen         = 20
+	objListpackEncoding  = "listpack"
+	objHashtableEncoding = "hashtable"
+)
+
+// ObjectEncoding returns the redis encoding name a key would have in redis,
+// or an empty string if the key does not exist.
+//
+// ledisdb always stores data in the same format, so the name is derived from
+// the data like redis does: a string is "int", "embstr" or "raw", a hash,
+// list, set or zset of at most ziplist_max_entries entries, none bigger than
+// ziplist_max_value_size bytes, is "listpack", a set of at most 512 integers
+// is "intset", and bigger ones are "hashtable", "quicklist" or "skiplist". If a key holds more than one
+// data type, the first one in KV, hash, list, set, zset, HLL and stream order
+// is used.
+func (db *DB) ObjectEncoding(key []byte) (string, error) {
+	if err := checkKeySize(key); err != nil {
+		return "", err
+	}
+
+	for _, dataType := range expireTypes {
+		if n, err := db.keyExists(dataType, key); err != nil {
+			return "", err
+		} else if n == 1 {
+			return db.objectEncoding(dataType, key)
+		}
+	}
+
+	return "", nil
+}
+
+// redisTypeNames are the redis type names of the data types. A HyperLogLog is a
+// string in redis and a geo key is a zset.
+var redisTypeNames = map[byte]string{
+	KVType:     "string",
+	HLLType:    "string",
+	HashType:   "hash",
+	ListType:   "list",
+	SetType:    "set",
+	ZSetType:   "zset",
+	StreamType: "stream",
+}
+
+// Type returns the redis type name of key, "string", "list", "set", "zset",
+// "hash" or "stream", or "none" if the key does not exist. If a key holds
+// more than one data type, the first one in the ObjectEncoding order is used.
+func (db *DB) Type(key []byte) (string, error) {
+	if err := checkKeySize(key); err != nil {
+		return "", err
+	}
+
+	for _, dataType := range expireTypes {
+		if n, err := db.keyExists(dataType, key); err != nil {
+			return "", err
+		} else if n == 1 {
+			return redisTypeNames[dataType], nil
+		}
+	}
+
+	return "none", nil
+}
+
+// DebugObject is what DEBUG OBJECT reports about a key.
+type DebugObject struct {
+	Type     string
+	Encoding string
+	// the size of the serialized value compressed by zlib, to estimate the
+	// size in a snapshot
+	SerializedLength int
+	// the unix time in seconds of the last access, and the seconds since
+	LRU         int64
+	IdleSeconds int64
+}
+
+// DebugObject returns the DebugObject of key, or ErrNoSuchKey. If a key holds
+// more than one data type, the first one is used like ObjectEncoding.
+func (db *DB) DebugObject(key []byte) (*DebugObject, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+
+	for _, dataType := range expireTypes {
+		if n, err := db.keyExists(dataType, key); err != nil {
+			return nil, err
+		} else if n == 0 {
+			continue
+		}
+
+		o := &DebugObject{Type: redisTypeNames[dataType]}
+
+		var err error
+		if o.Encoding, err = db.objectEncoding(dataType, key); err != nil {
+			return nil, err
+		}
+
+		data, err := db.serialize(dataType, key)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		o.SerializedLength = buf.Len()
+
+		o.LRU = db.access.entry(key).last
+		if o.IdleSeconds = time.Now().Unix() - o.LRU; o.IdleSeconds < 0 {
+			o.IdleSeconds = 0
+		}
+		return o, nil
+	}
+
+	return nil, ErrNoSuchKey
+}
+
+// serialize returns the value of key of dataType like DUMP, a HyperLogLog is
+// dumped as a string, and a stream is its stored entries.
+func (db *DB) serialize(dataType byte, key []byte) ([]byte, error) {
+	switch dataType {
+	case KVType:
+		return db.Dump(key)
+	case HLLType:
+		v, err := db.bucket.Get(db.hllEncodeKey(key))
+		if err != nil {
+			return nil, err
+		}
+		return rdb.Dump(rdb.String(v))
+	case HashType:
+		return db.HDump(key)
+	case ListType:
+		return db.LDump(key)
+	case SetType:
+		return db.SDump(key)
+	case ZSetType:
+		return db.ZDump(key)
+	case StreamType:
+		min := db.xEncodeEntryKey(key, StreamID{})
+		max := db.xEncodeEntryKey(key, StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64})
+
+		it := db.bucket.RangeLimitIterator(min, max, store.RangeClose, 0, -1)
+		defer it.Close()
+
+		var data []byte
+		for ; it.Valid(); it.Next() {
+			data = append(data, it.RawValue()...)
+		}
+		return data, nil
+	default:
+		return nil, errExpType
+	}
+}
+
+// ObjectHelp returns the help lines of the OBJECT sub-commands.
+func (db *DB) ObjectHelp() []string {
+	return []string{
+		"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"ENCODING <key>",
+		"    Return the kind of internal representation the Redis server would use for the value stored at <key>.",
+		"FREQ <key>",
+		"    Return the access frequency index of the <key>. The returned integer is proportional to the",
+		"    logarithm of the recent access frequency of the key.",
+		"IDLETIME <key>",
+		"    Return the idle time of the <key>, that is the approximated number of seconds elapsed since the last",
+		"    access to the key.",
+		"HELP",
+		"    Print this help.",
+	}
+}
+
+func (db *DB) objectEncoding(dataType byte, key []byte) (string, error) {
+	switch dataType {
+	case KVType:
+		v, err := db.bucket.Get(db.encodeKVKey(key))
+		if err != nil {
+			return "", err
+		}
+		return stringEncoding(v), nil
+	case HLLType:
+		// HyperLogLogs are plain strings in redis, always bigger than embstr
+		return "raw", nil
+	case HashType:
+		return db.hObjectEncoding(key)
+	case ListType:
+		return db.lObjectEncoding(key)
+	case SetType:
+		return db.sObjectEncoding(key)
+	case ZSetType:
+		return db.zObjectEncoding(key)
+	case StreamType:
+		return "stream", nil
+	default:
+		return "", errExpType
+	}
+}
+
+func stringEncoding(v []byte) string {
+	if len(v) <= objIntMaxLen {
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return "int"
+		}
+	}
+
+	if len(v) <= objEmbstrMaxLen {
+		return "embstr"
+	}
+	return "raw"
+}
+
+// rangeAll reports whether f is true for all the keys in [min, max).
+func (db *DB) rangeAll(min []byte, max []byte, f func(ek []byte, v []byte) (bool, error)) (bool, error) {
+	it := db.bucket.RangeLimitIterator(min, max, store.RangeROpen, 0, -1)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		if ok, err := f(it.RawKey(), it.Value()); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// ziplistLimits returns the most entries and the biggest value of a key
+// reported as "listpack".
+func (db *DB) ziplistLimits() (entries int64, size int) {
+	db.l.cfg.View(func() {
+		entries, size = int64(db.l.cfg.ZiplistMaxEntries), db.l.cfg.ZiplistMaxValueSize
+	})
+	return
+}
+
+// hObjectEncoding returns the encoding the hash is stored in, see hWrite,
+// or derives it from the fields like the other data types without
+// hash_ziplist.
+func (db *DB) hObjectEncoding(key []byte) (string, error) {
+	if v, err := db.bucket.Get(db.hEncodeZipKey(key)); err != nil {
+		return "", err
+	} else if v != nil {
+		return objListpackEncoding, nil
+	} else if db.hashZiplist() {
+		return objHashtableEncoding, nil
+	}
+
+	maxEntries, maxValue := db.ziplistLimits()
+
+	if n, err := db.HLen(key); err != nil {
+		return "", err
+	} else if n > maxEntries {
+		return objHashtableEncoding, nil
+	}
+
+	small, err := db.rangeAll(db.hEncodeStartKey(key), db.hEncodeStopKey(key), func(ek []byte, v []byte) (bool, error) {
+		_, field, err := db.hDecodeHashKey(ek)
+		return len(field) <= maxValue && len(v) <= maxValue, err
+	})
+	if err != nil {
+		return "", err
+	} else if !small {
+		return objHashtableEncoding, nil
+	}
+	return objListpackEncoding, nil
+}
+
+func (db *DB) lObjectEncoding(key []byte) (string, error) {
+	maxEntries, maxValue := db.ziplistLimits()
+
+	headSeq, tailSeq, size, err := db.lGetMeta(nil, db.lEncodeMetaKey(key))
+	if err != nil {
+		return "", err
+	} else if int64(size) > maxEntries {
+		return "quicklist", nil
+	}
+
+	it := db.bucket.RangeLimitIterator(db.lEncodeListKey(key, headSeq), db.lEncodeListKey(key, tailSeq), store.RangeClose, 0, -1)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		if len(it.Value()) > maxValue {
+			return "quicklist", nil
+		}
+	}
+	return objListpackEncoding, nil
+}
+
+func (db *DB) sObjectEncoding(key []byte) (string, error) {
+	maxEntries, maxValue := db.ziplistLimits()
+
+	n, err := db.SCard(key)
+	if err != nil {
+		return "", err
+	} else if n > objIntsetMaxEntries {
+		return objHashtableEncoding, nil
+	}
+
+	ints, small := true, n <= maxEntries
+	_, err = db.rangeAll(db.sEncodeStartKey(key), db.sEncodeStopKey(key), func(ek []byte, v []byte) (bool, error) {
+		_, member, err := db.sDecodeSetKey(ek)
+		if err != nil {
+			return false, err
+		}
+
+		if ints && stringEncoding(member) != "int" {
+			ints = false
+		}
+		if small && len(member) > maxValue {
+			small = false
+		}
+		return ints || small, nil
+	})
+
+	switch {
+	case err != nil:
+		return "", err
+	case ints:
+		return "intset", nil
+	case small:
+		return objListpackEncoding, nil
+	default:
+		return objHashtableEncoding, nil
+	}
+}
+
+func (db *DB) zObjectEncoding(key []byte) (string, error) {
+	maxEntries, maxValue := db.ziplistLimits()
+
+	if n, err := db.ZCard(key); err != nil {
+		return "", err
+	} else if n > maxEntries {
+		return "skiplist", nil
+	}
+
+	small, err := db.rangeAll(db.zEncodeStartSetKey(key), db.zEncodeStopSetKey(key), func(ek []byte, v []byte) (bool, error) {
+		_, member, err := db.zDecodeSetKey(ek)
+		return len(member) <= maxValue, err
+	})
+	if err != nil {
+		return "", err
+	} else if !small {
+		return "skiplist", nil
+	}
+	return objListpackEncoding, nil
+}
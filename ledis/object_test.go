@@ -0,0 +1,167 @@
+package ledis
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestObjectEncoding(t *testing.T) {
+	db := getTestDB()
+
+	check := func(key string, enc string) {
+		t.Helper()
+		if v, err := db.ObjectEncoding([]byte(key)); err != nil {
+			t.Fatal(err)
+		} else if v != enc {
+			t.Fatal(key, v, enc)
+		}
+	}
+
+	check("obj_none", "")
+
+	db.Set([]byte("obj_int"), []byte("-12345"))
+	db.Set([]byte("obj_embstr"), []byte("hello"))
+	db.Set([]byte("obj_raw"), bytes.Repeat([]byte("a"), 45))
+	check("obj_int", "int")
+	check("obj_embstr", "embstr")
+	check("obj_raw", "raw")
+
+	db.HSet([]byte("obj_hash"), []byte("f"), []byte("v"))
+	check("obj_hash", "listpack")
+	db.HSet([]byte("obj_hash"), []byte("big"), bytes.Repeat([]byte("v"), 65))
+	check("obj_hash", "hashtable")
+
+	db.RPush([]byte("obj_list"), []byte("a"), []byte("b"))
+	check("obj_list", "listpack")
+	for i := 0; i < 128; i++ {
+		db.RPush([]byte("obj_list"), []byte("c"))
+	}
+	check("obj_list", "quicklist")
+
+	db.SAdd([]byte("obj_set"), []byte("1"), []byte("2"))
+	check("obj_set", "intset")
+	db.SAdd([]byte("obj_set"), []byte("a"))
+	check("obj_set", "listpack")
+	for i := 0; i < 128; i++ {
+		db.SAdd([]byte("obj_set"), []byte(fmt.Sprintf("m%d", i)))
+	}
+	check("obj_set", "hashtable")
+
+	db.ZAdd([]byte("obj_zset"), ScorePair{1, []byte("a")})
+	check("obj_zset", "listpack")
+	db.ZAdd([]byte("obj_zset"), ScorePair{2, bytes.Repeat([]byte("m"), 65)})
+	check("obj_zset", "skiplist")
+
+	db.HLLAdd([]byte("obj_hll"), []byte("a"))
+	check("obj_hll", "raw")
+
+	db.XAdd([]byte("obj_stream"), "*", FVPair{[]byte("f"), []byte("v")})
+	check("obj_stream", "stream")
+}
+
+func TestDebugObject(t *testing.T) {
+	db := getTestDB()
+
+	if _, err := db.DebugObject([]byte("debug_obj_none")); err != ErrNoSuchKey {
+		t.Fatal(err)
+	}
+
+	db.Set([]byte("debug_obj_kv"), bytes.Repeat([]byte("a"), 1000))
+	db.HLLAdd([]byte("debug_obj_hll"), []byte("a"))
+	db.XAdd([]byte("debug_obj_stream"), "*", FVPair{[]byte("f"), []byte("v")})
+
+	for key, tp := range map[string]string{
+		"debug_obj_kv":     "string",
+		"debug_obj_hll":    "string",
+		"debug_obj_stream": "stream",
+	} {
+		if o, err := db.DebugObject([]byte(key)); err != nil {
+			t.Fatal(err)
+		} else if o.Type != tp || o.SerializedLength <= 0 || o.IdleSeconds < 0 {
+			t.Fatal(key, o)
+		}
+	}
+
+	// the length is compressed
+	if o, _ := db.DebugObject([]byte("debug_obj_kv")); o.SerializedLength >= 1000 || o.Encoding != "raw" {
+		t.Fatal(o)
+	}
+}
+
+func TestType(t *testing.T) {
+	db := getTestDB()
+
+	check := func(key string, tp string) {
+		t.Helper()
+		if v, err := db.Type([]byte(key)); err != nil {
+			t.Fatal(err)
+		} else if v != tp {
+			t.Fatal(key, v, tp)
+		}
+	}
+
+	db.Set([]byte("type_kv"), []byte("v"))
+	db.HSet([]byte("type_hash"), []byte("f"), []byte("v"))
+	db.RPush([]byte("type_list"), []byte("a"))
+	db.SAdd([]byte("type_set"), []byte("a"))
+	db.GeoAdd([]byte("type_geo"), GeoMember{Name: []byte("a"), Lat: 1, Lon: 1})
+	db.HLLAdd([]byte("type_hll"), []byte("a"))
+	db.XAdd([]byte("type_stream"), "*", FVPair{[]byte("f"), []byte("v")})
+
+	check("type_none", "none")
+	check("type_kv", "string")
+	check("type_hash", "hash")
+	check("type_list", "list")
+	check("type_set", "set")
+	check("type_geo", "zset")
+	check("type_hll", "string")
+	check("type_stream", "stream")
+
+	if help := db.ObjectHelp(); len(help) == 0 {
+		t.Fatal("empty help")
+	}
+}
+
+func TestObjectEncodingLimits(t *testing.T) {
+	db := getTestDB()
+
+	cfg := db.l.cfg
+	var entries, size int
+	cfg.Update(func() {
+		entries, size = cfg.ZiplistMaxEntries, cfg.ZiplistMaxValueSize
+		cfg.ZiplistMaxEntries, cfg.ZiplistMaxValueSize = 2, 4
+	})
+	defer cfg.Update(func() {
+		cfg.ZiplistMaxEntries, cfg.ZiplistMaxValueSize = entries, size
+	})
+
+	key := []byte("obj_limit_hash")
+	db.HClear(key)
+	db.HSet(key, []byte("a"), []byte("v"))
+	db.HSet(key, []byte("b"), []byte("v"))
+
+	if v, _ := db.ObjectEncoding(key); v != "listpack" {
+		t.Fatal(v)
+	}
+
+	db.HSet(key, []byte("c"), []byte("v"))
+	if v, _ := db.ObjectEncoding(key); v != "hashtable" {
+		t.Fatal(v)
+	}
+
+	db.HClear(key)
+
+	key = []byte("obj_limit_list")
+	db.LClear(key)
+	db.RPush(key, []byte("1234"))
+	if v, _ := db.ObjectEncoding(key); v != "listpack" {
+		t.Fatal(v)
+	}
+
+	db.RPush(key, []byte("12345"))
+	if v, _ := db.ObjectEncoding(key); v != "quicklist" {
+		t.Fatal(v)
+	}
+	db.LClear(key)
+}
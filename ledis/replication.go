@@ -6,7 +6,6 @@ import (
 	"io"
 	"time"
 
-	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/snappy"
 	"github.com/siddontang/ledisdb/rpl"
 	"github.com/siddontang/ledisdb/store"
@@ -19,6 +18,8 @@ const (
 // For replication error.
 var (
 	ErrLogMissed = errors.New("log is pured in server")
+
+	ErrUncommittedLogs = errors.New("replication logs received but not applied yet")
 )
 
 // ReplicationUsed returns whether replication is used or not.
@@ -38,7 +39,7 @@ func (l *Ledis) handleReplication() error {
 	for {
 		if err = l.r.NextNeedCommitLog(rl); err != nil {
 			if err != rpl.ErrNoBehindLog {
-				log.Errorf("get next commit log err, %s", err.Error)
+				l.logger.Error("get next commit log failed", rpl.EventType("replay"), rpl.Err(err))
 				return err
 			}
 
@@ -50,29 +51,111 @@ func (l *Ledis) handleReplication() error {
 		if rl.Compression == 1 {
 			//todo optimize
 			if rl.Data, err = snappy.Decode(nil, rl.Data); err != nil {
-				log.Errorf("decode log error %s", err.Error())
+				l.logger.Error("decode log failed", rpl.LogPos(rl.ID), rpl.EventType("replay"), rpl.Err(err))
 				return err
 			}
 		}
 
+		var r store.BatchDataReplay = l.rbatch
+		if l.rfilter != nil {
+			r = &filterReplay{l.rfilter, l.rbatch}
+		}
+
+		var ns []Notification
+		var items []store.BatchItem
 		if bd, err := store.NewBatchData(rl.Data); err != nil {
-			log.Errorf("decode batch log error %s", err.Error())
+			l.logger.Error("decode batch log failed", rpl.LogPos(rl.ID), rpl.EventType("replay"), rpl.Err(err))
 			return err
-		} else if err = bd.Replay(l.rbatch); err != nil {
-			log.Errorf("replay batch log error %s", err.Error())
+		} else if err = bd.Replay(r); err != nil {
+			l.logger.Error("replay batch log failed", rpl.LogPos(rl.ID), rpl.EventType("replay"), rpl.Err(err))
+		} else if l.nm.watched() || l.wm.watched() {
+			items = l.replicationItems(bd)
+			if l.nm.watched() {
+				ns = l.nm.decode(items, "")
+			}
 		}
 
 		l.commitLock.Lock()
 		if err = l.rbatch.Commit(); err != nil {
-			log.Errorf("commit log error %s", err.Error())
+			l.logger.Error("commit log failed", rpl.LogPos(rl.ID), rpl.EventType("replay"), rpl.Err(err))
 		} else if err = l.r.UpdateCommitID(rl.ID); err != nil {
-			log.Errorf("update commit id error %s", err.Error())
+			l.logger.Error("update commit id failed", rpl.LogPos(rl.ID), rpl.EventType("replay"), rpl.Err(err))
 		}
 
 		l.commitLock.Unlock()
 		if err != nil {
 			return err
 		}
+		l.changes.Add(1)
+
+		// a value may have the prefix too, which only reloads the users
+		if bytes.Contains(rl.Data, aclKeyPrefix) {
+			if err = l.loadACL(); err != nil {
+				l.logger.Error("load acl users failed", rpl.LogPos(rl.ID), rpl.EventType("replay"), rpl.Err(err))
+				return err
+			}
+		}
+
+		if l.wm.watched() {
+			l.wm.touch(items)
+		}
+		l.nm.publish(ns)
+	}
+}
+
+// replicationItems returns the batch items applied by the filter.
+func (l *Ledis) replicationItems(bd *store.BatchData) []store.BatchItem {
+	items, err := bd.Items()
+	if err != nil {
+		return nil
+	}
+
+	if l.rfilter != nil {
+		n := 0
+		for _, item := range items {
+			if l.rfilter(item.Key, item.Value) {
+				items[n] = item
+				n++
+			}
+		}
+		items = items[:n]
+	}
+
+	return items
+}
+
+// ReplicationFilter decides whether a replicated key should be applied
+// to the slave store. The key is the raw store key, laid out as:
+//
+//	| db index (uvarint) | data type (1 byte) | type specific encoded key |
+//
+// where data type is one of KVType, HashType, ListType, ExpTimeType, etc.
+// The value is nil if the log item is a delete.
+type ReplicationFilter func(key []byte, value []byte) bool
+
+// SetReplicationFilter sets the filter for the logs applied from master.
+// Items for which the filter returns false are skipped, but the commit id
+// still advances. A nil filter applies all items.
+func (l *Ledis) SetReplicationFilter(f ReplicationFilter) {
+	l.wLock.Lock()
+	l.rfilter = f
+	l.wLock.Unlock()
+}
+
+type filterReplay struct {
+	f  ReplicationFilter
+	wb *store.WriteBatch
+}
+
+func (r *filterReplay) Put(key, value []byte) {
+	if r.f(key, value) {
+		r.wb.Put(key, value)
+	}
+}
+
+func (r *filterReplay) Delete(key []byte) {
+	if r.f(key, nil) {
+		r.wb.Delete(key)
 	}
 }
 
@@ -121,6 +204,17 @@ func (l *Ledis) WaitReplication() error {
 
 // StoreLogsFromReader stores logs from the Reader
 func (l *Ledis) StoreLogsFromReader(rb io.Reader) error {
+	return l.storeLogsFromReader(rb, 0)
+}
+
+// StoreLogsFromReaderUntil stores logs from the Reader whose create time
+// is not after until, it stops at the first later log and returns nil.
+// Used with LoadDump, it recovers the data to a point in time.
+func (l *Ledis) StoreLogsFromReaderUntil(rb io.Reader, until time.Time) error {
+	return l.storeLogsFromReader(rb, uint32(until.Unix()))
+}
+
+func (l *Ledis) storeLogsFromReader(rb io.Reader, until uint32) error {
 	if !l.ReplicationUsed() {
 		return ErrRplNotSupport
 	} else if !l.cfg.Readonly {
@@ -138,6 +232,10 @@ func (l *Ledis) StoreLogsFromReader(rb io.Reader) error {
 			}
 		}
 
+		if until > 0 && log.CreateTime > until {
+			break
+		}
+
 		if err := l.r.StoreLog(log); err != nil {
 			return err
 		}
@@ -235,6 +333,15 @@ func (l *Ledis) propagate(rl *rpl.Log) {
 	}
 }
 
+// SetSlaveLogID sets the func of the lowest last log id of the connected
+// slaves, the logs they have not synced yet are kept over
+// max_log_file_num and max_log_total_size.
+func (l *Ledis) SetSlaveLogID(f rpl.SlaveLogIDFunc) {
+	if l.r != nil {
+		l.r.SetSlaveLogID(f)
+	}
+}
+
 // NewLogEventHandler is the handler to handle new log event.
 type NewLogEventHandler func(rl *rpl.Log)
 
@@ -249,6 +356,70 @@ func (l *Ledis) AddNewLogEventHandler(h NewLogEventHandler) error {
 	return nil
 }
 
+// ReplicationID returns the id of the history of the replication logs, a
+// slave has the id of its master. It is empty without replication.
+func (l *Ledis) ReplicationID() string {
+	if !l.ReplicationUsed() {
+		return ""
+	}
+	return l.r.ID()
+}
+
+// SetReplicationID saves the replication id of the master after a full
+// sync.
+func (l *Ledis) SetReplicationID(id string) error {
+	if !l.ReplicationUsed() {
+		return ErrRplNotSupport
+	}
+	return l.r.SetID(id)
+}
+
+// ReplicationID2 returns the replication id before the last SlaveOfNoOne and the
+// last log id of its history, empty if none.
+func (l *Ledis) ReplicationID2() (string, uint64) {
+	if !l.ReplicationUsed() {
+		return "", 0
+	}
+	return l.r.ID2()
+}
+
+// SlaveOfNoOne makes a slave a master after its replication from the old
+// master is stopped. The logs received but not applied yet are discarded with
+// the batch being applied, they may be only a part of the writes of the old
+// master, or it fails with ErrUncommittedLogs if replication.safe_promotion.
+// Discarding clears all the logs, so the other slaves of the old master fully
+// sync. Then a new history of the logs starts with a new replication id. The
+// old id is kept as the replication id2 up to the last log, so the slaves of
+// the old master which have not missed anything after it go on from its logs.
+func (l *Ledis) SlaveOfNoOne() error {
+	if !l.ReplicationUsed() {
+		return ErrRplNotSupport
+	}
+
+	// no log is applied meanwhile
+	l.wLock.Lock()
+	defer l.wLock.Unlock()
+
+	l.rbatch.Rollback()
+
+	s, err := l.r.Stat()
+	if err != nil {
+		return err
+	}
+
+	if s.LastID > s.CommitID {
+		if l.cfg.Replication.SafePromotion {
+			return ErrUncommittedLogs
+		}
+
+		l.logger.Error("discard the logs not applied for slaveof no one", rpl.Field{Key: "first_log_pos", Value: s.CommitID + 1}, rpl.LogPos(s.LastID), rpl.EventType("promote"))
+		if err = l.r.ClearWithCommitID(s.CommitID); err != nil {
+			return err
+		}
+	}
+	return l.r.ResetID()
+}
+
 // ReplicationStat returns the statistics of repliaciton.
 func (l *Ledis) ReplicationStat() (*rpl.Stat, error) {
 	if !l.ReplicationUsed() {
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/siddontang/go/snappy"
 	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/rpl"
 	"github.com/siddontang/ledisdb/store"
 )
 
@@ -36,6 +40,7 @@ func TestReplication(t *testing.T) {
 
 	cfgM.UseReplication = true
 	cfgM.Replication.Compression = true
+	cfgM.Replication.Checksum = true
 
 	os.RemoveAll(cfgM.DataDir)
 
@@ -52,7 +57,7 @@ func TestReplication(t *testing.T) {
 
 	os.RemoveAll(cfgS.DataDir)
 
-	slave, err = Open(cfgS)
+	slave, err = Open(cfgS, WithLogger(NopLogger{}))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -67,11 +72,43 @@ func TestReplication(t *testing.T) {
 	db.HSet([]byte("b"), []byte("2"), []byte("value"))
 	db.HSet([]byte("c"), []byte("3"), []byte("value"))
 
-	// m, _ := db.Multi()
-	// m.Set([]byte("a1"), []byte("value"))
-	// m.Set([]byte("b1"), []byte("value"))
-	// m.Set([]byte("c1"), []byte("value"))
-	// m.Close()
+	beginID, _ := master.r.LastLogID()
+
+	m, _ := db.Multi()
+	m.Set([]byte("a1"), []byte("value"))
+	m.Set([]byte("b1"), []byte("value"))
+	m.Set([]byte("c1"), []byte("value"))
+	m.Incr([]byte("d1"))
+	if n, _ := m.Incr([]byte("d1")); n != 2 {
+		t.Fatal("the multi must read its writes", n)
+	} else if v, _ := db.Get([]byte("a1")); v != nil {
+		t.Fatal("the writes of a multi must not be seen before close", string(v))
+	}
+	m.Close()
+
+	// one log for all the writes of a multi
+	lastID, _ := master.r.LastLogID()
+	var l rpl.Log
+	if lastID != beginID+1 {
+		t.Fatal(beginID, lastID)
+	} else if err = master.r.GetLog(lastID, &l); err != nil {
+		t.Fatal(err)
+	} else if l.CreateTime != m.multi.createTime {
+		t.Fatal(l.CreateTime, m.multi.createTime)
+	} else if l.Compression == 1 {
+		if l.Data, err = snappy.Decode(nil, l.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bd, err := store.NewBatchData(l.Data); err != nil {
+		t.Fatal(err)
+	} else if items, err := bd.Items(); err != nil || len(items) != 4 {
+		t.Fatal(items, err)
+	}
+
+	if v, _ := db.Get([]byte("d1")); string(v) != "2" {
+		t.Fatal(string(v))
+	}
 
 	slave.FlushAll()
 
@@ -106,3 +143,187 @@ func TestReplication(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestReplicationFilter(t *testing.T) {
+	cfgM := config.NewConfigDefault()
+	cfgM.DataDir = "/tmp/test_repl_filter/master"
+	cfgM.UseReplication = true
+
+	os.RemoveAll(cfgM.DataDir)
+
+	master, err := Open(cfgM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	cfgS := config.NewConfigDefault()
+	cfgS.DataDir = "/tmp/test_repl_filter/slave"
+	cfgS.UseReplication = true
+	cfgS.Readonly = true
+
+	os.RemoveAll(cfgS.DataDir)
+
+	slave, err := Open(cfgS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+
+	// only apply kv keys in db 0
+	slave.SetReplicationFilter(func(key []byte, value []byte) bool {
+		return len(key) > 1 && key[0] == 0 && key[1] == KVType
+	})
+
+	db, _ := master.Select(0)
+	db.Set([]byte("a"), []byte("value"))
+	db.HSet([]byte("a"), []byte("1"), []byte("value"))
+
+	db1, _ := master.Select(1)
+	db1.Set([]byte("a"), []byte("value"))
+
+	var buf bytes.Buffer
+	if _, _, err = master.ReadLogsTo(1, &buf); err != nil {
+		t.Fatal(err)
+	} else if err = slave.StoreLogsFromReader(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	slave.WaitReplication()
+
+	sdb, _ := slave.Select(0)
+	if v, err := sdb.Get([]byte("a")); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "value" {
+		t.Fatal(string(v))
+	}
+
+	if n, err := sdb.HLen([]byte("a")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	sdb1, _ := slave.Select(1)
+	if v, err := sdb1.Get([]byte("a")); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
+	if id, err := slave.r.LastCommitID(); err != nil {
+		t.Fatal(err)
+	} else if id != 3 {
+		t.Fatal(id)
+	}
+}
+
+func TestReplicationUntil(t *testing.T) {
+	cfgS := config.NewConfigDefault()
+	cfgS.DataDir = "/tmp/test_repl_until"
+	cfgS.UseReplication = true
+	cfgS.Readonly = true
+
+	os.RemoveAll(cfgS.DataDir)
+
+	slave, err := Open(cfgS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+
+	var buf bytes.Buffer
+	for i := uint64(1); i <= 3; i++ {
+		l := &rpl.Log{ID: i, CreateTime: uint32(100 * i)}
+		if err = l.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = slave.StoreLogsFromReaderUntil(&buf, time.Unix(200, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, err := slave.r.LastLogID(); err != nil {
+		t.Fatal(err)
+	} else if id != 2 {
+		t.Fatal(id)
+	}
+}
+
+// testLogger keeps the fields of the error messages.
+type testLogger struct {
+	sync.Mutex
+	errors []map[string]interface{}
+}
+
+func (l *testLogger) Info(msg string, fields ...Field) {}
+
+func (l *testLogger) Error(msg string, fields ...Field) {
+	m := map[string]interface{}{"msg": msg}
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+
+	l.Lock()
+	l.errors = append(l.errors, m)
+	l.Unlock()
+}
+
+func TestSlaveOfNoOne(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_slaveof_no_one"
+	cfg.UseReplication = true
+	cfg.Readonly = true
+
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	logger := &testLogger{}
+	l, err := Open(cfg, WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// received but not applied
+	if err = l.r.StoreLog(&rpl.Log{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	id := l.ReplicationID()
+
+	cfg.Update(func() { cfg.Replication.SafePromotion = true })
+	if err = l.SlaveOfNoOne(); err != ErrUncommittedLogs {
+		t.Fatal(err)
+	} else if l.ReplicationID() != id {
+		t.Fatal("id changed")
+	}
+
+	cfg.Update(func() { cfg.Replication.SafePromotion = false })
+	if err = l.SlaveOfNoOne(); err != nil {
+		t.Fatal(err)
+	} else if l.ReplicationID() == id {
+		t.Fatal("id not changed")
+	}
+
+	logger.Lock()
+	if len(logger.errors) != 1 || logger.errors[0]["log_pos"] != uint64(1) || logger.errors[0]["event_type"] != "promote" {
+		t.Fatal(logger.errors)
+	}
+	logger.Unlock()
+
+	if s, err := l.ReplicationStat(); err != nil {
+		t.Fatal(err)
+	} else if s.LastID != 0 || s.CommitID != 0 {
+		t.Fatal(s)
+	}
+
+	// the new writes are logged after the commit id
+	cfg.SetReadonly(false)
+	db, _ := l.Select(0)
+	if err = db.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	} else if id, _ := l.r.LastLogID(); id != 1 {
+		t.Fatal(id)
+	}
+}
@@ -0,0 +1,39 @@
+package ledis
+
+import (
+	"time"
+
+	"github.com/siddontang/ledisdb/rpl"
+)
+
+// CommitOptions are the options of the writes of a DB from
+// WithCommitOptions.
+type CommitOptions struct {
+	// WaitReplicas > 0 makes a write return only after so many slaves have
+	// its replication log, or after WaitTimeout, 0 means no timeout. The
+	// write is committed locally anyway.
+	WaitReplicas int
+	WaitTimeout  time.Duration
+}
+
+// ReplicaWaiter blocks until n slaves have the log of id or timeout, then
+// returns how many have it.
+type ReplicaWaiter func(id uint64, n int, timeout time.Duration) int
+
+// SetReplicaWaiter sets the waiter of the writes with WaitReplicas, it is
+// set at start by the server which knows the slaves. The writes do not wait
+// without it.
+func (l *Ledis) SetReplicaWaiter(w ReplicaWaiter) {
+	l.rwaiter = w
+}
+
+func (l *Ledis) waitReplicas(id uint64, opts CommitOptions) {
+	if l.rwaiter == nil {
+		return
+	}
+
+	if n := l.rwaiter(id, opts.WaitReplicas, opts.WaitTimeout); n < opts.WaitReplicas {
+		l.logger.Info("not enough slaves have the log", rpl.LogPos(id), rpl.EventType("wait"),
+			rpl.Field{Key: "slaves", Value: n}, rpl.Field{Key: "wait_slaves", Value: opts.WaitReplicas}, rpl.Field{Key: "timeout", Value: opts.WaitTimeout})
+	}
+}
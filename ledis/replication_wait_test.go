@@ -0,0 +1,67 @@
+package ledis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestCommitOptions(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_commit_options"
+	cfg.UseReplication = true
+
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	type wait struct {
+		id      uint64
+		n       int
+		timeout time.Duration
+	}
+	var waits []wait
+	l.SetReplicaWaiter(func(id uint64, n int, timeout time.Duration) int {
+		waits = append(waits, wait{id, n, timeout})
+		return 0
+	})
+
+	db, _ := l.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	if len(waits) != 0 {
+		t.Fatal(waits)
+	}
+
+	wdb := db.WithCommitOptions(CommitOptions{WaitReplicas: 2, WaitTimeout: time.Second})
+	if err = wdb.Set([]byte("a"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	wdb.HSet([]byte("h"), []byte("f"), []byte("v"))
+	wdb.FlushAll()
+
+	// FlushAll commits the kv and the hash data
+	lastID, _ := l.r.LastLogID()
+	if len(waits) != 4 {
+		t.Fatal(waits)
+	} else if w := waits[0]; w.id != 2 || w.n != 2 || w.timeout != time.Second {
+		t.Fatal(w)
+	} else if waits[3].id != lastID {
+		t.Fatal(waits[3], lastID)
+	}
+
+	// the copy shares the data and the locks with db
+	if n, _ := db.Exists([]byte("a")); n != 0 {
+		t.Fatal(n)
+	}
+	db.Set([]byte("a"), []byte("3"))
+	if len(waits) != 4 {
+		t.Fatal(waits)
+	}
+}
@@ -0,0 +1,77 @@
+package ledis
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+type sampleEntry struct {
+	key   []byte
+	value []byte
+}
+
+// sampleRange returns count random entries of the iterator in one pass.
+//
+// If count > 0, the entries are distinct and chosen by reservoir sampling,
+// so only count entries are kept in memory. If count < 0, -count entries
+// are chosen with repeats, size must be the number of entries in the range.
+func sampleRange(it *store.RangeLimitIterator, count int, size int64) []sampleEntry {
+	defer it.Close()
+
+	var ay []sampleEntry
+	if count > 0 {
+		ay = make([]sampleEntry, 0, count)
+		var n int64
+		for ; it.Valid(); it.Next() {
+			n++
+			if len(ay) < count {
+				ay = append(ay, sampleEntry{it.Key(), it.Value()})
+			} else if i := rand.Int63n(n); i < int64(count) {
+				ay[i] = sampleEntry{it.Key(), it.Value()}
+			}
+		}
+	} else if count < 0 && size > 0 {
+		indexes := make([]int64, -count)
+		for i := range indexes {
+			indexes[i] = rand.Int63n(size)
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+		ay = make([]sampleEntry, 0, -count)
+		var n int64
+		for ; it.Valid() && len(ay) < len(indexes); it.Next() {
+			var e *sampleEntry
+			for len(ay) < len(indexes) && indexes[len(ay)] == n {
+				if e == nil {
+					e = &sampleEntry{it.Key(), it.Value()}
+				}
+				ay = append(ay, *e)
+			}
+			n++
+		}
+	}
+
+	// neither the reservoir nor the sorted indexes are in random order
+	rand.Shuffle(len(ay), func(i, j int) { ay[i], ay[j] = ay[j], ay[i] })
+	return ay
+}
+
+// sampleIndexes returns count random indexes in [0, size) like sampleRange,
+// for the entries already in memory.
+func sampleIndexes(size int, count int) []int {
+	if count > 0 {
+		ay := rand.Perm(size)
+		if count < size {
+			ay = ay[:count]
+		}
+		return ay
+	}
+
+	ay := make([]int, -count)
+	for i := range ay {
+		ay[i] = rand.Intn(size)
+	}
+	return ay
+}
@@ -1,14 +1,18 @@
 package ledis
 
 import (
+	"bytes"
 	"errors"
 	"regexp"
+	"regexp/syntax"
+	"strings"
 
 	"github.com/siddontang/ledisdb/store"
 )
 
 var errDataType = errors.New("error data type")
 var errMetaKey = errors.New("error meta key")
+var errScanCursor = errors.New("invalid scan cursor")
 
 //Scan scans the data. If inclusive is true, scan range [cursor, inf) else (cursor, inf)
 func (db *DB) Scan(dataType DataType, cursor []byte, count int, inclusive bool, match string) ([][]byte, error) {
@@ -43,6 +47,10 @@ func getDataStoreType(dataType DataType) (byte, error) {
 		storeDataType = SSizeType
 	case ZSET:
 		storeDataType = ZSizeType
+	case HLL:
+		storeDataType = HLLType
+	case STREAM:
+		storeDataType = StreamMetaType
 	default:
 		return 0, errDataType
 	}
@@ -62,6 +70,54 @@ func buildMatchRegexp(match string) (*regexp.Regexp, error) {
 	return r, nil
 }
 
+// buildGlobRegexp builds the regexp for a glob style pattern like redis
+// MATCH, * matches anything, ? matches one character, [abc] and [^a-z] match
+// one character in or not in the class, and \ escapes the next character.
+func buildGlobRegexp(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("(?s)^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteByte('.')
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			j := strings.IndexByte(pattern[i+1:], ']')
+			if j < 0 {
+				buf.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				break
+			}
+
+			class := pattern[i+1 : i+1+j]
+			buf.WriteByte('[')
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				buf.WriteByte('^')
+				class = class[1:]
+			}
+			buf.WriteString(strings.Replace(class, "\\", "\\\\", -1))
+			buf.WriteByte(']')
+			i += j + 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	buf.WriteByte('$')
+	return regexp.Compile(buf.String())
+}
+
 func (db *DB) buildScanIterator(minKey []byte, maxKey []byte, inclusive bool, reverse bool) *store.RangeLimitIterator {
 	tp := store.RangeOpen
 
@@ -85,6 +141,81 @@ func (db *DB) buildScanIterator(minKey []byte, maxKey []byte, inclusive bool, re
 	return it
 }
 
+// matchPrefix returns the literal prefix of everything r matches, nil if
+// there is none or r is not anchored at the start, like a glob pattern
+// "user:*" or a regexp "^user:".
+func matchPrefix(r *regexp.Regexp) []byte {
+	if r == nil {
+		return nil
+	}
+
+	re, err := syntax.Parse(r.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	for re.Op == syntax.OpConcat && len(re.Sub) > 0 {
+		re = re.Sub[0]
+	}
+	if re.Op != syntax.OpBeginText {
+		return nil
+	}
+
+	if prefix, _ := r.LiteralPrefix(); len(prefix) > 0 {
+		return []byte(prefix)
+	}
+	return nil
+}
+
+// buildPrefixScanIterator is buildScanIterator narrowed to the store keys
+// starting with prefix, the keys out of it are never walked. The prefix is
+// always included, it may be a key.
+func (db *DB) buildPrefixScanIterator(minKey []byte, maxKey []byte, inclusive bool, reverse bool, prefix []byte) *store.RangeLimitIterator {
+	if len(prefix) == 0 {
+		return db.buildScanIterator(minKey, maxKey, inclusive, reverse)
+	}
+
+	minOpen := reverse || !inclusive
+	maxOpen := !reverse || !inclusive
+
+	if bytes.Compare(prefix, minKey) > 0 {
+		minKey, minOpen = prefix, false
+	}
+	if end := store.PrefixEnd(prefix); end != nil && bytes.Compare(end, maxKey) < 0 {
+		maxKey, maxOpen = end, true
+	}
+
+	var tp uint8 = store.RangeClose
+	if minOpen {
+		tp |= store.RangeLOpen
+	}
+	if maxOpen {
+		tp |= store.RangeROpen
+	}
+
+	if !reverse {
+		return db.bucket.RangeIterator(minKey, maxKey, tp)
+	}
+	return db.bucket.RevRangeIterator(minKey, maxKey, tp)
+}
+
+// scanPrefix returns the store key prefix of the keys of storeDataType
+// matched by r, nil if r matches keys of any prefix.
+func (db *DB) scanPrefix(storeDataType byte, r *regexp.Regexp) ([]byte, error) {
+	if p := matchPrefix(r); p != nil {
+		return db.encodeScanKey(storeDataType, p)
+	}
+	return nil, nil
+}
+
+// dataScanPrefix is scanPrefix for the fields or members of key.
+func (db *DB) dataScanPrefix(storeDataType byte, key []byte, r *regexp.Regexp) ([]byte, error) {
+	if p := matchPrefix(r); p != nil {
+		return db.encodeDataScanKey(storeDataType, key, p)
+	}
+	return nil, nil
+}
+
 func (db *DB) buildScanKeyRange(storeDataType byte, key []byte, reverse bool) (minKey []byte, maxKey []byte, err error) {
 	if !reverse {
 		if minKey, err = db.encodeScanMinKey(storeDataType, key); err != nil {
@@ -104,6 +235,15 @@ func (db *DB) buildScanKeyRange(storeDataType byte, key []byte, reverse bool) (m
 	return
 }
 
+// scanCheckSteps is how many keys a scan walks between checking the context.
+const scanCheckSteps = 1024
+
+// scanCanceled reports whether the context of db is done, checked only every
+// scanCheckSteps steps to keep the scan fast.
+func (db *DB) scanCanceled(steps int) bool {
+	return db.ctx != nil && steps%scanCheckSteps == 0 && db.ctx.Err() != nil
+}
+
 func checkScanCount(count int) int {
 	if count <= 0 {
 		count = defaultScanCount
@@ -125,13 +265,23 @@ func (db *DB) scanGeneric(storeDataType byte, key []byte, count int,
 		return nil, err
 	}
 
+	prefix, err := db.scanPrefix(storeDataType, r)
+	if err != nil {
+		return nil, err
+	}
+
 	count = checkScanCount(count)
 
-	it := db.buildScanIterator(minKey, maxKey, inclusive, reverse)
+	it := db.buildPrefixScanIterator(minKey, maxKey, inclusive, reverse, prefix)
 
 	v := make([][]byte, 0, count)
 
-	for i := 0; it.Valid() && i < count; it.Next() {
+	for i, n := 0, 0; it.Valid() && i < count; it.Next() {
+		if n++; db.scanCanceled(n) {
+			it.Close()
+			return v, db.ctx.Err()
+		}
+
 		if k, err := db.decodeScanKey(storeDataType, it.Key()); err != nil {
 			continue
 		} else if r != nil && !r.Match(k) {
@@ -174,6 +324,10 @@ func (db *DB) encodeScanKey(storeDataType byte, key []byte) ([]byte, error) {
 		return db.zEncodeSizeKey(key), nil
 	case SSizeType:
 		return db.sEncodeSizeKey(key), nil
+	case HLLType:
+		return db.hllEncodeKey(key), nil
+	case StreamMetaType:
+		return db.xEncodeMetaKey(key), nil
 	default:
 		return nil, errDataType
 	}
@@ -191,6 +345,10 @@ func (db *DB) decodeScanKey(storeDataType byte, ek []byte) (key []byte, err erro
 		key, err = db.zDecodeSizeKey(ek)
 	case SSizeType:
 		key, err = db.sDecodeSizeKey(ek)
+	case HLLType:
+		key, err = db.hllDecodeKey(ek)
+	case StreamMetaType:
+		key, err = db.xDecodeMetaKey(ek)
 	default:
 		err = errDataType
 	}
@@ -250,8 +408,10 @@ func (db *DB) encodeDataScanKey(storeDataType byte, key []byte, cursor []byte) (
 	}
 }
 
+// buildDataScanIterator builds the iterator of the fields or members of key
+// after cursor, only the ones matched by r if it has a literal prefix.
 func (db *DB) buildDataScanIterator(storeDataType byte, key []byte, cursor []byte, count int,
-	inclusive bool, reverse bool) (*store.RangeLimitIterator, error) {
+	inclusive bool, reverse bool, r *regexp.Regexp) (*store.RangeLimitIterator, error) {
 
 	if err := checkKeySize(key); err != nil {
 		return nil, err
@@ -262,7 +422,12 @@ func (db *DB) buildDataScanIterator(storeDataType byte, key []byte, cursor []byt
 		return nil, err
 	}
 
-	it := db.buildScanIterator(minKey, maxKey, inclusive, reverse)
+	prefix, err := db.dataScanPrefix(storeDataType, key, r)
+	if err != nil {
+		return nil, err
+	}
+
+	it := db.buildPrefixScanIterator(minKey, maxKey, inclusive, reverse, prefix)
 
 	return it, nil
 }
@@ -277,14 +442,26 @@ func (db *DB) hScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 
 	v := make([]FVPair, 0, count)
 
-	it, err := db.buildDataScanIterator(HashType, key, cursor, count, inclusive, reverse)
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	} else if pairs, zip, err := db.hGetZiplist(key); err != nil {
+		return nil, err
+	} else if zip {
+		return hScanZiplist(pairs, cursor, count, inclusive, r, reverse, v), nil
+	}
+
+	it, err := db.buildDataScanIterator(HashType, key, cursor, count, inclusive, reverse, r)
 	if err != nil {
 		return nil, err
 	}
 
 	defer it.Close()
 
-	for i := 0; it.Valid() && i < count; it.Next() {
+	for i, n := 0, 0; it.Valid() && i < count; it.Next() {
+		if n++; db.scanCanceled(n) {
+			return v, db.ctx.Err()
+		}
+
 		_, f, err := db.hDecodeHashKey(it.Key())
 		if err != nil {
 			return nil, err
@@ -300,6 +477,37 @@ func (db *DB) hScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 	return v, nil
 }
 
+// hScanZiplist scans the sorted pairs of a hash in the ziplist encoding like
+// the store keys of the hashtable encoding, appended to v.
+func hScanZiplist(pairs []FVPair, cursor []byte, count int, inclusive bool, r *regexp.Regexp, reverse bool, v []FVPair) []FVPair {
+	for i := range pairs {
+		if len(v) >= count {
+			break
+		}
+
+		p := pairs[i]
+		if reverse {
+			p = pairs[len(pairs)-1-i]
+		}
+
+		if len(cursor) > 0 {
+			c := bytes.Compare(p.Field, cursor)
+			if reverse {
+				c = -c
+			}
+			if c < 0 || c == 0 && !inclusive {
+				continue
+			}
+		}
+
+		if r != nil && !r.Match(p.Field) {
+			continue
+		}
+		v = append(v, p)
+	}
+	return v
+}
+
 // HScan scans data for hash.
 func (db *DB) HScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([]FVPair, error) {
 	return db.hScanGeneric(key, cursor, count, inclusive, match, false)
@@ -320,14 +528,18 @@ func (db *DB) sScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 
 	v := make([][]byte, 0, count)
 
-	it, err := db.buildDataScanIterator(SetType, key, cursor, count, inclusive, reverse)
+	it, err := db.buildDataScanIterator(SetType, key, cursor, count, inclusive, reverse, r)
 	if err != nil {
 		return nil, err
 	}
 
 	defer it.Close()
 
-	for i := 0; it.Valid() && i < count; it.Next() {
+	for i, n := 0, 0; it.Valid() && i < count; it.Next() {
+		if n++; db.scanCanceled(n) {
+			return v, db.ctx.Err()
+		}
+
 		_, m, err := db.sDecodeSetKey(it.Key())
 		if err != nil {
 			return nil, err
@@ -348,6 +560,160 @@ func (db *DB) SScan(key []byte, cursor []byte, count int, inclusive bool, match
 	return db.sScanGeneric(key, cursor, count, inclusive, match, false)
 }
 
+// SScanMatch scans the set members after cursor like redis SSCAN, the members
+// are filtered by the glob pattern. count is a hint of how many members to
+// walk, the scan goes on until at least one member matches or the set is
+// exhausted, then next is nil.
+func (db *DB) SScanMatch(key []byte, cursor []byte, count int, pattern string) (next []byte, members [][]byte, err error) {
+	count = checkScanCount(count)
+
+	r, err := buildGlobRegexp(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members = make([][]byte, 0, count)
+	if db.isExpired(SetType, key) {
+		return nil, members, nil
+	}
+
+	it, err := db.buildDataScanIterator(SetType, key, cursor, count, false, false, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer it.Close()
+
+	var last []byte
+	for n := 0; it.Valid(); it.Next() {
+		if db.scanCanceled(n + 1) {
+			return nil, members, db.ctx.Err()
+		}
+
+		_, m, err := db.sDecodeSetKey(it.Key())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		last = m
+		if r == nil || r.Match(m) {
+			members = append(members, m)
+		}
+
+		if n++; n >= count && len(members) > 0 {
+			it.Next()
+			break
+		}
+	}
+
+	if it.Valid() {
+		next = last
+	}
+
+	return next, members, nil
+}
+
+// scanKeyTypes are the data types walked by ScanKeys in order, with the
+// names of the type filter.
+var scanKeyTypes = []struct {
+	name      string
+	storeType byte
+}{
+	{"string", KVType},
+	{"list", LMetaType},
+	{"hash", HSizeType},
+	{"set", SSizeType},
+	{"zset", ZSizeType},
+	{"stream", StreamMetaType},
+}
+
+func scanKeyTypeIndex(name string) int {
+	for i, tp := range scanKeyTypes {
+		if tp.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ScanKeys scans the keys of all the data types like redis SCAN, the types
+// are walked one after another and a key of many types is returned for each
+// of them. The keys are filtered by the glob pattern and, if keyType is not
+// empty, by the type, one of string, list, hash, set, zset and stream. Only
+// the keys of keyType are walked, a type is a separate range of the store.
+//
+// The cursor is "type:key" of the last walked key, the scan only moves
+// forward, so every key which exists during the whole scan is returned once.
+// count is a hint of how many keys to walk, a page may be empty, and only a
+// nil next ends the scan.
+func (db *DB) ScanKeys(cursor []byte, count int, pattern string, keyType string) (next []byte, keys [][]byte, err error) {
+	count = checkScanCount(count)
+
+	r, err := buildGlobRegexp(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(keyType) > 0 && scanKeyTypeIndex(keyType) < 0 {
+		return nil, nil, errDataType
+	}
+
+	start := 0
+	var after []byte
+	if len(cursor) > 0 {
+		i := bytes.IndexByte(cursor, ':')
+		if i < 0 {
+			return nil, nil, errScanCursor
+		} else if start = scanKeyTypeIndex(string(cursor[:i])); start < 0 {
+			return nil, nil, errScanCursor
+		}
+		after = cursor[i+1:]
+	}
+
+	keys = make([][]byte, 0, count)
+	n := 0
+	for ; start < len(scanKeyTypes); start, after = start+1, nil {
+		tp := scanKeyTypes[start]
+		if len(keyType) > 0 && keyType != tp.name {
+			continue
+		}
+
+		minKey, maxKey, err := db.buildScanKeyRange(tp.storeType, after, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		prefix, err := db.scanPrefix(tp.storeType, r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		it := db.buildPrefixScanIterator(minKey, maxKey, false, false, prefix)
+		for ; it.Valid(); it.Next() {
+			if n++; db.scanCanceled(n) {
+				it.Close()
+				return nil, keys, db.ctx.Err()
+			}
+
+			k, err := db.decodeScanKey(tp.storeType, it.Key())
+			if err != nil {
+				continue
+			} else if r == nil || r.Match(k) {
+				keys = append(keys, k)
+			}
+
+			if n >= count {
+				it.Close()
+				next = append([]byte(tp.name+":"), k...)
+				return next, keys, nil
+			}
+		}
+		it.Close()
+	}
+
+	return nil, keys, nil
+}
+
 // SRevScan scans data reversed for set.
 func (db *DB) SRevScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([][]byte, error) {
 	return db.sScanGeneric(key, cursor, count, inclusive, match, true)
@@ -363,14 +729,18 @@ func (db *DB) zScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 
 	v := make([]ScorePair, 0, count)
 
-	it, err := db.buildDataScanIterator(ZSetType, key, cursor, count, inclusive, reverse)
+	it, err := db.buildDataScanIterator(ZSetType, key, cursor, count, inclusive, reverse, r)
 	if err != nil {
 		return nil, err
 	}
 
 	defer it.Close()
 
-	for i := 0; it.Valid() && i < count; it.Next() {
+	for i, n := 0, 0; it.Valid() && i < count; it.Next() {
+		if n++; db.scanCanceled(n) {
+			return v, db.ctx.Err()
+		}
+
 		_, m, err := db.zDecodeSetKey(it.Key())
 		if err != nil {
 			return nil, err
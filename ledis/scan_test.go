@@ -1,6 +1,8 @@
 package ledis
 
 import (
+	"context"
+	"fmt"
 	"testing"
 )
 
@@ -400,3 +402,321 @@ func TestDBZScan(t *testing.T) {
 	}
 
 }
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		miss    []string
+	}{
+		{"a*", []string{"a", "abc"}, []string{"ba", ""}},
+		{"a?c", []string{"abc", "a.c"}, []string{"ac", "abbc"}},
+		{"[ab]x", []string{"ax", "bx"}, []string{"cx"}},
+		{"[^ab]x", []string{"cx"}, []string{"ax"}},
+		{"a\\*", []string{"a*"}, []string{"ab"}},
+		{"a.b", []string{"a.b"}, []string{"axb"}},
+	}
+
+	for _, tt := range tests {
+		r, err := buildGlobRegexp(tt.pattern)
+		if err != nil {
+			t.Fatal(tt.pattern, err)
+		}
+		for _, s := range tt.match {
+			if !r.MatchString(s) {
+				t.Fatal(tt.pattern, s)
+			}
+		}
+		for _, s := range tt.miss {
+			if r.MatchString(s) {
+				t.Fatal(tt.pattern, s)
+			}
+		}
+	}
+}
+
+func TestDBSScanMatch(t *testing.T) {
+	db := getTestDB()
+	key := []byte("scan_s_match_key")
+	db.SClear(key)
+
+	for i := 0; i < 50; i++ {
+		db.SAdd(key, []byte(fmt.Sprintf("a%02d", i)), []byte(fmt.Sprintf("b%02d", i)))
+	}
+
+	var cursor []byte
+	var all []string
+	pages := 0
+	for {
+		next, v, err := db.SScanMatch(key, cursor, 7, "b*[05]")
+		if err != nil {
+			t.Fatal(err)
+		} else if len(v) == 0 && next != nil {
+			t.Fatal("empty page before the end")
+		}
+
+		for _, m := range v {
+			all = append(all, string(m))
+		}
+
+		pages++
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+
+	if pages < 2 {
+		t.Fatal(pages)
+	} else if len(all) != 10 {
+		t.Fatal(all)
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range all {
+		if m[0] != 'b' || (m[2] != '0' && m[2] != '5') || seen[m] {
+			t.Fatal(all)
+		}
+		seen[m] = true
+	}
+
+	if next, v, err := db.SScanMatch(key, nil, 1000, ""); err != nil {
+		t.Fatal(err)
+	} else if next != nil || len(v) != 100 {
+		t.Fatal(next, len(v))
+	}
+}
+
+func TestDBScanContext(t *testing.T) {
+	db, _ := getTestDB().l.Select(2)
+	db.FlushAll()
+
+	pairs := make([]KVPair, 10000)
+	for i := range pairs {
+		pairs[i] = KVPair{Key: []byte(fmt.Sprintf("scan_ctx_%05d", i)), Value: []byte("v")}
+	}
+	db.MSet(pairs...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cdb := db.WithContext(ctx)
+	if v, err := cdb.Scan(KV, nil, len(pairs), false, ""); err != context.Canceled {
+		t.Fatal(err)
+	} else if len(v) != scanCheckSteps-1 {
+		t.Fatal(len(v))
+	}
+
+	// a short scan does not look at the context
+	if _, err := cdb.Scan(KV, nil, 10, false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// db itself is not changed
+	if v, err := db.Scan(KV, nil, len(pairs), false, ""); err != nil {
+		t.Fatal(err)
+	} else if len(v) != len(pairs) {
+		t.Fatal(len(v))
+	}
+}
+
+func TestDBScanKeys(t *testing.T) {
+	db, _ := getTestDB().l.Select(5)
+	db.FlushAll()
+
+	for i := 0; i < 20; i++ {
+		db.Set([]byte(fmt.Sprintf("scan_keys_kv_%02d", i)), []byte("v"))
+	}
+	db.HSet([]byte("scan_keys_a"), []byte("f"), []byte("v"))
+	db.SAdd([]byte("scan_keys_a"), []byte("m"))
+	db.ZAdd([]byte("scan_keys_z"), ScorePair{Score: 1, Member: []byte("m")})
+
+	scanAll := func(count int, pattern string, keyType string) []string {
+		var cursor []byte
+		var all []string
+		for {
+			next, v, err := db.ScanKeys(cursor, count, pattern, keyType)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, k := range v {
+				all = append(all, string(k))
+			}
+
+			if next == nil {
+				return all
+			}
+			cursor = next
+
+			// a key inserted before the cursor is not returned
+			db.Set([]byte("scan_keys_kv_"), []byte("v"))
+		}
+	}
+
+	if all := scanAll(3, "", ""); len(all) != 23 {
+		t.Fatal(all)
+	} else if all[20] != "scan_keys_a" || all[21] != "scan_keys_a" || all[22] != "scan_keys_z" {
+		t.Fatal(all)
+	}
+
+	if all := scanAll(2, "*_1?", "string"); len(all) != 10 {
+		t.Fatal(all)
+	}
+
+	if all := scanAll(10, "", "zset"); len(all) != 1 || all[0] != "scan_keys_z" {
+		t.Fatal(all)
+	}
+
+	if all := scanAll(10, "", "list"); len(all) != 0 {
+		t.Fatal(all)
+	}
+
+	if _, _, err := db.ScanKeys(nil, 10, "", "unknown"); err == nil {
+		t.Fatal("invalid type must fail")
+	} else if _, _, err := db.ScanKeys([]byte("unknown"), 10, "", ""); err == nil {
+		t.Fatal("invalid cursor must fail")
+	}
+
+	db.FlushAll()
+}
+
+func TestMatchPrefix(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		prefix  string
+	}{
+		{"^user:", "user:"},
+		{"^user:[0-9]+$", "user:"},
+		{"user:", ""},
+		{"^a|^b", ""},
+		{"^(?i)user", ""},
+		{".*", ""},
+	} {
+		r, _ := buildMatchRegexp(test.pattern)
+		if p := matchPrefix(r); string(p) != test.prefix {
+			t.Fatal(test.pattern, string(p))
+		}
+	}
+
+	for _, test := range []struct {
+		pattern string
+		prefix  string
+	}{
+		{"user:*", "user:"},
+		{"user:?", "user:"},
+		{"us\\*er*", "us*er"},
+		{"*user", ""},
+		{"[ab]*", ""},
+	} {
+		r, _ := buildGlobRegexp(test.pattern)
+		if p := matchPrefix(r); string(p) != test.prefix {
+			t.Fatal(test.pattern, string(p))
+		}
+	}
+}
+
+func TestDBScanMatchPrefix(t *testing.T) {
+	db, _ := getTestDB().l.Select(6)
+	db.FlushAll()
+	defer db.FlushAll()
+
+	key := []byte("scan_prefix_h")
+	for _, k := range []string{"a", "ab", "ab1", "ab2", "abc", "ac", "b"} {
+		db.Set([]byte(k), []byte("v"))
+		db.HSet(key, []byte(k), []byte("v"))
+	}
+
+	for _, test := range []struct {
+		cursor    string
+		inclusive bool
+		reverse   bool
+		keys      []string
+	}{
+		{"", true, false, []string{"ab", "ab1", "ab2", "abc"}},
+		{"", true, true, []string{"abc", "ab2", "ab1", "ab"}},
+		{"ab", true, false, []string{"ab", "ab1", "ab2", "abc"}},
+		{"ab", false, false, []string{"ab1", "ab2", "abc"}},
+		{"ab1", true, false, []string{"ab1", "ab2", "abc"}},
+		{"ab1", false, true, []string{"ab"}},
+		{"ab2", true, true, []string{"ab2", "ab1", "ab"}},
+		{"abc", false, false, nil},
+		{"ac", false, true, []string{"abc", "ab2", "ab1", "ab"}},
+		{"a", false, false, []string{"ab", "ab1", "ab2", "abc"}},
+	} {
+		var cursor []byte
+		if len(test.cursor) > 0 {
+			cursor = []byte(test.cursor)
+		}
+
+		scan, hscan := db.Scan, db.HScan
+		if test.reverse {
+			scan, hscan = db.RevScan, db.HRevScan
+		}
+
+		if v, err := scan(KV, cursor, 10, test.inclusive, "^ab"); err != nil {
+			t.Fatal(err)
+		} else {
+			checkTestScan(t, v, test.keys...)
+		}
+
+		if v, err := hscan(key, cursor, 10, test.inclusive, "^ab"); err != nil {
+			t.Fatal(err)
+		} else if len(v) != len(test.keys) {
+			t.Fatal(test, len(v))
+		} else {
+			for i, p := range v {
+				if string(p.Field) != test.keys[i] {
+					t.Fatal(test, string(p.Field))
+				}
+			}
+		}
+	}
+
+	if _, keys, err := db.ScanKeys(nil, 10, "ab?", "string"); err != nil {
+		t.Fatal(err)
+	} else {
+		checkTestScan(t, keys, "ab1", "ab2", "abc")
+	}
+
+	if _, members, err := db.SScanMatch(key, nil, 10, "ab*"); err != nil {
+		t.Fatal(err)
+	} else if len(members) != 0 {
+		t.Fatal(members)
+	}
+}
+
+func benchmarkScanMatch(b *testing.B, match string) {
+	db, _ := getTestDB().l.Select(7)
+	db.FlushAll()
+	defer func() {
+		b.StopTimer()
+		db.FlushAll()
+	}()
+
+	for i := 0; i < 100000; i++ {
+		// one in 100 keys is matched
+		k := fmt.Sprintf("other:%06d", i)
+		if i%100 == 0 {
+			k = fmt.Sprintf("user:%06d", i)
+		}
+		db.Set([]byte(k), []byte("v"))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if v, err := db.Scan(KV, nil, 1000, true, match); err != nil {
+			b.Fatal(err)
+		} else if len(v) != 1000 {
+			b.Fatal(len(v))
+		}
+	}
+}
+
+func BenchmarkScanMatchPrefix(b *testing.B) {
+	benchmarkScanMatch(b, "^user:")
+}
+
+func BenchmarkScanMatchNoPrefix(b *testing.B) {
+	benchmarkScanMatch(b, "user:")
+}
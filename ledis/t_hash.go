@@ -1,8 +1,10 @@
 package ledis
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/siddontang/go/num"
@@ -17,6 +19,8 @@ type FVPair struct {
 
 var errHashKey = errors.New("invalid hash key")
 var errHSizeKey = errors.New("invalid hsize key")
+var errHZipKey = errors.New("invalid hzip key")
+var errHZipValue = errors.New("invalid hzip value")
 
 const (
 	hashStartSep byte = ':'
@@ -130,38 +134,303 @@ func (db *DB) hEncodeStopKey(key []byte) []byte {
 	return k
 }
 
-func (db *DB) hSetItem(key []byte, field []byte, value []byte) (int64, error) {
-	t := db.hashBatch
+func (db *DB) hEncodeZipKey(key []byte) []byte {
+	buf := make([]byte, len(key)+1+len(db.indexVarBuf))
 
-	ek := db.hEncodeHashKey(key, field)
+	pos := copy(buf, db.indexVarBuf)
+	buf[pos] = HZipType
 
-	var n int64 = 1
-	if v, _ := db.bucket.Get(ek); v != nil {
-		n = 0
-	} else {
-		if _, err := db.hIncrSize(key, 1); err != nil {
-			return 0, err
+	pos++
+	copy(buf[pos:], key)
+
+	return buf
+}
+
+func (db *DB) hDecodeZipKey(ek []byte) ([]byte, error) {
+	pos, err := db.checkKeyIndex(ek)
+	if err != nil {
+		return nil, err
+	}
+
+	if pos+1 > len(ek) || ek[pos] != HZipType {
+		return nil, errHZipKey
+	}
+	pos++
+
+	return ek[pos:], nil
+}
+
+// encodeZiplist encodes the pairs sorted by field, each one is the uvarint
+// length and the bytes of the field, then of the value.
+func encodeZiplist(pairs []FVPair) []byte {
+	n := 0
+	for _, p := range pairs {
+		n += 2*binary.MaxVarintLen32 + len(p.Field) + len(p.Value)
+	}
+
+	buf := make([]byte, 0, n)
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, p := range pairs {
+		buf = append(buf, lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(p.Field)))]...)
+		buf = append(buf, p.Field...)
+		buf = append(buf, lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(p.Value)))]...)
+		buf = append(buf, p.Value...)
+	}
+	return buf
+}
+
+func decodeZiplist(buf []byte) ([]FVPair, error) {
+	var pairs []FVPair
+	for len(buf) > 0 {
+		var p FVPair
+		var err error
+		if p.Field, buf, err = decodeZiplistEntry(buf); err != nil {
+			return nil, err
+		} else if p.Value, buf, err = decodeZiplistEntry(buf); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, nil
+}
+
+func decodeZiplistEntry(buf []byte) ([]byte, []byte, error) {
+	n, pos := binary.Uvarint(buf)
+	if pos <= 0 || uint64(len(buf)-pos) < n {
+		return nil, nil, errHZipValue
+	}
+
+	end := pos + int(n)
+	return buf[pos:end:end], buf[end:], nil
+}
+
+// ziplistSearch returns the index of field in the sorted pairs, or where it
+// is inserted if it is not found.
+func ziplistSearch(pairs []FVPair, field []byte) (int, bool) {
+	i := sort.Search(len(pairs), func(i int) bool {
+		return bytes.Compare(pairs[i].Field, field) >= 0
+	})
+	return i, i < len(pairs) && bytes.Equal(pairs[i].Field, field)
+}
+
+// hGetZiplist returns the pairs of key if it is in the ziplist encoding, ok
+// is false if it is in the hashtable encoding or does not exist.
+func (db *DB) hGetZiplist(key []byte) (pairs []FVPair, ok bool, err error) {
+	v, err := db.bucket.Get(db.hEncodeZipKey(key))
+	if err != nil || v == nil {
+		return nil, false, err
+	}
+
+	if pairs, err = decodeZiplist(v); err != nil {
+		return nil, false, err
+	}
+	return pairs, true, nil
+}
+
+// hashZiplist reports whether the small hashes are written in the ziplist
+// encoding.
+func (db *DB) hashZiplist() bool {
+	var on bool
+	db.l.cfg.View(func() {
+		on = db.l.cfg.HashZiplist
+	})
+	return on
+}
+
+// hFitsZiplist reports whether the pairs are few and small enough to be kept
+// in the ziplist encoding, never without hash_ziplist.
+func (db *DB) hFitsZiplist(pairs []FVPair) bool {
+	if !db.hashZiplist() {
+		return false
+	}
+
+	maxEntries, maxValue := db.ziplistLimits()
+	if int64(len(pairs)) > maxEntries {
+		return false
+	}
+
+	for _, p := range pairs {
+		if len(p.Field) > maxValue || len(p.Value) > maxValue {
+			return false
+		}
+	}
+	return true
+}
+
+// hGetValue returns the value of the field in either encoding, without
+// checking the ttl.
+func (db *DB) hGetValue(key []byte, field []byte) ([]byte, error) {
+	pairs, zip, err := db.hGetZiplist(key)
+	if err != nil {
+		return nil, err
+	} else if zip {
+		if i, ok := ziplistSearch(pairs, field); ok {
+			return pairs[i].Value, nil
+		}
+		return nil, nil
+	}
+
+	return db.bucket.Get(db.hEncodeHashKey(key, field))
+}
+
+// hWrite sets the pairs and deletes the fields of key in t, it returns how
+// many fields are added and deleted.
+//
+// With hash_ziplist a new hash is in the ziplist encoding, all the pairs in
+// one value. It is converted to the hashtable encoding, a store key for each field, once it
+// has more than ziplist_max_entries fields or a field or value longer than
+// ziplist_max_value_size, and back if it fits again after a delete drops
+// the fields below ziplist_max_entries. The old encoding is deleted before
+// the new one is written, both in t, so the conversion is atomic and the
+// last notification of the batch is the one of the final state. Without
+// hash_ziplist nothing fits, a hash in the ziplist encoding is converted by
+// its next write.
+func (db *DB) hWrite(t *batch, key []byte, pairs []FVPair, fields [][]byte) (int64, int64, error) {
+	zl, zip, err := db.hGetZiplist(key)
+	if err != nil {
+		return 0, 0, err
+	} else if zip {
+		return db.hWriteZiplist(t, key, zl, true, pairs, fields)
+	}
+
+	if size, err := Int64(db.bucket.Get(db.hEncodeSizeKey(key))); err != nil {
+		return 0, 0, err
+	} else if size == 0 {
+		return db.hWriteZiplist(t, key, nil, false, pairs, fields)
+	}
+	return db.hWriteHashtable(t, key, pairs, fields)
+}
+
+func (db *DB) hWriteZiplist(t *batch, key []byte, zl []FVPair, exists bool, pairs []FVPair, fields [][]byte) (int64, int64, error) {
+	var added, deleted int64
+	for _, p := range pairs {
+		if i, ok := ziplistSearch(zl, p.Field); ok {
+			zl[i].Value = p.Value
+		} else {
+			zl = append(zl, FVPair{})
+			copy(zl[i+1:], zl[i:])
+			zl[i] = p
+			added++
+		}
+	}
+
+	for _, field := range fields {
+		if i, ok := ziplistSearch(zl, field); ok {
+			zl = append(zl[:i], zl[i+1:]...)
+			deleted++
+		}
+	}
+
+	if len(pairs) == 0 && deleted == 0 {
+		return 0, 0, nil
+	}
+
+	zk := db.hEncodeZipKey(key)
+	switch {
+	case len(zl) == 0:
+		t.Delete(zk)
+	case db.hFitsZiplist(zl):
+		t.Put(zk, encodeZiplist(zl))
+	default:
+		if exists {
+			t.Delete(zk)
+		}
+		for _, p := range zl {
+			t.Put(db.hEncodeHashKey(key, p.Field), p.Value)
 		}
 	}
 
-	t.Put(ek, value)
-	return n, nil
+	_, err := db.hIncrSize(key, added-deleted)
+	return added, deleted, err
+}
+
+func (db *DB) hWriteHashtable(t *batch, key []byte, pairs []FVPair, fields [][]byte) (int64, int64, error) {
+	it := db.bucket.NewIterator()
+	defer it.Close()
+
+	var added, deleted int64
+	for _, p := range pairs {
+		ek := db.hEncodeHashKey(key, p.Field)
+		if v := it.RawFind(ek); v == nil {
+			added++
+		}
+		t.Put(ek, p.Value)
+	}
+
+	for _, field := range fields {
+		ek := db.hEncodeHashKey(key, field)
+		if v := it.RawFind(ek); v != nil {
+			deleted++
+			t.Delete(ek)
+		}
+	}
+
+	size, err := db.hIncrSize(key, added-deleted)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if maxEntries, _ := db.ziplistLimits(); deleted > 0 && size > 0 && size < maxEntries && db.hashZiplist() {
+		err = db.hToZiplist(t, key, fields)
+	}
+	return added, deleted, err
+}
+
+// hToZiplist converts key back to the ziplist encoding if it fits without
+// the deleted fields, which are already deleted in t.
+func (db *DB) hToZiplist(t *batch, key []byte, deleted [][]byte) error {
+	skip := make(map[string]struct{}, len(deleted))
+	for _, field := range deleted {
+		skip[string(field)] = struct{}{}
+	}
+
+	it := db.bucket.RangeLimitIterator(db.hEncodeStartKey(key), db.hEncodeStopKey(key), store.RangeROpen, 0, -1)
+	defer it.Close()
+
+	var zl []FVPair
+	for ; it.Valid(); it.Next() {
+		_, field, err := db.hDecodeHashKey(it.Key())
+		if err != nil {
+			return err
+		} else if _, ok := skip[string(field)]; ok {
+			continue
+		}
+
+		zl = append(zl, FVPair{Field: field, Value: it.Value()})
+	}
+
+	if !db.hFitsZiplist(zl) {
+		return nil
+	}
+
+	for _, p := range zl {
+		t.Delete(db.hEncodeHashKey(key, p.Field))
+	}
+	t.Put(db.hEncodeZipKey(key), encodeZiplist(zl))
+	return nil
 }
 
 //	ps : here just focus on deleting the hash data,
 //		 any other likes expire is ignore.
 func (db *DB) hDelete(t *batch, key []byte) int64 {
 	sk := db.hEncodeSizeKey(key)
-	start := db.hEncodeStartKey(key)
-	stop := db.hEncodeStopKey(key)
+	zk := db.hEncodeZipKey(key)
 
 	var num int64
-	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
-	for ; it.Valid(); it.Next() {
-		t.Delete(it.Key())
-		num++
+	if v, _ := db.bucket.Get(zk); v != nil {
+		num, _ = Int64(db.bucket.Get(sk))
+		t.Delete(zk)
+	} else {
+		start := db.hEncodeStartKey(key)
+		stop := db.hEncodeStopKey(key)
+
+		it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
+		for ; it.Valid(); it.Next() {
+			t.Delete(it.Key())
+			num++
+		}
+		it.Close()
 	}
-	it.Close()
 
 	t.Delete(sk)
 	return num
@@ -190,6 +459,10 @@ func (db *DB) HLen(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if db.isExpired(HashType, key) {
+		return 0, nil
+	}
+
 	return Int64(db.bucket.Get(db.hEncodeSizeKey(key)))
 }
 
@@ -205,7 +478,11 @@ func (db *DB) HSet(key []byte, field []byte, value []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
-	n, err := db.hSetItem(key, field, value)
+	if err := db.expireOnWrite(t, HashType, key); err != nil {
+		return 0, err
+	}
+
+	n, _, err := db.hWrite(t, key, []FVPair{{field, value}}, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -220,7 +497,11 @@ func (db *DB) HGet(key []byte, field []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return db.bucket.Get(db.hEncodeHashKey(key, field))
+	if db.isExpired(HashType, key) {
+		return nil, nil
+	}
+
+	return db.hGetValue(key, field)
 }
 
 // HMset sets multi field-values.
@@ -229,34 +510,24 @@ func (db *DB) HMset(key []byte, args ...FVPair) error {
 	t.Lock()
 	defer t.Unlock()
 
-	var err error
-	var ek []byte
-	var num int64
+	if err := db.expireOnWrite(t, HashType, key); err != nil {
+		return err
+	}
+
 	for i := 0; i < len(args); i++ {
 		if err := checkHashKFSize(key, args[i].Field); err != nil {
 			return err
 		} else if err := checkValueSize(args[i].Value); err != nil {
 			return err
 		}
-
-		ek = db.hEncodeHashKey(key, args[i].Field)
-
-		if v, err := db.bucket.Get(ek); err != nil {
-			return err
-		} else if v == nil {
-			num++
-		}
-
-		t.Put(ek, args[i].Value)
 	}
 
-	if _, err = db.hIncrSize(key, num); err != nil {
+	if _, _, err := db.hWrite(t, key, args, nil); err != nil {
 		return err
 	}
 
 	//todo add binglog
-	err = t.Commit()
-	return err
+	return t.Commit()
 }
 
 // HMget gets multi values of fields
@@ -267,11 +538,27 @@ func (db *DB) HMget(key []byte, args ...[]byte) ([][]byte, error) {
 	defer it.Close()
 
 	r := make([][]byte, len(args))
+	if db.isExpired(HashType, key) {
+		return r, nil
+	}
+
+	pairs, zip, err := db.hGetZiplist(key)
+	if err != nil {
+		return nil, err
+	}
+
 	for i := 0; i < len(args); i++ {
 		if err := checkHashKFSize(key, args[i]); err != nil {
 			return nil, err
 		}
 
+		if zip {
+			if j, ok := ziplistSearch(pairs, args[i]); ok {
+				r[i] = pairs[j].Value
+			}
+			continue
+		}
+
 		ek = db.hEncodeHashKey(key, args[i])
 
 		r[i] = it.Find(ek)
@@ -284,34 +571,21 @@ func (db *DB) HMget(key []byte, args ...[]byte) ([][]byte, error) {
 func (db *DB) HDel(key []byte, args ...[]byte) (int64, error) {
 	t := db.hashBatch
 
-	var ek []byte
-	var v []byte
-	var err error
-
 	t.Lock()
 	defer t.Unlock()
 
-	it := db.bucket.NewIterator()
-	defer it.Close()
+	if err := db.expireOnWrite(t, HashType, key); err != nil {
+		return 0, err
+	}
 
-	var num int64
 	for i := 0; i < len(args); i++ {
 		if err := checkHashKFSize(key, args[i]); err != nil {
 			return 0, err
 		}
-
-		ek = db.hEncodeHashKey(key, args[i])
-
-		v = it.RawFind(ek)
-		if v == nil {
-			continue
-		} else {
-			num++
-			t.Delete(ek)
-		}
 	}
 
-	if _, err = db.hIncrSize(key, -num); err != nil {
+	_, num, err := db.hWrite(t, key, nil, args)
+	if err != nil {
 		return 0, err
 	}
 
@@ -349,22 +623,23 @@ func (db *DB) HIncrBy(key []byte, field []byte, delta int64) (int64, error) {
 	}
 
 	t := db.hashBatch
-	var ek []byte
 	var err error
 
 	t.Lock()
 	defer t.Unlock()
 
-	ek = db.hEncodeHashKey(key, field)
+	if err := db.expireOnWrite(t, HashType, key); err != nil {
+		return 0, err
+	}
 
 	var n int64
-	if n, err = StrInt64(db.bucket.Get(ek)); err != nil {
+	if n, err = StrInt64(db.hGetValue(key, field)); err != nil {
 		return 0, err
 	}
 
 	n += delta
 
-	_, err = db.hSetItem(key, field, num.FormatInt64ToSlice(n))
+	_, _, err = db.hWrite(t, key, []FVPair{{field, num.FormatInt64ToSlice(n)}}, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -384,6 +659,15 @@ func (db *DB) HGetAll(key []byte) ([]FVPair, error) {
 	stop := db.hEncodeStopKey(key)
 
 	v := make([]FVPair, 0, 16)
+	if db.isExpired(HashType, key) {
+		return v, nil
+	}
+
+	if pairs, zip, err := db.hGetZiplist(key); err != nil {
+		return nil, err
+	} else if zip {
+		return pairs, nil
+	}
 
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
@@ -400,6 +684,43 @@ func (db *DB) HGetAll(key []byte) ([]FVPair, error) {
 	return v, nil
 }
 
+// HRandField returns count random fields with their values. If count > 0,
+// the fields are distinct, otherwise -count fields are returned with
+// repeats allowed.
+func (db *DB) HRandField(key []byte, count int) ([]FVPair, error) {
+	size, err := db.HLen(key)
+	if err != nil {
+		return nil, err
+	} else if size == 0 || count == 0 {
+		return []FVPair{}, nil
+	}
+
+	if pairs, zip, err := db.hGetZiplist(key); err != nil {
+		return nil, err
+	} else if zip {
+		indexes := sampleIndexes(len(pairs), count)
+		v := make([]FVPair, len(indexes))
+		for i, j := range indexes {
+			v[i] = pairs[j]
+		}
+		return v, nil
+	}
+
+	it := db.bucket.RangeLimitIterator(db.hEncodeStartKey(key), db.hEncodeStopKey(key), store.RangeROpen, 0, -1)
+	entries := sampleRange(it, count, size)
+
+	v := make([]FVPair, len(entries))
+	for i, e := range entries {
+		_, f, err := db.hDecodeHashKey(e.key)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = FVPair{Field: f, Value: e.value}
+	}
+
+	return v, nil
+}
+
 // HKeys returns the all fields.
 func (db *DB) HKeys(key []byte) ([][]byte, error) {
 	if err := checkKeySize(key); err != nil {
@@ -410,6 +731,18 @@ func (db *DB) HKeys(key []byte) ([][]byte, error) {
 	stop := db.hEncodeStopKey(key)
 
 	v := make([][]byte, 0, 16)
+	if db.isExpired(HashType, key) {
+		return v, nil
+	}
+
+	if pairs, zip, err := db.hGetZiplist(key); err != nil {
+		return nil, err
+	} else if zip {
+		for _, p := range pairs {
+			v = append(v, p.Field)
+		}
+		return v, nil
+	}
 
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
@@ -435,6 +768,18 @@ func (db *DB) HValues(key []byte) ([][]byte, error) {
 	stop := db.hEncodeStopKey(key)
 
 	v := make([][]byte, 0, 16)
+	if db.isExpired(HashType, key) {
+		return v, nil
+	}
+
+	if pairs, zip, err := db.hGetZiplist(key); err != nil {
+		return nil, err
+	} else if zip {
+		for _, p := range pairs {
+			v = append(v, p.Value)
+		}
+		return v, nil
+	}
 
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
@@ -461,6 +806,10 @@ func (db *DB) HClear(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, HashType, key); err != nil {
+		return 0, err
+	}
+
 	num := db.hDelete(t, key)
 	db.rmExpire(t, HashType, key)
 
@@ -474,6 +823,10 @@ func (db *DB) HMclear(keys ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, HashType, keys...); err != nil {
+		return 0, err
+	}
+
 	for _, key := range keys {
 		if err := checkKeySize(key); err != nil {
 			return 0, err
@@ -502,7 +855,7 @@ func (db *DB) HExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.hExpireAt(key, time.Now().Unix()+duration)
+	return db.hExpireAt(key, nowMs()+duration*1000)
 }
 
 // HExpireAt expires the data at time when.
@@ -511,7 +864,7 @@ func (db *DB) HExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.hExpireAt(key, when)
+	return db.hExpireAt(key, when*1000)
 }
 
 // HTTL gets the TTL of data.
@@ -523,6 +876,44 @@ func (db *DB) HTTL(key []byte) (int64, error) {
 	return db.ttl(HashType, key)
 }
 
+// HPExpire expires the data with duration in milliseconds.
+func (db *DB) HPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.hExpireAt(key, nowMs()+duration)
+}
+
+// HPExpireAt expires the data at when in milliseconds.
+func (db *DB) HPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMs() {
+		return 0, errExpireValue
+	}
+
+	return db.hExpireAt(key, when)
+}
+
+// HExpireWithJitter expires the hash with ttl lengthened by a random
+// fraction in [0, jitter) of itself, the resolved expire time is saved.
+func (db *DB) HExpireWithJitter(key []byte, ttl time.Duration, jitter float64) (int64, error) {
+	duration, err := jitterDuration(ttl, jitter)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.hExpireAt(key, nowMs()+duration)
+}
+
+// HPTTL gets the TTL of the data in milliseconds.
+func (db *DB) HPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(HashType, key)
+}
+
 // HPersist removes the TTL of data.
 func (db *DB) HPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -533,6 +924,10 @@ func (db *DB) HPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, HashType, key); err != nil {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, HashType, key)
 	if err != nil {
 		return 0, err
@@ -547,6 +942,9 @@ func (db *DB) HKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+	if db.isExpired(HashType, key) {
+		return 0, nil
+	}
 	sk := db.hEncodeSizeKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
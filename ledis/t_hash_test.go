@@ -1,8 +1,11 @@
 package ledis
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
+
+	"github.com/siddontang/ledisdb/store"
 )
 
 func TestHashCodec(t *testing.T) {
@@ -154,3 +157,359 @@ func TestHFlush(t *testing.T) {
 	}
 
 }
+
+func TestHRandField(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_hrandfield")
+	db.HClear(key)
+
+	if v, err := db.HRandField(key, 1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatal(v)
+	}
+
+	for i := 0; i < 10; i++ {
+		db.HSet(key, []byte(fmt.Sprintf("f%d", i)), []byte(fmt.Sprintf("v%d", i)))
+	}
+
+	if v, err := db.HRandField(key, 20); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 10 {
+		t.Fatal(len(v))
+	}
+
+	if v, err := db.HRandField(key, 5); err != nil {
+		t.Fatal(err)
+	} else {
+		seen := make(map[string]bool)
+		for _, p := range v {
+			if seen[string(p.Field)] {
+				t.Fatal("duplicated field", string(p.Field))
+			} else if string(p.Value) != "v"+string(p.Field[1:]) {
+				t.Fatal(string(p.Field), string(p.Value))
+			}
+			seen[string(p.Field)] = true
+		}
+		if len(seen) != 5 {
+			t.Fatal(len(seen))
+		}
+	}
+
+	// the 10000 samples must be uniform, each field is expected 1000 times
+	// with a standard deviation under 30, so 800..1200 never fails in practice.
+	checkUniform := func(counts map[string]int) {
+		if len(counts) != 10 {
+			t.Fatal(counts)
+		}
+		for f, n := range counts {
+			if n < 800 || n > 1200 {
+				t.Fatal(f, n, counts)
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		v, err := db.HRandField(key, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range v {
+			counts[string(p.Field)]++
+		}
+	}
+	checkUniform(counts)
+
+	v, err := db.HRandField(key, -10000)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(v) != 10000 {
+		t.Fatal(len(v))
+	}
+
+	counts = make(map[string]int)
+	for _, p := range v {
+		counts[string(p.Field)]++
+	}
+	checkUniform(counts)
+}
+
+func TestHashZiplist(t *testing.T) {
+	db := getTestDB()
+
+	cfg := db.l.cfg
+	var entries, size int
+	cfg.Update(func() {
+		entries, size = cfg.ZiplistMaxEntries, cfg.ZiplistMaxValueSize
+		cfg.ZiplistMaxEntries, cfg.ZiplistMaxValueSize = 3, 4
+	})
+	defer cfg.Update(func() {
+		cfg.ZiplistMaxEntries, cfg.ZiplistMaxValueSize = entries, size
+	})
+	cfg.Update(func() { cfg.HashZiplist = true })
+	defer cfg.Update(func() { cfg.HashZiplist = false })
+
+	key := []byte("hash_ziplist")
+	db.HClear(key)
+	defer db.HClear(key)
+
+	isZiplist := func() bool {
+		v, err := db.bucket.Get(db.hEncodeZipKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v != nil
+	}
+
+	checkAll := func(expected string) {
+		v, err := db.HGetAll(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := ""
+		for _, p := range v {
+			s += string(p.Field) + "=" + string(p.Value) + " "
+		}
+		if s != expected {
+			t.Fatalf("%q != %q", s, expected)
+		}
+
+		if n, _ := db.HLen(key); n != int64(len(v)) {
+			t.Fatal(n, len(v))
+		}
+	}
+
+	db.HSet(key, []byte("c"), []byte("3"))
+	db.HMset(key, FVPair{[]byte("a"), []byte("1")}, FVPair{[]byte("b"), []byte("2")})
+	if !isZiplist() {
+		t.Fatal("must be ziplist")
+	}
+	checkAll("a=1 b=2 c=3 ")
+
+	if v, _ := db.HGet(key, []byte("b")); string(v) != "2" {
+		t.Fatal(string(v))
+	} else if v, _ := db.HMget(key, []byte("c"), []byte("d")); string(v[0]) != "3" || v[1] != nil {
+		t.Fatal(v)
+	} else if n, _ := db.HIncrBy(key, []byte("a"), 10); n != 11 {
+		t.Fatal(n)
+	}
+
+	// too many fields
+	db.HSet(key, []byte("d"), []byte("4"))
+	if isZiplist() {
+		t.Fatal("must be hashtable")
+	} else if v, _ := db.bucket.Get(db.hEncodeHashKey(key, []byte("d"))); string(v) != "4" {
+		t.Fatal(string(v))
+	}
+	checkAll("a=11 b=2 c=3 d=4 ")
+
+	// below the limit again
+	if n, _ := db.HDel(key, []byte("d"), []byte("c"), []byte("x")); n != 2 {
+		t.Fatal(n)
+	} else if !isZiplist() {
+		t.Fatal("must be ziplist")
+	} else if v, _ := db.bucket.Get(db.hEncodeHashKey(key, []byte("a"))); v != nil {
+		t.Fatal("hashtable field not deleted")
+	}
+	checkAll("a=11 b=2 ")
+
+	// too long value
+	db.HSet(key, []byte("b"), []byte("12345"))
+	if isZiplist() {
+		t.Fatal("must be hashtable")
+	}
+	checkAll("a=11 b=12345 ")
+
+	// the long value is still there
+	db.HDel(key, []byte("a"))
+	if isZiplist() {
+		t.Fatal("must be hashtable")
+	}
+
+	db.HSet(key, []byte("b"), []byte("2"))
+	db.HSet(key, []byte("a"), []byte("1"))
+	db.HDel(key, []byte("a"))
+	if !isZiplist() {
+		t.Fatal("must be ziplist")
+	}
+
+	if n, _ := db.HDel(key, []byte("b")); n != 1 {
+		t.Fatal(n)
+	} else if isZiplist() {
+		t.Fatal("ziplist not deleted")
+	} else if n, _ := db.HKeyExists(key); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestHashZiplistScan(t *testing.T) {
+	db := getTestDB()
+
+	cfg := db.l.cfg
+	var entries int
+	cfg.View(func() { entries = cfg.ZiplistMaxEntries })
+	defer cfg.Update(func() { cfg.ZiplistMaxEntries = entries })
+	cfg.Update(func() { cfg.HashZiplist = true })
+	defer cfg.Update(func() { cfg.HashZiplist = false })
+
+	zipKey := []byte("hash_ziplist_scan_zip")
+	tableKey := []byte("hash_ziplist_scan_table")
+	defer db.HMclear(zipKey, tableKey)
+
+	var pairs []FVPair
+	for i := 0; i < 10; i++ {
+		pairs = append(pairs, FVPair{[]byte(fmt.Sprintf("f%d", i)), []byte("v")})
+	}
+
+	cfg.Update(func() { cfg.ZiplistMaxEntries = 10 })
+	db.HMset(zipKey, pairs...)
+	cfg.Update(func() { cfg.ZiplistMaxEntries = 0 })
+	db.HMset(tableKey, pairs...)
+
+	if v, _ := db.ObjectEncoding(zipKey); v != "listpack" {
+		t.Fatal(v)
+	} else if v, _ := db.ObjectEncoding(tableKey); v != "hashtable" {
+		t.Fatal(v)
+	}
+
+	for _, test := range []struct {
+		cursor    string
+		inclusive bool
+		match     string
+		reverse   bool
+	}{
+		{"", false, "", false},
+		{"f3", false, "", false},
+		{"f3", true, "", false},
+		{"f3", false, "", true},
+		{"f3", true, "", true},
+		{"", false, "f[2-5]", true},
+	} {
+		scan := db.HScan
+		if test.reverse {
+			scan = db.HRevScan
+		}
+
+		zv, err := scan(zipKey, []byte(test.cursor), 4, test.inclusive, test.match)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tv, err := scan(tableKey, []byte(test.cursor), 4, test.inclusive, test.match)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fmt.Sprint(zv) != fmt.Sprint(tv) || len(zv) == 0 {
+			t.Fatal(test, zv, tv)
+		}
+	}
+}
+
+// storeSize returns the bytes of all the store keys and values of db.
+func storeSize(db *DB) int64 {
+	min := append(append([]byte(nil), db.indexVarBuf...), NoneType)
+	max := append(append([]byte(nil), db.indexVarBuf...), maxDataType)
+
+	it := db.bucket.RangeLimitIterator(min, max, store.RangeOpen, 0, -1)
+	defer it.Close()
+
+	var n int64
+	for ; it.Valid(); it.Next() {
+		n += int64(len(it.RawKey()) + len(it.RawValue()))
+	}
+	return n
+}
+
+func TestHashZiplistOff(t *testing.T) {
+	db := getTestDB()
+	cfg := db.l.cfg
+
+	key := []byte("hash_ziplist_off")
+	db.HClear(key)
+	defer db.HClear(key)
+
+	isZiplist := func() bool {
+		v, err := db.bucket.Get(db.hEncodeZipKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v != nil
+	}
+
+	// off by default
+	if db.HSet(key, []byte("a"), []byte("1")); isZiplist() {
+		t.Fatal("must not be ziplist")
+	}
+	db.HClear(key)
+
+	cfg.Update(func() { cfg.HashZiplist = true })
+	db.HSet(key, []byte("a"), []byte("1"))
+	cfg.Update(func() { cfg.HashZiplist = false })
+	if !isZiplist() {
+		t.Fatal("must be ziplist")
+	}
+
+	// read in the ziplist, converted by the write
+	if v, err := db.HGet(key, []byte("a")); err != nil || string(v) != "1" {
+		t.Fatal(string(v), err)
+	} else if _, err = db.HSet(key, []byte("b"), []byte("2")); err != nil {
+		t.Fatal(err)
+	} else if isZiplist() {
+		t.Fatal("ziplist not converted")
+	}
+
+	if n, err := db.HLen(key); err != nil || n != 2 {
+		t.Fatal(n, err)
+	} else if v, err := db.HGet(key, []byte("a")); err != nil || string(v) != "1" {
+		t.Fatal(string(v), err)
+	} else if v, _ := db.ObjectEncoding(key); v != "listpack" {
+		t.Fatal(v)
+	}
+}
+
+// benchmarkSmallHash writes the hashes of 32 fields with 32 bytes values and
+// reports the store bytes of a hash.
+func benchmarkSmallHash(b *testing.B, maxEntries int) {
+	db := getTestDB()
+	db, _ = db.l.Select(200)
+	db.FlushAll()
+	defer db.FlushAll()
+
+	cfg := db.l.cfg
+	var entries int
+	cfg.Update(func() {
+		entries = cfg.ZiplistMaxEntries
+		cfg.ZiplistMaxEntries = maxEntries
+	})
+	defer cfg.Update(func() { cfg.ZiplistMaxEntries = entries })
+	cfg.Update(func() { cfg.HashZiplist = true })
+	defer cfg.Update(func() { cfg.HashZiplist = false })
+
+	pairs := make([]FVPair, 32)
+	for i := range pairs {
+		pairs[i] = FVPair{[]byte(fmt.Sprintf("field_%d", i)), bytes.Repeat([]byte{'v'}, 32)}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.HMset([]byte(fmt.Sprintf("bench_hash_%d", i)), pairs...); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(storeSize(db))/float64(b.N), "store-bytes/hash")
+}
+
+func BenchmarkSmallHashZiplist(b *testing.B) {
+	benchmarkSmallHash(b, 128)
+}
+
+// BenchmarkSmallHashHashtable is BenchmarkSmallHashZiplist in the hashtable
+// encoding, to compare the store bytes.
+func BenchmarkSmallHashHashtable(b *testing.B) {
+	benchmarkSmallHash(b, 0)
+}
@@ -0,0 +1,489 @@
+package ledis
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"time"
+)
+
+/*
+	The HyperLogLog uses the same dense representation as Redis,
+	16384 registers of 6 bits, 12288 bytes saved under key:
+
+	db index + HLLType + key -> registers
+*/
+
+const (
+	hllP         = 14
+	hllQ         = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllBits      = 6
+	hllRegMax    = 1<<hllBits - 1
+	hllSize      = (hllRegisters*hllBits + 7) / 8
+
+	hllAlphaInf = 0.721347520444481703680
+	hllSeed     = 0xadc83b19
+)
+
+var (
+	errHLLKey   = errors.New("invalid hyperloglog key")
+	errHLLValue = errors.New("invalid hyperloglog value")
+)
+
+type hllRegs []byte
+
+func (r hllRegs) get(index int) uint8 {
+	pos := index * hllBits / 8
+	fb := uint(index*hllBits) & 7
+
+	v := r[pos] >> fb
+	if pos+1 < len(r) {
+		v |= r[pos+1] << (8 - fb)
+	}
+	return v & hllRegMax
+}
+
+func (r hllRegs) set(index int, v uint8) {
+	pos := index * hllBits / 8
+	fb := uint(index*hllBits) & 7
+
+	r[pos] &^= hllRegMax << fb
+	r[pos] |= v << fb
+	if pos+1 < len(r) {
+		r[pos+1] &^= hllRegMax >> (8 - fb)
+		r[pos+1] |= v >> (8 - fb)
+	}
+}
+
+// add adds the element and reports whether any register is changed.
+func (r hllRegs) add(element []byte) bool {
+	hash := murmurHash64A(element, hllSeed)
+
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	hash |= 1 << hllQ
+	count := uint8(bits.TrailingZeros64(hash) + 1)
+
+	if count > r.get(index) {
+		r.set(index, count)
+		return true
+	}
+	return false
+}
+
+// merge sets every register to the max of r and o.
+func (r hllRegs) merge(o hllRegs) {
+	for i := 0; i < hllRegisters; i++ {
+		if v := o.get(i); v > r.get(i) {
+			r.set(i, v)
+		}
+	}
+}
+
+// count estimates the cardinality with the improved estimator of
+// Otmar Ertl, like Redis does.
+func (r hllRegs) count() int64 {
+	var histo [64]int
+	for i := 0; i < hllRegisters; i++ {
+		histo[r.get(i)]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+
+	return int64(math.Floor(hllAlphaInf*m*m/z + 0.5))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ (uint64(len(data)) * m)
+
+	for ; len(data) >= 8; data = data[8:] {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+
+		h ^= k
+		h *= m
+	}
+
+	if n := len(data); n > 0 {
+		for i := n - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * uint(i))
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+
+	return h
+}
+
+func checkHLLKeys(keys ...[]byte) error {
+	for _, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) hllEncodeKey(key []byte) []byte {
+	ek := make([]byte, len(key)+1+len(db.indexVarBuf))
+	pos := copy(ek, db.indexVarBuf)
+	ek[pos] = HLLType
+	pos++
+	copy(ek[pos:], key)
+	return ek
+}
+
+func (db *DB) hllDecodeKey(ek []byte) ([]byte, error) {
+	pos, err := db.checkKeyIndex(ek)
+	if err != nil {
+		return nil, err
+	}
+	if pos+1 > len(ek) || ek[pos] != HLLType {
+		return nil, errHLLKey
+	}
+
+	pos++
+
+	return ek[pos:], nil
+}
+
+// hllGet returns the registers of the key, nil if the key does not exist.
+func (db *DB) hllGet(key []byte) (hllRegs, error) {
+	if db.isExpired(HLLType, key) {
+		return nil, nil
+	}
+
+	v, err := db.bucket.Get(db.hllEncodeKey(key))
+	if err != nil || v == nil {
+		return nil, err
+	} else if len(v) != hllSize {
+		return nil, errHLLValue
+	}
+
+	return hllRegs(v), nil
+}
+
+func (db *DB) hllDelete(t *batch, key []byte) int64 {
+	t.Delete(db.hllEncodeKey(key))
+	return 1
+}
+
+func (db *DB) hllSetExpireAt(key []byte, when int64) (int64, error) {
+	t := db.hllBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if exist, err := db.HLLKeyExists(key); err != nil || exist == 0 {
+		return 0, err
+	}
+
+	db.expireAt(t, HLLType, key, when)
+	if err := t.Commit(); err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// HLLAdd adds the elements to the HyperLogLog, it returns 1 if the
+// estimated cardinality is changed or the key is created, else 0.
+func (db *DB) HLLAdd(key []byte, elements ...[]byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	}
+
+	t := db.hllBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, HLLType, key); err != nil {
+		return 0, err
+	}
+
+	regs, err := db.hllGet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	if regs == nil {
+		regs = make(hllRegs, hllSize)
+		n = 1
+	}
+
+	for _, e := range elements {
+		if regs.add(e) {
+			n = 1
+		}
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	t.Put(db.hllEncodeKey(key), regs)
+	err = t.Commit()
+	return n, err
+}
+
+// HLLCount returns the estimated cardinality of the union of the keys.
+func (db *DB) HLLCount(keys ...[]byte) (int64, error) {
+	if err := checkHLLKeys(keys...); err != nil {
+		return 0, err
+	}
+
+	var union hllRegs
+	for _, key := range keys {
+		regs, err := db.hllGet(key)
+		if err != nil {
+			return 0, err
+		} else if regs == nil {
+			continue
+		}
+
+		if union == nil {
+			union = regs
+		} else {
+			union.merge(regs)
+		}
+	}
+
+	if union == nil {
+		return 0, nil
+	}
+
+	return union.count(), nil
+}
+
+// HLLMerge merges the source keys and the destination key into the
+// destination key.
+func (db *DB) HLLMerge(destKey []byte, srcKeys ...[]byte) error {
+	if err := checkHLLKeys(destKey); err != nil {
+		return err
+	} else if err := checkHLLKeys(srcKeys...); err != nil {
+		return err
+	}
+
+	t := db.hllBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, HLLType, destKey); err != nil {
+		return err
+	}
+
+	union, err := db.hllGet(destKey)
+	if err != nil {
+		return err
+	} else if union == nil {
+		union = make(hllRegs, hllSize)
+	}
+
+	for _, key := range srcKeys {
+		regs, err := db.hllGet(key)
+		if err != nil {
+			return err
+		} else if regs != nil {
+			union.merge(regs)
+		}
+	}
+
+	t.Put(db.hllEncodeKey(destKey), union)
+	return t.Commit()
+}
+
+// HLLDel deletes the HyperLogLogs.
+func (db *DB) HLLDel(keys ...[]byte) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	if err := checkHLLKeys(keys...); err != nil {
+		return 0, err
+	}
+
+	t := db.hllBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, HLLType, keys...); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for _, key := range keys {
+		if exist, err := db.HLLKeyExists(key); err != nil {
+			return 0, err
+		} else if exist == 0 {
+			continue
+		}
+
+		n += db.hllDelete(t, key)
+		db.rmExpire(t, HLLType, key)
+	}
+
+	err := t.Commit()
+	return n, err
+}
+
+func (db *DB) hllFlush() (drop int64, err error) {
+	t := db.hllBatch
+	t.Lock()
+	defer t.Unlock()
+	return db.flushType(t, HLLType)
+}
+
+// HLLExpire expires the HyperLogLog.
+func (db *DB) HLLExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.hllSetExpireAt(key, nowMs()+duration*1000)
+}
+
+// HLLExpireAt expires the HyperLogLog at when.
+func (db *DB) HLLExpireAt(key []byte, when int64) (int64, error) {
+	if when <= time.Now().Unix() {
+		return 0, errExpireValue
+	}
+
+	return db.hllSetExpireAt(key, when*1000)
+}
+
+// HLLTTL returns the TTL of the HyperLogLog.
+func (db *DB) HLLTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.ttl(HLLType, key)
+}
+
+// HLLPExpire expires the HyperLogLog with duration in milliseconds.
+func (db *DB) HLLPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.hllSetExpireAt(key, nowMs()+duration)
+}
+
+// HLLPExpireAt expires the HyperLogLog at when in milliseconds.
+func (db *DB) HLLPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMs() {
+		return 0, errExpireValue
+	}
+
+	return db.hllSetExpireAt(key, when)
+}
+
+// HLLExpireWithJitter expires the HyperLogLog with ttl lengthened by a
+// random fraction in [0, jitter) of itself, the resolved expire time is saved.
+func (db *DB) HLLExpireWithJitter(key []byte, ttl time.Duration, jitter float64) (int64, error) {
+	duration, err := jitterDuration(ttl, jitter)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.hllSetExpireAt(key, nowMs()+duration)
+}
+
+// HLLPTTL returns the TTL of the HyperLogLog in milliseconds.
+func (db *DB) HLLPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(HLLType, key)
+}
+
+// HLLPersist removes the TTL of the HyperLogLog.
+func (db *DB) HLLPersist(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	}
+
+	t := db.hllBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, HLLType, key); err != nil {
+		return 0, err
+	}
+
+	n, err := db.rmExpire(t, HLLType, key)
+	if err != nil {
+		return 0, err
+	}
+
+	err = t.Commit()
+	return n, err
+}
+
+// HLLKeyExists checks whether the HyperLogLog exists or not.
+func (db *DB) HLLKeyExists(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	}
+	if db.isExpired(HLLType, key) {
+		return 0, nil
+	}
+	v, err := db.bucket.Get(db.hllEncodeKey(key))
+	if v != nil && err == nil {
+		return 1, nil
+	}
+	return 0, err
+}
@@ -0,0 +1,104 @@
+package ledis
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHLLCodec(t *testing.T) {
+	db := getTestDB()
+
+	ek := db.hllEncodeKey([]byte("key"))
+	if k, err := db.hllDecodeKey(ek); err != nil {
+		t.Fatal(err)
+	} else if string(k) != "key" {
+		t.Fatal(string(k))
+	}
+}
+
+func TestHLLRegisters(t *testing.T) {
+	regs := make(hllRegs, hllSize)
+	for i := 0; i < hllRegisters; i++ {
+		regs.set(i, uint8(i%64))
+	}
+
+	for i := 0; i < hllRegisters; i++ {
+		if v := regs.get(i); v != uint8(i%64) {
+			t.Fatal(i, v)
+		}
+	}
+
+	// same hash as Redis
+	if h := murmurHash64A([]byte("hello"), hllSeed); h != 0xf656f01eecfe400 {
+		t.Fatalf("%x", h)
+	}
+
+	if h := murmurHash64A([]byte("hello world!"), hllSeed); h != 0xfc444011f57220c {
+		t.Fatalf("%x", h)
+	}
+}
+
+func TestDBHLL(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_hll_a")
+	key1 := []byte("testdb_hll_b")
+	key2 := []byte("testdb_hll_c")
+
+	if n, err := db.HLLCount(key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.HLLAdd(key, []byte("a"), []byte("b"), []byte("c")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.HLLAdd(key, []byte("a")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.HLLCount(key); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	for i := 0; i < 10000; i++ {
+		if _, err := db.HLLAdd(key1, []byte(fmt.Sprintf("e_%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the standard error is 0.81%
+	if n, err := db.HLLCount(key1); err != nil {
+		t.Fatal(err)
+	} else if n < 9700 || n > 10300 {
+		t.Fatal(n)
+	}
+
+	if err := db.HLLMerge(key2, key, key1); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := db.HLLCount(key2); err != nil {
+		t.Fatal(err)
+	} else if m, _ := db.HLLCount(key, key1); n != m {
+		t.Fatal(n, m)
+	}
+
+	if n, err := db.HLLDel(key, key1, key2, []byte("testdb_hll_d")); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	if n, _ := db.HLLKeyExists(key); n != 0 {
+		t.Fatal(n)
+	}
+}
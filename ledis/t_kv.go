@@ -1,6 +1,7 @@
 package ledis
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -73,16 +74,18 @@ func (db *DB) incr(key []byte, delta int64) (int64, error) {
 		return 0, err
 	}
 
-	var err error
-	key = db.encodeKVKey(key)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
-	var n int64
-	n, err = StrInt64(db.bucket.Get(key))
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	key = db.encodeKVKey(key)
+
+	n, err := StrInt64(db.bucket.Get(key))
 	if err != nil {
 		return 0, err
 	}
@@ -160,6 +163,10 @@ func (db *DB) Exists(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if db.isExpired(KVType, key) {
+		return 0, nil
+	}
+
 	var err error
 	key = db.encodeKVKey(key)
 
@@ -178,17 +185,180 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 		return nil, err
 	}
 
+	if db.isExpired(KVType, key) {
+		return nil, nil
+	}
+
 	key = db.encodeKVKey(key)
 
 	return db.bucket.Get(key)
 }
 
+// GetDel gets the value and deletes the key in one batch.
+func (db *DB) GetDel(key []byte) ([]byte, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+
+	t := db.kvBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if db.isExpired(KVType, key) {
+		return nil, nil
+	}
+
+	ek := db.encodeKVKey(key)
+	value, err := db.bucket.Get(ek)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	t.Delete(ek)
+	db.rmExpire(t, KVType, key)
+
+	err = t.Commit()
+	return value, err
+}
+
+// CAS sets the value of key to value only if the current value equals
+// expected, and reports whether it is set. A missing key never equals, the
+// ttl of the key is kept like Set.
+func (db *DB) CAS(key []byte, expected []byte, value []byte) (bool, error) {
+	if err := checkKeySize(key); err != nil {
+		return false, err
+	} else if err := checkValueSize(value); err != nil {
+		return false, err
+	}
+
+	t := db.kvBatch
+	t.Lock()
+	defer t.Unlock()
+
+	ek := db.encodeKVKey(key)
+	if ok, err := db.casMatch(key, ek, expected); err != nil || !ok {
+		return false, err
+	}
+
+	t.Put(ek, value)
+	err := t.Commit()
+	return err == nil, err
+}
+
+// CAD deletes key only if its value equals expected, and reports whether it
+// is deleted.
+func (db *DB) CAD(key []byte, expected []byte) (bool, error) {
+	if err := checkKeySize(key); err != nil {
+		return false, err
+	}
+
+	t := db.kvBatch
+	t.Lock()
+	defer t.Unlock()
+
+	ek := db.encodeKVKey(key)
+	if ok, err := db.casMatch(key, ek, expected); err != nil || !ok {
+		return false, err
+	}
+
+	t.Delete(ek)
+	db.rmExpire(t, KVType, key)
+	err := t.Commit()
+	return err == nil, err
+}
+
+// casMatch must be called with the kv batch locked.
+func (db *DB) casMatch(key []byte, ek []byte, expected []byte) (bool, error) {
+	if db.isExpired(KVType, key) {
+		return false, nil
+	}
+
+	v, err := db.bucket.Get(ek)
+	if err != nil || v == nil {
+		return false, err
+	}
+	return bytes.Equal(v, expected), nil
+}
+
+// GetExOptions are the options for GetEx, at most one can be set.
+type GetExOptions struct {
+	// TTL sets the timeout if greater than 0.
+	TTL time.Duration
+	// ExpireAt sets the expire time if not zero.
+	ExpireAt time.Time
+	// Persist removes the timeout.
+	Persist bool
+}
+
+var errGetExOptions = errors.New("only one of TTL, ExpireAt and Persist can be set")
+
+// GetEx gets the value and changes the TTL of the key by opts in one batch.
+func (db *DB) GetEx(key []byte, opts GetExOptions) ([]byte, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+
+	var when int64
+	set := 0
+	if opts.TTL != 0 {
+		if opts.TTL < 0 {
+			return nil, errExpireValue
+		}
+		when = nowMs() + int64(opts.TTL/time.Millisecond)
+		set++
+	}
+	if !opts.ExpireAt.IsZero() {
+		when = opts.ExpireAt.UnixNano() / int64(time.Millisecond)
+		if when <= nowMs() {
+			return nil, errExpireValue
+		}
+		set++
+	}
+	if opts.Persist {
+		set++
+	}
+
+	if set > 1 {
+		return nil, errGetExOptions
+	} else if set == 0 {
+		return db.Get(key)
+	}
+
+	t := db.kvBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if db.isExpired(KVType, key) {
+		return nil, nil
+	}
+
+	value, err := db.bucket.Get(db.encodeKVKey(key))
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	if opts.Persist {
+		if _, err = db.rmExpire(t, KVType, key); err != nil {
+			return nil, err
+		}
+	} else {
+		db.expireAt(t, KVType, key, when)
+	}
+
+	err = t.Commit()
+	return value, err
+}
+
 // GetSlice gets the slice of the data.
 func (db *DB) GetSlice(key []byte) (store.Slice, error) {
 	if err := checkKeySize(key); err != nil {
 		return nil, err
 	}
 
+	if db.isExpired(KVType, key) {
+		return nil, nil
+	}
+
 	key = db.encodeKVKey(key)
 
 	return db.bucket.GetSlice(key)
@@ -202,19 +372,21 @@ func (db *DB) GetSet(key []byte, value []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	key = db.encodeKVKey(key)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
-	oldValue, err := db.bucket.Get(key)
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return nil, err
+	}
+
+	oldValue, err := db.bucket.Get(db.encodeKVKey(key))
 	if err != nil {
 		return nil, err
 	}
 
-	t.Put(key, value)
+	t.Put(db.encodeKVKey(key), value)
 
 	err = t.Commit()
 
@@ -243,6 +415,10 @@ func (db *DB) MGet(keys ...[]byte) ([][]byte, error) {
 			return nil, err
 		}
 
+		if db.isExpired(KVType, keys[i]) {
+			continue
+		}
+
 		values[i] = it.Find(db.encodeKVKey(keys[i]))
 	}
 
@@ -269,6 +445,8 @@ func (db *DB) MSet(args ...KVPair) error {
 			return err
 		} else if err := checkValueSize(args[i].Value); err != nil {
 			return err
+		} else if err := db.expireOnWrite(t, KVType, args[i].Key); err != nil {
+			return err
 		}
 
 		key = db.encodeKVKey(args[i].Key)
@@ -291,19 +469,19 @@ func (db *DB) Set(key []byte, value []byte) error {
 		return err
 	}
 
-	var err error
-	key = db.encodeKVKey(key)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
-	t.Put(key, value)
+	// the ttl of a live key is kept, only a lazily expired one is dropped
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return err
+	}
 
-	err = t.Commit()
+	t.Put(db.encodeKVKey(key), value)
 
-	return err
+	return t.Commit()
 }
 
 // SetNX sets the data if not existed.
@@ -314,9 +492,6 @@ func (db *DB) SetNX(key []byte, value []byte) (int64, error) {
 		return 0, err
 	}
 
-	var err error
-	key = db.encodeKVKey(key)
-
 	var n int64 = 1
 
 	t := db.kvBatch
@@ -324,6 +499,13 @@ func (db *DB) SetNX(key []byte, value []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	var err error
+	key = db.encodeKVKey(key)
+
 	if v, err := db.bucket.Get(key); err != nil {
 		return 0, err
 	} else if v != nil {
@@ -354,8 +536,12 @@ func (db *DB) SetEX(key []byte, duration int64, value []byte) error {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return err
+	}
+
 	t.Put(ek, value)
-	db.expireAt(t, KVType, key, time.Now().Unix()+duration)
+	db.expireAt(t, KVType, key, nowMs()+duration*1000)
 
 	return t.Commit()
 }
@@ -373,7 +559,7 @@ func (db *DB) Expire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.setExpireAt(key, time.Now().Unix()+duration)
+	return db.setExpireAt(key, nowMs()+duration*1000)
 }
 
 // ExpireAt expires the data at when.
@@ -382,7 +568,7 @@ func (db *DB) ExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.setExpireAt(key, when)
+	return db.setExpireAt(key, when*1000)
 }
 
 // TTL returns the TTL of the data.
@@ -394,6 +580,67 @@ func (db *DB) TTL(key []byte) (int64, error) {
 	return db.ttl(KVType, key)
 }
 
+// PExpire expires the data with duration in milliseconds.
+func (db *DB) PExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.setExpireAt(key, nowMs()+duration)
+}
+
+// PExpireAt expires the data at when in milliseconds.
+func (db *DB) PExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMs() {
+		return 0, errExpireValue
+	}
+
+	return db.setExpireAt(key, when)
+}
+
+// ExpireWithJitter expires the data with ttl lengthened by a random
+// fraction in [0, jitter) of itself, the resolved expire time is saved.
+func (db *DB) ExpireWithJitter(key []byte, ttl time.Duration, jitter float64) (int64, error) {
+	duration, err := jitterDuration(ttl, jitter)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.setExpireAt(key, nowMs()+duration)
+}
+
+// PTTL gets the TTL of the data in milliseconds.
+func (db *DB) PTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(KVType, key)
+}
+
+// ExpireTime returns the unix time in seconds at which the data expires, -1
+// if it has no TTL and -2 if it does not exist.
+func (db *DB) ExpireTime(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	when, err := db.pexpireTime(KVType, key)
+	if when > 0 {
+		when /= 1000
+	}
+	return when, err
+}
+
+// PExpireTime is ExpireTime in milliseconds.
+func (db *DB) PExpireTime(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pexpireTime(KVType, key)
+}
+
 // Persist removes the TTL of the data.
 func (db *DB) Persist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -424,13 +671,17 @@ func (db *DB) SetRange(key []byte, offset int, value []byte) (int64, error) {
 		return 0, errValueSize
 	}
 
-	key = db.encodeKVKey(key)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	key = db.encodeKVKey(key)
+
 	oldValue, err := db.bucket.Get(key)
 	if err != nil {
 		return 0, err
@@ -480,6 +731,11 @@ func (db *DB) GetRange(key []byte, start int, end int) ([]byte, error) {
 	if err := checkKeySize(key); err != nil {
 		return nil, err
 	}
+
+	if db.isExpired(KVType, key) {
+		return nil, nil
+	}
+
 	key = db.encodeKVKey(key)
 
 	value, err := db.bucket.Get(key)
@@ -505,6 +761,10 @@ func (db *DB) StrLen(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if s == nil {
+		return 0, nil
+	}
+
 	n := s.Size()
 	s.Free()
 	return int64(n), nil
@@ -519,13 +779,17 @@ func (db *DB) Append(key []byte, value []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
-	key = db.encodeKVKey(key)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	key = db.encodeKVKey(key)
+
 	oldValue, err := db.bucket.Get(key)
 	if err != nil {
 		return 0, err
@@ -561,6 +825,15 @@ func (db *DB) BitOP(op string, destKey []byte, srcKeys ...[]byte) (int64, error)
 		return 0, nil
 	}
 
+	t := db.kvBatch
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, KVType, append([][]byte{destKey}, srcKeys...)...); err != nil {
+		return 0, err
+	}
+
 	key := db.encodeKVKey(srcKeys[0])
 
 	value, err := db.bucket.Get(key)
@@ -616,11 +889,6 @@ func (db *DB) BitOP(op string, destKey []byte, srcKeys ...[]byte) (int64, error)
 
 	key = db.encodeKVKey(destKey)
 
-	t := db.kvBatch
-
-	t.Lock()
-	defer t.Unlock()
-
 	t.Put(key, value)
 
 	if err := t.Commit(); err != nil {
@@ -655,6 +923,10 @@ func (db *DB) BitCount(key []byte, start int, end int) (int64, error) {
 		return 0, err
 	}
 
+	if db.isExpired(KVType, key) {
+		return 0, nil
+	}
+
 	key = db.encodeKVKey(key)
 	value, err := db.bucket.Get(key)
 	if err != nil {
@@ -693,10 +965,14 @@ func (db *DB) BitPos(key []byte, on int, start int, end int) (int64, error) {
 		skipValue = 0xFF
 	}
 
+	expired := db.isExpired(KVType, key)
+
 	key = db.encodeKVKey(key)
 	value, err := db.bucket.Get(key)
 	if err != nil {
 		return 0, err
+	} else if expired {
+		value = nil
 	}
 
 	start, end = getRange(start, end, len(value))
@@ -732,6 +1008,10 @@ func (db *DB) SetBit(key []byte, offset int, on int) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, KVType, key); err != nil {
+		return 0, err
+	}
+
 	key = db.encodeKVKey(key)
 	value, err := db.bucket.Get(key)
 	if err != nil {
@@ -771,6 +1051,10 @@ func (db *DB) GetBit(key []byte, offset int) (int64, error) {
 		return 0, err
 	}
 
+	if db.isExpired(KVType, key) {
+		return 0, nil
+	}
+
 	key = db.encodeKVKey(key)
 
 	value, err := db.bucket.Get(key)
@@ -792,3 +1076,238 @@ func (db *DB) GetBit(key []byte, offset int) (int64, error) {
 
 	return 0, nil
 }
+
+// BitFieldOpType is the operation type of BitFieldOp.
+type BitFieldOpType int
+
+// For BitFieldOp Type
+const (
+	BitFieldGet BitFieldOpType = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldEncoding is the integer encoding of BitFieldOp.
+type BitFieldEncoding int
+
+// For BitFieldOp Encoding
+const (
+	BitFieldUnsigned BitFieldEncoding = iota
+	BitFieldSigned
+)
+
+// BitFieldOverflow is the overflow policy of BitFieldOp.
+type BitFieldOverflow int
+
+// For BitFieldOp Overflow
+const (
+	BitFieldWrap BitFieldOverflow = iota
+	BitFieldSat
+	BitFieldFail
+)
+
+// BitFieldOp is an operation of BitField on the integer of Width bits at BitOffset.
+type BitFieldOp struct {
+	Type      BitFieldOpType
+	Encoding  BitFieldEncoding
+	BitOffset int
+	Width     uint8
+	// Value is the value for SET or the increment for INCRBY.
+	Value    int64
+	Overflow BitFieldOverflow
+}
+
+var errBitFieldWidth = errors.New("invalid bitfield type, use i1 to i64 or u1 to u63")
+
+func (op *BitFieldOp) check() error {
+	if op.Width == 0 || op.Width > 64 || (op.Encoding == BitFieldUnsigned && op.Width == 64) {
+		return errBitFieldWidth
+	} else if op.BitOffset < 0 {
+		return fmt.Errorf("bit offset must be a natural number, not %d", op.BitOffset)
+	} else if (op.BitOffset+int(op.Width)+7)/8 > MaxValueSize {
+		return errValueSize
+	}
+	return nil
+}
+
+func getBitField(value []byte, offset int, width uint8) uint64 {
+	var v uint64
+	for i := 0; i < int(width); i++ {
+		pos := offset + i
+		v <<= 1
+		if byteOffset := pos >> 3; byteOffset < len(value) {
+			v |= uint64(value[byteOffset]>>(7-uint(pos&0x7))) & 1
+		}
+	}
+	return v
+}
+
+func setBitField(value []byte, offset int, width uint8, v uint64) {
+	for i := 0; i < int(width); i++ {
+		pos := offset + i
+		bit := 7 - uint(pos&0x7)
+		value[pos>>3] &= ^(1 << bit)
+		value[pos>>3] |= uint8(v>>(uint(width)-1-uint(i))&1) << bit
+	}
+}
+
+// unsignedBitField returns the result of value + incr in width bits, ok is
+// false if it overflows with the FAIL policy, same as Redis.
+func unsignedBitField(value uint64, incr int64, width uint8, overflow BitFieldOverflow) (uint64, bool) {
+	max := uint64(1)<<width - 1
+	wrapped := (value + uint64(incr)) & max
+
+	if value > max || (incr > 0 && uint64(incr) > max-value) {
+		switch overflow {
+		case BitFieldSat:
+			return max, true
+		case BitFieldFail:
+			return 0, false
+		}
+		return wrapped, true
+	} else if incr < 0 && uint64(0)-uint64(incr) > value {
+		switch overflow {
+		case BitFieldSat:
+			return 0, true
+		case BitFieldFail:
+			return 0, false
+		}
+		return wrapped, true
+	}
+
+	return value + uint64(incr), true
+}
+
+// signedBitField is like unsignedBitField for the signed integers.
+func signedBitField(value int64, incr int64, width uint8, overflow BitFieldOverflow) (int64, bool) {
+	max := int64(uint64(1)<<(width-1) - 1)
+	min := -max - 1
+
+	wrap := func() int64 {
+		mask := ^uint64(0) << width
+		c := uint64(value) + uint64(incr)
+		if c&(uint64(1)<<(width-1)) != 0 {
+			c |= mask
+		} else {
+			c &= ^mask
+		}
+		return int64(c)
+	}
+
+	if value > max || (incr > 0 && value > max-incr) {
+		switch overflow {
+		case BitFieldSat:
+			return max, true
+		case BitFieldFail:
+			return 0, false
+		}
+		return wrap(), true
+	} else if value < min || (incr < 0 && value < min-incr) {
+		switch overflow {
+		case BitFieldSat:
+			return min, true
+		case BitFieldFail:
+			return 0, false
+		}
+		return wrap(), true
+	}
+
+	return value + incr, true
+}
+
+// BitField runs the operations on the integers of the data in one batch,
+// it returns the value of GET, the old value of SET and the new value of
+// INCRBY for every operation, nil if it fails with the FAIL policy.
+func (db *DB) BitField(key []byte, ops []BitFieldOp) ([]interface{}, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+
+	readonly := true
+	for i := range ops {
+		if err := ops[i].check(); err != nil {
+			return nil, err
+		} else if ops[i].Type != BitFieldGet {
+			readonly = false
+		}
+	}
+
+	if readonly && db.isExpired(KVType, key) {
+		return make([]interface{}, len(ops)), nil
+	}
+
+	t := db.kvBatch
+	if !readonly {
+		t.Lock()
+		defer t.Unlock()
+
+		if err := db.expireOnWrite(t, KVType, key); err != nil {
+			return nil, err
+		}
+	}
+
+	key = db.encodeKVKey(key)
+	value, err := db.bucket.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	res := make([]interface{}, len(ops))
+	for i, op := range ops {
+		old := getBitField(value, op.BitOffset, op.Width)
+
+		n := int64(old)
+		if op.Encoding == BitFieldSigned {
+			// sign extend
+			n = n << (64 - op.Width) >> (64 - op.Width)
+		}
+
+		if op.Type == BitFieldGet {
+			res[i] = n
+			continue
+		}
+
+		var v uint64
+		var ok bool
+		switch {
+		case op.Encoding == BitFieldSigned && op.Type == BitFieldSet:
+			var sv int64
+			sv, ok = signedBitField(op.Value, 0, op.Width, op.Overflow)
+			v = uint64(sv)
+			res[i] = n
+		case op.Encoding == BitFieldSigned:
+			var sv int64
+			sv, ok = signedBitField(n, op.Value, op.Width, op.Overflow)
+			v = uint64(sv)
+			res[i] = sv
+		case op.Type == BitFieldSet:
+			v, ok = unsignedBitField(uint64(op.Value), 0, op.Width, op.Overflow)
+			res[i] = n
+		default:
+			v, ok = unsignedBitField(old, op.Value, op.Width, op.Overflow)
+			res[i] = int64(v)
+		}
+
+		if !ok {
+			res[i] = nil
+			continue
+		}
+
+		if extra := (op.BitOffset+int(op.Width)+7)/8 - len(value); extra > 0 {
+			value = append(value, make([]byte, extra)...)
+		}
+
+		setBitField(value, op.BitOffset, op.Width, v)
+		changed = true
+	}
+
+	if changed {
+		t.Put(key, value)
+		if err := t.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
@@ -3,6 +3,7 @@ package ledis
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestKVCodec(t *testing.T) {
@@ -271,6 +272,41 @@ func TestKVPersist(t *testing.T) {
 		t.Fatal(n)
 	}
 }
+
+func TestKVExpireTime(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("test_kv_expiretime")
+	db.Del(key)
+
+	if n, err := db.ExpireTime(key); err != nil || n != -2 {
+		t.Fatal(n, err)
+	}
+
+	db.Set(key, []byte("v"))
+	if n, err := db.PExpireTime(key); err != nil || n != -1 {
+		t.Fatal(n, err)
+	}
+
+	db.Expire(key, 100)
+	when, err := db.ExpireTime(key)
+	if err != nil {
+		t.Fatal(err)
+	} else if ms, _ := db.PExpireTime(key); ms/1000 != when {
+		t.Fatal(ms, when)
+	}
+
+	// the expire time is fixed while the TTL goes down
+	time.Sleep(time.Second)
+	if n, _ := db.ExpireTime(key); n != when {
+		t.Fatal(n, when)
+	} else if ttl, _ := db.TTL(key); ttl < 98 || ttl > 99 {
+		t.Fatal(ttl)
+	} else if d := when - time.Now().Unix() - ttl; d < -1 || d > 1 {
+		t.Fatal(when, ttl)
+	}
+}
+
 func TestKVFlush(t *testing.T) {
 	db := getTestDB()
 	db.FlushAll()
@@ -350,3 +386,203 @@ func TestKVSetEX(t *testing.T) {
 	}
 
 }
+
+func TestKVBitField(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_kv_bitfield")
+	db.Del(key)
+
+	check := func(ops []BitFieldOp, expect ...interface{}) {
+		res, err := db.BitField(key, ops)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(res) != len(expect) {
+			t.Fatal(res)
+		}
+
+		for i := range res {
+			if res[i] != expect[i] {
+				t.Fatal(i, res, expect)
+			}
+		}
+	}
+
+	// the bits set by SETBIT are visible
+	if _, err := db.SetBit(key, 7, 1); err != nil {
+		t.Fatal(err)
+	}
+	check([]BitFieldOp{{Type: BitFieldGet, BitOffset: 0, Width: 8}}, int64(1))
+
+	check([]BitFieldOp{
+		{Type: BitFieldSet, Encoding: BitFieldSigned, BitOffset: 0, Width: 8, Value: -100},
+		{Type: BitFieldGet, Encoding: BitFieldSigned, BitOffset: 0, Width: 8},
+		{Type: BitFieldGet, BitOffset: 0, Width: 8},
+	}, int64(1), int64(-100), int64(156))
+
+	check([]BitFieldOp{
+		{Type: BitFieldIncrBy, Encoding: BitFieldSigned, BitOffset: 0, Width: 8, Value: -100},
+		{Type: BitFieldIncrBy, Encoding: BitFieldSigned, BitOffset: 0, Width: 8, Value: -100, Overflow: BitFieldSat},
+		{Type: BitFieldIncrBy, Encoding: BitFieldSigned, BitOffset: 0, Width: 8, Value: 1000, Overflow: BitFieldFail},
+	}, int64(56), int64(-44), nil)
+
+	for _, expect := range []int64{1, 2, 3, 3} {
+		check([]BitFieldOp{{Type: BitFieldIncrBy, BitOffset: 100, Width: 2, Value: 1, Overflow: BitFieldSat}}, expect)
+	}
+	check([]BitFieldOp{{Type: BitFieldIncrBy, BitOffset: 100, Width: 2, Value: 1}}, int64(0))
+	check([]BitFieldOp{{Type: BitFieldIncrBy, BitOffset: 200, Width: 2, Value: -1, Overflow: BitFieldFail}}, nil)
+
+	check([]BitFieldOp{
+		{Type: BitFieldSet, Encoding: BitFieldSigned, BitOffset: 8, Width: 64, Value: -1},
+		{Type: BitFieldGet, BitOffset: 8, Width: 63},
+	}, int64(0), int64(1<<63-1))
+
+	if v, err := db.GetBit(key, 8); err != nil {
+		t.Fatal(err)
+	} else if v != 1 {
+		t.Fatal(v)
+	}
+
+	if _, err := db.BitField(key, []BitFieldOp{{Type: BitFieldGet, Width: 64}}); err != errBitFieldWidth {
+		t.Fatal(err)
+	}
+}
+
+func TestKVGetDelEx(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_kv_getdel")
+
+	if v, err := db.GetDel(key); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
+	db.Set(key, []byte("hello"))
+	db.Expire(key, 100)
+
+	if v, err := db.GetDel(key); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "hello" {
+		t.Fatal(string(v))
+	}
+
+	if n, err := db.Exists(key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.TTL(key); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatal(n)
+	}
+
+	if v, err := db.GetEx(key, GetExOptions{TTL: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
+	db.Set(key, []byte("hello"))
+
+	if v, err := db.GetEx(key, GetExOptions{TTL: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "hello" {
+		t.Fatal(string(v))
+	}
+
+	if n, err := db.TTL(key); err != nil {
+		t.Fatal(err)
+	} else if n != 10 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.GetEx(key, GetExOptions{ExpireAt: time.Now().Add(100 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := db.TTL(key); err != nil {
+		t.Fatal(err)
+	} else if n < 99 || n > 100 {
+		t.Fatal(n)
+	}
+
+	if v, err := db.GetEx(key, GetExOptions{}); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "hello" {
+		t.Fatal(string(v))
+	}
+
+	if _, err := db.GetEx(key, GetExOptions{Persist: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := db.TTL(key); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.GetEx(key, GetExOptions{TTL: time.Second, Persist: true}); err != errGetExOptions {
+		t.Fatal(err)
+	}
+
+	if _, err := db.GetEx(key, GetExOptions{ExpireAt: time.Now().Add(-time.Second)}); err != errExpireValue {
+		t.Fatal(err)
+	}
+}
+
+func TestKVCASCAD(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_kv_cas")
+	db.Del(key)
+
+	if ok, err := db.CAS(key, []byte(""), []byte("a")); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not swap a missing key")
+	}
+
+	db.Set(key, []byte("a"))
+	db.Expire(key, 100)
+
+	if ok, err := db.CAS(key, []byte("b"), []byte("c")); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not swap")
+	}
+
+	if ok, err := db.CAS(key, []byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("must swap")
+	}
+
+	if v, _ := db.Get(key); string(v) != "b" {
+		t.Fatal(string(v))
+	} else if n, _ := db.TTL(key); n <= 0 {
+		t.Fatal(n)
+	}
+
+	if ok, err := db.CAD(key, []byte("a")); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("must not delete")
+	}
+
+	if ok, err := db.CAD(key, []byte("b")); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("must delete")
+	}
+
+	if n, _ := db.Exists(key); n != 0 {
+		t.Fatal(n)
+	} else if n, _ := db.TTL(key); n != -1 {
+		t.Fatal(n)
+	}
+}
@@ -1,6 +1,7 @@
 package ledis
 
 import (
+	"bytes"
 	"container/list"
 	"encoding/binary"
 	"errors"
@@ -23,9 +24,22 @@ const (
 	listInitialSeq int32 = listMinSeq + (listMaxSeq-listMinSeq)/2
 )
 
+// ListDir is the end of the list.
+type ListDir int8
+
+// For ListDir
+const (
+	ListHead ListDir = ListDir(listHeadSeq)
+	ListTail ListDir = ListDir(listTailSeq)
+)
+
 var errLMetaKey = errors.New("invalid lmeta key")
 var errListKey = errors.New("invalid list key")
 var errListSeq = errors.New("invalid list sequence, overflow")
+var errListRank = errors.New("rank can't be zero, use 1 to start from the first match or -1 from the last")
+var errListPosArgs = errors.New("count and maxlen can't be negative")
+var errListDir = errors.New("invalid list direction")
+var errListMPopCount = errors.New("invalid list mpop count")
 
 func (db *DB) lEncodeMetaKey(key []byte) []byte {
 	buf := make([]byte, len(key)+1+len(db.indexVarBuf))
@@ -115,6 +129,10 @@ func (db *DB) lpush(key []byte, whereSeq int32, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ListType, key); err != nil {
+		return 0, err
+	}
+
 	metaKey := db.lEncodeMetaKey(key)
 	headSeq, tailSeq, size, err = db.lGetMeta(nil, metaKey)
 	if err != nil {
@@ -167,6 +185,15 @@ func (db *DB) lpush(key []byte, whereSeq int32, args ...[]byte) (int64, error) {
 }
 
 func (db *DB) lpop(key []byte, whereSeq int32) ([]byte, error) {
+	v, err := db.lpopN(key, whereSeq, 1)
+	if err != nil || len(v) == 0 {
+		return nil, err
+	}
+	return v[0], nil
+}
+
+// lpopN pops at most count values from the whereSeq end in one batch.
+func (db *DB) lpopN(key []byte, whereSeq int32, count int) ([][]byte, error) {
 	if err := checkKeySize(key); err != nil {
 		return nil, err
 	}
@@ -175,6 +202,10 @@ func (db *DB) lpop(key []byte, whereSeq int32) ([]byte, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ListType, key); err != nil {
+		return nil, err
+	}
+
 	var headSeq int32
 	var tailSeq int32
 	var size int32
@@ -188,33 +219,232 @@ func (db *DB) lpop(key []byte, whereSeq int32) ([]byte, error) {
 		return nil, nil
 	}
 
-	var value []byte
+	if count > int(size) {
+		count = int(size)
+	}
+
+	values := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		seq := headSeq
+		if whereSeq == listTailSeq {
+			seq = tailSeq
+		}
+
+		itemKey := db.lEncodeListKey(key, seq)
+		value, err := db.bucket.Get(itemKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if whereSeq == listHeadSeq {
+			headSeq++
+		} else {
+			tailSeq--
+		}
+
+		t.Delete(itemKey)
+		values = append(values, value)
+	}
+
+	size = db.lSetMeta(metaKey, headSeq, tailSeq)
+	if size == 0 {
+		db.rmExpire(t, ListType, key)
+	}
+
+	err = t.Commit()
+	return values, err
+}
+
+// LMPop pops at most count values from the dir end of the first non empty
+// list in keys, it returns the key and the values, or nil if all are empty.
+func (db *DB) LMPop(dir ListDir, count int, keys ...[]byte) ([]byte, [][]byte, error) {
+	if dir != ListHead && dir != ListTail {
+		return nil, nil, errListDir
+	} else if count <= 0 {
+		return nil, nil, errListMPopCount
+	}
+
+	for _, key := range keys {
+		if db.isExpired(ListType, key) {
+			continue
+		}
+
+		if v, err := db.lpopN(key, int32(dir), count); err != nil {
+			return nil, nil, err
+		} else if len(v) > 0 {
+			return key, v, nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+// BLMPop is like LMPop, but waits until any list is not empty or timeout,
+// timeout <= 0 means waiting forever.
+func (db *DB) BLMPop(dir ListDir, count int, timeout time.Duration, keys ...[]byte) ([]byte, [][]byte, error) {
+	for {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+
+		// wait before popping so a push between them is not missed
+		for _, key := range keys {
+			db.lbkeys.wait(key, cancel)
+		}
+
+		key, v, err := db.LMPop(dir, count, keys...)
+		if err != nil || key != nil {
+			cancel()
+			return key, v, err
+		}
+
+		//blocking wait
+		<-ctx.Done()
+		cancel()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil, nil
+		}
+	}
+}
+
+// LMove pops the element from the srcDir end of src and pushes it to the
+// dstDir end of dst in one batch, it returns nil if src is empty.
+func (db *DB) LMove(src []byte, dst []byte, srcDir ListDir, dstDir ListDir) ([]byte, error) {
+	if err := checkKeySize(src); err != nil {
+		return nil, err
+	} else if err := checkKeySize(dst); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []ListDir{srcDir, dstDir} {
+		if dir != ListHead && dir != ListTail {
+			return nil, errListDir
+		}
+	}
+
+	if db.isExpired(ListType, src) {
+		return nil, nil
+	}
+
+	t := db.listBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, ListType, src, dst); err != nil {
+		return nil, err
+	}
+
+	srcMetaKey := db.lEncodeMetaKey(src)
+	headSeq, tailSeq, size, err := db.lGetMeta(nil, srcMetaKey)
+	if err != nil {
+		return nil, err
+	} else if size == 0 {
+		return nil, nil
+	}
 
 	seq := headSeq
-	if whereSeq == listTailSeq {
+	if srcDir == ListTail {
 		seq = tailSeq
 	}
 
-	itemKey := db.lEncodeListKey(key, seq)
-	value, err = db.bucket.Get(itemKey)
+	itemKey := db.lEncodeListKey(src, seq)
+	value, err := db.bucket.Get(itemKey)
 	if err != nil {
 		return nil, err
 	}
 
-	if whereSeq == listHeadSeq {
+	same := bytes.Equal(src, dst)
+	if same && (size == 1 || srcDir == dstDir) {
+		// the list is not changed
+		return value, nil
+	}
+
+	t.Delete(itemKey)
+	if srcDir == ListHead {
 		headSeq++
 	} else {
 		tailSeq--
 	}
 
-	t.Delete(itemKey)
-	size = db.lSetMeta(metaKey, headSeq, tailSeq)
-	if size == 0 {
-		db.rmExpire(t, ListType, key)
+	dstMetaKey := srcMetaKey
+	dstHeadSeq, dstTailSeq, dstSize := headSeq, tailSeq, size-1
+	if !same {
+		dstMetaKey = db.lEncodeMetaKey(dst)
+		if dstHeadSeq, dstTailSeq, dstSize, err = db.lGetMeta(nil, dstMetaKey); err != nil {
+			return nil, err
+		}
 	}
 
-	err = t.Commit()
-	return value, err
+	seq = dstHeadSeq
+	var delta int32 = -1
+	if dstDir == ListTail {
+		seq = dstTailSeq
+		delta = 1
+	}
+
+	if dstSize > 0 {
+		seq += delta
+	}
+
+	if seq <= listMinSeq || seq >= listMaxSeq {
+		return nil, errListSeq
+	}
+
+	t.Put(db.lEncodeListKey(dst, seq), value)
+
+	if dstDir == ListHead {
+		dstHeadSeq = seq
+	} else {
+		dstTailSeq = seq
+	}
+
+	if !same && db.lSetMeta(srcMetaKey, headSeq, tailSeq) == 0 {
+		db.rmExpire(t, ListType, src)
+	}
+	db.lSetMeta(dstMetaKey, dstHeadSeq, dstTailSeq)
+
+	if err = t.Commit(); err != nil {
+		return nil, err
+	}
+
+	db.lSignalAsReady(dst)
+	return value, nil
+}
+
+// BLMove is the blocking LMove, it waits until src has an element or
+// timeout, timeout <= 0 means waiting forever, it returns nil on timeout.
+func (db *DB) BLMove(src []byte, dst []byte, srcDir ListDir, dstDir ListDir, timeout time.Duration) ([]byte, error) {
+	for {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+
+		// wait before moving so a push between them is not missed
+		db.lbkeys.wait(src, cancel)
+
+		v, err := db.LMove(src, dst, srcDir, dstDir)
+		if err != nil || v != nil {
+			cancel()
+			return v, err
+		}
+
+		//blocking wait
+		<-ctx.Done()
+		cancel()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil
+		}
+	}
 }
 
 func (db *DB) ltrim2(key []byte, startP, stopP int64) (err error) {
@@ -226,6 +456,10 @@ func (db *DB) ltrim2(key []byte, startP, stopP int64) (err error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ListType, key); err != nil {
+		return err
+	}
+
 	var headSeq int32
 	var llen int32
 	start := int32(startP)
@@ -284,6 +518,10 @@ func (db *DB) ltrim(key []byte, trimSize, whereSeq int32) (int32, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ListType, key); err != nil {
+		return 0, err
+	}
+
 	var headSeq int32
 	var tailSeq int32
 	var size int32
@@ -427,6 +665,10 @@ func (db *DB) LIndex(key []byte, index int32) ([]byte, error) {
 		return nil, err
 	}
 
+	if db.isExpired(ListType, key) {
+		return nil, nil
+	}
+
 	var seq int32
 	var headSeq int32
 	var tailSeq int32
@@ -454,12 +696,87 @@ func (db *DB) LIndex(key []byte, index int32) ([]byte, error) {
 	return v, nil
 }
 
+// LPos returns the indexes of the elements equal to element. It starts from
+// the rank-th match, counted from the tail if rank is negative, returns at
+// most count indexes, 0 means all, and compares at most maxLen elements,
+// 0 means the whole list.
+func (db *DB) LPos(key []byte, element []byte, rank int, count int, maxLen int) ([]int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	} else if rank == 0 {
+		return nil, errListRank
+	} else if count < 0 || maxLen < 0 {
+		return nil, errListPosArgs
+	}
+
+	if db.isExpired(ListType, key) {
+		return []int64{}, nil
+	}
+
+	metaKey := db.lEncodeMetaKey(key)
+
+	it := db.bucket.NewIterator()
+	defer it.Close()
+
+	headSeq, tailSeq, size, err := db.lGetMeta(it, metaKey)
+	if err != nil {
+		return nil, err
+	} else if size == 0 {
+		return []int64{}, nil
+	}
+
+	if maxLen == 0 {
+		maxLen = -1
+	}
+
+	r := &store.Range{
+		Min:  db.lEncodeListKey(key, headSeq),
+		Max:  db.lEncodeListKey(key, tailSeq),
+		Type: store.RangeClose}
+	l := &store.Limit{Offset: 0, Count: maxLen}
+
+	var rit *store.RangeLimitIterator
+	skip := rank - 1
+	if rank > 0 {
+		rit = store.NewRangeLimitIterator(it, r, l)
+	} else {
+		rit = store.NewRevRangeLimitIterator(it, r, l)
+		skip = -rank - 1
+	}
+
+	pos := []int64{}
+	for ; rit.Valid(); rit.Next() {
+		if !bytes.Equal(rit.RawValue(), element) {
+			continue
+		} else if skip > 0 {
+			skip--
+			continue
+		}
+
+		_, seq, err := db.lDecodeListKey(rit.RawKey())
+		if err != nil {
+			return nil, err
+		}
+
+		pos = append(pos, int64(seq-headSeq))
+		if count > 0 && len(pos) == count {
+			break
+		}
+	}
+
+	return pos, nil
+}
+
 // LLen gets the length of the list.
 func (db *DB) LLen(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
 
+	if db.isExpired(ListType, key) {
+		return 0, nil
+	}
+
 	ek := db.lEncodeMetaKey(key)
 	_, _, size, err := db.lGetMeta(nil, ek)
 	return int64(size), err
@@ -504,6 +821,10 @@ func (db *DB) LSet(key []byte, index int32, value []byte) error {
 	t := db.listBatch
 	t.Lock()
 	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, ListType, key); err != nil {
+		return err
+	}
 	metaKey := db.lEncodeMetaKey(key)
 
 	headSeq, tailSeq, _, err = db.lGetMeta(nil, metaKey)
@@ -531,6 +852,10 @@ func (db *DB) LRange(key []byte, start int32, stop int32) ([][]byte, error) {
 		return nil, err
 	}
 
+	if db.isExpired(ListType, key) {
+		return [][]byte{}, nil
+	}
+
 	var headSeq int32
 	var llen int32
 	var err error
@@ -604,6 +929,10 @@ func (db *DB) LClear(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ListType, key); err != nil {
+		return 0, err
+	}
+
 	num := db.lDelete(t, key)
 	db.rmExpire(t, ListType, key)
 
@@ -617,6 +946,10 @@ func (db *DB) LMclear(keys ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ListType, keys...); err != nil {
+		return 0, err
+	}
+
 	for _, key := range keys {
 		if err := checkKeySize(key); err != nil {
 			return 0, err
@@ -644,7 +977,7 @@ func (db *DB) LExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.lExpireAt(key, time.Now().Unix()+duration)
+	return db.lExpireAt(key, nowMs()+duration*1000)
 }
 
 // LExpireAt expires the list at when.
@@ -653,7 +986,7 @@ func (db *DB) LExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.lExpireAt(key, when)
+	return db.lExpireAt(key, when*1000)
 }
 
 // LTTL gets the TTL of list.
@@ -665,6 +998,44 @@ func (db *DB) LTTL(key []byte) (int64, error) {
 	return db.ttl(ListType, key)
 }
 
+// LPExpire expires the list with duration in milliseconds.
+func (db *DB) LPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.lExpireAt(key, nowMs()+duration)
+}
+
+// LPExpireAt expires the list at when in milliseconds.
+func (db *DB) LPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMs() {
+		return 0, errExpireValue
+	}
+
+	return db.lExpireAt(key, when)
+}
+
+// LExpireWithJitter expires the list with ttl lengthened by a random
+// fraction in [0, jitter) of itself, the resolved expire time is saved.
+func (db *DB) LExpireWithJitter(key []byte, ttl time.Duration, jitter float64) (int64, error) {
+	duration, err := jitterDuration(ttl, jitter)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.lExpireAt(key, nowMs()+duration)
+}
+
+// LPTTL gets the TTL of the list in milliseconds.
+func (db *DB) LPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(ListType, key)
+}
+
 // LPersist removes the TTL of list.
 func (db *DB) LPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -675,6 +1046,10 @@ func (db *DB) LPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ListType, key); err != nil {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, ListType, key)
 	if err != nil {
 		return 0, err
@@ -709,6 +1084,9 @@ func (db *DB) LKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+	if db.isExpired(ListType, key) {
+		return 0, nil
+	}
 	sk := db.lEncodeMetaKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
@@ -793,7 +1171,14 @@ func (l *lBlockKeys) popOrWait(db *DB, key []byte, whereSeq int32, fn context.Ca
 		return []interface{}{key, v}, nil
 	}
 
+	l.wait(key, fn)
+	return nil, nil
+}
+
+// wait registers fn to be called when the key is signaled.
+func (l *lBlockKeys) wait(key []byte, fn context.CancelFunc) {
 	l.Lock()
+	defer l.Unlock()
 
 	s := hack.String(key)
 	chs, ok := l.keys[s]
@@ -803,6 +1188,4 @@ func (l *lBlockKeys) popOrWait(db *DB, key []byte, whereSeq int32, fn context.Ca
 	}
 
 	chs.PushBack(fn)
-	l.Unlock()
-	return nil, nil
 }
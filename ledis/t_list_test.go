@@ -332,3 +332,230 @@ func TestListPop(t *testing.T) {
 	}
 
 }
+
+func TestListPos(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("test_list_pos")
+	db.LClear(key)
+
+	if pos, err := db.LPos(key, []byte("c"), 1, 0, 0); err != nil {
+		t.Fatal(err)
+	} else if len(pos) != 0 {
+		t.Fatal(pos)
+	}
+
+	for _, v := range []string{"a", "b", "c", "1", "2", "3", "c", "c"} {
+		db.RPush(key, []byte(v))
+	}
+
+	tests := []struct {
+		element string
+		rank    int
+		count   int
+		maxLen  int
+		pos     []int64
+	}{
+		{"c", 1, 1, 0, []int64{2}},
+		{"c", 2, 1, 0, []int64{6}},
+		{"c", -1, 1, 0, []int64{7}},
+		{"c", 1, 2, 0, []int64{2, 6}},
+		{"c", -1, 2, 0, []int64{7, 6}},
+		{"c", 1, 0, 0, []int64{2, 6, 7}},
+		{"c", -2, 0, 0, []int64{6, 2}},
+		{"c", 1, 0, 2, []int64{}},
+		{"c", 1, 0, 3, []int64{2}},
+		{"c", -1, 0, 2, []int64{7, 6}},
+		{"c", 4, 0, 0, []int64{}},
+		{"c", -4, 0, 0, []int64{}},
+		{"d", 1, 0, 0, []int64{}},
+	}
+
+	for _, tt := range tests {
+		pos, err := db.LPos(key, []byte(tt.element), tt.rank, tt.count, tt.maxLen)
+		if err != nil {
+			t.Fatal(err)
+		} else if fmt.Sprint(pos) != fmt.Sprint(tt.pos) {
+			t.Fatal(tt, pos)
+		}
+	}
+
+	if _, err := db.LPos(key, []byte("c"), 0, 0, 0); err != errListRank {
+		t.Fatal(err)
+	}
+
+	if _, err := db.LPos(key, []byte("c"), 1, -1, 0); err != errListPosArgs {
+		t.Fatal(err)
+	}
+
+	if _, err := db.LPos(key, []byte("c"), 1, 0, -1); err != errListPosArgs {
+		t.Fatal(err)
+	}
+}
+
+func TestListMove(t *testing.T) {
+	db := getTestDB()
+
+	src := []byte("test_list_move_src")
+	dst := []byte("test_list_move_dst")
+	db.LMclear(src, dst)
+
+	if v, err := db.LMove(src, dst, ListTail, ListHead); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(v)
+	}
+
+	db.RPush(src, []byte("1"), []byte("2"), []byte("3"))
+
+	if v, err := db.LMove(src, dst, ListTail, ListHead); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "3" {
+		t.Fatal(string(v))
+	}
+
+	if v, err := db.LMove(src, dst, ListHead, ListTail); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "1" {
+		t.Fatal(string(v))
+	}
+
+	// rotate
+	if v, err := db.LMove(dst, dst, ListHead, ListTail); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "3" {
+		t.Fatal(string(v))
+	}
+
+	if v, err := db.LRange(dst, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprintf("%s", v) != "[1 3]" {
+		t.Fatalf("%s", v)
+	}
+
+	db.LExpire(src, 100)
+	if v, err := db.LMove(src, dst, ListHead, ListHead); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "2" {
+		t.Fatal(string(v))
+	}
+
+	if n, err := db.LKeyExists(src); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.LTTL(src); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatal(n)
+	}
+
+	if v, err := db.LRange(dst, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprintf("%s", v) != "[2 1 3]" {
+		t.Fatalf("%s", v)
+	}
+
+	if _, err := db.LMove(src, dst, 0, ListHead); err != errListDir {
+		t.Fatal(err)
+	}
+}
+
+func TestListBlockMove(t *testing.T) {
+	db := getTestDB()
+
+	src := []byte("test_list_bmove_src")
+	dst := []byte("test_list_bmove_dst")
+	db.LMclear(src, dst)
+
+	if v, err := db.BLMove(src, dst, ListHead, ListHead, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(v)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		if v, err := db.BLMove(src, dst, ListHead, ListHead, 0); err != nil {
+			t.Error(err)
+		} else if string(v) != "value" {
+			t.Error(string(v))
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	db.LPush(src, []byte("value"))
+	wg.Wait()
+
+	if n, err := db.LLen(dst); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestListMPop(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("testdb_lmpop_1")
+	key2 := []byte("testdb_lmpop_2")
+	db.LMclear(key1, key2)
+
+	if key, v, err := db.LMPop(ListHead, 1, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if key != nil || v != nil {
+		t.Fatal(key, v)
+	}
+
+	db.RPush(key2, []byte("a"), []byte("b"), []byte("c"))
+
+	if key, v, err := db.LMPop(ListTail, 2, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if string(key) != string(key2) || len(v) != 2 || string(v[0]) != "c" || string(v[1]) != "b" {
+		t.Fatal(string(key), v)
+	}
+
+	if key, v, err := db.LMPop(ListHead, 10, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if string(key) != string(key2) || len(v) != 1 || string(v[0]) != "a" {
+		t.Fatal(string(key), v)
+	}
+
+	if n, _ := db.LKeyExists(key2); n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, _, err := db.LMPop(ListHead, 0, key1); err != errListMPopCount {
+		t.Fatal(err)
+	}
+
+	if key, _, err := db.BLMPop(ListHead, 1, 10*time.Millisecond, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if key != nil {
+		t.Fatal(string(key))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		key, v, err := db.BLMPop(ListHead, 2, 0, key1, key2)
+		if err != nil {
+			t.Error(err)
+		} else if string(key) != string(key1) || len(v) != 2 {
+			t.Error(string(key), v)
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	db.RPush(key1, []byte("x"), []byte("y"))
+	wg.Wait()
+}
@@ -11,6 +11,8 @@ import (
 
 var errSetKey = errors.New("invalid set key")
 var errSSizeKey = errors.New("invalid ssize key")
+var errSetKeysNum = errors.New("invalid set keys number")
+var errSetLimit = errors.New("invalid set limit")
 
 // For set operation type.
 const (
@@ -212,6 +214,10 @@ func (db *DB) SAdd(key []byte, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, SetType, key); err != nil {
+		return 0, err
+	}
+
 	var err error
 	var ek []byte
 	var num int64
@@ -246,6 +252,10 @@ func (db *DB) SCard(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if db.isExpired(SetType, key) {
+		return 0, nil
+	}
+
 	sk := db.sEncodeSizeKey(key)
 
 	return Int64(db.bucket.Get(sk))
@@ -312,6 +322,9 @@ func (db *DB) SKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+	if db.isExpired(SetType, key) {
+		return 0, nil
+	}
 	sk := db.sEncodeSizeKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
@@ -387,8 +400,71 @@ func (db *DB) SInterStore(dstKey []byte, keys ...[]byte) (int64, error) {
 	return n, err
 }
 
+// SInterCard returns the cardinality of the intersection of the sets without
+// building it, the smallest set is used to probe the others. If limit > 0,
+// the counting stops once limit is reached.
+func (db *DB) SInterCard(limit int, keys ...[]byte) (int64, error) {
+	if len(keys) == 0 {
+		return 0, errSetKeysNum
+	} else if limit < 0 {
+		return 0, errSetLimit
+	}
+
+	probe := 0
+	var minCard int64
+	for i, key := range keys {
+		n, err := db.SCard(key)
+		if err != nil {
+			return 0, err
+		} else if n == 0 {
+			return 0, nil
+		}
+
+		if i == 0 || n < minCard {
+			probe, minCard = i, n
+		}
+	}
+
+	it := db.bucket.RangeLimitIterator(db.sEncodeStartKey(keys[probe]), db.sEncodeStopKey(keys[probe]), store.RangeROpen, 0, -1)
+	defer it.Close()
+
+	var n int64
+	for ; it.Valid(); it.Next() {
+		_, member, err := db.sDecodeSetKey(it.RawKey())
+		if err != nil {
+			return 0, err
+		}
+
+		found := true
+		for i, key := range keys {
+			if i == probe {
+				continue
+			}
+
+			if v, err := db.bucket.Get(db.sEncodeSetKey(key, member)); err != nil {
+				return 0, err
+			} else if v == nil {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			if n++; limit > 0 && n >= int64(limit) {
+				break
+			}
+		}
+	}
+
+	return n, nil
+}
+
 // SIsMember checks member in set.
 func (db *DB) SIsMember(key []byte, member []byte) (int64, error) {
+	if db.isExpired(SetType, key) {
+		return 0, nil
+	}
+
 	ek := db.sEncodeSetKey(key, member)
 
 	var n int64 = 1
@@ -410,6 +486,9 @@ func (db *DB) SMembers(key []byte) ([][]byte, error) {
 	stop := db.sEncodeStopKey(key)
 
 	v := make([][]byte, 0, 16)
+	if db.isExpired(SetType, key) {
+		return v, nil
+	}
 
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
@@ -432,6 +511,10 @@ func (db *DB) SRem(key []byte, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, SetType, key); err != nil {
+		return 0, err
+	}
+
 	var ek []byte
 	var v []byte
 	var err error
@@ -517,6 +600,10 @@ func (db *DB) sStoreGeneric(dstKey []byte, optType byte, keys ...[]byte) (int64,
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, SetType, dstKey); err != nil {
+		return 0, err
+	}
+
 	db.sDelete(t, dstKey)
 
 	var err error
@@ -570,6 +657,10 @@ func (db *DB) SClear(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, SetType, key); err != nil {
+		return 0, err
+	}
+
 	num := db.sDelete(t, key)
 	db.rmExpire(t, SetType, key)
 
@@ -583,6 +674,10 @@ func (db *DB) SMclear(keys ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, SetType, keys...); err != nil {
+		return 0, err
+	}
+
 	for _, key := range keys {
 		if err := checkKeySize(key); err != nil {
 			return 0, err
@@ -602,7 +697,7 @@ func (db *DB) SExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.sExpireAt(key, time.Now().Unix()+duration)
+	return db.sExpireAt(key, nowMs()+duration*1000)
 
 }
 
@@ -612,7 +707,7 @@ func (db *DB) SExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.sExpireAt(key, when)
+	return db.sExpireAt(key, when*1000)
 
 }
 
@@ -625,6 +720,44 @@ func (db *DB) STTL(key []byte) (int64, error) {
 	return db.ttl(SetType, key)
 }
 
+// SPExpire expires the set with duration in milliseconds.
+func (db *DB) SPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.sExpireAt(key, nowMs()+duration)
+}
+
+// SPExpireAt expires the set at when in milliseconds.
+func (db *DB) SPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMs() {
+		return 0, errExpireValue
+	}
+
+	return db.sExpireAt(key, when)
+}
+
+// SExpireWithJitter expires the set with ttl lengthened by a random
+// fraction in [0, jitter) of itself, the resolved expire time is saved.
+func (db *DB) SExpireWithJitter(key []byte, ttl time.Duration, jitter float64) (int64, error) {
+	duration, err := jitterDuration(ttl, jitter)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.sExpireAt(key, nowMs()+duration)
+}
+
+// SPTTL gets the TTL of the set in milliseconds.
+func (db *DB) SPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(SetType, key)
+}
+
 // SPersist removes the TTL of set.
 func (db *DB) SPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -635,6 +768,10 @@ func (db *DB) SPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, SetType, key); err != nil {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, SetType, key)
 	if err != nil {
 		return 0, err
@@ -2,6 +2,7 @@ package ledis
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -390,3 +391,79 @@ func TestSKeyExists(t *testing.T) {
 	}
 
 }
+
+func TestSInterCard(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("testdb_sintercard_1")
+	key2 := []byte("testdb_sintercard_2")
+	key3 := []byte("testdb_sintercard_3")
+	db.SMclear(key1, key2, key3)
+
+	db.SAdd(key1, []byte("a"), []byte("b"), []byte("c"), []byte("d"))
+	db.SAdd(key2, []byte("b"), []byte("c"), []byte("d"), []byte("e"))
+
+	if n, err := db.SInterCard(0, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.SInterCard(2, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.SInterCard(0, key1, key2, key3); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.SInterCard(-1, key1); err != errSetLimit {
+		t.Fatal(err)
+	}
+
+	if _, err := db.SInterCard(0); err != errSetKeysNum {
+		t.Fatal(err)
+	}
+}
+
+func benchmarkSInter(b *testing.B, f func(db *DB, small []byte, large []byte)) {
+	db := getTestDB()
+
+	small := []byte("bench_sinter_small")
+	large := []byte("bench_sinter_large")
+	db.SMclear(small, large)
+
+	for i := 0; i < 10000; i++ {
+		db.SAdd(large, []byte(strconv.Itoa(i)))
+		if i%100 == 0 {
+			db.SAdd(small, []byte(strconv.Itoa(i)))
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f(db, small, large)
+	}
+}
+
+func BenchmarkSInter(b *testing.B) {
+	benchmarkSInter(b, func(db *DB, small []byte, large []byte) {
+		db.SInter(large, small)
+	})
+}
+
+func BenchmarkSInterCard(b *testing.B) {
+	benchmarkSInter(b, func(db *DB, small []byte, large []byte) {
+		db.SInterCard(0, large, small)
+	})
+}
+
+func BenchmarkSInterCardLimit(b *testing.B) {
+	benchmarkSInter(b, func(db *DB, small []byte, large []byte) {
+		db.SInterCard(10, large, small)
+	})
+}
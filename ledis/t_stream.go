@@ -0,0 +1,797 @@
+package ledis
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+/*
+	A stream is an append only log of entries ordered by ID, every entry
+	is saved under its big endian ID, so the store keeps them in ID order:
+
+	db index + StreamType + key len + key + streamStartSep + ms + seq -> fields
+	db index + StreamMetaType + key -> length + last ID
+
+	The consumer groups are saved after the entries, see t_stream_group.go.
+*/
+
+const (
+	streamStartSep byte = ':'
+
+	streamIDSize   = 16
+	streamMetaSize = 8 + streamIDSize
+)
+
+var (
+	errStreamKey     = errors.New("invalid stream key")
+	errStreamMeta    = errors.New("invalid stream meta")
+	errStreamValue   = errors.New("invalid stream value")
+	errStreamID      = errors.New("invalid stream ID specified")
+	errStreamIDZero  = errors.New("the ID specified must be greater than 0-0")
+	errStreamIDSmall = errors.New("the ID specified is equal or smaller than the stream top item")
+	errStreamFields  = errors.New("stream entry must have at least one field")
+	errStreamKeyIDs  = errors.New("stream keys and IDs must have the same length")
+)
+
+// StreamID is the ID of a stream entry, formatted as <ms>-<seq>.
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var (
+	streamIDMin = StreamID{0, 0}
+	streamIDMax = StreamID{math.MaxUint64, math.MaxUint64}
+)
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Less reports whether id is smaller than o.
+func (id StreamID) Less(o StreamID) bool {
+	return id.Ms < o.Ms || (id.Ms == o.Ms && id.Seq < o.Seq)
+}
+
+// next returns the smallest ID greater than id, ok is false if id is the max.
+func (id StreamID) next() (StreamID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return StreamID{id.Ms, id.Seq + 1}, true
+	} else if id.Ms < math.MaxUint64 {
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// ParseStreamID parses the ID <ms>-<seq>, seq is defSeq if omitted.
+func ParseStreamID(s string, defSeq uint64) (StreamID, error) {
+	var id StreamID
+	var err error
+
+	ms, seq := s, ""
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		ms, seq = s[:i], s[i+1:]
+	}
+
+	if id.Ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return id, errStreamID
+	}
+
+	if len(seq) == 0 && len(ms) == len(s) {
+		id.Seq = defSeq
+	} else if id.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+		return id, errStreamID
+	}
+
+	return id, nil
+}
+
+// parseStreamRangeID parses the range bound, "-" and "+" are the min and max.
+func parseStreamRangeID(s string, start bool) (StreamID, error) {
+	switch s {
+	case "-":
+		return streamIDMin, nil
+	case "+":
+		return streamIDMax, nil
+	}
+
+	if start {
+		return ParseStreamID(s, 0)
+	}
+	return ParseStreamID(s, math.MaxUint64)
+}
+
+// StreamEntry is an entry of the stream.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []FVPair
+}
+
+type streamMeta struct {
+	length int64
+	last   StreamID
+}
+
+func (m *streamMeta) encode() []byte {
+	buf := make([]byte, streamMetaSize)
+	binary.BigEndian.PutUint64(buf, uint64(m.length))
+	binary.BigEndian.PutUint64(buf[8:], m.last.Ms)
+	binary.BigEndian.PutUint64(buf[16:], m.last.Seq)
+	return buf
+}
+
+func (m *streamMeta) decode(v []byte) error {
+	if len(v) < streamMetaSize {
+		return errStreamMeta
+	}
+
+	m.length = int64(binary.BigEndian.Uint64(v))
+	m.last.Ms = binary.BigEndian.Uint64(v[8:])
+	m.last.Seq = binary.BigEndian.Uint64(v[16:])
+	return nil
+}
+
+// nextID returns the ID for the new entry, id is "*", "<ms>-*", "<ms>"
+// or "<ms>-<seq>", the ID must be greater than the last ID.
+func (m *streamMeta) nextID(id string) (StreamID, error) {
+	if id == "*" {
+		now := StreamID{uint64(nowMs()), 0}
+		if m.last.Less(now) {
+			return now, nil
+		}
+
+		next, ok := m.last.next()
+		if !ok {
+			return next, errStreamIDSmall
+		}
+		return next, nil
+	}
+
+	auto := false
+	if strings.HasSuffix(id, "-*") {
+		id = id[:len(id)-2]
+		auto = true
+	} else if strings.IndexByte(id, '-') < 0 {
+		auto = true
+	}
+
+	next, err := ParseStreamID(id, 0)
+	if err != nil {
+		return next, err
+	}
+
+	if auto && next.Ms == m.last.Ms {
+		if m.last.Seq == math.MaxUint64 {
+			return next, errStreamIDSmall
+		}
+		next.Seq = m.last.Seq + 1
+	} else if auto && next.Ms == 0 {
+		next.Seq = 1
+	}
+
+	if next == streamIDMin {
+		return next, errStreamIDZero
+	} else if !m.last.Less(next) {
+		return next, errStreamIDSmall
+	}
+
+	return next, nil
+}
+
+func checkStreamFields(fields []FVPair) error {
+	if len(fields) == 0 {
+		return errStreamFields
+	}
+
+	for _, f := range fields {
+		if len(f.Field) > MaxHashFieldSize || len(f.Field) == 0 {
+			return errHashFieldSize
+		} else if err := checkValueSize(f.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func xEncodeFields(fields []FVPair) []byte {
+	size := binary.MaxVarintLen64
+	for _, f := range fields {
+		size += 2*binary.MaxVarintLen64 + len(f.Field) + len(f.Value)
+	}
+
+	buf := make([]byte, size)
+	pos := binary.PutUvarint(buf, uint64(len(fields)))
+	for _, f := range fields {
+		pos += binary.PutUvarint(buf[pos:], uint64(len(f.Field)))
+		pos += copy(buf[pos:], f.Field)
+		pos += binary.PutUvarint(buf[pos:], uint64(len(f.Value)))
+		pos += copy(buf[pos:], f.Value)
+	}
+
+	return buf[:pos]
+}
+
+func xDecodeFields(v []byte) ([]FVPair, error) {
+	n, pos := binary.Uvarint(v)
+	if pos <= 0 || n > uint64(len(v)) {
+		return nil, errStreamValue
+	}
+
+	next := func() ([]byte, error) {
+		l, m := binary.Uvarint(v[pos:])
+		if m <= 0 || uint64(len(v)-pos-m) < l {
+			return nil, errStreamValue
+		}
+		pos += m
+		b := v[pos : pos+int(l)]
+		pos += int(l)
+		return b, nil
+	}
+
+	var err error
+	fields := make([]FVPair, n)
+	for i := range fields {
+		if fields[i].Field, err = next(); err != nil {
+			return nil, err
+		} else if fields[i].Value, err = next(); err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
+
+func (db *DB) xEncodeMetaKey(key []byte) []byte {
+	ek := make([]byte, len(key)+1+len(db.indexVarBuf))
+	pos := copy(ek, db.indexVarBuf)
+	ek[pos] = StreamMetaType
+	pos++
+	copy(ek[pos:], key)
+	return ek
+}
+
+func (db *DB) xDecodeMetaKey(ek []byte) ([]byte, error) {
+	pos, err := db.checkKeyIndex(ek)
+	if err != nil {
+		return nil, err
+	}
+	if pos+1 > len(ek) || ek[pos] != StreamMetaType {
+		return nil, errStreamKey
+	}
+
+	pos++
+
+	return ek[pos:], nil
+}
+
+// xEncodeKeyPrefix returns the prefix of all the keys of the stream but the
+// meta, the entries and the consumer groups.
+func (db *DB) xEncodeKeyPrefix(key []byte) []byte {
+	buf := make([]byte, len(key)+1+2+len(db.indexVarBuf))
+
+	pos := copy(buf, db.indexVarBuf)
+
+	buf[pos] = StreamType
+	pos++
+
+	binary.BigEndian.PutUint16(buf[pos:], uint16(len(key)))
+	pos += 2
+
+	copy(buf[pos:], key)
+	return buf
+}
+
+func (db *DB) xEncodeEntryKey(key []byte, id StreamID) []byte {
+	buf := make([]byte, len(key)+1+1+2+streamIDSize+len(db.indexVarBuf))
+
+	pos := copy(buf, db.indexVarBuf)
+
+	buf[pos] = StreamType
+	pos++
+
+	binary.BigEndian.PutUint16(buf[pos:], uint16(len(key)))
+	pos += 2
+
+	pos += copy(buf[pos:], key)
+
+	buf[pos] = streamStartSep
+	pos++
+
+	binary.BigEndian.PutUint64(buf[pos:], id.Ms)
+	binary.BigEndian.PutUint64(buf[pos+8:], id.Seq)
+
+	return buf
+}
+
+func (db *DB) xDecodeEntryKey(ek []byte) ([]byte, StreamID, error) {
+	var id StreamID
+
+	pos, err := db.checkKeyIndex(ek)
+	if err != nil {
+		return nil, id, err
+	}
+
+	if pos+1 > len(ek) || ek[pos] != StreamType {
+		return nil, id, errStreamKey
+	}
+	pos++
+
+	if pos+2 > len(ek) {
+		return nil, id, errStreamKey
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(ek[pos:]))
+	pos += 2
+
+	if pos+keyLen+1+streamIDSize != len(ek) {
+		return nil, id, errStreamKey
+	}
+
+	key := ek[pos : pos+keyLen]
+	pos += keyLen
+
+	if ek[pos] != streamStartSep {
+		return nil, id, errStreamKey
+	}
+	pos++
+
+	id.Ms = binary.BigEndian.Uint64(ek[pos:])
+	id.Seq = binary.BigEndian.Uint64(ek[pos+8:])
+	return key, id, nil
+}
+
+// xGetMeta returns the meta of the stream, nil if the stream does not exist.
+func (db *DB) xGetMeta(key []byte) (*streamMeta, error) {
+	if db.isExpired(StreamType, key) {
+		return nil, nil
+	}
+
+	v, err := db.bucket.Get(db.xEncodeMetaKey(key))
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	m := new(streamMeta)
+	if err = m.decode(v); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// xDelete deletes the stream with its consumer groups, it returns the
+// number of the entries.
+func (db *DB) xDelete(t *batch, key []byte) int64 {
+	prefix := db.xEncodeKeyPrefix(key)
+
+	var num int64
+	it := db.bucket.RangeLimitIterator(prefix, store.PrefixEnd(prefix), store.RangeROpen, 0, -1)
+	for ; it.Valid(); it.Next() {
+		ek := it.Key()
+		if ek[len(prefix)] == streamStartSep {
+			num++
+		}
+		t.Delete(ek)
+	}
+	it.Close()
+
+	t.Delete(db.xEncodeMetaKey(key))
+	return num
+}
+
+// xRange returns the entries with ID in [start, stop], count <= 0 means no limit.
+func (db *DB) xRange(key []byte, start StreamID, stop StreamID, count int, reverse bool) ([]StreamEntry, error) {
+	if stop.Less(start) {
+		return []StreamEntry{}, nil
+	}
+
+	if m, err := db.xGetMeta(key); err != nil {
+		return nil, err
+	} else if m == nil {
+		return []StreamEntry{}, nil
+	}
+
+	if count <= 0 {
+		count = -1
+	}
+
+	min := db.xEncodeEntryKey(key, start)
+	max := db.xEncodeEntryKey(key, stop)
+
+	var it *store.RangeLimitIterator
+	if reverse {
+		it = db.bucket.RevRangeLimitIterator(min, max, store.RangeClose, 0, count)
+	} else {
+		it = db.bucket.RangeLimitIterator(min, max, store.RangeClose, 0, count)
+	}
+	defer it.Close()
+
+	entries := []StreamEntry{}
+	for ; it.Valid(); it.Next() {
+		_, id, err := db.xDecodeEntryKey(it.Key())
+		if err != nil {
+			return nil, err
+		}
+
+		fields, err := xDecodeFields(it.Value())
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, StreamEntry{ID: id, Fields: fields})
+	}
+
+	return entries, nil
+}
+
+func (db *DB) xSetExpireAt(key []byte, when int64) (int64, error) {
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if exist, err := db.XKeyExists(key); err != nil || exist == 0 {
+		return 0, err
+	}
+
+	db.expireAt(t, StreamType, key, when)
+	if err := t.Commit(); err != nil {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// XAdd appends the entry to the stream, it returns the ID of the entry.
+// The ID is generated if id is "*", the sequence is generated for "<ms>-*".
+func (db *DB) XAdd(key []byte, id string, fields ...FVPair) (string, error) {
+	if err := checkKeySize(key); err != nil {
+		return "", err
+	} else if err := checkStreamFields(fields); err != nil {
+		return "", err
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, key); err != nil {
+		return "", err
+	}
+
+	m, err := db.xGetMeta(key)
+	if err != nil {
+		return "", err
+	} else if m == nil {
+		m = new(streamMeta)
+	}
+
+	next, err := m.nextID(id)
+	if err != nil {
+		return "", err
+	}
+
+	m.length++
+	m.last = next
+
+	t.Put(db.xEncodeEntryKey(key, next), xEncodeFields(fields))
+	t.Put(db.xEncodeMetaKey(key), m.encode())
+
+	if err = t.Commit(); err != nil {
+		return "", err
+	}
+
+	db.xbkeys.signal(key)
+	return next.String(), nil
+}
+
+// XLen returns the number of entries in the stream.
+func (db *DB) XLen(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	}
+
+	m, err := db.xGetMeta(key)
+	if err != nil || m == nil {
+		return 0, err
+	}
+	return m.length, nil
+}
+
+// XRange returns the entries with ID between start and end, "-" and "+"
+// are the min and max ID, count <= 0 means no limit.
+func (db *DB) XRange(key []byte, start string, end string, count int) ([]StreamEntry, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+
+	min, err := parseStreamRangeID(start, true)
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := parseStreamRangeID(end, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.xRange(key, min, max, count, false)
+}
+
+// XRevRange is like XRange, but returns the entries in reverse order.
+func (db *DB) XRevRange(key []byte, end string, start string, count int) ([]StreamEntry, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+
+	min, err := parseStreamRangeID(start, true)
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := parseStreamRangeID(end, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.xRange(key, min, max, count, true)
+}
+
+// xReadIDs parses the last IDs of the keys, "$" is the last ID of the stream.
+func (db *DB) xReadIDs(keys [][]byte, lastIDs []string) ([]StreamID, error) {
+	if len(keys) != len(lastIDs) {
+		return nil, errStreamKeyIDs
+	}
+
+	ids := make([]StreamID, len(keys))
+	for i, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return nil, err
+		}
+
+		if lastIDs[i] != "$" {
+			id, err := ParseStreamID(lastIDs[i], 0)
+			if err != nil {
+				return nil, err
+			}
+			ids[i] = id
+			continue
+		}
+
+		m, err := db.xGetMeta(key)
+		if err != nil {
+			return nil, err
+		} else if m != nil {
+			ids[i] = m.last
+		}
+	}
+
+	return ids, nil
+}
+
+func (db *DB) xRead(keys [][]byte, ids []StreamID, count int) (map[string][]StreamEntry, error) {
+	res := make(map[string][]StreamEntry)
+	for i, key := range keys {
+		start, ok := ids[i].next()
+		if !ok {
+			continue
+		}
+
+		entries, err := db.xRange(key, start, streamIDMax, count, false)
+		if err != nil {
+			return nil, err
+		} else if len(entries) > 0 {
+			res[string(key)] = entries
+		}
+	}
+
+	return res, nil
+}
+
+// XRead returns at most count entries with ID greater than the last ID
+// for every stream, the streams without new entries are not in the result.
+func (db *DB) XRead(keys [][]byte, lastIDs []string, count int) (map[string][]StreamEntry, error) {
+	ids, err := db.xReadIDs(keys, lastIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.xRead(keys, ids, count)
+}
+
+// XReadBlock is like XRead, but waits until any stream has new entries or
+// timeout, timeout <= 0 means waiting forever, it returns nil on timeout.
+func (db *DB) XReadBlock(keys [][]byte, lastIDs []string, count int, timeout time.Duration) (map[string][]StreamEntry, error) {
+	ids, err := db.xReadIDs(keys, lastIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+
+		// wait before reading so an entry added between them is not missed
+		for _, key := range keys {
+			db.xbkeys.wait(key, cancel)
+		}
+
+		res, err := db.xRead(keys, ids, count)
+		if err != nil || len(res) > 0 {
+			cancel()
+			return res, err
+		}
+
+		//blocking wait
+		<-ctx.Done()
+		cancel()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil
+		}
+	}
+}
+
+// XClear deletes the stream, it returns the number of deleted entries.
+func (db *DB) XClear(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, key); err != nil {
+		return 0, err
+	}
+
+	num := db.xDelete(t, key)
+	db.rmExpire(t, StreamType, key)
+
+	err := t.Commit()
+	return num, err
+}
+
+// XMClear deletes the streams.
+func (db *DB) XMClear(keys ...[]byte) (int64, error) {
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, keys...); err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return 0, err
+		}
+
+		db.xDelete(t, key)
+		db.rmExpire(t, StreamType, key)
+	}
+
+	err := t.Commit()
+	return int64(len(keys)), err
+}
+
+func (db *DB) xFlush() (drop int64, err error) {
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+	return db.flushType(t, StreamType)
+}
+
+// XExpire expires the stream.
+func (db *DB) XExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.xSetExpireAt(key, nowMs()+duration*1000)
+}
+
+// XExpireAt expires the stream at when.
+func (db *DB) XExpireAt(key []byte, when int64) (int64, error) {
+	if when <= time.Now().Unix() {
+		return 0, errExpireValue
+	}
+
+	return db.xSetExpireAt(key, when*1000)
+}
+
+// XTTL returns the TTL of the stream.
+func (db *DB) XTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.ttl(StreamType, key)
+}
+
+// XPExpire expires the stream with duration in milliseconds.
+func (db *DB) XPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.xSetExpireAt(key, nowMs()+duration)
+}
+
+// XPExpireAt expires the stream at when in milliseconds.
+func (db *DB) XPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMs() {
+		return 0, errExpireValue
+	}
+
+	return db.xSetExpireAt(key, when)
+}
+
+// XExpireWithJitter expires the stream with ttl lengthened by a
+// random fraction in [0, jitter) of itself, the resolved expire time is saved.
+func (db *DB) XExpireWithJitter(key []byte, ttl time.Duration, jitter float64) (int64, error) {
+	duration, err := jitterDuration(ttl, jitter)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.xSetExpireAt(key, nowMs()+duration)
+}
+
+// XPTTL returns the TTL of the stream in milliseconds.
+func (db *DB) XPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(StreamType, key)
+}
+
+// XPersist removes the TTL of the stream.
+func (db *DB) XPersist(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, key); err != nil {
+		return 0, err
+	}
+
+	n, err := db.rmExpire(t, StreamType, key)
+	if err != nil {
+		return 0, err
+	}
+
+	err = t.Commit()
+	return n, err
+}
+
+// XKeyExists checks whether the stream exists or not.
+func (db *DB) XKeyExists(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	}
+	if db.isExpired(StreamType, key) {
+		return 0, nil
+	}
+	v, err := db.bucket.Get(db.xEncodeMetaKey(key))
+	if v != nil && err == nil {
+		return 1, nil
+	}
+	return 0, err
+}
@@ -0,0 +1,669 @@
+package ledis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+/*
+	The consumer groups of a stream are saved after its entries, under the
+	same key prefix with their own separators:
+
+	db index + StreamType + key len + key + streamGroupSep + group -> last delivered ID
+	db index + StreamType + key len + key + streamPendingSep + group len + group + ms + seq -> delivery time + delivery count + consumer
+
+	The pending entries of a group are kept in ID order for XPENDING and
+	XAUTOCLAIM, the ones of a consumer are found by filtering them.
+*/
+
+const (
+	streamGroupSep   byte = 'g'
+	streamPendingSep byte = 'p'
+
+	streamPendingSize = 16
+
+	// XAUTOCLAIM claims this many entries if COUNT is not given
+	streamAutoClaimCount = 100
+)
+
+var (
+	errStreamGroupKey  = errors.New("invalid stream group key")
+	errStreamPending   = errors.New("invalid stream pending entry")
+	errStreamNoKey     = errors.New("The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	errStreamNoGroup   = errors.New("NOGROUP No such key or consumer group")
+	errStreamBusyGroup = errors.New("BUSYGROUP Consumer Group name already exists")
+	errStreamGroupName = errors.New("invalid consumer group name")
+	errStreamConsumer  = errors.New("invalid consumer name")
+)
+
+// StreamPending is an entry delivered to a consumer but not acknowledged.
+type StreamPending struct {
+	ID       StreamID
+	Consumer []byte
+	// the milliseconds since the last delivery
+	Idle       int64
+	Deliveries int64
+}
+
+// StreamConsumerPending is the number of the pending entries of a consumer.
+type StreamConsumerPending struct {
+	Consumer []byte
+	Count    int64
+}
+
+// StreamPendingSummary is the summary form of XPENDING, Min and Max are
+// zero if there is no pending entry.
+type StreamPendingSummary struct {
+	Count     int64
+	Min       StreamID
+	Max       StreamID
+	Consumers []StreamConsumerPending
+}
+
+type streamPendingValue struct {
+	time       int64
+	deliveries int64
+	consumer   []byte
+}
+
+func (v *streamPendingValue) encode() []byte {
+	buf := make([]byte, streamPendingSize+len(v.consumer))
+	binary.BigEndian.PutUint64(buf, uint64(v.time))
+	binary.BigEndian.PutUint64(buf[8:], uint64(v.deliveries))
+	copy(buf[streamPendingSize:], v.consumer)
+	return buf
+}
+
+func (v *streamPendingValue) decode(b []byte) error {
+	if len(b) < streamPendingSize {
+		return errStreamPending
+	}
+
+	v.time = int64(binary.BigEndian.Uint64(b))
+	v.deliveries = int64(binary.BigEndian.Uint64(b[8:]))
+	v.consumer = b[streamPendingSize:]
+	return nil
+}
+
+func checkStreamGroupName(group []byte, consumer []byte) error {
+	if len(group) == 0 || len(group) > MaxKeySize {
+		return errStreamGroupName
+	} else if consumer != nil && (len(consumer) == 0 || len(consumer) > MaxKeySize) {
+		return errStreamConsumer
+	}
+	return nil
+}
+
+func (db *DB) xEncodeGroupKey(key []byte, group []byte) []byte {
+	prefix := db.xEncodeKeyPrefix(key)
+
+	buf := make([]byte, len(prefix)+1+len(group))
+	pos := copy(buf, prefix)
+	buf[pos] = streamGroupSep
+	pos++
+	copy(buf[pos:], group)
+	return buf
+}
+
+func (db *DB) xEncodePendingKey(key []byte, group []byte, id StreamID) []byte {
+	prefix := db.xEncodeKeyPrefix(key)
+
+	buf := make([]byte, len(prefix)+1+2+len(group)+streamIDSize)
+	pos := copy(buf, prefix)
+	buf[pos] = streamPendingSep
+	pos++
+
+	binary.BigEndian.PutUint16(buf[pos:], uint16(len(group)))
+	pos += 2
+
+	pos += copy(buf[pos:], group)
+
+	binary.BigEndian.PutUint64(buf[pos:], id.Ms)
+	binary.BigEndian.PutUint64(buf[pos+8:], id.Seq)
+	return buf
+}
+
+// xDecodeGroupItemKey decodes the key of a consumer group or a pending
+// entry, id is only decoded for a pending entry.
+func (db *DB) xDecodeGroupItemKey(ek []byte) (key []byte, group []byte, id StreamID, pending bool, err error) {
+	pos, err := db.checkKeyIndex(ek)
+	if err != nil {
+		return
+	}
+
+	if pos+1+2 > len(ek) || ek[pos] != StreamType {
+		err = errStreamGroupKey
+		return
+	}
+	pos++
+
+	keyLen := int(binary.BigEndian.Uint16(ek[pos:]))
+	pos += 2
+
+	if pos+keyLen+1 > len(ek) {
+		err = errStreamGroupKey
+		return
+	}
+
+	key = ek[pos : pos+keyLen]
+	pos += keyLen
+
+	switch ek[pos] {
+	case streamGroupSep:
+		group = ek[pos+1:]
+	case streamPendingSep:
+		pos++
+		if pos+2 > len(ek) {
+			err = errStreamGroupKey
+			return
+		}
+
+		groupLen := int(binary.BigEndian.Uint16(ek[pos:]))
+		pos += 2
+
+		if pos+groupLen+streamIDSize != len(ek) {
+			err = errStreamGroupKey
+			return
+		}
+
+		group = ek[pos : pos+groupLen]
+		pos += groupLen
+
+		id.Ms = binary.BigEndian.Uint64(ek[pos:])
+		id.Seq = binary.BigEndian.Uint64(ek[pos+8:])
+		pending = true
+	default:
+		err = errStreamGroupKey
+	}
+	return
+}
+
+// xGetGroup returns the last delivered ID of the group, ok is false if the
+// stream or the group does not exist.
+func (db *DB) xGetGroup(key []byte, group []byte) (last StreamID, ok bool, err error) {
+	if m, err := db.xGetMeta(key); err != nil || m == nil {
+		return last, false, err
+	}
+
+	v, err := db.bucket.Get(db.xEncodeGroupKey(key, group))
+	if err != nil || v == nil {
+		return last, false, err
+	} else if len(v) != streamIDSize {
+		return last, false, errStreamValue
+	}
+
+	last.Ms = binary.BigEndian.Uint64(v)
+	last.Seq = binary.BigEndian.Uint64(v[8:])
+	return last, true, nil
+}
+
+func (db *DB) xPutGroup(t *batch, key []byte, group []byte, last StreamID) {
+	v := make([]byte, streamIDSize)
+	binary.BigEndian.PutUint64(v, last.Ms)
+	binary.BigEndian.PutUint64(v[8:], last.Seq)
+	t.Put(db.xEncodeGroupKey(key, group), v)
+}
+
+// xRangePending calls f for the pending entries of the group with ID in
+// [start, stop] in order, until f returns false.
+func (db *DB) xRangePending(key []byte, group []byte, start StreamID, stop StreamID, f func(id StreamID, v *streamPendingValue) (bool, error)) error {
+	if stop.Less(start) {
+		return nil
+	}
+
+	min := db.xEncodePendingKey(key, group, start)
+	max := db.xEncodePendingKey(key, group, stop)
+
+	it := db.bucket.RangeLimitIterator(min, max, store.RangeClose, 0, -1)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		_, _, id, _, err := db.xDecodeGroupItemKey(it.RawKey())
+		if err != nil {
+			return err
+		}
+
+		v := new(streamPendingValue)
+		if err = v.decode(it.Value()); err != nil {
+			return err
+		}
+
+		if ok, err := f(id, v); err != nil || !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// xGetEntry returns the fields of the entry, nil if it is deleted.
+func (db *DB) xGetEntry(key []byte, id StreamID) ([]FVPair, error) {
+	v, err := db.bucket.Get(db.xEncodeEntryKey(key, id))
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return xDecodeFields(v)
+}
+
+// XGroupCreate creates the consumer group of the stream, it delivers the
+// entries after id, "$" is the last entry of the stream. The stream is
+// created empty if it does not exist and mkstream is true.
+func (db *DB) XGroupCreate(key []byte, group []byte, id string, mkstream bool) error {
+	if err := checkKeySize(key); err != nil {
+		return err
+	} else if err := checkStreamGroupName(group, nil); err != nil {
+		return err
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, key); err != nil {
+		return err
+	}
+
+	m, err := db.xGetMeta(key)
+	if err != nil {
+		return err
+	} else if m == nil {
+		if !mkstream {
+			return errStreamNoKey
+		}
+
+		// the groups of an expired stream not deleted yet
+		db.xDelete(t, key)
+		db.rmExpire(t, StreamType, key)
+
+		m = new(streamMeta)
+		t.Put(db.xEncodeMetaKey(key), m.encode())
+	} else if v, err := db.bucket.Get(db.xEncodeGroupKey(key, group)); err != nil {
+		return err
+	} else if v != nil {
+		return errStreamBusyGroup
+	}
+
+	last := m.last
+	if id != "$" {
+		if last, err = ParseStreamID(id, 0); err != nil {
+			return err
+		}
+	}
+
+	db.xPutGroup(t, key, group, last)
+	return t.Commit()
+}
+
+// XGroupDestroy deletes the consumer group with its pending entries, it
+// returns 1 if the group existed.
+func (db *DB) XGroupDestroy(key []byte, group []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	} else if err := checkStreamGroupName(group, nil); err != nil {
+		return 0, err
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, key); err != nil {
+		return 0, err
+	}
+
+	if _, ok, err := db.xGetGroup(key, group); err != nil || !ok {
+		return 0, err
+	}
+
+	t.Delete(db.xEncodeGroupKey(key, group))
+
+	min := db.xEncodePendingKey(key, group, streamIDMin)
+	max := db.xEncodePendingKey(key, group, streamIDMax)
+	it := db.bucket.RangeLimitIterator(min, max, store.RangeClose, 0, -1)
+	for ; it.Valid(); it.Next() {
+		t.Delete(it.Key())
+	}
+	it.Close()
+
+	return 1, t.Commit()
+}
+
+// XReadGroup reads the streams as the consumer of the group. For the ID
+// ">" it returns at most count entries never delivered to the group, and
+// adds them to the pending entries of the consumer unless noAck. For
+// another ID it returns the pending entries of the consumer after it
+// again, the entries deleted from the stream have nil fields. The streams
+// without new entries are not in the result, the ones read again always
+// are.
+func (db *DB) XReadGroup(group []byte, consumer []byte, keys [][]byte, ids []string, count int, noAck bool) (map[string][]StreamEntry, error) {
+	if len(keys) != len(ids) {
+		return nil, errStreamKeyIDs
+	} else if err := checkStreamGroupName(group, consumer); err != nil {
+		return nil, err
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, keys...); err != nil {
+		return nil, err
+	}
+
+	// check all the groups and the IDs before any delivery
+	lasts := make([]StreamID, len(keys))
+	for i, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return nil, err
+		}
+
+		last, ok, err := db.xGetGroup(key, group)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errStreamNoGroup
+		}
+
+		if ids[i] == ">" {
+			lasts[i] = last
+		} else if lasts[i], err = ParseStreamID(ids[i], 0); err != nil {
+			return nil, err
+		}
+	}
+
+	now := nowMs()
+	res := make(map[string][]StreamEntry)
+	for i, key := range keys {
+		var entries []StreamEntry
+		var err error
+		if ids[i] == ">" {
+			entries, err = db.xReadGroupNew(t, key, group, consumer, lasts[i], count, noAck, now)
+		} else {
+			entries, err = db.xReadGroupPending(key, group, consumer, lasts[i], count)
+		}
+
+		if err != nil {
+			return nil, err
+		} else if len(entries) > 0 || ids[i] != ">" {
+			res[string(key)] = entries
+		}
+	}
+
+	if err := t.Commit(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (db *DB) xReadGroupNew(t *batch, key []byte, group []byte, consumer []byte, last StreamID, count int, noAck bool, now int64) ([]StreamEntry, error) {
+	start, ok := last.next()
+	if !ok {
+		return nil, nil
+	}
+
+	entries, err := db.xRange(key, start, streamIDMax, count, false)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	if !noAck {
+		v := &streamPendingValue{time: now, deliveries: 1, consumer: consumer}
+		for _, e := range entries {
+			t.Put(db.xEncodePendingKey(key, group, e.ID), v.encode())
+		}
+	}
+
+	db.xPutGroup(t, key, group, entries[len(entries)-1].ID)
+	return entries, nil
+}
+
+func (db *DB) xReadGroupPending(key []byte, group []byte, consumer []byte, last StreamID, count int) ([]StreamEntry, error) {
+	start, ok := last.next()
+	if !ok {
+		return nil, nil
+	}
+
+	entries := []StreamEntry{}
+	err := db.xRangePending(key, group, start, streamIDMax, func(id StreamID, v *streamPendingValue) (bool, error) {
+		if !bytes.Equal(v.consumer, consumer) {
+			return true, nil
+		}
+
+		fields, err := db.xGetEntry(key, id)
+		if err != nil {
+			return false, err
+		}
+
+		entries = append(entries, StreamEntry{ID: id, Fields: fields})
+		return count <= 0 || len(entries) < count, nil
+	})
+	return entries, err
+}
+
+// XAck acknowledges the entries of the group, they are removed from the
+// pending entries. It returns the number of the entries acknowledged.
+func (db *DB) XAck(key []byte, group []byte, ids ...string) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return 0, err
+	} else if err := checkStreamGroupName(group, nil); err != nil {
+		return 0, err
+	}
+
+	pids := make([]StreamID, len(ids))
+	for i, id := range ids {
+		var err error
+		if pids[i], err = ParseStreamID(id, 0); err != nil {
+			return 0, err
+		}
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, key); err != nil {
+		return 0, err
+	}
+
+	if _, ok, err := db.xGetGroup(key, group); err != nil || !ok {
+		return 0, err
+	}
+
+	var n int64
+	seen := make(map[StreamID]struct{})
+	for _, id := range pids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		pk := db.xEncodePendingKey(key, group, id)
+		if v, err := db.bucket.Get(pk); err != nil {
+			return 0, err
+		} else if v != nil {
+			t.Delete(pk)
+			n++
+		}
+	}
+
+	return n, t.Commit()
+}
+
+// XPendingSummary returns the number of the pending entries of the group,
+// the smallest and the greatest ID, and the number of every consumer.
+func (db *DB) XPendingSummary(key []byte, group []byte) (*StreamPendingSummary, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	} else if err := checkStreamGroupName(group, nil); err != nil {
+		return nil, err
+	}
+
+	if _, ok, err := db.xGetGroup(key, group); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errStreamNoGroup
+	}
+
+	s := new(StreamPendingSummary)
+	consumers := make(map[string]int)
+	err := db.xRangePending(key, group, streamIDMin, streamIDMax, func(id StreamID, v *streamPendingValue) (bool, error) {
+		if s.Count == 0 {
+			s.Min = id
+		}
+		s.Max = id
+		s.Count++
+
+		if i, ok := consumers[string(v.consumer)]; ok {
+			s.Consumers[i].Count++
+		} else {
+			consumers[string(v.consumer)] = len(s.Consumers)
+			s.Consumers = append(s.Consumers, StreamConsumerPending{Consumer: append([]byte(nil), v.consumer...), Count: 1})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// XPending returns at most count pending entries of the group with ID
+// between start and end, "-" and "+" are the min and max ID. Only the
+// entries idle for minIdle milliseconds at least are returned, and only
+// the ones of consumer if it is not nil.
+func (db *DB) XPending(key []byte, group []byte, start string, end string, count int, consumer []byte, minIdle int64) ([]StreamPending, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	} else if err := checkStreamGroupName(group, nil); err != nil {
+		return nil, err
+	}
+
+	min, err := parseStreamRangeID(start, true)
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := parseStreamRangeID(end, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok, err := db.xGetGroup(key, group); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errStreamNoGroup
+	}
+
+	now := nowMs()
+	ps := []StreamPending{}
+	if count <= 0 {
+		return ps, nil
+	}
+
+	err = db.xRangePending(key, group, min, max, func(id StreamID, v *streamPendingValue) (bool, error) {
+		idle := now - v.time
+		if idle < 0 {
+			idle = 0
+		}
+
+		if idle < minIdle || (consumer != nil && !bytes.Equal(v.consumer, consumer)) {
+			return true, nil
+		}
+
+		ps = append(ps, StreamPending{
+			ID:         id,
+			Consumer:   append([]byte(nil), v.consumer...),
+			Idle:       idle,
+			Deliveries: v.deliveries,
+		})
+		return len(ps) < count, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// XAutoClaim transfers at most count pending entries of the group idle for
+// minIdle milliseconds at least, from start on, to the consumer. The
+// delivery count of the claimed entries is incremented unless justID, the
+// entries deleted from the stream are removed from the pending entries.
+// It returns the ID to start the next call from, 0-0 if all the pending
+// entries are scanned, the claimed entries, only with the IDs if justID,
+// and the IDs of the deleted entries.
+func (db *DB) XAutoClaim(key []byte, group []byte, consumer []byte, minIdle int64, start string, count int, justID bool) (StreamID, []StreamEntry, []StreamID, error) {
+	next := streamIDMin
+
+	if err := checkKeySize(key); err != nil {
+		return next, nil, nil, err
+	} else if err := checkStreamGroupName(group, consumer); err != nil {
+		return next, nil, nil, err
+	}
+
+	min, err := parseStreamRangeID(start, true)
+	if err != nil {
+		return next, nil, nil, err
+	}
+
+	if count <= 0 {
+		count = streamAutoClaimCount
+	}
+
+	t := db.streamBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, StreamType, key); err != nil {
+		return StreamID{}, nil, nil, err
+	}
+
+	if _, ok, err := db.xGetGroup(key, group); err != nil {
+		return next, nil, nil, err
+	} else if !ok {
+		return next, nil, nil, errStreamNoGroup
+	}
+
+	now := nowMs()
+	claimed := []StreamEntry{}
+	deleted := []StreamID{}
+	err = db.xRangePending(key, group, min, streamIDMax, func(id StreamID, v *streamPendingValue) (bool, error) {
+		if len(claimed)+len(deleted) >= count {
+			next = id
+			return false, nil
+		} else if now-v.time < minIdle {
+			return true, nil
+		}
+
+		pk := db.xEncodePendingKey(key, group, id)
+
+		fields, err := db.xGetEntry(key, id)
+		if err != nil {
+			return false, err
+		} else if fields == nil {
+			t.Delete(pk)
+			deleted = append(deleted, id)
+			return true, nil
+		}
+
+		v.consumer = consumer
+		v.time = now
+		if !justID {
+			v.deliveries++
+			claimed = append(claimed, StreamEntry{ID: id, Fields: fields})
+		} else {
+			claimed = append(claimed, StreamEntry{ID: id})
+		}
+		t.Put(pk, v.encode())
+		return true, nil
+	})
+	if err != nil {
+		return streamIDMin, nil, nil, err
+	}
+
+	if err = t.Commit(); err != nil {
+		return streamIDMin, nil, nil, err
+	}
+	return next, claimed, deleted, nil
+}
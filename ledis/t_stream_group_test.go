@@ -0,0 +1,163 @@
+package ledis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamGroupCodec(t *testing.T) {
+	db := getTestDB()
+
+	key, group, id := []byte("key"), []byte("group"), StreamID{1, 2}
+
+	if k, g, _, pending, err := db.xDecodeGroupItemKey(db.xEncodeGroupKey(key, group)); err != nil {
+		t.Fatal(err)
+	} else if string(k) != "key" || string(g) != "group" || pending {
+		t.Fatal(string(k), string(g), pending)
+	}
+
+	if k, g, i, pending, err := db.xDecodeGroupItemKey(db.xEncodePendingKey(key, group, id)); err != nil {
+		t.Fatal(err)
+	} else if string(k) != "key" || string(g) != "group" || i != id || !pending {
+		t.Fatal(string(k), string(g), i, pending)
+	}
+
+	if _, _, _, _, err := db.xDecodeGroupItemKey(db.xEncodeEntryKey(key, id)); err != errStreamGroupKey {
+		t.Fatal(err)
+	}
+}
+
+func TestDBStreamGroup(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_stream_group")
+	group := []byte("g")
+	db.XClear(key)
+	defer db.XClear(key)
+
+	f := FVPair{Field: []byte("f"), Value: []byte("v")}
+
+	if err := db.XGroupCreate(key, group, "$", false); err != errStreamNoKey {
+		t.Fatal(err)
+	} else if err = db.XGroupCreate(key, group, "$", true); err != nil {
+		t.Fatal(err)
+	} else if err = db.XGroupCreate(key, group, "0", false); err != errStreamBusyGroup {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1-1", "2-1", "3-1"} {
+		if _, err := db.XAdd(key, id, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.XReadGroup([]byte("none"), []byte("a"), [][]byte{key}, []string{">"}, 0, false); err != errStreamNoGroup {
+		t.Fatal(err)
+	}
+
+	res, err := db.XReadGroup(group, []byte("a"), [][]byte{key}, []string{">"}, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	} else if es := res[string(key)]; len(es) != 2 || es[0].ID != (StreamID{1, 1}) || es[1].ID != (StreamID{2, 1}) {
+		t.Fatal(es)
+	}
+
+	// the last one is not pending with noAck
+	if res, err = db.XReadGroup(group, []byte("b"), [][]byte{key}, []string{">"}, 0, true); err != nil {
+		t.Fatal(err)
+	} else if es := res[string(key)]; len(es) != 1 || es[0].ID != (StreamID{3, 1}) {
+		t.Fatal(es)
+	}
+
+	if res, err = db.XReadGroup(group, []byte("b"), [][]byte{key}, []string{">"}, 0, false); err != nil {
+		t.Fatal(err)
+	} else if len(res) != 0 {
+		t.Fatal(res)
+	}
+
+	// the history of the consumer
+	if res, err = db.XReadGroup(group, []byte("a"), [][]byte{key}, []string{"1-1"}, 0, false); err != nil {
+		t.Fatal(err)
+	} else if es, ok := res[string(key)]; !ok || len(es) != 1 || es[0].ID != (StreamID{2, 1}) {
+		t.Fatal(es)
+	}
+
+	if s, err := db.XPendingSummary(key, group); err != nil {
+		t.Fatal(err)
+	} else if s.Count != 2 || s.Min != (StreamID{1, 1}) || s.Max != (StreamID{2, 1}) || len(s.Consumers) != 1 {
+		t.Fatal(s)
+	} else if string(s.Consumers[0].Consumer) != "a" || s.Consumers[0].Count != 2 {
+		t.Fatal(s.Consumers)
+	}
+
+	if ps, err := db.XPending(key, group, "-", "+", 10, nil, 0); err != nil {
+		t.Fatal(err)
+	} else if len(ps) != 2 || ps[0].ID != (StreamID{1, 1}) || string(ps[0].Consumer) != "a" || ps[0].Deliveries != 1 {
+		t.Fatal(ps)
+	}
+
+	if ps, err := db.XPending(key, group, "-", "+", 10, []byte("b"), 0); err != nil {
+		t.Fatal(err)
+	} else if len(ps) != 0 {
+		t.Fatal(ps)
+	}
+
+	if ps, err := db.XPending(key, group, "-", "+", 10, nil, 60000); err != nil {
+		t.Fatal(err)
+	} else if len(ps) != 0 {
+		t.Fatal(ps)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// claimed one by one
+	next, claimed, deleted, err := db.XAutoClaim(key, group, []byte("b"), 10, "0", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	} else if next != (StreamID{2, 1}) || len(claimed) != 1 || claimed[0].ID != (StreamID{1, 1}) || len(deleted) != 0 {
+		t.Fatal(next, claimed, deleted)
+	}
+
+	if next, claimed, _, err = db.XAutoClaim(key, group, []byte("b"), 10, next.String(), 1, true); err != nil {
+		t.Fatal(err)
+	} else if next != streamIDMin || len(claimed) != 1 || claimed[0].Fields != nil {
+		t.Fatal(next, claimed)
+	}
+
+	if ps, err := db.XPending(key, group, "-", "+", 10, []byte("b"), 0); err != nil {
+		t.Fatal(err)
+	} else if len(ps) != 2 || ps[0].Deliveries != 2 || ps[1].Deliveries != 1 {
+		t.Fatal(ps)
+	}
+
+	if n, err := db.XAck(key, group, "1-1", "1-1", "3-1"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	// the groups are copied and deleted with the stream
+	dst := []byte("testdb_stream_group_copy")
+	db.XClear(dst)
+	defer db.XClear(dst)
+
+	if ok, err := db.Copy(key, dst, false, CopyOptions{}); err != nil || !ok {
+		t.Fatal(ok, err)
+	} else if s, err := db.XPendingSummary(dst, group); err != nil || s.Count != 1 {
+		t.Fatal(s, err)
+	}
+
+	if n, err := db.XGroupDestroy(dst, group); err != nil || n != 1 {
+		t.Fatal(n, err)
+	} else if _, err = db.XPendingSummary(dst, group); err != errStreamNoGroup {
+		t.Fatal(err)
+	}
+
+	if n, err := db.XClear(key); err != nil || n != 3 {
+		t.Fatal(n, err)
+	} else if err = db.XGroupCreate(key, group, "0", true); err != nil {
+		t.Fatal(err)
+	} else if s, err := db.XPendingSummary(key, group); err != nil || s.Count != 0 {
+		t.Fatal(s, err)
+	}
+}
@@ -0,0 +1,189 @@
+package ledis
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamCodec(t *testing.T) {
+	db := getTestDB()
+
+	id := StreamID{1526919030474, 55}
+	ek := db.xEncodeEntryKey([]byte("key"), id)
+	if k, i, err := db.xDecodeEntryKey(ek); err != nil {
+		t.Fatal(err)
+	} else if string(k) != "key" {
+		t.Fatal(string(k))
+	} else if i != id {
+		t.Fatal(i)
+	}
+
+	ek = db.xEncodeMetaKey([]byte("key"))
+	if k, err := db.xDecodeMetaKey(ek); err != nil {
+		t.Fatal(err)
+	} else if string(k) != "key" {
+		t.Fatal(string(k))
+	}
+
+	fields := []FVPair{{[]byte("a"), []byte("1")}, {[]byte("b"), []byte{}}}
+	if v, err := xDecodeFields(xEncodeFields(fields)); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || string(v[0].Field) != "a" || string(v[0].Value) != "1" ||
+		string(v[1].Field) != "b" || len(v[1].Value) != 0 {
+		t.Fatal(v)
+	}
+
+	if id, err := ParseStreamID("5", 7); err != nil {
+		t.Fatal(err)
+	} else if id != (StreamID{5, 7}) {
+		t.Fatal(id)
+	}
+
+	for _, s := range []string{"", "a-1", "1-", "1-a", "-1"} {
+		if _, err := ParseStreamID(s, 0); err == nil {
+			t.Fatal(s)
+		}
+	}
+}
+
+func TestDBStream(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_stream_a")
+	f := func(v string) FVPair {
+		return FVPair{Field: []byte("f"), Value: []byte(v)}
+	}
+
+	if n, err := db.XLen(key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if id, err := db.XAdd(key, "1-1", f("a")); err != nil {
+		t.Fatal(err)
+	} else if id != "1-1" {
+		t.Fatal(id)
+	}
+
+	if _, err := db.XAdd(key, "1-1", f("b")); err != errStreamIDSmall {
+		t.Fatal(err)
+	}
+
+	if _, err := db.XAdd(key, "0-0", f("b")); err != errStreamIDZero {
+		t.Fatal(err)
+	}
+
+	if _, err := db.XAdd(key, "*"); err != errStreamFields {
+		t.Fatal(err)
+	}
+
+	if id, err := db.XAdd(key, "1-*", f("b")); err != nil {
+		t.Fatal(err)
+	} else if id != "1-2" {
+		t.Fatal(id)
+	}
+
+	if id, err := db.XAdd(key, "3", f("c")); err != nil {
+		t.Fatal(err)
+	} else if id != "3-0" {
+		t.Fatal(id)
+	}
+
+	id, err := db.XAdd(key, "*", f("d"), FVPair{[]byte("g"), []byte("e")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := db.XLen(key); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatal(n)
+	}
+
+	if entries, err := db.XRange(key, "-", "+", 0); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 4 {
+		t.Fatal(len(entries))
+	} else if entries[3].ID.String() != id || len(entries[3].Fields) != 2 || string(entries[3].Fields[1].Value) != "e" {
+		t.Fatal(entries[3])
+	}
+
+	if entries, err := db.XRange(key, "1", "1", 0); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 || string(entries[1].Fields[0].Value) != "b" {
+		t.Fatal(entries)
+	}
+
+	if entries, err := db.XRevRange(key, "+", "-", 2); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 || entries[0].ID.String() != id || entries[1].ID.String() != "3-0" {
+		t.Fatal(entries)
+	}
+
+	if res, err := db.XRead([][]byte{key}, []string{"1-2"}, 1); err != nil {
+		t.Fatal(err)
+	} else if entries := res[string(key)]; len(entries) != 1 || entries[0].ID.String() != "3-0" {
+		t.Fatal(res)
+	}
+
+	if res, err := db.XRead([][]byte{key}, []string{"$"}, 0); err != nil {
+		t.Fatal(err)
+	} else if len(res) != 0 {
+		t.Fatal(res)
+	}
+
+	if _, err := db.XRead([][]byte{key}, []string{"0", "0"}, 0); err != errStreamKeyIDs {
+		t.Fatal(err)
+	}
+
+	if n, err := db.XClear(key); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.XKeyExists(key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestStreamBlock(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("test_xblock_key1")
+	key2 := []byte("test_xblock_key2")
+
+	db.XMClear(key1, key2)
+
+	if res, err := db.XReadBlock([][]byte{key1, key2}, []string{"$", "$"}, 0, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	} else if res != nil {
+		t.Fatal(res)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	f := func() {
+		defer wg.Done()
+
+		res, err := db.XReadBlock([][]byte{key1, key2}, []string{"$", "$"}, 0, 0)
+		if err != nil {
+			t.Error(err)
+		} else if len(res[string(key2)]) != 1 {
+			t.Error(res)
+		}
+	}
+
+	go f()
+	go f()
+
+	time.Sleep(10 * time.Millisecond)
+
+	db.XAdd(key2, "*", FVPair{[]byte("f"), []byte("v")})
+	wg.Wait()
+}
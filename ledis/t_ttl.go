@@ -3,17 +3,64 @@ package ledis
 import (
 	"encoding/binary"
 	"errors"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/siddontang/go/log"
 	"github.com/siddontang/ledisdb/store"
 )
 
 var (
 	errExpMetaKey = errors.New("invalid expire meta key")
 	errExpTimeKey = errors.New("invalid expire time key")
+
+	ErrExpireNX   = errors.New("NX and XX, GT or LT options at the same time are not compatible")
+	ErrExpireGTLT = errors.New("GT and LT options at the same time are not compatible")
+)
+
+// ExpireCond is a set of conditions on the current TTL for an expire time to
+// be set, like the options of the redis EXPIRE.
+type ExpireCond uint8
+
+const (
+	// ExpireNX sets the expire time only if the data has no TTL.
+	ExpireNX ExpireCond = 1 << iota
+	// ExpireXX sets the expire time only if the data has a TTL.
+	ExpireXX
+	// ExpireGT sets the expire time only if it is later than the current one,
+	// no TTL is the latest.
+	ExpireGT
+	// ExpireLT sets the expire time only if it is earlier than the current one.
+	ExpireLT
 )
 
+func (cond ExpireCond) check() error {
+	if cond&ExpireNX != 0 && cond != ExpireNX {
+		return ErrExpireNX
+	} else if cond&ExpireGT != 0 && cond&ExpireLT != 0 {
+		return ErrExpireGTLT
+	}
+	return nil
+}
+
+// holds reports whether the expire time when can replace the current one,
+// 0 if there's no TTL.
+func (cond ExpireCond) holds(current int64, when int64) bool {
+	switch {
+	case cond&ExpireNX != 0 && current > 0:
+		return false
+	case cond&ExpireXX != 0 && current == 0:
+		return false
+	case cond&ExpireGT != 0 && (current == 0 || when <= current):
+		return false
+	case cond&ExpireLT != 0 && current > 0 && when >= current:
+		return false
+	}
+	return true
+}
+
 type onExpired func(*batch, []byte) int64
 
 type ttlChecker struct {
@@ -87,10 +134,45 @@ func (db *DB) expDecodeTimeKey(tk []byte) (byte, []byte, int64, error) {
 	return tk[pos+9], tk[pos+10:], int64(binary.BigEndian.Uint64(tk[pos+1:])), nil
 }
 
-func (db *DB) expire(t *batch, dataType byte, key []byte, duration int64) {
-	db.expireAt(t, dataType, key, time.Now().Unix()+duration)
+/*
+	The expire time is saved in milliseconds. The old data saved it in seconds,
+	we tell them by the magnitude, any time less than minMsExpireTime is in seconds.
+*/
+const minMsExpireTime int64 = 1e11
+
+func expireTimeMs(when int64) int64 {
+	if when < minMsExpireTime {
+		return when * 1000
+	}
+	return when
+}
+
+func nowMs() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
 }
 
+// jitterDuration returns ttl * (1 + rand.Float64()*jitter) in milliseconds.
+func jitterDuration(ttl time.Duration, jitter float64) (int64, error) {
+	if jitter < 0 || math.IsNaN(jitter) || math.IsInf(jitter, 0) {
+		return 0, errExpireValue
+	}
+
+	d := float64(ttl/time.Millisecond) * (1 + rand.Float64()*jitter)
+	if d <= 0 || d >= math.MaxInt64 {
+		return 0, errExpireValue
+	}
+
+	return int64(d), nil
+}
+
+// JitterTTL returns ttl lengthened by a random fraction in [0, jitter) of
+// itself.
+func JitterTTL(ttl time.Duration, jitter float64) (time.Duration, error) {
+	d, err := jitterDuration(ttl, jitter)
+	return time.Duration(d) * time.Millisecond, err
+}
+
+// expireAt sets the expire time in milliseconds.
 func (db *DB) expireAt(t *batch, dataType byte, key []byte, when int64) {
 	mk := db.expEncodeMetaKey(dataType, key)
 	tk := db.expEncodeTimeKey(dataType, key, when)
@@ -101,13 +183,23 @@ func (db *DB) expireAt(t *batch, dataType byte, key []byte, when int64) {
 	db.ttlChecker.setNextCheckTime(when, false)
 }
 
+// ttl returns the TTL in seconds, rounded up.
 func (db *DB) ttl(dataType byte, key []byte) (t int64, err error) {
+	if t, err = db.pttl(dataType, key); t > 0 {
+		t = (t + 999) / 1000
+	}
+
+	return t, err
+}
+
+// pttl returns the TTL in milliseconds.
+func (db *DB) pttl(dataType byte, key []byte) (t int64, err error) {
 	mk := db.expEncodeMetaKey(dataType, key)
 
 	if t, err = Int64(db.bucket.Get(mk)); err != nil || t == 0 {
 		t = -1
 	} else {
-		t -= time.Now().Unix()
+		t = expireTimeMs(t) - nowMs()
 		if t <= 0 {
 			t = -1
 		}
@@ -117,6 +209,28 @@ func (db *DB) ttl(dataType byte, key []byte) (t int64, err error) {
 	return t, err
 }
 
+// pexpireTime returns the expire time in unix milliseconds, -1 if the data
+// has no TTL and -2 if it does not exist.
+func (db *DB) pexpireTime(dataType byte, key []byte) (int64, error) {
+	when, err := Int64(db.bucket.Get(db.expEncodeMetaKey(dataType, key)))
+	if err != nil {
+		return -1, err
+	} else if when > 0 {
+		if when = expireTimeMs(when); when <= nowMs() {
+			// expired but not removed yet
+			return -2, nil
+		}
+		return when, nil
+	}
+
+	if n, err := db.keyExists(dataType, key); err != nil {
+		return -1, err
+	} else if n == 0 {
+		return -2, nil
+	}
+	return -1, nil
+}
+
 func (db *DB) rmExpire(t *batch, dataType byte, key []byte) (int64, error) {
 	mk := db.expEncodeMetaKey(dataType, key)
 	v, err := db.bucket.Get(mk)
@@ -137,6 +251,178 @@ func (db *DB) rmExpire(t *batch, dataType byte, key []byte) (int64, error) {
 	return 1, nil
 }
 
+// ExpireAtIf sets the expire time of the dataType of key to when if the key
+// exists and cond holds, the check and the write are atomic. It returns
+// false if the expire time is not set.
+func (db *DB) ExpireAtIf(dataType DataType, key []byte, when time.Time, cond ExpireCond) (bool, error) {
+	if err := checkKeySize(key); err != nil {
+		return false, err
+	} else if err := cond.check(); err != nil {
+		return false, err
+	}
+
+	ms := when.UnixNano() / int64(time.Millisecond)
+	if ms <= nowMs() {
+		return false, errExpireValue
+	}
+
+	var storeType byte
+	switch dataType {
+	case KV:
+		storeType = KVType
+	case LIST:
+		storeType = ListType
+	case HASH:
+		storeType = HashType
+	case SET:
+		storeType = SetType
+	case ZSET:
+		storeType = ZSetType
+	case HLL:
+		storeType = HLLType
+	case STREAM:
+		storeType = StreamType
+	default:
+		return false, errDataType
+	}
+
+	t := db.ttlChecker.txs[storeType]
+	t.Lock()
+	defer t.Unlock()
+
+	if n, err := db.keyExists(storeType, key); err != nil || n == 0 {
+		return false, err
+	}
+
+	current, err := Int64(db.bucket.Get(db.expEncodeMetaKey(storeType, key)))
+	if err != nil {
+		return false, err
+	} else if current > 0 {
+		current = expireTimeMs(current)
+	}
+
+	if !cond.holds(current, ms) {
+		return false, nil
+	}
+
+	db.expireAt(t, storeType, key, ms)
+	if err := t.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// expireTypes are the data types which support TTL.
+var expireTypes = []byte{KVType, HashType, ListType, SetType, ZSetType, HLLType, StreamType}
+
+func (db *DB) keyExists(dataType byte, key []byte) (int64, error) {
+	switch dataType {
+	case KVType:
+		return db.Exists(key)
+	case HashType:
+		return db.HKeyExists(key)
+	case ListType:
+		return db.LKeyExists(key)
+	case SetType:
+		return db.SKeyExists(key)
+	case ZSetType:
+		return db.ZKeyExists(key)
+	case HLLType:
+		return db.HLLKeyExists(key)
+	case StreamType:
+		return db.XKeyExists(key)
+	default:
+		return 0, errExpType
+	}
+}
+
+// mExpireAt sets the expire time in milliseconds for all the data types of
+// every key in one batch, it blocks all the other writes.
+func (db *DB) mExpireAt(keys [][]byte, when int64) ([]bool, error) {
+	for _, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return nil, err
+		}
+	}
+
+	t := db.newExclusiveBatch()
+	t.Lock()
+	defer t.Unlock()
+
+	exists := make([]bool, len(keys))
+	for i, key := range keys {
+		for _, dataType := range expireTypes {
+			if n, err := db.keyExists(dataType, key); err != nil {
+				return nil, err
+			} else if n == 1 {
+				db.expireAt(t, dataType, key, when)
+				exists[i] = true
+			}
+		}
+	}
+
+	if err := t.Commit(); err != nil {
+		return nil, err
+	}
+
+	return exists, nil
+}
+
+// MExpire expires all the data types of the keys with ttl atomically,
+// the result tells which keys exist.
+func (db *DB) MExpire(keys [][]byte, ttl time.Duration) ([]bool, error) {
+	if ttl < time.Millisecond {
+		return nil, errExpireValue
+	}
+
+	return db.mExpireAt(keys, nowMs()+int64(ttl/time.Millisecond))
+}
+
+// PMExpire expires all the data types of the keys with duration in
+// milliseconds atomically, the result tells which keys exist.
+func (db *DB) PMExpire(keys [][]byte, duration int64) ([]bool, error) {
+	if duration <= 0 {
+		return nil, errExpireValue
+	}
+
+	return db.mExpireAt(keys, nowMs()+duration)
+}
+
+// MTTL returns the TTL in seconds of the keys, for a key of many data types,
+// the shortest one is used. It is -1 if the key has no TTL, -2 if the key
+// does not exist.
+func (db *DB) MTTL(keys [][]byte) ([]int64, error) {
+	ttls := make([]int64, len(keys))
+	for i, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return nil, err
+		}
+
+		ttls[i] = -2
+		for _, dataType := range expireTypes {
+			if n, err := db.keyExists(dataType, key); err != nil {
+				return nil, err
+			} else if n == 0 {
+				continue
+			}
+
+			t, err := db.ttl(dataType, key)
+			if err != nil {
+				return nil, err
+			}
+
+			if t > 0 && (ttls[i] < 0 || t < ttls[i]) {
+				ttls[i] = t
+			} else if ttls[i] == -2 {
+				ttls[i] = -1
+			}
+		}
+	}
+
+	return ttls, nil
+}
+
 func (c *ttlChecker) register(dataType byte, t *batch, f onExpired) {
 	c.txs[dataType] = t
 	c.cbs[dataType] = f
@@ -144,16 +430,31 @@ func (c *ttlChecker) register(dataType byte, t *batch, f onExpired) {
 
 func (c *ttlChecker) setNextCheckTime(when int64, force bool) {
 	c.Lock()
+	wake := false
 	if force {
 		c.nc = when
 	} else if c.nc > when {
 		c.nc = when
+		wake = true
 	}
 	c.Unlock()
+
+	if wake {
+		// let the checker reschedule for the earlier time
+		AsyncNotify(c.db.l.ttlWakeCh)
+	}
+}
+
+// nextCheckTime returns the next check time in milliseconds.
+func (c *ttlChecker) nextCheckTime() int64 {
+	c.Lock()
+	nc := c.nc
+	c.Unlock()
+	return nc
 }
 
 func (c *ttlChecker) check() {
-	now := time.Now().Unix()
+	now := nowMs()
 
 	c.Lock()
 	nc := c.nc
@@ -163,10 +464,9 @@ func (c *ttlChecker) check() {
 		return
 	}
 
-	nc = now + 3600
+	nc = now + 3600*1000
 
 	db := c.db
-	dbGet := db.bucket.Get
 
 	minKey := db.expEncodeTimeKey(NoneType, nil, 0)
 	maxKey := db.expEncodeTimeKey(maxDataType, nil, nc)
@@ -181,37 +481,247 @@ func (c *ttlChecker) check() {
 			continue
 		}
 
-		if nt > now {
+		if nt < minMsExpireTime {
+			// the old time in seconds, sorted before all the new ones
+			if nt = expireTimeMs(nt); nt > now {
+				if nt < nc {
+					nc = nt
+				}
+				continue
+			}
+		} else if nt > now {
 			//the next ttl check time is nt!
-			nc = nt
+			if nt < nc {
+				nc = nt
+			}
 			break
 		}
 
-		t := c.txs[dt]
-		cb := c.cbs[dt]
-		if tk == nil || cb == nil {
+		if tk == nil {
 			continue
 		}
 
-		t.Lock()
+		c.expire(dt, k, tk, mk, now)
+	}
+	it.Close()
+
+	c.setNextCheckTime(nc, true)
 
-		if exp, err := Int64(dbGet(mk)); err == nil {
-			// check expire again
-			if exp <= now {
-				cb(t, k)
-				t.Delete(tk)
-				t.Delete(mk)
+	return
+}
 
-				t.Commit()
-			}
+// expire deletes the data if it is expired at now, tk is the expire time key,
+// if nil, it is built from the saved expire time.
+func (c *ttlChecker) expire(dataType byte, key []byte, tk []byte, mk []byte, now int64) {
+	t := c.txs[dataType]
+	cb := c.cbs[dataType]
+	if cb == nil {
+		return
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	// check expire again
+	exp, err := Int64(c.db.bucket.Get(mk))
+	if err != nil || exp == 0 || expireTimeMs(exp) > now {
+		return
+	}
 
+	if tk == nil {
+		tk = c.db.expEncodeTimeKey(dataType, key, exp)
+	}
+
+	cb(t, key)
+	t.Delete(tk)
+	t.Delete(mk)
+
+	c.db.l.queueExpired(dataType, key)
+
+	t.delEvent = EventExpired
+	t.Commit()
+	t.delEvent = ""
+}
+
+type lazyExpireEvent struct {
+	db       *DB
+	dataType byte
+	key      []byte
+}
+
+// isExpired checks the TTL on read in the lazy expiry mode, the expired data
+// is hidden and deleted asynchronously.
+func (db *DB) isExpired(dataType byte, key []byte) bool {
+	// every read checks the expiry of the key first, so it is the access too
+	if !db.noTouch {
+		db.access.touch(key)
+	}
+
+	if !db.l.lazyExpiry {
+		return false
+	}
+
+	when, err := Int64(db.bucket.Get(db.expEncodeMetaKey(dataType, key)))
+	if err != nil || when == 0 || expireTimeMs(when) > nowMs() {
+		return false
+	}
+
+	// can not write, the deletion comes from the master by replication, and
+	// the writes of a Multi delete it themselves
+	if !db.l.IsReadOnly() && db.multi == nil {
+		select {
+		case db.l.lazyExpireCh <- lazyExpireEvent{db, dataType, append([]byte(nil), key...)}:
+		default:
+			// the data is deleted on next read
 		}
+	}
+
+	return true
+}
 
-		t.Unlock()
+// expireOnWrite deletes the expired data of keys in the lazy expiry mode, it
+// is called by the writes with t locked before they read the data. The reads
+// hide the expired data, so a write must start from a missing key too, not
+// from the expired data and its TTL. The deletion is committed first like
+// onLazyExpired does.
+func (db *DB) expireOnWrite(t *batch, dataType byte, keys ...[]byte) error {
+	if !db.l.lazyExpiry {
+		return nil
 	}
-	it.Close()
 
-	c.setNextCheckTime(nc, true)
+	now := nowMs()
+	n := 0
+	for _, key := range keys {
+		mk := db.expEncodeMetaKey(dataType, key)
+		when, err := Int64(db.bucket.Get(mk))
+		if err != nil {
+			return err
+		} else if when == 0 || expireTimeMs(when) > now {
+			continue
+		}
 
-	return
+		db.deleteData(t, dataType, key)
+		t.Delete(db.expEncodeTimeKey(dataType, key, when))
+		t.Delete(mk)
+
+		db.l.queueExpired(dataType, key)
+		n++
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	t.delEvent = EventExpired
+	err := t.Commit()
+	t.delEvent = ""
+	return err
+}
+
+// deleteData deletes the data of key of dataType in t without its TTL.
+func (db *DB) deleteData(t *batch, dataType byte, key []byte) int64 {
+	switch dataType {
+	case KVType:
+		return db.delete(t, key)
+	case ListType:
+		return db.lDelete(t, key)
+	case HashType:
+		return db.hDelete(t, key)
+	case SetType:
+		return db.sDelete(t, key)
+	case ZSetType:
+		return db.zDelete(t, key)
+	case HLLType:
+		return db.hllDelete(t, key)
+	case StreamType:
+		return db.xDelete(t, key)
+	default:
+		return 0
+	}
+}
+
+func (l *Ledis) onLazyExpired() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case e := <-l.lazyExpireCh:
+			mk := e.db.expEncodeMetaKey(e.dataType, e.key)
+			e.db.ttlChecker.expire(e.dataType, e.key, nil, mk, nowMs())
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// ExpireCallback is called when the ttl checker removes an expired key,
+// dataType is KVType, HashType, ListType, SetType, ZSetType, HLLType or StreamType.
+type ExpireCallback func(dataType byte, key []byte)
+
+type expireEvent struct {
+	dataType byte
+	key      []byte
+}
+
+const expireEventQueueSize = 1024
+
+type expireCallback struct {
+	cb ExpireCallback
+}
+
+// OnExpire registers the callback for the expired keys. The callbacks run in
+// a separate goroutine in registration order, so they never block the ttl
+// checker. If they can not keep up, the events are dropped with a warning.
+func (l *Ledis) OnExpire(cb ExpireCallback) CancelFunc {
+	e := &expireCallback{cb}
+
+	l.expireCbLock.Lock()
+	l.expireCbs = append(l.expireCbs, e)
+	l.expireCbLock.Unlock()
+
+	return func() {
+		l.expireCbLock.Lock()
+		for i, v := range l.expireCbs {
+			if v == e {
+				l.expireCbs = append(l.expireCbs[:i:i], l.expireCbs[i+1:]...)
+				break
+			}
+		}
+		l.expireCbLock.Unlock()
+	}
+}
+
+func (l *Ledis) queueExpired(dataType byte, key []byte) {
+	l.expireCbLock.Lock()
+	n := len(l.expireCbs)
+	l.expireCbLock.Unlock()
+
+	if n == 0 {
+		return
+	}
+
+	select {
+	case l.expireCh <- expireEvent{dataType, append([]byte(nil), key...)}:
+	default:
+		log.Warnf("expire callback queue is full, drop expired %s key %q", TypeName[dataType], key)
+	}
+}
+
+func (l *Ledis) onExpired() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case e := <-l.expireCh:
+			l.expireCbLock.Lock()
+			cbs := l.expireCbs
+			l.expireCbLock.Unlock()
+
+			for _, c := range cbs {
+				c.cb(e.dataType, e.key)
+			}
+		case <-l.quit:
+			return
+		}
+	}
 }
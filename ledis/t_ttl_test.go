@@ -2,11 +2,13 @@ package ledis
 
 import (
 	"fmt"
+	"os"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/siddontang/go/hack"
+	"github.com/siddontang/ledisdb/config"
 )
 
 var m sync.Mutex
@@ -20,6 +22,12 @@ type adaptor struct {
 	expireAt func([]byte, int64) (int64, error)
 	ttl      func([]byte) (int64, error)
 
+	pexpire   func([]byte, int64) (int64, error)
+	pexpireAt func([]byte, int64) (int64, error)
+	pttl      func([]byte) (int64, error)
+
+	expireWithJitter func([]byte, time.Duration, float64) (int64, error)
+
 	showIdent func() string
 }
 
@@ -38,6 +46,10 @@ func kvAdaptor(db *DB) *adaptor {
 	adp.expire = db.Expire
 	adp.expireAt = db.ExpireAt
 	adp.ttl = db.TTL
+	adp.pexpire = db.PExpire
+	adp.pexpireAt = db.PExpireAt
+	adp.expireWithJitter = db.ExpireWithJitter
+	adp.pttl = db.PTTL
 
 	return adp
 }
@@ -74,6 +86,10 @@ func listAdaptor(db *DB) *adaptor {
 	adp.expire = db.LExpire
 	adp.expireAt = db.LExpireAt
 	adp.ttl = db.LTTL
+	adp.pexpire = db.LPExpire
+	adp.pexpireAt = db.LPExpireAt
+	adp.expireWithJitter = db.LExpireWithJitter
+	adp.pttl = db.LPTTL
 
 	return adp
 }
@@ -112,6 +128,10 @@ func hashAdaptor(db *DB) *adaptor {
 	adp.expire = db.HExpire
 	adp.expireAt = db.HExpireAt
 	adp.ttl = db.HTTL
+	adp.pexpire = db.HPExpire
+	adp.pexpireAt = db.HPExpireAt
+	adp.expireWithJitter = db.HExpireWithJitter
+	adp.pttl = db.HPTTL
 
 	return adp
 }
@@ -151,6 +171,10 @@ func zsetAdaptor(db *DB) *adaptor {
 	adp.expire = db.ZExpire
 	adp.expireAt = db.ZExpireAt
 	adp.ttl = db.ZTTL
+	adp.pexpire = db.ZPExpire
+	adp.pexpireAt = db.ZPExpireAt
+	adp.expireWithJitter = db.ZExpireWithJitter
+	adp.pttl = db.ZPTTL
 
 	return adp
 }
@@ -187,9 +211,66 @@ func setAdaptor(db *DB) *adaptor {
 	adp.expire = db.SExpire
 	adp.expireAt = db.SExpireAt
 	adp.ttl = db.STTL
+	adp.pexpire = db.SPExpire
+	adp.pexpireAt = db.SPExpireAt
+	adp.expireWithJitter = db.SExpireWithJitter
+	adp.pttl = db.SPTTL
+
+	return adp
+
+}
+
+func hllAdaptor(db *DB) *adaptor {
+	adp := new(adaptor)
+	adp.showIdent = func() string {
+		return "hll-adaptor"
+	}
+
+	adp.set = func(k []byte, v []byte) (int64, error) {
+		return db.HLLAdd(k, v)
+	}
+
+	adp.exists = db.HLLKeyExists
+	adp.del = func(k []byte) (int64, error) {
+		return db.HLLDel(k)
+	}
+
+	adp.expire = db.HLLExpire
+	adp.expireAt = db.HLLExpireAt
+	adp.ttl = db.HLLTTL
+	adp.pexpire = db.HLLPExpire
+	adp.pexpireAt = db.HLLPExpireAt
+	adp.expireWithJitter = db.HLLExpireWithJitter
+	adp.pttl = db.HLLPTTL
 
 	return adp
+}
+
+func streamAdaptor(db *DB) *adaptor {
+	adp := new(adaptor)
+	adp.showIdent = func() string {
+		return "stream-adaptor"
+	}
+
+	adp.set = func(k []byte, v []byte) (int64, error) {
+		if _, err := db.XAdd(k, "*", FVPair{Field: []byte("f"), Value: v}); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	adp.exists = db.XKeyExists
+	adp.del = db.XClear
+
+	adp.expire = db.XExpire
+	adp.expireAt = db.XExpireAt
+	adp.ttl = db.XTTL
+	adp.pexpire = db.XPExpire
+	adp.pexpireAt = db.XPExpireAt
+	adp.expireWithJitter = db.XExpireWithJitter
+	adp.pttl = db.XPTTL
 
+	return adp
 }
 
 // func bitAdaptor(db *DB) *adaptor {
@@ -229,13 +310,15 @@ func setAdaptor(db *DB) *adaptor {
 // }
 
 func allAdaptors(db *DB) []*adaptor {
-	adps := make([]*adaptor, 5)
+	adps := make([]*adaptor, 7)
 	adps[0] = kvAdaptor(db)
 	adps[1] = listAdaptor(db)
 	adps[2] = hashAdaptor(db)
 	adps[3] = zsetAdaptor(db)
 	adps[4] = setAdaptor(db)
-	//adps[5] = bitAdaptor(db)
+	adps[5] = hllAdaptor(db)
+	adps[6] = streamAdaptor(db)
+	//adps[7] = bitAdaptor(db)
 	return adps
 }
 
@@ -462,3 +545,499 @@ func TestTTLCodec(t *testing.T) {
 	}
 
 }
+
+func TestPExpire(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	k := []byte("pttl_a")
+
+	dbEntries := allAdaptors(db)
+	for _, entry := range dbEntries {
+		ident := entry.showIdent()
+
+		entry.set(k, []byte("1"))
+
+		if ok, _ := entry.pexpire(k, 1500); ok != 1 {
+			t.Fatal(ident, ok)
+		}
+
+		if tRemain, _ := entry.pttl(k); tRemain <= 1000 || tRemain > 1500 {
+			t.Fatal(ident, tRemain)
+		}
+
+		if tRemain, _ := entry.ttl(k); tRemain != 2 {
+			t.Fatal(ident, tRemain)
+		}
+
+		if ok, err := entry.pexpireAt(k, nowMs()-1); err == nil || ok != 0 {
+			t.Fatal(ident, ok, err)
+		}
+
+		if ok, _ := entry.pexpireAt(k, nowMs()+200); ok != 1 {
+			t.Fatal(ident, ok)
+		}
+	}
+
+	// the ttl checker must not wait the whole check interval
+	time.Sleep(500 * time.Millisecond)
+
+	for _, entry := range dbEntries {
+		if n, _ := entry.exists(k); n != 0 {
+			t.Fatal(entry.showIdent(), "must be expired")
+		}
+	}
+}
+
+func TestTTLSecondsCompatibility(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	k := []byte("ttl_old")
+
+	db.Set(k, []byte("1"))
+
+	// the old data saved the expire time in seconds
+	when := time.Now().Unix() + 10
+
+	wb := db.kvBatch
+	wb.Lock()
+	mk := db.expEncodeMetaKey(KVType, k)
+	wb.Put(db.expEncodeTimeKey(KVType, k, when), mk)
+	wb.Put(mk, PutInt64(when))
+	wb.Commit()
+	wb.Unlock()
+
+	if tRemain, _ := db.TTL(k); tRemain <= 8 || tRemain > 10 {
+		t.Fatal(tRemain)
+	}
+
+	if tRemain, _ := db.PTTL(k); tRemain <= 8000 || tRemain > 10000 {
+		t.Fatal(tRemain)
+	}
+
+	if n, _ := db.Persist(k); n != 1 {
+		t.Fatal(n)
+	} else if tRemain, _ := db.TTL(k); tRemain != -1 {
+		t.Fatal(tRemain)
+	}
+
+	db.Del(k)
+}
+
+func TestOnExpire(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	k := []byte("ttl_on_expire")
+
+	ch := make(chan string, 4)
+	cancel1 := db.l.OnExpire(func(dataType byte, key []byte) {
+		if string(key) == string(k) {
+			ch <- fmt.Sprintf("1 %s", TypeName[dataType])
+		}
+	})
+	defer cancel1()
+
+	cancel2 := db.l.OnExpire(func(dataType byte, key []byte) {
+		if string(key) == string(k) {
+			ch <- fmt.Sprintf("2 %s", TypeName[dataType])
+		}
+	})
+
+	db.HSet(k, []byte("a"), []byte("1"))
+	db.HPExpire(k, 100)
+
+	for _, v := range []string{"1 hash", "2 hash"} {
+		select {
+		case s := <-ch:
+			if s != v {
+				t.Fatal(s, v)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("wait expire callback timeout")
+		}
+	}
+
+	cancel2()
+
+	db.Set(k, []byte("1"))
+	db.PExpire(k, 100)
+
+	select {
+	case s := <-ch:
+		if s != "1 kv" {
+			t.Fatal(s)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait expire callback timeout")
+	}
+
+	select {
+	case s := <-ch:
+		t.Fatal("canceled callback called", s)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestExpireWithJitter(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	k := []byte("jitter_a")
+
+	for _, entry := range allAdaptors(db) {
+		ident := entry.showIdent()
+
+		entry.set(k, []byte("1"))
+
+		if ok, _ := entry.expireWithJitter(k, 10*time.Second, 0.5); ok != 1 {
+			t.Fatal(ident, ok)
+		}
+
+		if tRemain, _ := entry.pttl(k); tRemain <= 9000 || tRemain > 15000 {
+			t.Fatal(ident, tRemain)
+		}
+
+		if ok, _ := entry.expireWithJitter(k, 10*time.Second, 0); ok != 1 {
+			t.Fatal(ident, ok)
+		}
+
+		if tRemain, _ := entry.pttl(k); tRemain <= 9000 || tRemain > 10000 {
+			t.Fatal(ident, tRemain)
+		}
+
+		if _, err := entry.expireWithJitter(k, 10*time.Second, -0.5); err == nil {
+			t.Fatal(ident, "negative jitter must fail")
+		}
+
+		if _, err := entry.expireWithJitter(k, 0, 0.5); err == nil {
+			t.Fatal(ident, "zero ttl must fail")
+		}
+
+		entry.del(k)
+	}
+}
+
+func TestMExpire(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	k1 := []byte("mexpire_a")
+	k2 := []byte("mexpire_b")
+	k3 := []byte("mexpire_c")
+
+	db.Set(k1, []byte("1"))
+	db.HSet(k1, []byte("f"), []byte("1"))
+	db.SAdd(k2, []byte("1"))
+
+	if ttls, err := db.MTTL([][]byte{k1, k2, k3}); err != nil {
+		t.Fatal(err)
+	} else if ttls[0] != -1 || ttls[1] != -1 || ttls[2] != -2 {
+		t.Fatal(ttls)
+	}
+
+	if exists, err := db.MExpire([][]byte{k1, k2, k3}, 10*time.Second); err != nil {
+		t.Fatal(err)
+	} else if !exists[0] || !exists[1] || exists[2] {
+		t.Fatal(exists)
+	}
+
+	if n, _ := db.HTTL(k1); n != 10 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.PMExpire([][]byte{k2}, 5000); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttls, err := db.MTTL([][]byte{k1, k2, k3}); err != nil {
+		t.Fatal(err)
+	} else if ttls[0] != 10 || ttls[1] != 5 || ttls[2] != -2 {
+		t.Fatal(ttls)
+	}
+
+	if _, err := db.MExpire([][]byte{k1}, 0); err == nil {
+		t.Fatal("must error")
+	}
+
+	db.Del(k1)
+	db.HClear(k1)
+	db.SClear(k2)
+}
+
+func openExpiryTestLedis(tb testing.TB, mode string) *Ledis {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_ledis_expiry_" + mode
+	cfg.ExpiryMode = mode
+	cfg.TTLCheckInterval = 3600
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return l
+}
+
+func TestLazyExpiry(t *testing.T) {
+	l := openExpiryTestLedis(t, config.ExpiryLazy)
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	k := []byte("lazy_expiry")
+	db.Set(k, []byte("1"))
+	db.HSet(k, []byte("a"), []byte("1"))
+	db.LPush(k, []byte("1"))
+	db.SAdd(k, []byte("1"))
+	db.ZAdd(k, ScorePair{1, []byte("1")})
+
+	for _, a := range allAdaptors(db) {
+		if _, err := a.pexpire(k, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	// no background checker, the data is still saved
+	if v, _ := db.bucket.Get(db.encodeKVKey(k)); v == nil {
+		t.Fatal("expired data must not be deleted before read")
+	}
+
+	if v, err := db.Get(k); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
+	if v, err := db.HGet(k, []byte("a")); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
+	if n, err := db.LLen(k); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.SCard(k); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.ZCard(k); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	// the read deletes the expired data asynchronously
+	for i := 0; ; i++ {
+		if v, _ := db.bucket.Get(db.encodeKVKey(k)); v == nil {
+			break
+		} else if i == 100 {
+			t.Fatal("expired data is not deleted after read")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n, err := db.Exists(k); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestLazyExpiryWrite(t *testing.T) {
+	l := openExpiryTestLedis(t, config.ExpiryLazy)
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	k1 := []byte("lazy_expiry_set")
+	k2 := []byte("lazy_expiry_incr")
+	k3 := []byte("lazy_expiry_hash")
+	db.Set(k1, []byte("old"))
+	db.Set(k2, []byte("10"))
+	db.HSet(k3, []byte("a"), []byte("1"))
+	db.PExpire(k1, 100)
+	db.PExpire(k2, 100)
+	db.HPExpire(k3, 100)
+
+	time.Sleep(200 * time.Millisecond)
+
+	// the writes start from a missing key, not the expired data
+	if err := db.Set(k1, []byte("new")); err != nil {
+		t.Fatal(err)
+	} else if v, err := db.Get(k1); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "new" {
+		t.Fatal(string(v))
+	} else if n, _ := db.PTTL(k1); n != -1 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.Incr(k2); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	} else if n, _ := db.PTTL(k2); n != -1 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.HSet(k3, []byte("b"), []byte("2")); err != nil {
+		t.Fatal(err)
+	} else if n, _ := db.HLen(k3); n != 1 {
+		t.Fatal(n)
+	} else if n, _ := db.HTTL(k3); n != -1 {
+		t.Fatal(n)
+	}
+
+	// the ttl of a lazily expired key is gone after the sets, SETEX has
+	// its own
+	for name, set := range kvTTLSetters {
+		k := []byte("lazy_expiry_" + name)
+		db.Set(k, []byte("old"))
+		db.PExpire(k, 100)
+		time.Sleep(200 * time.Millisecond)
+
+		ttl := int64(-1)
+		if name == "setex" {
+			ttl = 100
+		}
+		if err := set(db, k); err != nil {
+			t.Fatal(name, err)
+		} else if v, _ := db.Get(k); string(v) != "new" {
+			t.Fatal(name, string(v))
+		} else if n, _ := db.TTL(k); n != ttl {
+			t.Fatal(name, n)
+		}
+	}
+}
+
+// kvTTLSetters set the value of key to new.
+var kvTTLSetters = map[string]func(db *DB, key []byte) error{
+	"set": func(db *DB, key []byte) error {
+		return db.Set(key, []byte("new"))
+	},
+	"mset": func(db *DB, key []byte) error {
+		return db.MSet(KVPair{key, []byte("new")})
+	},
+	"getset": func(db *DB, key []byte) error {
+		_, err := db.GetSet(key, []byte("new"))
+		return err
+	},
+	"setex": func(db *DB, key []byte) error {
+		return db.SetEX(key, 100, []byte("new"))
+	},
+}
+
+func TestSetKeepTTL(t *testing.T) {
+	for _, mode := range []string{config.ExpiryEager, config.ExpiryLazy} {
+		l := openExpiryTestLedis(t, mode)
+		db, _ := l.Select(0)
+
+		// the sets keep the ttl of a live key, SETEX replaces it
+		for name, set := range kvTTLSetters {
+			k := []byte("keep_ttl_" + name)
+			db.Set(k, []byte("old"))
+			db.Expire(k, 1000)
+			if n, _ := db.TTL(k); n != 1000 {
+				t.Fatal(mode, name, n)
+			}
+
+			ttl := int64(1000)
+			if name == "setex" {
+				ttl = 100
+			}
+			if err := set(db, k); err != nil {
+				t.Fatal(mode, name, err)
+			} else if v, _ := db.Get(k); string(v) != "new" {
+				t.Fatal(mode, name, string(v))
+			} else if n, _ := db.TTL(k); n != ttl {
+				t.Fatal(mode, name, n)
+			}
+		}
+
+		l.Close()
+	}
+}
+
+func benchmarkExpiryGet(b *testing.B, mode string) {
+	l := openExpiryTestLedis(b, mode)
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	k := []byte("expiry_get")
+	db.Set(k, []byte("1"))
+	db.Expire(k, 3600)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Get(k)
+	}
+}
+
+func BenchmarkEagerExpiryGet(b *testing.B) {
+	benchmarkExpiryGet(b, config.ExpiryEager)
+}
+
+func BenchmarkLazyExpiryGet(b *testing.B) {
+	benchmarkExpiryGet(b, config.ExpiryLazy)
+}
+
+func TestExpireAtIf(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	k := []byte("expire_if_a")
+	db.Set(k, []byte("1"))
+	defer db.Del(k)
+
+	later := time.Now().Add(100 * time.Second)
+	earlier := time.Now().Add(50 * time.Second)
+
+	if ok, err := db.ExpireAtIf(KV, k, later, ExpireXX); err != nil || ok {
+		t.Fatal(ok, err)
+	} else if ok, err := db.ExpireAtIf(KV, k, later, ExpireGT); err != nil || ok {
+		t.Fatal(ok, err)
+	} else if ok, err := db.ExpireAtIf(KV, k, later, ExpireNX); err != nil || !ok {
+		t.Fatal(ok, err)
+	} else if ok, err := db.ExpireAtIf(KV, k, earlier, ExpireNX); err != nil || ok {
+		t.Fatal(ok, err)
+	} else if ok, err := db.ExpireAtIf(KV, k, earlier, ExpireGT); err != nil || ok {
+		t.Fatal(ok, err)
+	} else if ok, err := db.ExpireAtIf(KV, k, earlier, ExpireLT|ExpireXX); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+
+	if tRemain, _ := db.TTL(k); tRemain > 50 || tRemain < 49 {
+		t.Fatal(tRemain)
+	}
+
+	// the list of the same key has no data
+	if ok, err := db.ExpireAtIf(LIST, k, later, 0); err != nil || ok {
+		t.Fatal(ok, err)
+	}
+
+	if _, err := db.ExpireAtIf(KV, k, later, ExpireNX|ExpireGT); err != ErrExpireNX {
+		t.Fatal(err)
+	} else if _, err := db.ExpireAtIf(KV, k, later, ExpireGT|ExpireLT); err != ErrExpireGTLT {
+		t.Fatal(err)
+	} else if _, err := db.ExpireAtIf(KV, k, time.Now().Add(-time.Second), 0); err == nil {
+		t.Fatal("past time must fail")
+	}
+}
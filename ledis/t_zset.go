@@ -2,6 +2,7 @@ package ledis
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"time"
@@ -21,6 +22,15 @@ const (
 	AggregateMax byte = 2
 )
 
+// ZDir is the end of the zset ordered by score.
+type ZDir int8
+
+// For ZDir
+const (
+	ZDirMin ZDir = 0
+	ZDirMax ZDir = 1
+)
+
 // ScorePair is the pair of score and member.
 type ScorePair struct {
 	Score  int64
@@ -34,6 +44,8 @@ var errScoreOverflow = errors.New("zset score overflow")
 var errInvalidAggregate = errors.New("invalid aggregate")
 var errInvalidWeightNum = errors.New("invalid weight number")
 var errInvalidSrcKeyNum = errors.New("invalid src key number")
+var errZPopCount = errors.New("invalid zpop count")
+var errZDir = errors.New("invalid zset direction")
 
 const (
 	zsetNScoreSep    byte = '<'
@@ -323,6 +335,10 @@ func (db *DB) ZAdd(key []byte, args ...ScorePair) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	var num int64
 	for i := 0; i < len(args); i++ {
 		score := args[i].Score
@@ -344,8 +360,12 @@ func (db *DB) ZAdd(key []byte, args ...ScorePair) (int64, error) {
 		return 0, err
 	}
 
-	err := t.Commit()
-	return num, err
+	if err := t.Commit(); err != nil {
+		return 0, err
+	}
+
+	db.zbkeys.signal(key)
+	return num, nil
 }
 
 func (db *DB) zIncrSize(t *batch, key []byte, delta int64) (int64, error) {
@@ -373,6 +393,10 @@ func (db *DB) ZCard(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if db.isExpired(ZSetType, key) {
+		return 0, nil
+	}
+
 	sk := db.zEncodeSizeKey(key)
 	return Int64(db.bucket.Get(sk))
 }
@@ -383,6 +407,10 @@ func (db *DB) ZScore(key []byte, member []byte) (int64, error) {
 		return InvalidScore, err
 	}
 
+	if db.isExpired(ZSetType, key) {
+		return InvalidScore, ErrScoreMiss
+	}
+
 	score := InvalidScore
 
 	k := db.zEncodeSetKey(key, member)
@@ -409,6 +437,10 @@ func (db *DB) ZRem(key []byte, members ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	var num int64
 	for i := 0; i < len(members); i++ {
 		if err := checkZSetKMSize(key, members[i]); err != nil {
@@ -430,6 +462,186 @@ func (db *DB) ZRem(key []byte, members ...[]byte) (int64, error) {
 	return num, err
 }
 
+func (db *DB) zPop(key []byte, count int, reverse bool) ([]ScorePair, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	} else if count < 0 {
+		return nil, errZPopCount
+	} else if count == 0 {
+		return []ScorePair{}, nil
+	}
+
+	t := db.zsetBatch
+	t.Lock()
+	defer t.Unlock()
+
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return nil, err
+	}
+
+	v, err := db.zRange(key, MinScore, MaxScore, 0, count, reverse)
+	if err != nil || len(v) == 0 {
+		return v, err
+	}
+
+	for _, pair := range v {
+		if _, err := db.zDelItem(t, key, pair.Member, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := db.zIncrSize(t, key, -int64(len(v))); err != nil {
+		return nil, err
+	}
+
+	if err := t.Commit(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// ZPopMin removes and returns at most count members with the lowest scores,
+// ordered from the lowest.
+func (db *DB) ZPopMin(key []byte, count int) ([]ScorePair, error) {
+	return db.zPop(key, count, false)
+}
+
+// ZPopMax removes and returns at most count members with the highest scores,
+// ordered from the highest.
+func (db *DB) ZPopMax(key []byte, count int) ([]ScorePair, error) {
+	return db.zPop(key, count, true)
+}
+
+// BZPopMin pops the member with the lowest score from the first non empty
+// zset in block way, it returns key, member and score, or nil on timeout.
+func (db *DB) BZPopMin(keys [][]byte, timeout time.Duration) ([]interface{}, error) {
+	return db.zblockPop(keys, false, timeout)
+}
+
+// BZPopMax pops the member with the highest score from the first non empty
+// zset in block way, it returns key, member and score, or nil on timeout.
+func (db *DB) BZPopMax(keys [][]byte, timeout time.Duration) ([]interface{}, error) {
+	return db.zblockPop(keys, true, timeout)
+}
+
+func (db *DB) zblockPop(keys [][]byte, reverse bool, timeout time.Duration) ([]interface{}, error) {
+	for {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+
+		for _, key := range keys {
+			v, err := db.zPop(key, 1, reverse)
+			if err != nil {
+				cancel()
+				return nil, err
+			} else if len(v) > 0 {
+				cancel()
+				return []interface{}{key, v[0].Member, v[0].Score}, nil
+			}
+
+			db.zbkeys.wait(key, cancel)
+		}
+
+		//blocking wait
+		<-ctx.Done()
+		cancel()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil
+		}
+	}
+}
+
+// ZMPop pops at most count members from the dir end of the first non empty
+// zset in keys, it returns the key and the members, or nil if all are empty.
+func (db *DB) ZMPop(dir ZDir, count int, keys ...[]byte) ([]byte, []ScorePair, error) {
+	if dir != ZDirMin && dir != ZDirMax {
+		return nil, nil, errZDir
+	} else if count <= 0 {
+		return nil, nil, errZPopCount
+	}
+
+	for _, key := range keys {
+		if v, err := db.zPop(key, count, dir == ZDirMax); err != nil {
+			return nil, nil, err
+		} else if len(v) > 0 {
+			return key, v, nil
+		}
+	}
+
+	return nil, nil, nil
+}
+
+// BZMPop is like ZMPop, but waits until any zset is not empty or timeout,
+// timeout <= 0 means waiting forever.
+func (db *DB) BZMPop(dir ZDir, count int, timeout time.Duration, keys ...[]byte) ([]byte, []ScorePair, error) {
+	for {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+
+		// wait before popping so an add between them is not missed
+		for _, key := range keys {
+			db.zbkeys.wait(key, cancel)
+		}
+
+		key, v, err := db.ZMPop(dir, count, keys...)
+		if err != nil || key != nil {
+			cancel()
+			return key, v, err
+		}
+
+		//blocking wait
+		<-ctx.Done()
+		cancel()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, nil, nil
+		}
+	}
+}
+
+// ZRandMember returns count random members with their scores. If count > 0,
+// the members are distinct, otherwise -count members are returned with
+// repeats allowed.
+func (db *DB) ZRandMember(key []byte, count int) ([]ScorePair, error) {
+	size, err := db.ZCard(key)
+	if err != nil {
+		return nil, err
+	} else if size == 0 || count == 0 {
+		return []ScorePair{}, nil
+	}
+
+	it := db.bucket.RangeLimitIterator(db.zEncodeStartSetKey(key), db.zEncodeStopSetKey(key), store.RangeROpen, 0, -1)
+	entries := sampleRange(it, count, size)
+
+	v := make([]ScorePair, len(entries))
+	for i, e := range entries {
+		_, m, err := db.zDecodeSetKey(e.key)
+		if err != nil {
+			return nil, err
+		}
+
+		score, err := Int64(e.value, nil)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = ScorePair{Score: score, Member: m}
+	}
+
+	return v, nil
+}
+
 // ZIncrBy increases the score of member with delta.
 func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
 	if err := checkZSetKMSize(key, member); err != nil {
@@ -440,6 +652,10 @@ func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	ek := db.zEncodeSetKey(key, member)
 
 	var oldScore int64
@@ -469,8 +685,12 @@ func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
 		t.Delete(oldSk)
 	}
 
-	err = t.Commit()
-	return newScore, err
+	if err = t.Commit(); err != nil {
+		return InvalidScore, err
+	}
+
+	db.zbkeys.signal(key)
+	return newScore, nil
 }
 
 // ZCount gets the number of score in [min, max]
@@ -478,6 +698,9 @@ func (db *DB) ZCount(key []byte, min int64, max int64) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+	if db.isExpired(ZSetType, key) {
+		return 0, nil
+	}
 	minKey := db.zEncodeStartScoreKey(key, min)
 	maxKey := db.zEncodeStopScoreKey(key, max)
 
@@ -498,6 +721,10 @@ func (db *DB) zrank(key []byte, member []byte, reverse bool) (int64, error) {
 		return 0, err
 	}
 
+	if db.isExpired(ZSetType, key) {
+		return -1, nil
+	}
+
 	k := db.zEncodeSetKey(key, member)
 
 	it := db.bucket.NewIterator()
@@ -592,6 +819,10 @@ func (db *DB) zRange(key []byte, min int64, max int64, offset int, count int, re
 		return []ScorePair{}, nil
 	}
 
+	if db.isExpired(ZSetType, key) {
+		return []ScorePair{}, nil
+	}
+
 	nv := count
 	// count may be very large, so we must limit it for below mem make.
 	if nv <= 0 || nv > 1024 {
@@ -674,6 +905,10 @@ func (db *DB) ZClear(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	rmCnt, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1)
 	if err == nil {
 		err = t.Commit()
@@ -688,6 +923,10 @@ func (db *DB) ZMclear(keys ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, keys...); err != nil {
+		return 0, err
+	}
+
 	for _, key := range keys {
 		if _, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1); err != nil {
 			return 0, err
@@ -730,6 +969,10 @@ func (db *DB) ZRemRangeByRank(key []byte, start int, stop int) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	rmCnt, err = db.zRemRange(t, key, MinScore, MaxScore, offset, count)
 	if err == nil {
 		err = t.Commit()
@@ -744,6 +987,10 @@ func (db *DB) ZRemRangeByScore(key []byte, min int64, max int64) (int64, error)
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	rmCnt, err := db.zRemRange(t, key, min, max, 0, -1)
 	if err == nil {
 		err = t.Commit()
@@ -801,7 +1048,7 @@ func (db *DB) ZExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.zExpireAt(key, time.Now().Unix()+duration)
+	return db.zExpireAt(key, nowMs()+duration*1000)
 }
 
 // ZExpireAt expires the zset at when.
@@ -810,7 +1057,7 @@ func (db *DB) ZExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.zExpireAt(key, when)
+	return db.zExpireAt(key, when*1000)
 }
 
 // ZTTL gets the TTL of zset.
@@ -822,6 +1069,44 @@ func (db *DB) ZTTL(key []byte) (int64, error) {
 	return db.ttl(ZSetType, key)
 }
 
+// ZPExpire expires the zset with duration in milliseconds.
+func (db *DB) ZPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.zExpireAt(key, nowMs()+duration)
+}
+
+// ZPExpireAt expires the zset at when in milliseconds.
+func (db *DB) ZPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMs() {
+		return 0, errExpireValue
+	}
+
+	return db.zExpireAt(key, when)
+}
+
+// ZExpireWithJitter expires the zset with ttl lengthened by a random
+// fraction in [0, jitter) of itself, the resolved expire time is saved.
+func (db *DB) ZExpireWithJitter(key []byte, ttl time.Duration, jitter float64) (int64, error) {
+	duration, err := jitterDuration(ttl, jitter)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.zExpireAt(key, nowMs()+duration)
+}
+
+// ZPTTL gets the TTL of the zset in milliseconds.
+func (db *DB) ZPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(ZSetType, key)
+}
+
 // ZPersist removes the TTL of zset.
 func (db *DB) ZPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -832,6 +1117,10 @@ func (db *DB) ZPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, ZSetType, key)
 	if err != nil {
 		return 0, err
@@ -901,30 +1190,7 @@ func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 		}
 	}
 
-	t := db.zsetBatch
-	t.Lock()
-	defer t.Unlock()
-
-	db.zDelete(t, destKey)
-
-	for member, score := range destMap {
-		if err := checkZSetKMSize(destKey, []byte(member)); err != nil {
-			return 0, err
-		}
-
-		if _, err := db.zSetItem(t, destKey, score, []byte(member)); err != nil {
-			return 0, err
-		}
-	}
-
-	var n = int64(len(destMap))
-	sk := db.zEncodeSizeKey(destKey)
-	t.Put(sk, PutInt64(n))
-
-	if err := t.Commit(); err != nil {
-		return 0, err
-	}
-	return n, nil
+	return db.zStore(destKey, destMap)
 }
 
 // ZInterStore intersects the zsets and stores to dest zset.
@@ -971,11 +1237,83 @@ func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 		destMap = tmpMap
 	}
 
+	return db.zStore(destKey, destMap)
+}
+
+func (db *DB) zDiffGeneric(srcKeys [][]byte) ([]ScorePair, error) {
+	if len(srcKeys) < 1 {
+		return nil, errInvalidSrcKeyNum
+	}
+
+	scorePairs, err := db.ZRange(srcKeys[0], 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range srcKeys[1:] {
+		if len(scorePairs) == 0 {
+			break
+		}
+
+		members, err := db.ZRange(key, 0, -1)
+		if err != nil {
+			return nil, err
+		}
+
+		exclude := make(map[string]bool, len(members))
+		for _, pair := range members {
+			exclude[hack.String(pair.Member)] = true
+		}
+
+		n := 0
+		for _, pair := range scorePairs {
+			if !exclude[hack.String(pair.Member)] {
+				scorePairs[n] = pair
+				n++
+			}
+		}
+		scorePairs = scorePairs[:n]
+	}
+
+	return scorePairs, nil
+}
+
+// ZDiff returns the members of the first zset which are not in the others,
+// ordered by score.
+func (db *DB) ZDiff(srcKeys ...[]byte) ([]ScorePair, error) {
+	return db.zDiffGeneric(srcKeys)
+}
+
+// ZDiffStore stores the difference of the zsets to dest zset.
+func (db *DB) ZDiffStore(destKey []byte, srcKeys [][]byte) (int64, error) {
+	scorePairs, err := db.zDiffGeneric(srcKeys)
+	if err != nil {
+		return 0, err
+	}
+
+	destMap := make(map[string]int64, len(scorePairs))
+	for _, pair := range scorePairs {
+		destMap[hack.String(pair.Member)] = pair.Score
+	}
+
+	return db.zStore(destKey, destMap)
+}
+
+// zStore replaces dest zset with the members in one batch, the expire time
+// of dest is removed too.
+func (db *DB) zStore(destKey []byte, destMap map[string]int64) (int64, error) {
 	t := db.zsetBatch
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, destKey); err != nil {
+		return 0, err
+	}
+
 	db.zDelete(t, destKey)
+	if _, err := db.rmExpire(t, ZSetType, destKey); err != nil {
+		return 0, err
+	}
 
 	for member, score := range destMap {
 		if err := checkZSetKMSize(destKey, []byte(member)); err != nil {
@@ -988,16 +1326,27 @@ func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 
 	n := int64(len(destMap))
 	sk := db.zEncodeSizeKey(destKey)
-	t.Put(sk, PutInt64(n))
+	if n > 0 {
+		t.Put(sk, PutInt64(n))
+	} else {
+		t.Delete(sk)
+	}
 
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
+
+	if n > 0 {
+		db.zbkeys.signal(destKey)
+	}
 	return n, nil
 }
 
-// ZRangeByLex scans the zset lexicographically
-func (db *DB) ZRangeByLex(key []byte, min []byte, max []byte, rangeType uint8, offset int, count int) ([][]byte, error) {
+func (db *DB) zRangeByLex(key []byte, min []byte, max []byte, rangeType uint8, offset int, count int, reverse bool) ([]ScorePair, error) {
+	if db.isExpired(ZSetType, key) {
+		return []ScorePair{}, nil
+	}
+
 	if min == nil {
 		min = db.zEncodeStartSetKey(key)
 	} else {
@@ -1009,19 +1358,70 @@ func (db *DB) ZRangeByLex(key []byte, min []byte, max []byte, rangeType uint8, o
 		max = db.zEncodeSetKey(key, max)
 	}
 
-	it := db.bucket.RangeLimitIterator(min, max, rangeType, offset, count)
+	var it *store.RangeLimitIterator
+	if reverse {
+		it = db.bucket.RevRangeLimitIterator(min, max, rangeType, offset, count)
+	} else {
+		it = db.bucket.RangeLimitIterator(min, max, rangeType, offset, count)
+	}
 	defer it.Close()
 
-	ay := make([][]byte, 0, 16)
+	ay := make([]ScorePair, 0, 16)
 	for ; it.Valid(); it.Next() {
 		if _, m, err := db.zDecodeSetKey(it.Key()); err == nil {
-			ay = append(ay, m)
+			score, err := Int64(it.RawValue(), nil)
+			if err != nil {
+				return nil, err
+			}
+			ay = append(ay, ScorePair{Score: score, Member: m})
 		}
 	}
 
 	return ay, nil
 }
 
+func scorePairMembers(ay []ScorePair) [][]byte {
+	members := make([][]byte, len(ay))
+	for i, pair := range ay {
+		members[i] = pair.Member
+	}
+	return members
+}
+
+// ZRangeByLex scans the zset lexicographically
+func (db *DB) ZRangeByLex(key []byte, min []byte, max []byte, rangeType uint8, offset int, count int) ([][]byte, error) {
+	ay, err := db.zRangeByLex(key, min, max, rangeType, offset, count, false)
+	if err != nil {
+		return nil, err
+	}
+	return scorePairMembers(ay), nil
+}
+
+// ZRevRangeByLex scans the zset lexicographically in reverse order
+func (db *DB) ZRevRangeByLex(key []byte, min []byte, max []byte, rangeType uint8, offset int, count int) ([][]byte, error) {
+	ay, err := db.zRangeByLex(key, min, max, rangeType, offset, count, true)
+	if err != nil {
+		return nil, err
+	}
+	return scorePairMembers(ay), nil
+}
+
+// ZRangeByLexStore stores the members of src zset in [min, max]
+// lexicographically to dest zset, with their scores.
+func (db *DB) ZRangeByLexStore(destKey []byte, srcKey []byte, min []byte, max []byte, rangeType uint8, offset int, count int) (int64, error) {
+	ay, err := db.zRangeByLex(srcKey, min, max, rangeType, offset, count, false)
+	if err != nil {
+		return 0, err
+	}
+
+	destMap := make(map[string]int64, len(ay))
+	for _, pair := range ay {
+		destMap[hack.String(pair.Member)] = pair.Score
+	}
+
+	return db.zStore(destKey, destMap)
+}
+
 // ZRemRangeByLex remvoes members in [min, max] lexicographically
 func (db *DB) ZRemRangeByLex(key []byte, min []byte, max []byte, rangeType uint8) (int64, error) {
 	if min == nil {
@@ -1039,6 +1439,10 @@ func (db *DB) ZRemRangeByLex(key []byte, min []byte, max []byte, rangeType uint8
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.expireOnWrite(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	it := db.bucket.RangeIterator(min, max, rangeType)
 	defer it.Close()
 
@@ -1057,6 +1461,10 @@ func (db *DB) ZRemRangeByLex(key []byte, min []byte, max []byte, rangeType uint8
 
 // ZLexCount gets the count of zset lexicographically.
 func (db *DB) ZLexCount(key []byte, min []byte, max []byte, rangeType uint8) (int64, error) {
+	if db.isExpired(ZSetType, key) {
+		return 0, nil
+	}
+
 	if min == nil {
 		min = db.zEncodeStartSetKey(key)
 	} else {
@@ -1084,6 +1492,9 @@ func (db *DB) ZKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+	if db.isExpired(ZSetType, key) {
+		return 0, nil
+	}
 	sk := db.zEncodeSizeKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
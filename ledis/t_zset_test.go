@@ -3,7 +3,9 @@ package ledis
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/siddontang/ledisdb/store"
 )
@@ -381,6 +383,67 @@ func TestZInterStore(t *testing.T) {
 	}
 }
 
+func TestZDiffStore(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("testdb_zdiff_1")
+	key2 := []byte("testdb_zdiff_2")
+	key3 := []byte("testdb_zdiff_3")
+	out := []byte("testdb_zdiff_out")
+	db.ZMclear(key1, key2, key3, out)
+
+	db.ZAdd(key1, ScorePair{3, []byte("c")}, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")})
+	db.ZAdd(key2, ScorePair{5, []byte("b")}, ScorePair{6, []byte("d")})
+
+	if v, err := db.ZDiff(key1, key2, key3); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || string(v[0].Member) != "a" || v[1].Score != 3 {
+		t.Fatal(v)
+	}
+
+	if _, err := db.ZDiff(); err != errInvalidSrcKeyNum {
+		t.Fatal(err)
+	}
+
+	db.ZAdd(out, ScorePair{1, []byte("x")})
+	db.ZExpire(out, 100)
+
+	if n, err := db.ZDiffStore(out, [][]byte{key1, key2}); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if v, _ := db.ZScore(out, []byte("c")); v != 3 {
+		t.Fatal(v)
+	}
+	if n, _ := db.ZTTL(out); n != -1 {
+		t.Fatal(n)
+	}
+
+	// an empty result removes dest
+	if n, err := db.ZDiffStore(out, [][]byte{key3, key1}); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, _ := db.ZKeyExists(out); n != 0 {
+		t.Fatal(n)
+	}
+
+	// nil weights default to 1, duplicated members are aggregated
+	if n, err := db.ZUnionStore(out, [][]byte{key1, key2, key1}, nil, AggregateSum); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatal(n)
+	}
+
+	if v, _ := db.ZScore(out, []byte("b")); v != 9 {
+		t.Fatal(v)
+	}
+}
+
 func TestZScan(t *testing.T) {
 	db := getTestDB()
 	db.FlushAll()
@@ -447,6 +510,25 @@ func TestZLex(t *testing.T) {
 		t.Fatal("must equal b, c, d, e, f", fmt.Sprintf("%q", ay))
 	}
 
+	if ay, err := db.ZRevRangeByLex(key, []byte("b"), nil, store.RangeLOpen, 1, 2); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ay, [][]byte{[]byte("f"), []byte("e")}) {
+		t.Fatal("must equal f, e", fmt.Sprintf("%q", ay))
+	}
+
+	out := []byte("myzset_lex_out")
+	if n, err := db.ZRangeByLexStore(out, key, []byte("b"), []byte("d"), store.RangeClose, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	if ay, err := db.ZRange(out, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 3 || string(ay[0].Member) != "b" || ay[2].Score != 0 {
+		t.Fatal(ay)
+	}
+
 	if n, err := db.ZLexCount(key, nil, nil, store.RangeClose); err != nil {
 		t.Fatal(err)
 	} else if n != 7 {
@@ -484,3 +566,162 @@ func TestZKeyExists(t *testing.T) {
 		t.Fatal("invalid value ", n)
 	}
 }
+
+func TestZPop(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_zpop")
+	db.ZClear(key)
+
+	db.ZAdd(key, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")},
+		ScorePair{3, []byte("c")}, ScorePair{4, []byte("d")})
+
+	if v, err := db.ZPopMin(key, 2); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || string(v[0].Member) != "a" || v[1].Score != 2 {
+		t.Fatal(v)
+	}
+
+	if v, err := db.ZPopMax(key, 1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || string(v[0].Member) != "d" {
+		t.Fatal(v)
+	}
+
+	if n, _ := db.ZCard(key); n != 1 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.ZPopMin(key, -1); err != errZPopCount {
+		t.Fatal(err)
+	}
+
+	if v, err := db.ZPopMax(key, 10); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || string(v[0].Member) != "c" {
+		t.Fatal(v)
+	}
+
+	if n, _ := db.ZKeyExists(key); n != 0 {
+		t.Fatal(n)
+	}
+
+	if v, err := db.ZPopMin(key, 1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatal(v)
+	}
+}
+
+func TestZBlockPop(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("testdb_bzpop_1")
+	key2 := []byte("testdb_bzpop_2")
+	db.ZMclear(key1, key2)
+
+	if v, err := db.BZPopMin([][]byte{key1, key2}, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(v)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		v, err := db.BZPopMax([][]byte{key1, key2}, 0)
+		if err != nil {
+			t.Error(err)
+		} else if len(v) != 3 || string(v[0].([]byte)) != string(key2) || string(v[1].([]byte)) != "b" {
+			t.Error(v)
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	db.ZAdd(key2, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")})
+	wg.Wait()
+
+	if n, _ := db.ZCard(key2); n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestZRandMember(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("testdb_zrandmember")
+	db.ZClear(key)
+
+	for i := 0; i < 10; i++ {
+		db.ZAdd(key, ScorePair{int64(i), []byte(fmt.Sprintf("m%d", i))})
+	}
+
+	if v, err := db.ZRandMember(key, 3); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 {
+		t.Fatal(len(v))
+	} else if fmt.Sprintf("m%d", v[0].Score) != string(v[0].Member) {
+		t.Fatal(v[0])
+	}
+
+	v, err := db.ZRandMember(key, -10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for _, p := range v {
+		counts[string(p.Member)]++
+	}
+
+	if len(counts) != 10 {
+		t.Fatal(counts)
+	}
+	for m, n := range counts {
+		if n < 800 || n > 1200 {
+			t.Fatal(m, n, counts)
+		}
+	}
+
+	if v, err := db.ZRandMember(key, -20); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 20 {
+		t.Fatal(len(v))
+	}
+}
+
+func TestZMPop(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("testdb_zmpop_1")
+	key2 := []byte("testdb_zmpop_2")
+	db.ZMclear(key1, key2)
+
+	db.ZAdd(key2, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")}, ScorePair{3, []byte("c")})
+
+	if key, v, err := db.ZMPop(ZDirMax, 2, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if string(key) != string(key2) || len(v) != 2 || string(v[0].Member) != "c" {
+		t.Fatal(string(key), v)
+	}
+
+	if _, _, err := db.ZMPop(ZDir(3), 1, key1); err != errZDir {
+		t.Fatal(err)
+	}
+
+	if key, v, err := db.BZMPop(ZDirMin, 5, 10*time.Millisecond, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if string(key) != string(key2) || len(v) != 1 || string(v[0].Member) != "a" {
+		t.Fatal(string(key), v)
+	}
+
+	if key, _, err := db.BZMPop(ZDirMin, 1, 10*time.Millisecond, key1, key2); err != nil {
+		t.Fatal(err)
+	} else if key != nil {
+		t.Fatal(string(key))
+	}
+}
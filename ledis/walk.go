@@ -0,0 +1,173 @@
+package ledis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+var errSnapshotWrite = errors.New("write to a snapshot")
+
+// snapshotBucket is a read only bucket on a snapshot, the writes fail.
+type snapshotBucket struct {
+	*store.Snapshot
+}
+
+func (s snapshotBucket) Put(key []byte, value []byte) error {
+	return errSnapshotWrite
+}
+
+func (s snapshotBucket) Delete(key []byte) error {
+	return errSnapshotWrite
+}
+
+func (s snapshotBucket) NewWriteBatch() *store.WriteBatch {
+	return nil
+}
+
+func (s snapshotBucket) RangeIterator(min []byte, max []byte, rangeType uint8) *store.RangeLimitIterator {
+	return store.NewRangeIterator(s.NewIterator(), &store.Range{Min: min, Max: max, Type: rangeType})
+}
+
+func (s snapshotBucket) RevRangeIterator(min []byte, max []byte, rangeType uint8) *store.RangeLimitIterator {
+	return store.NewRevRangeIterator(s.NewIterator(), &store.Range{Min: min, Max: max, Type: rangeType})
+}
+
+func (s snapshotBucket) RangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *store.RangeLimitIterator {
+	return store.NewRangeLimitIterator(s.NewIterator(), &store.Range{Min: min, Max: max, Type: rangeType}, &store.Limit{Offset: offset, Count: count})
+}
+
+func (s snapshotBucket) RevRangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *store.RangeLimitIterator {
+	return store.NewRevRangeLimitIterator(s.NewIterator(), &store.Range{Min: min, Max: max, Type: rangeType}, &store.Limit{Offset: offset, Count: count})
+}
+
+// walkTypes are the data types walked by ObjectWalk with their meta types,
+// in the DBSize order.
+var walkTypes = []struct {
+	dataType byte
+	metaType byte
+}{
+	{KVType, KVType},
+	{ListType, LMetaType},
+	{HashType, HSizeType},
+	{ZSetType, ZSizeType},
+	{SetType, SSizeType},
+	{HLLType, HLLType},
+	{StreamType, StreamMetaType},
+}
+
+// ObjectWalk calls fn for every key of db with its redis type and encoding,
+// like TYPE and OBJECT ENCODING, the bytes of the values stored for it, and
+// its TTL in milliseconds or -1 if it has none. A key of more than one data
+// type is walked once for each.
+//
+// The keys are walked in a snapshot of the store, not with the cursors of
+// SCAN, so fn sees db at one point in time and may write to it. The walk
+// stops with the error of fn, or the error of ctx when it is done.
+//
+// The members of a set and zset are stored in the keys of the store, so the
+// size of a set is only its meta value, and of a zset its scores.
+func (db *DB) ObjectWalk(ctx context.Context, fn func(key []byte, typ string, encoding string, sizBytes int64, ttlMs int64) error) error {
+	snap, err := db.sdb.NewSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Close()
+
+	v := *db
+	v.bucket = snapshotBucket{snap}
+	v.noTouch = true
+
+	for _, t := range walkTypes {
+		if err := v.objectWalk(ctx, t.dataType, t.metaType, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) objectWalk(ctx context.Context, dataType byte, metaType byte, fn func([]byte, string, string, int64, int64) error) error {
+	minKey, maxKey, err := db.buildScanKeyRange(metaType, nil, false)
+	if err != nil {
+		return err
+	}
+
+	it := db.buildScanIterator(minKey, maxKey, false, false)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		key, err := db.decodeScanKey(metaType, it.Key())
+		if err != nil {
+			return err
+		}
+
+		encoding, err := db.objectEncoding(dataType, key)
+		if err != nil {
+			return err
+		}
+
+		size, err := db.valueSize(dataType, key)
+		if err != nil {
+			return err
+		}
+		size += int64(len(it.RawValue()))
+
+		ttl, err := db.pttl(dataType, key)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(key, redisTypeNames[dataType], encoding, size, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueSize returns the bytes of the values stored for key of dataType,
+// besides its meta value.
+func (db *DB) valueSize(dataType byte, key []byte) (int64, error) {
+	var min, max []byte
+	switch dataType {
+	case KVType, HLLType:
+		// the meta is the value
+		return 0, nil
+	case ListType:
+		headSeq, tailSeq, _, err := db.lGetMeta(nil, db.lEncodeMetaKey(key))
+		if err != nil {
+			return 0, err
+		}
+		min, max = db.lEncodeListKey(key, headSeq), store.PrefixEnd(db.lEncodeListKey(key, tailSeq))
+	case HashType:
+		v, err := db.bucket.Get(db.hEncodeZipKey(key))
+		if err != nil {
+			return 0, err
+		} else if v != nil {
+			return int64(len(v)), nil
+		}
+		min, max = db.hEncodeStartKey(key), db.hEncodeStopKey(key)
+	case SetType:
+		min, max = db.sEncodeStartKey(key), db.sEncodeStopKey(key)
+	case ZSetType:
+		min, max = db.zEncodeStartSetKey(key), db.zEncodeStopSetKey(key)
+	case StreamType:
+		prefix := db.xEncodeKeyPrefix(key)
+		min, max = prefix, store.PrefixEnd(prefix)
+	default:
+		return 0, errExpType
+	}
+
+	var n int64
+	_, err := db.rangeAll(min, max, func(ek []byte, v []byte) (bool, error) {
+		n += int64(len(v))
+		return true, nil
+	})
+	return n, err
+}
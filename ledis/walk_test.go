@@ -0,0 +1,73 @@
+package ledis
+
+import (
+	"context"
+	"testing"
+)
+
+func TestObjectWalk(t *testing.T) {
+	getTestDB()
+	db, _ := testLedis.Select(4)
+	db.FlushAll()
+	defer db.FlushAll()
+
+	db.Set([]byte("walk_kv"), []byte("12345"))
+	db.PExpire([]byte("walk_kv"), 60000)
+	db.RPush([]byte("walk_list"), []byte("a"), []byte("bc"))
+	db.HSet([]byte("walk_hash"), []byte("f"), []byte("value"))
+	db.SAdd([]byte("walk_set"), []byte("1"), []byte("2"))
+	db.ZAdd([]byte("walk_zset"), ScorePair{1, []byte("a")})
+
+	type object struct {
+		typ      string
+		encoding string
+		size     int64
+		ttl      int64
+	}
+
+	objs := make(map[string]object)
+	err := db.ObjectWalk(context.Background(), func(key []byte, typ string, encoding string, size int64, ttl int64) error {
+		if _, ok := objs[string(key)]; ok {
+			t.Fatal("walked twice", string(key))
+		}
+		objs[string(key)] = object{typ, encoding, size, ttl}
+
+		// the walk is in a snapshot
+		return db.Set([]byte("walk_new"), []byte("v"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(objs) != 5 {
+		t.Fatal(objs)
+	}
+
+	for key, typ := range map[string]string{"walk_kv": "string", "walk_list": "list", "walk_hash": "hash", "walk_set": "set", "walk_zset": "zset"} {
+		if o := objs[key]; o.typ != typ {
+			t.Fatal(key, o)
+		} else if enc, _ := db.ObjectEncoding([]byte(key)); o.encoding != enc {
+			t.Fatal(key, o, enc)
+		} else if o.size <= 0 {
+			t.Fatal(key, o)
+		} else if key != "walk_kv" && o.ttl != -1 {
+			t.Fatal(key, o)
+		}
+	}
+
+	if o := objs["walk_kv"]; o.size != 5 || o.ttl <= 0 || o.ttl > 60000 {
+		t.Fatal(o)
+	} else if o = objs["walk_list"]; o.size != 3+8 {
+		// the values and the head and tail seq of the meta
+		t.Fatal(o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	err = db.ObjectWalk(ctx, func(key []byte, typ string, encoding string, size int64, ttl int64) error {
+		n++
+		cancel()
+		return nil
+	})
+	if err != context.Canceled || n != 1 {
+		t.Fatal(err, n)
+	}
+}
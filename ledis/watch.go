@@ -0,0 +1,138 @@
+package ledis
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/ledisdb/store"
+)
+
+type watchKey struct {
+	index int
+	key   string
+}
+
+type watchEntry struct {
+	version uint64
+	refs    int
+}
+
+// watchManager keeps a version for every watched key, bumped on every write
+// to the key. Only the watched keys have a version, so a write to the other
+// keys costs nothing, and a version lives in memory only as long as a
+// connection which can be aborted by it.
+type watchManager struct {
+	m    sync.Mutex
+	keys map[watchKey]*watchEntry
+
+	n int32
+}
+
+func newWatchManager() *watchManager {
+	m := new(watchManager)
+	m.keys = make(map[watchKey]*watchEntry)
+	return m
+}
+
+func (m *watchManager) watched() bool {
+	return atomic.LoadInt32(&m.n) > 0
+}
+
+// touch bumps the versions of the watched keys written by the batch items.
+func (m *watchManager) touch(items []store.BatchItem) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	db := new(DB)
+	for _, item := range items {
+		index, _, _, key, err := decodeItemKey(db, item)
+		if err != nil {
+			continue
+		}
+
+		if e, ok := m.keys[watchKey{index, hack.String(key)}]; ok {
+			e.version++
+		}
+	}
+}
+
+// touchAll bumps the versions of all the watched keys.
+func (m *watchManager) touchAll() {
+	m.m.Lock()
+	for _, e := range m.keys {
+		e.version++
+	}
+	m.m.Unlock()
+}
+
+// Watch is a set of keys watched for the optimistic locking, like redis
+// WATCH. It must be closed after use.
+type Watch struct {
+	m *watchManager
+
+	keys     []watchKey
+	versions []uint64
+}
+
+// Watch starts watching keys, any later write to them changes the Watch.
+func (db *DB) Watch(keys ...[]byte) (*Watch, error) {
+	for _, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return nil, err
+		}
+	}
+
+	m := db.l.wm
+	w := &Watch{m: m}
+
+	m.m.Lock()
+	for _, key := range keys {
+		k := watchKey{db.Index(), string(key)}
+		e, ok := m.keys[k]
+		if !ok {
+			e = new(watchEntry)
+			m.keys[k] = e
+		}
+		e.refs++
+
+		w.keys = append(w.keys, k)
+		w.versions = append(w.versions, e.version)
+	}
+	atomic.StoreInt32(&m.n, int32(len(m.keys)))
+	m.m.Unlock()
+
+	return w, nil
+}
+
+// Changed reports whether any watched key is written after Watch. It is
+// only reliable with the write lock held, like in a Multi.
+func (w *Watch) Changed() bool {
+	w.m.m.Lock()
+	defer w.m.m.Unlock()
+
+	for i, k := range w.keys {
+		if w.m.keys[k].version != w.versions[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops watching the keys.
+func (w *Watch) Close() {
+	m := w.m
+
+	m.m.Lock()
+	for _, k := range w.keys {
+		e := m.keys[k]
+		if e.refs--; e.refs == 0 {
+			delete(m.keys, k)
+		}
+	}
+	atomic.StoreInt32(&m.n, int32(len(m.keys)))
+	m.m.Unlock()
+
+	w.keys = nil
+	w.versions = nil
+}
@@ -0,0 +1,66 @@
+package ledis
+
+import (
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	db := getTestDB()
+
+	key1 := []byte("test_watch_1")
+	key2 := []byte("test_watch_2")
+	db.Del(key1, key2)
+
+	w1, err := db.Watch(key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w2, err := db.Watch(key1, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w1.Changed() || w2.Changed() {
+		t.Fatal("must not change")
+	}
+
+	db.HSet(key2, []byte("f"), []byte("v"))
+	if w1.Changed() {
+		t.Fatal("must not change, key2 is not watched")
+	} else if !w2.Changed() {
+		t.Fatal("must change")
+	}
+
+	// a key watched twice stays watched until both close
+	w2.Close()
+	if !db.l.wm.watched() {
+		t.Fatal("key1 must be watched")
+	}
+
+	// the same key in another db is a different key
+	db1, _ := db.l.Select(1)
+	db1.Set(key1, []byte("v"))
+	if w1.Changed() {
+		t.Fatal("must not change")
+	}
+
+	db.Expire(key2, 10)
+	db.Set(key1, []byte("v"))
+	if !w1.Changed() {
+		t.Fatal("must change")
+	}
+
+	w1.Close()
+	if db.l.wm.watched() {
+		t.Fatal("no key must be watched")
+	}
+
+	w, _ := db.Watch(key1)
+	defer w.Close()
+
+	db.l.FlushAll()
+	if !w.Changed() {
+		t.Fatal("must change after flush all")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 
 	"github.com/edsrzf/mmap-go"
-	"github.com/siddontang/go/log"
 )
 
 //like leveldb or rocksdb file interface, haha!
@@ -288,12 +287,15 @@ type mmapReadFile struct {
 	f    *os.File
 	m    mmap.MMap
 	name string
+
+	logger Logger
 }
 
-func newMmapReadFile(name string) (readFile, error) {
+func newMmapReadFile(name string, logger Logger) (readFile, error) {
 	m := new(mmapReadFile)
 
 	m.name = name
+	m.logger = logger
 
 	var err error
 	m.f, err = os.Open(name)
@@ -321,14 +323,14 @@ func (m *mmapReadFile) ReadAt(buf []byte, offset int64) (int, error) {
 func (m *mmapReadFile) Close() error {
 	if m.m != nil {
 		if err := m.m.Unmap(); err != nil {
-			log.Errorf("unmap %s error %s", m.name, err.Error())
+			m.logger.Error("unmap log file", Field{"file", m.name}, EventType("close"), Err(err))
 		}
 		m.m = nil
 	}
 
 	if m.f != nil {
 		if err := m.f.Close(); err != nil {
-			log.Errorf("close %s error %s", m.name, err.Error())
+			m.logger.Error("close log file", Field{"file", m.name}, EventType("close"), Err(err))
 		}
 		m.f = nil
 	}
@@ -354,9 +356,9 @@ func newWriteFile(useMmap bool, name string, size int64) (writeFile, error) {
 	}
 }
 
-func newReadFile(useMmap bool, name string) (readFile, error) {
+func newReadFile(useMmap bool, name string, logger Logger) (readFile, error) {
 	if useMmap {
-		return newMmapReadFile(name)
+		return newMmapReadFile(name, logger)
 	} else {
 		return newRawReadFile(name)
 	}
@@ -8,7 +8,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/num"
 	"github.com/siddontang/ledisdb/config"
 )
@@ -58,13 +57,31 @@ type FileStore struct {
 	rs tableReaders
 	w  *tableWriter
 
+	// the logs the slaves still need, guarded by rm
+	slaveLogID SlaveLogIDFunc
+
+	logger Logger
+
 	quit chan struct{}
 }
 
-func NewFileStore(base string, cfg *config.Config) (*FileStore, error) {
+// SlaveLogIDFunc returns the lowest last log id of the connected slaves,
+// ok is false if no slave is connected.
+type SlaveLogIDFunc func() (id uint64, ok bool)
+
+// SetSlaveLogID sets the func of the slave log id, the tables with the logs
+// after it are not purged over the limits.
+func (s *FileStore) SetSlaveLogID(f SlaveLogIDFunc) {
+	s.rm.Lock()
+	s.slaveLogID = f
+	s.rm.Unlock()
+}
+
+func NewFileStore(base string, cfg *config.Config, logger Logger) (*FileStore, error) {
 	s := new(FileStore)
 
 	s.quit = make(chan struct{})
+	s.logger = logger
 
 	var err error
 
@@ -91,7 +108,7 @@ func NewFileStore(base string, cfg *config.Config) (*FileStore, error) {
 		index = s.rs[len(s.rs)-1].index + 1
 	}
 
-	s.w = newTableWriter(s.base, index, cfg.Replication.MaxLogFileSize, cfg.Replication.UseMmap)
+	s.w = newTableWriter(s.base, index, cfg.Replication.MaxLogFileSize, cfg.Replication.UseMmap, s.logger)
 	s.w.SetSyncType(cfg.Replication.SyncLog)
 
 	go s.checkTableReaders()
@@ -178,7 +195,7 @@ func (s *FileStore) storeLog(l *Log) error {
 	r, err = s.w.Flush()
 
 	if err != nil {
-		log.Fatalf("write table flush error %s, can not store!!!", err.Error())
+		s.logger.Error("flush table failed, can not store", FileIndex(s.w.index), LogPos(l.ID), EventType("flush"), Err(err))
 
 		s.w.Close()
 
@@ -194,7 +211,11 @@ func (s *FileStore) storeLog(l *Log) error {
 	return err
 }
 
+// PurgeExpired purges the tables of the logs older than n seconds, but keeps
+// the ones with the logs the slaves have not synced yet.
 func (s *FileStore) PurgeExpired(n int64) error {
+	slaveID, slaved := s.slaveSynced()
+
 	s.rm.Lock()
 
 	var purges []*tableReader
@@ -203,6 +224,9 @@ func (s *FileStore) PurgeExpired(n int64) error {
 
 	for i, r := range s.rs {
 		if r.lastTime > t {
+			if slaved {
+				i = s.slaveKept(i, slaveID, "expired")
+			}
 			purges = append([]*tableReader{}, s.rs[0:i]...)
 			n := copy(s.rs, s.rs[i:])
 			s.rs = s.rs[0:n]
@@ -246,7 +270,7 @@ func (s *FileStore) Clear() error {
 		return err
 	}
 
-	s.w = newTableWriter(s.base, 1, s.cfg.Replication.MaxLogFileSize, s.cfg.Replication.UseMmap)
+	s.w = newTableWriter(s.base, 1, s.cfg.Replication.MaxLogFileSize, s.cfg.Replication.UseMmap, s.logger)
 
 	return nil
 }
@@ -259,7 +283,7 @@ func (s *FileStore) Close() error {
 
 	if r, err := s.w.Flush(); err != nil {
 		if err != errNilHandler {
-			log.Errorf("close err: %s", err.Error())
+			s.logger.Error("flush table failed", FileIndex(s.w.index), EventType("close"), Err(err))
 		}
 	} else {
 		r.Close()
@@ -292,17 +316,9 @@ func (s *FileStore) checkTableReaders() {
 				}
 			}
 
-			purges := []*tableReader{}
-			maxNum := s.cfg.Replication.MaxLogFileNum
-			num := len(s.rs)
-			if num > maxNum {
-				purges = s.rs[:num-maxNum]
-				s.rs = s.rs[num-maxNum:]
-			}
-
 			s.rm.Unlock()
 
-			s.purgeTableReaders(purges)
+			s.purgeOverLimit()
 
 		case <-s.quit:
 			return
@@ -310,16 +326,79 @@ func (s *FileStore) checkTableReaders() {
 	}
 }
 
+// purgeOverLimit purges the oldest tables over max_log_file_num or max_log_total_size,
+// but keeps the ones with the logs the slaves have not synced yet.
+func (s *FileStore) purgeOverLimit() {
+	slaveID, slaved := s.slaveSynced()
+
+	s.rm.Lock()
+
+	n := 0
+	maxNum := s.cfg.Replication.MaxLogFileNum
+	if num := len(s.rs); num > maxNum {
+		n = num - maxNum
+	}
+
+	if maxSize := s.cfg.Replication.MaxLogTotalSize; maxSize > 0 {
+		var total int64
+		for i := len(s.rs) - 1; i >= n; i-- {
+			total += s.rs[i].Size()
+			if total > maxSize {
+				n = i + 1
+				break
+			}
+		}
+	}
+
+	if slaved {
+		n = s.slaveKept(n, slaveID, "over the limit")
+	}
+
+	purges := s.rs[:n]
+	s.rs = s.rs[n:]
+
+	s.rm.Unlock()
+
+	s.purgeTableReaders(purges)
+}
+
+// slaveSynced returns the lowest last log id of the connected slaves, it is
+// called out of rm, the func may wait for the lock of the slaves.
+func (s *FileStore) slaveSynced() (uint64, bool) {
+	s.rm.RLock()
+	f := s.slaveLogID
+	s.rm.RUnlock()
+
+	if f == nil {
+		return 0, false
+	}
+	return f()
+}
+
+// slaveKept returns how many of the first n tables to purge, the ones with
+// the logs after slaveID are kept. It must be called with rm held.
+func (s *FileStore) slaveKept(n int, slaveID uint64, reason string) int {
+	purge := n
+	for purge > 0 && s.rs[purge-1].last > slaveID {
+		purge--
+	}
+	if purge < n {
+		s.logger.Info("keep the tables a slave has not synced", Field{"tables", n - purge}, Field{"reason", reason}, LogPos(slaveID), EventType("purge"))
+	}
+	return purge
+}
+
 func (s *FileStore) purgeTableReaders(purges []*tableReader) {
 	for _, r := range purges {
 		dataName := fmtTableDataName(r.base, r.index)
 		metaName := fmtTableMetaName(r.base, r.index)
 		r.Close()
+		s.logger.Info("purge table", FileIndex(r.index), Field{"first_log_pos", r.first}, LogPos(r.last), EventType("purge"))
 		if err := os.Remove(dataName); err != nil {
-			log.Errorf("purge table data %s err: %s", dataName, err.Error())
+			s.logger.Error("purge table data failed", FileIndex(r.index), EventType("purge"), Err(err))
 		}
 		if err := os.Remove(metaName); err != nil {
-			log.Errorf("purge table meta %s err: %s", metaName, err.Error())
+			s.logger.Error("purge table meta failed", FileIndex(r.index), EventType("purge"), Err(err))
 		}
 
 	}
@@ -337,8 +416,8 @@ func (s *FileStore) load() error {
 	var index int64
 	for _, f := range fs {
 		if _, err := fmt.Sscanf(f.Name(), "%08d.data", &index); err == nil {
-			if r, err = newTableReader(s.base, index, s.cfg.Replication.UseMmap); err != nil {
-				log.Errorf("load table %s err: %s", f.Name(), err.Error())
+			if r, err = newTableReader(s.base, index, s.cfg.Replication.UseMmap, s.logger); err != nil {
+				s.logger.Error("load table failed", FileIndex(index), EventType("load"), Err(err))
 			} else {
 				s.rs = append(s.rs, r)
 			}
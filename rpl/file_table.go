@@ -6,11 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path"
 	"sync"
 	"time"
 
-	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/sync2"
 )
 
@@ -47,9 +47,11 @@ type tableReader struct {
 	lastReadTime sync2.AtomicInt64
 
 	useMmap bool
+
+	logger Logger
 }
 
-func newTableReader(base string, index int64, useMmap bool) (*tableReader, error) {
+func newTableReader(base string, index int64, useMmap bool, logger Logger) (*tableReader, error) {
 	if index <= 0 {
 		return nil, fmt.Errorf("invalid index %d", index)
 	}
@@ -58,14 +60,15 @@ func newTableReader(base string, index int64, useMmap bool) (*tableReader, error
 	t.index = index
 
 	t.useMmap = useMmap
+	t.logger = logger
 
 	var err error
 
 	if err = t.check(); err != nil {
-		log.Errorf("check %d error: %s, try to repair", t.index, err.Error())
+		t.logger.Error("check table failed, try to repair", FileIndex(t.index), EventType("check"), Err(err))
 
 		if err = t.repair(); err != nil {
-			log.Errorf("repair %d error: %s", t.index, err.Error())
+			t.logger.Error("repair table failed", FileIndex(t.index), EventType("repair"), Err(err))
 			return nil, err
 		}
 	}
@@ -99,6 +102,18 @@ func (t *tableReader) close() {
 	}
 }
 
+// Size returns the disk size of the table data and meta files.
+func (t *tableReader) Size() int64 {
+	var n int64
+	if st, err := os.Stat(fmtTableDataName(t.base, t.index)); err == nil {
+		n += st.Size()
+	}
+	if st, err := os.Stat(fmtTableMetaName(t.base, t.index)); err == nil {
+		n += st.Size()
+	}
+	return n
+}
+
 func (t *tableReader) Keepalived() bool {
 	l := t.lastReadTime.Get()
 	if l > 0 && time.Now().Unix()-l > tableReaderKeepaliveInterval {
@@ -120,7 +135,7 @@ func (t *tableReader) getLogPos(index int) (uint32, error) {
 func (t *tableReader) checkData() error {
 	var err error
 	//check will use raw file mode
-	if t.data, err = newReadFile(false, fmtTableDataName(t.base, t.index)); err != nil {
+	if t.data, err = newReadFile(false, fmtTableDataName(t.base, t.index), t.logger); err != nil {
 		return err
 	}
 
@@ -143,7 +158,7 @@ func (t *tableReader) checkData() error {
 func (t *tableReader) checkMeta() error {
 	var err error
 	//check will use raw file mode
-	if t.meta, err = newReadFile(false, fmtTableMetaName(t.base, t.index)); err != nil {
+	if t.meta, err = newReadFile(false, fmtTableMetaName(t.base, t.index), t.logger); err != nil {
 		return err
 	}
 
@@ -222,12 +237,12 @@ func (t *tableReader) repair() error {
 		nextPos, err = t.decodeLogHead(&l, data, pos)
 		if err != nil {
 			//if error, we may lost all logs from pos
-			log.Errorf("%s may lost logs from %d", data.Name(), pos)
+			t.logger.Error("table may lose the logs from the offset", FileIndex(t.index), Field{"offset", pos}, EventType("repair"), Err(err))
 			break
 		}
 
 		if l.ID == 0 {
-			log.Errorf("%s may lost logs from %d, invalid log 0", data.Name(), pos)
+			t.logger.Error("table may lose the logs from the offset, invalid log 0", FileIndex(t.index), Field{"offset", pos}, EventType("repair"))
 			break
 		}
 
@@ -238,7 +253,7 @@ func (t *tableReader) repair() error {
 		if t.last == 0 {
 			t.last = l.ID
 		} else if l.ID <= t.last {
-			log.Errorf("%s may lost logs from %d, invalid logid %d", t.data.Name(), pos, l.ID)
+			t.logger.Error("table may lose the logs from the offset, invalid log id", FileIndex(t.index), Field{"offset", pos}, LogPos(l.ID), EventType("repair"))
 			break
 		}
 
@@ -261,7 +276,7 @@ func (t *tableReader) repair() error {
 	data.SetOffset(pos)
 
 	if _, err = data.Write(magic); err != nil {
-		log.Errorf("write magic error %s", err.Error())
+		t.logger.Error("write magic failed", FileIndex(t.index), EventType("repair"), Err(err))
 	}
 
 	if err = data.Close(); err != nil {
@@ -277,7 +292,7 @@ func (t *tableReader) decodeLogHead(l *Log, r io.ReaderAt, pos int64) (int64, er
 		return 0, err
 	}
 
-	return pos + int64(l.HeadSize()) + int64(dataLen), nil
+	return pos + int64(l.HeadSize()) + int64(dataLen) + int64(l.checksumSize()), nil
 }
 
 func (t *tableReader) GetLog(id uint64, l *Log) error {
@@ -313,13 +328,13 @@ func (t *tableReader) GetLog(id uint64, l *Log) error {
 func (t *tableReader) openTable() error {
 	var err error
 	if t.data == nil {
-		if t.data, err = newReadFile(t.useMmap, fmtTableDataName(t.base, t.index)); err != nil {
+		if t.data, err = newReadFile(t.useMmap, fmtTableDataName(t.base, t.index), t.logger); err != nil {
 			return err
 		}
 	}
 
 	if t.meta == nil {
-		if t.meta, err = newReadFile(t.useMmap, fmtTableMetaName(t.base, t.index)); err != nil {
+		if t.meta, err = newReadFile(t.useMmap, fmtTableMetaName(t.base, t.index), t.logger); err != nil {
 			return err
 		}
 
@@ -350,9 +365,11 @@ type tableWriter struct {
 	posBuf []byte
 
 	useMmap bool
+
+	logger Logger
 }
 
-func newTableWriter(base string, index int64, maxLogSize int64, useMmap bool) *tableWriter {
+func newTableWriter(base string, index int64, maxLogSize int64, useMmap bool, logger Logger) *tableWriter {
 	if index <= 0 {
 		panic(fmt.Errorf("invalid index %d", index))
 	}
@@ -369,6 +386,7 @@ func newTableWriter(base string, index int64, maxLogSize int64, useMmap bool) *t
 	t.posBuf = make([]byte, 4)
 
 	t.useMmap = useMmap
+	t.logger = logger
 
 	return t
 }
@@ -388,18 +406,18 @@ func (t *tableWriter) SetSyncType(tp int) {
 func (t *tableWriter) close() {
 	if t.meta != nil {
 		if err := t.meta.Close(); err != nil {
-			log.Fatalf("close log meta error %s", err.Error())
+			t.logger.Error("close log meta failed", FileIndex(t.index), EventType("close"), Err(err))
 		}
 		t.meta = nil
 	}
 
 	if t.data != nil {
 		if _, err := t.data.Write(magic); err != nil {
-			log.Fatalf("write magic error %s", err.Error())
+			t.logger.Error("write magic failed", FileIndex(t.index), EventType("close"), Err(err))
 		}
 
 		if err := t.data.Close(); err != nil {
-			log.Fatalf("close log data error %s", err.Error())
+			t.logger.Error("close log data failed", FileIndex(t.index), EventType("close"), Err(err))
 		}
 		t.data = nil
 	}
@@ -443,6 +461,7 @@ func (t *tableWriter) Flush() (*tableReader, error) {
 	tr.last = t.last
 	tr.lastTime = t.lastTime
 	tr.useMmap = t.useMmap
+	tr.logger = t.logger
 
 	t.close()
 
@@ -520,7 +539,7 @@ func (t *tableWriter) storeLog(l *Log) error {
 
 	if t.syncType == 2 {
 		if err := t.data.Sync(); err != nil {
-			log.Errorf("sync table error %s", err.Error())
+			t.logger.Error("sync table failed", FileIndex(t.index), LogPos(l.ID), EventType("sync"), Err(err))
 		}
 	}
 
@@ -31,7 +31,7 @@ func testFileTable(t *testing.T, useMmap bool) {
 	l.Compression = 0
 	l.Data = make([]byte, 4096)
 
-	w := newTableWriter(base, 1, 1024*1024, useMmap)
+	w := newTableWriter(base, 1, 1024*1024, useMmap, NopLogger{})
 	defer w.Close()
 
 	for i := 0; i < 10; i++ {
@@ -115,7 +115,7 @@ func testFileTable(t *testing.T, useMmap bool) {
 
 	r.Close()
 
-	if r, err = newTableReader(base, 1, useMmap); err != nil {
+	if r, err = newTableReader(base, 1, useMmap, NopLogger{}); err != nil {
 		t.Fatal(err)
 	}
 	defer r.Close()
@@ -153,7 +153,7 @@ func testFileTable(t *testing.T, useMmap bool) {
 		r.Close()
 	}
 
-	if r, err = newTableReader(base, 2, useMmap); err != nil {
+	if r, err = newTableReader(base, 2, useMmap, NopLogger{}); err != nil {
 		t.Fatal(err)
 	}
 	r.Close()
@@ -167,7 +167,7 @@ func testRepair(t *testing.T, name string, index int64, s int64, cutSize int64,
 		t.Fatal(err)
 	}
 
-	if r, err = newTableReader(path.Dir(name), index, useMmap); err != nil {
+	if r, err = newTableReader(path.Dir(name), index, useMmap, NopLogger{}); err != nil {
 		t.Fatal(err)
 	}
 	defer r.Close()
@@ -3,17 +3,30 @@ package rpl
 import (
 	"bytes"
 	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"sync"
 )
 
 const LogHeadSize = 17
 
+// the high bit of the compression byte marks a trailing crc32c checksum,
+// logs written without checksum keep the old format.
+const (
+	logChecksumFlag uint8 = 0x80
+	logChecksumSize       = 4
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
 type Log struct {
 	ID          uint64
 	CreateTime  uint32
 	Compression uint8
 
+	// Checksum appends a crc32c of the log after data when encoding
+	Checksum bool
+
 	Data []byte
 }
 
@@ -22,7 +35,14 @@ func (l *Log) HeadSize() int {
 }
 
 func (l *Log) Size() int {
-	return l.HeadSize() + len(l.Data)
+	return l.HeadSize() + len(l.Data) + l.checksumSize()
+}
+
+func (l *Log) checksumSize() int {
+	if l.Checksum {
+		return logChecksumSize
+	}
+	return 0
 }
 
 func (l *Log) Marshal() ([]byte, error) {
@@ -46,8 +66,7 @@ var headPool = sync.Pool{
 	New: func() interface{} { return make([]byte, LogHeadSize) },
 }
 
-func (l *Log) Encode(w io.Writer) error {
-	b := headPool.Get().([]byte)
+func (l *Log) encodeHeadBuf(b []byte) {
 	pos := 0
 
 	binary.BigEndian.PutUint64(b[pos:], l.ID)
@@ -55,8 +74,26 @@ func (l *Log) Encode(w io.Writer) error {
 	binary.BigEndian.PutUint32(b[pos:], uint32(l.CreateTime))
 	pos += 4
 	b[pos] = l.Compression
+	if l.Checksum {
+		b[pos] |= logChecksumFlag
+	}
 	pos++
 	binary.BigEndian.PutUint32(b[pos:], uint32(len(l.Data)))
+}
+
+// crc32c of the head and data
+func (l *Log) sum() uint32 {
+	var b [LogHeadSize]byte
+	l.encodeHeadBuf(b[:])
+
+	crc := crc32.Update(0, crc32cTable, b[:])
+	return crc32.Update(crc, crc32cTable, l.Data)
+}
+
+func (l *Log) Encode(w io.Writer) error {
+	b := headPool.Get().([]byte)
+
+	l.encodeHeadBuf(b)
 
 	n, err := w.Write(b)
 	headPool.Put(b)
@@ -72,6 +109,17 @@ func (l *Log) Encode(w io.Writer) error {
 	} else if n != len(l.Data) {
 		return io.ErrShortWrite
 	}
+
+	if l.Checksum {
+		var sb [logChecksumSize]byte
+		binary.BigEndian.PutUint32(sb[:], l.sum())
+		if n, err = w.Write(sb[:]); err != nil {
+			return err
+		} else if n != logChecksumSize {
+			return io.ErrShortWrite
+		}
+	}
+
 	return nil
 }
 
@@ -87,6 +135,15 @@ func (l *Log) Decode(r io.Reader) error {
 		return err
 	}
 
+	if l.Checksum {
+		var sb [logChecksumSize]byte
+		if _, err := io.ReadFull(r, sb[:]); err != nil {
+			return err
+		}
+
+		return l.checkSum(sb[:])
+	}
+
 	return nil
 }
 
@@ -118,7 +175,28 @@ func (l *Log) DecodeAt(r io.ReaderAt, pos int64) error {
 		err = nil
 	}
 
-	return err
+	if err != nil || !l.Checksum {
+		return err
+	}
+
+	var sb [logChecksumSize]byte
+	n, err = r.ReadAt(sb[:], pos+int64(LogHeadSize)+int64(length))
+	if err == io.EOF && n == logChecksumSize {
+		err = nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return l.checkSum(sb[:])
+}
+
+func (l *Log) checkSum(b []byte) error {
+	if binary.BigEndian.Uint32(b) != l.sum() {
+		return ErrChecksumMismatch
+	}
+	return nil
 }
 
 func (l *Log) growData(length int) {
@@ -159,7 +237,8 @@ func (l *Log) decodeHeadBuf(buf []byte) uint32 {
 	l.CreateTime = binary.BigEndian.Uint32(buf[pos:])
 	pos += 4
 
-	l.Compression = uint8(buf[pos])
+	l.Compression = uint8(buf[pos]) &^ logChecksumFlag
+	l.Checksum = uint8(buf[pos])&logChecksumFlag != 0
 	pos++
 
 	length := binary.BigEndian.Uint32(buf[pos:])
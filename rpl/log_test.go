@@ -37,3 +37,37 @@ func TestLog(t *testing.T) {
 		t.Fatal("must equal")
 	}
 }
+
+func TestLogChecksum(t *testing.T) {
+	l1 := &Log{ID: 1, CreateTime: 100, Checksum: true, Data: []byte("hello world")}
+
+	buf, err := l1.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(buf) != l1.Size() {
+		t.Fatal(len(buf), l1.Size())
+	}
+
+	l2 := &Log{}
+	if err = l2.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(l1, l2) {
+		t.Fatal("must equal")
+	}
+
+	l3 := &Log{}
+	if err = l3.DecodeAt(bytes.NewReader(buf), 0); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(l1, l3) {
+		t.Fatal("must equal")
+	}
+
+	buf[LogHeadSize] ^= 0xFF
+	if err = l2.Unmarshal(buf); err != ErrChecksumMismatch {
+		t.Fatal(err)
+	}
+
+	if err = l3.DecodeAt(bytes.NewReader(buf), 0); err != ErrChecksumMismatch {
+		t.Fatal(err)
+	}
+}
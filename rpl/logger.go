@@ -0,0 +1,69 @@
+package rpl
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/siddontang/go/log"
+)
+
+// Field is a structured field of a log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// FileIndex is the index of a log file of the file store.
+func FileIndex(index int64) Field {
+	return Field{"file_index", index}
+}
+
+// LogPos is the id of a log, its position in the replication.
+func LogPos(id uint64) Field {
+	return Field{"log_pos", id}
+}
+
+// EventType is the step of the replication which is logged, like commit or
+// purge.
+func EventType(t string) Field {
+	return Field{"event_type", t}
+}
+
+// Err is the error of the step.
+func Err(err error) Field {
+	return Field{"error", err}
+}
+
+// Logger logs the replication, it must be safe for concurrent use.
+type Logger interface {
+	Info(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// GoLogger logs by the default logger of github.com/siddontang/go/log, the
+// fields follow the message as key=value, so its level and handler apply.
+type GoLogger struct{}
+
+func (GoLogger) Info(msg string, fields ...Field) {
+	log.Info(formatLog(msg, fields))
+}
+
+func (GoLogger) Error(msg string, fields ...Field) {
+	log.Error(formatLog(msg, fields))
+}
+
+func formatLog(msg string, fields []Field) string {
+	var b bytes.Buffer
+	b.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+	return b.String()
+}
+
+// NopLogger logs nothing, for the tests which want no output.
+type NopLogger struct{}
+
+func (NopLogger) Info(msg string, fields ...Field) {}
+
+func (NopLogger) Error(msg string, fields ...Field) {}
@@ -0,0 +1,43 @@
+package rpl
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testLogger keeps the messages formatted like GoLogger.
+type testLogger struct {
+	sync.Mutex
+	msgs []string
+}
+
+func (l *testLogger) Info(msg string, fields ...Field) {
+	l.Lock()
+	l.msgs = append(l.msgs, formatLog(msg, fields))
+	l.Unlock()
+}
+
+func (l *testLogger) Error(msg string, fields ...Field) {
+	l.Info(msg, fields...)
+}
+
+func (l *testLogger) has(prefix string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, msg := range l.msgs {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFormatLog(t *testing.T) {
+	msg := formatLog("commit failed", []Field{FileIndex(2), LogPos(10), EventType("commit"), Err(errors.New("closed"))})
+	if msg != "commit failed file_index=2 log_pos=10 event_type=commit error=closed" {
+		t.Fatal(msg)
+	}
+}
@@ -1,13 +1,17 @@
 package rpl
 
 import (
+	"crypto/rand"
 	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/snappy"
 	"github.com/siddontang/ledisdb/config"
 )
@@ -23,11 +27,21 @@ type Replication struct {
 
 	cfg *config.Config
 
+	logger Logger
+
 	s LogStore
 
 	commitID  uint64
 	commitLog *os.File
 
+	// the id of the history of the logs, a slave has the id of its master
+	id     string
+	idPath string
+	// the id before the last ResetID, the logs up to id2LastID are of its
+	// history too
+	id2       string
+	id2LastID uint64
+
 	quit chan struct{}
 
 	wg sync.WaitGroup
@@ -37,7 +51,9 @@ type Replication struct {
 	ncm sync.Mutex
 }
 
-func NewReplication(cfg *config.Config) (*Replication, error) {
+// NewReplication opens the replication of cfg which logs by logger, or by
+// GoLogger if it is nil.
+func NewReplication(cfg *config.Config, logger Logger) (*Replication, error) {
 	if len(cfg.Replication.Path) == 0 {
 		cfg.Replication.Path = path.Join(cfg.DataDir, "rpl")
 	}
@@ -51,6 +67,11 @@ func NewReplication(cfg *config.Config) (*Replication, error) {
 
 	r.cfg = cfg
 
+	if logger == nil {
+		logger = GoLogger{}
+	}
+	r.logger = logger
+
 	var err error
 
 	switch cfg.Replication.StoreName {
@@ -59,7 +80,7 @@ func NewReplication(cfg *config.Config) (*Replication, error) {
 			return nil, err
 		}
 	default:
-		if r.s, err = NewFileStore(path.Join(base, "ldb"), cfg); err != nil {
+		if r.s, err = NewFileStore(path.Join(base, "ldb"), cfg, r.logger); err != nil {
 			return nil, err
 		}
 	}
@@ -74,7 +95,12 @@ func NewReplication(cfg *config.Config) (*Replication, error) {
 		return nil, err
 	}
 
-	log.Infof("staring replication with commit ID %d", r.commitID)
+	r.idPath = path.Join(base, "replication.id")
+	if err = r.loadID(); err != nil {
+		return nil, err
+	}
+
+	r.logger.Info("starting replication", LogPos(r.commitID), EventType("start"))
 
 	r.wg.Add(1)
 	go r.run()
@@ -82,6 +108,14 @@ func NewReplication(cfg *config.Config) (*Replication, error) {
 	return r, nil
 }
 
+// SetSlaveLogID sets the func of the lowest last log id of the slaves, the
+// file store keeps the logs after it when purging over the limits.
+func (r *Replication) SetSlaveLogID(f SlaveLogIDFunc) {
+	if s, ok := r.s.(*FileStore); ok {
+		s.SetSlaveLogID(f)
+	}
+}
+
 func (r *Replication) Close() error {
 	close(r.quit)
 
@@ -90,7 +124,7 @@ func (r *Replication) Close() error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	log.Infof("closing replication with commit ID %d", r.commitID)
+	r.logger.Info("closing replication", LogPos(r.commitID), EventType("close"))
 
 	if r.s != nil {
 		r.s.Close()
@@ -98,7 +132,7 @@ func (r *Replication) Close() error {
 	}
 
 	if err := r.updateCommitID(r.commitID, true); err != nil {
-		log.Errorf("update commit id err %s", err.Error())
+		r.logger.Error("update commit id failed", LogPos(r.commitID), EventType("close"), Err(err))
 	}
 
 	if r.commitLog != nil {
@@ -110,6 +144,11 @@ func (r *Replication) Close() error {
 }
 
 func (r *Replication) Log(data []byte) (*Log, error) {
+	return r.LogWithTime(data, uint32(time.Now().Unix()))
+}
+
+// LogWithTime stores a log with the given create time in unix seconds.
+func (r *Replication) LogWithTime(data []byte, createTime uint32) (*Log, error) {
 	if r.cfg.Replication.Compression {
 		//todo optimize
 		var err error
@@ -136,7 +175,7 @@ func (r *Replication) Log(data []byte) (*Log, error) {
 
 	l := new(Log)
 	l.ID = lastID + 1
-	l.CreateTime = uint32(time.Now().Unix())
+	l.CreateTime = createTime
 
 	if r.cfg.Replication.Compression {
 		l.Compression = 1
@@ -144,6 +183,8 @@ func (r *Replication) Log(data []byte) (*Log, error) {
 		l.Compression = 0
 	}
 
+	l.Checksum = r.cfg.Replication.Checksum
+
 	l.Data = data
 
 	if err = r.s.StoreLog(l); err != nil {
@@ -277,6 +318,102 @@ func (r *Replication) NextNeedCommitLog(log *Log) error {
 
 }
 
+// IDLen is the length of a replication id, in hex.
+const IDLen = 40
+
+// loadID reads the replication id file, the id in the first line and the
+// id2 with its last log id in the second one if any.
+func (r *Replication) loadID() error {
+	data, err := ioutil.ReadFile(r.idPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if len(lines[0]) != IDLen {
+		return r.resetID()
+	}
+	r.id = lines[0]
+
+	if len(lines) > 1 {
+		if _, err := fmt.Sscanf(lines[1], "%s %d", &r.id2, &r.id2LastID); err != nil || len(r.id2) != IDLen {
+			r.id2, r.id2LastID = "", 0
+		}
+	}
+	return nil
+}
+
+// ID returns the replication id of the logs.
+func (r *Replication) ID() string {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.id
+}
+
+// ID2 returns the replication id before the last ResetID and the last log id
+// of its history, empty if none.
+func (r *Replication) ID2() (string, uint64) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.id2, r.id2LastID
+}
+
+// SetID saves id as the replication id and forgets id2, a slave sets the id
+// of its master after a sync from it.
+func (r *Replication) SetID(id string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.saveID(id, "", 0)
+}
+
+// ResetID saves a new random replication id, the logs written after it are
+// a new history. The old id is kept as id2 up to the last log.
+func (r *Replication) ResetID() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.resetID()
+}
+
+func (r *Replication) resetID() error {
+	b := make([]byte, IDLen/2)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	if len(r.id) == 0 {
+		return r.saveID(hex.EncodeToString(b), "", 0)
+	}
+
+	lastID, err := r.s.LastID()
+	if err != nil {
+		return err
+	} else if lastID < r.commitID {
+		lastID = r.commitID
+	}
+	return r.saveID(hex.EncodeToString(b), r.id, lastID)
+}
+
+func (r *Replication) saveID(id string, id2 string, id2LastID uint64) error {
+	data := id
+	if len(id2) > 0 {
+		data = fmt.Sprintf("%s\n%s %d", id, id2, id2LastID)
+	}
+
+	tmp := r.idPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return err
+	} else if err = os.Rename(tmp, r.idPath); err != nil {
+		return err
+	}
+
+	r.id, r.id2, r.id2LastID = id, id2, id2LastID
+	return nil
+}
+
 func (r *Replication) Clear() error {
 	return r.ClearWithCommitID(0)
 }
@@ -306,7 +443,7 @@ func (r *Replication) run() {
 			err := r.s.PurgeExpired(int64(n))
 			r.m.Unlock()
 			if err != nil {
-				log.Errorf("purge expired log error %s", err.Error())
+				r.logger.Error("purge expired logs failed", EventType("purge"), Err(err))
 			}
 		case <-syncTc.C:
 			if r.cfg.Replication.SyncLog == 1 {
@@ -314,7 +451,7 @@ func (r *Replication) run() {
 				err := r.s.Sync()
 				r.m.Unlock()
 				if err != nil {
-					log.Errorf("sync store error %s", err.Error())
+					r.logger.Error("sync store failed", EventType("sync"), Err(err))
 				}
 			}
 			if r.cfg.Replication.SyncLog != 2 {
@@ -324,7 +461,7 @@ func (r *Replication) run() {
 				r.m.Unlock()
 
 				if err != nil {
-					log.Errorf("sync commitid error %s", err.Error())
+					r.logger.Error("sync commit id failed", EventType("sync"), Err(err))
 				}
 			}
 		case <-r.quit:
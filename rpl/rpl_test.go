@@ -3,6 +3,7 @@ package rpl
 import (
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/siddontang/ledisdb/config"
@@ -18,7 +19,7 @@ func TestReplication(t *testing.T) {
 	c := config.NewConfigDefault()
 	c.Replication.Path = dir
 
-	r, err := NewReplication(c)
+	r, err := NewReplication(c, NopLogger{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -43,3 +44,60 @@ func TestReplication(t *testing.T) {
 
 	r.Close()
 }
+
+func TestReplicationID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpl_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := config.NewConfigDefault()
+	c.Replication.Path = dir
+
+	r, err := NewReplication(c, NopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := r.ID()
+	if len(id) != IDLen {
+		t.Fatal(id)
+	} else if id2, _ := r.ID2(); len(id2) != 0 {
+		t.Fatal(id2)
+	}
+
+	if err = r.ResetID(); err != nil {
+		t.Fatal(err)
+	} else if r.ID() == id {
+		t.Fatal("id not reset")
+	} else if id2, lastID := r.ID2(); id2 != id || lastID != 0 {
+		t.Fatal(id2, lastID)
+	}
+
+	// the old id is kept after restart
+	r.Close()
+	if r, err = NewReplication(c, NopLogger{}); err != nil {
+		t.Fatal(err)
+	} else if id2, _ := r.ID2(); id2 != id {
+		t.Fatal(id2)
+	}
+
+	id = strings.Repeat("a", IDLen)
+	if err = r.SetID(id); err != nil {
+		t.Fatal(err)
+	} else if id2, _ := r.ID2(); len(id2) != 0 {
+		t.Fatal(id2)
+	}
+	r.Close()
+
+	// the id is kept after restart
+	if r, err = NewReplication(c, NopLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if r.ID() != id {
+		t.Fatal(r.ID())
+	}
+}
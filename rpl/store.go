@@ -9,10 +9,11 @@ const (
 )
 
 var (
-	ErrLogNotFound    = errors.New("log not found")
-	ErrStoreLogID     = errors.New("log id is less")
-	ErrNoBehindLog    = errors.New("no behind commit log")
-	ErrCommitIDBehind = errors.New("commit id is behind last log id")
+	ErrLogNotFound      = errors.New("log not found")
+	ErrStoreLogID       = errors.New("log id is less")
+	ErrNoBehindLog      = errors.New("no behind commit log")
+	ErrCommitIDBehind   = errors.New("commit id is behind last log id")
+	ErrChecksumMismatch = errors.New("log checksum mismatch")
 )
 
 type LogStore interface {
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/siddontang/ledisdb/config"
 )
@@ -38,7 +39,7 @@ func TestFileStore(t *testing.T) {
 	cfg := config.NewConfigDefault()
 	cfg.Replication.MaxLogFileSize = 4096
 
-	l, err := NewFileStore(dir, cfg)
+	l, err := NewFileStore(dir, cfg, NopLogger{})
 	if err != nil {
 		t.Fatalf("err: %v ", err)
 	}
@@ -47,6 +48,174 @@ func TestFileStore(t *testing.T) {
 	testLogs(t, l)
 }
 
+func TestFileStoreChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldb")
+	if err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.NewConfigDefault()
+	cfg.Replication.MaxLogFileSize = 4096
+
+	l, err := NewFileStore(dir, cfg, NopLogger{})
+	if err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+
+	data := make([]byte, 1024)
+	for i := uint64(1); i <= 10; i++ {
+		log := Log{ID: i, Checksum: i%2 == 0, Data: data}
+		if err := l.StoreLog(&log); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	l.Close()
+
+	// reload the tables and check both formats
+	if l, err = NewFileStore(dir, cfg, NopLogger{}); err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+	defer l.Close()
+
+	for i := uint64(1); i <= 10; i++ {
+		var out Log
+		if err := l.GetLog(i, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		} else if out.ID != i || out.Checksum != (i%2 == 0) {
+			t.Fatalf("bad log %d %v", out.ID, out.Checksum)
+		}
+	}
+}
+
+func TestFileStorePurgeOverLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldb")
+	if err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.NewConfigDefault()
+	cfg.Replication.MaxLogFileSize = 4096
+
+	l, err := NewFileStore(dir, cfg, NopLogger{})
+	if err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+	defer l.Close()
+
+	data := make([]byte, 1024)
+	for i := uint64(1); i <= 50; i++ {
+		if err := l.StoreLog(&Log{ID: i, Data: data}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	tables := len(l.rs)
+	if tables < 5 {
+		t.Fatalf("bad table num %d", tables)
+	}
+
+	l.purgeOverLimit()
+	if len(l.rs) != tables {
+		t.Fatalf("no table must be purged, %d != %d", len(l.rs), tables)
+	}
+
+	// a slave still needs the logs of the second table
+	slaveID := l.rs[0].last
+	l.SetSlaveLogID(func() (uint64, bool) { return slaveID, true })
+
+	cfg.Replication.MaxLogFileNum = tables - 2
+	l.purgeOverLimit()
+	if len(l.rs) != tables-1 {
+		t.Fatalf("bad table num %d", len(l.rs))
+	} else if id, _ := l.FirstID(); id != slaveID+1 {
+		t.Fatalf("bad first id %d", id)
+	}
+
+	l.SetSlaveLogID(nil)
+	cfg.Replication.MaxLogFileNum = tables - 1
+	l.purgeOverLimit()
+	if len(l.rs) != tables-1 {
+		t.Fatalf("bad table num %d", len(l.rs))
+	}
+
+	cfg.Replication.MaxLogTotalSize = 2 * l.rs[0].Size()
+	l.purgeOverLimit()
+	if len(l.rs) != 2 {
+		t.Fatalf("bad table num %d", len(l.rs))
+	}
+
+	last := l.rs[1].last
+	if id, _ := l.FirstID(); id != l.rs[0].first {
+		t.Fatalf("bad first id %d", id)
+	} else if err := l.GetLog(last, &Log{}); err != nil {
+		t.Fatalf("err: %v", err)
+	} else if err := l.GetLog(id-1, &Log{}); err != ErrLogNotFound {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestFileStorePurgeExpired(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldb")
+	if err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.NewConfigDefault()
+	cfg.Replication.MaxLogFileSize = 4096
+
+	logger := &testLogger{}
+	l, err := NewFileStore(dir, cfg, logger)
+	if err != nil {
+		t.Fatalf("err: %v ", err)
+	}
+	defer l.Close()
+
+	// the first 40 logs are an hour old
+	now := uint32(time.Now().Unix())
+	data := make([]byte, 1024)
+	for i := uint64(1); i <= 50; i++ {
+		createTime := now
+		if i <= 40 {
+			createTime = now - 3600
+		}
+		if err := l.StoreLog(&Log{ID: i, CreateTime: createTime, Data: data}); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	tables := len(l.rs)
+	if tables < 5 {
+		t.Fatalf("bad table num %d", tables)
+	}
+
+	// a slave still needs the logs of the second table
+	slaveID := l.rs[0].last
+	l.SetSlaveLogID(func() (uint64, bool) { return slaveID, true })
+
+	if err := l.PurgeExpired(60); err != nil {
+		t.Fatalf("err: %v", err)
+	} else if len(l.rs) != tables-1 {
+		t.Fatalf("bad table num %d", len(l.rs))
+	} else if id, _ := l.FirstID(); id != slaveID+1 {
+		t.Fatalf("bad first id %d", id)
+	} else if !logger.has("purge table file_index=1 ") || logger.has("purge table file_index=2 ") {
+		t.Fatalf("bad purge logs %q", logger.msgs)
+	}
+
+	l.SetSlaveLogID(nil)
+	if err := l.PurgeExpired(60); err != nil {
+		t.Fatalf("err: %v", err)
+	} else if l.rs[0].lastTime != now {
+		t.Fatalf("bad table time %d", l.rs[0].lastTime)
+	} else if id, _ := l.FirstID(); id > 41 {
+		t.Fatalf("bad first id %d", id)
+	}
+}
+
 func testLogs(t *testing.T, l LogStore) {
 	// Should be no first index
 	idx, err := l.FirstID()
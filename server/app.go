@@ -1,7 +1,9 @@
 package server
 
 import (
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
@@ -11,6 +13,7 @@ import (
 	"sync"
 
 	"crypto/tls"
+	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/goredis"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/ledis"
@@ -20,6 +23,7 @@ type App struct {
 	cfg *config.Config
 
 	listener     net.Listener
+	unixListener net.Listener
 	httpListener net.Listener
 
 	ldb *ledis.Ledis
@@ -29,25 +33,42 @@ type App struct {
 	quit chan struct{}
 
 	access *accessLog
+	audit  *AuditLog
 
 	//for slave replication
 	m *master
 
+	// the master is discovered from the sentinels if set
+	sentinel *sentinel
+
 	info *info
 
 	script *script
 
+	slowlog *SlowLog
+
+	pubsub *PubSubHub
+	// cancels the forward of the expired keys to pubsub
+	expireNotifyCancel ledis.CancelFunc
+
+	restLimiter *restLimiter
+
 	// handle slaves
 	slock        sync.Mutex
 	slaves       map[string]*client
 	slaveSyncAck chan uint64
+	// closed and renewed when any slave syncs, to wake up all the WAITs
+	slaveSyncCh chan struct{}
 
 	snap *snapshotStore
 
 	connWait sync.WaitGroup
 
-	rcm sync.Mutex
-	rcs map[*respClient]struct{}
+	// the id of the client to the *respClient, CLIENT LIST ranges over it
+	// without blocking the new connections
+	rcs      sync.Map
+	rcNum    sync2.AtomicInt64
+	clientID sync2.AtomicUint64
 
 	migrateM          sync.Mutex
 	migrateClients    map[string]*goredis.Client
@@ -68,11 +89,74 @@ func tlsConfig(c *config.TLS) (*tls.Config, error) {
 		return nil, err
 	}
 
-	return &tls.Config{
+	tlsCfg := &tls.Config{
 		Certificates: []tls.Certificate{
 			crt,
 		},
-	}, nil
+	}
+
+	if len(c.ClientCA) > 0 {
+		if tlsCfg.ClientCAs, err = loadCertPool(c.ClientCA); err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificate in %s", path)
+	}
+
+	return pool, nil
+}
+
+// masterTLSConfig builds the client tls config for connecting to master.
+func masterTLSConfig(c *config.ReplicationConfig, addr string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := &tls.Config{ServerName: host}
+
+	if len(c.MasterTLSCA) > 0 {
+		if tlsCfg.RootCAs, err = loadCertPool(c.MasterTLSCA); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.MasterTLSCertificate) > 0 {
+		crt, err := tls.LoadX509KeyPair(c.MasterTLSCertificate, c.MasterTLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{crt}
+	}
+
+	return tlsCfg, nil
+}
+
+// chmodUnixSocket sets the octal permissions perm of the socket file name, if
+// perm is not empty.
+func chmodUnixSocket(name string, perm string) error {
+	if len(perm) == 0 {
+		return nil
+	}
+
+	mode, err := strconv.ParseInt(perm, 8, 32)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(name, os.FileMode(mode))
 }
 
 func listen(netType, laddr string, tlsCfg *tls.Config) (net.Listener, error) {
@@ -99,8 +183,11 @@ func NewApp(cfg *config.Config) (*App, error) {
 
 	app.slaves = make(map[string]*client)
 	app.slaveSyncAck = make(chan uint64)
+	app.slaveSyncCh = make(chan struct{})
 
-	app.rcs = make(map[*respClient]struct{})
+	app.slowlog = newSlowLog(cfg.SlowlogMaxLen)
+	app.pubsub = newPubSubHub()
+	app.restLimiter = newRestLimiter()
 
 	app.migrateClients = make(map[string]*goredis.Client)
 	app.newMigrateKeyLockers()
@@ -126,12 +213,8 @@ func NewApp(cfg *config.Config) (*App, error) {
 			return nil, err
 		}
 
-		if addrNetType == "unix" && len(cfg.AddrUnixSocketPerm) > 0 {
-			var perm int64
-			if perm, err = strconv.ParseInt(cfg.AddrUnixSocketPerm, 8, 32); err != nil {
-				return nil, err
-			}
-			if err = os.Chmod(cfg.Addr, os.FileMode(perm)); err != nil {
+		if addrNetType == "unix" {
+			if err = chmodUnixSocket(cfg.Addr, cfg.AddrUnixSocketPerm); err != nil {
 				return nil, err
 			}
 		}
@@ -144,8 +227,27 @@ func NewApp(cfg *config.Config) (*App, error) {
 		}
 	}
 
+	if len(cfg.UnixSocket) > 0 {
+		// a socket file left by a crash
+		os.Remove(cfg.UnixSocket)
+
+		if app.unixListener, err = net.Listen("unix", cfg.UnixSocket); err != nil {
+			return nil, err
+		}
+		if err = chmodUnixSocket(cfg.UnixSocket, cfg.AddrUnixSocketPerm); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(cfg.HttpAddr) > 0 {
-		if app.httpListener, err = listen(netType(cfg.HttpAddr), cfg.HttpAddr, tlsCfg); err != nil {
+		// HTTP/2 is served over TLS
+		httpTLSCfg := tlsCfg
+		if httpTLSCfg != nil {
+			httpTLSCfg = tlsCfg.Clone()
+			httpTLSCfg.NextProtos = []string{"h2", "http/1.1"}
+		}
+
+		if app.httpListener, err = listen(netType(cfg.HttpAddr), cfg.HttpAddr, httpTLSCfg); err != nil {
 			return nil, err
 		}
 	}
@@ -162,6 +264,17 @@ func NewApp(cfg *config.Config) (*App, error) {
 		}
 	}
 
+	if len(cfg.AuditLog) > 0 {
+		name := cfg.AuditLog
+		if path.Dir(name) == "." {
+			name = path.Join(cfg.DataDir, name)
+		}
+
+		if app.audit, err = newAuditLog(name); err != nil {
+			return nil, err
+		}
+	}
+
 	if app.snap, err = newSnapshotStore(cfg); err != nil {
 		return nil, err
 	}
@@ -175,11 +288,25 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
+	if cfg.ExpireNotifyEnabled {
+		var ch <-chan ledis.Notification
+		ch, app.expireNotifyCancel = app.ldb.Subscribe("__keyevent@*__:" + ledis.EventExpired)
+		go app.publishExpired(ch)
+	}
+
 	app.m = newMaster(app)
 
+	if len(cfg.Sentinel.Addrs) > 0 {
+		if app.sentinel, err = newSentinel(app); err != nil {
+			return nil, err
+		}
+	}
+
 	app.openScript()
 
 	app.ldb.AddNewLogEventHandler(app.publishNewLog)
+	app.ldb.SetReplicaWaiter(app.waitReplicas)
+	app.ldb.SetSlaveLogID(app.minSlaveLogID)
 
 	return app, nil
 }
@@ -195,6 +322,11 @@ func (app *App) Close() {
 
 	app.listener.Close()
 
+	// the socket file is removed too
+	if app.unixListener != nil {
+		app.unixListener.Close()
+	}
+
 	//close all migrate connections
 	app.migrateM.Lock()
 	for k, c := range app.migrateClients {
@@ -214,6 +346,10 @@ func (app *App) Close() {
 
 	app.closeScript()
 
+	if app.sentinel != nil {
+		app.sentinel.Close()
+	}
+
 	app.m.Lock()
 	app.m.Close()
 	app.m.Unlock()
@@ -224,22 +360,40 @@ func (app *App) Close() {
 		app.access.Close()
 	}
 
+	if app.audit != nil {
+		app.audit.Close()
+	}
+
+	if app.expireNotifyCancel != nil {
+		app.expireNotifyCancel()
+	}
+
 	app.ldb.Close()
 }
 
 func (app *App) Run() {
-	if len(app.cfg.SlaveOf) > 0 {
+	if app.sentinel != nil {
+		app.sentinel.start()
+	} else if len(app.cfg.SlaveOf) > 0 {
 		app.slaveof(app.cfg.SlaveOf, false, app.cfg.Readonly)
 	}
 
 	go app.httpServe()
 
+	if app.unixListener != nil {
+		go app.accept(app.unixListener)
+	}
+
+	app.accept(app.listener)
+}
+
+func (app *App) accept(l net.Listener) {
 	for {
 		select {
 		case <-app.quit:
 			return
 		default:
-			conn, err := app.listener.Accept()
+			conn, err := l.Accept()
 			if err != nil {
 				continue
 			}
@@ -256,6 +410,12 @@ func (app *App) httpServe() {
 
 	mux := http.NewServeMux()
 
+	mux.Handle("/replication/status", app.ReplicationStatusHandler())
+
+	mux.HandleFunc("/cmd/", func(w http.ResponseWriter, r *http.Request) {
+		newClientREST(app, w, r)
+	})
+
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		newClientHTTP(app, w, r)
 	})
@@ -268,6 +428,11 @@ func (app *App) Ledis() *ledis.Ledis {
 	return app.ldb
 }
 
+// AuditLog returns the audit log, nil if audit_log is not set.
+func (app *App) AuditLog() *AuditLog {
+	return app.audit
+}
+
 func (app *App) Address() string {
 	return app.listener.Addr().String()
 }
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"io/ioutil"
 	"os"
+	"path"
 	"sync"
 	"testing"
 
@@ -12,6 +14,7 @@ import (
 var testAppOnce sync.Once
 var testAppAuthOnce sync.Once
 var testApp *App
+var testAppAuth *App
 
 var testLedisClient *goredis.Client
 var testLedisClientAuth *goredis.Client
@@ -42,13 +45,13 @@ func startTestAppAuth(password string) {
 		os.RemoveAll(cfg.DataDir)
 
 		var err error
-		testApp, err = NewApp(cfg)
+		testAppAuth, err = NewApp(cfg)
 		if err != nil {
 			println(err.Error())
 			panic(err)
 		}
 
-		go testApp.Run()
+		go testAppAuth.Run()
 	}
 
 	testAppAuthOnce.Do(f)
@@ -94,3 +97,48 @@ func startTestApp() {
 func TestApp(t *testing.T) {
 	startTestApp()
 }
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledis_unixsocket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = path.Join(dir, "data")
+	cfg.Addr = "127.0.0.1:11194"
+	cfg.UnixSocket = path.Join(dir, "ledis.sock")
+	cfg.AddrUnixSocketPerm = "0700"
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Run()
+
+	if fi, err := os.Stat(cfg.UnixSocket); err != nil {
+		t.Fatal(err)
+	} else if perm := fi.Mode().Perm(); perm != 0700 {
+		t.Fatal(perm)
+	}
+
+	for _, addr := range []string{cfg.UnixSocket, cfg.Addr} {
+		c, err := goredis.Connect(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s, err := goredis.String(c.Do("set", "test_unixsocket", addr)); err != nil || s != OK {
+			t.Fatal(s, err)
+		} else if s, err := goredis.String(c.Do("get", "test_unixsocket")); err != nil || s != addr {
+			t.Fatal(s, err)
+		}
+		c.Close()
+	}
+
+	app.Close()
+	if _, err := os.Stat(cfg.UnixSocket); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/go/log"
+)
+
+// passwordArg returns the index of the password in the arguments of cmd,
+// which is never logged, or -1.
+func passwordArg(cmd string, args [][]byte) int {
+	switch cmd {
+	case "auth":
+		return len(args) - 1
+	case "acladd":
+		return 1
+	case "hello":
+		for i := 0; i+2 < len(args); i++ {
+			if strings.ToLower(hack.String(args[i])) == "auth" {
+				return i + 2
+			}
+		}
+		return -1
+	default:
+		return -1
+	}
+}
+
+// isReadCommand reports whether the command is audited only with
+// audit_reads, CONFIG GET, CLIENT LIST and CLIENT GETNAME are reads too.
+func isReadCommand(cmd string, args [][]byte) bool {
+	switch cmd {
+	case "config":
+		return len(args) > 0 && strings.ToLower(hack.String(args[0])) == "get"
+	case "client":
+		if len(args) == 0 {
+			return false
+		}
+		sub := strings.ToLower(hack.String(args[0]))
+		return sub == "list" || sub == "getname"
+	}
+	return commandHas(cmd, flagRead)
+}
+
+type auditRecord struct {
+	Ts         string   `json:"ts"`
+	ClientAddr string   `json:"client_addr"`
+	Username   string   `json:"username"`
+	Command    string   `json:"command"`
+	Key        string   `json:"key,omitempty"`
+	Args       []string `json:"args,omitempty"`
+	Outcome    string   `json:"outcome"`
+}
+
+// AuditLog writes a JSON line for every audited command to a file.
+type AuditLog struct {
+	m    sync.Mutex
+	name string
+	f    *os.File
+}
+
+func newAuditLog(name string) (*AuditLog, error) {
+	if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+
+	l := &AuditLog{name: name}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *AuditLog) open() error {
+	f, err := os.OpenFile(l.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	l.f = f
+	return nil
+}
+
+// Rotate reopens the file, it is used after the file is moved by a tool
+// like logrotate.
+func (l *AuditLog) Rotate() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.f.Close()
+	return l.open()
+}
+
+// Close closes the file.
+func (l *AuditLog) Close() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return l.f.Close()
+}
+
+// log audits the command of c finished with err.
+func (l *AuditLog) log(c *client, err error, withValues bool) {
+	r := auditRecord{
+		Ts:         time.Now().Format(time.RFC3339Nano),
+		ClientAddr: c.remoteAddr,
+		Username:   c.user,
+		Command:    c.cmd,
+		Outcome:    OK,
+	}
+
+	if len(r.Username) == 0 {
+		r.Username = "default"
+	}
+
+	keys := commandKeys(c.cmd, c.args)
+	if len(keys) > 0 {
+		r.Key = string(keys[0])
+	}
+
+	if withValues {
+		password := passwordArg(c.cmd, c.args)
+		r.Args = make([]string, len(c.args))
+		for i, arg := range c.args {
+			if i == password {
+				r.Args[i] = "(redacted)"
+			} else {
+				r.Args[i] = string(arg)
+			}
+		}
+	}
+
+	if err != nil {
+		r.Outcome = err.Error()
+	}
+
+	data, _ := json.Marshal(r)
+	data = append(data, '\n')
+
+	l.m.Lock()
+	if _, err := l.f.Write(data); err != nil {
+		log.Errorf("write audit log error %s", err.Error())
+	}
+	l.m.Unlock()
+}
+
+// audit logs the command of c if it is audited.
+func (c *client) audit(err error) {
+	l := c.app.audit
+	if l == nil {
+		return
+	}
+
+	var reads, values bool
+	c.app.cfg.View(func() {
+		reads, values = c.app.cfg.AuditReads, c.app.cfg.AuditLogValues
+	})
+	if !reads && isReadCommand(c.cmd, c.args) {
+		return
+	}
+
+	l.log(c, err, values)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+func readAuditRecords(t *testing.T, name string) []auditRecord {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []auditRecord
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var r auditRecord
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestAuditLog(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_audit_log"
+	cfg.Addr = "127.0.0.1:11191"
+	cfg.AuditLog = "audit.log"
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	c := goredis.NewClient(cfg.Addr, "")
+	defer c.Close()
+
+	name := path.Join(cfg.DataDir, cfg.AuditLog)
+
+	c.Do("set", "a", "secret")
+	c.Do("get", "a")
+	c.Do("incr", "a")
+
+	records := readAuditRecords(t, name)
+	if len(records) != 2 {
+		t.Fatal(records)
+	}
+
+	if r := records[0]; r.Command != "set" || r.Key != "a" || r.Username != "default" || r.Outcome != OK || len(r.Args) != 0 {
+		t.Fatal(r)
+	} else if r := records[1]; r.Command != "incr" || r.Outcome == OK {
+		t.Fatal(r)
+	}
+
+	// the file is moved away like by logrotate
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	} else if err := app.AuditLog().Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Update(func() {
+		cfg.AuditReads = true
+		cfg.AuditLogValues = true
+	})
+
+	c.Do("get", "a")
+	c.Do("auth", "password")
+
+	records = readAuditRecords(t, name)
+	if len(records) != 2 {
+		t.Fatal(records)
+	}
+
+	if r := records[0]; r.Command != "get" || r.Key != "a" {
+		t.Fatal(r)
+	} else if r := records[1]; r.Command != "auth" || len(r.Args) != 1 || r.Args[0] != "(redacted)" {
+		t.Fatal(r)
+	}
+}
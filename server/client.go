@@ -2,16 +2,20 @@ package server
 
 import (
 	"bytes"
+	"context"
 	//	"fmt"
 	"io"
 	"strings"
 	"time"
 
+	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/ledisdb/ledis"
 )
 
-type responseWriter interface {
+// Encoder writes the replies of the commands, the handlers are shared by
+// RESP2, RESP3, HTTP and Lua which encode the replies their own way.
+type Encoder interface {
 	writeError(error)
 	writeStatus(string)
 	writeInteger(int64)
@@ -21,6 +25,11 @@ type responseWriter interface {
 	writeFVPairArray([]ledis.FVPair)
 	writeScorePairArray([]ledis.ScorePair, bool)
 	writeBulkFrom(int64, io.Reader)
+	// the typed replies of RESP3, the others fall back to the types above
+	writeFVPairMap([]ledis.FVPair)
+	writeSliceSet([][]byte)
+	writeScore(int64)
+	writeBool(bool)
 	flush()
 }
 
@@ -39,19 +48,57 @@ type client struct {
 	cmd        string
 	args       [][]byte
 
+	// unique in the server, assigned in the order of the connections
+	id         uint64
+	createTime time.Time
+
 	isAuthed bool
+	// the ACL user authenticated with AUTH username password, empty for the
+	// default user which can run all the commands
+	user string
+	// set by HELLO SETNAME and CLIENT SETNAME
+	name sync2.AtomicString
+
+	// the last command, its unix time in nanoseconds and the CLIENT LIST
+	// flags after it, read by CLIENT LIST from the other connections
+	lastCmd  sync2.AtomicString
+	lastTime sync2.AtomicInt64
+	flags    sync2.AtomicString
+
+	// the connection is closed once the reply is written
+	closeAfterReply bool
 
-	resp responseWriter
+	// set by CLIENT NO-EVICT and CLIENT NO-TOUCH
+	noEvict bool
+	noTouch bool
+
+	resp Encoder
 
 	syncBuf bytes.Buffer
 
 	lastLogID sync2.AtomicUint64
+	// unix time of the last sync from the slave
+	lastSyncTime sync2.AtomicInt64
+	// limits the bytes sent to the slave by replication.throttle_bytes
+	syncThrottle syncThrottle
 
 	// reqErr chan error
 
 	buf bytes.Buffer
 
 	slaveListeningAddr string
+
+	// the commands queued after MULTI, nil if not in MULTI
+	tx *transaction
+
+	// the keys watched since WATCH, EXEC fails if any of them is written
+	watches []*ledis.Watch
+
+	// kill closes the connection of the client, nil if it has none
+	kill func()
+
+	// the subscriptions of SUBSCRIBE and PSUBSCRIBE, nil before any
+	ps *Subscriber
 }
 
 func newClient(app *App) *client {
@@ -59,6 +106,10 @@ func newClient(app *App) *client {
 
 	c.app = app
 	c.ldb = app.ldb
+	c.id = app.clientID.Add(1)
+	c.createTime = time.Now()
+	c.lastTime.Set(c.createTime.UnixNano())
+	c.flags.Set("N")
 	c.isAuthed = false
 	c.db, _ = app.ldb.Select(0) //use default db
 
@@ -66,7 +117,57 @@ func newClient(app *App) *client {
 }
 
 func (c *client) close() {
+	c.unwatch()
+	c.unsubscribeAll()
+}
+
+// reset brings the client back to the state of a new connection.
+func (c *client) reset() {
+	c.tx = nil
+	c.unwatch()
+	c.unsubscribeAll()
+	c.db, _ = c.app.ldb.Select(0)
+	c.isAuthed = false
+	c.user = ""
+	c.name.Set("")
+	c.noEvict = false
+	c.noTouch = false
+	if w, ok := c.resp.(*respWriter); ok {
+		w.protocolVersion = 2
+	}
+}
+
+// touch records the command started at start for CLIENT LIST.
+func (c *client) touch(start time.Time) {
+	c.lastCmd.Set(c.cmd)
+	c.lastTime.Set(start.UnixNano())
+
+	flags := ""
+	if len(c.slaveListeningAddr) > 0 {
+		flags += "S"
+	}
+	if c.subscribed() {
+		flags += "P"
+	}
+	if c.tx != nil {
+		flags += "x"
+	}
+	if c.noEvict {
+		flags += "e"
+	}
+	if c.noTouch {
+		flags += "T"
+	}
+	if len(flags) == 0 {
+		flags = "N"
+	}
+	c.flags.Set(flags)
+}
 
+// resp3 reports whether the client speaks RESP3 after HELLO 3.
+func (c *client) resp3() bool {
+	w, ok := c.resp.(*respWriter)
+	return ok && w.protocolVersion == 3
 }
 
 func (c *client) authEnabled() bool {
@@ -80,19 +181,52 @@ func (c *client) perform() {
 
 	c.cmd = strings.ToLower(c.cmd)
 
+	queued := false
 	if len(c.cmd) == 0 {
 		err = ErrEmptyCommand
-	} else if exeCmd, ok := regCmds[c.cmd]; !ok {
+	} else if exeCmd, ok := commands[c.cmd]; !ok {
 		err = ErrNotFound
-	} else if c.authEnabled() && !c.isAuthed && c.cmd != "auth" {
+	} else if c.authEnabled() && !c.isAuthed && c.cmd != "auth" && c.cmd != "hello" && c.cmd != "reset" {
 		err = ErrNotAuthenticated
+	} else if !c.aclAllowed() {
+		err = ErrNoPermission
+	} else if c.subscribed() && !commandHas(c.cmd, flagSubscribed) && !c.resp3() {
+		err = ErrPubSubMode
+	} else if c.tx == nil && c.app.cfg.GetReadonly() && isWriteCommand(c.cmd, c.args) {
+		// the writes queued in MULTI are rejected by EXEC
+		err = ErrReadonly
+	} else if c.tx != nil && !commandHas(c.cmd, flagTx) {
+		queued = true
+		if err = c.tx.queue(c.cmd, c.args); err == nil {
+			c.resp.writeStatus(QUEUED)
+		}
 	} else {
-		err = exeCmd(c)
+		c.app.info.Stats.TotalCommands.Add(1)
+		err = c.execute(exeCmd.f)
 	}
 
-	if c.app.access != nil {
-		duration := time.Since(start)
+	// a command rejected in MULTI makes EXEC fail
+	if err != nil && c.tx != nil && !commandHas(c.cmd, flagTx) {
+		c.tx.err = true
+	}
+
+	// the queued commands are audited by EXEC when they run
+	if !queued && err != ErrEmptyCommand && err != ErrNotFound {
+		c.audit(err)
+	}
+
+	if err != ErrEmptyCommand {
+		c.touch(start)
+	}
+
+	duration := time.Since(start)
+	var slower int
+	c.app.cfg.View(func() { slower = c.app.cfg.SlowlogLogSlowerThan })
+	if slower >= 0 && duration >= time.Duration(slower)*time.Microsecond {
+		c.app.slowlog.log(c, start, duration)
+	}
 
+	if c.app.access != nil {
 		fullCmd := c.catGenericCommand()
 		cost := duration.Nanoseconds() / 1000000
 
@@ -111,6 +245,55 @@ func (c *client) perform() {
 	return
 }
 
+// execute runs the command within command_timeout. The scans stop with an
+// error at the timeout, the connection is closed if the command still runs
+// at twice the timeout.
+func (c *client) execute(exeCmd CommandFunc) error {
+	if c.noEvict {
+		c.db.NoEvict(commandKeys(c.cmd, c.args)...)
+	}
+	if c.noTouch {
+		db := c.db
+		c.db = db.WithNoTouch()
+		defer c.restoreDB(db)
+	}
+
+	timeout := time.Duration(c.app.cfg.CommandTimeout) * time.Millisecond
+	if timeout <= 0 || commandHas(c.cmd, flagUntimed) || (c.cmd == "xread" && hasBlockArg(c.args)) {
+		return exeCmd(c)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if c.kill != nil {
+		watchdog := time.AfterFunc(2*timeout, func() {
+			log.Errorf("command %s runs over %s, close the connection %s", c.cmd, 2*timeout, c.remoteAddr)
+			c.kill()
+		})
+		defer watchdog.Stop()
+	}
+
+	db := c.db
+	c.db = db.WithContext(ctx)
+
+	err := exeCmd(c)
+	if ctx.Err() == context.DeadlineExceeded && err == context.DeadlineExceeded {
+		err = ErrCmdTimeout
+	}
+
+	c.restoreDB(db)
+	return err
+}
+
+// restoreDB sets back db the command ran with a copy of, unless SELECT
+// changed the db.
+func (c *client) restoreDB(db *ledis.DB) {
+	if c.db.Index() == db.Index() {
+		c.db = db
+	}
+}
+
 func (c *client) catGenericCommand() []byte {
 	buffer := c.buf
 	buffer.Reset()
@@ -125,7 +308,7 @@ func (c *client) catGenericCommand() []byte {
 	return buffer.Bytes()
 }
 
-func writeValue(w responseWriter, value interface{}) {
+func writeValue(w Encoder, value interface{}) {
 	switch v := value.(type) {
 	case []interface{}:
 		w.writeArray(v)
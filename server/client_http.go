@@ -24,6 +24,7 @@ var httpUnsupportedCommands = map[string]struct{}{
 	"slaveof":  {},
 	"fullsync": {},
 	"sync":     {},
+	"psync":    {},
 	"quit":     {},
 	"begin":    {},
 	"commit":   {},
@@ -210,6 +211,26 @@ func (w *httpWriter) writeBulkFrom(n int64, rb io.Reader) {
 	w.writeError(fmt.Errorf("unsupport"))
 }
 
+func (w *httpWriter) writeFVPairMap(lst []ledis.FVPair) {
+	w.writeFVPairArray(lst)
+}
+
+func (w *httpWriter) writeSliceSet(lst [][]byte) {
+	w.writeSliceArray(lst)
+}
+
+func (w *httpWriter) writeScore(n int64) {
+	w.genericWrite(strconv.FormatInt(n, 10))
+}
+
+func (w *httpWriter) writeBool(b bool) {
+	if b {
+		w.writeInteger(1)
+	} else {
+		w.writeInteger(0)
+	}
+}
+
 func (w *httpWriter) flush() {
 
 }
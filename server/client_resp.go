@@ -9,6 +9,7 @@ import (
 	"os"
 	"runtime"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
@@ -33,36 +34,41 @@ type respClient struct {
 }
 
 type respWriter struct {
+	// held while writing a reply or a pub/sub message
+	m    sync.Mutex
 	buff *bufio.Writer
+
+	// 2 or 3, switched by HELLO
+	protocolVersion int
 }
 
 func (app *App) addRespClient(c *respClient) {
-	app.rcm.Lock()
-	app.rcs[c] = struct{}{}
-	app.rcm.Unlock()
+	app.rcs.Store(c.id, c)
+	app.rcNum.Add(1)
 }
 
 func (app *App) delRespClient(c *respClient) {
-	app.rcm.Lock()
-	delete(app.rcs, c)
-	app.rcm.Unlock()
+	app.rcs.Delete(c.id)
+	app.rcNum.Add(-1)
 }
 
 func (app *App) closeAllRespClients() {
-	app.rcm.Lock()
-
-	for c := range app.rcs {
-		c.conn.Close()
-	}
-
-	app.rcm.Unlock()
+	app.rcs.Range(func(_, v interface{}) bool {
+		v.(*respClient).conn.Close()
+		return true
+	})
 }
 
 func (app *App) respClientNum() int {
-	app.rcm.Lock()
-	n := len(app.rcs)
-	app.rcm.Unlock()
-	return n
+	return int(app.rcNum.Get())
+}
+
+// respClient returns the client of the id, nil if it is closed.
+func (app *App) respClient(id uint64) *respClient {
+	if v, ok := app.rcs.Load(id); ok {
+		return v.(*respClient)
+	}
+	return nil
 }
 
 func newClientRESP(conn net.Conn, app *App) {
@@ -70,6 +76,7 @@ func newClientRESP(conn net.Conn, app *App) {
 
 	c.client = newClient(app)
 	c.conn = conn
+	c.kill = func() { conn.Close() }
 
 	c.activeQuit = false
 
@@ -127,8 +134,17 @@ func (c *respClient) run() {
 
 	kc := time.Duration(c.app.cfg.ConnKeepaliveInterval) * time.Second
 	for {
-		if kc > 0 {
-			c.conn.SetReadDeadline(time.Now().Add(kc))
+		timeout := kc
+
+		// a slave syncs every heartbeat interval
+		hb := time.Duration(c.app.cfg.Replication.HeartbeatTimeout) * time.Second
+		slave := len(c.slaveListeningAddr) > 0 && hb > 0 && (timeout == 0 || hb < timeout)
+		if slave {
+			timeout = hb
+		}
+
+		if timeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(timeout))
 		}
 
 		c.cmd = ""
@@ -137,6 +153,8 @@ func (c *respClient) run() {
 		reqData, err := c.respReader.ParseRequest()
 		if err == nil {
 			err = c.handleRequest(reqData)
+		} else if ne, ok := err.(net.Error); ok && ne.Timeout() && slave {
+			log.Warnf("slave %s has not synced for %s, close the connection", c.slaveListeningAddr, hb)
 		}
 
 		if err != nil {
@@ -146,6 +164,10 @@ func (c *respClient) run() {
 }
 
 func (c *respClient) handleRequest(reqData [][]byte) error {
+	w := c.resp.(*respWriter)
+	w.m.Lock()
+	defer w.m.Unlock()
+
 	if len(reqData) == 0 {
 		c.cmd = ""
 		c.args = reqData[0:0]
@@ -155,6 +177,14 @@ func (c *respClient) handleRequest(reqData [][]byte) error {
 	}
 
 	if c.cmd == "xselect" {
+		// the queued command would run on the db selected at EXEC
+		if c.tx != nil {
+			c.tx.err = true
+			c.resp.writeError(fmt.Errorf("xselect is not allowed in MULTI"))
+			c.resp.flush()
+			return nil
+		}
+
 		err := c.handleXSelectCmd()
 		if err != nil {
 			c.resp.writeError(err)
@@ -184,6 +214,12 @@ func (c *respClient) handleRequest(reqData [][]byte) error {
 
 	c.perform()
 
+	// CLIENT KILL of the client itself
+	if c.closeAfterReply {
+		c.conn.Close()
+		return errClientQuit
+	}
+
 	return nil
 }
 
@@ -222,6 +258,7 @@ func (c *respClient) handleXSelectCmd() error {
 func newWriterRESP(conn net.Conn, size int) *respWriter {
 	w := new(respWriter)
 	w.buff = bufio.NewWriterSize(conn, size)
+	w.protocolVersion = 2
 	return w
 }
 
@@ -245,7 +282,19 @@ func (w *respWriter) writeInteger(n int64) {
 	w.buff.Write(Delims)
 }
 
+// writeNull writes the RESP3 null, which is used for both the null bulk and
+// the null array.
+func (w *respWriter) writeNull() {
+	w.buff.WriteByte('_')
+	w.buff.Write(Delims)
+}
+
 func (w *respWriter) writeBulk(b []byte) {
+	if b == nil && w.protocolVersion == 3 {
+		w.writeNull()
+		return
+	}
+
 	w.buff.WriteByte('$')
 	if b == nil {
 		w.buff.Write(NullBulk)
@@ -259,6 +308,11 @@ func (w *respWriter) writeBulk(b []byte) {
 }
 
 func (w *respWriter) writeArray(lst []interface{}) {
+	if lst == nil && w.protocolVersion == 3 {
+		w.writeNull()
+		return
+	}
+
 	w.buff.WriteByte('*')
 	if lst == nil {
 		w.buff.Write(NullArray)
@@ -290,7 +344,44 @@ func (w *respWriter) writeArray(lst []interface{}) {
 	}
 }
 
+// writeArrayHeader begins an array of n items written after.
+func (w *respWriter) writeArrayHeader(n int) {
+	w.buff.WriteByte('*')
+	w.buff.Write(hack.Slice(strconv.Itoa(n)))
+	w.buff.Write(Delims)
+}
+
+// writeMapHeader begins a map of n pairs written after, it is an array of 2n
+// items in RESP2.
+func (w *respWriter) writeMapHeader(n int) {
+	if w.protocolVersion == 3 {
+		w.buff.WriteByte('%')
+	} else {
+		w.buff.WriteByte('*')
+		n *= 2
+	}
+	w.buff.Write(hack.Slice(strconv.Itoa(n)))
+	w.buff.Write(Delims)
+}
+
+// writePushHeader begins a push of n items written after, like a pub/sub
+// message, it is an array in RESP2.
+func (w *respWriter) writePushHeader(n int) {
+	if w.protocolVersion == 3 {
+		w.buff.WriteByte('>')
+	} else {
+		w.buff.WriteByte('*')
+	}
+	w.buff.Write(hack.Slice(strconv.Itoa(n)))
+	w.buff.Write(Delims)
+}
+
 func (w *respWriter) writeSliceArray(lst [][]byte) {
+	if lst == nil && w.protocolVersion == 3 {
+		w.writeNull()
+		return
+	}
+
 	w.buff.WriteByte('*')
 	if lst == nil {
 		w.buff.Write(NullArray)
@@ -306,6 +397,11 @@ func (w *respWriter) writeSliceArray(lst [][]byte) {
 }
 
 func (w *respWriter) writeFVPairArray(lst []ledis.FVPair) {
+	if lst == nil && w.protocolVersion == 3 {
+		w.writeNull()
+		return
+	}
+
 	w.buff.WriteByte('*')
 	if lst == nil {
 		w.buff.Write(NullArray)
@@ -322,6 +418,11 @@ func (w *respWriter) writeFVPairArray(lst []ledis.FVPair) {
 }
 
 func (w *respWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool) {
+	if lst == nil && w.protocolVersion == 3 {
+		w.writeNull()
+		return
+	}
+
 	w.buff.WriteByte('*')
 	if lst == nil {
 		w.buff.Write(NullArray)
@@ -355,6 +456,65 @@ func (w *respWriter) writeBulkFrom(n int64, rb io.Reader) {
 	w.buff.Write(Delims)
 }
 
+func (w *respWriter) writeFVPairMap(lst []ledis.FVPair) {
+	if w.protocolVersion != 3 {
+		w.writeFVPairArray(lst)
+		return
+	}
+
+	w.writeMapHeader(len(lst))
+	for i := 0; i < len(lst); i++ {
+		w.writeBulk(lst[i].Field)
+		w.writeBulk(lst[i].Value)
+	}
+}
+
+func (w *respWriter) writeSliceSet(lst [][]byte) {
+	if w.protocolVersion != 3 {
+		w.writeSliceArray(lst)
+		return
+	}
+
+	w.buff.WriteByte('~')
+	w.buff.Write(hack.Slice(strconv.Itoa(len(lst))))
+	w.buff.Write(Delims)
+
+	for i := 0; i < len(lst); i++ {
+		w.writeBulk(lst[i])
+	}
+}
+
+// writeScore writes a score as a double in RESP3, the scores are integers
+// so the double has no fraction.
+func (w *respWriter) writeScore(n int64) {
+	if w.protocolVersion != 3 {
+		w.writeBulk(num.FormatInt64ToSlice(n))
+		return
+	}
+
+	w.buff.WriteByte(',')
+	w.buff.Write(num.FormatInt64ToSlice(n))
+	w.buff.Write(Delims)
+}
+
+func (w *respWriter) writeBool(b bool) {
+	if w.protocolVersion != 3 {
+		if b {
+			w.writeInteger(1)
+		} else {
+			w.writeInteger(0)
+		}
+		return
+	}
+
+	if b {
+		w.buff.Write(hack.Slice("#t"))
+	} else {
+		w.buff.Write(hack.Slice("#f"))
+	}
+	w.buff.Write(Delims)
+}
+
 func (w *respWriter) flush() {
 	w.buff.Flush()
 }
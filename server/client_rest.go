@@ -0,0 +1,354 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+// the max size of the JSON body of a request
+const restMaxBodySize = 64 * 1024 * 1024
+
+var (
+	errRestMethod    = errors.New("only POST is allowed")
+	errRestArgument  = errors.New("an argument must be a string or a number")
+	errRestRateLimit = errors.New("rate limit exceeded")
+)
+
+// restRequest is the body of POST /cmd/<command>.
+type restRequest struct {
+	Args []interface{} `json:"args"`
+}
+
+// restClient runs a command posted to /cmd/<command> with the arguments in a
+// JSON body, the reply is a JSON object too.
+type restClient struct {
+	*client
+}
+
+type restWriter struct {
+	w http.ResponseWriter
+
+	result interface{}
+	err    error
+}
+
+func newClientREST(app *App, w http.ResponseWriter, r *http.Request) {
+	app.connWait.Add(1)
+	defer app.connWait.Done()
+
+	c := new(restClient)
+	c.client = newClient(app)
+	defer c.client.close()
+
+	rw := &restWriter{w: w}
+	c.resp = rw
+	c.remoteAddr = r.RemoteAddr
+
+	if err := c.makeRequest(app, r); err != nil {
+		rw.writeError(err)
+		rw.flush()
+		return
+	}
+
+	c.perform()
+}
+
+func (c *restClient) makeRequest(app *App, r *http.Request) error {
+	if r.Method != "POST" {
+		return errRestMethod
+	}
+
+	c.cmd = strings.ToLower(strings.TrimPrefix(r.URL.Path, "/cmd/"))
+	if _, ok := httpUnsupportedCommands[c.cmd]; ok {
+		return fmt.Errorf("unsupported command: '%s'", c.cmd)
+	}
+
+	if s := r.URL.Query().Get("db"); len(s) > 0 {
+		index, err := strconv.Atoi(s)
+		if err != nil {
+			return ErrValue
+		}
+		if c.db, err = app.ldb.Select(index); err != nil {
+			return err
+		}
+	}
+
+	var req restRequest
+	d := json.NewDecoder(io.LimitReader(r.Body, restMaxBodySize))
+	d.UseNumber()
+	if err := d.Decode(&req); err != nil && err != io.EOF {
+		return err
+	}
+
+	c.args = make([][]byte, len(req.Args))
+	for i, arg := range req.Args {
+		switch v := arg.(type) {
+		case string:
+			c.args[i] = []byte(v)
+		case json.Number:
+			c.args[i] = []byte(v.String())
+		default:
+			return errRestArgument
+		}
+	}
+
+	if err := c.authorize(r); err != nil {
+		return err
+	}
+
+	user := c.user
+	if len(user) == 0 {
+		user = "default"
+	}
+	if !app.restLimiter.allow(user, &app.cfg.HttpRateLimit) {
+		return errRestRateLimit
+	}
+	return nil
+}
+
+// authorize authenticates the client with the bearer token, which is
+// username:password for an ACL user or the password of the default user.
+func (c *restClient) authorize(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if len(auth) == 0 {
+		return nil
+	}
+
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return ErrAuthenticationFailure
+	}
+
+	token := auth[len(prefix):]
+	if i := strings.IndexByte(token, ':'); i >= 0 {
+		return c.authenticate([][]byte{[]byte(token[:i]), []byte(token[i+1:])})
+	}
+	return c.authenticate([][]byte{[]byte(token)})
+}
+
+// rest writer
+
+func (w *restWriter) writeError(err error) {
+	w.err = err
+}
+
+func (w *restWriter) writeStatus(status string) {
+	w.result = status
+}
+
+func (w *restWriter) writeInteger(n int64) {
+	w.result = n
+}
+
+func (w *restWriter) writeBulk(b []byte) {
+	w.result = restBulk(b)
+}
+
+func (w *restWriter) writeArray(lst []interface{}) {
+	w.result = restArray(lst)
+}
+
+func (w *restWriter) writeSliceArray(lst [][]byte) {
+	w.result = restSliceArray(lst)
+}
+
+func (w *restWriter) writeFVPairArray(lst []ledis.FVPair) {
+	if lst == nil {
+		w.result = nil
+		return
+	}
+
+	ay := make([]interface{}, 0, 2*len(lst))
+	for _, p := range lst {
+		ay = append(ay, restBulk(p.Field), restBulk(p.Value))
+	}
+	w.result = ay
+}
+
+func (w *restWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool) {
+	if lst == nil {
+		w.result = nil
+		return
+	}
+
+	ay := make([]interface{}, 0, 2*len(lst))
+	for _, p := range lst {
+		ay = append(ay, restBulk(p.Member))
+		if withScores {
+			ay = append(ay, p.Score)
+		}
+	}
+	w.result = ay
+}
+
+func (w *restWriter) writeBulkFrom(n int64, rb io.Reader) {
+	w.writeError(fmt.Errorf("unsupport"))
+}
+
+func (w *restWriter) writeFVPairMap(lst []ledis.FVPair) {
+	m := make(map[string]interface{}, len(lst))
+	for _, p := range lst {
+		m[string(p.Field)] = restBulk(p.Value)
+	}
+	w.result = m
+}
+
+func (w *restWriter) writeSliceSet(lst [][]byte) {
+	w.writeSliceArray(lst)
+}
+
+func (w *restWriter) writeScore(n int64) {
+	w.result = n
+}
+
+func (w *restWriter) writeBool(b bool) {
+	w.result = b
+}
+
+func (w *restWriter) flush() {
+	var reply map[string]interface{}
+	status := http.StatusOK
+	if w.err != nil {
+		reply = map[string]interface{}{"error": w.err.Error()}
+		status = restErrorStatus(w.err)
+	} else {
+		reply = map[string]interface{}{"result": w.result}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(reply); err != nil {
+		status = http.StatusInternalServerError
+		buf.Reset()
+		json.NewEncoder(&buf).Encode(map[string]interface{}{"error": err.Error()})
+	}
+
+	w.w.Header().Set("Content-Type", "application/json")
+	w.w.WriteHeader(status)
+	w.w.Write(buf.Bytes())
+}
+
+func restErrorStatus(err error) int {
+	switch err {
+	case errRestMethod:
+		return http.StatusMethodNotAllowed
+	case errRestRateLimit:
+		return http.StatusTooManyRequests
+	case ErrNotAuthenticated, ErrAuthenticationFailure:
+		return http.StatusUnauthorized
+	case ErrNoPermission:
+		return http.StatusForbidden
+	case ErrNotFound:
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func restBulk(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
+
+func restSliceArray(lst [][]byte) interface{} {
+	if lst == nil {
+		return nil
+	}
+
+	ay := make([]interface{}, len(lst))
+	for i, b := range lst {
+		ay[i] = restBulk(b)
+	}
+	return ay
+}
+
+func restArray(lst []interface{}) interface{} {
+	if lst == nil {
+		return nil
+	}
+
+	ay := make([]interface{}, len(lst))
+	for i := range lst {
+		switch v := lst[i].(type) {
+		case []interface{}:
+			ay[i] = restArray(v)
+		case [][]byte:
+			ay[i] = restSliceArray(v)
+		case []byte:
+			ay[i] = restBulk(v)
+		case nil, int64, string:
+			ay[i] = v
+		case error:
+			ay[i] = map[string]interface{}{"error": v.Error()}
+		default:
+			panic(fmt.Sprintf("invalid array type %T %v", lst[i], v))
+		}
+	}
+	return ay
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// restLimiter keeps a token bucket for each user of the /cmd API.
+type restLimiter struct {
+	m       sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRestLimiter() *restLimiter {
+	return &restLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token from the bucket of the user, it is false if the bucket
+// is empty.
+func (l *restLimiter) allow(user string, cfg *config.HttpRateLimitConfig) bool {
+	rate := cfg.Rate
+	if r, ok := cfg.Users[user]; ok {
+		rate = r
+	}
+	if rate <= 0 {
+		return true
+	}
+
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = rate
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[user]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		l.buckets[user] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func restDo(t *testing.T, client *http.Client, url string, token string, args ...interface{}) (int, map[string]interface{}) {
+	body, _ := json.Marshal(map[string]interface{}{"args": args})
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	var reply map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&reply); err != nil {
+		t.Fatal(err)
+	}
+	return r.StatusCode, reply
+}
+
+func checkRestResult(t *testing.T, cmd string, expected interface{}, args ...interface{}) {
+	url := fmt.Sprintf("http://%s/cmd/%s", testApp.cfg.HttpAddr, cmd)
+	status, reply := restDo(t, http.DefaultClient, url, "", args...)
+	if status != http.StatusOK {
+		t.Fatal(cmd, status, reply)
+	} else if !reflect.DeepEqual(reply["result"], expected) {
+		t.Fatalf("%s: %#v != %#v", cmd, reply["result"], expected)
+	}
+}
+
+func TestRestAPI(t *testing.T) {
+	startTestApp()
+
+	checkRestResult(t, "set", "OK", "test_rest_kv", "a")
+	checkRestResult(t, "get", "a", "test_rest_kv")
+	checkRestResult(t, "get", nil, "test_rest_kv_2")
+	checkRestResult(t, "incrby", float64(3), "test_rest_kv_2", 3)
+
+	checkRestResult(t, "hmset", "OK", "test_rest_hash", "a", "1", "b", "2")
+	checkRestResult(t, "hgetall", map[string]interface{}{"a": "1", "b": "2"}, "test_rest_hash")
+	checkRestResult(t, "hmget", []interface{}{"1", nil}, "test_rest_hash", "a", "c")
+	checkRestResult(t, "hexists", true, "test_rest_hash", "a")
+
+	checkRestResult(t, "rpush", float64(2), "test_rest_list", "a", "b")
+	checkRestResult(t, "lrange", []interface{}{"a", "b"}, "test_rest_list", 0, -1)
+
+	checkRestResult(t, "sadd", float64(1), "test_rest_set", "a")
+	checkRestResult(t, "smembers", []interface{}{"a"}, "test_rest_set")
+	checkRestResult(t, "sismember", false, "test_rest_set", "b")
+
+	checkRestResult(t, "zadd", float64(2), "test_rest_zset", 1, "a", 2, "b")
+	checkRestResult(t, "zrange", []interface{}{"a", float64(1), "b", float64(2)}, "test_rest_zset", 0, -1, "withscores")
+	checkRestResult(t, "zscore", float64(2), "test_rest_zset", "b")
+
+	checkRestResult(t, "xadd", "1-1", "test_rest_stream", "1-1", "f", "v")
+	checkRestResult(t, "xlen", float64(1), "test_rest_stream")
+
+	checkRestResult(t, "pfadd", float64(1), "test_rest_hll", "a", "b")
+	checkRestResult(t, "pfcount", float64(2), "test_rest_hll")
+
+	url := fmt.Sprintf("http://%s/cmd/", testApp.cfg.HttpAddr)
+
+	if r, err := http.Get(url + "get"); err != nil {
+		t.Fatal(err)
+	} else if r.Body.Close(); r.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal(r.StatusCode)
+	}
+
+	if status, reply := restDo(t, http.DefaultClient, url+"test_rest_unknown", ""); status != http.StatusNotFound {
+		t.Fatal(status, reply)
+	}
+
+	if status, reply := restDo(t, http.DefaultClient, url+"incr", "", "test_rest_kv"); status != http.StatusBadRequest || reply["error"] == nil {
+		t.Fatal(status, reply)
+	} else if _, ok := reply["result"]; ok {
+		t.Fatal(reply)
+	}
+
+	// the ACL user of the bearer token
+	checkRestResult(t, "acladd", "OK", "test_rest_user", "pass", "commands", "get", "keys", "test_rest_*")
+	defer checkRestResult(t, "acldel", float64(1), "test_rest_user")
+
+	if status, reply := restDo(t, http.DefaultClient, url+"get", "test_rest_user:pass", "test_rest_kv"); status != http.StatusOK || reply["result"] != "a" {
+		t.Fatal(status, reply)
+	} else if status, reply := restDo(t, http.DefaultClient, url+"set", "test_rest_user:pass", "test_rest_kv", "b"); status != http.StatusForbidden {
+		t.Fatal(status, reply)
+	} else if status, reply := restDo(t, http.DefaultClient, url+"get", "test_rest_user:wrong", "test_rest_kv"); status != http.StatusUnauthorized {
+		t.Fatal(status, reply)
+	}
+
+	// the bucket of the user has one token, the others are not limited
+	testApp.cfg.HttpRateLimit.Users = map[string]int{"test_rest_user": 1}
+	defer func() { testApp.cfg.HttpRateLimit.Users = nil }()
+
+	if status, _ := restDo(t, http.DefaultClient, url+"get", "test_rest_user:pass", "test_rest_kv"); status != http.StatusOK {
+		t.Fatal(status)
+	} else if status, _ := restDo(t, http.DefaultClient, url+"get", "test_rest_user:pass", "test_rest_kv"); status != http.StatusTooManyRequests {
+		t.Fatal(status)
+	}
+	checkRestResult(t, "get", "a", "test_rest_kv")
+}
+
+func TestRestAPIHTTP2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledis_rest_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, dir, "ca", nil)
+	newTestCert(t, dir, "server", ca)
+
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = path.Join(dir, "data")
+	cfg.Addr = "127.0.0.1:11192"
+	cfg.HttpAddr = "127.0.0.1:11193"
+	cfg.TLS = config.TLS{
+		Enabled:     true,
+		Certificate: path.Join(dir, "server.crt"),
+		Key:         path.Join(dir, "server.key"),
+	}
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("https://%s/cmd/ping", cfg.HttpAddr), nil)
+	r, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+
+	if r.ProtoMajor != 2 || r.StatusCode != http.StatusOK {
+		t.Fatal(r.Proto, r.StatusCode)
+	}
+}
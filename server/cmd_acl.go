@@ -0,0 +1,195 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+)
+
+// commandKeys returns the keys in the arguments of cmd for the ACL check.
+func commandKeys(cmd string, args [][]byte) [][]byte {
+	c, ok := commands[cmd]
+	if !ok || len(args) == 0 {
+		return nil
+	}
+
+	var keys [][]byte
+	if spec := c.keys; spec.first > 0 {
+		last := spec.last
+		if last < 0 {
+			last += len(args) + 1
+		}
+		if last > len(args) {
+			last = len(args)
+		}
+		for i := spec.first; i <= last; i += spec.step {
+			keys = append(keys, args[i-1])
+		}
+	}
+
+	if c.keys.movable != nil {
+		keys = append(keys, c.keys.movable(args)...)
+	}
+	return keys
+}
+
+// commandKeySpec returns the key positions of cmd, movable is true if the
+// keys are found by the arguments, the positions only cover the fixed keys
+// then.
+func commandKeySpec(cmd string) (first int, last int, step int, movable bool) {
+	c, ok := commands[cmd]
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return c.keys.first, c.keys.last, c.keys.step, c.keys.movable != nil
+}
+
+// numKeysAt returns a keySpec movable which finds the keys after the number
+// of the keys at index i.
+func numKeysAt(i int) func(args [][]byte) [][]byte {
+	return func(args [][]byte) [][]byte {
+		return numKeys(args, i)
+	}
+}
+
+// streamKeys finds the keys of XREAD and XREADGROUP after STREAMS.
+func streamKeys(args [][]byte) [][]byte {
+	for i, arg := range args {
+		if strings.ToLower(hack.String(arg)) == "streams" {
+			streams := args[i+1:]
+			return streams[:len(streams)/2]
+		}
+	}
+	return nil
+}
+
+// numKeys returns the keys after the number of the keys at index i.
+func numKeys(args [][]byte, i int) [][]byte {
+	if i >= len(args) {
+		return nil
+	}
+
+	n, err := strconv.Atoi(hack.String(args[i]))
+	if err != nil || n < 0 || i+1+n > len(args) {
+		// the command rejects it later
+		return nil
+	}
+
+	return args[i+1 : i+1+n]
+}
+
+// aclAllowed reports whether the ACL user of the client can run the command
+// on its keys, the default user can run all.
+func (c *client) aclAllowed() bool {
+	if len(c.user) == 0 || c.cmd == "auth" || c.cmd == "reset" {
+		return true
+	}
+
+	u := c.app.ldb.ACLGetUser(c.user)
+	if u == nil || !u.AllowCommand(c.cmd) {
+		return false
+	}
+
+	for _, key := range commandKeys(c.cmd, c.args) {
+		if !u.AllowKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// ACLADD username password [COMMANDS pattern [pattern ...]] [KEYS pattern [pattern ...]]
+func acladdCommand(c *client) error {
+	args := c.args
+	if len(args) < 2 {
+		return ErrCmdParams
+	}
+
+	// the patterns are added to the last named list
+	var commands, keys []string
+	var patterns *[]string
+	for _, arg := range args[2:] {
+		switch strings.ToLower(hack.String(arg)) {
+		case "commands":
+			patterns = &commands
+		case "keys":
+			patterns = &keys
+		default:
+			if patterns == nil {
+				return ErrSyntax
+			}
+			*patterns = append(*patterns, string(arg))
+		}
+	}
+
+	if err := c.app.ldb.ACLAddUser(string(args[0]), string(args[1]), commands, keys); err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// ACLDEL username [username ...]
+func acldelCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	var n int64
+	for _, name := range c.args {
+		if ok, err := c.app.ldb.ACLDelUser(string(name)); err != nil {
+			return err
+		} else if ok {
+			n++
+		}
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
+// ACLLIST
+func acllistCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	c.resp.writeSliceArray(stringsToSlices(c.app.ldb.ACLUsers()))
+	return nil
+}
+
+// ACLGETUSER username
+func aclgetuserCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	u := c.app.ldb.ACLGetUser(string(c.args[0]))
+	if u == nil {
+		c.resp.writeArray(nil)
+		return nil
+	}
+
+	c.resp.writeArray([]interface{}{
+		[]byte("commands"), stringsToSlices(u.Commands),
+		[]byte("keys"), stringsToSlices(u.Keys),
+	})
+	return nil
+}
+
+func stringsToSlices(ss []string) [][]byte {
+	ay := make([][]byte, len(ss))
+	for i, s := range ss {
+		ay[i] = []byte(s)
+	}
+	return ay
+}
+
+func init() {
+	register("acladd", acladdCommand)
+	register("acldel", acldelCommand)
+	register("acllist", acllistCommand)
+	register("aclgetuser", aclgetuserCommand)
+}
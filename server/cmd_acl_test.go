@@ -0,0 +1,148 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestACLCommands(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c2 := getTestConn()
+	defer c2.Close()
+	// the pooled connection is reused by the other tests
+	defer c2.Do("reset")
+
+	if _, err := c.Do("acladd", "acl_alice", "pass", "keys"); err != nil {
+		t.Fatal(err)
+	} else if _, err := c.Do("acladd", "acl_alice", "pass", "get"); err == nil {
+		t.Fatal("must error, pattern without a list")
+	}
+
+	if ok, err := goredis.String(c.Do("acladd", "acl_alice", "pass", "commands", "get", "set", "mset", "keys", "user:*")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if names, err := goredis.Strings(c.Do("acllist")); err != nil {
+		t.Fatal(err)
+	} else if len(names) != 1 || names[0] != "acl_alice" {
+		t.Fatal(names)
+	}
+
+	if ay, err := goredis.Values(c.Do("aclgetuser", "acl_alice")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 {
+		t.Fatal(ay)
+	} else if keys, _ := goredis.Strings(ay[3], nil); len(keys) != 1 || keys[0] != "user:*" {
+		t.Fatal(keys)
+	}
+
+	if ay, err := c.Do("aclgetuser", "acl_bob"); err != nil || ay != nil {
+		t.Fatal(ay, err)
+	}
+
+	if _, err := c2.Do("auth", "acl_alice", "bad"); err == nil {
+		t.Fatal("must error")
+	} else if _, err := c2.Do("auth", "acl_alice", "pass"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c2.Do("set", "user:1", "a"); err != nil {
+		t.Fatal(err)
+	} else if v, err := goredis.String(c2.Do("get", "user:1")); err != nil || v != "a" {
+		t.Fatal(v, err)
+	}
+
+	for _, args := range [][]interface{}{
+		{"set", "order:1", "a"},
+		{"mset", "user:2", "a", "order:2", "b"},
+		{"del", "user:1"},
+		{"acllist"},
+	} {
+		if _, err := c2.Do(args[0].(string), args[1:]...); err == nil || err.Error() != ErrNoPermission.Error() {
+			t.Fatal(args, err)
+		}
+	}
+
+	if n, err := goredis.Int(c.Do("acldel", "acl_alice", "acl_bob")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	// the deleted user can not run any command
+	if _, err := c2.Do("get", "user:1"); err == nil {
+		t.Fatal("must error")
+	}
+
+	// EXEC holds the write lock which they wait for
+	for _, args := range [][]interface{}{
+		{"acladd", "acl_carol", "pass"},
+		{"acldel", "acl_carol"},
+	} {
+		c.Do("multi")
+		if _, err := c.Do(args[0].(string), args[1:]...); err == nil {
+			t.Fatal("must error in multi", args)
+		} else if _, err := c.Do("exec"); err == nil {
+			t.Fatal("must error, exec abort")
+		}
+	}
+
+	if u, err := c.Do("aclgetuser", "acl_carol"); err != nil || u != nil {
+		t.Fatal(u, err)
+	}
+}
+
+func TestCommandKeys(t *testing.T) {
+	tbl := []struct {
+		args []string
+		keys []string
+	}{
+		{[]string{"get", "a"}, []string{"a"}},
+		{[]string{"ping"}, nil},
+		{[]string{"mset", "a", "1", "b", "2"}, []string{"a", "b"}},
+		{[]string{"blpop", "a", "b", "0"}, []string{"a", "b"}},
+		{[]string{"lmpop", "2", "a", "b", "left"}, []string{"a", "b"}},
+		{[]string{"zunionstore", "d", "2", "a", "b"}, []string{"d", "a", "b"}},
+		{[]string{"xread", "count", "1", "streams", "a", "b", "0", "0"}, []string{"a", "b"}},
+		{[]string{"xreadgroup", "group", "g", "c", "streams", "a", "b", ">", ">"}, []string{"a", "b"}},
+		{[]string{"xgroup", "create", "a", "g", "$"}, []string{"a"}},
+		{[]string{"lmove", "a", "b", "left", "right"}, []string{"a", "b"}},
+		{[]string{"eval", "return 1", "1", "a", "arg"}, []string{"a"}},
+	}
+
+	for _, tt := range tbl {
+		args := make([][]byte, len(tt.args)-1)
+		for i, arg := range tt.args[1:] {
+			args[i] = []byte(arg)
+		}
+
+		keys := commandKeys(tt.args[0], args)
+		if len(keys) != len(tt.keys) {
+			t.Fatal(tt.args, len(keys))
+		}
+		for i, key := range keys {
+			if string(key) != tt.keys[i] {
+				t.Fatal(tt.args, string(key))
+			}
+		}
+	}
+}
+
+func TestACLWriteCommands(t *testing.T) {
+	for _, cmd := range []string{"acladd", "acldel"} {
+		if !isWriteCommand(cmd, nil) {
+			t.Fatal(cmd)
+		}
+	}
+
+	for _, cmd := range []string{"acllist", "aclgetuser"} {
+		if isWriteCommand(cmd, nil) {
+			t.Fatal(cmd)
+		}
+	}
+}
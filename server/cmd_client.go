@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/hack"
+)
+
+// validClientName reports whether name has no spaces, newlines or other
+// special characters like redis.
+func validClientName(name []byte) bool {
+	for _, b := range name {
+		if b < '!' || b > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// info returns the CLIENT LIST line of the client.
+func (c *client) info(now time.Time) string {
+	age := now.Sub(c.createTime) / time.Second
+	idle := now.Sub(time.Unix(0, c.lastTime.Get())) / time.Second
+
+	return fmt.Sprintf("id=%d addr=%s name=%s age=%d idle=%d flags=%s cmd=%s",
+		c.id, c.remoteAddr, c.name.Get(), age, idle, c.flags.Get(), c.lastCmd.Get())
+}
+
+// parseOnOff parses ON or OFF.
+func parseOnOff(arg []byte) (bool, error) {
+	switch strings.ToLower(hack.String(arg)) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return false, ErrSyntax
+	}
+}
+
+// CLIENT SETNAME name | GETNAME | LIST | KILL ID id | NO-EVICT ON|OFF | NO-TOUCH ON|OFF
+func clientCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	args := c.args[1:]
+	switch strings.ToLower(hack.String(c.args[0])) {
+	case "setname":
+		if len(args) != 1 {
+			return ErrCmdParams
+		} else if !validClientName(args[0]) {
+			return ErrClientName
+		}
+		c.name.Set(string(args[0]))
+		c.resp.writeStatus(OK)
+	case "getname":
+		if len(args) != 0 {
+			return ErrCmdParams
+		}
+
+		if name := c.name.Get(); len(name) > 0 {
+			c.resp.writeBulk([]byte(name))
+		} else {
+			c.resp.writeBulk(nil)
+		}
+	case "list":
+		if len(args) != 0 {
+			return ErrCmdParams
+		}
+
+		var buf bytes.Buffer
+		now := time.Now()
+		c.app.rcs.Range(func(_, v interface{}) bool {
+			buf.WriteString(v.(*respClient).info(now))
+			buf.WriteByte('\n')
+			return true
+		})
+		c.resp.writeBulk(buf.Bytes())
+	case "kill":
+		if len(args) != 2 || strings.ToLower(hack.String(args[0])) != "id" {
+			return ErrSyntax
+		}
+
+		id, err := strconv.ParseUint(hack.String(args[1]), 10, 64)
+		if err != nil {
+			return ErrValue
+		}
+
+		n := int64(0)
+		if id == c.id {
+			c.closeAfterReply = true
+			n = 1
+		} else if rc := c.app.respClient(id); rc != nil {
+			rc.kill()
+			n = 1
+		}
+		c.resp.writeInteger(n)
+	case "no-evict", "no-touch":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		on, err := parseOnOff(args[0])
+		if err != nil {
+			return err
+		}
+
+		if strings.ToLower(hack.String(c.args[0])) == "no-evict" {
+			c.noEvict = on
+		} else {
+			c.noTouch = on
+		}
+		c.resp.writeStatus(OK)
+	default:
+		return ErrCmdParams
+	}
+
+	return nil
+}
+
+func init() {
+	register("client", clientCommand)
+}
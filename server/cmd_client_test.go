@@ -0,0 +1,113 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+)
+
+func clientListLine(t *testing.T, c *goredis.Conn, name string) string {
+	list, err := goredis.String(c.Do("client", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(list, "\n") {
+		if strings.Contains(line, " name="+name+" ") {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestClient(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if name, err := c.Do("client", "getname"); err != nil || name != nil {
+		t.Fatal(name, err)
+	}
+
+	if _, err := c.Do("client", "setname", "a b"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if s, err := goredis.String(c.Do("client", "setname", "test_client")); err != nil || s != OK {
+		t.Fatal(s, err)
+	} else if name, err := goredis.String(c.Do("client", "getname")); err != nil || name != "test_client" {
+		t.Fatal(name, err)
+	}
+	defer c.Do("client", "setname", "")
+
+	s, err := goredis.Connect(testApp.cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Do("client", "setname", "test_client_2")
+	s.Send("subscribe", "test_client")
+	receiveStrings(t, s)
+
+	line := clientListLine(t, c.Conn, "test_client_2")
+	if !strings.Contains(line, " flags=P cmd=subscribe") {
+		t.Fatal(line)
+	}
+
+	var id string
+	for _, field := range strings.Fields(line) {
+		if strings.HasPrefix(field, "id=") {
+			id = field[3:]
+		}
+	}
+
+	if n, err := goredis.Int(c.Do("client", "kill", "id", id)); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+
+	for i := 0; i < 100 && len(clientListLine(t, c.Conn, "test_client_2")) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if line := clientListLine(t, c.Conn, "test_client_2"); len(line) > 0 {
+		t.Fatal(line)
+	}
+
+	if n, err := goredis.Int(c.Do("client", "kill", "id", id)); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+
+	if line := clientListLine(t, c.Conn, "test_client"); !strings.Contains(line, " flags=N cmd=client") {
+		t.Fatal(line)
+	}
+}
+
+func TestClientNoEvictNoTouch(t *testing.T) {
+	c, err := goredis.Connect(testApp.cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Do("client", "setname", "test_client_no_touch")
+	if _, err := c.Do("client", "no-touch", "maybe"); err == nil {
+		t.Fatal("must error")
+	}
+
+	for _, cmd := range []string{"no-evict", "no-touch"} {
+		if s, err := goredis.String(c.Do("client", cmd, "on")); err != nil || s != OK {
+			t.Fatal(cmd, s, err)
+		}
+	}
+
+	c.Do("get", "test_client_no_touch")
+	if line := clientListLine(t, c, "test_client_no_touch"); !strings.Contains(line, " flags=eT cmd=get") {
+		t.Fatal(line)
+	}
+
+	c.Do("client", "no-evict", "off")
+	if line := clientListLine(t, c, "test_client_no_touch"); !strings.Contains(line, " flags=T ") {
+		t.Fatal(line)
+	}
+}
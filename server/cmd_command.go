@@ -0,0 +1,179 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+)
+
+// isWriteCommand returns whether cmd with the arguments writes the keyspace,
+// a script is only checked by the commands it runs.
+func isWriteCommand(cmd string, args [][]byte) bool {
+	c, ok := commands[cmd]
+	if !ok || c.flags&flagWrite == 0 {
+		return false
+	} else if c.writes != nil {
+		return c.writes(args)
+	}
+	return true
+}
+
+// commandFlags returns the redis COMMAND flags of cmd.
+func commandFlags(cmd string, hasKeys bool, movable bool) []interface{} {
+	flags := []interface{}{}
+	switch {
+	case commandHas(cmd, flagRead) && hasKeys:
+		flags = append(flags, "readonly")
+	case commandHas(cmd, flagWrite):
+		flags = append(flags, "write")
+	}
+
+	if commandHas(cmd, flagAdmin) {
+		flags = append(flags, "admin")
+	}
+	if commandHas(cmd, flagPubSub) {
+		flags = append(flags, "pubsub")
+	}
+	if commandHas(cmd, flagBlocking) {
+		flags = append(flags, "blocking")
+	}
+	if movable {
+		flags = append(flags, "movablekeys")
+	}
+	return flags
+}
+
+// commandInfo returns the redis COMMAND INFO reply of cmd, name, arity,
+// flags, the first key, the last key and the step, or nil if cmd is unknown.
+func commandInfo(cmd string) []interface{} {
+	if _, ok := commands[cmd]; !ok {
+		return nil
+	}
+
+	arity := -1
+	if doc, ok := commandDocs[cmd]; ok {
+		arity = doc.arity
+	}
+
+	first, last, step, movable := commandKeySpec(cmd)
+	return []interface{}{
+		[]byte(cmd),
+		int64(arity),
+		commandFlags(cmd, first > 0, movable),
+		int64(first),
+		int64(last),
+		int64(step),
+	}
+}
+
+// commandNames returns the names of the commands in order, all if names is
+// empty.
+func commandNames(names [][]byte) []string {
+	if len(names) == 0 {
+		all := make([]string, 0, len(commands))
+		for name := range commands {
+			all = append(all, name)
+		}
+		sort.Strings(all)
+		return all
+	}
+
+	ay := make([]string, len(names))
+	for i, name := range names {
+		ay[i] = strings.ToLower(string(name))
+	}
+	return ay
+}
+
+// commandDocReply returns the doc of cmd like redis COMMAND DOCS, the
+// summary, the group and the syntax, with the sub-commands if any.
+func commandDocReply(cmd string, doc commandDoc) []interface{} {
+	syntax := strings.ToUpper(cmd)
+	if doc.arguments != "-" {
+		syntax += " " + doc.arguments
+	}
+
+	reply := []interface{}{
+		[]byte("summary"), []byte(doc.summary),
+		[]byte("group"), []byte(strings.ToLower(doc.group)),
+		[]byte("syntax"), []byte(syntax),
+	}
+
+	var names []string
+	for name := range commandDocs {
+		if strings.HasPrefix(name, cmd+" ") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var subs []interface{}
+	for _, name := range names {
+		subs = append(subs, []byte(name), commandDocReply(name, commandDocs[name]))
+	}
+	if len(subs) > 0 {
+		reply = append(reply, []byte("subcommands"), subs)
+	}
+	return reply
+}
+
+// COMMAND [COUNT | INFO [name ...] | DOCS [name ...] | GETKEYS command [arg ...]]
+func commandCommand(c *client) error {
+	args := c.args
+	if len(args) == 0 {
+		names := commandNames(nil)
+		ay := make([]interface{}, len(names))
+		for i, name := range names {
+			ay[i] = commandInfo(name)
+		}
+		c.resp.writeArray(ay)
+		return nil
+	}
+
+	switch strings.ToLower(hack.String(args[0])) {
+	case "count":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+		c.resp.writeInteger(int64(len(commands)))
+	case "info":
+		names := commandNames(args[1:])
+		ay := make([]interface{}, len(names))
+		for i, name := range names {
+			if info := commandInfo(name); info != nil {
+				ay[i] = info
+			}
+		}
+		c.resp.writeArray(ay)
+	case "docs":
+		var ay []interface{}
+		for _, name := range commandNames(args[1:]) {
+			if doc, ok := commandDocs[name]; ok && commands[name] != nil {
+				ay = append(ay, []byte(name), commandDocReply(name, doc))
+			}
+		}
+		if ay == nil {
+			ay = []interface{}{}
+		}
+		c.resp.writeArray(ay)
+	case "getkeys":
+		if len(args) < 2 {
+			return ErrCmdParams
+		}
+
+		cmd := strings.ToLower(hack.String(args[1]))
+		if _, ok := commands[cmd]; !ok {
+			return ErrNotFound
+		}
+		c.resp.writeSliceArray(commandKeys(cmd, args[2:]))
+	default:
+		return ErrCmdParams
+	}
+
+	return nil
+}
+
+func init() {
+	register("command", commandCommand)
+}
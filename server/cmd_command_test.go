@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestCommandDocs(t *testing.T) {
+	for name, cmd := range commands {
+		if cmd.f == nil {
+			t.Errorf("%s is not registered", name)
+		}
+		if doc, ok := commandDocs[name]; !ok {
+			t.Errorf("%s is not in doc/commands.json", name)
+		} else if len(doc.summary) == 0 || strings.HasPrefix(doc.summary, "#") {
+			t.Errorf("%s has no summary in doc/commands.md", name)
+		}
+	}
+}
+
+func TestCommandKeySpec(t *testing.T) {
+	args := make([][]byte, 7)
+	for i := range args {
+		args[i] = []byte(fmt.Sprintf("a%d", i+1))
+	}
+
+	for name := range commands {
+		first, last, step, movable := commandKeySpec(name)
+		if movable {
+			continue
+		}
+
+		var expected [][]byte
+		if first > 0 {
+			if last < 0 {
+				last += len(args) + 1
+			}
+			for i := first; i <= last; i += step {
+				expected = append(expected, args[i-1])
+			}
+		}
+
+		if keys := commandKeys(name, args); !reflect.DeepEqual(keys, expected) {
+			t.Errorf("%s: %q != %q", name, keys, expected)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if n, err := goredis.Int(c.Do("command", "count")); err != nil || n != len(commands) {
+		t.Fatal(n, err)
+	}
+
+	if ay, err := goredis.Values(c.Do("command")); err != nil || len(ay) != len(commands) {
+		t.Fatal(len(ay), err)
+	}
+
+	ay, err := goredis.Values(c.Do("command", "info", "get", "MSET", "test_command_unknown"))
+	if err != nil || len(ay) != 3 {
+		t.Fatal(ay, err)
+	} else if ay[2] != nil {
+		t.Fatal(ay[2])
+	}
+
+	for i, expected := range []struct {
+		name              string
+		arity             int
+		flag              string
+		first, last, step int
+	}{
+		{"get", 2, "readonly", 1, 1, 1},
+		{"mset", -3, "write", 1, -1, 2},
+	} {
+		info, _ := goredis.Values(ay[i], nil)
+		if len(info) != 6 {
+			t.Fatal(info)
+		} else if name, _ := goredis.String(info[0], nil); name != expected.name {
+			t.Fatal(name)
+		} else if flags, _ := goredis.Values(info[2], nil); len(flags) != 1 || flags[0] != expected.flag {
+			t.Fatal(flags)
+		}
+
+		for j, n := range []int{1, 3, 4, 5} {
+			if v, _ := goredis.Int(info[n], nil); v != []int{expected.arity, expected.first, expected.last, expected.step}[j] {
+				t.Fatal(expected.name, n, v)
+			}
+		}
+	}
+
+	if ay, err := goredis.Values(c.Do("command", "docs", "config", "test_command_unknown")); err != nil || len(ay) != 2 {
+		t.Fatal(ay, err)
+	} else if doc, _ := goredis.Values(ay[1], nil); len(doc) != 8 {
+		t.Fatal(doc)
+	} else if syntax, _ := goredis.String(doc[5], nil); syntax != "CONFIG subcommand [argument ...]" {
+		t.Fatal(syntax)
+	} else if subs, _ := goredis.Values(doc[7], nil); len(subs) != 6 {
+		t.Fatal(subs)
+	}
+
+	if keys, err := goredis.Strings(c.Do("command", "getkeys", "mset", "a", "1", "b", "2")); err != nil || !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatal(keys, err)
+	}
+
+	if _, err := c.Do("command", "getkeys", "test_command_unknown"); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/hack"
+)
+
+// the longest DEBUG SLEEP
+const debugMaxSleep = 30 * time.Second
+
+// the bits of the redis LRU clock
+const debugLRUClockMax = 1<<24 - 1
+
+// debugLocks reports whether DEBUG waits for the write lock, which EXEC
+// holds, or sleeps, which blocks the other writes in EXEC.
+func debugLocks(args [][]byte) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	sub := strings.ToLower(hack.String(args[0]))
+	return sub == "reload" || sub == "sleep"
+}
+
+// DEBUG SLEEP seconds | JMAP | QUICKLIST-PACKED-THRESHOLD bytes | OBJECT key |
+// RELOAD
+func debugCommand(c *client) error {
+	var enabled bool
+	c.app.cfg.View(func() { enabled = c.app.cfg.DebugCommandsEnabled })
+	if !enabled {
+		return ErrDebugDisabled
+	}
+
+	args := c.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(hack.String(args[0])) {
+	case "sleep":
+		if len(args) != 2 {
+			return ErrCmdParams
+		}
+
+		seconds, err := strconv.ParseFloat(hack.String(args[1]), 64)
+		if err != nil {
+			return ErrFloat
+		}
+
+		d := time.Duration(seconds * float64(time.Second))
+		if d < 0 || d > debugMaxSleep {
+			return ErrDebugSleep
+		}
+
+		select {
+		case <-time.After(d):
+		case <-c.app.quit:
+		}
+	case "jmap":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+	case "quicklist-packed-threshold":
+		if len(args) != 2 {
+			return ErrCmdParams
+		}
+
+		// ledisdb has one value limit for all the data types, it is only
+		// changed until the restart
+		size, err := strconv.Atoi(hack.String(args[1]))
+		if err != nil || size <= 0 {
+			return ErrValue
+		}
+		c.app.cfg.Update(func() { c.app.cfg.ZiplistMaxValueSize = size })
+	case "object":
+		if len(args) != 2 {
+			return ErrCmdParams
+		}
+
+		o, err := c.db.DebugObject(args[1])
+		if err != nil {
+			return err
+		}
+
+		// the values have no address, the checksum of the key tells them
+		// apart instead
+		c.resp.writeStatus(fmt.Sprintf("Value at:0x%08x refcount:1 encoding:%s serializedlength:%d lru:%d lru_seconds_idle:%d type:%s",
+			crc32.ChecksumIEEE(args[1]), o.Encoding, o.SerializedLength, o.LRU&debugLRUClockMax, o.IdleSeconds, o.Type))
+		return nil
+	case "reload":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		if err := c.app.ldb.Reload(); err != nil {
+			return err
+		}
+	default:
+		return ErrCmdParams
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func init() {
+	register("debug", debugCommand)
+}
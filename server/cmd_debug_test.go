@@ -0,0 +1,129 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestDebug(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("debug", "jmap"); err == nil {
+		t.Fatal("must error when disabled")
+	}
+
+	cfg := testApp.cfg
+	var size int
+	cfg.Update(func() {
+		cfg.DebugCommandsEnabled = true
+		size = cfg.ZiplistMaxValueSize
+	})
+	defer cfg.Update(func() {
+		cfg.DebugCommandsEnabled = false
+		cfg.ZiplistMaxValueSize = size
+	})
+
+	start := time.Now()
+	if s, err := goredis.String(c.Do("debug", "sleep", 0.1)); err != nil || s != OK {
+		t.Fatal(s, err)
+	} else if d := time.Since(start); d < 100*time.Millisecond {
+		t.Fatal(d)
+	}
+
+	for _, args := range [][]interface{}{
+		{"sleep", 31},
+		{"sleep", -1},
+		{"sleep", "a"},
+		{"quicklist-packed-threshold", 0},
+		{"unknown"},
+	} {
+		if _, err := c.Do("debug", args...); err == nil {
+			t.Fatal(args, "must error")
+		}
+	}
+
+	if s, err := goredis.String(c.Do("debug", "jmap")); err != nil || s != OK {
+		t.Fatal(s, err)
+	}
+
+	key := "test_debug_list"
+	c.Do("lclear", key)
+	c.Do("rpush", key, "12345")
+	defer c.Do("lclear", key)
+
+	if s, err := goredis.String(c.Do("debug", "quicklist-packed-threshold", 4)); err != nil || s != OK {
+		t.Fatal(s, err)
+	} else if enc, _ := goredis.String(c.Do("object", "encoding", key)); enc != "quicklist" {
+		t.Fatal(enc)
+	}
+
+	// a value compresses well
+	c.Do("set", "test_debug_kv", strings.Repeat("a", 1000))
+	defer c.Do("del", "test_debug_kv")
+
+	s, err := goredis.String(c.Do("debug", "object", "test_debug_kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string]string{}
+	for _, f := range strings.Fields(s) {
+		if i := strings.IndexByte(f, ':'); i > 0 {
+			fields[f[:i]] = f[i+1:]
+		}
+	}
+
+	if fields["encoding"] != "raw" || fields["type"] != "string" || fields["refcount"] != "1" {
+		t.Fatal(s)
+	} else if n, err := strconv.Atoi(fields["serializedlength"]); err != nil || n <= 0 || n >= 1000 {
+		t.Fatal(s)
+	} else if n, err := strconv.Atoi(fields["lru_seconds_idle"]); err != nil || n < 0 {
+		t.Fatal(s)
+	} else if !strings.HasPrefix(s, "Value at:0x") {
+		t.Fatal(s)
+	}
+
+	if s, err = goredis.String(c.Do("debug", "object", key)); err != nil || !strings.Contains(s, " type:list") {
+		t.Fatal(s, err)
+	}
+
+	if _, err = c.Do("debug", "object", "test_debug_missing"); err == nil || !strings.Contains(err.Error(), "no such key") {
+		t.Fatal(err)
+	}
+
+	// the data and the writes survive the reload
+	if s, err = goredis.String(c.Do("debug", "reload")); err != nil || s != OK {
+		t.Fatal(s, err)
+	}
+
+	if v, err := goredis.String(c.Do("get", "test_debug_kv")); err != nil || len(v) != 1000 {
+		t.Fatal(len(v), err)
+	} else if n, err := goredis.Int(c.Do("rpush", key, "6")); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+
+	if _, err = c.Do("debug", "reload", "now"); err == nil {
+		t.Fatal("must error")
+	}
+
+	// EXEC holds the write lock which the reload waits for
+	for _, sub := range [][]interface{}{{"reload"}, {"sleep", "0.1"}} {
+		c.Do("multi")
+		if _, err = c.Do("debug", sub...); err == nil {
+			t.Fatal("must error in multi", sub)
+		} else if _, err = c.Do("exec"); err == nil {
+			t.Fatal("must error, exec abort")
+		}
+	}
+
+	c.Do("multi")
+	c.Do("debug", "object", "test_debug_kv")
+	if ay, err := goredis.Values(c.Do("exec")); err != nil || len(ay) != 1 {
+		t.Fatal(ay, err)
+	}
+}
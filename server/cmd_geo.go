@@ -0,0 +1,193 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+func parseGeoFloat(buf []byte) (float64, error) {
+	f, err := strconv.ParseFloat(hack.String(buf), 64)
+	if err != nil {
+		return 0, ErrFloat
+	}
+	return f, nil
+}
+
+func formatGeoDist(d float64) []byte {
+	return strconv.AppendFloat(nil, d, 'f', 4, 64)
+}
+
+func formatGeoCoord(lon float64, lat float64) []interface{} {
+	return []interface{}{
+		strconv.AppendFloat(nil, lon, 'g', 17, 64),
+		strconv.AppendFloat(nil, lat, 'g', 17, 64),
+	}
+}
+
+// GEOADD key longitude latitude member [longitude latitude member ...]
+func geoaddCommand(c *client) error {
+	args := c.args
+	if len(args) < 4 || (len(args)-1)%3 != 0 {
+		return ErrCmdParams
+	}
+
+	members := make([]ledis.GeoMember, 0, len(args)/3)
+	for i := 1; i < len(args); i += 3 {
+		lon, err := parseGeoFloat(args[i])
+		if err != nil {
+			return err
+		}
+
+		lat, err := parseGeoFloat(args[i+1])
+		if err != nil {
+			return err
+		}
+
+		members = append(members, ledis.GeoMember{Name: args[i+2], Lat: lat, Lon: lon})
+	}
+
+	if n, err := c.db.GeoAdd(args[0], members...); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+// GEODIST key member1 member2 [unit]
+func geodistCommand(c *client) error {
+	args := c.args
+	if len(args) != 3 && len(args) != 4 {
+		return ErrCmdParams
+	}
+
+	unit := "m"
+	if len(args) == 4 {
+		unit = strings.ToLower(hack.String(args[3]))
+	}
+
+	if d, err := c.db.GeoDist(args[0], args[1], args[2], unit); err == ledis.ErrScoreMiss {
+		c.resp.writeBulk(nil)
+	} else if err != nil {
+		return err
+	} else {
+		c.resp.writeBulk(formatGeoDist(d))
+	}
+
+	return nil
+}
+
+// GEOPOS key member [member ...]
+func geoposCommand(c *client) error {
+	args := c.args
+	if len(args) < 2 {
+		return ErrCmdParams
+	}
+
+	pos, err := c.db.GeoPos(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+
+	ay := make([]interface{}, len(pos))
+	for i, p := range pos {
+		if p != nil {
+			ay[i] = formatGeoCoord(p.Lon, p.Lat)
+		}
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+// GEORADIUS key longitude latitude radius m|km|ft|mi [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count] [ASC|DESC]
+func georadiusCommand(c *client) error {
+	args := c.args
+	if len(args) < 5 {
+		return ErrCmdParams
+	}
+
+	lon, err := parseGeoFloat(args[1])
+	if err != nil {
+		return err
+	}
+
+	lat, err := parseGeoFloat(args[2])
+	if err != nil {
+		return err
+	}
+
+	radius, err := parseGeoFloat(args[3])
+	if err != nil {
+		return err
+	}
+
+	unit := strings.ToLower(hack.String(args[4]))
+
+	var withCoord, withDist, withHash bool
+	var opts ledis.GeoRadiusOptions
+	for i := 5; i < len(args); i++ {
+		switch strings.ToLower(hack.String(args[i])) {
+		case "withcoord":
+			withCoord = true
+		case "withdist":
+			withDist = true
+		case "withhash":
+			withHash = true
+		case "asc":
+			opts.Sort = 1
+		case "desc":
+			opts.Sort = -1
+		case "count":
+			if i+1 >= len(args) {
+				return ErrSyntax
+			}
+
+			i++
+			if opts.Count, err = strconv.Atoi(hack.String(args[i])); err != nil || opts.Count <= 0 {
+				return ErrValue
+			}
+		default:
+			return ErrSyntax
+		}
+	}
+
+	res, err := c.db.GeoRadius(args[0], lat, lon, radius, unit, opts)
+	if err != nil {
+		return err
+	}
+
+	ay := make([]interface{}, len(res))
+	for i, r := range res {
+		if !withCoord && !withDist && !withHash {
+			ay[i] = r.Name
+			continue
+		}
+
+		item := []interface{}{r.Name}
+		if withDist {
+			item = append(item, formatGeoDist(r.Dist))
+		}
+		if withHash {
+			item = append(item, r.Hash)
+		}
+		if withCoord {
+			item = append(item, formatGeoCoord(r.Lon, r.Lat))
+		}
+		ay[i] = item
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+func init() {
+	register("geoadd", geoaddCommand)
+	register("geodist", geodistCommand)
+	register("geopos", geoposCommand)
+	register("georadius", georadiusCommand)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestGeo(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_geo"
+
+	if n, err := goredis.Int(c.Do("geoadd", key, 13.361389, 38.115556, "Palermo", 15.087269, 37.502669, "Catania")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if v, err := goredis.String(c.Do("geodist", key, "Palermo", "Catania", "km")); err != nil {
+		t.Fatal(err)
+	} else if v != "166.2742" {
+		t.Fatal(v)
+	}
+
+	if v, err := c.Do("geodist", key, "Palermo", "Rome"); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("geopos", key, "Palermo", "Rome")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[1] != nil {
+		t.Fatal(v)
+	} else if pos := v[0].([]interface{}); len(pos) != 2 {
+		t.Fatal(pos)
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("georadius", key, 15, 37, 200, "km", "ASC")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || string(v[0].([]byte)) != "Catania" || string(v[1].([]byte)) != "Palermo" {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("georadius", key, 15, 37, 200, "km", "WITHDIST", "COUNT", 1, "DESC")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 {
+		t.Fatal(v)
+	} else if item := v[0].([]interface{}); string(item[0].([]byte)) != "Palermo" || string(item[1].([]byte)) != "190.4424" {
+		t.Fatal(item)
+	}
+
+	if _, err := c.Do("georadius", key, 15, 37, 200, "parsec"); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -1,6 +1,11 @@
 package server
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/hack"
 	"github.com/siddontang/ledisdb/ledis"
 )
 
@@ -40,15 +45,10 @@ func hexistsCommand(c *client) error {
 		return ErrCmdParams
 	}
 
-	var n int64 = 1
 	if v, err := c.db.HGet(args[0], args[1]); err != nil {
 		return err
 	} else {
-		if v == nil {
-			n = 0
-		}
-
-		c.resp.writeInteger(n)
+		c.resp.writeBool(v != nil)
 	}
 	return nil
 }
@@ -156,7 +156,67 @@ func hgetallCommand(c *client) error {
 	if v, err := c.db.HGetAll(args[0]); err != nil {
 		return err
 	} else {
+		c.resp.writeFVPairMap(v)
+	}
+
+	return nil
+}
+
+// parseRandArgs parses key [count [opt]] for the random sampling commands,
+// single is true if count is not given.
+func parseRandArgs(args [][]byte, opt string) (count int, withOpt bool, single bool, err error) {
+	if len(args) < 1 || len(args) > 3 {
+		err = ErrCmdParams
+		return
+	}
+
+	if len(args) == 1 {
+		return 1, false, true, nil
+	}
+
+	if count, err = strconv.Atoi(hack.String(args[1])); err != nil {
+		err = ErrValue
+		return
+	}
+
+	if len(args) == 3 {
+		if strings.ToLower(hack.String(args[2])) != opt {
+			err = ErrSyntax
+			return
+		}
+		withOpt = true
+	}
+
+	return
+}
+
+// HRANDFIELD key [count [WITHVALUES]]
+func hrandfieldCommand(c *client) error {
+	args := c.args
+	count, withValues, single, err := parseRandArgs(args, "withvalues")
+	if err != nil {
+		return err
+	}
+
+	v, err := c.db.HRandField(args[0], count)
+	if err != nil {
+		return err
+	}
+
+	if single {
+		if len(v) == 0 {
+			c.resp.writeBulk(nil)
+		} else {
+			c.resp.writeBulk(v[0].Field)
+		}
+	} else if withValues {
 		c.resp.writeFVPairArray(v)
+	} else {
+		fields := make([][]byte, len(v))
+		for i, p := range v {
+			fields[i] = p.Field
+		}
+		c.resp.writeSliceArray(fields)
 	}
 
 	return nil
@@ -223,37 +283,20 @@ func hmclearCommand(c *client) error {
 }
 
 func hexpireCommand(c *client) error {
-	args := c.args
-	if len(args) != 2 {
-		return ErrCmdParams
-	}
-
-	duration, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
-	}
-
-	if v, err := c.db.HExpire(args[0], duration); err != nil {
-		return err
-	} else {
-		c.resp.writeInteger(v)
-	}
-
-	return nil
+	return expireGeneric(c, time.Second, ledis.HASH, c.db.HExpireWithJitter)
 }
 
 func hexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Second, ledis.HASH, c.db.HExpireAt)
+}
+
+func httlCommand(c *client) error {
 	args := c.args
-	if len(args) != 2 {
+	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	when, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
-	}
-
-	if v, err := c.db.HExpireAt(args[0], when); err != nil {
+	if v, err := c.db.HTTL(args[0]); err != nil {
 		return err
 	} else {
 		c.resp.writeInteger(v)
@@ -262,13 +305,21 @@ func hexpireAtCommand(c *client) error {
 	return nil
 }
 
-func httlCommand(c *client) error {
+func hpexpireCommand(c *client) error {
+	return expireGeneric(c, time.Millisecond, ledis.HASH, c.db.HExpireWithJitter)
+}
+
+func hpexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Millisecond, ledis.HASH, c.db.HPExpireAt)
+}
+
+func hpttlCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	if v, err := c.db.HTTL(args[0]); err != nil {
+	if v, err := c.db.HPTTL(args[0]); err != nil {
 		return err
 	} else {
 		c.resp.writeInteger(v)
@@ -310,6 +361,7 @@ func init() {
 	register("hexists", hexistsCommand)
 	register("hget", hgetCommand)
 	register("hgetall", hgetallCommand)
+	register("hrandfield", hrandfieldCommand)
 	register("hincrby", hincrbyCommand)
 	register("hkeys", hkeysCommand)
 	register("hlen", hlenCommand)
@@ -326,5 +378,8 @@ func init() {
 	register("hexpireat", hexpireAtCommand)
 	register("httl", httlCommand)
 	register("hpersist", hpersistCommand)
+	register("hpexpire", hpexpireCommand)
+	register("hpexpireat", hpexpireAtCommand)
+	register("hpttl", hpttlCommand)
 	register("hkeyexists", hkeyexistsCommand)
 }
@@ -309,3 +309,38 @@ func TestHashErrorParams(t *testing.T) {
 		t.Fatalf("invalid err of %v", err)
 	}
 }
+
+func TestHashRandField(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_hrandfield"
+
+	if _, err := goredis.Bytes(c.Do("hrandfield", key)); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	c.Do("hmset", key, "a", "1", "b", "2", "c", "3")
+
+	if f, err := goredis.String(c.Do("hrandfield", key)); err != nil {
+		t.Fatal(err)
+	} else if f != "a" && f != "b" && f != "c" {
+		t.Fatal(f)
+	}
+
+	if v, err := goredis.Strings(c.Do("hrandfield", key, 5)); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Strings(c.Do("hrandfield", key, -5, "withvalues")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 10 {
+		t.Fatal(v)
+	}
+
+	if _, err := c.Do("hrandfield", key, 1, "withscores"); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"time"
+
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+func pfaddCommand(c *client) error {
+	args := c.args
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.HLLAdd(args[0], args[1:]...); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func pfcountCommand(c *client) error {
+	args := c.args
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.HLLCount(args...); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func pfmergeCommand(c *client) error {
+	args := c.args
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	if err := c.db.HLLMerge(args[0], args[1:]...); err != nil {
+		return err
+	} else {
+		c.resp.writeStatus(OK)
+	}
+
+	return nil
+}
+
+func pfdelCommand(c *client) error {
+	args := c.args
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.HLLDel(args...); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func pfexpireCommand(c *client) error {
+	return expireGeneric(c, time.Second, ledis.HLL, c.db.HLLExpireWithJitter)
+}
+
+func pfexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Second, ledis.HLL, c.db.HLLExpireAt)
+}
+
+func pfttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.HLLTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func pfpexpireCommand(c *client) error {
+	return expireGeneric(c, time.Millisecond, ledis.HLL, c.db.HLLExpireWithJitter)
+}
+
+func pfpexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Millisecond, ledis.HLL, c.db.HLLPExpireAt)
+}
+
+func pfpttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.HLLPTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func pfpersistCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.HLLPersist(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func pfkeyexistsCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+	if n, err := c.db.HLLKeyExists(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+	return nil
+}
+
+func init() {
+	register("pfadd", pfaddCommand)
+	register("pfcount", pfcountCommand)
+	register("pfmerge", pfmergeCommand)
+
+	register("pfdel", pfdelCommand)
+	register("pfexpire", pfexpireCommand)
+	register("pfexpireat", pfexpireAtCommand)
+	register("pfttl", pfttlCommand)
+	register("pfpersist", pfpersistCommand)
+	register("pfpexpire", pfpexpireCommand)
+	register("pfpexpireat", pfpexpireAtCommand)
+	register("pfpttl", pfpttlCommand)
+	register("pfkeyexists", pfkeyexistsCommand)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestHLL(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key1 := "testdb_cmd_hll_1"
+	key2 := "testdb_cmd_hll_2"
+	key3 := "testdb_cmd_hll_3"
+
+	if n, err := goredis.Int(c.Do("pfadd", key1, "a", "b", "c")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pfadd", key1, "a")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pfadd", key2, "c", "d")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pfcount", key1, key2)); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatal(n)
+	}
+
+	if ok, err := goredis.String(c.Do("pfmerge", key3, key1, key2)); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if n, err := goredis.Int(c.Do("pfcount", key3)); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pfexpire", key3, 100)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pfttl", key3)); err != nil {
+		t.Fatal(err)
+	} else if n != 100 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pfdel", key1, key2, key3)); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pfkeyexists", key3)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+}
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/siddontang/go/hack"
 	"github.com/siddontang/ledisdb/ledis"
 )
 
@@ -69,6 +73,105 @@ func getsetCommand(c *client) error {
 	return nil
 }
 
+func getdelCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.GetDel(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeBulk(v)
+	}
+
+	return nil
+}
+
+// CAS key expected value
+func casCommand(c *client) error {
+	args := c.args
+	if len(args) != 3 {
+		return ErrCmdParams
+	}
+
+	if ok, err := c.db.CAS(args[0], args[1], args[2]); err != nil {
+		return err
+	} else {
+		c.resp.writeBool(ok)
+	}
+
+	return nil
+}
+
+// CAD key expected
+func cadCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	if ok, err := c.db.CAD(args[0], args[1]); err != nil {
+		return err
+	} else {
+		c.resp.writeBool(ok)
+	}
+
+	return nil
+}
+
+// getexWrites reports whether GETEX sets or removes the TTL, it is a read
+// without an option.
+func getexWrites(args [][]byte) bool {
+	return len(args) > 1
+}
+
+// GETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp|PERSIST]
+func getexCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 && len(args) != 2 && len(args) != 3 {
+		return ErrCmdParams
+	}
+
+	var opts ledis.GetExOptions
+	if len(args) == 2 {
+		if strings.ToLower(hack.String(args[1])) != "persist" {
+			return ErrSyntax
+		}
+		opts.Persist = true
+	} else if len(args) == 3 {
+		n, err := ledis.StrInt64(args[2], nil)
+		if err != nil {
+			return ErrValue
+		}
+
+		switch strings.ToLower(hack.String(args[1])) {
+		case "ex":
+			opts.TTL = time.Duration(n) * time.Second
+		case "px":
+			opts.TTL = time.Duration(n) * time.Millisecond
+		case "exat":
+			opts.ExpireAt = time.Unix(n, 0)
+		case "pxat":
+			opts.ExpireAt = time.Unix(0, n*int64(time.Millisecond))
+		default:
+			return ErrSyntax
+		}
+
+		if n <= 0 {
+			return ErrValue
+		}
+	}
+
+	if v, err := c.db.GetEx(args[0], opts); err != nil {
+		return err
+	} else {
+		c.resp.writeBulk(v)
+	}
+
+	return nil
+}
+
 func setnxCommand(c *client) error {
 	args := c.args
 	if len(args) != 2 {
@@ -244,29 +347,84 @@ func mgetCommand(c *client) error {
 	return nil
 }
 
-func expireCommand(c *client) error {
+// parseExpireOpts parses the options after the duration or the time of the
+// expire commands, [NX|XX|GT|LT ...] [JITTER fraction].
+func parseExpireOpts(args [][]byte) (cond ledis.ExpireCond, jitter float64, err error) {
+	for i := 0; i < len(args); i++ {
+		switch strings.ToLower(hack.String(args[i])) {
+		case "nx":
+			cond |= ledis.ExpireNX
+		case "xx":
+			cond |= ledis.ExpireXX
+		case "gt":
+			cond |= ledis.ExpireGT
+		case "lt":
+			cond |= ledis.ExpireLT
+		case "jitter":
+			if i++; i >= len(args) {
+				return 0, 0, ErrSyntax
+			}
+			if jitter, err = strconv.ParseFloat(hack.String(args[i]), 64); err != nil {
+				return 0, 0, ErrValue
+			}
+		default:
+			return 0, 0, ErrSyntax
+		}
+	}
+	return
+}
+
+// expireGeneric handles "key duration [NX|XX|GT|LT] [JITTER fraction]", the
+// duration is in unit.
+func expireGeneric(c *client, unit time.Duration, dataType ledis.DataType,
+	f func(key []byte, ttl time.Duration, jitter float64) (int64, error)) error {
 	args := c.args
-	if len(args) != 2 {
+	if len(args) < 2 {
 		return ErrCmdParams
 	}
 
 	duration, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
+	if max := int64(math.MaxInt64 / unit); err != nil || duration > max || duration < -max {
 		return ErrValue
 	}
 
-	if v, err := c.db.Expire(args[0], duration); err != nil {
+	cond, jitter, err := parseExpireOpts(args[2:])
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(duration) * unit
+	if cond == 0 {
+		if v, err := f(args[0], ttl, jitter); err != nil {
+			return err
+		} else {
+			c.resp.writeInteger(v)
+		}
+		return nil
+	}
+
+	if jitter != 0 {
+		if ttl, err = ledis.JitterTTL(ttl, jitter); err != nil {
+			return err
+		}
+	}
+
+	if ok, err := c.db.ExpireAtIf(dataType, args[0], time.Now().Add(ttl), cond); err != nil {
 		return err
+	} else if ok {
+		c.resp.writeInteger(1)
 	} else {
-		c.resp.writeInteger(v)
+		c.resp.writeInteger(0)
 	}
 
 	return nil
 }
 
-func expireAtCommand(c *client) error {
+// expireAtGeneric handles "key time [NX|XX|GT|LT]", the unix time is in unit.
+func expireAtGeneric(c *client, unit time.Duration, dataType ledis.DataType,
+	f func(key []byte, when int64) (int64, error)) error {
 	args := c.args
-	if len(args) != 2 {
+	if len(args) < 2 {
 		return ErrCmdParams
 	}
 
@@ -275,15 +433,41 @@ func expireAtCommand(c *client) error {
 		return ErrValue
 	}
 
-	if v, err := c.db.ExpireAt(args[0], when); err != nil {
+	cond, jitter, err := parseExpireOpts(args[2:])
+	if err != nil {
+		return err
+	} else if jitter != 0 {
+		return ErrSyntax
+	}
+
+	if cond == 0 {
+		if v, err := f(args[0], when); err != nil {
+			return err
+		} else {
+			c.resp.writeInteger(v)
+		}
+		return nil
+	}
+
+	if ok, err := c.db.ExpireAtIf(dataType, args[0], time.Unix(0, when*int64(unit)), cond); err != nil {
 		return err
+	} else if ok {
+		c.resp.writeInteger(1)
 	} else {
-		c.resp.writeInteger(v)
+		c.resp.writeInteger(0)
 	}
 
 	return nil
 }
 
+func expireCommand(c *client) error {
+	return expireGeneric(c, time.Second, ledis.KV, c.db.ExpireWithJitter)
+}
+
+func expireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Second, ledis.KV, c.db.ExpireAt)
+}
+
 func ttlCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
@@ -299,6 +483,61 @@ func ttlCommand(c *client) error {
 	return nil
 }
 
+func pexpireCommand(c *client) error {
+	return expireGeneric(c, time.Millisecond, ledis.KV, c.db.ExpireWithJitter)
+}
+
+func pexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Millisecond, ledis.KV, c.db.PExpireAt)
+}
+
+func pttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.PTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+// EXPIRETIME key
+func expiretimeCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.ExpireTime(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+// PEXPIRETIME key
+func pexpiretimeCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.PExpireTime(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
 func persistCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
@@ -470,6 +709,134 @@ func bitposCommand(c *client) error {
 	return nil
 }
 
+// parseBitFieldType parses the type like i8 or u16 and the offset like 10
+// or #2 which is multiplied by the width.
+func parseBitFieldType(op *ledis.BitFieldOp, tp []byte, offset []byte) error {
+	if len(tp) < 2 {
+		return ErrSyntax
+	}
+
+	switch tp[0] {
+	case 'i', 'I':
+		op.Encoding = ledis.BitFieldSigned
+	case 'u', 'U':
+		op.Encoding = ledis.BitFieldUnsigned
+	default:
+		return ErrSyntax
+	}
+
+	width, err := strconv.ParseUint(hack.String(tp[1:]), 10, 8)
+	if err != nil {
+		return ErrSyntax
+	}
+	op.Width = uint8(width)
+
+	multiply := len(offset) > 0 && offset[0] == '#'
+	if multiply {
+		offset = offset[1:]
+	}
+
+	if op.BitOffset, err = strconv.Atoi(hack.String(offset)); err != nil || op.BitOffset < 0 {
+		return ErrOffset
+	}
+
+	if multiply {
+		op.BitOffset *= int(op.Width)
+	}
+	return nil
+}
+
+// bitfieldWrites reports whether BITFIELD has a SET or an INCRBY, it is a
+// read with only GET.
+func bitfieldWrites(args [][]byte) bool {
+	for i := 1; i < len(args); {
+		switch strings.ToLower(hack.String(args[i])) {
+		case "overflow":
+			i += 2
+		case "get":
+			i += 3
+		default:
+			// SET, INCRBY or a syntax error
+			return true
+		}
+	}
+	return false
+}
+
+// BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL]
+func bitfieldCommand(c *client) error {
+	args := c.args
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	var ops []ledis.BitFieldOp
+	overflow := ledis.BitFieldWrap
+	for i := 1; i < len(args); {
+		var op ledis.BitFieldOp
+
+		switch strings.ToLower(hack.String(args[i])) {
+		case "overflow":
+			if i+1 >= len(args) {
+				return ErrSyntax
+			}
+
+			switch strings.ToLower(hack.String(args[i+1])) {
+			case "wrap":
+				overflow = ledis.BitFieldWrap
+			case "sat":
+				overflow = ledis.BitFieldSat
+			case "fail":
+				overflow = ledis.BitFieldFail
+			default:
+				return ErrSyntax
+			}
+			i += 2
+			continue
+		case "get":
+			op.Type = ledis.BitFieldGet
+		case "set":
+			op.Type = ledis.BitFieldSet
+		case "incrby":
+			op.Type = ledis.BitFieldIncrBy
+		default:
+			return ErrSyntax
+		}
+
+		n := 3
+		if op.Type != ledis.BitFieldGet {
+			n = 4
+		}
+
+		if i+n > len(args) {
+			return ErrSyntax
+		}
+
+		if err := parseBitFieldType(&op, args[i+1], args[i+2]); err != nil {
+			return err
+		}
+
+		if op.Type != ledis.BitFieldGet {
+			v, err := ledis.StrInt64(args[i+3], nil)
+			if err != nil {
+				return ErrValue
+			}
+			op.Value = v
+		}
+
+		op.Overflow = overflow
+		ops = append(ops, op)
+		i += n
+	}
+
+	if ay, err := c.db.BitField(args[0], ops); err != nil {
+		return err
+	} else {
+		c.resp.writeArray(ay)
+	}
+	return nil
+}
+
 func getbitCommand(c *client) error {
 	args := c.args
 	if len(args) != 2 {
@@ -515,9 +882,67 @@ func setbitCommand(c *client) error {
 	return nil
 }
 
+func mexpireGeneric(c *client, unit time.Duration) error {
+	args := c.args
+	if len(args) < 2 {
+		return ErrCmdParams
+	}
+
+	duration, err := ledis.StrInt64(args[0], nil)
+	if max := int64(math.MaxInt64 / unit); err != nil || duration > max {
+		return ErrValue
+	}
+
+	exists, err := c.db.MExpire(args[1:], time.Duration(duration)*unit)
+	if err != nil {
+		return err
+	}
+
+	ay := make([]interface{}, len(exists))
+	for i, ok := range exists {
+		if ok {
+			ay[i] = int64(1)
+		} else {
+			ay[i] = int64(0)
+		}
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+func mexpireCommand(c *client) error {
+	return mexpireGeneric(c, time.Second)
+}
+
+func pmexpireCommand(c *client) error {
+	return mexpireGeneric(c, time.Millisecond)
+}
+
+func mttlCommand(c *client) error {
+	args := c.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	ttls, err := c.db.MTTL(args)
+	if err != nil {
+		return err
+	}
+
+	ay := make([]interface{}, len(ttls))
+	for i, t := range ttls {
+		ay[i] = t
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
 func init() {
 	register("append", appendCommand)
 	register("bitcount", bitcountCommand)
+	register("bitfield", bitfieldCommand)
 	register("bitop", bitopCommand)
 	register("bitpos", bitposCommand)
 	register("decr", decrCommand)
@@ -526,6 +951,10 @@ func init() {
 	register("exists", existsCommand)
 	register("get", getCommand)
 	register("getbit", getbitCommand)
+	register("getdel", getdelCommand)
+	register("cas", casCommand)
+	register("cad", cadCommand)
+	register("getex", getexCommand)
 	register("getrange", getrangeCommand)
 	register("getset", getsetCommand)
 	register("incr", incrCommand)
@@ -542,4 +971,12 @@ func init() {
 	register("expireat", expireAtCommand)
 	register("ttl", ttlCommand)
 	register("persist", persistCommand)
+	register("pexpire", pexpireCommand)
+	register("pexpireat", pexpireAtCommand)
+	register("pttl", pttlCommand)
+	register("expiretime", expiretimeCommand)
+	register("pexpiretime", pexpiretimeCommand)
+	register("mexpire", mexpireCommand)
+	register("pmexpire", pmexpireCommand)
+	register("mttl", mttlCommand)
 }
@@ -292,3 +292,158 @@ func TestKVErrorParams(t *testing.T) {
 	}
 
 }
+
+func TestKVBitField(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_kv_bitfield"
+
+	int64s := func(reply interface{}, err error) ([]int64, error) {
+		ay, err := goredis.Values(reply, err)
+		if err != nil {
+			return nil, err
+		}
+
+		ns := make([]int64, len(ay))
+		for i := range ay {
+			if ns[i], err = goredis.Int64(ay[i], nil); err != nil {
+				return nil, err
+			}
+		}
+		return ns, nil
+	}
+
+	if ay, err := int64s(c.Do("bitfield", key, "incrby", "i5", 100, 1, "get", "u4", 0)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 || ay[0] != 1 || ay[1] != 0 {
+		t.Fatal(ay)
+	}
+
+	if ay, err := int64s(c.Do("bitfield", key, "set", "u8", "#1", 255, "get", "u4", 8)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 || ay[0] != 0 || ay[1] != 15 {
+		t.Fatal(ay)
+	}
+
+	if n, err := goredis.Int64(c.Do("getbit", key, 15)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if ay, err := goredis.Values(c.Do("bitfield", key, "overflow", "fail", "incrby", "u8", 8, 1)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 || ay[0] != nil {
+		t.Fatal(ay)
+	}
+
+	if ay, err := int64s(c.Do("bitfield", key, "overflow", "sat", "incrby", "u8", 8, 1)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 || ay[0] != 255 {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("bitfield", key, "get", "u64", 0); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("bitfield", key, "get", "u8"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("bitfield", key, "overflow", "none", "get", "u8", 0); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestKVGetDelEx(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_kv_getdel"
+
+	if _, err := c.Do("set", key, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := goredis.String(c.Do("getex", key, "ex", 100)); err != nil {
+		t.Fatal(err)
+	} else if v != "hello" {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c.Do("ttl", key)); err != nil {
+		t.Fatal(err)
+	} else if n != 100 {
+		t.Fatal(n)
+	}
+
+	if _, err := goredis.String(c.Do("getex", key, "persist")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := goredis.Int(c.Do("ttl", key)); err != nil {
+		t.Fatal(err)
+	} else if n != -1 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("getex", key, "ex", 0); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("getex", key, "keep", 10); err == nil {
+		t.Fatal("must error")
+	}
+
+	if v, err := goredis.String(c.Do("getdel", key)); err != nil {
+		t.Fatal(err)
+	} else if v != "hello" {
+		t.Fatal(v)
+	}
+
+	if _, err := goredis.String(c.Do("getdel", key)); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+}
+
+func TestKVCASCAD(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_kv_cas"
+	c.Do("set", key, "a")
+
+	if n, err := goredis.Int(c.Do("cas", key, "b", "c")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("cas", key, "a", "b")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("cad", key, "a")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("cad", key, "b")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if _, err := goredis.String(c.Do("get", key)); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("cas", key, "a"); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -2,9 +2,9 @@ package server
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
-	"bytes"
 	"github.com/siddontang/go/hack"
 	"github.com/siddontang/ledisdb/ledis"
 )
@@ -104,6 +104,54 @@ func lindexCommand(c *client) error {
 	return nil
 }
 
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func lposCommand(c *client) error {
+	args := c.args
+	if len(args) < 2 || len(args)%2 != 0 {
+		return ErrCmdParams
+	}
+
+	rank, count, maxLen := 1, 1, 0
+	withCount := false
+	for i := 2; i < len(args); i += 2 {
+		n, err := strconv.Atoi(hack.String(args[i+1]))
+		if err != nil {
+			return ErrValue
+		}
+
+		switch strings.ToLower(hack.String(args[i])) {
+		case "rank":
+			rank = n
+		case "count":
+			count = n
+			withCount = true
+		case "maxlen":
+			maxLen = n
+		default:
+			return ErrSyntax
+		}
+	}
+
+	pos, err := c.db.LPos(args[0], args[1], rank, count, maxLen)
+	if err != nil {
+		return err
+	}
+
+	if withCount {
+		ay := make([]interface{}, len(pos))
+		for i, p := range pos {
+			ay[i] = p
+		}
+		c.resp.writeArray(ay)
+	} else if len(pos) == 0 {
+		c.resp.writeBulk(nil)
+	} else {
+		c.resp.writeInteger(pos[0])
+	}
+
+	return nil
+}
+
 func lrangeCommand(c *client) error {
 	args := c.args
 	if len(args) != 3 {
@@ -164,37 +212,20 @@ func lmclearCommand(c *client) error {
 }
 
 func lexpireCommand(c *client) error {
-	args := c.args
-	if len(args) != 2 {
-		return ErrCmdParams
-	}
-
-	duration, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
-	}
-
-	if v, err := c.db.LExpire(args[0], duration); err != nil {
-		return err
-	} else {
-		c.resp.writeInteger(v)
-	}
-
-	return nil
+	return expireGeneric(c, time.Second, ledis.LIST, c.db.LExpireWithJitter)
 }
 
 func lexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Second, ledis.LIST, c.db.LExpireAt)
+}
+
+func lttlCommand(c *client) error {
 	args := c.args
-	if len(args) != 2 {
+	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	when, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
-	}
-
-	if v, err := c.db.LExpireAt(args[0], when); err != nil {
+	if v, err := c.db.LTTL(args[0]); err != nil {
 		return err
 	} else {
 		c.resp.writeInteger(v)
@@ -203,13 +234,21 @@ func lexpireAtCommand(c *client) error {
 	return nil
 }
 
-func lttlCommand(c *client) error {
+func lpexpireCommand(c *client) error {
+	return expireGeneric(c, time.Millisecond, ledis.LIST, c.db.LExpireWithJitter)
+}
+
+func lpexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Millisecond, ledis.LIST, c.db.LPExpireAt)
+}
+
+func lpttlCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	if v, err := c.db.LTTL(args[0]); err != nil {
+	if v, err := c.db.LPTTL(args[0]); err != nil {
 		return err
 	} else {
 		c.resp.writeInteger(v)
@@ -286,43 +325,12 @@ func brpoplpushCommand(c *client) error {
 		return err
 	}
 
-	var ttl int64 = -1
-	if bytes.Compare(source, dest) == 0 {
-		var err error
-		ttl, err = c.db.LTTL(source)
-		if err != nil {
-			return err
-		}
-	}
-
-	ay, err := c.db.BRPop([][]byte{source}, timeout)
-	if err != nil {
+	if data, err := c.db.BLMove(source, dest, ledis.ListTail, ledis.ListHead, timeout); err != nil {
 		return err
+	} else {
+		c.resp.writeBulk(data)
 	}
-
-	if ay == nil {
-		c.resp.writeBulk(nil)
-		return nil
-	}
-
-	data, ok := ay[1].([]byte)
-	if !ok {
-		//not sure if this even possible
-		return ErrValue
-	}
-	if _, err := c.db.LPush(dest, data); err != nil {
-		c.db.RPush(source, data) //revert pop
-		return err
-	}
-
-	//reset ttl
-	if ttl != -1 {
-		c.db.LExpire(source, ttl)
-	}
-
-	c.resp.writeBulk(data)
 	return nil
-
 }
 
 func lParseBRPoplpushArgs(c *client) (source []byte, dest []byte, timeout time.Duration, err error) {
@@ -349,38 +357,168 @@ func rpoplpushCommand(c *client) error {
 	if len(args) != 2 {
 		return ErrCmdParams
 	}
-	source, dest := args[0], args[1]
 
-	var ttl int64 = -1
-	if bytes.Compare(source, dest) == 0 {
-		var err error
-		ttl, err = c.db.LTTL(source)
-		if err != nil {
-			return err
-		}
+	if data, err := c.db.LMove(args[0], args[1], ledis.ListTail, ledis.ListHead); err != nil {
+		return err
+	} else {
+		c.resp.writeBulk(data)
+	}
+	return nil
+}
+
+func parseListDir(buf []byte) (ledis.ListDir, error) {
+	switch strings.ToLower(hack.String(buf)) {
+	case "left":
+		return ledis.ListHead, nil
+	case "right":
+		return ledis.ListTail, nil
+	default:
+		return 0, ErrSyntax
+	}
+}
+
+// parseMPopArgs parses numkeys key [key ...] where [COUNT count] for the
+// MPOP commands.
+func parseMPopArgs(args [][]byte) (keys [][]byte, where []byte, count int, err error) {
+	if len(args) < 3 {
+		err = ErrCmdParams
+		return
+	}
+
+	var numKeys int
+	if numKeys, err = strconv.Atoi(hack.String(args[0])); err != nil || numKeys <= 0 {
+		err = ErrValue
+		return
+	} else if len(args) < numKeys+2 {
+		err = ErrCmdParams
+		return
+	}
+
+	keys = args[1 : numKeys+1]
+	where = args[numKeys+1]
+	count = 1
+
+	args = args[numKeys+2:]
+	if len(args) == 0 {
+		return
+	} else if len(args) != 2 || strings.ToLower(hack.String(args[0])) != "count" {
+		err = ErrSyntax
+		return
 	}
 
-	data, err := c.db.RPop(source)
+	if count, err = strconv.Atoi(hack.String(args[1])); err != nil || count <= 0 {
+		err = ErrValue
+	}
+	return
+}
+
+func parseBlockTimeout(buf []byte) (time.Duration, error) {
+	t, err := strconv.ParseFloat(hack.String(buf), 64)
+	if err != nil || t < 0 {
+		return 0, ErrValue
+	}
+	return time.Duration(t * float64(time.Second)), nil
+}
+
+func lmpopGeneric(c *client, args [][]byte, block bool, timeout time.Duration) error {
+	keys, where, count, err := parseMPopArgs(args)
 	if err != nil {
 		return err
 	}
 
-	if data == nil {
-		c.resp.writeBulk(nil)
-		return nil
+	dir, err := parseListDir(where)
+	if err != nil {
+		return err
+	}
+
+	var key []byte
+	var values [][]byte
+	if block {
+		key, values, err = c.db.BLMPop(dir, count, timeout, keys...)
+	} else {
+		key, values, err = c.db.LMPop(dir, count, keys...)
+	}
+
+	if err != nil {
+		return err
+	} else if key == nil {
+		c.resp.writeArray(nil)
+	} else {
+		c.resp.writeArray([]interface{}{key, values})
+	}
+	return nil
+}
+
+// LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func lmpopCommand(c *client) error {
+	return lmpopGeneric(c, c.args, false, 0)
+}
+
+// BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func blmpopCommand(c *client) error {
+	args := c.args
+	if len(args) < 4 {
+		return ErrCmdParams
 	}
 
-	if _, err := c.db.LPush(dest, data); err != nil {
-		c.db.RPush(source, data) //revert pop
+	timeout, err := parseBlockTimeout(args[0])
+	if err != nil {
 		return err
 	}
 
-	//reset ttl
-	if ttl != -1 {
-		c.db.LExpire(source, ttl)
+	return lmpopGeneric(c, args[1:], true, timeout)
+}
+
+func lParseLMoveArgs(args [][]byte) (srcDir ledis.ListDir, dstDir ledis.ListDir, err error) {
+	if srcDir, err = parseListDir(args[2]); err != nil {
+		return
+	}
+	dstDir, err = parseListDir(args[3])
+	return
+}
+
+// LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func lmoveCommand(c *client) error {
+	args := c.args
+	if len(args) != 4 {
+		return ErrCmdParams
+	}
+
+	srcDir, dstDir, err := lParseLMoveArgs(args)
+	if err != nil {
+		return err
 	}
 
-	c.resp.writeBulk(data)
+	if data, err := c.db.LMove(args[0], args[1], srcDir, dstDir); err != nil {
+		return err
+	} else {
+		c.resp.writeBulk(data)
+	}
+	return nil
+}
+
+// BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func blmoveCommand(c *client) error {
+	args := c.args
+	if len(args) != 5 {
+		return ErrCmdParams
+	}
+
+	srcDir, dstDir, err := lParseLMoveArgs(args)
+	if err != nil {
+		return err
+	}
+
+	t, err := strconv.ParseFloat(hack.String(args[4]), 64)
+	if err != nil {
+		return err
+	}
+
+	if data, err := c.db.BLMove(args[0], args[1], srcDir, dstDir, time.Duration(t*float64(time.Second))); err != nil {
+		return err
+	} else {
+		c.resp.writeBulk(data)
+	}
 	return nil
 }
 
@@ -477,12 +615,17 @@ func init() {
 	register("lindex", lindexCommand)
 	register("llen", llenCommand)
 	register("lpop", lpopCommand)
+	register("lpos", lposCommand)
 	register("lrange", lrangeCommand)
 	register("lpush", lpushCommand)
 	register("rpop", rpopCommand)
 	register("rpush", rpushCommand)
 	register("brpoplpush", brpoplpushCommand)
 	register("rpoplpush", rpoplpushCommand)
+	register("lmove", lmoveCommand)
+	register("blmove", blmoveCommand)
+	register("lmpop", lmpopCommand)
+	register("blmpop", blmpopCommand)
 
 	//ledisdb special command
 
@@ -492,6 +635,9 @@ func init() {
 	register("lexpireat", lexpireAtCommand)
 	register("lttl", lttlCommand)
 	register("lpersist", lpersistCommand)
+	register("lpexpire", lpexpireCommand)
+	register("lpexpireat", lpexpireAtCommand)
+	register("lpttl", lpttlCommand)
 	register("lkeyexists", lkeyexistsCommand)
 
 	register("ltrim_front", lTrimFrontCommand)
@@ -526,3 +526,128 @@ func TestListErrorParams(t *testing.T) {
 		t.Fatalf("invalid err of %v", err)
 	}
 }
+
+func TestListPos(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "test_cmd_list_pos"
+	if _, err := c.Do("rpush", key, "a", "b", "c", "1", "2", "3", "c", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := goredis.Int(c.Do("lpos", key, "c")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("lpos", key, "c", "rank", -1)); err != nil {
+		t.Fatal(err)
+	} else if n != 7 {
+		t.Fatal(n)
+	}
+
+	if _, err := goredis.Int(c.Do("lpos", key, "d")); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	if ay, err := goredis.Values(c.Do("lpos", key, "c", "count", 0, "maxlen", 7)); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(ay) != "[2 6]" {
+		t.Fatal(ay)
+	}
+
+	if ay, err := goredis.Values(c.Do("lpos", key, "d", "count", 2)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 0 {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("lpos", key, "c", "rank", 0); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("lpos", key, "c", "rank"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestListMove(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	src := "test_cmd_list_move_src"
+	dst := "test_cmd_list_move_dst"
+
+	if _, err := c.Do("rpush", src, "a", "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := goredis.String(c.Do("lmove", src, dst, "right", "left")); err != nil {
+		t.Fatal(err)
+	} else if v != "c" {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.String(c.Do("lmove", src, dst, "LEFT", "RIGHT")); err != nil {
+		t.Fatal(err)
+	} else if v != "a" {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Strings(c.Do("lrange", dst, 0, -1)); err != nil {
+		t.Fatal(err)
+	} else if fmt.Sprint(v) != "[c a]" {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.String(c.Do("blmove", src, dst, "left", "left", 0)); err != nil {
+		t.Fatal(err)
+	} else if v != "b" {
+		t.Fatal(v)
+	}
+
+	if _, err := goredis.String(c.Do("blmove", src, dst, "left", "left", 0.01)); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("lmove", src, dst, "up", "left"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestListMPop(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("rpush", "lmpop_b", "1", "2", "3")
+
+	if ay, err := goredis.Values(c.Do("lmpop", 2, "lmpop_a", "lmpop_b", "right", "count", 2)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 {
+		t.Fatal(ay)
+	} else if key, _ := goredis.String(ay[0], nil); key != "lmpop_b" {
+		t.Fatal(key)
+	} else if v, _ := goredis.Strings(ay[1], nil); len(v) != 2 || v[0] != "3" || v[1] != "2" {
+		t.Fatal(v)
+	}
+
+	if ay, err := goredis.Values(c.Do("blmpop", 0.01, 1, "lmpop_b", "left", "count", 5)); err != nil {
+		t.Fatal(err)
+	} else if v, _ := goredis.Strings(ay[1], nil); len(v) != 1 || v[0] != "1" {
+		t.Fatal(v)
+	}
+
+	if _, err := goredis.Values(c.Do("blmpop", 0.01, 1, "lmpop_b", "left")); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("lmpop", 2, "lmpop_a", "lmpop_b", "up"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("lmpop", 3, "lmpop_a", "left"); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -88,10 +89,11 @@ func zdumpCommand(c *client) error {
 	return nil
 }
 
-// unlike redis, restore will try to delete old key first
+// RESTORE key ttl value [REPLACE]
+// the key only conflicts with the same data type as the dumped value
 func restoreCommand(c *client) error {
 	args := c.args
-	if len(args) != 3 {
+	if len(args) != 3 && len(args) != 4 {
 		return ErrCmdParams
 	}
 
@@ -102,7 +104,15 @@ func restoreCommand(c *client) error {
 	}
 	data := args[2]
 
-	if err = c.db.Restore(key, ttl, data); err != nil {
+	replace := false
+	if len(args) == 4 {
+		if strings.ToLower(hack.String(args[3])) != "replace" {
+			return ErrSyntax
+		}
+		replace = true
+	}
+
+	if err = c.db.Restore(key, ttl, data, replace); err != nil {
 		return err
 	} else {
 		c.resp.writeStatus(OK)
@@ -111,6 +121,96 @@ func restoreCommand(c *client) error {
 	return nil
 }
 
+// COPY source destination [DB destination-db] [REPLACE]
+func copyCommand(c *client) error {
+	args := c.args
+	if len(args) < 2 {
+		return ErrCmdParams
+	}
+
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(hack.String(args[i])) {
+		case "replace":
+			replace = true
+		case "db":
+			if i+1 >= len(args) {
+				return ErrSyntax
+			}
+
+			i++
+			index, err := strconv.Atoi(hack.String(args[i]))
+			if err != nil {
+				return ErrValue
+			} else if index != c.db.Index() {
+				return ErrCopyDB
+			}
+		default:
+			return ErrSyntax
+		}
+	}
+
+	if ok, err := c.db.Copy(args[0], args[1], replace, ledis.CopyOptions{WithTTL: true}); err != nil {
+		return err
+	} else {
+		c.resp.writeBool(ok)
+	}
+
+	return nil
+}
+
+// MOVE key db
+func moveCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	index, err := strconv.Atoi(hack.String(args[1]))
+	if err != nil {
+		return ErrValue
+	}
+
+	if ok, err := c.db.Move(args[0], index); err != nil {
+		return err
+	} else {
+		c.resp.writeBool(ok)
+	}
+
+	return nil
+}
+
+// RENAME key newkey
+func renameCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	if err := c.db.Rename(args[0], args[1]); err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// RENAMENX key newkey
+func renamenxCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	if ok, err := c.db.RenameNX(args[0], args[1]); err != nil {
+		return err
+	} else {
+		c.resp.writeBool(ok)
+	}
+
+	return nil
+}
+
 // maybe only used in xcodis for redis data port
 func xrestoreCommand(c *client) error {
 	args := c.args
@@ -126,7 +226,7 @@ func xrestoreCommand(c *client) error {
 	}
 	data := args[3]
 
-	if err = c.db.Restore(key, ttl, data); err != nil {
+	if err = c.db.Restore(key, ttl, data, true); err != nil {
 		return err
 	} else {
 		c.resp.writeStatus(OK)
@@ -173,18 +273,19 @@ func xdel(db *ledis.DB, tp string, key []byte) error {
 	return err
 }
 
+// xttl returns the TTL in milliseconds
 func xttl(db *ledis.DB, tp string, key []byte) (int64, error) {
 	switch strings.ToUpper(tp) {
 	case KVName:
-		return db.TTL(key)
+		return db.PTTL(key)
 	case HashName:
-		return db.HTTL(key)
+		return db.HPTTL(key)
 	case ListName:
-		return db.LTTL(key)
+		return db.LPTTL(key)
 	case SetName:
-		return db.STTL(key)
+		return db.SPTTL(key)
 	case ZSetName:
-		return db.ZTTL(key)
+		return db.ZPTTL(key)
 	default:
 		return 0, fmt.Errorf("invalid key type %s", tp)
 	}
@@ -493,8 +594,7 @@ func migrateKey(c *client, conn *goredis.PoolConn, tp string, key []byte, timeou
 
 	conn.SetReadDeadline(time.Now().Add(t))
 
-	//ttl is second, but restore need millisecond
-	if _, err = conn.Do("restore", key, ttl*1e3, data); err != nil {
+	if _, err = conn.Do("restore", key, ttl, data, "replace"); err != nil {
 		return err
 	}
 
@@ -527,6 +627,10 @@ func init() {
 	register("sdump", sdumpCommand)
 	register("zdump", zdumpCommand)
 	register("restore", restoreCommand)
+	register("copy", copyCommand)
+	register("move", moveCommand)
+	register("rename", renameCommand)
+	register("renamenx", renamenxCommand)
 	register("xrestore", xrestoreCommand)
 	register("xdump", xdumpCommand)
 	register("xmigrate", xmigrateCommand)
@@ -46,7 +46,9 @@ func TestDumpRestore(t *testing.T) {
 func testDumpRestore(c *goredis.PoolConn, dump string, key string, t *testing.T) {
 	if data, err := goredis.Bytes(c.Do(dump, key)); err != nil {
 		t.Fatal(err)
-	} else if _, err := c.Do("restore", key, 0, data); err != nil {
+	} else if _, err := c.Do("restore", key, 0, data); err == nil {
+		t.Fatal("must error, key exists")
+	} else if _, err := c.Do("restore", key, 0, data, "replace"); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -142,3 +144,120 @@ func TestMigrate(t *testing.T) {
 		t.Fatal(s, "must empty")
 	}
 }
+
+func TestCopy(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("rpush", "copy_src", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := goredis.Int(c.Do("copy", "copy_src", "copy_dst")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("copy", "copy_src", "copy_dst")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("copy", "copy_src", "copy_dst", "db", 0, "replace")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("llen", "copy_dst")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("copy", "copy_src", "copy_dst", "db", 1); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestMove(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("select", 4)
+	// the pooled connection is reused by the other tests
+	defer c.Do("select", 0)
+	c.Do("del", "move_key")
+	c.Do("select", 0)
+	c.Do("del", "move_key")
+
+	if _, err := c.Do("rpush", "move_key", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := goredis.Int(c.Do("move", "move_key", 4)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("move", "move_key", 4)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("move", "move_key", 0); err == nil {
+		t.Fatal("must error")
+	}
+
+	c.Do("select", 4)
+	if n, err := goredis.Int(c.Do("llen", "move_key")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestRename(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("del", "rename_a", "rename_b")
+	c.Do("hclear", "rename_a")
+
+	if _, err := c.Do("rename", "rename_a", "rename_b"); err == nil {
+		t.Fatal("must error")
+	}
+
+	c.Do("hset", "rename_a", "f", "v")
+	c.Do("set", "rename_b", "v")
+
+	if n, err := goredis.Int(c.Do("renamenx", "rename_a", "rename_b")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if ok, err := goredis.String(c.Do("rename", "rename_a", "rename_b")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if v, err := goredis.String(c.Do("hget", "rename_b", "f")); err != nil {
+		t.Fatal(err)
+	} else if v != "v" {
+		t.Fatal(v)
+	} else if n, _ := goredis.Int(c.Do("exists", "rename_b")); n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("renamenx", "rename_b", "rename_a")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+type txCommand struct {
+	cmd  string
+	args [][]byte
+}
+
+type transaction struct {
+	cmds []txCommand
+
+	// a command was rejected while queuing
+	err bool
+}
+
+func (tx *transaction) queue(cmd string, args [][]byte) error {
+	if cmd == "watch" {
+		return ErrWatchInMulti
+	} else if commandHas(cmd, flagNoMulti) || (cmd == "xread" && hasBlockArg(args)) || (cmd == "debug" && debugLocks(args)) {
+		return fmt.Errorf("%s is not allowed in MULTI", cmd)
+	}
+
+	// the request buffer is reused by the next request
+	ay := make([][]byte, len(args))
+	for i, arg := range args {
+		ay[i] = append([]byte(nil), arg...)
+	}
+
+	tx.cmds = append(tx.cmds, txCommand{string(append([]byte(nil), cmd...)), ay})
+	return nil
+}
+
+func hasBlockArg(args [][]byte) bool {
+	for _, arg := range args {
+		if strings.ToLower(hack.String(arg)) == "block" {
+			return true
+		}
+	}
+	return false
+}
+
+// MULTI
+func multiCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	} else if c.tx != nil {
+		return ErrMultiNested
+	} else if _, ok := c.resp.(*respWriter); !ok {
+		return ErrMultiClient
+	}
+
+	c.tx = new(transaction)
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// EXEC
+func execCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	tx := c.tx
+	if tx == nil {
+		return ErrExecWithoutMulti
+	}
+
+	c.tx = nil
+	defer c.unwatch()
+	if tx.err {
+		return ErrExecAbort
+	}
+
+	if c.app.cfg.GetReadonly() {
+		for _, cmd := range tx.cmds {
+			if isWriteCommand(cmd.cmd, cmd.args) {
+				return ErrReadonly
+			}
+		}
+	}
+
+	m, err := c.db.Multi()
+	if err != nil {
+		return err
+	}
+
+	closed := false
+	defer func() {
+		if !closed {
+			m.Close()
+		}
+	}()
+
+	// no write runs in the Multi, so the keys can not change after the check
+	for _, w := range c.watches {
+		if w.Changed() {
+			c.resp.writeSliceArray(nil)
+			return nil
+		}
+	}
+
+	replies := c.execQueued(m, tx.cmds)
+
+	// the replies are sent only after the writes are committed
+	closed = true
+	if err = m.Close(); err != nil {
+		log.Errorf("commit exec error %s", err.Error())
+		return err
+	}
+
+	w := c.resp.(*respWriter)
+	w.writeArrayHeader(len(tx.cmds))
+	w.buff.Write(replies)
+	return nil
+}
+
+// execQueued runs the commands on the DB of m and returns their replies, one
+// by one as the items of the EXEC array.
+func (c *client) execQueued(m *ledis.Multi, cmds []txCommand) []byte {
+	w := c.resp.(*respWriter)
+
+	var replies bytes.Buffer
+	db, buff := c.db, w.buff
+	c.db, w.buff = m.DB, bufio.NewWriter(&replies)
+	defer func() {
+		c.db, w.buff = db, buff
+		c.cmd, c.args = "exec", nil
+	}()
+
+	for _, cmd := range cmds {
+		c.cmd, c.args = cmd.cmd, cmd.args
+		err := commands[c.cmd].f(c)
+		if err != nil {
+			c.resp.writeError(err)
+		}
+		c.audit(err)
+	}
+
+	w.buff.Flush()
+	return replies.Bytes()
+}
+
+// DISCARD
+func discardCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	} else if c.tx == nil {
+		return ErrDiscardWithoutMulti
+	}
+
+	c.tx = nil
+	c.unwatch()
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// WATCH key [key ...]
+func watchCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	} else if _, ok := c.resp.(*respWriter); !ok {
+		return ErrMultiClient
+	}
+
+	w, err := c.db.Watch(c.args...)
+	if err != nil {
+		return err
+	}
+
+	c.watches = append(c.watches, w)
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// UNWATCH
+func unwatchCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	c.unwatch()
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func (c *client) unwatch() {
+	for _, w := range c.watches {
+		w.Close()
+	}
+	c.watches = nil
+}
+
+func init() {
+	register("multi", multiCommand)
+	register("exec", execCommand)
+	register("discard", discardCommand)
+	register("watch", watchCommand)
+	register("unwatch", unwatchCommand)
+}
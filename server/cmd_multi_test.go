@@ -0,0 +1,188 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestMultiExec(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "test_multi_exec"
+	c.Do("del", key)
+
+	if _, err := c.Do("exec"); err == nil {
+		t.Fatal("must error, exec without multi")
+	} else if _, err := c.Do("discard"); err == nil {
+		t.Fatal("must error, discard without multi")
+	}
+
+	if ok, err := goredis.String(c.Do("multi")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if _, err := c.Do("multi"); err == nil {
+		t.Fatal("must error, nested multi")
+	}
+
+	for _, args := range [][]interface{}{
+		{"incr", key},
+		{"incr", key},
+		{"lpush", key, "a"},
+		{"get", key},
+	} {
+		if s, err := goredis.String(c.Do(args[0].(string), args[1:]...)); err != nil {
+			t.Fatal(err)
+		} else if s != QUEUED {
+			t.Fatal(s)
+		}
+	}
+
+	ay, err := goredis.Values(c.Do("exec"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 {
+		t.Fatal(len(ay))
+	}
+
+	if n, _ := goredis.Int64(ay[1], nil); n != 2 {
+		t.Fatal(ay[1])
+	} else if n, _ := goredis.Int64(ay[2], nil); n != 1 {
+		t.Fatal(ay[2])
+	} else if v, _ := goredis.String(ay[3], nil); v != "2" {
+		t.Fatal(ay[3])
+	}
+
+	// the connection is out of MULTI after EXEC
+	if v, err := goredis.String(c.Do("get", key)); err != nil {
+		t.Fatal(err)
+	} else if v != "2" {
+		t.Fatal(v)
+	}
+
+	// an error in execution is the reply of its command only
+	c.Do("multi")
+	c.Do("incrby", key, "a")
+	c.Do("incr", key)
+	if ay, err := goredis.Values(c.Do("exec")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 {
+		t.Fatal(len(ay))
+	} else if _, ok := ay[0].(goredis.Error); !ok {
+		t.Fatal(ay[0])
+	} else if n, _ := goredis.Int64(ay[1], nil); n != 3 {
+		t.Fatal(ay[1])
+	}
+
+	// an error in queuing discards the whole transaction
+	c.Do("multi")
+	c.Do("incr", key)
+	if _, err := c.Do("not_a_command"); err == nil {
+		t.Fatal("must error")
+	} else if _, err := c.Do("blpop", key, 0); err == nil {
+		t.Fatal("must error, blocking in multi")
+	}
+	if _, err := c.Do("exec"); err == nil {
+		t.Fatal("must error, exec abort")
+	}
+
+	c.Do("multi")
+	c.Do("incr", key)
+	if ok, err := goredis.String(c.Do("discard")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if v, err := goredis.String(c.Do("get", key)); err != nil {
+		t.Fatal(err)
+	} else if v != "3" {
+		t.Fatal(v)
+	}
+}
+
+func TestExecCommitError(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "test_exec_commit_error"
+	c.Do("del", key)
+
+	// the server becomes readonly before the commit
+	commands["test_exec_readonly"] = &command{f: func(c *client) error {
+		c.app.cfg.SetReadonly(true)
+		c.resp.writeStatus(OK)
+		return nil
+	}}
+	defer delete(commands, "test_exec_readonly")
+	defer testApp.cfg.SetReadonly(false)
+
+	c.Do("multi")
+	c.Do("set", key, "1")
+	c.Do("test_exec_readonly")
+	if ay, err := c.Do("exec"); err == nil {
+		t.Fatal("must error", ay)
+	}
+
+	testApp.cfg.SetReadonly(false)
+	if v, err := c.Do("get", key); err != nil || v != nil {
+		t.Fatal(v, err)
+	}
+}
+
+func TestWatchExec(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c2 := getTestConn()
+	defer c2.Close()
+
+	key := "test_watch_exec"
+	c.Do("set", key, 1)
+
+	c.Do("watch", key)
+	c.Do("multi")
+	if _, err := c.Do("watch", key); err == nil {
+		t.Fatal("must error, watch in multi")
+	}
+	c.Do("discard")
+
+	// the key stays unwatched after discard
+	c.Do("multi")
+	c.Do("incr", key)
+	c2.Do("set", key, 10)
+	if ay, err := goredis.Values(c.Do("exec")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 {
+		t.Fatal(len(ay))
+	}
+
+	c.Do("watch", key)
+	c2.Do("set", key, 10)
+	c.Do("multi")
+	c.Do("incr", key)
+	if ay, err := c.Do("exec"); err != nil {
+		t.Fatal(err)
+	} else if ay != nil {
+		t.Fatalf("must abort with a null array, got %v", ay)
+	}
+
+	if v, _ := goredis.String(c.Do("get", key)); v != "10" {
+		t.Fatal(v)
+	}
+
+	c.Do("watch", key)
+	c.Do("unwatch")
+	c2.Do("set", key, 20)
+	c.Do("multi")
+	c.Do("incr", key)
+	if ay, err := goredis.Values(c.Do("exec")); err != nil {
+		t.Fatal(err)
+	} else if n, _ := goredis.Int64(ay[0], nil); n != 21 {
+		t.Fatal(ay[0])
+	}
+}
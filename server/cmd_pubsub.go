@@ -0,0 +1,212 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/siddontang/go/hack"
+)
+
+var (
+	pubsubMessageReply      = []byte("message")
+	pubsubPMessageReply     = []byte("pmessage")
+	pubsubSubscribeReply    = []byte("subscribe")
+	pubsubUnsubscribeReply  = []byte("unsubscribe")
+	pubsubPSubscribeReply   = []byte("psubscribe")
+	pubsubPUnsubscribeReply = []byte("punsubscribe")
+)
+
+// subscribed reports whether the client is in the pub/sub mode.
+func (c *client) subscribed() bool {
+	return c.ps != nil && c.ps.count() > 0
+}
+
+func (c *client) subscriber() (*Subscriber, error) {
+	if c.ps == nil {
+		w, ok := c.resp.(*respWriter)
+		if !ok || c.kill == nil {
+			return nil, ErrPubSubClient
+		}
+		c.ps = newSubscriber(w, c.kill)
+	}
+	return c.ps, nil
+}
+
+// unsubscribeAll leaves the pub/sub mode.
+func (c *client) unsubscribeAll() {
+	if c.ps == nil {
+		return
+	}
+
+	hub := c.app.pubsub
+	hub.Unsubscribe(c.ps, subscriptionNames(c.ps.channels)...)
+	hub.PUnsubscribe(c.ps, subscriptionNames(c.ps.patterns)...)
+
+	c.ps.close()
+	c.ps = nil
+}
+
+func subscriptionNames(m map[string]struct{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+// writeSubscription replies a change of the subscriptions with the number
+// of the subscriptions left.
+func (c *client) writeSubscription(kind []byte, name []byte) {
+	n := 0
+	if c.ps != nil {
+		n = c.ps.count()
+	}
+
+	w := c.resp.(*respWriter)
+	w.writePushHeader(3)
+	w.writeBulk(kind)
+	w.writeBulk(name)
+	w.writeInteger(int64(n))
+}
+
+// SUBSCRIBE channel [channel ...]
+func subscribeCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	s, err := c.subscriber()
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range c.args {
+		c.app.pubsub.Subscribe(s, string(channel))
+		c.writeSubscription(pubsubSubscribeReply, channel)
+	}
+	return nil
+}
+
+// PSUBSCRIBE pattern [pattern ...]
+func psubscribeCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	s, err := c.subscriber()
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range c.args {
+		c.app.pubsub.PSubscribe(s, string(pattern))
+		c.writeSubscription(pubsubPSubscribeReply, pattern)
+	}
+	return nil
+}
+
+// unsubscribeGeneric unsubscribes the names, or all the subscribed ones if
+// no name is given.
+func unsubscribeGeneric(c *client, pattern bool) error {
+	if _, ok := c.resp.(*respWriter); !ok {
+		return ErrPubSubClient
+	}
+
+	kind := pubsubUnsubscribeReply
+	if pattern {
+		kind = pubsubPUnsubscribeReply
+	}
+
+	names := c.args
+	if len(names) == 0 && c.ps != nil {
+		subscribed := c.ps.channels
+		if pattern {
+			subscribed = c.ps.patterns
+		}
+		for _, name := range subscriptionNames(subscribed) {
+			names = append(names, []byte(name))
+		}
+	}
+
+	if len(names) == 0 {
+		c.writeSubscription(kind, nil)
+		return nil
+	}
+
+	for _, name := range names {
+		if c.ps != nil && pattern {
+			c.app.pubsub.PUnsubscribe(c.ps, string(name))
+		} else if c.ps != nil {
+			c.app.pubsub.Unsubscribe(c.ps, string(name))
+		}
+		c.writeSubscription(kind, name)
+	}
+	return nil
+}
+
+// UNSUBSCRIBE [channel ...]
+func unsubscribeCommand(c *client) error {
+	return unsubscribeGeneric(c, false)
+}
+
+// PUNSUBSCRIBE [pattern ...]
+func punsubscribeCommand(c *client) error {
+	return unsubscribeGeneric(c, true)
+}
+
+// PUBLISH channel message
+func publishCommand(c *client) error {
+	if len(c.args) != 2 {
+		return ErrCmdParams
+	}
+
+	n := c.app.pubsub.Publish(string(c.args[0]), c.args[1])
+	c.resp.writeInteger(int64(n))
+	return nil
+}
+
+// PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT
+func pubsubCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	hub := c.app.pubsub
+	args := c.args[1:]
+
+	switch strings.ToLower(hack.String(c.args[0])) {
+	case "channels":
+		if len(args) > 1 {
+			return ErrCmdParams
+		}
+
+		pattern := ""
+		if len(args) == 1 {
+			pattern = string(args[0])
+		}
+		c.resp.writeSliceArray(stringsToSlices(hub.Channels(pattern)))
+	case "numsub":
+		ay := make([]interface{}, 0, 2*len(args))
+		for _, channel := range args {
+			ay = append(ay, channel, int64(hub.NumSub(string(channel))))
+		}
+		c.resp.writeArray(ay)
+	case "numpat":
+		if len(args) != 0 {
+			return ErrCmdParams
+		}
+		c.resp.writeInteger(int64(hub.NumPat()))
+	default:
+		return ErrCmdParams
+	}
+
+	return nil
+}
+
+func init() {
+	register("subscribe", subscribeCommand)
+	register("unsubscribe", unsubscribeCommand)
+	register("psubscribe", psubscribeCommand)
+	register("punsubscribe", punsubscribeCommand)
+	register("publish", publishCommand)
+	register("pubsub", pubsubCommand)
+}
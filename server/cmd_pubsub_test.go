@@ -0,0 +1,180 @@
+package server
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+func receiveStrings(t *testing.T, c *goredis.Conn) []string {
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	ay, err := goredis.Values(c.Receive())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := make([]string, len(ay))
+	for i, v := range ay {
+		switch v := v.(type) {
+		case int64:
+			ss[i] = strconv.FormatInt(v, 10)
+		case []byte:
+			ss[i] = string(v)
+		}
+	}
+	return ss
+}
+
+func checkStrings(t *testing.T, ss []string, expected ...string) {
+	if len(ss) != len(expected) {
+		t.Fatalf("%q != %q", ss, expected)
+	}
+	for i := range ss {
+		if ss[i] != expected[i] {
+			t.Fatalf("%q != %q", ss, expected)
+		}
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	s, err := goredis.Connect(testApp.cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if n, err := goredis.Int(c.Do("publish", "test_pubsub", "a")); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+
+	s.Send("subscribe", "test_pubsub", "test_pubsub_2")
+	checkStrings(t, receiveStrings(t, s), "subscribe", "test_pubsub", "1")
+	checkStrings(t, receiveStrings(t, s), "subscribe", "test_pubsub_2", "2")
+
+	s.Send("psubscribe", "test_pub*")
+	checkStrings(t, receiveStrings(t, s), "psubscribe", "test_pub*", "3")
+
+	// only the pub/sub commands in the pub/sub mode
+	if _, err := s.Do("get", "a"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if n, err := goredis.Int(c.Do("publish", "test_pubsub", "hello")); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	checkStrings(t, receiveStrings(t, s), "message", "test_pubsub", "hello")
+	checkStrings(t, receiveStrings(t, s), "pmessage", "test_pub*", "test_pubsub", "hello")
+
+	if channels, err := goredis.Strings(c.Do("pubsub", "channels", "test_pubsub*")); err != nil {
+		t.Fatal(err)
+	} else {
+		checkStrings(t, channels, "test_pubsub", "test_pubsub_2")
+	}
+
+	if ay, err := goredis.Values(c.Do("pubsub", "numsub", "test_pubsub", "test_pubsub_3")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 {
+		t.Fatal(ay)
+	} else if n, _ := goredis.Int(ay[1], nil); n != 1 {
+		t.Fatal(n)
+	} else if n, _ := goredis.Int(ay[3], nil); n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pubsub", "numpat")); err != nil || n < 1 {
+		t.Fatal(n, err)
+	}
+
+	s.Send("unsubscribe", "test_pubsub")
+	checkStrings(t, receiveStrings(t, s), "unsubscribe", "test_pubsub", "2")
+
+	s.Send("punsubscribe")
+	checkStrings(t, receiveStrings(t, s), "punsubscribe", "test_pub*", "1")
+
+	if n, err := goredis.Int(c.Do("publish", "test_pubsub", "hello")); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+
+	s.Send("unsubscribe")
+	checkStrings(t, receiveStrings(t, s), "unsubscribe", "test_pubsub_2", "0")
+
+	// out of the pub/sub mode
+	if _, err := s.Do("get", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a closed connection is unsubscribed
+	s.Send("subscribe", "test_pubsub")
+	checkStrings(t, receiveStrings(t, s), "subscribe", "test_pubsub", "1")
+	s.Close()
+
+	for i := 0; i < 100; i++ {
+		if n, _ := goredis.Int(c.Do("pubsub", "numsub", "test_pubsub")); n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ay, _ := goredis.Values(c.Do("pubsub", "numsub", "test_pubsub")); len(ay) != 2 {
+		t.Fatal(ay)
+	} else if n, _ := goredis.Int(ay[1], nil); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestPubSubExpired(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_pubsub_expired"
+	cfg.Addr = "127.0.0.1:11204"
+	cfg.ExpireNotifyEnabled = true
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	c, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Send("subscribe", "__keyevent@0__:expired")
+	checkStrings(t, receiveStrings(t, s), "subscribe", "__keyevent@0__:expired", "1")
+	s.Send("psubscribe", "__keyspace@0__:*")
+	checkStrings(t, receiveStrings(t, s), "psubscribe", "__keyspace@0__:*", "2")
+
+	c.Do("set", "test_expired", "a")
+	if _, err = c.Do("pexpire", "test_expired", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(600 * time.Millisecond)
+	for _, expected := range [][]string{
+		{"message", "__keyevent@0__:expired", "test_expired"},
+		{"pmessage", "__keyspace@0__:*", "__keyspace@0__:test_expired", "expired"},
+	} {
+		s.SetReadDeadline(deadline)
+		ay, err := goredis.Strings(s.Receive())
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkStrings(t, ay, expected...)
+	}
+}
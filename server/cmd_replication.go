@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"net"
@@ -89,7 +90,12 @@ func fullsyncCommand(c *client) error {
 
 	n := s.Size()
 
-	c.resp.writeBulkFrom(n, s)
+	c.resp.writeBulkFrom(n, &throttledReader{
+		r:     s,
+		t:     &c.syncThrottle,
+		limit: c.app.cfg.Replication.ThrottleBytes,
+		quit:  c.app.quit,
+	})
 
 	s.Close()
 
@@ -123,6 +129,8 @@ func syncCommand(c *client) error {
 	}
 
 	c.lastLogID.Set(lastLogID)
+	c.lastSyncTime.Set(time.Now().Unix())
+	c.app.slaveSynced()
 
 	if lastLogID == stat.LastID {
 		c.app.slaveAck(c)
@@ -132,7 +140,7 @@ func syncCommand(c *client) error {
 
 	c.syncBuf.Write(dummyBuf)
 
-	if _, _, err := c.app.ldb.ReadLogsToTimeout(logId, &c.syncBuf, 1, c.app.quit); err != nil {
+	if _, _, err := c.app.ldb.ReadLogsToTimeout(logId, &c.syncBuf, c.app.cfg.Replication.HeartbeatInterval, c.app.quit); err != nil {
 		return err
 	} else {
 		buf := c.syncBuf.Bytes()
@@ -144,12 +152,54 @@ func syncCommand(c *client) error {
 
 		binary.BigEndian.PutUint64(buf, stat.LastID)
 
+		c.syncThrottle.wait(len(buf), c.app.cfg.Replication.ThrottleBytes, c.app.quit)
 		c.resp.writeBulk(buf)
 	}
 
 	return nil
 }
 
+// PSYNC replid logid
+//
+// inner command, a slave asks before SYNC whether it can go on from logid with
+// the logs of replid. The reply is CONTINUE if the master has the logs from
+// logid of the same history, else FULLRESYNC, both with the master replid.
+// The history of the replid2 of a promoted slave ends at its last log id.
+func psyncCommand(c *client) error {
+	if len(c.args) != 2 {
+		return ErrCmdParams
+	}
+
+	logID, err := ledis.StrUint64(c.args[1], nil)
+	if err != nil {
+		return ErrCmdParams
+	}
+
+	stat, err := c.app.ldb.ReplicationStat()
+	if err != nil {
+		return err
+	}
+
+	id := c.app.ldb.ReplicationID()
+	id2, id2LastID := c.app.ldb.ReplicationID2()
+
+	ok := false
+	switch replID := hack.String(c.args[0]); {
+	case replID == id:
+		ok = logID <= stat.LastID+1
+	case len(id2) > 0 && replID == id2:
+		// the old history ends at the promotion
+		ok = logID <= id2LastID+1
+	}
+
+	if ok && logID >= stat.FirstID {
+		c.resp.writeStatus(psyncContinue + " " + id)
+	} else {
+		c.resp.writeStatus(psyncFullResync + " " + id)
+	}
+	return nil
+}
+
 //inner command, only for replication
 //REPLCONF <option> <value> <option> <value> ...
 func replconfCommand(c *client) error {
@@ -236,6 +286,45 @@ func roleCommand(c *client) error {
 	return nil
 }
 
+// WAIT numreplicas timeout
+func waitCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	numReplicas, err := ledis.StrInt64(args[0], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	timeout, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	} else if timeout < 0 {
+		return ErrTimeoutNegative
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-c.app.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	n, err := c.app.Wait(ctx, int(numReplicas), time.Duration(timeout)*time.Millisecond)
+	if err != nil && err != context.Canceled {
+		return err
+	}
+
+	c.resp.writeInteger(int64(n))
+	return nil
+}
+
 func replStatetring(r int32) string {
 	switch r {
 	case replConnectState:
@@ -269,6 +358,8 @@ func init() {
 	register("slaveof", slaveofCommand)
 	register("fullsync", fullsyncCommand)
 	register("sync", syncCommand)
+	register("psync", psyncCommand)
 	register("replconf", replconfCommand)
 	register("role", roleCommand)
+	register("wait", waitCommand)
 }
@@ -1,7 +1,12 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"testing"
@@ -9,6 +14,7 @@ import (
 
 	"github.com/siddontang/goredis"
 	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
 )
 
 func checkDataEqual(master *App, slave *App) error {
@@ -98,8 +104,18 @@ func TestReplication(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// the slave has the history of the master after the full sync
+	masterID := master.ldb.ReplicationID()
+	if id := slave.ldb.ReplicationID(); id != masterID {
+		t.Fatal(id, masterID)
+	}
+
 	slave.slaveof("", false, false)
 
+	if id := slave.ldb.ReplicationID(); id == masterID {
+		t.Fatal("a new master must have a new replication id")
+	}
+
 	db.Set([]byte("a2"), value)
 	db.Set([]byte("b2"), value)
 	db.Set([]byte("c2"), value)
@@ -148,6 +164,54 @@ func TestReplication(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	if s := master.replicationStatus(); s.Role != "master" || s.SlaveCount != 1 {
+		t.Fatal(s)
+	} else if s.Slaves[0].Addr != "127.0.0.1:11183" || s.Slaves[0].State != "connected" {
+		t.Fatal(s.Slaves[0])
+	}
+
+	w := httptest.NewRecorder()
+	slave.ReplicationStatusHandler().ServeHTTP(w, httptest.NewRequest("GET", "/replication/status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code)
+	}
+
+	var s replicationStatus
+	if err = json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	} else if s.Role != "slave" || s.MasterAddr != masterCfg.Addr || s.MasterLinkStatus != "up" {
+		t.Fatal(s)
+	}
+
+	db.Set([]byte("a4"), value)
+	if n, err := master.Wait(context.Background(), 1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := master.Wait(context.Background(), 2, 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := master.Wait(ctx, 2, 0); err != context.Canceled {
+		t.Fatal(err)
+	}
+
+	// the write returns after the slave has its log
+	wdb := db.WithCommitOptions(ledis.CommitOptions{WaitReplicas: 1, WaitTimeout: 5 * time.Second})
+	if err = wdb.Set([]byte("a5"), value); err != nil {
+		t.Fatal(err)
+	}
+	mStat, _ = master.ldb.ReplicationStat()
+	if s := master.replicationStatus(); s.Slaves[0].LastLogID != mStat.LastID {
+		t.Fatal(s.Slaves[0].LastLogID, mStat.LastID)
+	}
+
 	slave.tryReSlaveof()
 
 	time.Sleep(1 * time.Second)
@@ -170,3 +234,250 @@ func checkTestRole(addr string, checkRoles []interface{}) error {
 	}
 	return nil
 }
+
+func TestWaitNoSlaves(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if n, err := goredis.Int(c.Do("wait", 1, 0)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("wait", 1, -1); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestReadonlyReplica(t *testing.T) {
+	dataDir := "/tmp/test_readonly_replica"
+	os.RemoveAll(dataDir)
+	defer os.RemoveAll(dataDir)
+
+	masterCfg := config.NewConfigDefault()
+	masterCfg.DataDir = fmt.Sprintf("%s/master", dataDir)
+	masterCfg.UseReplication = true
+
+	master, err := ledis.Open(masterCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	slaveCfg := config.NewConfigDefault()
+	slaveCfg.DataDir = fmt.Sprintf("%s/slave", dataDir)
+	slaveCfg.Addr = "127.0.0.1:11195"
+	slaveCfg.UseReplication = true
+	slaveCfg.Readonly = true
+
+	slave, err := NewApp(slaveCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+	go slave.Run()
+
+	c, err := goredis.Connect(slaveCfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for _, args := range [][]interface{}{
+		{"set", "a", "1"},
+		{"del", "a"},
+		{"lpush", "l", "1"},
+		{"flushall"},
+		{"getex", "a", "ex", "10"},
+		{"getex", "a", "persist"},
+		{"bitfield", "a", "get", "u8", "0", "set", "u8", "0", "1"},
+		{"xlsort", "l", "store", "d"},
+	} {
+		if _, err := c.Do(args[0].(string), args[1:]...); err == nil || err.Error() != ErrReadonly.Error() {
+			t.Fatal(args, err)
+		}
+	}
+
+	if v, err := c.Do("get", "a"); err != nil || v != nil {
+		t.Fatal(v, err)
+	}
+
+	// the reads of the commands which only write with some arguments
+	reads := [][]interface{}{
+		{"getex", "a"},
+		{"bitfield", "a", "overflow", "sat", "get", "u8", "0"},
+		{"xlsort", "l", "limit", "0", "10", "by", "store"},
+		{"xssort", "s", "alpha"},
+		{"xzsort", "z", "desc"},
+	}
+	for _, args := range reads {
+		if _, err := c.Do(args[0].(string), args[1:]...); err != nil {
+			t.Fatal(args, err)
+		}
+	}
+
+	c.Do("multi")
+	for _, args := range reads {
+		c.Do(args[0].(string), args[1:]...)
+	}
+	if v, err := goredis.MultiBulk(c.Do("exec")); err != nil || len(v) != len(reads) {
+		t.Fatal(v, err)
+	}
+
+	// the write queued in MULTI fails EXEC
+	if _, err := c.Do("multi"); err != nil {
+		t.Fatal(err)
+	} else if _, err := c.Do("set", "a", "1"); err != nil {
+		t.Fatal(err)
+	} else if _, err := c.Do("exec"); err == nil || err.Error() != ErrReadonly.Error() {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("multi"); err != nil {
+		t.Fatal(err)
+	} else if _, err := c.Do("get", "a"); err != nil {
+		t.Fatal(err)
+	} else if v, err := goredis.MultiBulk(c.Do("exec")); err != nil || len(v) != 1 || v[0] != nil {
+		t.Fatal(v, err)
+	}
+
+	// the replication still writes
+	db, _ := master.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+
+	var buf bytes.Buffer
+	if _, _, err := master.ReadLogsTo(1, &buf); err != nil {
+		t.Fatal(err)
+	} else if err := slave.ldb.StoreLogsFromReader(&buf); err != nil {
+		t.Fatal(err)
+	}
+	slave.ldb.WaitReplication()
+
+	if v, err := goredis.String(c.Do("get", "a")); err != nil || v != "1" {
+		t.Fatal(v, err)
+	}
+}
+
+func TestReplicationHeartbeat(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_replication_heartbeat"
+	cfg.Addr = "127.0.0.1:11196"
+	cfg.UseReplication = true
+	cfg.Replication.HeartbeatTimeout = 1
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	connectSlave := func(port string) *goredis.Conn {
+		c, err := goredis.Connect(cfg.Addr)
+		if err != nil {
+			t.Fatal(err)
+		} else if _, err = c.Do("replconf", "listening-port", port); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// the heartbeat of the master is an empty reply
+	synced := connectSlave("11197")
+	defer synced.Close()
+
+	stalled := connectSlave("11198")
+	defer stalled.Close()
+
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		if _, err := synced.Do("sync", 1); err != nil {
+			t.Fatal(err)
+		} else if d := time.Since(start); d > 2*time.Second {
+			t.Fatal(d)
+		}
+	}
+
+	if _, err := stalled.Do("ping"); err == nil {
+		t.Fatal("the stalled slave must be closed")
+	}
+
+	if s := app.replicationStatus(); s.SlaveCount != 1 || s.Slaves[0].Addr != "127.0.0.1:11197" {
+		t.Fatal(s)
+	}
+}
+
+func TestPSync(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_psync"
+	cfg.Addr = "127.0.0.1:11199"
+	cfg.UseReplication = true
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	db, _ := app.ldb.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+
+	c, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	id := app.ldb.ReplicationID()
+	for _, test := range []struct {
+		id    string
+		logID int
+		reply string
+	}{
+		{id, 1, psyncContinue},
+		{id, 3, psyncContinue},
+		{id, 4, psyncFullResync},
+		{"?", 1, psyncFullResync},
+	} {
+		if s, err := goredis.String(c.Do("psync", test.id, test.logID)); err != nil {
+			t.Fatal(err)
+		} else if s != test.reply+" "+id {
+			t.Fatal(test, s)
+		}
+	}
+
+	// the slaves of the old id continue up to the promotion
+	if err = app.ldb.SlaveOfNoOne(); err != nil {
+		t.Fatal(err)
+	}
+	db.Set([]byte("c"), []byte("3"))
+
+	newID := app.ldb.ReplicationID()
+	if newID == id {
+		t.Fatal("id not changed")
+	}
+
+	for _, test := range []struct {
+		id    string
+		logID int
+		reply string
+	}{
+		{id, 3, psyncContinue},
+		{id, 4, psyncFullResync},
+		{newID, 4, psyncContinue},
+		{"", 1, psyncFullResync},
+	} {
+		if s, err := goredis.String(c.Do("psync", test.id, test.logID)); err != nil {
+			t.Fatal(err)
+		} else if s != test.reply+" "+newID {
+			t.Fatal(test, s)
+		}
+	}
+}
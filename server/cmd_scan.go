@@ -90,6 +90,10 @@ func (scg scanCommandGroup) xscanCommand(c *client) error {
 		dataType = ledis.SET
 	case "ZSET":
 		dataType = ledis.ZSET
+	case "HLL":
+		dataType = ledis.HLL
+	case "STREAM":
+		dataType = ledis.STREAM
 	default:
 		return fmt.Errorf("invalid key type %s", args[0])
 	}
@@ -211,6 +215,90 @@ func (scg scanCommandGroup) xsscanCommand(c *client) error {
 	return nil
 }
 
+// SSCAN key cursor [MATCH pattern] [COUNT count]
+//
+// Unlike XSSCAN, MATCH is a glob pattern and COUNT is a hint, a page may
+// hold more or fewer members, and only a returned "0" cursor ends the scan.
+func sscanCommand(c *client) error {
+	args := c.args
+
+	if len(args) < 2 {
+		return ErrCmdParams
+	}
+
+	key := args[0]
+
+	cursor, match, count, desc, err := parseScanArgs(args[1:])
+	if err != nil {
+		return err
+	} else if desc {
+		return scanGroup.xsscanCommand(c)
+	}
+
+	next, ay, err := c.db.SScanMatch(key, cursor, count, match)
+	if err != nil {
+		return err
+	}
+
+	if next == nil {
+		next = nilCursorRedis
+	}
+
+	c.resp.writeArray([]interface{}{next, ay})
+	return nil
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+//
+// The keys of all the data types are scanned, the cursor is "0" or the one
+// returned by the last SCAN.
+func scanCommand(c *client) error {
+	args := c.args
+
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	cursor := args[0]
+	if bytes.Equal(cursor, nilCursorRedis) {
+		cursor = nil
+	}
+
+	var match, keyType string
+	count := 10
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return ErrCmdParams
+		}
+
+		switch strings.ToUpper(hack.String(args[i])) {
+		case "MATCH":
+			match = hack.String(args[i+1])
+		case "COUNT":
+			var err error
+			if count, err = strconv.Atoi(hack.String(args[i+1])); err != nil {
+				return ErrValue
+			}
+		case "TYPE":
+			keyType = strings.ToLower(hack.String(args[i+1]))
+		default:
+			return fmt.Errorf("invalid argument %s", args[i])
+		}
+	}
+
+	next, ay, err := c.db.ScanKeys(cursor, count, match, keyType)
+	if err != nil {
+		return err
+	}
+
+	if next == nil {
+		next = nilCursorRedis
+	}
+
+	c.resp.writeArray([]interface{}{next, ay})
+	return nil
+}
+
 // XZSCAN key cursor [MATCH match] [COUNT count] [ASC|DESC]
 func (scg scanCommandGroup) xzscanCommand(c *client) error {
 	args := c.args
@@ -269,8 +357,9 @@ var (
 )
 
 func init() {
+	register("scan", scanCommand)
 	register("hscan", scanGroup.xhscanCommand)
-	register("sscan", scanGroup.xsscanCommand)
+	register("sscan", sscanCommand)
 	register("zscan", scanGroup.xzscanCommand)
 
 	register("xscan", xScanGroup.xscanCommand)
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/siddontang/goredis"
 	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
 )
 
 func TestScan(t *testing.T) {
@@ -185,6 +187,31 @@ func TestSetScan(t *testing.T) {
 	} else {
 		checkScanValues(t, ay[1], "a", "b")
 	}
+
+	key = "scan_set_match"
+	c.Do("SADD", key, "a1", "a2", "b1", "b2", "c1")
+
+	var members []string
+	cursor := "0"
+	for {
+		ay, err := goredis.Values(c.Do("SSCAN", key, cursor, "MATCH", "[ab]1", "COUNT", 1))
+		if err != nil {
+			t.Fatal(err)
+		} else if len(ay) != 2 {
+			t.Fatal(len(ay))
+		}
+
+		v, _ := goredis.Strings(ay[1], nil)
+		members = append(members, v...)
+
+		if cursor, _ = goredis.String(ay[0], nil); cursor == "0" {
+			break
+		}
+	}
+
+	if len(members) != 2 || members[0] != "a1" || members[1] != "b1" {
+		t.Fatal(members)
+	}
 }
 
 func TestXZSetScan(t *testing.T) {
@@ -219,3 +246,107 @@ func TestZSetScan(t *testing.T) {
 	}
 
 }
+
+func TestScanCommandTimeout(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_command_timeout"
+	cfg.Addr = "127.0.0.1:11190"
+	cfg.CommandTimeout = 100
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	db, _ := app.ldb.Select(0)
+
+	const total = 1000000
+	pairs := make([]ledis.KVPair, 0, 10000)
+	for i := 0; i < total; i++ {
+		pairs = append(pairs, ledis.KVPair{Key: []byte(fmt.Sprintf("timeout_%07d", i)), Value: []byte("v")})
+		if len(pairs) == cap(pairs) {
+			if err := db.MSet(pairs...); err != nil {
+				t.Fatal(err)
+			}
+			pairs = pairs[:0]
+		}
+	}
+
+	c := goredis.NewClient(cfg.Addr, "")
+	defer c.Close()
+
+	start := time.Now()
+	if _, err := c.Do("xscan", "KV", "", "COUNT", total); err == nil || err.Error() != ErrCmdTimeout.Error() {
+		t.Fatal(err)
+	} else if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("scan interrupted after %s", d)
+	}
+
+	// a command which can not stop has its connection closed
+	tc := newClient(app)
+	killed := make(chan struct{})
+	tc.kill = func() { close(killed) }
+	tc.cmd = "test"
+	tc.execute(func(c *client) error {
+		select {
+		case <-killed:
+		case <-time.After(time.Second):
+		}
+		return nil
+	})
+
+	select {
+	case <-killed:
+	default:
+		t.Fatal("connection not closed")
+	}
+}
+
+func TestKeyScan(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("select", 9)
+	defer c.Do("select", 0)
+	c.Do("flushdb")
+
+	c.Do("set", "scan_a", "v")
+	c.Do("set", "scan_b", "v")
+	c.Do("hset", "scan_a", "f", "v")
+	c.Do("rpush", "scan_c", "v")
+
+	scanAll := func(args ...interface{}) []string {
+		var keys []string
+		cursor := "0"
+		for {
+			ay, err := goredis.Values(c.Do("SCAN", append([]interface{}{cursor}, args...)...))
+			if err != nil {
+				t.Fatal(err)
+			} else if len(ay) != 2 {
+				t.Fatal(len(ay))
+			}
+
+			v, _ := goredis.Strings(ay[1], nil)
+			keys = append(keys, v...)
+
+			if cursor, _ = goredis.String(ay[0], nil); cursor == "0" {
+				return keys
+			}
+		}
+	}
+
+	checkStrings(t, scanAll("COUNT", 1), "scan_a", "scan_b", "scan_c", "scan_a")
+	checkStrings(t, scanAll("TYPE", "string", "MATCH", "*_b"), "scan_b")
+	checkStrings(t, scanAll("TYPE", "HASH"), "scan_a")
+
+	if _, err := c.Do("SCAN", "0", "TYPE", "unknown"); err == nil {
+		t.Fatal("must error")
+	} else if _, err := c.Do("SCAN", "0", "COUNT"); err == nil {
+		t.Fatal("must error")
+	}
+
+	c.Do("flushdb")
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"errors"
@@ -10,6 +11,7 @@ import (
 
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/yuin/gopher-lua"
 )
@@ -46,12 +48,15 @@ func evalGenericCommand(c *client, evalSha1 bool) (err error) {
 
 	defer func() {
 		luaClient.db = nil
+		luaClient.user = ""
 		// luaClient.script = nil
 
 		s.Unlock()
 	}()
 
 	luaClient.db = c.db
+	// the commands of the script are checked against the ACL user of c
+	luaClient.user = c.user
 	// luaClient.script = m
 	luaClient.remoteAddr = c.remoteAddr
 
@@ -86,12 +91,26 @@ func evalGenericCommand(c *client, evalSha1 bool) (err error) {
 
 	l.Push(global)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	l.SetContext(ctx)
+	s.setRunning(cancel)
+
+	defer func() {
+		s.setRunning(nil)
+		l.RemoveContext()
+		cancel()
+	}()
+
 	// catch any uncaught panic
 	// this happens for example when the user,
 	// makes a mistake using `ledis.call`
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("panic: %v", r)
+			if ctx.Err() != nil {
+				err = ErrScriptKilled
+			} else {
+				err = fmt.Errorf("panic: %v", r)
+			}
 		}
 	}()
 	l.Call(0, lua.MultRet)
@@ -114,6 +133,17 @@ func evalshaCommand(c *client) error {
 }
 
 func scriptCommand(c *client) error {
+	args := c.args
+
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	// the running script holds the lock
+	if strings.ToLower(hack.String(args[0])) == "kill" {
+		return scriptKillCommand(c)
+	}
+
 	s := c.app.script
 	l := s.l
 
@@ -126,12 +156,6 @@ func scriptCommand(c *client) error {
 		s.Unlock()
 	}()
 
-	args := c.args
-
-	if len(args) < 1 {
-		return ErrCmdParams
-	}
-
 	switch strings.ToLower(hack.String(args[0])) {
 	case "load":
 		return scriptLoadCommand(c)
@@ -207,6 +231,21 @@ func scriptFlushCommand(c *client) error {
 	return nil
 }
 
+func scriptKillCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	var limit time.Duration
+	c.app.cfg.View(func() { limit = time.Duration(c.app.cfg.LuaTimeLimit) * time.Millisecond })
+	if err := c.app.script.kill(limit); err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
 func init() {
 	register("eval", evalCommand)
 	register("evalsha", evalshaCommand)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/siddontang/goredis"
 )
@@ -62,3 +63,51 @@ func TestCmdEval(t *testing.T) {
 		t.Fatal(fmt.Sprintf("%v", ay))
 	}
 }
+
+func TestCmdScriptKill(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("script", "kill"); err == nil || err.Error() != ErrScriptNotBusy.Error() {
+		t.Fatal(err)
+	}
+
+	cfg := testApp.cfg
+	var limit int
+	cfg.Update(func() {
+		limit = cfg.LuaTimeLimit
+		cfg.LuaTimeLimit = 100
+	})
+	defer cfg.Update(func() { cfg.LuaTimeLimit = limit })
+
+	done := make(chan error, 1)
+	go func() {
+		c1 := getTestConn()
+		defer c1.Close()
+
+		_, err := c1.Do("eval", "while true do end", 0)
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if ok, err := goredis.String(c.Do("script", "kill")); err != nil {
+		t.Fatal(err)
+	} else if ok != "OK" {
+		t.Fatal(ok)
+	}
+
+	if err := <-done; err == nil || err.Error() != ErrScriptKilled.Error() {
+		t.Fatal(err)
+	} else if d := time.Since(start); d < 50*time.Millisecond {
+		t.Fatal("killed before the time limit", d)
+	}
+
+	// the script engine is usable after the kill
+	if v, err := goredis.Int(c.Do("eval", "return 1", 0)); err != nil {
+		t.Fatal(err)
+	} else if v != 1 {
+		t.Fatal(v)
+	}
+}
@@ -5,12 +5,24 @@ import (
 	"github.com/siddontang/go/num"
 
 	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 func pingCommand(c *client) error {
+	// like redis, a reply in the pub/sub mode of RESP2 is an array
+	if c.subscribed() && !c.resp3() {
+		msg := []byte{}
+		if len(c.args) > 0 {
+			msg = c.args[0]
+		}
+		c.resp.writeSliceArray([][]byte{[]byte("pong"), msg})
+		return nil
+	}
+
 	c.resp.writeStatus(PONG)
 	return nil
 }
@@ -19,19 +31,44 @@ func defaultAuth(c *config.Config, password string) bool {
 	return c.AuthPassword == password
 }
 
+// AUTH [username] password
 func authCommand(c *client) error {
-	if len(c.args) != 1 {
+	if len(c.args) != 1 && len(c.args) != 2 {
 		return ErrCmdParams
 	}
 
+	if err := c.authenticate(c.args); err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// authenticate authenticates c with [username] password.
+func (c *client) authenticate(args [][]byte) error {
+	// the default user is the one with auth_password
+	if len(args) == 2 && string(args[0]) != "default" {
+		name := string(args[0])
+		if u := c.app.ldb.ACLGetUser(name); u != nil && u.CheckPassword(string(args[1])) {
+			c.isAuthed = true
+			c.user = name
+			return nil
+		}
+
+		c.isAuthed = false
+		c.user = ""
+		return ErrAuthenticationFailure
+	}
+
 	method := defaultAuth
 	if c.app.cfg.AuthMethod != nil {
 		method = c.app.cfg.AuthMethod
 	}
 
-	if method(c.app.cfg, string(c.args[0])) {
+	c.user = ""
+	if method(c.app.cfg, string(args[len(args)-1])) {
 		c.isAuthed = true
-		c.resp.writeStatus(OK)
 		return nil
 	} else {
 		c.isAuthed = false
@@ -39,6 +76,83 @@ func authCommand(c *client) error {
 	}
 }
 
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func helloCommand(c *client) error {
+	w, ok := c.resp.(*respWriter)
+	if !ok {
+		return ErrHelloClient
+	}
+
+	args := c.args
+	version := w.protocolVersion
+	if len(args) > 0 {
+		var err error
+		if version, err = strconv.Atoi(hack.String(args[0])); err != nil {
+			return ErrValue
+		} else if version != 2 && version != 3 {
+			return ErrNoProto
+		}
+		args = args[1:]
+	}
+
+	var auth [][]byte
+	var name []byte
+	for len(args) > 0 {
+		switch strings.ToLower(hack.String(args[0])) {
+		case "auth":
+			if len(args) < 3 {
+				return ErrSyntax
+			}
+			auth = args[1:3]
+			args = args[3:]
+		case "setname":
+			if len(args) < 2 {
+				return ErrSyntax
+			}
+			name = args[1]
+			args = args[2:]
+		default:
+			return ErrSyntax
+		}
+	}
+
+	if auth != nil {
+		if err := c.authenticate(auth); err != nil {
+			return err
+		}
+	} else if c.authEnabled() && !c.isAuthed {
+		return ErrNotAuthenticated
+	}
+
+	if name != nil {
+		c.name.Set(string(name))
+	}
+
+	c.app.m.Lock()
+	role := "master"
+	if len(c.app.cfg.SlaveOf) > 0 {
+		role = "slave"
+	}
+	c.app.m.Unlock()
+
+	w.protocolVersion = version
+
+	w.writeMapHeader(6)
+	w.writeBulk([]byte("server"))
+	w.writeBulk([]byte("ledis"))
+	w.writeBulk([]byte("version"))
+	w.writeBulk([]byte(ledis.Version))
+	w.writeBulk([]byte("proto"))
+	w.writeInteger(int64(version))
+	w.writeBulk([]byte("mode"))
+	w.writeBulk([]byte("standalone"))
+	w.writeBulk([]byte("role"))
+	w.writeBulk([]byte(role))
+	w.writeBulk([]byte("modules"))
+	w.writeSliceArray([][]byte{})
+	return nil
+}
+
 func echoCommand(c *client) error {
 	if len(c.args) != 1 {
 		return ErrCmdParams
@@ -110,8 +224,27 @@ func flushallCommand(c *client) error {
 	return nil
 }
 
+// FLUSHDB [ASYNC | SYNC]
 func flushdbCommand(c *client) error {
-	_, err := c.db.FlushAll()
+	async := false
+	if len(c.args) > 1 {
+		return ErrCmdParams
+	} else if len(c.args) == 1 {
+		switch strings.ToLower(hack.String(c.args[0])) {
+		case "async":
+			async = true
+		case "sync":
+		default:
+			return ErrCmdParams
+		}
+	}
+
+	var err error
+	if async {
+		err = c.db.FlushDBAsync()
+	} else {
+		_, err = c.db.FlushAll()
+	}
 	if err != nil {
 		return err
 	}
@@ -120,6 +253,21 @@ func flushdbCommand(c *client) error {
 	return nil
 }
 
+// DBSIZE
+func dbsizeCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	n, err := c.db.DBSize()
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
 func timeCommand(c *client) error {
 	if len(c.args) != 0 {
 		return ErrCmdParams
@@ -143,23 +291,59 @@ func timeCommand(c *client) error {
 	return nil
 }
 
+// RESET
+func resetCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	c.reset()
+	c.resp.writeStatus(RESET)
+	return nil
+}
+
+// CONFIG GET pattern
 func configGetCommand(c *client) error {
 	args := c.args
 	if len(args) != 2 {
 		return ErrCmdParams
 	}
 
-	ay := make([][]byte, 0, 2)
-	key := hack.String(args[1])
-	switch key {
-	case "databases":
-		ay = append(ay, []byte("databases"), num.FormatIntToSlice(c.app.cfg.Databases))
+	m, err := c.app.ldb.ConfigGet(hack.String(args[1]))
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ay := make([][]byte, 0, 2*len(names))
+	for _, name := range names {
+		ay = append(ay, []byte(name), []byte(m[name]))
 	}
 
 	c.resp.writeSliceArray(ay)
 	return nil
 }
 
+// CONFIG SET parameter value
+func configSetCommand(c *client) error {
+	args := c.args
+	if len(args) != 3 {
+		return ErrCmdParams
+	}
+
+	if err := c.app.ldb.ConfigSet(hack.String(args[1]), hack.String(args[2])); err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
 func configCommand(c *client) error {
 	if len(c.args) < 1 {
 		return ErrCmdParams
@@ -175,19 +359,134 @@ func configCommand(c *client) error {
 		}
 	case "get":
 		return configGetCommand(c)
+	case "set":
+		return configSetCommand(c)
+	default:
+		return ErrCmdParams
+	}
+}
+
+// OBJECT ENCODING|IDLETIME|FREQ key | HELP
+func objectCommand(c *client) error {
+	args := c.args
+	if len(args) == 1 && strings.ToLower(hack.String(args[0])) == "help" {
+		c.resp.writeSliceArray(stringsToSlices(c.db.ObjectHelp()))
+		return nil
+	} else if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	var f func(key []byte) (int64, error)
+	switch strings.ToLower(hack.String(args[0])) {
+	case "encoding":
+		if enc, err := c.db.ObjectEncoding(args[1]); err != nil {
+			return err
+		} else if len(enc) == 0 {
+			c.resp.writeBulk(nil)
+		} else {
+			c.resp.writeBulk([]byte(enc))
+		}
+		return nil
+	case "idletime":
+		f = c.db.ObjectIdleTime
+	case "freq":
+		f = c.db.ObjectFreq
+	default:
+		return ErrCmdParams
+	}
+
+	if n, err := f(args[1]); err != nil {
+		return err
+	} else if n < 0 {
+		c.resp.writeBulk(nil)
+	} else {
+		c.resp.writeInteger(n)
+	}
+	return nil
+}
+
+// TYPE key
+func typeCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	if tp, err := c.db.Type(c.args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeStatus(tp)
+	}
+	return nil
+}
+
+// SLOWLOG GET [count] | LEN | RESET
+func slowlogCommand(c *client) error {
+	args := c.args
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(hack.String(args[0])) {
+	case "get":
+		count := int64(10)
+		if len(args) == 2 {
+			var err error
+			if count, err = strconv.ParseInt(hack.String(args[1]), 10, 64); err != nil {
+				return ErrValue
+			}
+		} else if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		entries := c.app.slowlog.Get(int(count))
+		ay := make([]interface{}, len(entries))
+		for i, e := range entries {
+			cmd := make([][]byte, 0, len(e.Args)+1)
+			cmd = append(cmd, []byte(e.Cmd))
+			cmd = append(cmd, e.Args...)
+
+			ay[i] = []interface{}{
+				int64(e.ID),
+				e.Time.Unix(),
+				int64(e.Duration / time.Microsecond),
+				cmd,
+				[]byte(e.RemoteAddr),
+				[]byte(""),
+			}
+		}
+		c.resp.writeArray(ay)
+	case "len":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+		c.resp.writeInteger(int64(c.app.slowlog.Len()))
+	case "reset":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+		c.app.slowlog.Reset()
+		c.resp.writeStatus(OK)
 	default:
 		return ErrCmdParams
 	}
+
+	return nil
 }
 
 func init() {
 	register("auth", authCommand)
+	register("hello", helloCommand)
 	register("ping", pingCommand)
 	register("echo", echoCommand)
 	register("select", selectCommand)
 	register("info", infoCommand)
 	register("flushall", flushallCommand)
 	register("flushdb", flushdbCommand)
+	register("dbsize", dbsizeCommand)
 	register("time", timeCommand)
 	register("config", configCommand)
+	register("object", objectCommand)
+	register("type", typeCommand)
+	register("slowlog", slowlogCommand)
+	register("reset", resetCommand)
 }
@@ -1,9 +1,18 @@
 package server
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/ledis"
 )
 
 func TestAuth(t *testing.T) {
@@ -87,3 +96,375 @@ func TestXSelect(t *testing.T) {
 	c2.Do("SELECT", 0)
 
 }
+
+func TestType(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("set", "type_kv", "v")
+	c.Do("zadd", "type_zset", 1, "a")
+
+	for key, tp := range map[string]string{"type_kv": "string", "type_zset": "zset", "type_none": "none"} {
+		if v, err := goredis.String(c.Do("type", key)); err != nil {
+			t.Fatal(err)
+		} else if v != tp {
+			t.Fatal(key, v)
+		}
+	}
+
+	if help, err := goredis.Strings(c.Do("object", "help")); err != nil {
+		t.Fatal(err)
+	} else if len(help) == 0 || !strings.HasPrefix(help[0], "OBJECT") {
+		t.Fatal(help)
+	}
+}
+
+func TestObject(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("set", "object_encoding_kv", "123")
+	c.Do("sadd", "object_encoding_set", "a")
+
+	if v, err := goredis.String(c.Do("object", "encoding", "object_encoding_kv")); err != nil {
+		t.Fatal(err)
+	} else if v != "int" {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.String(c.Do("object", "encoding", "object_encoding_set")); err != nil {
+		t.Fatal(err)
+	} else if v != "listpack" {
+		t.Fatal(v)
+	}
+
+	if _, err := goredis.String(c.Do("object", "encoding", "object_encoding_none")); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	c.Do("get", "object_encoding_kv")
+	if n, err := goredis.Int(c.Do("object", "idletime", "object_encoding_kv")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, err := goredis.Int(c.Do("object", "idletime", "object_encoding_none")); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	// the default noeviction policy does not track the frequency
+	if _, err := c.Do("object", "freq", "object_encoding_kv"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("object", "refcount", "object_encoding_kv"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestSlowlog(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	cfg := testApp.cfg
+	setSlower := func(v int) {
+		cfg.Update(func() { cfg.SlowlogLogSlowerThan = v })
+	}
+
+	var slower int
+	cfg.View(func() { slower = cfg.SlowlogLogSlowerThan })
+	defer setSlower(slower)
+
+	setSlower(-1)
+	c.Do("slowlog", "reset")
+
+	setSlower(0)
+	c.Do("set", "test_slowlog", "v")
+
+	setSlower(-1)
+
+	if n, err := goredis.Int(c.Do("slowlog", "len")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	ay, err := goredis.Values(c.Do("slowlog", "get"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 {
+		t.Fatal(len(ay))
+	}
+
+	e, _ := goredis.Values(ay[0], nil)
+	if len(e) != 6 {
+		t.Fatal(len(e))
+	} else if cmd, _ := goredis.Strings(e[3], nil); !reflect.DeepEqual(cmd, []string{"set", "test_slowlog", "v"}) {
+		t.Fatal(cmd)
+	}
+
+	if _, err := c.Do("slowlog", "reset"); err != nil {
+		t.Fatal(err)
+	} else if n, _ := goredis.Int(c.Do("slowlog", "len")); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	slower := testApp.cfg.SlowlogLogSlowerThan
+	defer func() { testApp.cfg.SlowlogLogSlowerThan = slower }()
+
+	if ok, err := goredis.String(c.Do("config", "set", "slowlog-log-slower-than", 20000)); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if ay, err := goredis.Strings(c.Do("config", "get", "slowlog*")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ay, []string{"slowlog_log_slower_than", "20000", "slowlog_max_len", "128"}) {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("config", "set", "databases", 2); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestInfoMemory(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if ok, err := goredis.String(c.Do("config", "set", "maxmemory", 1<<40)); err != nil || ok != OK {
+		t.Fatal(ok, err)
+	}
+	defer func() { testApp.cfg.MaxMemory = 0 }()
+
+	s, err := goredis.String(c.Do("info", "memory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"\r\nused_memory:", "\r\nmaxmemory:1099511627776\r\n", "\r\nevicted_keys:0\r\n"} {
+		if !strings.Contains(s, line) {
+			t.Fatal(s)
+		}
+	}
+}
+
+func TestInfoSections(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("set", "test_info_sections", "v")
+
+	s, err := goredis.String(c.Do("info", "all"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sections := map[string]bool{}
+	fields := map[string]string{}
+	for _, line := range strings.Split(s, "\r\n") {
+		if strings.HasPrefix(line, "# ") {
+			sections[line[2:]] = true
+		} else if i := strings.IndexByte(line, ':'); i > 0 {
+			fields[line[:i]] = line[i+1:]
+		}
+	}
+
+	for _, section := range []string{"Server", "Clients", "Mem", "Persistence", "Stats", "Replication", "CPU", "Keyspace"} {
+		if !sections[section] {
+			t.Fatal(section, s)
+		}
+	}
+
+	for _, key := range []string{"connected_clients", "used_memory", "rdb_changes_since_last_save",
+		"master_replid", "master_repl_offset", "role", "total_commands_processed", "used_cpu_sys"} {
+		if _, ok := fields[key]; !ok {
+			t.Fatal(key, s)
+		}
+	}
+
+	if n, err := strconv.Atoi(fields["connected_clients"]); err != nil || n < 1 {
+		t.Fatal(fields["connected_clients"])
+	} else if !strings.HasPrefix(fields["db0"], "keys=") || !strings.Contains(fields["db0"], ",expires=") {
+		t.Fatal(fields["db0"])
+	}
+
+	if s, err = goredis.String(c.Do("info", "keyspace")); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(s, "# Keyspace\r\ndb0:keys=") {
+		t.Fatal(s)
+	}
+}
+
+func TestReset(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "test_reset"
+	c.Do("set", key, "db0")
+
+	c.Do("select", 1)
+	c.Do("watch", key)
+	c.Do("multi")
+	c.Do("set", key, "db1")
+
+	if s, err := goredis.String(c.Do("reset")); err != nil {
+		t.Fatal(err)
+	} else if s != RESET {
+		t.Fatal(s)
+	}
+
+	if _, err := c.Do("exec"); err == nil {
+		t.Fatal("must error, multi is aborted")
+	} else if v, _ := goredis.String(c.Do("get", key)); v != "db0" {
+		t.Fatal(v)
+	}
+
+	c2 := getTestConnAuth("password")
+	defer c2.Close()
+
+	if _, err := c2.Do("auth", "password"); err != nil {
+		t.Fatal(err)
+	} else if _, err := c2.Do("reset"); err != nil {
+		t.Fatal(err)
+	} else if _, err := c2.Do("get", key); err == nil || err.Error() != ErrNotAuthenticated.Error() {
+		t.Fatal(err)
+	}
+}
+
+func TestDBSize(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("select", 7)
+	// the pooled connection is reused by the other tests
+	defer c.Do("select", 0)
+	c.Do("flushdb")
+
+	c.Do("set", "test_dbsize_a", 1)
+	c.Do("hset", "test_dbsize_b", "f", 1)
+
+	if n, err := goredis.Int64(c.Do("dbsize")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("dbsize", "a"); err == nil {
+		t.Fatal("must error")
+	}
+
+	c.Do("flushdb")
+	if n, _ := goredis.Int64(c.Do("dbsize")); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestFlushDBAsync(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("select", 8)
+	defer c.Do("select", 0)
+
+	c.Do("set", "test_flushdb_async_a", 1)
+	c.Do("hset", "test_flushdb_async_b", "f", 1)
+
+	if ok, err := goredis.String(c.Do("flushdb", "ASYNC")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	for i := 0; i < 100; i++ {
+		if n, _ := goredis.Int64(c.Do("dbsize")); n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n, _ := goredis.Int64(c.Do("dbsize")); n != 0 {
+		t.Fatal(n)
+	}
+
+	c.Do("set", "test_flushdb_async_a", 1)
+	if ok, err := goredis.String(c.Do("flushdb", "sync")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+	if n, _ := goredis.Int64(c.Do("dbsize")); n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("flushdb", "now"); err == nil {
+		t.Fatal("must error")
+	}
+	if _, err := c.Do("flushdb", "async", "sync"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+// checkRawReply sends the command and checks the raw reply, goredis can't
+// read RESP3.
+func checkRawReply(t *testing.T, conn net.Conn, r *bufio.Reader, expected string, args ...string) {
+	req := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		req += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len(expected))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(args, err)
+	} else if string(buf) != expected {
+		t.Fatalf("%v: %q != %q", args, buf, expected)
+	}
+}
+
+func TestHello(t *testing.T) {
+	startTestApp()
+
+	conn, err := net.Dial("tcp", testApp.cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	checkRawReply(t, conn, r, "-NOPROTO unsupported protocol version\r\n", "hello", "4")
+
+	hello := "$6\r\nserver\r\n$5\r\nledis\r\n" +
+		fmt.Sprintf("$7\r\nversion\r\n$%d\r\n%s\r\n", len(ledis.Version), ledis.Version) +
+		"$5\r\nproto\r\n:3\r\n$4\r\nmode\r\n$10\r\nstandalone\r\n" +
+		"$4\r\nrole\r\n$6\r\nmaster\r\n$7\r\nmodules\r\n*0\r\n"
+	checkRawReply(t, conn, r, "%6\r\n"+hello, "hello", "3", "setname", "test_hello")
+
+	checkRawReply(t, conn, r, ":1\r\n", "hset", "test_hello_h", "a", "1")
+	checkRawReply(t, conn, r, "%1\r\n$1\r\na\r\n$1\r\n1\r\n", "hgetall", "test_hello_h")
+	checkRawReply(t, conn, r, "#t\r\n", "hexists", "test_hello_h", "a")
+	checkRawReply(t, conn, r, "#f\r\n", "hexists", "test_hello_h", "b")
+	checkRawReply(t, conn, r, "_\r\n", "hget", "test_hello_h", "b")
+
+	checkRawReply(t, conn, r, ":1\r\n", "sadd", "test_hello_s", "a")
+	checkRawReply(t, conn, r, "~1\r\n$1\r\na\r\n", "smembers", "test_hello_s")
+	checkRawReply(t, conn, r, "#t\r\n", "sismember", "test_hello_s", "a")
+
+	checkRawReply(t, conn, r, ":1\r\n", "zadd", "test_hello_z", "5", "a")
+	checkRawReply(t, conn, r, ",5\r\n", "zscore", "test_hello_z", "a")
+
+	// back to RESP2
+	checkRawReply(t, conn, r, "+RESET\r\n", "reset")
+	checkRawReply(t, conn, r, ":1\r\n", "sismember", "test_hello_s", "a")
+	checkRawReply(t, conn, r, "$1\r\n5\r\n", "zscore", "test_hello_z", "a")
+	checkRawReply(t, conn, r, "$-1\r\n", "hget", "test_hello_h", "b")
+}
@@ -1,6 +1,11 @@
 package server
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/hack"
 	"github.com/siddontang/ledisdb/ledis"
 )
 
@@ -40,7 +45,7 @@ func soptGeneric(c *client, optType byte) error {
 	if err != nil {
 		return err
 	} else {
-		c.resp.writeSliceArray(v)
+		c.resp.writeSliceSet(v)
 	}
 
 	return nil
@@ -106,6 +111,41 @@ func sinterstoreCommand(c *client) error {
 	return soptStoreGeneric(c, ledis.InterType)
 }
 
+// SINTERCARD numkeys key [key ...] [LIMIT limit]
+func sintercardCommand(c *client) error {
+	args := c.args
+	if len(args) < 2 {
+		return ErrCmdParams
+	}
+
+	numKeys, err := strconv.Atoi(hack.String(args[0]))
+	if err != nil || numKeys <= 0 {
+		return ErrValue
+	} else if len(args) < numKeys+1 {
+		return ErrCmdParams
+	}
+
+	keys := args[1 : numKeys+1]
+	limit := 0
+
+	rest := args[numKeys+1:]
+	if len(rest) == 2 && strings.ToLower(hack.String(rest[0])) == "limit" {
+		if limit, err = strconv.Atoi(hack.String(rest[1])); err != nil || limit < 0 {
+			return ErrValue
+		}
+	} else if len(rest) != 0 {
+		return ErrSyntax
+	}
+
+	if n, err := c.db.SInterCard(limit, keys...); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
 func sismemberCommand(c *client) error {
 	args := c.args
 	if len(args) != 2 {
@@ -115,7 +155,7 @@ func sismemberCommand(c *client) error {
 	if n, err := c.db.SIsMember(args[0], args[1]); err != nil {
 		return err
 	} else {
-		c.resp.writeInteger(n)
+		c.resp.writeBool(n == 1)
 	}
 
 	return nil
@@ -130,7 +170,7 @@ func smembersCommand(c *client) error {
 	if v, err := c.db.SMembers(args[0]); err != nil {
 		return err
 	} else {
-		c.resp.writeSliceArray(v)
+		c.resp.writeSliceSet(v)
 	}
 
 	return nil
@@ -192,59 +232,50 @@ func smclearCommand(c *client) error {
 }
 
 func sexpireCommand(c *client) error {
-	args := c.args
-	if len(args) != 2 {
-		return ErrCmdParams
-	}
-
-	duration, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
-	}
-
-	if v, err := c.db.SExpire(args[0], duration); err != nil {
-		return err
-	} else {
-		c.resp.writeInteger(v)
-	}
-
-	return nil
+	return expireGeneric(c, time.Second, ledis.SET, c.db.SExpireWithJitter)
 }
 
 func sexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Second, ledis.SET, c.db.SExpireAt)
+}
+
+func sttlCommand(c *client) error {
 	args := c.args
-	if len(args) != 2 {
+	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	when, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
-	}
-
-	if v, err := c.db.SExpireAt(args[0], when); err != nil {
+	if v, err := c.db.STTL(args[0]); err != nil {
 		return err
 	} else {
 		c.resp.writeInteger(v)
 	}
 
 	return nil
+
 }
 
-func sttlCommand(c *client) error {
+func spexpireCommand(c *client) error {
+	return expireGeneric(c, time.Millisecond, ledis.SET, c.db.SExpireWithJitter)
+}
+
+func spexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Millisecond, ledis.SET, c.db.SPExpireAt)
+}
+
+func spttlCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	if v, err := c.db.STTL(args[0]); err != nil {
+	if v, err := c.db.SPTTL(args[0]); err != nil {
 		return err
 	} else {
 		c.resp.writeInteger(v)
 	}
 
 	return nil
-
 }
 
 func spersistCommand(c *client) error {
@@ -282,6 +313,7 @@ func init() {
 	register("sdiffstore", sdiffstoreCommand)
 	register("sinter", sinterCommand)
 	register("sinterstore", sinterstoreCommand)
+	register("sintercard", sintercardCommand)
 	register("sismember", sismemberCommand)
 	register("smembers", smembersCommand)
 	register("srem", sremCommand)
@@ -294,6 +326,9 @@ func init() {
 	register("sexpireat", sexpireAtCommand)
 	register("sttl", sttlCommand)
 	register("spersist", spersistCommand)
+	register("spexpire", spexpireCommand)
+	register("spexpireat", spexpireAtCommand)
+	register("spttl", spttlCommand)
 	register("skeyexists", skeyexistsCommand)
 
 }
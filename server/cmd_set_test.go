@@ -214,3 +214,34 @@ func TestSetErrorParams(t *testing.T) {
 	}
 
 }
+
+func TestSetInterCard(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key1 := "testdb_cmd_sintercard_1"
+	key2 := "testdb_cmd_sintercard_2"
+
+	c.Do("sadd", key1, "a", "b", "c")
+	c.Do("sadd", key2, "b", "c", "d")
+
+	if n, err := goredis.Int(c.Do("sintercard", 2, key1, key2)); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("sintercard", 2, key1, key2, "limit", 1)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("sintercard", 3, key1, key2); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("sintercard", 2, key1, key2, "limit"); err == nil {
+		t.Fatal("must error")
+	}
+}
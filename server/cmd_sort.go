@@ -42,6 +42,20 @@ var storeArg = []byte("store")
 var byArg = []byte("by")
 var getArg = []byte("get")
 
+// sortWrites reports whether the sort has a STORE, it is a read without.
+func sortWrites(args [][]byte) bool {
+	for i := 1; i < len(args); i++ {
+		if bytes.EqualFold(args[i], limitArg) && i+2 < len(args) {
+			i += 2
+		} else if (bytes.EqualFold(args[i], byArg) || bytes.EqualFold(args[i], getArg)) && i+1 < len(args) {
+			i++
+		} else if bytes.EqualFold(args[i], storeArg) && i+1 < len(args) {
+			return true
+		}
+	}
+	return false
+}
+
 func handleXSort(c *client, tp string) error {
 	args := c.args
 	if len(args) == 0 {
@@ -0,0 +1,562 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+// streamEntriesReply replies the entries, an entry deleted from the stream
+// has nil fields.
+func streamEntriesReply(entries []ledis.StreamEntry) []interface{} {
+	ay := make([]interface{}, len(entries))
+	for i, e := range entries {
+		if e.Fields == nil {
+			ay[i] = []interface{}{[]byte(e.ID.String()), nil}
+			continue
+		}
+
+		fields := make([][]byte, 0, 2*len(e.Fields))
+		for _, f := range e.Fields {
+			fields = append(fields, f.Field, f.Value)
+		}
+		ay[i] = []interface{}{[]byte(e.ID.String()), fields}
+	}
+	return ay
+}
+
+func parseStreamCount(buf []byte) (int, error) {
+	n, err := strconv.Atoi(hack.String(buf))
+	if err != nil || n < 0 {
+		return 0, ErrValue
+	}
+	return n, nil
+}
+
+// XADD key ID field value [field value ...]
+func xaddCommand(c *client) error {
+	args := c.args
+	if len(args) < 4 || len(args)%2 != 0 {
+		return ErrCmdParams
+	}
+
+	fields := make([]ledis.FVPair, 0, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		fields = append(fields, ledis.FVPair{Field: args[i], Value: args[i+1]})
+	}
+
+	if id, err := c.db.XAdd(args[0], hack.String(args[1]), fields...); err != nil {
+		return err
+	} else {
+		c.resp.writeBulk([]byte(id))
+	}
+
+	return nil
+}
+
+func xlenCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.XLen(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func xrangeGeneric(c *client, reverse bool) error {
+	args := c.args
+	if len(args) != 3 && len(args) != 5 {
+		return ErrCmdParams
+	}
+
+	count := 0
+	if len(args) == 5 {
+		if strings.ToLower(hack.String(args[3])) != "count" {
+			return ErrSyntax
+		}
+
+		var err error
+		if count, err = parseStreamCount(args[4]); err != nil {
+			return err
+		}
+	}
+
+	var entries []ledis.StreamEntry
+	var err error
+	if reverse {
+		entries, err = c.db.XRevRange(args[0], hack.String(args[1]), hack.String(args[2]), count)
+	} else {
+		entries, err = c.db.XRange(args[0], hack.String(args[1]), hack.String(args[2]), count)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeArray(streamEntriesReply(entries))
+	return nil
+}
+
+// XRANGE key start end [COUNT count]
+func xrangeCommand(c *client) error {
+	return xrangeGeneric(c, false)
+}
+
+// XREVRANGE key end start [COUNT count]
+func xrevrangeCommand(c *client) error {
+	return xrangeGeneric(c, true)
+}
+
+// XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] ID [ID ...]
+func xreadCommand(c *client) error {
+	args := c.args
+
+	count := 0
+	block := false
+	var timeout time.Duration
+	var err error
+
+	i := 0
+	for ; i < len(args); i++ {
+		opt := strings.ToLower(hack.String(args[i]))
+		if opt == "streams" {
+			break
+		} else if i+1 >= len(args) {
+			return ErrSyntax
+		}
+
+		switch opt {
+		case "count":
+			i++
+			if count, err = parseStreamCount(args[i]); err != nil {
+				return err
+			}
+		case "block":
+			i++
+			ms, err := strconv.ParseInt(hack.String(args[i]), 10, 64)
+			if err != nil || ms < 0 {
+				return ErrValue
+			}
+			block = true
+			timeout = time.Duration(ms) * time.Millisecond
+		default:
+			return ErrSyntax
+		}
+	}
+
+	streams := args[i+1:]
+	if i == len(args) || len(streams) == 0 || len(streams)%2 != 0 {
+		return ErrCmdParams
+	}
+
+	keys := streams[:len(streams)/2]
+	ids := make([]string, len(keys))
+	for j, id := range streams[len(keys):] {
+		ids[j] = string(id)
+	}
+
+	var res map[string][]ledis.StreamEntry
+	if block {
+		res, err = c.db.XReadBlock(keys, ids, count, timeout)
+	} else {
+		res, err = c.db.XRead(keys, ids, count)
+	}
+
+	if err != nil {
+		return err
+	} else if len(res) == 0 {
+		c.resp.writeArray(nil)
+		return nil
+	}
+
+	ay := make([]interface{}, 0, len(res))
+	for _, key := range keys {
+		if entries, ok := res[string(key)]; ok {
+			ay = append(ay, []interface{}{key, streamEntriesReply(entries)})
+		}
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+// XGROUP CREATE key group ID [MKSTREAM] | DESTROY key group
+func xgroupCommand(c *client) error {
+	args := c.args
+	if len(args) < 3 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(hack.String(args[0])) {
+	case "create":
+		mkstream := false
+		if len(args) == 5 && strings.ToLower(hack.String(args[4])) == "mkstream" {
+			mkstream = true
+		} else if len(args) != 4 {
+			return ErrCmdParams
+		}
+
+		if err := c.db.XGroupCreate(args[1], args[2], hack.String(args[3]), mkstream); err != nil {
+			return err
+		}
+		c.resp.writeStatus(OK)
+	case "destroy":
+		if len(args) != 3 {
+			return ErrCmdParams
+		}
+
+		n, err := c.db.XGroupDestroy(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		c.resp.writeInteger(n)
+	default:
+		return ErrCmdParams
+	}
+
+	return nil
+}
+
+// XREADGROUP GROUP group consumer [COUNT count] [NOACK] STREAMS key [key ...] ID [ID ...]
+func xreadgroupCommand(c *client) error {
+	args := c.args
+	if len(args) < 3 || strings.ToLower(hack.String(args[0])) != "group" {
+		return ErrCmdParams
+	}
+
+	group, consumer := args[1], args[2]
+
+	count := 0
+	noAck := false
+	var err error
+
+	i := 3
+	for ; i < len(args); i++ {
+		opt := strings.ToLower(hack.String(args[i]))
+		if opt == "streams" {
+			break
+		}
+
+		switch opt {
+		case "count":
+			if i+1 >= len(args) {
+				return ErrSyntax
+			}
+			i++
+			if count, err = parseStreamCount(args[i]); err != nil {
+				return err
+			}
+		case "noack":
+			noAck = true
+		default:
+			return ErrSyntax
+		}
+	}
+
+	streams := args[i+1:]
+	if i == len(args) || len(streams) == 0 || len(streams)%2 != 0 {
+		return ErrCmdParams
+	}
+
+	keys := streams[:len(streams)/2]
+	ids := make([]string, len(keys))
+	for j, id := range streams[len(keys):] {
+		ids[j] = string(id)
+	}
+
+	res, err := c.db.XReadGroup(group, consumer, keys, ids, count, noAck)
+	if err != nil {
+		return err
+	} else if len(res) == 0 {
+		c.resp.writeArray(nil)
+		return nil
+	}
+
+	ay := make([]interface{}, 0, len(res))
+	for _, key := range keys {
+		if entries, ok := res[string(key)]; ok {
+			ay = append(ay, []interface{}{key, streamEntriesReply(entries)})
+		}
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+// XACK key group ID [ID ...]
+func xackCommand(c *client) error {
+	args := c.args
+	if len(args) < 3 {
+		return ErrCmdParams
+	}
+
+	ids := make([]string, len(args)-2)
+	for i, id := range args[2:] {
+		ids[i] = string(id)
+	}
+
+	n, err := c.db.XAck(args[0], args[1], ids...)
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
+// XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func xpendingCommand(c *client) error {
+	args := c.args
+	if len(args) == 2 {
+		s, err := c.db.XPendingSummary(args[0], args[1])
+		if err != nil {
+			return err
+		} else if s.Count == 0 {
+			c.resp.writeArray([]interface{}{int64(0), nil, nil, nil})
+			return nil
+		}
+
+		consumers := make([]interface{}, len(s.Consumers))
+		for i, p := range s.Consumers {
+			consumers[i] = []interface{}{p.Consumer, []byte(strconv.FormatInt(p.Count, 10))}
+		}
+
+		c.resp.writeArray([]interface{}{s.Count, []byte(s.Min.String()), []byte(s.Max.String()), consumers})
+		return nil
+	}
+
+	var minIdle int64
+	rest := args[2:]
+	if len(rest) > 0 && strings.ToLower(hack.String(rest[0])) == "idle" {
+		if len(rest) < 2 {
+			return ErrCmdParams
+		}
+
+		var err error
+		if minIdle, err = strconv.ParseInt(hack.String(rest[1]), 10, 64); err != nil || minIdle < 0 {
+			return ErrValue
+		}
+		rest = rest[2:]
+	}
+
+	if len(rest) != 3 && len(rest) != 4 {
+		return ErrCmdParams
+	}
+
+	count, err := parseStreamCount(rest[2])
+	if err != nil {
+		return err
+	}
+
+	var consumer []byte
+	if len(rest) == 4 {
+		consumer = rest[3]
+	}
+
+	ps, err := c.db.XPending(args[0], args[1], hack.String(rest[0]), hack.String(rest[1]), count, consumer, minIdle)
+	if err != nil {
+		return err
+	}
+
+	ay := make([]interface{}, len(ps))
+	for i, p := range ps {
+		ay[i] = []interface{}{[]byte(p.ID.String()), p.Consumer, p.Idle, p.Deliveries}
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+// XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func xautoclaimCommand(c *client) error {
+	args := c.args
+	if len(args) < 5 {
+		return ErrCmdParams
+	}
+
+	minIdle, err := strconv.ParseInt(hack.String(args[3]), 10, 64)
+	if err != nil || minIdle < 0 {
+		return ErrValue
+	}
+
+	count := 0
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToLower(hack.String(args[i])) {
+		case "count":
+			if i+1 >= len(args) {
+				return ErrSyntax
+			}
+			i++
+			if count, err = parseStreamCount(args[i]); err != nil {
+				return err
+			} else if count == 0 {
+				return ErrValue
+			}
+		case "justid":
+			justID = true
+		default:
+			return ErrSyntax
+		}
+	}
+
+	next, claimed, deleted, err := c.db.XAutoClaim(args[0], args[1], args[2], minIdle, hack.String(args[4]), count, justID)
+	if err != nil {
+		return err
+	}
+
+	var entries []interface{}
+	if justID {
+		entries = make([]interface{}, len(claimed))
+		for i, e := range claimed {
+			entries[i] = []byte(e.ID.String())
+		}
+	} else {
+		entries = streamEntriesReply(claimed)
+	}
+
+	ids := make([]interface{}, len(deleted))
+	for i, id := range deleted {
+		ids[i] = []byte(id.String())
+	}
+
+	c.resp.writeArray([]interface{}{[]byte(next.String()), entries, ids})
+	return nil
+}
+
+func xclearCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.XClear(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func xmclearCommand(c *client) error {
+	args := c.args
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.XMClear(args...); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func xexpireCommand(c *client) error {
+	return expireGeneric(c, time.Second, ledis.STREAM, c.db.XExpireWithJitter)
+}
+
+func xexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Second, ledis.STREAM, c.db.XExpireAt)
+}
+
+func xttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.XTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func xpexpireCommand(c *client) error {
+	return expireGeneric(c, time.Millisecond, ledis.STREAM, c.db.XExpireWithJitter)
+}
+
+func xpexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Millisecond, ledis.STREAM, c.db.XPExpireAt)
+}
+
+func xpttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.XPTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func xpersistCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if n, err := c.db.XPersist(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+
+	return nil
+}
+
+func xkeyexistsCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+	if n, err := c.db.XKeyExists(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(n)
+	}
+	return nil
+}
+
+func init() {
+	register("xadd", xaddCommand)
+	register("xlen", xlenCommand)
+	register("xrange", xrangeCommand)
+	register("xrevrange", xrevrangeCommand)
+	register("xread", xreadCommand)
+	register("xgroup", xgroupCommand)
+	register("xreadgroup", xreadgroupCommand)
+	register("xack", xackCommand)
+	register("xpending", xpendingCommand)
+	register("xautoclaim", xautoclaimCommand)
+
+	register("xclear", xclearCommand)
+	register("xmclear", xmclearCommand)
+	register("xexpire", xexpireCommand)
+	register("xexpireat", xexpireAtCommand)
+	register("xttl", xttlCommand)
+	register("xpexpire", xpexpireCommand)
+	register("xpexpireat", xpexpireAtCommand)
+	register("xpttl", xpttlCommand)
+	register("xpersist", xpersistCommand)
+	register("xkeyexists", xkeyexistsCommand)
+}
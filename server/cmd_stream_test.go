@@ -0,0 +1,183 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestStream(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key1 := "testdb_cmd_stream_1"
+	key2 := "testdb_cmd_stream_2"
+
+	if id, err := goredis.String(c.Do("xadd", key1, "1-1", "a", "1", "b", "2")); err != nil {
+		t.Fatal(err)
+	} else if id != "1-1" {
+		t.Fatal(id)
+	}
+
+	if id, err := goredis.String(c.Do("xadd", key1, "2-*", "a", "3")); err != nil {
+		t.Fatal(err)
+	} else if id != "2-0" {
+		t.Fatal(id)
+	}
+
+	if _, err := c.Do("xadd", key1, "1-5", "a", "4"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("xadd", key1, "*", "a"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if n, err := goredis.Int(c.Do("xlen", key1)); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if ay, err := goredis.Values(c.Do("xrange", key1, "-", "+")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 {
+		t.Fatal(len(ay))
+	} else if e, err := goredis.Values(ay[0], nil); err != nil {
+		t.Fatal(err)
+	} else if id, _ := goredis.String(e[0], nil); id != "1-1" {
+		t.Fatal(id)
+	} else if fields, _ := goredis.Strings(e[1], nil); len(fields) != 4 || fields[3] != "2" {
+		t.Fatal(fields)
+	}
+
+	if ay, err := goredis.Values(c.Do("xrevrange", key1, "+", "-", "count", 1)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 {
+		t.Fatal(len(ay))
+	} else if e, _ := goredis.Values(ay[0], nil); len(e) != 2 {
+		t.Fatal(e)
+	} else if id, _ := goredis.String(e[0], nil); id != "2-0" {
+		t.Fatal(id)
+	}
+
+	if ay, err := goredis.Values(c.Do("xread", "count", 1, "streams", key1, key2, "0", "0")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 {
+		t.Fatal(len(ay))
+	} else if s, _ := goredis.Values(ay[0], nil); len(s) != 2 {
+		t.Fatal(s)
+	} else if k, _ := goredis.String(s[0], nil); k != key1 {
+		t.Fatal(k)
+	} else if entries, _ := goredis.Values(s[1], nil); len(entries) != 1 {
+		t.Fatal(entries)
+	}
+
+	if _, err := goredis.Values(c.Do("xread", "block", 10, "streams", key1, "$")); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("xread", "streams", key1); err == nil {
+		t.Fatal("must error")
+	}
+
+	if n, err := goredis.Int(c.Do("xmclear", key1, key2)); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("xkeyexists", key1)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestStreamGroup(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_stream_group"
+	c.Do("xclear", key)
+	defer c.Do("xclear", key)
+
+	if _, err := c.Do("xgroup", "create", key, "g", "$"); err == nil {
+		t.Fatal("must error without the stream")
+	} else if s, err := goredis.String(c.Do("xgroup", "create", key, "g", "$", "mkstream")); err != nil || s != OK {
+		t.Fatal(s, err)
+	} else if _, err = c.Do("xgroup", "create", key, "g", "0"); err == nil || !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		t.Fatal(err)
+	}
+
+	c.Do("xadd", key, "1-1", "a", "1")
+	c.Do("xadd", key, "2-1", "b", "2")
+
+	ay, err := goredis.Values(c.Do("xreadgroup", "group", "g", "alice", "count", "1", "streams", key, ">"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 {
+		t.Fatal(ay)
+	}
+
+	if _, err = c.Do("xreadgroup", "group", "none", "alice", "streams", key, ">"); err == nil || !strings.HasPrefix(err.Error(), "NOGROUP") {
+		t.Fatal(err)
+	}
+
+	ay, err = goredis.Values(c.Do("xpending", key, "g"))
+	if err != nil {
+		t.Fatal(err)
+	} else if n, _ := goredis.Int64(ay[0], nil); n != 1 {
+		t.Fatal(ay)
+	} else if id, _ := goredis.String(ay[1], nil); id != "1-1" {
+		t.Fatal(ay)
+	}
+
+	ay, err = goredis.Values(c.Do("xpending", key, "g", "idle", 0, "-", "+", 10, "alice"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 {
+		t.Fatal(ay)
+	} else if p, _ := goredis.Values(ay[0], nil); len(p) != 4 {
+		t.Fatal(p)
+	} else if consumer, _ := goredis.String(p[1], nil); consumer != "alice" {
+		t.Fatal(consumer)
+	}
+
+	ay, err = goredis.Values(c.Do("xautoclaim", key, "g", "bob", 0, "0", "count", 10, "justid"))
+	if err != nil {
+		t.Fatal(err)
+	} else if next, _ := goredis.String(ay[0], nil); next != "0-0" {
+		t.Fatal(next)
+	} else if ids, _ := goredis.Strings(ay[1], nil); len(ids) != 1 || ids[0] != "1-1" {
+		t.Fatal(ids)
+	}
+
+	// read again by the new consumer
+	ay, err = goredis.Values(c.Do("xreadgroup", "group", "g", "bob", "streams", key, "0"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 1 {
+		t.Fatal(ay)
+	}
+
+	if n, err := goredis.Int(c.Do("xack", key, "g", "1-1", "2-1")); err != nil || n != 1 {
+		t.Fatal(n, err)
+	} else if n, err := goredis.Int(c.Do("xgroup", "destroy", key, "g")); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+
+	for _, args := range [][]interface{}{
+		{"xgroup", "create", key},
+		{"xgroup", "unknown", key, "g"},
+		{"xreadgroup", "g", "alice", "streams", key, ">"},
+		{"xreadgroup", "group", "g", "alice", "block", 0, "streams", key, ">"},
+		{"xpending", key, "g", "-", "+"},
+		{"xautoclaim", key, "g", "bob", -1, "0"},
+	} {
+		if _, err := c.Do(args[0].(string), args[1:]...); err == nil {
+			t.Fatal(args, "must error")
+		}
+	}
+}
@@ -127,3 +127,178 @@ func TestExpire(t *testing.T) {
 	}
 
 }
+
+func TestPExpire(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	for _, tt := range []string{"", "h", "l", "s", "z"} {
+		key := fmt.Sprintf("%spexpire_key", tt)
+
+		switch tt {
+		case "":
+			c.Do("set", key, "123")
+		case "h":
+			c.Do("hset", key, "a", "123")
+		case "l":
+			c.Do("rpush", key, "123")
+		case "s":
+			c.Do("sadd", key, "123")
+		case "z":
+			c.Do("zadd", key, 123, "a")
+		}
+
+		if n, err := goredis.Int(c.Do(tt+"pexpire", key, 10000)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(n)
+		}
+
+		if n, err := goredis.Int64(c.Do(tt+"pttl", key)); err != nil {
+			t.Fatal(err)
+		} else if n <= 9000 || n > 10000 {
+			t.Fatal(tt, n)
+		}
+
+		if n, err := goredis.Int(c.Do(tt+"pexpireat", key, time.Now().UnixNano()/1e6+5000)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(n)
+		}
+
+		if n, err := goredis.Int64(c.Do(tt+"ttl", key)); err != nil {
+			t.Fatal(err)
+		} else if n != 5 {
+			t.Fatal(tt, n)
+		}
+
+		if n, err := goredis.Int(c.Do(tt+"expire", key, 10, "JITTER", 0.5)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(n)
+		}
+
+		if n, err := goredis.Int64(c.Do(tt+"pttl", key)); err != nil {
+			t.Fatal(err)
+		} else if n <= 9000 || n > 15000 {
+			t.Fatal(tt, n)
+		}
+
+		if _, err := c.Do(tt+"pexpire", key, 10000, "JITTER", -1); err == nil {
+			t.Fatal("must error")
+		}
+
+		if _, err := c.Do(tt+"pexpire", key, 10000, "RANDOM", 0.5); err == nil {
+			t.Fatal("must error")
+		}
+	}
+}
+
+func TestMExpire(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("set", "mexpire_a", "1")
+	c.Do("rpush", "mexpire_b", "1")
+
+	if v, err := goredis.MultiBulk(c.Do("mexpire", 10, "mexpire_a", "mexpire_b", "mexpire_c")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 || v[0].(int64) != 1 || v[1].(int64) != 1 || v[2].(int64) != 0 {
+		t.Fatal(v)
+	}
+
+	if _, err := c.Do("pmexpire", 5000, "mexpire_b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("mttl", "mexpire_a", "mexpire_b", "mexpire_c")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 || v[0].(int64) != 10 || v[1].(int64) != 5 || v[2].(int64) != -2 {
+		t.Fatal(v)
+	}
+
+	if _, err := c.Do("mexpire", 10); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestExpireTime(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "test_expiretime"
+	c.Do("del", key)
+
+	if n, err := goredis.Int64(c.Do("expiretime", key)); err != nil || n != -2 {
+		t.Fatal(n, err)
+	}
+
+	when := now() + 100
+	c.Do("set", key, "v")
+	c.Do("expireat", key, when)
+
+	if n, err := goredis.Int64(c.Do("expiretime", key)); err != nil || n != when {
+		t.Fatal(n, err)
+	} else if n, err := goredis.Int64(c.Do("pexpiretime", key)); err != nil || n != when*1000 {
+		t.Fatal(n, err)
+	}
+
+	c.Do("persist", key)
+	if n, err := goredis.Int64(c.Do("expiretime", key)); err != nil || n != -1 {
+		t.Fatal(n, err)
+	}
+}
+
+func TestExpireCond(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "test_expire_cond"
+	c.Do("del", key)
+	c.Do("set", key, "v")
+
+	for _, tc := range []struct {
+		args []interface{}
+		n    int64
+		ttl  int64
+	}{
+		{[]interface{}{"expire", key, 100, "xx"}, 0, -1},
+		{[]interface{}{"expire", key, 100, "gt"}, 0, -1},
+		{[]interface{}{"expire", key, 100, "nx"}, 1, 100},
+		{[]interface{}{"expire", key, 200, "nx"}, 0, 100},
+		{[]interface{}{"expire", key, 50, "gt"}, 0, 100},
+		{[]interface{}{"expire", key, 200, "gt"}, 1, 200},
+		{[]interface{}{"pexpire", key, 150000, "lt", "xx"}, 1, 150},
+		{[]interface{}{"expireat", key, now() + 300, "lt"}, 0, 150},
+		{[]interface{}{"expireat", key, now() + 300, "gt"}, 1, 300},
+		{[]interface{}{"pexpireat", key, (now() + 100) * 1000, "lt"}, 1, 100},
+	} {
+		if n, err := goredis.Int64(c.Do(tc.args[0].(string), tc.args[1:]...)); err != nil || n != tc.n {
+			t.Fatal(tc.args, n, err)
+		} else if ttl, _ := goredis.Int64(c.Do("ttl", key)); ttl != tc.ttl && ttl != tc.ttl-1 {
+			t.Fatal(tc.args, ttl)
+		}
+	}
+
+	// the other data types have the options too
+	c.Do("hset", key, "f", "v")
+	if n, err := goredis.Int64(c.Do("hexpire", key, 100, "nx")); err != nil || n != 1 {
+		t.Fatal(n, err)
+	} else if n, err := goredis.Int64(c.Do("hexpire", key, 100, "nx")); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+	c.Do("hclear", key)
+
+	for _, args := range [][]interface{}{
+		{key, 100, "nx", "xx"},
+		{key, 100, "gt", "lt"},
+		{key, 100, "unknown"},
+	} {
+		if _, err := c.Do("expire", args...); err == nil {
+			t.Fatal(args, "must error")
+		}
+	}
+	if _, err := c.Do("expireat", key, now()+100, "jitter", 0.5); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/siddontang/go/hack"
 	"github.com/siddontang/go/num"
@@ -77,7 +78,7 @@ func zscoreCommand(c *client) error {
 			return err
 		}
 	} else {
-		c.resp.writeBulk(num.FormatInt64ToSlice(s))
+		c.resp.writeScore(s)
 	}
 
 	return nil
@@ -312,6 +313,10 @@ func zrangeGeneric(c *client, reverse bool) error {
 
 	key := args[0]
 
+	if !reverse && len(args) > 3 && strings.ToLower(hack.String(args[3])) == "bylex" {
+		return zrangeBylexCommand(c)
+	}
+
 	start, stop, err := zparseRange(c, args[1], args[2])
 	if err != nil {
 		return ErrValue
@@ -339,6 +344,182 @@ func zrangeGeneric(c *client, reverse bool) error {
 	return nil
 }
 
+// ZRANDMEMBER key [count [WITHSCORES]]
+func zrandmemberCommand(c *client) error {
+	args := c.args
+	count, withScores, single, err := parseRandArgs(args, "withscores")
+	if err != nil {
+		return err
+	}
+
+	v, err := c.db.ZRandMember(args[0], count)
+	if err != nil {
+		return err
+	}
+
+	if !single {
+		c.resp.writeScorePairArray(v, withScores)
+	} else if len(v) == 0 {
+		c.resp.writeBulk(nil)
+	} else {
+		c.resp.writeBulk(v[0].Member)
+	}
+
+	return nil
+}
+
+func zpopGeneric(c *client, reverse bool) error {
+	args := c.args
+	if len(args) != 1 && len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	count := 1
+	if len(args) == 2 {
+		var err error
+		if count, err = strconv.Atoi(hack.String(args[1])); err != nil || count < 0 {
+			return ErrValue
+		}
+	}
+
+	var datas []ledis.ScorePair
+	var err error
+	if reverse {
+		datas, err = c.db.ZPopMax(args[0], count)
+	} else {
+		datas, err = c.db.ZPopMin(args[0], count)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeScorePairArray(datas, true)
+	return nil
+}
+
+// ZPOPMIN key [count]
+func zpopminCommand(c *client) error {
+	return zpopGeneric(c, false)
+}
+
+// ZPOPMAX key [count]
+func zpopmaxCommand(c *client) error {
+	return zpopGeneric(c, true)
+}
+
+func zblockPopGeneric(c *client, reverse bool) error {
+	keys, timeout, err := lParseBPopArgs(c)
+	if err != nil {
+		return err
+	}
+
+	var ay []interface{}
+	if reverse {
+		ay, err = c.db.BZPopMax(keys, timeout)
+	} else {
+		ay, err = c.db.BZPopMin(keys, timeout)
+	}
+
+	if err != nil {
+		return err
+	} else if ay != nil {
+		// the score is replied as a bulk string like the other zset commands
+		ay[2] = num.FormatInt64ToSlice(ay[2].(int64))
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+func zmpopGeneric(c *client, args [][]byte, block bool, timeout time.Duration) error {
+	keys, where, count, err := parseMPopArgs(args)
+	if err != nil {
+		return err
+	}
+
+	var dir ledis.ZDir
+	switch strings.ToLower(hack.String(where)) {
+	case "min":
+		dir = ledis.ZDirMin
+	case "max":
+		dir = ledis.ZDirMax
+	default:
+		return ErrSyntax
+	}
+
+	var key []byte
+	var datas []ledis.ScorePair
+	if block {
+		key, datas, err = c.db.BZMPop(dir, count, timeout, keys...)
+	} else {
+		key, datas, err = c.db.ZMPop(dir, count, keys...)
+	}
+
+	if err != nil {
+		return err
+	} else if key == nil {
+		c.resp.writeArray(nil)
+		return nil
+	}
+
+	ay := make([]interface{}, len(datas))
+	for i, pair := range datas {
+		ay[i] = []interface{}{pair.Member, num.FormatInt64ToSlice(pair.Score)}
+	}
+
+	c.resp.writeArray([]interface{}{key, ay})
+	return nil
+}
+
+// ZMPOP numkeys key [key ...] MIN|MAX [COUNT count]
+func zmpopCommand(c *client) error {
+	return zmpopGeneric(c, c.args, false, 0)
+}
+
+// BZMPOP timeout numkeys key [key ...] MIN|MAX [COUNT count]
+func bzmpopCommand(c *client) error {
+	args := c.args
+	if len(args) < 4 {
+		return ErrCmdParams
+	}
+
+	timeout, err := parseBlockTimeout(args[0])
+	if err != nil {
+		return err
+	}
+
+	return zmpopGeneric(c, args[1:], true, timeout)
+}
+
+// BZPOPMIN key [key ...] timeout
+func bzpopminCommand(c *client) error {
+	return zblockPopGeneric(c, false)
+}
+
+// BZPOPMAX key [key ...] timeout
+func bzpopmaxCommand(c *client) error {
+	return zblockPopGeneric(c, true)
+}
+
+// ZRANGE key min max BYLEX [REV] [LIMIT offset count]
+func zrangeBylexCommand(c *client) error {
+	args := c.args
+
+	rest := args[4:]
+	reverse := false
+	if len(rest) > 0 && strings.ToLower(hack.String(rest[0])) == "rev" {
+		reverse = true
+		rest = rest[1:]
+	}
+
+	if reverse {
+		// with REV the range is given from max to min
+		return zrangebylexGeneric(c, args[0], args[2], args[1], rest, true)
+	}
+	return zrangebylexGeneric(c, args[0], args[1], args[2], rest, false)
+}
+
 func zrangeCommand(c *client) error {
 	return zrangeGeneric(c, false)
 }
@@ -456,52 +637,43 @@ func zmclearCommand(c *client) error {
 }
 
 func zexpireCommand(c *client) error {
-	args := c.args
-	if len(args) != 2 {
-		return ErrCmdParams
-	}
-
-	duration, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
-	}
-
-	v, err := c.db.ZExpire(args[0], duration)
-
-	if err == nil {
-		c.resp.writeInteger(v)
-	}
-
-	return err
+	return expireGeneric(c, time.Second, ledis.ZSET, c.db.ZExpireWithJitter)
 }
 
 func zexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Second, ledis.ZSET, c.db.ZExpireAt)
+}
+
+func zttlCommand(c *client) error {
 	args := c.args
-	if len(args) != 2 {
+	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	when, err := ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrValue
+	if v, err := c.db.ZTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
 	}
 
-	v, err := c.db.ZExpireAt(args[0], when)
+	return nil
+}
 
-	if err == nil {
-		c.resp.writeInteger(v)
-	}
+func zpexpireCommand(c *client) error {
+	return expireGeneric(c, time.Millisecond, ledis.ZSET, c.db.ZExpireWithJitter)
+}
 
-	return err
+func zpexpireAtCommand(c *client) error {
+	return expireAtGeneric(c, time.Millisecond, ledis.ZSET, c.db.ZPExpireAt)
 }
 
-func zttlCommand(c *client) error {
+func zpttlCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	if v, err := c.db.ZTTL(args[0]); err != nil {
+	if v, err := c.db.ZPTTL(args[0]); err != nil {
 		return err
 	} else {
 		c.resp.writeInteger(v)
@@ -642,6 +814,68 @@ func zinterstoreCommand(c *client) error {
 	return err
 }
 
+func zparseDiffKeys(args [][]byte) ([][]byte, [][]byte, error) {
+	nKeys, err := strconv.Atoi(hack.String(args[0]))
+	if err != nil || nKeys <= 0 {
+		return nil, nil, ErrValue
+	} else if len(args) < nKeys+1 {
+		return nil, nil, ErrSyntax
+	}
+
+	return args[1 : nKeys+1], args[nKeys+1:], nil
+}
+
+// ZDIFF numkeys key [key ...] [WITHSCORES]
+func zdiffCommand(c *client) error {
+	args := c.args
+	if len(args) < 2 {
+		return ErrCmdParams
+	}
+
+	srcKeys, args, err := zparseDiffKeys(args)
+	if err != nil {
+		return err
+	}
+
+	withScores := false
+	if len(args) > 0 {
+		if len(args) != 1 || strings.ToLower(hack.String(args[0])) != "withscores" {
+			return ErrSyntax
+		}
+		withScores = true
+	}
+
+	if datas, err := c.db.ZDiff(srcKeys...); err != nil {
+		return err
+	} else {
+		c.resp.writeScorePairArray(datas, withScores)
+	}
+	return nil
+}
+
+// ZDIFFSTORE destination numkeys key [key ...]
+func zdiffstoreCommand(c *client) error {
+	args := c.args
+	if len(args) < 3 {
+		return ErrCmdParams
+	}
+
+	srcKeys, rest, err := zparseDiffKeys(args[1:])
+	if err != nil {
+		return err
+	} else if len(rest) != 0 {
+		return ErrSyntax
+	}
+
+	n, err := c.db.ZDiffStore(args[0], srcKeys)
+
+	if err == nil {
+		c.resp.writeInteger(n)
+	}
+
+	return err
+}
+
 func zparseMemberRange(minBuf []byte, maxBuf []byte) (min []byte, max []byte, rangeType uint8, err error) {
 	rangeType = store.RangeClose
 	if strings.ToLower(hack.String(minBuf)) == "-" {
@@ -684,42 +918,105 @@ func zparseMemberRange(minBuf []byte, maxBuf []byte) (min []byte, max []byte, ra
 	return
 }
 
+func zparseLexLimit(args [][]byte) (offset int, count int, err error) {
+	offset = 0
+	count = -1
+
+	if len(args) == 0 {
+		return
+	} else if len(args) != 3 {
+		err = ErrCmdParams
+		return
+	}
+
+	if strings.ToLower(hack.String(args[0])) != "limit" {
+		err = ErrSyntax
+		return
+	}
+
+	if offset, err = strconv.Atoi(hack.String(args[1])); err != nil {
+		err = ErrValue
+		return
+	}
+
+	if count, err = strconv.Atoi(hack.String(args[2])); err != nil {
+		err = ErrValue
+		return
+	}
+
+	return
+}
+
+func zrangebylexGeneric(c *client, key []byte, minBuf []byte, maxBuf []byte, args [][]byte, reverse bool) error {
+	min, max, rangeType, err := zparseMemberRange(minBuf, maxBuf)
+	if err != nil {
+		return err
+	}
+
+	offset, count, err := zparseLexLimit(args)
+	if err != nil {
+		return err
+	}
+
+	var ay [][]byte
+	if reverse {
+		ay, err = c.db.ZRevRangeByLex(key, min, max, rangeType, offset, count)
+	} else {
+		ay, err = c.db.ZRangeByLex(key, min, max, rangeType, offset, count)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeSliceArray(ay)
+	return nil
+}
+
+// ZRANGEBYLEX key min max [LIMIT offset count]
 func zrangebylexCommand(c *client) error {
 	args := c.args
 	if len(args) != 3 && len(args) != 6 {
 		return ErrCmdParams
 	}
 
-	min, max, rangeType, err := zparseMemberRange(args[1], args[2])
-	if err != nil {
-		return err
-	}
+	return zrangebylexGeneric(c, args[0], args[1], args[2], args[3:], false)
+}
 
-	var offset int = 0
-	var count int = -1
+// ZREVRANGEBYLEX key max min [LIMIT offset count]
+func zrevrangebylexCommand(c *client) error {
+	args := c.args
+	if len(args) != 3 && len(args) != 6 {
+		return ErrCmdParams
+	}
 
-	if len(args) == 6 {
-		if strings.ToLower(hack.String(args[3])) != "limit" {
-			return ErrSyntax
-		}
+	return zrangebylexGeneric(c, args[0], args[2], args[1], args[3:], true)
+}
 
-		if offset, err = strconv.Atoi(hack.String(args[4])); err != nil {
-			return ErrValue
-		}
+// ZRANGEBYLEXSTORE destination key min max [LIMIT offset count]
+func zrangebylexstoreCommand(c *client) error {
+	args := c.args
+	if len(args) != 4 && len(args) != 7 {
+		return ErrCmdParams
+	}
 
-		if count, err = strconv.Atoi(hack.String(args[5])); err != nil {
-			return ErrValue
-		}
+	min, max, rangeType, err := zparseMemberRange(args[2], args[3])
+	if err != nil {
+		return err
 	}
 
-	key := args[0]
-	if ay, err := c.db.ZRangeByLex(key, min, max, rangeType, offset, count); err != nil {
+	offset, count, err := zparseLexLimit(args[4:])
+	if err != nil {
 		return err
-	} else {
-		c.resp.writeSliceArray(ay)
 	}
 
-	return nil
+	n, err := c.db.ZRangeByLexStore(args[0], args[1], min, max, rangeType, offset, count)
+
+	if err == nil {
+		c.resp.writeInteger(n)
+	}
+
+	return err
 }
 
 func zremrangebylexCommand(c *client) error {
@@ -794,9 +1091,20 @@ func init() {
 	register("zscore", zscoreCommand)
 
 	register("zunionstore", zunionstoreCommand)
+	register("zdiff", zdiffCommand)
+	register("zdiffstore", zdiffstoreCommand)
 	register("zinterstore", zinterstoreCommand)
 
 	register("zrangebylex", zrangebylexCommand)
+	register("zrandmember", zrandmemberCommand)
+	register("zpopmin", zpopminCommand)
+	register("zpopmax", zpopmaxCommand)
+	register("bzpopmin", bzpopminCommand)
+	register("bzpopmax", bzpopmaxCommand)
+	register("zmpop", zmpopCommand)
+	register("bzmpop", bzmpopCommand)
+	register("zrevrangebylex", zrevrangebylexCommand)
+	register("zrangebylexstore", zrangebylexstoreCommand)
 	register("zremrangebylex", zremrangebylexCommand)
 	register("zlexcount", zlexcountCommand)
 
@@ -808,5 +1116,8 @@ func init() {
 	register("zexpireat", zexpireAtCommand)
 	register("zttl", zttlCommand)
 	register("zpersist", zpersistCommand)
+	register("zpexpire", zpexpireCommand)
+	register("zpexpireat", zpexpireAtCommand)
+	register("zpttl", zpttlCommand)
 	register("zkeyexists", zkeyexistsCommand)
 }
@@ -753,6 +753,40 @@ func TestZInterStore(t *testing.T) {
 	}
 }
 
+func TestZDiffStore(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("zadd", "zdiff_k1", "1", "one", "2", "two", "3", "three")
+	c.Do("zadd", "zdiff_k2", "1", "two")
+
+	if v, err := goredis.Strings(c.Do("zdiff", 2, "zdiff_k1", "zdiff_k2", "withscores")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 4 || v[0] != "one" || v[3] != "3" {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int64(c.Do("zdiffstore", "zdiff_out", 2, "zdiff_k1", "zdiff_k2")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int64(c.Do("zscore", "zdiff_out", "three")); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("zdiff", 3, "zdiff_k1", "zdiff_k2"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("zdiffstore", "zdiff_out", 1, "zdiff_k1", "zdiff_k2"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
 func TestZSetLex(t *testing.T) {
 	c := getTestConn()
 	defer c.Close()
@@ -781,6 +815,34 @@ func TestZSetLex(t *testing.T) {
 		t.Fatal("must equal")
 	}
 
+	if ay, err := goredis.Strings(c.Do("zrevrangebylex", key, "+", "[e", "limit", 0, 2)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ay, []string{"g", "f"}) {
+		t.Fatal("must equal", ay)
+	}
+
+	if ay, err := goredis.Strings(c.Do("zrange", key, "[b", "(e", "bylex", "limit", 1, 5)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ay, []string{"c", "d"}) {
+		t.Fatal("must equal", ay)
+	}
+
+	if ay, err := goredis.Strings(c.Do("zrange", key, "(e", "[b", "bylex", "rev")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ay, []string{"d", "c", "b"}) {
+		t.Fatal("must equal", ay)
+	}
+
+	if n, err := goredis.Int64(c.Do("zrangebylexstore", "myzset_lex_out", key, "[b", "[c")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("zrange", key, "[b", "(e", "bylex", "limit", 1); err == nil {
+		t.Fatal("must error")
+	}
+
 	if n, err := goredis.Int64(c.Do("zlexcount", key, "-", "(c")); err != nil {
 		t.Fatal(err)
 	} else if n != 2 {
@@ -800,3 +862,89 @@ func TestZSetLex(t *testing.T) {
 	}
 
 }
+
+func TestZPop(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_zpop"
+	c.Do("zadd", key, 1, "a", 2, "b", 3, "c")
+
+	if v, err := goredis.Strings(c.Do("zpopmin", key)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{"a", "1"}) {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Strings(c.Do("zpopmax", key, 5)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{"c", "3", "b", "2"}) {
+		t.Fatal(v)
+	}
+
+	if _, err := goredis.Values(c.Do("bzpopmin", key, 0.01)); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	c.Do("zadd", key, 5, "e")
+	if v, err := goredis.Strings(c.Do("bzpopmax", "testdb_cmd_zpop_empty", key, 1)); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{key, "e", "5"}) {
+		t.Fatal(v)
+	}
+
+	if _, err := c.Do("zpopmin", key, -1); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestZRandMember(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := "testdb_cmd_zrandmember"
+	c.Do("zadd", key, 1, "a", 2, "b")
+
+	if m, err := goredis.String(c.Do("zrandmember", key)); err != nil {
+		t.Fatal(err)
+	} else if m != "a" && m != "b" {
+		t.Fatal(m)
+	}
+
+	if v, err := goredis.Strings(c.Do("zrandmember", key, 2, "withscores")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 4 {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Strings(c.Do("zrandmember", key, -3)); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 3 {
+		t.Fatal(v)
+	}
+}
+
+func TestZMPop(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("zadd", "zmpop_b", 1, "a", 2, "b")
+
+	if ay, err := goredis.Values(c.Do("zmpop", 2, "zmpop_a", "zmpop_b", "max", "count", 5)); err != nil {
+		t.Fatal(err)
+	} else if key, _ := goredis.String(ay[0], nil); key != "zmpop_b" {
+		t.Fatal(key)
+	} else if members, _ := goredis.Values(ay[1], nil); len(members) != 2 {
+		t.Fatal(members)
+	} else if v, _ := goredis.Strings(members[0], nil); !reflect.DeepEqual(v, []string{"b", "2"}) {
+		t.Fatal(v)
+	}
+
+	if _, err := goredis.Values(c.Do("bzmpop", 0.01, 1, "zmpop_b", "min")); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("zmpop", 1, "zmpop_b", "mid"); err == nil {
+		t.Fatal("must error")
+	}
+}
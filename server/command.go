@@ -7,12 +7,80 @@ import (
 
 type CommandFunc func(c *client) error
 
-var regCmds = map[string]CommandFunc{}
+type commandFlag int
+
+const (
+	// flagRead changes neither the data nor the server state, it is audited
+	// only with audit_reads. An unknown command is audited as a write.
+	flagRead commandFlag = 1 << iota
+	// flagWrite writes the keyspace and is denied on a readonly server, a
+	// script is only checked by the commands it runs.
+	flagWrite
+	// flagAdmin manages the server, not the data.
+	flagAdmin
+	flagPubSub
+	// flagBlocking may block the connection, XREAD only with BLOCK.
+	flagBlocking
+	// flagNoMulti can not be queued in MULTI, EXEC holds the write lock
+	// which it waits for, directly or through another client, or it blocks
+	// the other writes for long. The replies of SUBSCRIBE and the others
+	// are not in the EXEC array.
+	flagNoMulti
+	// flagTx is run at once in MULTI, not queued.
+	flagTx
+	// flagUntimed is not limited by command_timeout, it blocks by design or
+	// has a limit of its own.
+	flagUntimed
+	// flagSubscribed is allowed in the pub/sub mode, QUIT too.
+	flagSubscribed
+)
+
+// keySpec is the positions of the first and the last key of a command and
+// the step between the keys like redis COMMAND, the command name is at 0
+// and a negative last is counted from the end. movable finds the keys by
+// the arguments like the number of the keys, after the fixed keys in the
+// positions.
+type keySpec struct {
+	first, last, step int
+	movable           func(args [][]byte) [][]byte
+}
+
+func keyRange(first int, last int, step int) keySpec {
+	return keySpec{first: first, last: last, step: step}
+}
+
+type command struct {
+	f     CommandFunc
+	flags commandFlag
+	keys  keySpec
+	// writes reports whether a call of a flagWrite command writes, for the
+	// commands which only write with some arguments.
+	writes func(args [][]byte) bool
+}
 
 func register(name string, f CommandFunc) {
-	if _, ok := regCmds[strings.ToLower(name)]; ok {
+	cmd, ok := commands[strings.ToLower(name)]
+	if !ok {
+		panic(fmt.Sprintf("%s is not in the command table", name))
+	} else if cmd.f != nil {
 		panic(fmt.Sprintf("%s has been registered", name))
 	}
 
-	regCmds[name] = f
+	cmd.f = f
+}
+
+// commandHas reports whether cmd is known and has the flag.
+func commandHas(cmd string, flag commandFlag) bool {
+	c, ok := commands[cmd]
+	return ok && c.flags&flag != 0
+}
+
+// commandDoc is the documentation of a command in doc/commands.json and
+// doc/commands.md, command_docs.go is generated from them by
+// tools/generate_commands.py.
+type commandDoc struct {
+	arity     int
+	group     string
+	arguments string
+	summary   string
 }
@@ -0,0 +1,296 @@
+//This file was generated by .tools/generate_commands.py on Wed Oct 14 2026 16:53:21 +0000
+
+package server
+
+var commandDocs = map[string]commandDoc{
+	"acladd":                           {-3, "ACL", "username password [COMMANDS pattern [pattern ...]] [KEYS pattern [pattern ...]]", "Creates the user, or replaces the password and the patterns of the existing one. The patterns are glob style, like `h*` for all the hash commands or `user:*` for the keys. A user without any command pattern can run no command. The users are written to the replication log like the other writes, so ACLADD fails on a read only slave, which gets the users of its master."},
+	"acldel":                           {-2, "ACL", "username [username ...]", "Deletes the users, the connections logged in as them can run no command after."},
+	"aclgetuser":                       {2, "ACL", "username", "Returns the patterns of the user."},
+	"acllist":                          {1, "ACL", "-", "Returns the names of all the users in order."},
+	"append":                           {3, "KV", "key value", "Appends value at the end of the string stored at key, a missing key is created as an empty string first."},
+	"auth":                             {-2, "Server", "[username] password", "Authenticates the connection with the password of `auth_password`, or as the ACL user username with its password. AUTH can be used before the other commands when `auth_password` is set."},
+	"bitcount":                         {-2, "KV", "key [start] [end]", "Counts the bits set to 1 in the string stored at key, only in the bytes from start to end if given. Negative offsets count from the end of the string."},
+	"bitfield":                         {-2, "KV", "key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL]", "Runs the GET, SET and INCRBY operations on the integers of the given width at the bit offset of the string, in one batch. The type is `i1` to `i64` for signed and `u1` to `u63` for unsigned integers, an offset like `#2` is multiplied by the width. OVERFLOW sets the WRAP (default), SAT or FAIL policy for the following operations. The bits are shared with SETBIT and GETBIT."},
+	"bitop":                            {-4, "KV", "operation destkey key [key ...]", "Runs the bitwise operation AND, OR, XOR or NOT on the strings of the keys and stores the result in destkey. NOT takes one key only. The shorter strings are padded with zero bytes."},
+	"bitpos":                           {-3, "KV", "key bit [start] [end]", "Returns the position of the first bit set to bit, 0 or 1, in the string stored at key, only in the bytes from start to end if given."},
+	"blmove":                           {6, "List", "source destination LEFT|RIGHT LEFT|RIGHT timeout", "BLMOVE is the blocking variant of LMOVE. When source is empty, it blocks the connection until another client pushes to it or the timeout in seconds is reached, 0 means blocking forever."},
+	"blmpop":                           {-5, "List", "timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]", "BLMPOP is the blocking variant of LMPOP. When all the lists are empty, it blocks the connection until another client pushes to one of them or the timeout in seconds is reached, 0 means blocking forever."},
+	"blpop":                            {-3, "List", "key [key ...] timeout", "BLPOP is a blocking list pop primitive. It is the blocking version of LPOP because it blocks the connection when there are no elements to pop from any of the given lists. An element is popped from the head of the first list that is non-empty, with the given keys being checked in the order that they are given."},
+	"brpop":                            {-3, "List", "key [key ...] timeout", "See BLPOP key [key ...] timeout for more information."},
+	"brpoplpush":                       {4, "List", "source destination timeout", "BRPOPLPUSH is the blocking variant of RPOPLPUSH. When source contains elements, this command behaves exactly like RPOPLPUSH. Redis will block the connection until another client pushes to it or until timeout is reached. A timeout of zero can be used to block indefinitely."},
+	"bzmpop":                           {-5, "ZSet", "timeout numkeys key [key ...] MIN|MAX [COUNT count]", "The blocking version of ZMPOP, it blocks until a member is added to one of the sorted sets or timeout (in seconds) is reached, 0 means blocking forever."},
+	"bzpopmax":                         {-3, "ZSet", "key [key ...] timeout", "The blocking version of ZPOPMAX, see BZPOPMIN for details."},
+	"bzpopmin":                         {-3, "ZSet", "key [key ...] timeout", "The blocking version of ZPOPMIN, it pops the member with the lowest score from the first non empty sorted set in the given keys. If all the sorted sets are empty, it blocks until a member is added or timeout (in seconds) is reached, 0 means blocking forever."},
+	"cad":                              {3, "KV", "key expected", "Atomically deletes key only if its current value is exactly expected, like releasing a lock only by its owner. It is not a redis command."},
+	"cas":                              {4, "KV", "key expected value", "Atomically sets key to value only if its current value is exactly expected, which makes a lock or a conditional update safe with concurrent clients. A missing key never matches. The timeout of key is kept. Nothing is written or replicated when the value does not match. It is not a redis command."},
+	"client":                           {-2, "Server", "subcommand [argument ...]", "A container for the CLIENT subcommands."},
+	"client getname":                   {2, "Server", "-", "Returns the name of the connection set by CLIENT SETNAME."},
+	"client kill":                      {4, "Server", "ID id", "Closes the connection of the id, after the reply if it is the connection itself."},
+	"client list":                      {2, "Server", "-", "Returns a line for each connection like redis, with the fields:"},
+	"client no-evict":                  {3, "Server", "ON|OFF", "Marks the connection so the keys of its commands are not evicted over `maxmemory` during the command and for a second after, like redis. It shows as the `e` flag in CLIENT LIST."},
+	"client no-touch":                  {3, "Server", "ON|OFF", "The commands of the connection do not change the idle time (OBJECT IDLETIME) and the access frequency (OBJECT FREQ) of the keys they read, so a monitoring connection does not make the keys look used. RESET turns it off."},
+	"client setname":                   {3, "Server", "name", "Sets the name of the connection, which CLIENT LIST shows. The name cannot have spaces, newlines or other special characters, an empty name removes it. HELLO SETNAME sets it too."},
+	"command":                          {-1, "Server", "[subcommand [argument ...]]", "Returns the COMMAND INFO reply of all the commands in the name order."},
+	"command count":                    {2, "Server", "-", "Returns the number of the commands."},
+	"command docs":                     {-2, "Server", "[command ...]", "Returns the name and the doc of the commands, all of them if no command is given. The doc has the summary, the group and the syntax, and the docs of the sub-commands like CONFIG GET. An unknown command is left out."},
+	"command getkeys":                  {-3, "Server", "command [arg ...]", "Returns the keys in the arguments of the command, like the ACL key patterns check them."},
+	"command info":                     {-2, "Server", "[command ...]", "Returns the info of the commands like redis, all of them if no command is given. The info of a command is its name, the arity, the flags, the position of the first key, the position of the last key and the step between the keys. A negative arity means at least that many arguments with the name, a negative last key is counted from the end. The flags are `readonly`, `write`, `admin`, `pubsub`, `blocking` and `movablekeys`, the last one if the keys are found by the arguments like `numkeys`, then only the fixed keys are in the positions. It is nil for an unknown command."},
+	"config":                           {-2, "Server", "subcommand [argument ...]", "A container for the CONFIG subcommands."},
+	"config get":                       {3, "Server", "pattern", "Returns the config parameters matching the glob style pattern. A parameter is named by its key in the config file, prefixed by its table like `replication.sync`. The redis style names with `-` are accepted too, like `slowlog-log-slower-than`."},
+	"config rewrite":                   {2, "Server", "-", "Rewrites the config file the server was started with."},
+	"config set":                       {4, "Server", "parameter value", "Sets a config parameter at runtime, it is used at once. If the server is started with a config file, the file is rewritten like CONFIG REWRITE."},
+	"copy":                             {-3, "Server", "source destination [DB destination-db] [REPLACE]", "Copy all the data types of source to destination, source is kept. The expire time of source is copied too."},
+	"dbsize":                           {1, "Server", "-", "Returns the number of the keys in the currently selected DB. Every data type has its own keyspace, so a key used by two data types counts twice. Like Redis, the expired keys not deleted yet are counted too."},
+	"debug":                            {-2, "Server", "subcommand [argument ...]", "A container for the DEBUG subcommands."},
+	"debug jmap":                       {2, "Server", "-", "Does nothing, for the redis compatibility."},
+	"debug object":                     {3, "Server", "key", "Describes key in the format of redis. `encoding` is the one of OBJECT ENCODING, `serializedlength` is the size of the value of DUMP compressed by zlib, to estimate the size in a snapshot, `lru` is the time of the last access in seconds on a 24-bit clock, `lru_seconds_idle` the seconds since, and `type` the one of TYPE. The values have no address, `Value at` is a checksum of the key. If a key has more than one data type, the first one is described like OBJECT ENCODING."},
+	"debug quicklist-packed-threshold": {3, "Server", "bytes", "Sets `ziplist_max_value_size` until the restart, which OBJECT ENCODING uses for all the data types, not only lists, and the hashes written later use to choose their encoding with `hash_ziplist`."},
+	"debug reload":                     {2, "Server", "-", "Closes the store and opens it again with the same config, to test that the data survives without a restart of the server. The writes wait until it is done. It fails if the iterators of the reads are still open after 10 seconds, and for the memory store."},
+	"debug sleep":                      {3, "Server", "seconds", "Blocks the connection for seconds, a float of at most 30, the other connections are not blocked."},
+	"decr":                             {2, "KV", "key", "Decrements the number stored at key by one. If the key does not exist, it is set to 0 before decrementing. An error returns if the value for the key is a wrong type that can not be represented as a `signed 64 bit integer`."},
+	"decrby":                           {3, "KV", "key decrement", "Decrements the number stored at key by decrement. like `DECR`."},
+	"del":                              {-2, "KV", "key [key ...]", "Removes the specified keys."},
+	"discard":                          {1, "Transaction", "-", "Drops all the queued commands and ends the transaction. The watched keys are unwatched."},
+	"dump":                             {2, "KV", "key", "Serialize the value stored at key with KV type in a Redis-specific format like RDB and return it to the user. The returned value can be synthesized back into a key using the RESTORE command."},
+	"echo":                             {2, "Server", "message", "Returns message."},
+	"eval":                             {-5, "Script", "script numkeys key [key ...] arg [arg ...]", "Runs the Lua script with the numkeys keys in `KEYS` and the other arguments in `ARGV`. The script calls the commands with `ledis.call` or `redis.call`."},
+	"evalsha":                          {-5, "Script", "sha1 numkeys key [key ...] arg [arg ...]", "Runs the script cached by SCRIPT LOAD or EVAL with the SHA1 digest sha1, like EVAL."},
+	"exec":                             {1, "Transaction", "-", "Runs all the queued commands and ends the transaction."},
+	"exists":                           {2, "KV", "key", "Returns if key exists"},
+	"expire":                           {-3, "KV", "key seconds [NX|XX|GT|LT] [JITTER fraction]", "Set a timeout on key. After the timeout has expired, the key will be deleted."},
+	"expireat":                         {-3, "KV", "key timestamp [NX|XX|GT|LT]", "Set an expired unix timestamp on key."},
+	"expiretime":                       {2, "KV", "key", "Returns the unix time in seconds at which the key expires. Unlike TTL, it is the stored time, so it does not go down. If the key was not set a timeout, `-1` returns, and `-2` if the key does not exist."},
+	"flushall":                         {1, "Server", "-", "Delete all the keys of all the existing databases and replication logs, not just the currently selected one. This command never fails."},
+	"flushdb":                          {-1, "Server", "[ASYNC | SYNC]", "Delete all the keys of the currently selected DB. This command never fails."},
+	"fullsync":                         {-1, "Replication", "[NEW]", "Inner command, starts a fullsync from the master set by SLAVEOF."},
+	"geoadd":                           {-5, "Geo", "key longitude latitude member [longitude latitude member ...]", "Adds the locations to the zset at key, like Redis, the score is the 52 bits geohash of the location, so all the zset commands work for the key."},
+	"geodist":                          {-4, "Geo", "key member1 member2 [m|km|ft|mi]", "Returns the distance between two members, the unit is meter by default."},
+	"geopos":                           {-3, "Geo", "key member [member ...]", "Returns the longitude and latitude of the members."},
+	"georadius":                        {-6, "Geo", "key longitude latitude radius m|km|ft|mi [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count] [ASC|DESC]", "Returns the members within the radius of the center. With COUNT and no order, the nearest ones return."},
+	"get":                              {2, "KV", "key", "Get the value of key. If the key does not exists, it returns `nil` value."},
+	"getbit":                           {3, "KV", "key offset", "Returns the bit at offset in the string stored at key."},
+	"getdel":                           {2, "KV", "key", "Atomically gets the value of key and deletes the key."},
+	"getex":                            {-2, "KV", "key [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp|PERSIST]", "Atomically gets the value of key and sets its timeout with EX, PX, EXAT or PXAT, or removes its timeout with PERSIST. Without an option, it is like GET."},
+	"getrange":                         {4, "KV", "key start end", "Returns the substring of the string stored at key between the offsets start and end, both included. Negative offsets count from the end of the string."},
+	"getset":                           {3, "KV", "key value", "Atomically sets key to value and returns the old value stored at key."},
+	"hclear":                           {2, "Hash", "key", "Deletes the specified hash key"},
+	"hdel":                             {-3, "Hash", "key field [field ...]", "Removes the specified fiedls from the hash stored at key."},
+	"hdump":                            {2, "Hash", "key", "See DUMP for more information."},
+	"hello":                            {-1, "Server", "[protover [AUTH username password] [SETNAME clientname]]", "Switches the connection to the protocol version protover, 2 or 3, and replies the information of the server. Without protover the version is not changed. AUTH authenticates like `AUTH username password` before the switch, SETNAME names the connection. HELLO can be used without AUTH only with the AUTH option."},
+	"hexists":                          {3, "Hash", "key field", "Returns if field is an existing field in the hash stored at key."},
+	"hexpire":                          {-3, "Hash", "key seconds [NX|XX|GT|LT] [JITTER fraction]", "Sets a hash key's time to live in seconds, like expire similarly."},
+	"hexpireat":                        {-3, "Hash", "key timestamp [NX|XX|GT|LT]", "Sets the expiration for a hash key as a unix timestamp, like expireat similarly."},
+	"hget":                             {3, "Hash", "key field", "Returns the value associated with field in the hash stored at key."},
+	"hgetall":                          {2, "Hash", "key", "Returns all fields and values of the hash stored at key."},
+	"hincrby":                          {4, "Hash", "key field increment", "Increments the number stored at field in the hash stored at key by increment. If key does not exist, a new hash key is created. If field does not exists the value is set to 0 before incrementing."},
+	"hkeyexists":                       {2, "Hash", "key", "Check key exists for hash data, like EXISTS key"},
+	"hkeys":                            {2, "Hash", "key", "Return all fields in the hash stored at key."},
+	"hlen":                             {2, "Hash", "key", "Returns the number of fields contained in the hash stored at key"},
+	"hmclear":                          {-2, "Hash", "key [key ...]", "Deletes the specified hash keys."},
+	"hmget":                            {-3, "Hash", "key field [field ...]", "Returns the values associated with the specified fields in the hash stored at key. If field does not exist in the hash, a `nil` value is returned."},
+	"hmset":                            {-4, "Hash", "key field value [field value ...]", "Sets the specified fields to their respective values in the hash stored at key."},
+	"hpersist":                         {2, "Hash", "key", "Remove the expiration from a hash key, like persist similarly. Remove the existing timeout on key."},
+	"hpexpire":                         {-3, "Hash", "key milliseconds [NX|XX|GT|LT] [JITTER fraction]", "Sets a hash key's time to live in milliseconds, like HEXPIRE similarly."},
+	"hpexpireat":                       {-3, "Hash", "key milliseconds-timestamp [NX|XX|GT|LT]", "Sets the expiration for a hash key as a unix timestamp in milliseconds, like HEXPIREAT similarly."},
+	"hpttl":                            {2, "Hash", "key", "Returns the remaining time to live of a hash key that has a timeout in milliseconds. If the key was not set a timeout, `-1` returns."},
+	"hrandfield":                       {-2, "Hash", "key [count [WITHVALUES]]", "Returns random fields of the hash stored at key. The hash is read in one pass and only the chosen fields are kept in memory."},
+	"hscan":                            {-3, "Hash", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Same like XHSCAN, but made redis compatible. Meaning that the initial cursor has to be `\"0\"`, and the final cursor will be `\"0\"` as well."},
+	"hset":                             {4, "Hash", "key field value", "Sets field in the hash stored at key to value. If key does not exists, a new hash key is created."},
+	"httl":                             {2, "Hash", "key", "Returns the remaining time to live of a key that has a timeout. If the key was not set a timeout, `-1` returns."},
+	"hvals":                            {2, "Hash", "key", "Returns all values in the hash stored at key."},
+	"incr":                             {2, "KV", "key", "Increments the number stored at key by one. If the key does not exists, it is SET to `0` before incrementing."},
+	"incrby":                           {3, "KV", "key increment", "Increments the number stored at key by increment. If the key does not exists, it is SET to `0` before incrementing."},
+	"info":                             {-1, "Server", "[section]", "Return information and statistic about the server in a format that is simple to parse by computers and easy to read by humans."},
+	"lclear":                           {2, "List", "key", "Deletes the specified list key"},
+	"ldump":                            {2, "List", "key", "See DUMP for more information."},
+	"lexpire":                          {-3, "List", "key seconds [NX|XX|GT|LT] [JITTER fraction]", "Set a timeout on key. After the timeout has expired, the key will be deleted."},
+	"lexpireat":                        {-3, "List", "key timestamp [NX|XX|GT|LT]", "Set an expired unix timestamp on key."},
+	"lindex":                           {3, "List", "key index", "Returns the element at index index in the list stored at key. The index is zero-based, so 0 means the first element, 1 the second element and so on. Negative indices can be used to designate elements starting at the tail of the list. Here, `-1` means the last element, `-2` means the penultimate and so forth. When the value at key is not a list, an error is returned."},
+	"lkeyexists":                       {2, "List", "key", "Check key exists for list data, like EXISTS key"},
+	"llen":                             {2, "List", "key", "Returns the length of the list stored at key. If key does not exist, it is interpreted as an empty list and `0`is returned. An error is returned when the value stored at key is not a list."},
+	"lmclear":                          {-2, "List", "key [key ...]", "Delete multiple keys from list"},
+	"lmove":                            {5, "List", "source destination LEFT|RIGHT LEFT|RIGHT", "Atomically returns and removes the first (LEFT) or last (RIGHT) element of the list stored at source, and pushes the element at the first (LEFT) or last (RIGHT) element of the list stored at destination. RPOPLPUSH is LMOVE source destination RIGHT LEFT."},
+	"lmpop":                            {-4, "List", "numkeys key [key ...] LEFT|RIGHT [COUNT count]", "Pops at most count elements, 1 by default, from the LEFT or RIGHT end of the first non empty list in the given keys. The elements of one list are popped in one batch."},
+	"lpersist":                         {2, "List", "key", "Remove the existing timeout on key"},
+	"lpexpire":                         {-3, "List", "key milliseconds [NX|XX|GT|LT] [JITTER fraction]", "Sets a list key's time to live in milliseconds, like LEXPIRE similarly."},
+	"lpexpireat":                       {-3, "List", "key milliseconds-timestamp [NX|XX|GT|LT]", "Sets the expiration for a list key as a unix timestamp in milliseconds, like LEXPIREAT similarly."},
+	"lpop":                             {2, "List", "key", "Removes and returns the first element of the list stored at key."},
+	"lpos":                             {-3, "List", "key element [RANK rank] [COUNT num-matches] [MAXLEN len]", "Returns the zero-based index of the element in the list stored at key. RANK selects the rank-th match, a negative rank counts the matches from the tail. With COUNT the indexes of num-matches matches return, 0 means all of them. MAXLEN compares at most len elements, 0 means the whole list."},
+	"lpttl":                            {2, "List", "key", "Returns the remaining time to live of a list key that has a timeout in milliseconds. If the key was not set a timeout, `-1` returns."},
+	"lpush":                            {-3, "List", "key value [value ...]", "Insert all the specified values at the head of the list stored at key. If key does not exist, it is created as empty list before performing the push operations. When key holds a value that is not a list, an error is returned."},
+	"lrange":                           {4, "List", "key start stop", "Returns the specified elements of the list stored at key. The offsets start and stop are zero-based indexes, with 0 being the first element of the list (the head of the list), `1` being the next element and so on."},
+	"ltrim":                            {4, "List", "key start stop", "Trims the list stored at key to the elements from start to stop, both included, like LRANGE. The other elements are deleted, and the key is deleted if the list becomes empty."},
+	"ltrim_back":                       {3, "List", "key size", "Deletes the size elements at the tail of the list stored at key, or all if the list is shorter."},
+	"ltrim_front":                      {3, "List", "key size", "Deletes the size elements at the head of the list stored at key, or all if the list is shorter."},
+	"lttl":                             {2, "List", "key", "Returns the remaining time to live of a key that has a timeout. If the key was not set a timeout, `-1` returns."},
+	"mexpire":                          {-3, "KV", "seconds key [key ...]", "Set the same timeout on many keys atomically. Every data type of the key gets the timeout."},
+	"mget":                             {-2, "KV", "key [key ...]", "Returns the values of all specified keys. If the key does not exists, a `nil` will return."},
+	"move":                             {3, "Server", "key db", "Move all the data types of key to the database db with the expire time, in one write. Nothing is moved if key exists as any data type in db."},
+	"mset":                             {-3, "KV", "key value [key value ...]", "Sets the given keys to their respective values."},
+	"mttl":                             {-2, "KV", "key [key ...]", "Returns the remaining time to live in seconds of many keys. If the key has many data types, the shortest one returns."},
+	"multi":                            {1, "Transaction", "-", "Marks the start of a transaction block. The following commands are queued, each one replies `QUEUED`, and they run on EXEC."},
+	"object":                           {-2, "Server", "subcommand [argument ...]", "A container for the OBJECT subcommands."},
+	"object encoding":                  {3, "Server", "key", "Returns the name of the internal encoding redis would use for the value stored at key, so clients written for redis can make the same memory and speed decisions."},
+	"object freq":                      {3, "Server", "key", "Returns the logarithmic access frequency counter of key, like redis LFU. The counter begins at 5, grows slower the bigger it is, and decays by one every `lfu_decay_time` minutes the key is not read, 1 by default."},
+	"object help":                      {2, "Server", "-", "Returns the help lines of the OBJECT sub-commands."},
+	"object idletime":                  {3, "Server", "key", "Returns the seconds since key was last read."},
+	"persist":                          {2, "KV", "key", "Remove the existing timeout on key"},
+	"pexpire":                          {-3, "KV", "key milliseconds [NX|XX|GT|LT] [JITTER fraction]", "Sets a key's time to live in milliseconds, like EXPIRE similarly, JITTER is supported too."},
+	"pexpireat":                        {-3, "KV", "key milliseconds-timestamp [NX|XX|GT|LT]", "Sets the expiration for a key as a unix timestamp in milliseconds, like EXPIREAT similarly."},
+	"pexpiretime":                      {2, "KV", "key", "Like EXPIRETIME, but the unix time is in milliseconds."},
+	"pfadd":                            {-2, "HyperLogLog", "key [element ...]", "Adds the elements to the HyperLogLog stored at key, creating it if it does not exist. The HyperLogLog uses the same 16384 registers dense representation as Redis."},
+	"pfcount":                          {-2, "HyperLogLog", "key [key ...]", "Returns the estimated cardinality of the union of the HyperLogLogs, the standard error is 0.81%."},
+	"pfdel":                            {-2, "HyperLogLog", "key [key ...]", "Deletes the HyperLogLogs."},
+	"pfexpire":                         {-3, "HyperLogLog", "key seconds [NX|XX|GT|LT] [JITTER fraction]", "Set a timeout on the HyperLogLog, like EXPIRE."},
+	"pfexpireat":                       {-3, "HyperLogLog", "key timestamp [NX|XX|GT|LT]", "Set an expired unix timestamp on the HyperLogLog, like EXPIREAT."},
+	"pfkeyexists":                      {2, "HyperLogLog", "key", "Check the HyperLogLog exists or not."},
+	"pfmerge":                          {-2, "HyperLogLog", "destkey [sourcekey ...]", "Merges the source HyperLogLogs and the destination one into the destination one."},
+	"pfpersist":                        {2, "HyperLogLog", "key", "Remove the existing timeout on the HyperLogLog."},
+	"pfpexpire":                        {-3, "HyperLogLog", "key milliseconds [NX|XX|GT|LT] [JITTER fraction]", "Like PFEXPIRE, but the timeout is in milliseconds."},
+	"pfpexpireat":                      {-3, "HyperLogLog", "key milliseconds-timestamp [NX|XX|GT|LT]", "Like PFEXPIREAT, but the timestamp is in milliseconds."},
+	"pfpttl":                           {2, "HyperLogLog", "key", "Returns the remaining time to live of the HyperLogLog in milliseconds, `-1` if no timeout."},
+	"pfttl":                            {2, "HyperLogLog", "key", "Returns the remaining time to live of the HyperLogLog in seconds, `-1` if no timeout."},
+	"ping":                             {1, "Server", "-", "Returns PONG. This command is often used to test if a connection is still alive, or to measure latency."},
+	"pmexpire":                         {-3, "KV", "milliseconds key [key ...]", "Like MEXPIRE, but the timeout is in milliseconds."},
+	"psubscribe":                       {-2, "PubSub", "pattern [pattern ...]", "Subscribes the connection to the channels matching the glob style patterns."},
+	"psync":                            {3, "Replication", "replid logid", "Inner command, a slave sends it after connecting to the master set by SLAVEOF, with the replication id it saved and the next logid it needs. The replication id names the history of the replication logs: a master creates a random one once, a slave saves the one of its master after a full sync, and SLAVEOF NO ONE creates a new one. If the id matches and the master still has the logs from logid, the slave goes on with SYNC, otherwise it runs FULLSYNC first. The id is `replication_id` in INFO replication."},
+	"pttl":                             {2, "KV", "key", "Returns the remaining time to live of a key that has a timeout in milliseconds. If the key was not set a timeout, `-1` returns."},
+	"publish":                          {3, "PubSub", "channel message", "Publishes the message to the channel."},
+	"pubsub":                           {-2, "PubSub", "subcommand [argument ...]", "A container for the PUBSUB subcommands."},
+	"pubsub channels":                  {-2, "PubSub", "[pattern]", "Returns the channels with any subscriber, matching the pattern if given. The pattern subscriptions are not counted."},
+	"pubsub numpat":                    {2, "PubSub", "-", "Returns the number of the pattern subscriptions of all the connections."},
+	"pubsub numsub":                    {-2, "PubSub", "[channel ...]", "Returns the number of the subscribers of the channels, not counting the pattern subscriptions."},
+	"punsubscribe":                     {-1, "PubSub", "[pattern ...]", "Unsubscribes the connection from the patterns, or from all the patterns if none is given."},
+	"rename":                           {3, "Server", "key newkey", "Rename all the data types of key to newkey with the expire time, in one write. Every data type of newkey is removed first. It is an error if key does not exist."},
+	"renamenx":                         {3, "Server", "key newkey", "Like RENAME, but nothing is renamed if newkey exists as any data type."},
+	"replconf":                         {-1, "Replication", "[option value ...]", "Inner command, a slave sends it to the master with its `listening-port` before SYNC, so the master lists it with the address in INFO replication and ROLE."},
+	"reset":                            {1, "Server", "-", "Resets the connection to the state of a new one: aborts MULTI, unwatches the keys, selects the database 0, switches back to RESP2 and logs out if `auth_password` is set. It can be used without AUTH."},
+	"restore":                          {-4, "Server", "key ttl value [REPLACE]", "Create a key associated with a value that is obtained by deserializing the provided serialized value (obtained via DUMP, LDUMP, HDUMP, SDUMP, ZDUMP)."},
+	"role":                             {1, "Server", "-", "Provide information on the role of an intance in the context of replication."},
+	"rpop":                             {2, "List", "key", "Removes and returns the last element of the list stored at key."},
+	"rpoplpush":                        {3, "List", "source destination", "Atomically returns and removes the last element (tail) of the list stored at source, and pushes the element at the first element (head) of the list stored at destination."},
+	"rpush":                            {-3, "List", "key value [value ...]", "Insert all the specified values at the tail of the list stored at key. If key does not exist, it is created as empty list before performing the push operation. When key holds a value that is not a list, an error is returned."},
+	"sadd":                             {-3, "Set", "key member [member ...]", "Add the specified members to the set stored at key. Specified members that are already a member of this set are ignored. If key does not exist, a new set is created before adding the specified members."},
+	"scan":                             {-2, "Server", "cursor [MATCH pattern] [COUNT count] [TYPE type]", "Iterate the keys of all the data types incrementally like redis SCAN, start with cursor \"0\"."},
+	"scard":                            {2, "Set", "key", "Returns the set cardinality (number of elements) of the set stored at key."},
+	"sclear":                           {2, "Set", "key", "Deletes the specified set key"},
+	"script":                           {-2, "Script", "subcommand [argument ...]", "A container for the SCRIPT subcommands."},
+	"script exists":                    {-3, "Script", "script [script ...]", "Reports whether the scripts of the SHA1 digests are cached."},
+	"script flush":                     {2, "Script", "-", "Removes all the cached scripts."},
+	"script kill":                      {2, "Script", "-", "Stops the running script. A script running for less than `lua_time_limit` milliseconds (5000 by default) is stopped only when the limit is reached, SCRIPT KILL waits until then. The killed script returns an error to its caller, the writes it has done before are kept."},
+	"script load":                      {3, "Script", "script", "Caches the script without running it, EVALSHA runs it by the SHA1 digest after."},
+	"sdiff":                            {-2, "Set", "key [key ...]", "Returns the members of the set resulting from the difference between the first set and all the successive sets. For example:"},
+	"sdiffstore":                       {-3, "Set", "destination key [key ...]", "This command is equal to `SDIFF`, but instead of returning the resulting set, it is stored in destination. If destination already exists, it is overwritten."},
+	"sdump":                            {2, "Set", "key", "See DUMP for more information."},
+	"select":                           {2, "Server", "index", "Select the DB with having the specified zero-based numeric index. New connections always use DB `0`. Currently, We support `16` DBs(`0-15`)."},
+	"set":                              {3, "KV", "key value", "Set key to the value."},
+	"setbit":                           {4, "KV", "key offset value", "Sets or clears the bit at offset in the string stored at key, value is 0 or 1. The string grows with zero bytes to hold the bit."},
+	"setex":                            {4, "KV", "key seconds value", "Set key to hold the string value and set key to timeout after a given number of seconds. This command is equivalent to executing the following commands:"},
+	"setnx":                            {3, "KV", "key value", "Set key to the value if key does not exist. If key already holds a value, no operation is performed."},
+	"setrange":                         {4, "KV", "key offset value", "Overwrites the string stored at key from offset with value. The string is padded with zero bytes if it is shorter than offset, a missing key is created."},
+	"sexpire":                          {-3, "Set", "key seconds [NX|XX|GT|LT] [JITTER fraction]", "Sets a set key\u2019s time to live in seconds, like expire similarly."},
+	"sexpireat":                        {-3, "Set", "key timestamp [NX|XX|GT|LT]", "Sets the expiration for a set key as a unix timestamp, like expireat similarly."},
+	"sinter":                           {-2, "Set", "key [key ...]", "Returns the members of the set resulting from the intersection of all the given sets. For example:"},
+	"sintercard":                       {-3, "Set", "numkeys key [key ...] [LIMIT limit]", "Returns the number of members in the intersection of all the given sets, without building the resulting set. The smallest set is used to probe the others."},
+	"sinterstore":                      {-3, "Set", "destination key [key ...]", "This command is equal to `SINTER`, but instead of returning the resulting set, it is stored in destination. If destination already exists, it is overwritten."},
+	"sismember":                        {3, "Set", "key member", "Returns if member is a member of the set stored at key."},
+	"skeyexists":                       {2, "Set", "key", "Check key exists for set data, like EXISTS key"},
+	"slaveof":                          {-3, "Replication", "host port [RESTART] [READONLY]", "Changes the replication settings of a slave on the fly. If the server is already acting as slave, `SLAVEOF NO ONE` will turn off the replication and turn the server into master. `SLAVEOF NO ONE READONLY` will turn the server into master with readonly mode. The logs received from the old master but not applied yet are discarded, or with `replication.safe_promotion` in the config, `SLAVEOF NO ONE` fails while there are any, the server stays a readonly slave without replication, and it can be sent again later."},
+	"slowlog":                          {-2, "Server", "subcommand [argument ...]", "A container for the SLOWLOG subcommands."},
+	"slowlog get":                      {-2, "Server", "[count]", "Returns the newest count entries of the slow log, 10 by default, all if count is negative. A command running at least `slowlog_log_slower_than` microseconds (10000 by default) is kept in the slow log, 0 keeps every command and a negative value none. The log keeps at most `slowlog_max_len` entries (128 by default), the oldest are dropped."},
+	"slowlog len":                      {2, "Server", "-", "Returns the number of the entries in the slow log."},
+	"slowlog reset":                    {2, "Server", "-", "Drops all the entries of the slow log."},
+	"smclear":                          {-2, "Set", "key [key ...]", "Deletes the specified set keys."},
+	"smembers":                         {2, "Set", "key", "Returns all the members of the set value stored at key. This has the same effect as running `SINTER` with one argument key."},
+	"spersist":                         {2, "Set", "key", "Remove the expiration from a set key, like persist similarly. Remove the existing timeout on key."},
+	"spexpire":                         {-3, "Set", "key milliseconds [NX|XX|GT|LT] [JITTER fraction]", "Sets a set key's time to live in milliseconds, like SEXPIRE similarly."},
+	"spexpireat":                       {-3, "Set", "key milliseconds-timestamp [NX|XX|GT|LT]", "Sets the expiration for a set key as a unix timestamp in milliseconds, like SEXPIREAT similarly."},
+	"spttl":                            {2, "Set", "key", "Returns the remaining time to live of a set key that has a timeout in milliseconds. If the key was not set a timeout, `-1` returns."},
+	"srem":                             {-3, "Set", "key member [member ...]", "Remove the specified members from the set stored at key. Specified members that are not a member of this set are ignored. If key does not exist, it is treated as an empty set and this command returns 0."},
+	"sscan":                            {-3, "Set", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Same like XSSCAN, but made redis compatible. Meaning that the initial cursor has to be `\"0\"`, and the final cursor will be `\"0\"` as well."},
+	"strlen":                           {2, "KV", "key", "Returns the length of the string stored at key."},
+	"sttl":                             {2, "Set", "key", "Returns the remaining time to live of a key that has a timeout. If the key was not set a timeout, -1 returns."},
+	"subscribe":                        {-2, "PubSub", "channel [channel ...]", "Subscribes the connection to the channels."},
+	"sunion":                           {-2, "Set", "key [key ...]", "Returns the members of the set resulting from the union of all the given sets. For example:"},
+	"sunionstore":                      {-3, "Set", "destination key [key ...]", "This command is equal to SUNION, but instead of returning the resulting set, it is stored in destination. If destination already exists, it is overwritten."},
+	"sync":                             {2, "Replication", "logid", "Inner command, syncs the new changed from master set by SLAVEOF with logid."},
+	"time":                             {1, "Server", "-", "The TIME command returns the current server time as a two items lists: a Unix timestamp and the amount of microseconds already elapsed in the current second"},
+	"ttl":                              {2, "KV", "key", "Returns the remaining time to live of a key that has a timeout. If the key was not set a timeout, -1 returns."},
+	"type":                             {2, "Server", "key", "Returns the redis type name of key: \"string\", \"list\", \"set\", \"zset\", \"hash\" or \"stream\", and \"none\" if key does not exist. A HyperLogLog is a \"string\" and a geo key a \"zset\" like in redis. If key holds more than one data type, the first one in the OBJECT ENCODING order is returned."},
+	"unsubscribe":                      {-1, "PubSub", "[channel ...]", "Unsubscribes the connection from the channels, or from all the channels if none is given."},
+	"unwatch":                          {1, "Transaction", "-", "Unwatches all the keys watched by the connection."},
+	"wait":                             {3, "Replication", "numreplicas timeout", "Blocks until at least numreplicas slaves have all the replication logs written before WAIT, or timeout milliseconds pass. A timeout 0 blocks forever. It returns at once if no slave is connected. WAIT is not allowed in MULTI."},
+	"watch":                            {-2, "Transaction", "key [key ...]", "Watches the keys for the next EXEC. If any of them is written by another command before EXEC, including an expiry, a delete or FLUSHALL, the transaction is aborted and EXEC returns a null array. The keys are unwatched after EXEC or DISCARD."},
+	"xack":                             {-4, "Stream", "key group ID [ID ...]", "Acknowledges the entries of the group, they are removed from the pending entries."},
+	"xadd":                             {-5, "Stream", "key ID field value [field value ...]", "Appends the entry to the stream stored at key, creating it if it does not exist. The ID is `<ms>-<seq>` and must be greater than the last ID of the stream. `*` generates the ID from the current time, `<ms>-*` or `<ms>` generates the sequence only."},
+	"xautoclaim":                       {-6, "Stream", "key group consumer min-idle-time start [COUNT count] [JUSTID]", "Transfers the pending entries of the group idle for min-idle-time milliseconds at least, from the ID start on, to the consumer, at most count ones, 100 by default. The delivery count of the claimed entries is incremented, unless JUSTID which only returns their IDs. The entries deleted from the stream are removed from the pending entries."},
+	"xclear":                           {2, "Stream", "key", "Deletes the stream."},
+	"xdump":                            {3, "Server", "type key", "Serializes the value of key of the type KV, HASH, LIST, SET or ZSET like DUMP, XRESTORE restores it. It is used by the migrations."},
+	"xexpire":                          {-3, "Stream", "key seconds [NX|XX|GT|LT] [JITTER fraction]", "Set a timeout on the stream, like EXPIRE."},
+	"xexpireat":                        {-3, "Stream", "key timestamp [NX|XX|GT|LT]", "Set an expired unix timestamp on the stream, like EXPIREAT."},
+	"xgroup":                           {-4, "Stream", "subcommand [argument ...]", "A container for the XGROUP subcommands."},
+	"xgroup create":                    {-5, "Stream", "key group ID [MKSTREAM]", "Creates the consumer group of the stream, which delivers the entries with ID greater than the given ID, `$` is the last ID of the stream. With MKSTREAM an empty stream is created if the key does not exist, otherwise it is an error. The groups are deleted with the stream, and kept by COPY, RENAME and MOVE, but not by DUMP, XDUMP and the migrations."},
+	"xgroup destroy":                   {4, "Stream", "key group", "Deletes the consumer group with its pending entries."},
+	"xhscan":                           {-3, "Hash", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Same like XSCAN, but return array of elements. contains two elements, a field and a value."},
+	"xkeyexists":                       {2, "Stream", "key", "Check the stream exists or not."},
+	"xlen":                             {2, "Stream", "key", "Returns the number of entries in the stream."},
+	"xlsort":                           {-2, "List", "key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]", "Returns or stores the elements contained in the list at key."},
+	"xmclear":                          {-2, "Stream", "key [key ...]", "Deletes the streams."},
+	"xmigrate":                         {7, "Server", "host port type key destination-db timeout", "Moves key of the type, or of all the types with ALL, to the database destination-db of the server at host and port with its TTL, then deletes it here. It waits at most timeout milliseconds for the other server. The other writes on the key wait for the migration."},
+	"xmigratedb":                       {7, "Server", "host port type count destination-db timeout", "Moves at most count keys of the type of the current database to the database destination-db of the server at host and port, like XMIGRATE for each key."},
+	"xpending":                         {-3, "Stream", "key group [[IDLE min-idle-time] start end count [consumer]]", "Without a range, returns the summary of the pending entries of the group: their number, the smallest and the greatest ID, and the number of the pending entries of every consumer. With a range, returns at most count pending entries with ID between start and end, `-` and `+` are the min and max ID, only the ones idle for min-idle-time milliseconds at least with IDLE, and only the ones of the consumer if given."},
+	"xpersist":                         {2, "Stream", "key", "Remove the existing timeout on the stream."},
+	"xpexpire":                         {-3, "Stream", "key milliseconds [NX|XX|GT|LT] [JITTER fraction]", "Like XEXPIRE, but the timeout is in milliseconds."},
+	"xpexpireat":                       {-3, "Stream", "key milliseconds-timestamp [NX|XX|GT|LT]", "Like XEXPIREAT, but the timestamp is in milliseconds."},
+	"xpttl":                            {2, "Stream", "key", "Returns the remaining time to live of the stream in milliseconds, `-1` if no timeout."},
+	"xrange":                           {-4, "Stream", "key start end [COUNT count]", "Returns the entries with ID between start and end inclusively. `-` and `+` are the smallest and the greatest ID, a start `<ms>` is `<ms>-0` and an end `<ms>` covers all the sequences of the millisecond."},
+	"xread":                            {-4, "Stream", "[COUNT count] [BLOCK milliseconds] STREAMS key [key ...] ID [ID ...]", "Returns at most count entries with ID greater than the given ID for every stream. `$` is the last ID of the stream when the command starts, so only the new entries return. With BLOCK, waits until any stream has new entries or the timeout, 0 means waiting forever."},
+	"xreadgroup":                       {-7, "Stream", "GROUP group consumer [COUNT count] [NOACK] STREAMS key [key ...] ID [ID ...]", "Like XREAD, but reads as the consumer of the group. For the ID `>`, returns at most count entries never delivered to the group, and adds them to the pending entries of the consumer until XACK, unless NOACK. For another ID, returns the pending entries of the consumer with greater ID again, an entry deleted from the stream has nil fields. BLOCK is not supported."},
+	"xrestore":                         {5, "Server", "type key ttl value", "Restores the value serialized by XDUMP to key of the type, replacing the value of key. A ttl 0 keeps no TTL, otherwise it is the TTL in milliseconds like RESTORE."},
+	"xrevrange":                        {-4, "Stream", "key end start [COUNT count]", "Like XRANGE, but returns the entries in reverse order."},
+	"xscan":                            {-3, "Server", "type cursor [MATCH match] [COUNT count] [ASC|DESC]", "Iterate data type keys incrementally."},
+	"xsscan":                           {-3, "Set", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Same like XSCAN."},
+	"xssort":                           {-2, "Set", "key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]", "Returns or stores the elements contained in the set at key."},
+	"xttl":                             {2, "Stream", "key", "Returns the remaining time to live of the stream in seconds, `-1` if no timeout."},
+	"xzscan":                           {-3, "ZSet", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Same like XSCAN, but return array of elements. contains two elements, a member and its associated score."},
+	"xzsort":                           {-2, "ZSet", "key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]", "Returns or stores the elements contained in the zset at key."},
+	"zadd":                             {-4, "ZSet", "key score member [score member ...]", "Adds all the specified members with the specified scores to the sorted set stored at key. It is possible to specify multiple `score / member` pairs. If a specified member is already a member of the sorted set, the score is updated and the element reinserted at the right position to ensure the correct ordering."},
+	"zcard":                            {2, "ZSet", "key", "Returns the sorted set cardinality (number of elements) of the sorted set stored at key."},
+	"zclear":                           {2, "ZSet", "key", "Delete the specified  key"},
+	"zcount":                           {4, "ZSet", "key min max", "Returns the number of elements in the sorted set at key with a score between `min` and `max`. The `min` and `max` arguments have the same semantic as described for `ZRANGEBYSCORE`."},
+	"zdiff":                            {-3, "ZSet", "numkeys key [key ...] [WITHSCORES]", "Returns the members of the first sorted set which are not in any of the other sorted sets, ordered by score. The scores come from the first sorted set."},
+	"zdiffstore":                       {-4, "ZSet", "destkey numkeys key [key ...]", "This command is equal to `ZDIFF`, but instead of returning the resulting sorted set, it is stored in destination."},
+	"zdump":                            {2, "ZSet", "key", "See DUMP for more information."},
+	"zexpire":                          {-3, "ZSet", "key seconds [NX|XX|GT|LT] [JITTER fraction]", "Set a timeout on key. After the timeout has expired, the key will be deleted."},
+	"zexpireat":                        {-3, "ZSet", "key timestamp [NX|XX|GT|LT]", "Set an expired unix timestamp on key. Similar to ZEXPIRE."},
+	"zincrby":                          {4, "ZSet", "key increment member", "Increments the score of member in the sorted set stored at key by increment. If member does not exist in the sorted set, it is added with increment as its score (as if its previous score was 0). If key does not exist, a new sorted set with the specified member as its sole member is created. An error is returned when key exists but does not hold a sorted set. The score value should be the string representation of a numeric value. It is possible to provide a negative value to decrement the score."},
+	"zinterstore":                      {-4, "ZSet", "destkey numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]", "Computes the intersection of numkeys sorted sets given by the specified keys, and stores the result in destination. It is mandatory to provide the number of input keys (numkeys) before passing the input keys and the other (optional) arguments."},
+	"zkeyexists":                       {2, "ZSet", "key", "Check key exists for zset data, like EXISTS key"},
+	"zlexcount":                        {4, "ZSet", "key min max", "Returns the number of elements in the sorted set at key with a value between min and max."},
+	"zmclear":                          {-2, "ZSet", "key [key ...]", "Delte multiple keys one time."},
+	"zmpop":                            {-4, "ZSet", "numkeys key [key ...] MIN|MAX [COUNT count]", "Pops at most count members, 1 by default, with the lowest (MIN) or highest (MAX) scores from the first non empty sorted set in the given keys. The members of one sorted set are popped in one batch."},
+	"zpersist":                         {2, "ZSet", "key", "Remove the existing timeout on key."},
+	"zpexpire":                         {-3, "ZSet", "key milliseconds [NX|XX|GT|LT] [JITTER fraction]", "Sets a zset key's time to live in milliseconds, like ZEXPIRE similarly."},
+	"zpexpireat":                       {-3, "ZSet", "key milliseconds-timestamp [NX|XX|GT|LT]", "Sets the expiration for a zset key as a unix timestamp in milliseconds, like ZEXPIREAT similarly."},
+	"zpopmax":                          {-2, "ZSet", "key [count]", "Removes and returns at most count members with the highest scores in the sorted set stored at key, count is 1 by default."},
+	"zpopmin":                          {-2, "ZSet", "key [count]", "Removes and returns at most count members with the lowest scores in the sorted set stored at key, count is 1 by default. All the members are removed in one batch."},
+	"zpttl":                            {2, "ZSet", "key", "Returns the remaining time to live of a zset key that has a timeout in milliseconds. If the key was not set a timeout, `-1` returns."},
+	"zrandmember":                      {-2, "ZSet", "key [count [WITHSCORES]]", "Returns random members of the sorted set stored at key, see HRANDFIELD for the meaning of count. With WITHSCORES, the score of every member is returned too."},
+	"zrange":                           {-4, "ZSet", "key start stop [BYLEX [REV] [LIMIT offset count]] [WITHSCORES]", "Returns the specified range of elements in the sorted set stored at key. The elements are considered to be ordered from the lowest to the highest score. Lexicographical order is used for elements with equal score."},
+	"zrangebylex":                      {-4, "ZSet", "key min max [LIMIT offset count]", "When all the elements in a sorted set are inserted with the same score, in order to force lexicographical ordering, this command returns all the elements in the sorted set at key with a value between min and max."},
+	"zrangebylexstore":                 {-5, "ZSet", "destination key min max [LIMIT offset count]", "This command is equal to `ZRANGEBYLEX`, but instead of returning the elements, they are stored with their scores in destination."},
+	"zrangebyscore":                    {-4, "ZSet", "key min max [WITHSCORES] [LIMIT offset count]", "Returns all the elements in the sorted set at key with a score between `min` and `max` (including elements with score equal to `min` or `max`). The elements are considered to be ordered from low to high scores."},
+	"zrank":                            {3, "ZSet", "key member", "Returns the rank of member in the sorted set stored at key, with the scores ordered from low to high. The rank (or index) is `0-based`, which means that the member with the lowest score has rank 0."},
+	"zrem":                             {-3, "ZSet", "key member [member ...]", "Removes the specified members from the sorted set stored at key. Non existing members are ignored. An error is returned when key exists and does not hold a sorted set."},
+	"zremrangebylex":                   {4, "ZSet", "key min max", "Removes all elements in the sorted set stored at key between the lexicographical range specified by min and max."},
+	"zremrangebyrank":                  {4, "ZSet", "key start stop", "Removes all elements in the sorted set stored at key with rank between start and stop. Both start and stop are 0 -based indexes with 0 being the element with the lowest score. These indexes can be negative numbers, where they indicate offsets starting at the element with the highest score. For example: -1 is the element with the highest score, -2 the element with the second highest score and so forth."},
+	"zremrangebyscore":                 {4, "ZSet", "key min max", "Removes all elements in the sorted set stored at key with a score between `min` and `max` (inclusive). `Min` and `max` can be exclusive, following the syntax of `ZRANGEBYSCORE`."},
+	"zrevrange":                        {-4, "ZSet", "key start stop [WITHSCORES]", "Returns the specified range of elements in the sorted set stored at key. The elements are considered to be ordered from the highest to the lowest score. Descending lexicographical order is used for elements with equal score. Apart from the reversed ordering, ZREVRANGE is similar to `ZRANGE`."},
+	"zrevrangebylex":                   {-4, "ZSet", "key max min [LIMIT offset count]", "This command is equal to `ZRANGEBYLEX`, but the elements are returned from the highest to the lowest, and max is given before min."},
+	"zrevrangebyscore":                 {-4, "ZSet", "key max min  [WITHSCORES][LIMIT offset count]", "Returns all the elements in the sorted set at key with a score between max and min (including elements with score equal to max or min). In contrary to the default ordering of sorted sets, for this command the elements are considered to be ordered from high to low scores. The elements having the same score are returned in reverse lexicographical order. Apart from the reversed ordering, ZREVRANGEBYSCORE is similar to ZRANGEBYSCORE."},
+	"zrevrank":                         {3, "ZSet", "key member", "Returns the rank of member in the sorted set stored at key, with the scores ordered from high to low. The rank (or index) is 0-based, which means that the member with the highest score has rank 0. Use ZRANK to get the rank of an element with the scores ordered from low to high."},
+	"zscan":                            {-3, "ZSet", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Same like XZSCAN, but made redis compatible. Meaning that the initial cursor has to be `\"0\"`, and the final cursor will be `\"0\"` as well."},
+	"zscore":                           {3, "ZSet", "key member", "Returns the score of member in the sorted set at key. If member does not exist in the sorted set, or key does not exist, `nil` is returned."},
+	"zttl":                             {2, "ZSet", "key", "Returns the remaining time to live of a key that has a timeout. If the key was not set a timeout, `-1` returns."},
+	"zunionstore":                      {-4, "ZSet", "destkey numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]", "Computes the union of numkeys sorted sets given by the specified keys, and stores the result in destination. It is mandatory to provide the number of input keys (numkeys) before passing the input keys and the other (optional) arguments."},
+}
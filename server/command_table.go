@@ -0,0 +1,263 @@
+package server
+
+// commands is the registry of the commands, the flags and the key positions
+// of each, which the ACL, the audit log, MULTI, the readonly check and
+// COMMAND read. A command must be in it to be registered.
+var commands = map[string]*command{
+	"acladd":           {flags: flagWrite | flagAdmin | flagNoMulti},
+	"acldel":           {flags: flagWrite | flagAdmin | flagNoMulti},
+	"aclgetuser":       {flags: flagRead | flagAdmin},
+	"acllist":          {flags: flagRead | flagAdmin},
+	"append":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"auth":             {},
+	"bitcount":         {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"bitfield":         {flags: flagWrite, keys: keyRange(1, 1, 1), writes: bitfieldWrites},
+	"bitop":            {flags: flagWrite, keys: keyRange(2, -1, 1)},
+	"bitpos":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"blmove":           {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keyRange(1, 2, 1)},
+	"blmpop":           {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keySpec{movable: numKeysAt(1)}},
+	"blpop":            {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keyRange(1, -2, 1)},
+	"brpop":            {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keyRange(1, -2, 1)},
+	"brpoplpush":       {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keyRange(1, 2, 1)},
+	"bzmpop":           {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keySpec{movable: numKeysAt(1)}},
+	"bzpopmax":         {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keyRange(1, -2, 1)},
+	"bzpopmin":         {flags: flagWrite | flagBlocking | flagNoMulti | flagUntimed, keys: keyRange(1, -2, 1)},
+	"cad":              {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"cas":              {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"client":           {},
+	"command":          {flags: flagRead},
+	"config":           {flags: flagAdmin},
+	"copy":             {flags: flagWrite, keys: keyRange(1, 2, 1)},
+	"dbsize":           {flags: flagRead},
+	"debug":            {flags: flagAdmin},
+	"decr":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"decrby":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"del":              {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"discard":          {flags: flagRead | flagTx},
+	"dump":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"echo":             {flags: flagRead},
+	"eval":             {flags: flagNoMulti | flagUntimed, keys: keySpec{movable: numKeysAt(1)}},
+	"evalsha":          {flags: flagNoMulti | flagUntimed, keys: keySpec{movable: numKeysAt(1)}},
+	"exec":             {flags: flagTx},
+	"exists":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"expire":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"expireat":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"expiretime":       {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"flushall":         {flags: flagWrite | flagNoMulti},
+	"flushdb":          {flags: flagWrite},
+	"fullsync":         {flags: flagAdmin | flagNoMulti | flagUntimed},
+	"geoadd":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"geodist":          {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"geopos":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"georadius":        {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"get":              {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"getbit":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"getdel":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"getex":            {flags: flagWrite, keys: keyRange(1, 1, 1), writes: getexWrites},
+	"getrange":         {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"getset":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hclear":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hdel":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hdump":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hello":            {},
+	"hexists":          {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hexpire":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hexpireat":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hget":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hgetall":          {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hincrby":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hkeyexists":       {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hkeys":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hlen":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hmclear":          {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"hmget":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hmset":            {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hpersist":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hpexpire":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hpexpireat":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"hpttl":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hrandfield":       {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hscan":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hset":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"httl":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"hvals":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"incr":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"incrby":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"info":             {flags: flagRead},
+	"lclear":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"ldump":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"lexpire":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lexpireat":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lindex":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"lkeyexists":       {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"llen":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"lmclear":          {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"lmove":            {flags: flagWrite, keys: keyRange(1, 2, 1)},
+	"lmpop":            {flags: flagWrite, keys: keySpec{movable: numKeysAt(0)}},
+	"lpersist":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lpexpire":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lpexpireat":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lpop":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lpos":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"lpttl":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"lpush":            {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lrange":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"ltrim":            {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"ltrim_back":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"ltrim_front":      {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"lttl":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"mexpire":          {flags: flagWrite, keys: keyRange(2, -1, 1)},
+	"mget":             {flags: flagRead, keys: keyRange(1, -1, 1)},
+	"move":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"mset":             {flags: flagWrite, keys: keyRange(1, -1, 2)},
+	"mttl":             {flags: flagRead, keys: keyRange(1, -1, 1)},
+	"multi":            {flags: flagRead | flagTx},
+	"object":           {flags: flagRead, keys: keyRange(2, 2, 1)},
+	"persist":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pexpire":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pexpireat":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pexpiretime":      {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"pfadd":            {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pfcount":          {flags: flagRead, keys: keyRange(1, -1, 1)},
+	"pfdel":            {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"pfexpire":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pfexpireat":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pfkeyexists":      {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"pfmerge":          {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"pfpersist":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pfpexpire":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pfpexpireat":      {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"pfpttl":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"pfttl":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"ping":             {flags: flagRead | flagSubscribed},
+	"pmexpire":         {flags: flagWrite, keys: keyRange(2, -1, 1)},
+	"psubscribe":       {flags: flagRead | flagPubSub | flagNoMulti | flagSubscribed},
+	"psync":            {flags: flagAdmin | flagNoMulti | flagUntimed},
+	"pttl":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"publish":          {flags: flagPubSub},
+	"pubsub":           {flags: flagRead | flagPubSub},
+	"punsubscribe":     {flags: flagRead | flagPubSub | flagNoMulti | flagSubscribed},
+	"rename":           {flags: flagWrite, keys: keyRange(1, 2, 1)},
+	"renamenx":         {flags: flagWrite, keys: keyRange(1, 2, 1)},
+	"replconf":         {flags: flagRead | flagAdmin},
+	"reset":            {flags: flagRead | flagTx | flagSubscribed},
+	"restore":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"role":             {flags: flagRead},
+	"rpop":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"rpoplpush":        {flags: flagWrite, keys: keyRange(1, 2, 1)},
+	"rpush":            {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"sadd":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"scan":             {flags: flagRead},
+	"scard":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"sclear":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"script":           {flags: flagNoMulti | flagUntimed},
+	"sdiff":            {flags: flagRead, keys: keyRange(1, -1, 1)},
+	"sdiffstore":       {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"sdump":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"select":           {flags: flagRead | flagNoMulti},
+	"set":              {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"setbit":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"setex":            {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"setnx":            {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"setrange":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"sexpire":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"sexpireat":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"sinter":           {flags: flagRead, keys: keyRange(1, -1, 1)},
+	"sintercard":       {flags: flagRead, keys: keySpec{movable: numKeysAt(0)}},
+	"sinterstore":      {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"sismember":        {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"skeyexists":       {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"slaveof":          {flags: flagAdmin | flagNoMulti | flagUntimed},
+	"slowlog":          {flags: flagRead | flagAdmin},
+	"smclear":          {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"smembers":         {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"spersist":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"spexpire":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"spexpireat":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"spttl":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"srem":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"sscan":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"strlen":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"sttl":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"subscribe":        {flags: flagRead | flagPubSub | flagNoMulti | flagSubscribed},
+	"sunion":           {flags: flagRead, keys: keyRange(1, -1, 1)},
+	"sunionstore":      {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"sync":             {flags: flagAdmin | flagNoMulti | flagUntimed},
+	"time":             {flags: flagRead},
+	"ttl":              {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"type":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"unsubscribe":      {flags: flagRead | flagPubSub | flagNoMulti | flagSubscribed},
+	"unwatch":          {flags: flagRead},
+	"wait":             {flags: flagRead | flagBlocking | flagNoMulti | flagUntimed},
+	"watch":            {flags: flagRead, keys: keyRange(1, -1, 1)},
+	"xack":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xadd":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xautoclaim":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xclear":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xdump":            {flags: flagRead, keys: keyRange(2, 2, 1)},
+	"xexpire":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xexpireat":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xgroup":           {flags: flagWrite, keys: keyRange(2, 2, 1)},
+	"xhscan":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xkeyexists":       {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xlen":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xlsort":           {flags: flagWrite, keys: keyRange(1, 1, 1), writes: sortWrites},
+	"xmclear":          {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"xmigrate":         {flags: flagWrite | flagAdmin | flagNoMulti | flagUntimed, keys: keyRange(4, 4, 1)},
+	"xmigratedb":       {flags: flagAdmin | flagNoMulti | flagUntimed},
+	"xpending":         {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xpersist":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xpexpire":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xpexpireat":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"xpttl":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xrange":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xread":            {flags: flagRead | flagBlocking, keys: keySpec{movable: streamKeys}},
+	"xreadgroup":       {flags: flagWrite, keys: keySpec{movable: streamKeys}},
+	"xrestore":         {flags: flagWrite, keys: keyRange(2, 2, 1)},
+	"xrevrange":        {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xscan":            {flags: flagRead},
+	"xsscan":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xssort":           {flags: flagWrite, keys: keyRange(1, 1, 1), writes: sortWrites},
+	"xttl":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xzscan":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"xzsort":           {flags: flagWrite, keys: keyRange(1, 1, 1), writes: sortWrites},
+	"zadd":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zcard":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zclear":           {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zcount":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zdiff":            {flags: flagRead, keys: keySpec{movable: numKeysAt(0)}},
+	"zdiffstore":       {flags: flagWrite, keys: keySpec{1, 1, 1, numKeysAt(1)}},
+	"zdump":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zexpire":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zexpireat":        {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zincrby":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zinterstore":      {flags: flagWrite, keys: keySpec{1, 1, 1, numKeysAt(1)}},
+	"zkeyexists":       {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zlexcount":        {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zmclear":          {flags: flagWrite, keys: keyRange(1, -1, 1)},
+	"zmpop":            {flags: flagWrite, keys: keySpec{movable: numKeysAt(0)}},
+	"zpersist":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zpexpire":         {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zpexpireat":       {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zpopmax":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zpopmin":          {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zpttl":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrandmember":      {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrange":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrangebylex":      {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrangebylexstore": {flags: flagWrite, keys: keyRange(1, 2, 1)},
+	"zrangebyscore":    {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrank":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrem":             {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zremrangebylex":   {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zremrangebyrank":  {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zremrangebyscore": {flags: flagWrite, keys: keyRange(1, 1, 1)},
+	"zrevrange":        {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrevrangebylex":   {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrevrangebyscore": {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zrevrank":         {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zscan":            {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zscore":           {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zttl":             {flags: flagRead, keys: keyRange(1, 1, 1)},
+	"zunionstore":      {flags: flagWrite, keys: keySpec{1, 1, 1, numKeysAt(1)}},
+}
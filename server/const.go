@@ -11,11 +11,32 @@ var (
 	ErrNotFound              = errors.New("command not found")
 	ErrNotAuthenticated      = errors.New("not authenticated")
 	ErrAuthenticationFailure = errors.New("authentication failure")
+	ErrNoPermission          = errors.New("NOPERM this user has no permissions to run the command or access the keys")
+	ErrPubSubClient          = errors.New("pub/sub is only supported on the redis protocol")
+	ErrPubSubMode            = errors.New("only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
 	ErrCmdParams             = errors.New("invalid command param")
 	ErrValue                 = errors.New("value is not an integer or out of range")
 	ErrSyntax                = errors.New("syntax error")
 	ErrOffset                = errors.New("offset bit is not an natural number")
 	ErrBool                  = errors.New("value is not 0 or 1")
+	ErrFloat                 = errors.New("value is not a valid float")
+	ErrScriptNotBusy         = errors.New("NOTBUSY No scripts in execution right now.")
+	ErrScriptKilled          = errors.New("script killed by user with SCRIPT KILL")
+	ErrMultiNested           = errors.New("MULTI calls can not be nested")
+	ErrExecWithoutMulti      = errors.New("EXEC without MULTI")
+	ErrDiscardWithoutMulti   = errors.New("DISCARD without MULTI")
+	ErrExecAbort             = errors.New("EXECABORT Transaction discarded because of previous errors.")
+	ErrWatchInMulti          = errors.New("WATCH inside MULTI is not allowed")
+	ErrMultiClient           = errors.New("transactions are only supported on the redis protocol")
+	ErrCmdTimeout            = errors.New("command timeout exceeded")
+	ErrTimeoutNegative       = errors.New("timeout is negative")
+	ErrCopyDB                = errors.New("copy to another database is not supported")
+	ErrNoProto               = errors.New("NOPROTO unsupported protocol version")
+	ErrHelloClient           = errors.New("HELLO is only supported on the redis protocol")
+	ErrDebugDisabled         = errors.New("DEBUG command not allowed, set debug_commands_enabled in the config")
+	ErrDebugSleep            = errors.New("sleep must be between 0 and 30 seconds")
+	ErrClientName            = errors.New("client names cannot contain spaces, newlines or special characters")
+	ErrReadonly              = errors.New("ERREADONLY Operation not permitted while slave replica is read only")
 )
 
 var (
@@ -24,25 +45,31 @@ var (
 	NullBulk  = []byte("-1")
 	NullArray = []byte("-1")
 
-	PONG  = "PONG"
-	OK    = "OK"
-	NOKEY = "NOKEY"
+	PONG   = "PONG"
+	OK     = "OK"
+	NOKEY  = "NOKEY"
+	QUEUED = "QUEUED"
+	RESET  = "RESET"
 )
 
 const (
-	KV   ledis.DataType = ledis.KV
-	LIST                = ledis.LIST
-	HASH                = ledis.HASH
-	SET                 = ledis.SET
-	ZSET                = ledis.ZSET
+	KV     ledis.DataType = ledis.KV
+	LIST                  = ledis.LIST
+	HASH                  = ledis.HASH
+	SET                   = ledis.SET
+	ZSET                  = ledis.ZSET
+	HLL                   = ledis.HLL
+	STREAM                = ledis.STREAM
 )
 
 const (
-	KVName   = ledis.KVName
-	ListName = ledis.ListName
-	HashName = ledis.HashName
-	SetName  = ledis.SetName
-	ZSetName = ledis.ZSetName
+	KVName     = ledis.KVName
+	ListName   = ledis.ListName
+	HashName   = ledis.HashName
+	SetName    = ledis.SetName
+	ZSetName   = ledis.ZSetName
+	HLLName    = ledis.HLLName
+	StreamName = ledis.StreamName
 )
 
 const (
@@ -8,8 +8,10 @@ import (
 	"runtime/debug"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/ledisdb/ledis"
 )
@@ -24,12 +26,18 @@ type info struct {
 		ProceessId int
 	}
 
+	Stats struct {
+		TotalCommands sync2.AtomicInt64
+	}
+
 	Replication struct {
 		PubLogNum          sync2.AtomicInt64
 		PubLogAckNum       sync2.AtomicInt64
 		PubLogTotalAckTime sync2.AtomicDuration
 
 		MasterLastLogID sync2.AtomicUint64
+		// unix time of the last reply from the master
+		MasterLastIOTime sync2.AtomicInt64
 	}
 }
 
@@ -63,18 +71,28 @@ func getMemoryHuman(m uint64) string {
 func (i *info) Dump(section string) []byte {
 	buf := &bytes.Buffer{}
 	switch strings.ToLower(section) {
-	case "":
+	case "", "all":
 		i.dumpAll(buf)
 	case "server":
 		i.dumpServer(buf)
-	case "mem":
+	case "clients":
+		i.dumpClients(buf)
+	case "mem", "memory":
 		i.dumpMem(buf)
 	case "gc":
 		i.dumpGC(buf)
 	case "store":
 		i.dumpStore(buf)
+	case "persistence":
+		i.dumpPersistence(buf)
+	case "stats":
+		i.dumpStats(buf)
 	case "replication":
 		i.dumpReplication(buf)
+	case "cpu":
+		i.dumpCPU(buf)
+	case "keyspace":
+		i.dumpKeyspace(buf)
 	default:
 		buf.WriteString(fmt.Sprintf("# %s\r\n", section))
 	}
@@ -90,13 +108,37 @@ type infoPair struct {
 func (i *info) dumpAll(buf *bytes.Buffer) {
 	i.dumpServer(buf)
 	buf.Write(Delims)
+	i.dumpClients(buf)
+	buf.Write(Delims)
 	i.dumpStore(buf)
 	buf.Write(Delims)
 	i.dumpMem(buf)
 	buf.Write(Delims)
 	i.dumpGC(buf)
 	buf.Write(Delims)
+	i.dumpPersistence(buf)
+	buf.Write(Delims)
+	i.dumpStats(buf)
+	buf.Write(Delims)
 	i.dumpReplication(buf)
+	buf.Write(Delims)
+	i.dumpCPU(buf)
+	buf.Write(Delims)
+	i.dumpKeyspace(buf)
+}
+
+// ledisInfo returns the metrics of section kept by ledis, see DB.Info.
+func (i *info) ledisInfo(section string) map[string]string {
+	db, err := i.app.ldb.Select(0)
+	if err != nil {
+		return nil
+	}
+
+	m, err := db.Info(section)
+	if err != nil {
+		log.Errorf("get %s info error %s", section, err.Error())
+	}
+	return m
 }
 
 func (i *info) dumpServer(buf *bytes.Buffer) {
@@ -114,6 +156,18 @@ func (i *info) dumpServer(buf *bytes.Buffer) {
 	)
 }
 
+func (i *info) dumpClients(buf *bytes.Buffer) {
+	buf.WriteString("# Clients\r\n")
+
+	i.app.slock.Lock()
+	slaves := len(i.app.slaves)
+	i.app.slock.Unlock()
+
+	i.dumpPairs(buf, infoPair{"connected_clients", i.app.respClientNum()},
+		infoPair{"connected_slaves", slaves},
+	)
+}
+
 func (i *info) dumpMem(buf *bytes.Buffer) {
 	buf.WriteString("# Mem\r\n")
 
@@ -133,6 +187,22 @@ func (i *info) dumpMem(buf *bytes.Buffer) {
 		infoPair{"mem_head_released", getMemoryHuman(mem.HeapReleased)},
 		infoPair{"mem_head_objects", mem.HeapObjects},
 	)
+
+	var maxMemory int64
+	var policy string
+	i.app.cfg.View(func() {
+		maxMemory = i.app.cfg.MaxMemory
+		policy = i.app.cfg.MaxMemoryPolicy
+	})
+
+	ldb := i.app.ldb
+	i.dumpPairs(buf, infoPair{"used_memory", ldb.UsedMemory()},
+		infoPair{"used_memory_human", getMemoryHuman(uint64(ldb.UsedMemory()))},
+		infoPair{"maxmemory", maxMemory},
+		infoPair{"maxmemory_human", getMemoryHuman(uint64(maxMemory))},
+		infoPair{"maxmemory_policy", policy},
+		infoPair{"evicted_keys", ldb.EvictedKeys()},
+	)
 }
 
 const (
@@ -197,6 +267,54 @@ func (i *info) dumpStore(buf *bytes.Buffer) {
 	)
 }
 
+func (i *info) dumpPersistence(buf *bytes.Buffer) {
+	buf.WriteString("# Persistence\r\n")
+
+	m := i.ledisInfo("persistence")
+	i.dumpPairs(buf, infoPair{"rdb_changes_since_last_save", m["rdb_changes_since_last_save"]},
+		infoPair{"rdb_last_save_time", m["rdb_last_save_time"]},
+	)
+}
+
+func (i *info) dumpStats(buf *bytes.Buffer) {
+	buf.WriteString("# Stats\r\n")
+
+	i.dumpPairs(buf, infoPair{"total_connections_received", i.app.clientID.Get()},
+		infoPair{"total_commands_processed", i.Stats.TotalCommands.Get()},
+		infoPair{"evicted_keys", i.app.ldb.EvictedKeys()},
+	)
+}
+
+func (i *info) dumpCPU(buf *bytes.Buffer) {
+	buf.WriteString("# CPU\r\n")
+
+	var ru syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &ru)
+
+	seconds := func(t syscall.Timeval) string {
+		return fmt.Sprintf("%d.%06d", t.Sec, t.Usec)
+	}
+	i.dumpPairs(buf, infoPair{"used_cpu_sys", seconds(ru.Stime)},
+		infoPair{"used_cpu_user", seconds(ru.Utime)},
+	)
+}
+
+// dumpKeyspace writes a line for each database with any key, like
+// db0:keys=1,expires=0,avg_ttl=0.
+func (i *info) dumpKeyspace(buf *bytes.Buffer) {
+	buf.WriteString("# Keyspace\r\n")
+
+	m := i.ledisInfo("keyspace")
+	p := []infoPair{}
+	for index := 0; index < i.app.cfg.Databases; index++ {
+		key := fmt.Sprintf("db%d", index)
+		if v, ok := m[key]; ok {
+			p = append(p, infoPair{key, v})
+		}
+	}
+	i.dumpPairs(buf, p...)
+}
+
 func (i *info) dumpReplication(buf *bytes.Buffer) {
 	buf.WriteString("# Replication\r\n")
 
@@ -250,6 +368,14 @@ func (i *info) dumpReplication(buf *bytes.Buffer) {
 
 	p = append(p, infoPair{"master_last_log_id", i.Replication.MasterLastLogID.Get()})
 
+	m := i.ledisInfo("replication")
+	p = append(p, infoPair{"master_replid", m["master_replid"]})
+	p = append(p, infoPair{"master_repl_offset", m["master_repl_offset"]})
+	p = append(p, infoPair{"replication_id", i.app.ldb.ReplicationID()})
+	id2, id2LastID := i.app.ldb.ReplicationID2()
+	p = append(p, infoPair{"replication_id2", id2})
+	p = append(p, infoPair{"replication_id2_last_log_id", id2LastID})
+
 	if isSlave {
 		// add some redis slave replication info for outer failover service :-)
 		state := i.app.m.state.Get()
@@ -259,6 +385,13 @@ func (i *info) dumpReplication(buf *bytes.Buffer) {
 			p = append(p, infoPair{"master_link_status", "down"})
 		}
 
+		// -1 before the first reply, like redis
+		if t := i.Replication.MasterLastIOTime.Get(); t > 0 {
+			p = append(p, infoPair{"master_last_io_seconds_ago", time.Now().Unix() - t})
+		} else {
+			p = append(p, infoPair{"master_last_io_seconds_ago", -1})
+		}
+
 		// here, all the slaves have same priority now
 		p = append(p, infoPair{"slave_priority", 100})
 		if s != nil {
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+// the max number of the messages waiting to be written to a subscriber, a
+// subscriber falling behind more is disconnected like redis does
+const pubsubQueueSize = 1024
+
+type pubsubMessage struct {
+	// empty if not by a pattern subscription
+	pattern string
+	channel string
+	data    []byte
+}
+
+type patternSubscriber struct {
+	pattern string
+	s       *Subscriber
+}
+
+// PubSubHub fans out the published messages to the subscribers in memory,
+// it has nothing to do with the keyspace. The subscriptions are copied on
+// write, so Publish takes no lock.
+type PubSubHub struct {
+	m sync.Mutex
+
+	// map[string][]*Subscriber
+	channels atomic.Value
+	// []patternSubscriber
+	patterns atomic.Value
+}
+
+func newPubSubHub() *PubSubHub {
+	h := new(PubSubHub)
+	h.channels.Store(map[string][]*Subscriber{})
+	h.patterns.Store([]patternSubscriber{})
+	return h
+}
+
+// Subscriber is a connection in the pub/sub mode, the messages are written
+// by its own goroutine.
+type Subscriber struct {
+	w    *respWriter
+	ch   chan *pubsubMessage
+	quit chan struct{}
+	kill func()
+
+	// only used by the goroutine of the connection and the hub
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func newSubscriber(w *respWriter, kill func()) *Subscriber {
+	s := &Subscriber{
+		w:        w,
+		ch:       make(chan *pubsubMessage, pubsubQueueSize),
+		quit:     make(chan struct{}),
+		kill:     kill,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+func (s *Subscriber) run() {
+	for {
+		select {
+		case m := <-s.ch:
+			s.w.m.Lock()
+			s.write(m)
+			// write the waiting messages with one flush
+			for n := len(s.ch); n > 0; n-- {
+				s.write(<-s.ch)
+			}
+			s.w.flush()
+			s.w.m.Unlock()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *Subscriber) write(m *pubsubMessage) {
+	if len(m.pattern) == 0 {
+		s.w.writePushHeader(3)
+		s.w.writeBulk(pubsubMessageReply)
+	} else {
+		s.w.writePushHeader(4)
+		s.w.writeBulk(pubsubPMessageReply)
+		s.w.writeBulk(hack.Slice(m.pattern))
+	}
+	s.w.writeBulk(hack.Slice(m.channel))
+	s.w.writeBulk(m.data)
+}
+
+func (s *Subscriber) push(m *pubsubMessage) {
+	select {
+	case s.ch <- m:
+	default:
+		log.Errorf("pubsub subscriber is too slow, close it")
+		s.kill()
+	}
+}
+
+// count returns the number of the channels and the patterns subscribed.
+func (s *Subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+func (s *Subscriber) close() {
+	close(s.quit)
+}
+
+// Subscribe subscribes s to the channels.
+func (h *PubSubHub) Subscribe(s *Subscriber, channels ...string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	old := h.channels.Load().(map[string][]*Subscriber)
+	m := make(map[string][]*Subscriber, len(old)+len(channels))
+	for channel, subs := range old {
+		m[channel] = subs
+	}
+
+	for _, channel := range channels {
+		if _, ok := s.channels[channel]; ok {
+			continue
+		}
+		s.channels[channel] = struct{}{}
+
+		subs := m[channel]
+		m[channel] = append(subs[:len(subs):len(subs)], s)
+	}
+
+	h.channels.Store(m)
+}
+
+// Unsubscribe unsubscribes s from the channels.
+func (h *PubSubHub) Unsubscribe(s *Subscriber, channels ...string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	old := h.channels.Load().(map[string][]*Subscriber)
+	m := make(map[string][]*Subscriber, len(old))
+	for channel, subs := range old {
+		m[channel] = subs
+	}
+
+	for _, channel := range channels {
+		if _, ok := s.channels[channel]; !ok {
+			continue
+		}
+		delete(s.channels, channel)
+
+		subs := make([]*Subscriber, 0, len(m[channel]))
+		for _, sub := range m[channel] {
+			if sub != s {
+				subs = append(subs, sub)
+			}
+		}
+
+		if len(subs) == 0 {
+			delete(m, channel)
+		} else {
+			m[channel] = subs
+		}
+	}
+
+	h.channels.Store(m)
+}
+
+// PSubscribe subscribes s to the channels matching the glob style patterns.
+func (h *PubSubHub) PSubscribe(s *Subscriber, patterns ...string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	old := h.patterns.Load().([]patternSubscriber)
+	ps := append([]patternSubscriber(nil), old...)
+
+	for _, pattern := range patterns {
+		if _, ok := s.patterns[pattern]; ok {
+			continue
+		}
+		s.patterns[pattern] = struct{}{}
+
+		ps = append(ps, patternSubscriber{pattern, s})
+	}
+
+	h.patterns.Store(ps)
+}
+
+// PUnsubscribe unsubscribes s from the patterns.
+func (h *PubSubHub) PUnsubscribe(s *Subscriber, patterns ...string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	removed := make(map[string]struct{}, len(patterns))
+	for _, pattern := range patterns {
+		if _, ok := s.patterns[pattern]; ok {
+			delete(s.patterns, pattern)
+			removed[pattern] = struct{}{}
+		}
+	}
+
+	old := h.patterns.Load().([]patternSubscriber)
+	ps := make([]patternSubscriber, 0, len(old))
+	for _, p := range old {
+		if _, ok := removed[p.pattern]; ok && p.s == s {
+			continue
+		}
+		ps = append(ps, p)
+	}
+
+	h.patterns.Store(ps)
+}
+
+// Publish sends message to the subscribers of channel and the patterns
+// matching channel, it returns the number of the receivers.
+func (h *PubSubHub) Publish(channel string, message []byte) int {
+	subs := h.channels.Load().(map[string][]*Subscriber)[channel]
+	ps := h.patterns.Load().([]patternSubscriber)
+	if len(subs) == 0 && len(ps) == 0 {
+		return 0
+	}
+
+	// the request buffer is reused by the next request
+	message = append([]byte(nil), message...)
+
+	n := 0
+	if len(subs) > 0 {
+		m := &pubsubMessage{channel: channel, data: message}
+		for _, s := range subs {
+			s.push(m)
+			n++
+		}
+	}
+
+	for _, p := range ps {
+		if ledis.MatchPattern(p.pattern, channel) {
+			p.s.push(&pubsubMessage{pattern: p.pattern, channel: channel, data: message})
+			n++
+		}
+	}
+
+	return n
+}
+
+// Channels returns the channels matching the pattern, which have at least
+// one subscriber, in order. An empty pattern matches all.
+func (h *PubSubHub) Channels(pattern string) []string {
+	m := h.channels.Load().(map[string][]*Subscriber)
+
+	channels := make([]string, 0, len(m))
+	for channel := range m {
+		if len(pattern) == 0 || ledis.MatchPattern(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Strings(channels)
+	return channels
+}
+
+// NumSub returns the number of the subscribers of the channel, not counting
+// the pattern subscriptions.
+func (h *PubSubHub) NumSub(channel string) int {
+	return len(h.channels.Load().(map[string][]*Subscriber)[channel])
+}
+
+// NumPat returns the number of the pattern subscriptions.
+func (h *PubSubHub) NumPat() int {
+	return len(h.patterns.Load().([]patternSubscriber))
+}
+
+// publishExpired publishes the keys deleted by the ttl checker until ch is
+// closed, the key to the keyevent channel and the event to the keyspace one.
+func (app *App) publishExpired(ch <-chan ledis.Notification) {
+	for n := range ch {
+		app.pubsub.Publish(fmt.Sprintf("__keyevent@%d__:%s", n.DB, n.Event), n.Key)
+		app.pubsub.Publish(fmt.Sprintf("__keyspace@%d__:%s", n.DB, n.Key), hack.Slice(n.Event))
+	}
+}
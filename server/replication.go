@@ -2,16 +2,19 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/num"
 	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/goredis"
@@ -24,6 +27,12 @@ var (
 	errReplClosed    = errors.New("replication is closed")
 )
 
+// the replies of PSYNC
+const (
+	psyncContinue   = "CONTINUE"
+	psyncFullResync = "FULLRESYNC"
+)
+
 const (
 	// slave needs to connect to its master
 	replConnectState int32 = iota + 1
@@ -103,13 +112,31 @@ func (m *master) closeConn() {
 	m.conn = nil
 }
 
+func (m *master) connect() (*goredis.Conn, error) {
+	if !m.app.cfg.Replication.MasterTLS {
+		return goredis.Connect(m.addr)
+	}
+
+	tlsCfg, err := masterTLSConfig(&m.app.cfg.Replication, m.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := tls.Dial("tcp", m.addr, tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return goredis.NewConn(conn)
+}
+
 func (m *master) checkConn() error {
 	m.connLock.Lock()
 	defer m.connLock.Unlock()
 
 	var err error
 	if m.conn == nil {
-		m.conn, err = goredis.Connect(m.addr)
+		m.conn, err = m.connect()
 
 		if err != nil {
 			return err
@@ -190,7 +217,7 @@ func (m *master) runReplication(restart bool) {
 		}
 
 		if err := m.checkConn(); err != nil {
-			log.Errorf("check master %s connection error %s, try 3s later", m.addr, err.Error())
+			m.app.ldb.Logger().Error("check master connection failed, try 3s later", ledis.Field{Key: "master", Value: m.addr}, rpl.EventType("connect"), rpl.Err(err))
 
 			select {
 			case <-time.After(3 * time.Second):
@@ -208,30 +235,31 @@ func (m *master) runReplication(restart bool) {
 
 		if err := m.replConf(); err != nil {
 			if strings.Contains(err.Error(), ledis.ErrRplNotSupport.Error()) {
-				log.Fatalf("master doesn't support replication, wait 10s and retry")
+				m.app.ldb.Logger().Error("master doesn't support replication, wait 10s and retry", ledis.Field{Key: "master", Value: m.addr}, rpl.EventType("replconf"), rpl.Err(err))
 				select {
 				case <-time.After(10 * time.Second):
 				case <-m.quit:
 					return
 				}
 			} else {
-				log.Errorf("replconf error %s", err.Error())
+				m.app.ldb.Logger().Error("replconf failed", ledis.Field{Key: "master", Value: m.addr}, rpl.EventType("replconf"), rpl.Err(err))
 			}
 
 			continue
 		}
 
-		if restart {
-			if err := m.fullSync(); err != nil {
-				log.Errorf("restart fullsync error %s", err.Error())
-				continue
-			}
-			m.state.Set(replConnectedState)
+		if err := m.psync(restart); err != nil {
+			m.app.ldb.Logger().Error("psync failed", ledis.Field{Key: "master", Value: m.addr}, rpl.EventType("psync"), rpl.Err(err))
+			continue
 		}
+		m.state.Set(replConnectedState)
+
+		// a reconnect syncs from the loaded logs, like after a heartbeat timeout
+		restart = false
 
 		for {
 			if err := m.sync(); err != nil {
-				log.Errorf("sync error %s", err.Error())
+				m.app.ldb.Logger().Error("sync failed", ledis.Field{Key: "master", Value: m.addr}, rpl.EventType("sync"), rpl.Err(err))
 				break
 			}
 			m.state.Set(replConnectedState)
@@ -260,8 +288,59 @@ func (m *master) replConf() error {
 	return nil
 }
 
+// psync fully syncs if restart, or if the master does not have the logs
+// after ours in the same history, then the replication id of the master is
+// ours. A master without PSYNC is synced as before.
+func (m *master) psync(restart bool) error {
+	id := m.app.ldb.ReplicationID()
+	if restart {
+		id = "?"
+	}
+
+	syncID, err := m.nextSyncLogID()
+	if err != nil {
+		return err
+	}
+
+	reply, err := goredis.String(m.conn.Do("psync", id, syncID))
+	if err != nil && strings.Contains(err.Error(), ErrNotFound.Error()) {
+		if restart {
+			return m.fullSync()
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(reply)
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid psync reply %s", reply)
+	}
+
+	switch fields[0] {
+	case psyncContinue:
+		m.app.ldb.Logger().Info("continue to sync", rpl.LogPos(syncID), ledis.Field{Key: "replication_id", Value: fields[1]}, rpl.EventType("psync"))
+
+		// the master is promoted from a slave of our history
+		if fields[1] != id {
+			return m.app.ldb.SetReplicationID(fields[1])
+		}
+		return nil
+	case psyncFullResync:
+		if err = m.fullSync(); err != nil {
+			return err
+		}
+		return m.app.ldb.SetReplicationID(fields[1])
+	default:
+		return fmt.Errorf("invalid psync reply %s", reply)
+	}
+}
+
 func (m *master) fullSync() error {
-	log.Info("begin full sync")
+	m.app.ldb.Logger().Info("begin full sync", ledis.Field{Key: "master", Value: m.addr}, rpl.EventType("fullsync"))
+
+	// the snapshot may take long to send
+	m.conn.SetReadDeadline(time.Time{})
 
 	if err := m.conn.Send("fullsync"); err != nil {
 		return err
@@ -280,12 +359,12 @@ func (m *master) fullSync() error {
 	err = m.conn.ReceiveBulkTo(f)
 	f.Close()
 	if err != nil {
-		log.Errorf("read dump data error %s", err.Error())
+		m.app.ldb.Logger().Error("read dump data failed", rpl.EventType("fullsync"), rpl.Err(err))
 		return err
 	}
 
 	if _, err = m.app.ldb.LoadDumpFile(dumpPath); err != nil {
-		log.Errorf("load dump file error %s", err.Error())
+		m.app.ldb.Logger().Error("load dump file failed", rpl.EventType("fullsync"), rpl.Err(err))
 		return err
 	}
 
@@ -312,6 +391,13 @@ func (m *master) sync() error {
 		return err
 	}
 
+	// the master replies every heartbeat interval, even without new logs
+	var deadline time.Time
+	if hb := m.app.cfg.Replication.HeartbeatTimeout; hb > 0 {
+		deadline = time.Now().Add(time.Duration(hb) * time.Second)
+	}
+	m.conn.SetReadDeadline(deadline)
+
 	if err := m.conn.Send("sync", syncID); err != nil {
 		return err
 	}
@@ -323,12 +409,16 @@ func (m *master) sync() error {
 	if err = m.conn.ReceiveBulkTo(&m.syncBuf); err != nil {
 		if strings.Contains(err.Error(), ledis.ErrLogMissed.Error()) {
 			return m.fullSync()
-		} else {
-			return err
+		} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			m.app.ldb.Logger().Error("master has not replied, reconnect", ledis.Field{Key: "master", Value: m.addr},
+				ledis.Field{Key: "heartbeat_timeout", Value: m.app.cfg.Replication.HeartbeatTimeout}, rpl.EventType("sync"), rpl.Err(err))
+			m.closeConn()
 		}
+		return err
 	}
 
 	m.state.Set(replConnectedState)
+	m.app.info.Replication.MasterLastIOTime.Set(time.Now().Unix())
 
 	buf := m.syncBuf.Bytes()
 
@@ -369,22 +459,39 @@ func (app *App) slaveof(masterAddr string, restart bool, readonly bool) error {
 		return fmt.Errorf("slaveof must enable replication")
 	}
 
-	app.cfg.SlaveOf = masterAddr
-
 	if len(masterAddr) == 0 {
-		log.Infof("slaveof no one, stop replication")
+		app.ldb.Logger().Info("slaveof no one, stop replication", rpl.EventType("promote"))
 		if err := app.m.stopReplication(); err != nil {
 			return err
 		}
 
+		// the new writes differ from the logs of the old master, if it
+		// fails the server stays a readonly slave without replication, and
+		// SLAVEOF NO ONE can be retried
+		if err := app.ldb.SlaveOfNoOne(); err != nil {
+			return err
+		}
+
+		app.cfg.SlaveOf = ""
+		app.info.Replication.MasterLastLogID.Set(0)
 		app.cfg.SetReadonly(readonly)
 	} else {
+		app.cfg.SlaveOf = masterAddr
 		return app.m.startReplication(masterAddr, restart)
 	}
 
 	return nil
 }
 
+// MasterAddr returns the address of the master replicated from, empty if
+// this server is a master.
+func (app *App) MasterAddr() string {
+	app.m.Lock()
+	defer app.m.Unlock()
+
+	return app.cfg.SlaveOf
+}
+
 func (app *App) tryReSlaveof() error {
 	app.m.Lock()
 	defer app.m.Unlock()
@@ -407,6 +514,7 @@ func (app *App) addSlave(c *client) {
 	defer app.slock.Unlock()
 
 	app.slaves[addr] = c
+	app.broadcastSlaveSync()
 }
 
 func (app *App) removeSlave(c *client, activeQuit bool) {
@@ -417,9 +525,102 @@ func (app *App) removeSlave(c *client, activeQuit bool) {
 
 	if _, ok := app.slaves[addr]; ok {
 		delete(app.slaves, addr)
-		log.Infof("remove slave %s", addr)
+		app.ldb.Logger().Info("remove slave", ledis.Field{Key: "slave", Value: addr}, rpl.EventType("sync"))
 		asyncNotifyUint64(app.slaveSyncAck, c.lastLogID.Get())
+		app.broadcastSlaveSync()
+	}
+}
+
+// slaveSynced wakes up the WAITs after the slave syncs.
+func (app *App) slaveSynced() {
+	app.slock.Lock()
+	app.broadcastSlaveSync()
+	app.slock.Unlock()
+}
+
+// broadcastSlaveSync must be called with slock held.
+func (app *App) broadcastSlaveSync() {
+	close(app.slaveSyncCh)
+	app.slaveSyncCh = make(chan struct{})
+}
+
+// Wait blocks until at least numReplicas slaves have all the logs written
+// before the call, then returns the number of these slaves. It returns the
+// number at timeout, or with the error of ctx when ctx is done. A timeout 0
+// means no timeout. It returns 0 at once if no slave is connected.
+func (app *App) Wait(ctx context.Context, numReplicas int, timeout time.Duration) (int, error) {
+	stat, err := app.ldb.ReplicationStat()
+	if err == ledis.ErrRplNotSupport {
+		// no slave can connect
+		return 0, nil
+	} else if err != nil {
+		return 0, err
 	}
+
+	return app.waitLog(ctx, stat.LastID, numReplicas, timeout)
+}
+
+// waitReplicas is the ledis ReplicaWaiter of the writes with WaitReplicas.
+func (app *App) waitReplicas(id uint64, numReplicas int, timeout time.Duration) int {
+	n, _ := app.waitLog(context.Background(), id, numReplicas, timeout)
+	return n
+}
+
+// waitLog is Wait for the slaves having the log of id.
+func (app *App) waitLog(ctx context.Context, id uint64, numReplicas int, timeout time.Duration) (int, error) {
+	app.slock.Lock()
+	n := len(app.slaves)
+	app.slock.Unlock()
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	for {
+		app.slock.Lock()
+		n = 0
+		for _, s := range app.slaves {
+			if s.lastLogID.Get() >= id {
+				n++
+			}
+		}
+		ch := app.slaveSyncCh
+		app.slock.Unlock()
+
+		if n >= numReplicas {
+			return n, nil
+		}
+
+		select {
+		case <-ch:
+		case <-timeoutCh:
+			return n, nil
+		case <-ctx.Done():
+			return n, ctx.Err()
+		}
+	}
+}
+
+// minSlaveLogID returns the lowest last log id of the connected slaves.
+func (app *App) minSlaveLogID() (uint64, bool) {
+	app.slock.Lock()
+	defer app.slock.Unlock()
+
+	var id uint64
+	ok := false
+	for _, s := range app.slaves {
+		if lastLogID := s.lastLogID.Get(); !ok || lastLogID < id {
+			id, ok = lastLogID, true
+		}
+	}
+	return id, ok
 }
 
 func (app *App) slaveAck(c *client) {
@@ -468,7 +669,8 @@ func (app *App) publishNewLog(l *rpl.Log) {
 			//slave has already owned this log
 			n++
 		} else if lastLogID > logId {
-			log.Errorf("invalid slave %s, lastlogid %d > %d", s.slaveListeningAddr, lastLogID, logId)
+			app.ldb.Logger().Error("invalid slave, its last log is after the new one", ledis.Field{Key: "slave", Value: s.slaveListeningAddr},
+				ledis.Field{Key: "slave_log_pos", Value: lastLogID}, rpl.LogPos(logId), rpl.EventType("sync"))
 		}
 	}
 
@@ -486,7 +688,7 @@ func (app *App) publishNewLog(l *rpl.Log) {
 		for i := 0; i < slaveNum; i++ {
 			id := <-app.slaveSyncAck
 			if id < logId {
-				log.Infof("some slave may close with last logid %d < %d", id, logId)
+				app.ldb.Logger().Info("some slave may close before the log", ledis.Field{Key: "slave_log_pos", Value: id}, rpl.LogPos(logId), rpl.EventType("sync"))
 			} else {
 				n++
 				if n >= total {
@@ -500,10 +702,112 @@ func (app *App) publishNewLog(l *rpl.Log) {
 	select {
 	case <-done:
 	case <-time.After(time.Duration(app.cfg.Replication.WaitSyncTime) * time.Millisecond):
-		log.Info("replication wait timeout")
+		app.ldb.Logger().Info("replication wait timeout", rpl.LogPos(logId), rpl.EventType("sync"))
 	}
 
 	stopTime := time.Now()
 	app.info.Replication.PubLogAckNum.Add(1)
 	app.info.Replication.PubLogTotalAckTime.Add(stopTime.Sub(startTime))
 }
+
+type slaveStatus struct {
+	Addr      string `json:"addr"`
+	LastLogID uint64 `json:"last_log_id"`
+	LagLogs   uint64 `json:"lag_logs"`
+	State     string `json:"state"`
+	// bytes per second sent to the slave in the last second
+	SendRate int64 `json:"send_rate"`
+}
+
+type replicationStatus struct {
+	Role        string        `json:"role"`
+	SlaveCount  int           `json:"slave_count"`
+	Slaves      []slaveStatus `json:"connected_slaves"`
+	LastLogID   uint64        `json:"last_log_id"`
+	CommitLogID uint64        `json:"commit_log_id"`
+	// the send limit for each slave in bytes per second, 0 means no limit
+	ThrottleBytes int64 `json:"throttle_bytes"`
+	// the id of the history of the logs, a slave has the id of its master
+	ReplicationID string `json:"replication_id"`
+
+	MasterAddr       string `json:"master_addr,omitempty"`
+	MasterLinkStatus string `json:"master_link_status,omitempty"`
+	MasterLastLogID  uint64 `json:"master_last_log_id,omitempty"`
+	LogsBehindMaster uint64 `json:"logs_behind_master,omitempty"`
+}
+
+func (app *App) replicationStatus() *replicationStatus {
+	s := &replicationStatus{
+		Role:          "master",
+		Slaves:        []slaveStatus{},
+		ThrottleBytes: app.cfg.Replication.ThrottleBytes,
+		ReplicationID: app.ldb.ReplicationID(),
+	}
+
+	if stat, _ := app.ldb.ReplicationStat(); stat != nil {
+		s.LastLogID = stat.LastID
+		s.CommitLogID = stat.CommitID
+	}
+
+	now := time.Now().Unix()
+	timeout := int64(app.cfg.Replication.SlaveTimeout)
+
+	app.slock.Lock()
+	for addr, c := range app.slaves {
+		ss := slaveStatus{Addr: addr, LastLogID: c.lastLogID.Get(), State: "connected", SendRate: c.syncThrottle.sendRate()}
+		if s.LastLogID > ss.LastLogID {
+			ss.LagLogs = s.LastLogID - ss.LastLogID
+		}
+		if now-c.lastSyncTime.Get() > timeout {
+			ss.State = "disconnected"
+		}
+		s.Slaves = append(s.Slaves, ss)
+	}
+	app.slock.Unlock()
+
+	s.SlaveCount = len(s.Slaves)
+
+	app.m.Lock()
+	slaveof := app.cfg.SlaveOf
+	app.m.Unlock()
+
+	if len(slaveof) > 0 {
+		s.Role = "slave"
+		s.MasterAddr = slaveof
+
+		state := app.m.state.Get()
+		if state == replSyncState || state == replConnectedState {
+			s.MasterLinkStatus = "up"
+		} else {
+			s.MasterLinkStatus = "down"
+		}
+
+		s.MasterLastLogID = app.info.Replication.MasterLastLogID.Get()
+		if s.MasterLastLogID > s.CommitLogID {
+			s.LogsBehindMaster = s.MasterLastLogID - s.CommitLogID
+		}
+	}
+
+	return s
+}
+
+// ReplicationStatusHandler returns the http handler reporting the replication
+// status in JSON, it responds 503 if this is a slave and the master link is down,
+// so it can be used as a readiness probe.
+func (app *App) ReplicationStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := app.replicationStatus()
+
+		buf, err := json.Marshal(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-type", "application/json; charset=utf-8")
+		if s.MasterLinkStatus == "down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(buf)
+	})
+}
@@ -1,12 +1,14 @@
 package server
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/siddontang/go/hack"
 	"github.com/siddontang/go/num"
@@ -97,6 +99,26 @@ func (w *luaWriter) writeBulkFrom(n int64, rb io.Reader) {
 	w.writeError(errors.New("unsupport"))
 }
 
+func (w *luaWriter) writeFVPairMap(lst []ledis.FVPair) {
+	w.writeFVPairArray(lst)
+}
+
+func (w *luaWriter) writeSliceSet(lst [][]byte) {
+	w.writeSliceArray(lst)
+}
+
+func (w *luaWriter) writeScore(n int64) {
+	w.writeBulk(num.FormatInt64ToSlice(n))
+}
+
+func (w *luaWriter) writeBool(b bool) {
+	if b {
+		w.writeInteger(1)
+	} else {
+		w.writeInteger(0)
+	}
+}
+
 func (w *luaWriter) flush() {
 }
 
@@ -165,6 +187,38 @@ type script struct {
 	c   *client
 
 	chunks map[string]struct{}
+
+	// the running script, guarded by runLock because the script lock
+	// is held while the script runs
+	runLock sync.Mutex
+	cancel  context.CancelFunc
+	start   time.Time
+}
+
+func (s *script) setRunning(cancel context.CancelFunc) {
+	s.runLock.Lock()
+	s.cancel = cancel
+	s.start = time.Now()
+	s.runLock.Unlock()
+}
+
+// kill stops the running script, but not before it has run for limit.
+func (s *script) kill(limit time.Duration) error {
+	s.runLock.Lock()
+	cancel, start := s.cancel, s.start
+	s.runLock.Unlock()
+
+	if cancel == nil {
+		return ErrScriptNotBusy
+	}
+
+	if d := limit - time.Since(start); d > 0 {
+		time.Sleep(d)
+	}
+
+	// no harm if the script is finished during the sleep
+	cancel()
+	return nil
 }
 
 func (app *App) openScript() {
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+// the channel of the sentinels to publish a failover, the message is
+// "<master name> <old ip> <old port> <new ip> <new port>"
+const sentinelSwitchMaster = "+switch-master"
+
+// sentinel replicates from the master of a sentinel master name, in place of
+// slaveof. It discovers the master when it starts, and switches to the new
+// one after a failover.
+type sentinel struct {
+	app *App
+	cfg *config.SentinelConfig
+
+	connLock sync.Mutex
+	conn     *goredis.Conn
+
+	wg sync.WaitGroup
+}
+
+func newSentinel(app *App) (*sentinel, error) {
+	cfg := &app.cfg.Sentinel
+	if len(cfg.MasterName) == 0 {
+		return nil, fmt.Errorf("sentinel master_name must be set with sentinel addrs")
+	}
+
+	return &sentinel{app: app, cfg: cfg}, nil
+}
+
+func (s *sentinel) start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Close stops following the sentinels, the replication goes on.
+func (s *sentinel) Close() {
+	s.connLock.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.connLock.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *sentinel) isQuited() bool {
+	select {
+	case <-s.app.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *sentinel) dial(addr string) (*goredis.Conn, error) {
+	conn, err := goredis.Connect(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.cfg.Password) > 0 {
+		if _, err = conn.Do("auth", s.cfg.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// masterAddr asks the sentinels in turn for the address of the master, the
+// first one which knows it wins.
+func (s *sentinel) masterAddr() (string, error) {
+	err := fmt.Errorf("no sentinel addrs")
+	for _, addr := range s.cfg.Addrs {
+		var conn *goredis.Conn
+		if conn, err = s.dial(addr); err != nil {
+			continue
+		}
+
+		var reply []string
+		reply, err = goredis.Strings(conn.Do("sentinel", "get-master-addr-by-name", s.cfg.MasterName))
+		conn.Close()
+
+		if err != nil {
+			continue
+		} else if len(reply) != 2 {
+			err = fmt.Errorf("invalid get-master-addr-by-name reply %v", reply)
+			continue
+		}
+		return net.JoinHostPort(reply[0], reply[1]), nil
+	}
+	return "", err
+}
+
+// subscribe returns a connection subscribed to the failovers of the first
+// sentinel which accepts it.
+func (s *sentinel) subscribe() (*goredis.Conn, error) {
+	err := fmt.Errorf("no sentinel addrs")
+	for _, addr := range s.cfg.Addrs {
+		var conn *goredis.Conn
+		if conn, err = s.dial(addr); err != nil {
+			continue
+		}
+
+		if _, err = conn.Do("subscribe", sentinelSwitchMaster); err != nil {
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+	return nil, err
+}
+
+func (s *sentinel) run() {
+	defer s.wg.Done()
+
+	for {
+		// the failovers are missed while not subscribed, so the master is
+		// asked again after every reconnect
+		if addr, err := s.masterAddr(); err != nil {
+			log.Errorf("get master %s from sentinels error %s", s.cfg.MasterName, err.Error())
+		} else if err = s.switchMaster(addr); err != nil {
+			log.Errorf("switch master to %s error %s", addr, err.Error())
+		}
+
+		if err := s.watch(); err != nil && !s.isQuited() {
+			log.Errorf("watch sentinels error %s, try 3s later", err.Error())
+		}
+
+		select {
+		case <-time.After(3 * time.Second):
+		case <-s.app.quit:
+			return
+		}
+	}
+}
+
+// watch switches the master on every failover of the master name, until the
+// connection to the sentinel is lost.
+func (s *sentinel) watch() error {
+	conn, err := s.subscribe()
+	if err != nil {
+		return err
+	}
+
+	s.connLock.Lock()
+	if s.isQuited() {
+		s.connLock.Unlock()
+		conn.Close()
+		return nil
+	}
+	s.conn = conn
+	s.connLock.Unlock()
+
+	defer func() {
+		s.connLock.Lock()
+		s.conn = nil
+		s.connLock.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		msg, err := goredis.Strings(conn.Receive())
+		if err != nil {
+			return err
+		} else if len(msg) != 3 || msg[0] != "message" || msg[1] != sentinelSwitchMaster {
+			continue
+		}
+
+		fields := strings.Fields(msg[2])
+		if len(fields) != 5 || fields[0] != s.cfg.MasterName {
+			continue
+		}
+
+		addr := net.JoinHostPort(fields[3], fields[4])
+		if err = s.switchMaster(addr); err != nil {
+			log.Errorf("switch master to %s error %s", addr, err.Error())
+		}
+	}
+}
+
+// switchMaster replicates from addr if it is not the master already, the
+// replication of the old master is stopped first.
+func (s *sentinel) switchMaster(addr string) error {
+	if addr == s.app.MasterAddr() {
+		return nil
+	} else if addr == s.app.cfg.Addr {
+		// the sentinels promote this server with SLAVEOF NO ONE
+		return nil
+	}
+
+	log.Infof("switch master of %s to %s by sentinels", s.cfg.MasterName, addr)
+
+	// the old master said nothing about the new one
+	s.app.info.Replication.MasterLastLogID.Set(0)
+	s.app.info.Replication.MasterLastIOTime.Set(0)
+
+	return s.app.slaveof(addr, false, true)
+}
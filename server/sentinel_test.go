@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+// testSentinel replies to the few sentinel commands used by the slaves.
+type testSentinel struct {
+	l net.Listener
+
+	m        sync.Mutex
+	password string
+	master   [2]string
+	subs     []*goredis.Conn
+}
+
+func (s *testSentinel) serve() {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *testSentinel) handle(c net.Conn) {
+	conn, _ := goredis.NewConn(c)
+	defer conn.Close()
+
+	for {
+		req, err := conn.ReceiveRequest()
+		if err != nil {
+			return
+		}
+
+		s.m.Lock()
+		switch strings.ToLower(string(req[0])) {
+		case "auth":
+			if string(req[1]) == s.password {
+				conn.SendValue("OK")
+			} else {
+				conn.SendValue(errors.New("ERR invalid password"))
+			}
+		case "sentinel":
+			conn.SendValue([]interface{}{[]byte(s.master[0]), []byte(s.master[1])})
+		case "subscribe":
+			s.subs = append(s.subs, conn)
+			conn.SendValue([]interface{}{[]byte("subscribe"), req[1], int64(1)})
+		}
+		s.m.Unlock()
+	}
+}
+
+func (s *testSentinel) failover(name string, ip string, port string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	msg := fmt.Sprintf("%s %s %s %s %s", name, s.master[0], s.master[1], ip, port)
+	if name == "mymaster" {
+		s.master = [2]string{ip, port}
+	}
+	for _, conn := range s.subs {
+		conn.SendValue([]interface{}{[]byte("message"), []byte(sentinelSwitchMaster), []byte(msg)})
+	}
+}
+
+func TestSentinel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := &testSentinel{l: l, password: "pass", master: [2]string{"127.0.0.1", "11201"}}
+	go s.serve()
+
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_sentinel"
+	cfg.Addr = "127.0.0.1:11200"
+	cfg.UseReplication = true
+	cfg.Sentinel = config.SentinelConfig{
+		Addrs:      []string{"127.0.0.1:1", l.Addr().String()},
+		MasterName: "mymaster",
+		Password:   "pass",
+	}
+	os.RemoveAll(cfg.DataDir)
+	defer os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	waitMaster := func(addr string) {
+		for i := 0; i < 50 && app.MasterAddr() != addr; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if a := app.MasterAddr(); a != addr {
+			t.Fatal(a)
+		}
+	}
+
+	// the master is discovered without slaveof
+	waitMaster("127.0.0.1:11201")
+	if !cfg.GetReadonly() {
+		t.Fatal("slave not readonly")
+	}
+
+	// the failovers of the other masters are ignored
+	for i := 0; i < 50; i++ {
+		s.m.Lock()
+		n := len(s.subs)
+		s.m.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	s.failover("other", "127.0.0.1", "11203")
+	s.failover("mymaster", "127.0.0.1", "11202")
+	waitMaster("127.0.0.1:11202")
+
+	// promoted by the sentinels with SLAVEOF NO ONE, not a slave of itself
+	s.failover("mymaster", "127.0.0.1", "11200")
+	time.Sleep(100 * time.Millisecond)
+	if a := app.MasterAddr(); a != "127.0.0.1:11202" {
+		t.Fatal(a)
+	}
+
+	if _, err = newSentinel(&App{cfg: &config.Config{Sentinel: config.SentinelConfig{Addrs: cfg.Sentinel.Addrs}}}); err == nil {
+		t.Fatal("no error without master_name")
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/siddontang/go/sync2"
+)
+
+// the max bytes of the arguments kept for a slow log entry
+const slowlogMaxArgBytes = 128
+
+// SlowlogEntry is a command which runs slower than slowlog_log_slower_than.
+type SlowlogEntry struct {
+	ID         uint64
+	Time       time.Time
+	Duration   time.Duration
+	Cmd        string
+	Args       [][]byte
+	RemoteAddr string
+}
+
+// SlowLog keeps the last slow commands in a ring buffer without a lock, a
+// writer takes a slot by an atomic counter and stores the entry atomically.
+type SlowLog struct {
+	slots []atomic.Value
+
+	next sync2.AtomicUint64
+	// the entries before it are dropped by reset
+	first sync2.AtomicUint64
+}
+
+func newSlowLog(maxLen int) *SlowLog {
+	if maxLen <= 0 {
+		maxLen = 1
+	}
+	return &SlowLog{slots: make([]atomic.Value, maxLen)}
+}
+
+func (s *SlowLog) log(c *client, start time.Time, d time.Duration) {
+	id := s.next.Add(1) - 1
+
+	e := &SlowlogEntry{
+		ID:         id,
+		Time:       start,
+		Duration:   d,
+		Cmd:        c.cmd,
+		Args:       summarizeArgs(c.args),
+		RemoteAddr: c.remoteAddr,
+	}
+
+	s.slots[id%uint64(len(s.slots))].Store(e)
+}
+
+// summarizeArgs copies at most slowlogMaxArgBytes bytes of args, like redis
+// the dropped parts are noted in the last argument.
+func summarizeArgs(args [][]byte) [][]byte {
+	ay := make([][]byte, 0, len(args))
+
+	n := 0
+	for i, arg := range args {
+		if n+len(arg) <= slowlogMaxArgBytes {
+			ay = append(ay, append([]byte(nil), arg...))
+			n += len(arg)
+			continue
+		}
+
+		if left := slowlogMaxArgBytes - n; left > 0 {
+			ay = append(ay, []byte(fmt.Sprintf("%s... (%d more bytes)", arg[:left], len(arg)-left)))
+			i++
+		}
+
+		if i < len(args) {
+			ay = append(ay, []byte(fmt.Sprintf("... (%d more arguments)", len(args)-i)))
+		}
+		break
+	}
+
+	return ay
+}
+
+// Get returns at most count entries from the newest, a negative count
+// returns all.
+func (s *SlowLog) Get(count int) []SlowlogEntry {
+	next, first := s.next.Get(), s.first.Get()
+
+	var entries []SlowlogEntry
+	for id := next; id > first && count != 0 && next-id < uint64(len(s.slots)); id-- {
+		e, _ := s.slots[(id-1)%uint64(len(s.slots))].Load().(*SlowlogEntry)
+		if e == nil || e.ID != id-1 {
+			// not stored yet, or overwritten by a newer one
+			continue
+		}
+
+		entries = append(entries, *e)
+		count--
+	}
+
+	return entries
+}
+
+// Len returns the number of the entries.
+func (s *SlowLog) Len() int {
+	return len(s.Get(-1))
+}
+
+// Reset drops all the entries.
+func (s *SlowLog) Reset() {
+	s.first.Set(s.next.Get())
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSlowLogRing(t *testing.T) {
+	s := newSlowLog(3)
+
+	c := new(client)
+	for i := 0; i < 5; i++ {
+		c.cmd = fmt.Sprintf("cmd%d", i)
+		s.log(c, time.Now(), time.Millisecond)
+	}
+
+	// the oldest entries are dropped
+	if es := s.Get(-1); len(es) != 3 {
+		t.Fatal(len(es))
+	} else if es[0].ID != 4 || es[0].Cmd != "cmd4" || es[2].ID != 2 {
+		t.Fatal(es)
+	}
+
+	if es := s.Get(2); len(es) != 2 || es[1].ID != 3 {
+		t.Fatal(es)
+	}
+
+	s.Reset()
+	if n := s.Len(); n != 0 {
+		t.Fatal(n)
+	}
+
+	s.log(c, time.Now(), time.Millisecond)
+	if es := s.Get(-1); len(es) != 1 || es[0].ID != 5 {
+		t.Fatal(es)
+	}
+}
+
+func TestSlowLogArgs(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 200)
+
+	if ay := summarizeArgs([][]byte{[]byte("k"), []byte("v")}); len(ay) != 2 {
+		t.Fatal(len(ay))
+	}
+
+	ay := summarizeArgs([][]byte{[]byte("k"), long, []byte("x"), []byte("y")})
+	if len(ay) != 3 {
+		t.Fatal(len(ay))
+	} else if want := fmt.Sprintf("%s... (73 more bytes)", long[:127]); string(ay[1]) != want {
+		t.Fatal(string(ay[1]))
+	} else if string(ay[2]) != "... (2 more arguments)" {
+		t.Fatal(string(ay[2]))
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// syncThrottle limits the bytes sent to a slave by FULLSYNC and SYNC, each
+// slave connection has its own. It also measures the send rate for the
+// replication status.
+type syncThrottle struct {
+	m sync.Mutex
+
+	// the bytes which can be sent now, negative if the sender must wait
+	tokens float64
+	last   time.Time
+
+	// the bytes sent since windowStart, and the rate of the window before
+	windowStart time.Time
+	windowBytes int64
+	rate        int64
+}
+
+// wait accounts n bytes sent and sleeps while they are over limit bytes per
+// second, 0 means no limit. The sleep is cut short by quit.
+func (t *syncThrottle) wait(n int, limit int64, quit chan struct{}) {
+	t.m.Lock()
+
+	now := time.Now()
+	if d := now.Sub(t.windowStart); d >= time.Second {
+		t.rate = int64(float64(t.windowBytes) / d.Seconds())
+		t.windowStart, t.windowBytes = now, 0
+	}
+	t.windowBytes += int64(n)
+
+	var delay time.Duration
+	if limit <= 0 {
+		t.last = time.Time{}
+	} else {
+		// a burst of one second at most
+		if t.last.IsZero() {
+			t.tokens = float64(limit)
+		} else if t.tokens += now.Sub(t.last).Seconds() * float64(limit); t.tokens > float64(limit) {
+			t.tokens = float64(limit)
+		}
+		t.last = now
+
+		if t.tokens -= float64(n); t.tokens < 0 {
+			delay = time.Duration(-t.tokens / float64(limit) * float64(time.Second))
+		}
+	}
+
+	t.m.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-quit:
+		}
+	}
+}
+
+// sendRate returns the bytes sent per second in the last second, 0 if
+// nothing is sent for a while.
+func (t *syncThrottle) sendRate() int64 {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if time.Since(t.windowStart) >= 2*time.Second {
+		return 0
+	}
+	return t.rate
+}
+
+// throttledReader reads the snapshot of FULLSYNC under the limit of the
+// slave connection.
+type throttledReader struct {
+	r     io.Reader
+	t     *syncThrottle
+	limit int64
+	quit  chan struct{}
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if r.limit > 0 && int64(len(p)) > r.limit {
+		p = p[:r.limit]
+	}
+
+	n, err := r.r.Read(p)
+	r.t.wait(n, r.limit, r.quit)
+	return n, err
+}
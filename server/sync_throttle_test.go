@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestSyncThrottle(t *testing.T) {
+	var s syncThrottle
+
+	// no limit
+	start := time.Now()
+	s.wait(1<<20, 0, nil)
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatal(d)
+	}
+
+	// the burst of one second, then the bytes over it wait
+	start = time.Now()
+	s.wait(1000, 1000, nil)
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatal(d)
+	}
+	s.wait(300, 1000, nil)
+	if d := time.Since(start); d < 250*time.Millisecond || d > time.Second {
+		t.Fatal(d)
+	}
+
+	// quit cuts the wait short
+	quit := make(chan struct{})
+	close(quit)
+	start = time.Now()
+	s.wait(10000, 1000, quit)
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatal(d)
+	}
+
+	var st syncThrottle
+	st.wait(1000, 0, nil)
+	if r := st.sendRate(); r != 0 {
+		t.Fatal(r)
+	}
+	st.windowStart = st.windowStart.Add(-time.Second)
+	st.wait(0, 0, nil)
+	if r := st.sendRate(); r < 900 || r > 1000 {
+		t.Fatal(r)
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	data := make([]byte, 1500)
+	r := &throttledReader{r: bytes.NewReader(data), t: new(syncThrottle), limit: 1000}
+
+	start := time.Now()
+	if b, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	} else if len(b) != len(data) {
+		t.Fatal(len(b))
+	} else if d := time.Since(start); d < 400*time.Millisecond || d > 2*time.Second {
+		t.Fatal(d)
+	}
+}
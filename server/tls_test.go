@@ -0,0 +1,157 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate signed by parent, or a self signed CA
+// if parent is nil, and writes the pem files to dir/name.crt and dir/name.key.
+func newTestCert(t *testing.T, dir string, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	if err = ioutil.WriteFile(path.Join(dir, name+".crt"), certPem, 0644); err != nil {
+		t.Fatal(err)
+	} else if err = ioutil.WriteFile(path.Join(dir, name+".key"), keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert, key}
+}
+
+func TestReplicationTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledis_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, dir, "ca", nil)
+	newTestCert(t, dir, "server", ca)
+	newTestCert(t, dir, "client", ca)
+
+	serverCfg, err := tlsConfig(&config.TLS{
+		Enabled:     true,
+		Certificate: path.Join(dir, "server.crt"),
+		Key:         path.Join(dir, "server.key"),
+		ClientCA:    path.Join(dir, "ca.crt"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := listen("tcp", "127.0.0.1:0", serverCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if err := conn.(*tls.Conn).Handshake(); err != nil {
+					return
+				}
+				conn.Write([]byte("+PONG\r\n"))
+			}(conn)
+		}
+	}()
+
+	addr := l.Addr().String()
+
+	ping := func(c *config.ReplicationConfig) error {
+		tlsCfg, err := masterTLSConfig(c, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := tls.Dial("tcp", addr, tlsCfg)
+		if err != nil {
+			return err
+		}
+
+		rc, _ := goredis.NewConn(conn)
+		defer rc.Close()
+
+		_, err = goredis.String(rc.Do("ping"))
+		return err
+	}
+
+	if err = ping(&config.ReplicationConfig{MasterTLS: true, MasterTLSCA: path.Join(dir, "ca.crt")}); err == nil {
+		t.Fatal("must fail without client certificate")
+	}
+
+	if err = ping(&config.ReplicationConfig{
+		MasterTLS:            true,
+		MasterTLSCA:          path.Join(dir, "ca.crt"),
+		MasterTLSCertificate: path.Join(dir, "client.crt"),
+		MasterTLSKey:         path.Join(dir, "client.key"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
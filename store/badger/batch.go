@@ -0,0 +1,82 @@
+// +build badger
+
+package badger
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// WriteBatch keeps the writes in a leveldb batch, whose data is the format
+// of the replication log, and commits them in one badger transaction.
+type WriteBatch struct {
+	db     *DB
+	wbatch *leveldb.Batch
+}
+
+func (w *WriteBatch) Put(key, value []byte) {
+	w.wbatch.Put(key, value)
+}
+
+func (w *WriteBatch) Delete(key []byte) {
+	w.wbatch.Delete(key)
+}
+
+func (w *WriteBatch) Commit() error {
+	return w.commit(false)
+}
+
+func (w *WriteBatch) SyncCommit() error {
+	return w.commit(true)
+}
+
+func (w *WriteBatch) commit(sync bool) error {
+	txn := w.db.db.NewTransaction(true)
+	defer txn.Discard()
+
+	r := &txnReplay{txn: txn}
+	if err := w.wbatch.Replay(r); err != nil {
+		return err
+	} else if r.err != nil {
+		return r.err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return err
+	} else if sync {
+		return w.db.db.Sync()
+	}
+	return nil
+}
+
+func (w *WriteBatch) Rollback() error {
+	w.wbatch.Reset()
+	return nil
+}
+
+func (w *WriteBatch) Close() {
+	w.wbatch.Reset()
+}
+
+func (w *WriteBatch) Data() []byte {
+	return w.wbatch.Dump()
+}
+
+// txnReplay writes the replayed batch to the transaction, keeping the first
+// error.
+type txnReplay struct {
+	txn *badger.Txn
+	err error
+}
+
+func (r *txnReplay) Put(key, value []byte) {
+	if r.err == nil {
+		r.err = r.txn.Set(key, value)
+	}
+}
+
+func (r *txnReplay) Delete(key []byte) {
+	if r.err == nil {
+		r.err = r.txn.Delete(key)
+	}
+}
@@ -0,0 +1,3 @@
+package badger
+
+const DBName = "badger"
@@ -0,0 +1,189 @@
+// +build badger
+
+// Package badger is a wrapper for BadgerDB, a pure go LSM tree with the
+// values kept in a separate log.
+//
+// The differences from leveldb:
+//   - a write batch is committed as one badger transaction, so it must fit
+//     in the memory table, a too big batch fails with ErrTxnTooBig. The
+//     transactions only write, so badger never reports a conflict for them.
+//   - the deleted and overwritten values are freed by the value log GC,
+//     which runs every gc_interval seconds and in Compact.
+//   - there is no repair, badger replays its log on open.
+package badger
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/store/driver"
+)
+
+type Store struct {
+}
+
+func (s Store) String() string {
+	return DBName
+}
+
+type DB struct {
+	path string
+
+	cfg *config.BadgerConfig
+
+	db *badger.DB
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+func (s Store) Open(path string, cfg *config.Config) (driver.IDB, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	db := new(DB)
+	db.path = path
+	db.cfg = &cfg.Badger
+
+	opts := badger.DefaultOptions(path).
+		WithLogger(nil).
+		WithBlockCacheSize(int64(db.cfg.CacheSize))
+
+	var err error
+	if db.db, err = badger.Open(opts); err != nil {
+		return nil, err
+	}
+
+	db.quit = make(chan struct{})
+	if db.cfg.GCInterval > 0 {
+		db.wg.Add(1)
+		go db.runGC()
+	}
+
+	return db, nil
+}
+
+func (s Store) Repair(path string, cfg *config.Config) error {
+	return nil
+}
+
+// runGC runs the value log GC every gc_interval until the db is closed.
+func (db *DB) runGC() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(db.cfg.GCInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := db.gc(); err != nil {
+				log.Errorf("badger value log gc error %s", err.Error())
+			}
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// gc rewrites the value log files until none can be rewritten.
+func (db *DB) gc() error {
+	for {
+		err := db.db.RunValueLogGC(db.cfg.GCDiscardRatio)
+		if err == badger.ErrNoRewrite || err == badger.ErrRejected {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (db *DB) Close() error {
+	close(db.quit)
+	db.wg.Wait()
+
+	return db.db.Close()
+}
+
+func (db *DB) Put(key, value []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+func (db *DB) Get(key []byte) ([]byte, error) {
+	var v []byte
+	err := db.db.View(func(txn *badger.Txn) error {
+		var err error
+		v, err = get(txn, key)
+		return err
+	})
+	return v, err
+}
+
+func get(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (db *DB) Delete(key []byte) error {
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+func (db *DB) SyncPut(key []byte, value []byte) error {
+	if err := db.Put(key, value); err != nil {
+		return err
+	}
+	return db.db.Sync()
+}
+
+func (db *DB) SyncDelete(key []byte) error {
+	if err := db.Delete(key); err != nil {
+		return err
+	}
+	return db.db.Sync()
+}
+
+func (db *DB) NewWriteBatch() driver.IWriteBatch {
+	wb := &WriteBatch{
+		db:     db,
+		wbatch: new(leveldb.Batch),
+	}
+	return wb
+}
+
+func (db *DB) NewIterator() driver.IIterator {
+	return newIterator(db.db.NewTransaction(false), true)
+}
+
+func (db *DB) NewSnapshot() (driver.ISnapshot, error) {
+	s := &Snapshot{
+		txn: db.db.NewTransaction(false),
+	}
+	return s, nil
+}
+
+func (db *DB) Compact() error {
+	if err := db.db.Flatten(1); err != nil {
+		return err
+	}
+	return db.gc()
+}
+
+func init() {
+	driver.Register(Store{})
+}
@@ -0,0 +1,146 @@
+// +build badger
+
+package badger
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Iterator moves in both directions with a forward and a reverse badger
+// iterator, badger iterates only one way. Switching the direction seeks the
+// other one to the current key.
+type Iterator struct {
+	txn *badger.Txn
+	// the iterator discards txn on close unless it is a snapshot's
+	ownTxn bool
+
+	forward *badger.Iterator
+	reverse *badger.Iterator
+
+	// the current one of forward and reverse, nil before the first move
+	cur *badger.Iterator
+
+	key   []byte
+	value []byte
+}
+
+func newIterator(txn *badger.Txn, ownTxn bool) *Iterator {
+	return &Iterator{txn: txn, ownTxn: ownTxn}
+}
+
+func (it *Iterator) iterator(reverse bool) *badger.Iterator {
+	if !reverse {
+		if it.forward == nil {
+			it.forward = it.txn.NewIterator(badger.DefaultIteratorOptions)
+		}
+		return it.forward
+	}
+
+	if it.reverse == nil {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it.reverse = it.txn.NewIterator(opts)
+	}
+	return it.reverse
+}
+
+// load copies the key and the value of the current item, they are kept
+// until the next move like leveldb.
+func (it *Iterator) load() {
+	it.key, it.value = nil, nil
+	if !it.cur.Valid() {
+		return
+	}
+
+	item := it.cur.Item()
+	it.key = item.KeyCopy(nil)
+
+	var err error
+	if it.value, err = item.ValueCopy(nil); err != nil {
+		it.key, it.value = nil, nil
+	}
+}
+
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+func (it *Iterator) Value() []byte {
+	return it.value
+}
+
+func (it *Iterator) Close() error {
+	if it.forward != nil {
+		it.forward.Close()
+		it.forward = nil
+	}
+	if it.reverse != nil {
+		it.reverse.Close()
+		it.reverse = nil
+	}
+	if it.ownTxn && it.txn != nil {
+		it.txn.Discard()
+	}
+	it.txn = nil
+	return nil
+}
+
+func (it *Iterator) Valid() bool {
+	return it.key != nil
+}
+
+func (it *Iterator) Next() {
+	if !it.Valid() {
+		return
+	}
+
+	if it.cur == it.reverse {
+		key := it.key
+		it.cur = it.iterator(false)
+		it.cur.Seek(key)
+		if it.cur.Valid() && bytes.Equal(it.cur.Item().Key(), key) {
+			it.cur.Next()
+		}
+	} else {
+		it.cur.Next()
+	}
+	it.load()
+}
+
+func (it *Iterator) Prev() {
+	if !it.Valid() {
+		return
+	}
+
+	if it.cur == it.forward {
+		key := it.key
+		it.cur = it.iterator(true)
+		it.cur.Seek(key)
+		if it.cur.Valid() && bytes.Equal(it.cur.Item().Key(), key) {
+			it.cur.Next()
+		}
+	} else {
+		it.cur.Next()
+	}
+	it.load()
+}
+
+func (it *Iterator) First() {
+	it.cur = it.iterator(false)
+	it.cur.Rewind()
+	it.load()
+}
+
+func (it *Iterator) Last() {
+	it.cur = it.iterator(true)
+	it.cur.Rewind()
+	it.load()
+}
+
+func (it *Iterator) Seek(key []byte) {
+	it.cur = it.iterator(false)
+	it.cur.Seek(key)
+	it.load()
+}
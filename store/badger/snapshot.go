@@ -0,0 +1,25 @@
+// +build badger
+
+package badger
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/siddontang/ledisdb/store/driver"
+)
+
+// Snapshot is a read only transaction, which sees the data at its start.
+type Snapshot struct {
+	txn *badger.Txn
+}
+
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	return get(s.txn, key)
+}
+
+func (s *Snapshot) NewIterator() driver.IIterator {
+	return newIterator(s.txn, false)
+}
+
+func (s *Snapshot) Close() {
+	s.txn.Discard()
+}
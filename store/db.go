@@ -1,9 +1,14 @@
 package store
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"time"
 
+	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/store/driver"
 )
@@ -11,6 +16,12 @@ import (
 type DB struct {
 	db   driver.IDB
 	name string
+	path string
+
+	// dbLock guards db against Reopen, gen counts the reopens so the write
+	// batches know when to move to the new db
+	dbLock sync.RWMutex
+	gen    sync2.AtomicInt64
 
 	st *Stat
 
@@ -25,15 +36,84 @@ func (db *DB) Close() error {
 	return db.db.Close()
 }
 
+// Reopen closes the store and opens it again in place, the close flushes
+// everything kept in memory to the files. The writes must be stopped by
+// the caller, the write batches are moved to the new store when they are
+// used next. It waits up to timeout for the iterators and the snapshots to
+// be closed. The memory store would lose everything, it can't be reopened.
+func (db *DB) Reopen(timeout time.Duration) error {
+	if db.name == "memory" {
+		return fmt.Errorf("memory store can not be reopened")
+	}
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		db.dbLock.Lock()
+		iters := db.st.IterNum.Get() - db.st.IterCloseNum.Get()
+		snaps := db.st.SnapshotNum.Get() - db.st.SnapshotCloseNum.Get()
+		if iters <= 0 && snaps <= 0 {
+			break
+		}
+		db.dbLock.Unlock()
+
+		if time.Since(start) > timeout {
+			return fmt.Errorf("store busy with %d iterators and %d snapshots", iters, snaps)
+		}
+	}
+	defer db.dbLock.Unlock()
+
+	s, err := driver.GetStore(db.cfg)
+	if err != nil {
+		return err
+	}
+
+	if err = db.db.Close(); err != nil {
+		return err
+	}
+
+	if db.db, err = s.Open(db.path, db.cfg); err != nil {
+		return err
+	}
+	db.gen.Add(1)
+	return nil
+}
+
 func (db *DB) String() string {
 	return db.name
 }
 
+// Size returns the size of the store files in bytes, or the heap in use for
+// the memory store.
+func (db *DB) Size() (int64, error) {
+	if db.name == "memory" {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		return int64(mem.HeapAlloc), nil
+	}
+
+	var n int64
+	err := filepath.Walk(db.path, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// a file removed by the compaction
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			n += fi.Size()
+		}
+		return nil
+	})
+	return n, err
+}
+
 func (db *DB) NewIterator() *Iterator {
 	db.st.IterNum.Add(1)
 
 	it := new(Iterator)
+	db.dbLock.RLock()
 	it.it = db.db.NewIterator()
+	db.dbLock.RUnlock()
 	it.st = db.st
 
 	return it
@@ -41,7 +121,9 @@ func (db *DB) NewIterator() *Iterator {
 
 func (db *DB) Get(key []byte) ([]byte, error) {
 	t := time.Now()
+	db.dbLock.RLock()
 	v, err := db.db.Get(key)
+	db.dbLock.RUnlock()
 	db.st.statGet(v, err)
 	db.st.GetTotalTime.Add(time.Now().Sub(t))
 	return v, err
@@ -50,6 +132,9 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 func (db *DB) Put(key []byte, value []byte) error {
 	db.st.PutNum.Add(1)
 
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
 	if db.needSyncCommit() {
 		return db.db.SyncPut(key, value)
 
@@ -62,6 +147,9 @@ func (db *DB) Put(key []byte, value []byte) error {
 func (db *DB) Delete(key []byte) error {
 	db.st.DeleteNum.Add(1)
 
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
 	if db.needSyncCommit() {
 		return db.db.SyncDelete(key)
 	} else {
@@ -72,7 +160,10 @@ func (db *DB) Delete(key []byte) error {
 func (db *DB) NewWriteBatch() *WriteBatch {
 	db.st.BatchNum.Add(1)
 	wb := new(WriteBatch)
+	db.dbLock.RLock()
 	wb.wb = db.db.NewWriteBatch()
+	wb.gen = db.gen.Get()
+	db.dbLock.RUnlock()
 	wb.st = db.st
 	wb.db = db
 	return wb
@@ -83,7 +174,10 @@ func (db *DB) NewSnapshot() (*Snapshot, error) {
 
 	var err error
 	s := &Snapshot{}
-	if s.ISnapshot, err = db.db.NewSnapshot(); err != nil {
+	db.dbLock.RLock()
+	s.ISnapshot, err = db.db.NewSnapshot()
+	db.dbLock.RUnlock()
+	if err != nil {
 		return nil, err
 	}
 	s.st = db.st
@@ -95,7 +189,9 @@ func (db *DB) Compact() error {
 	db.st.CompactNum.Add(1)
 
 	t := time.Now()
+	db.dbLock.RLock()
 	err := db.db.Compact()
+	db.dbLock.RUnlock()
 
 	db.st.CompactTotalTime.Add(time.Now().Sub(t))
 
@@ -110,6 +206,12 @@ func (db *DB) RevRangeIterator(min []byte, max []byte, rangeType uint8) *RangeLi
 	return NewRevRangeLimitIterator(db.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
 }
 
+// PrefixIterator iterates the keys starting with prefix, it seeks to prefix
+// and stops at PrefixEnd(prefix), the other keys are never walked.
+func (db *DB) PrefixIterator(prefix []byte) *RangeLimitIterator {
+	return db.RangeIterator(prefix, PrefixEnd(prefix), RangeROpen)
+}
+
 //count < 0, unlimit.
 //
 //offset must >= 0, if < 0, will get nothing.
@@ -150,9 +252,16 @@ func (db *DB) needSyncCommit() bool {
 }
 
 func (db *DB) GetSlice(key []byte) (Slice, error) {
-	if d, ok := db.db.(driver.ISliceGeter); ok {
+	// the store is reopened by the same driver, only db changes
+	db.dbLock.RLock()
+	_, ok := db.db.(driver.ISliceGeter)
+	db.dbLock.RUnlock()
+
+	if ok {
 		t := time.Now()
-		v, err := d.GetSlice(key)
+		db.dbLock.RLock()
+		v, err := db.db.(driver.ISliceGeter).GetSlice(key)
+		db.dbLock.RUnlock()
 		db.st.statGet(v, err)
 		db.st.GetTotalTime.Add(time.Now().Sub(t))
 		return v, err
@@ -34,6 +34,20 @@ type Range struct {
 	Type uint8
 }
 
+// PrefixEnd returns the smallest key bigger than all the keys starting with
+// prefix, nil if there is none, so [prefix, PrefixEnd(prefix)) holds exactly
+// the keys of the prefix.
+func PrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := append([]byte(nil), prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
 type Limit struct {
 	Offset int
 	Count  int
@@ -0,0 +1,332 @@
+package store
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/siddontang/ledisdb/store/driver"
+)
+
+// Overlay reads a DB with the writes not committed yet on top, the writes
+// added by Apply are seen by the reads at once but not by the DB, which
+// gets them when the caller commits Items.
+type Overlay struct {
+	db *DB
+
+	// the writes sorted by key, a nil value is a delete, the slice is
+	// replaced on every Apply so the open iterators keep theirs
+	items []BatchItem
+}
+
+func NewOverlay(db *DB) *Overlay {
+	return &Overlay{db: db}
+}
+
+// Apply adds the writes of items on top, a later one of the same key
+// replaces the earlier, the items are copied.
+func (o *Overlay) Apply(items []BatchItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	news := make([]BatchItem, len(items))
+	for i, item := range items {
+		news[i].Key = append([]byte(nil), item.Key...)
+		if item.Value != nil {
+			news[i].Value = append([]byte{}, item.Value...)
+		}
+	}
+
+	sort.SliceStable(news, func(i, j int) bool {
+		return bytes.Compare(news[i].Key, news[j].Key) < 0
+	})
+
+	// merge by key, the last write of the same key wins
+	merged := make([]BatchItem, 0, len(o.items)+len(news))
+	i := 0
+	for j := 0; j < len(news); j++ {
+		if j+1 < len(news) && bytes.Equal(news[j].Key, news[j+1].Key) {
+			continue
+		}
+
+		for ; i < len(o.items) && bytes.Compare(o.items[i].Key, news[j].Key) < 0; i++ {
+			merged = append(merged, o.items[i])
+		}
+		if i < len(o.items) && bytes.Equal(o.items[i].Key, news[j].Key) {
+			i++
+		}
+		merged = append(merged, news[j])
+	}
+	merged = append(merged, o.items[i:]...)
+
+	o.items = merged
+}
+
+// Items returns the writes sorted by key, a nil value is a delete.
+func (o *Overlay) Items() []BatchItem {
+	return o.items
+}
+
+// find returns the write of key, nil if key is not written.
+func (o *Overlay) find(key []byte) *BatchItem {
+	i := sort.Search(len(o.items), func(i int) bool {
+		return bytes.Compare(o.items[i].Key, key) >= 0
+	})
+	if i < len(o.items) && bytes.Equal(o.items[i].Key, key) {
+		return &o.items[i]
+	}
+	return nil
+}
+
+func (o *Overlay) Get(key []byte) ([]byte, error) {
+	if item := o.find(key); item == nil {
+		return o.db.Get(key)
+	} else if item.Value == nil {
+		return nil, nil
+	} else {
+		return append([]byte{}, item.Value...), nil
+	}
+}
+
+func (o *Overlay) GetSlice(key []byte) (Slice, error) {
+	if item := o.find(key); item == nil {
+		return o.db.GetSlice(key)
+	} else if item.Value == nil {
+		return nil, nil
+	} else {
+		return driver.GoSlice(append([]byte{}, item.Value...)), nil
+	}
+}
+
+func (o *Overlay) Put(key []byte, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	o.Apply([]BatchItem{{key, value}})
+	return nil
+}
+
+func (o *Overlay) Delete(key []byte) error {
+	o.Apply([]BatchItem{{key, nil}})
+	return nil
+}
+
+func (o *Overlay) NewIterator() *Iterator {
+	it := o.db.NewIterator()
+	it.it = &overlayIterator{it: it.it, items: o.items}
+	return it
+}
+
+func (o *Overlay) NewWriteBatch() *WriteBatch {
+	return o.db.NewWriteBatch()
+}
+
+func (o *Overlay) RangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRangeLimitIterator(o.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+func (o *Overlay) RevRangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(o.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+func (o *Overlay) RangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRangeLimitIterator(o.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
+
+func (o *Overlay) RevRangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(o.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
+
+// overlayIterator merges the iterator of the DB and the writes, a write
+// hides the DB key of the same one and a delete is skipped.
+//
+// Going forward, both are at the first key not less than the current one,
+// and going backward at the last key not greater.
+type overlayIterator struct {
+	it    driver.IIterator
+	items []BatchItem
+	i     int
+
+	forward bool
+
+	valid     bool
+	fromItems bool
+	key       []byte
+	value     []byte
+}
+
+func (it *overlayIterator) Close() error {
+	return it.it.Close()
+}
+
+// search returns the index of the first write not less than key, or
+// greater if after.
+func (it *overlayIterator) search(key []byte, after bool) int {
+	return sort.Search(len(it.items), func(i int) bool {
+		c := bytes.Compare(it.items[i].Key, key)
+		return c > 0 || (c == 0 && !after)
+	})
+}
+
+func (it *overlayIterator) First() {
+	it.it.First()
+	it.i = 0
+	it.findForward()
+}
+
+func (it *overlayIterator) Last() {
+	it.it.Last()
+	it.i = len(it.items) - 1
+	it.findBackward()
+}
+
+func (it *overlayIterator) Seek(key []byte) {
+	it.it.Seek(key)
+	it.i = it.search(key, false)
+	it.findForward()
+}
+
+func (it *overlayIterator) Next() {
+	if !it.valid {
+		return
+	}
+
+	if !it.forward {
+		key := append([]byte(nil), it.key...)
+		it.it.Seek(key)
+		if it.it.Valid() && bytes.Equal(it.it.Key(), key) {
+			it.it.Next()
+		}
+		it.i = it.search(key, true)
+	} else if !it.fromItems {
+		it.it.Next()
+	} else {
+		if it.it.Valid() && bytes.Equal(it.it.Key(), it.key) {
+			it.it.Next()
+		}
+		it.i++
+	}
+
+	it.findForward()
+}
+
+func (it *overlayIterator) Prev() {
+	if !it.valid {
+		return
+	}
+
+	if it.forward {
+		key := append([]byte(nil), it.key...)
+		it.it.Seek(key)
+		if it.it.Valid() {
+			it.it.Prev()
+		} else {
+			it.it.Last()
+		}
+		it.i = it.search(key, false) - 1
+	} else if !it.fromItems {
+		it.it.Prev()
+	} else {
+		if it.it.Valid() && bytes.Equal(it.it.Key(), it.key) {
+			it.it.Prev()
+		}
+		it.i--
+	}
+
+	it.findBackward()
+}
+
+// findForward moves to the smaller key of both, skipping the deletes.
+func (it *overlayIterator) findForward() {
+	it.forward = true
+
+	for {
+		dbValid, itemsValid := it.it.Valid(), it.i >= 0 && it.i < len(it.items)
+		if !dbValid && !itemsValid {
+			it.valid = false
+			return
+		}
+
+		c := 1
+		if !itemsValid {
+			c = -1
+		} else if dbValid {
+			c = bytes.Compare(it.it.Key(), it.items[it.i].Key)
+		}
+
+		if c < 0 {
+			it.setDB()
+			return
+		} else if it.items[it.i].Value != nil {
+			it.setItem()
+			return
+		}
+
+		// a delete of the key
+		if c == 0 {
+			it.it.Next()
+		}
+		it.i++
+	}
+}
+
+// findBackward moves to the greater key of both, skipping the deletes.
+func (it *overlayIterator) findBackward() {
+	it.forward = false
+
+	for {
+		dbValid, itemsValid := it.it.Valid(), it.i >= 0 && it.i < len(it.items)
+		if !dbValid && !itemsValid {
+			it.valid = false
+			return
+		}
+
+		c := -1
+		if !itemsValid {
+			c = 1
+		} else if dbValid {
+			c = bytes.Compare(it.it.Key(), it.items[it.i].Key)
+		}
+
+		if c > 0 {
+			it.setDB()
+			return
+		} else if it.items[it.i].Value != nil {
+			it.setItem()
+			return
+		}
+
+		if c == 0 {
+			it.it.Prev()
+		}
+		it.i--
+	}
+}
+
+func (it *overlayIterator) setDB() {
+	it.valid, it.fromItems = true, false
+	it.key, it.value = it.it.Key(), it.it.Value()
+}
+
+func (it *overlayIterator) setItem() {
+	it.valid, it.fromItems = true, true
+	it.key, it.value = it.items[it.i].Key, it.items[it.i].Value
+}
+
+func (it *overlayIterator) Valid() bool {
+	return it.valid
+}
+
+func (it *overlayIterator) Key() []byte {
+	if !it.valid {
+		return nil
+	}
+	return it.key
+}
+
+func (it *overlayIterator) Value() []byte {
+	if !it.valid {
+		return nil
+	}
+	return it.value
+}
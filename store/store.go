@@ -8,6 +8,7 @@ import (
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/store/driver"
 
+	_ "github.com/siddontang/ledisdb/store/badger"
 	_ "github.com/siddontang/ledisdb/store/goleveldb"
 	_ "github.com/siddontang/ledisdb/store/leveldb"
 	_ "github.com/siddontang/ledisdb/store/rocksdb"
@@ -41,6 +42,7 @@ func Open(cfg *config.Config) (*DB, error) {
 	db := new(DB)
 	db.db = idb
 	db.name = s.String()
+	db.path = path
 	db.st = &Stat{}
 	db.cfg = cfg
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/store/driver"
@@ -41,6 +42,8 @@ func testStore(db *DB, t *testing.T) {
 	testIterator(db, t)
 	testSnapshot(db, t)
 	testBatchData(db, t)
+	testOverlay(db, t)
+	testReopen(db, t)
 }
 
 func testClear(db *DB, t *testing.T) {
@@ -282,6 +285,26 @@ func testIterator(db *DB, t *testing.T) {
 		t.Fatal(err)
 	}
 	it.Close()
+
+	db.Put([]byte("key_10"), []byte("value"))
+	db.Put([]byte("key_2x"), []byte("value"))
+	db.Put([]byte("kez"), []byte("value"))
+
+	it = db.PrefixIterator([]byte("key_1"))
+	if err := checkIterator(it, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	it.Close()
+
+	if end := PrefixEnd([]byte("a\xff\xff")); string(end) != "b" {
+		t.Fatal(end)
+	} else if end := PrefixEnd([]byte("\xff")); end != nil {
+		t.Fatal(end)
+	}
+
+	db.Delete([]byte("key_10"))
+	db.Delete([]byte("key_2x"))
+	db.Delete([]byte("kez"))
 }
 
 func testSnapshot(db *DB, t *testing.T) {
@@ -324,6 +347,12 @@ func testSnapshot(db *DB, t *testing.T) {
 		t.Fatal(string(v))
 	}
 
+	if v, err := snap.Get([]byte("snapshot_none")); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
 	if v, err := db.Get(foo); err != nil {
 		t.Fatal(err)
 	} else if string(v) != "v2" {
@@ -367,3 +396,139 @@ func testBatchData(db *DB, t *testing.T) {
 		t.Fatalf("%v != %v", kvs, expected)
 	}
 }
+
+func checkOverlayKeys(it interface {
+	Valid() bool
+	Key() []byte
+	Value() []byte
+}, next func(), keys ...string) error {
+	v := []string{}
+	for ; it.Valid(); next() {
+		if string(it.Value()) != "v_"+string(it.Key()) {
+			return fmt.Errorf("invalid value %s of %s", it.Value(), it.Key())
+		}
+		v = append(v, string(it.Key()))
+	}
+
+	if !reflect.DeepEqual(v, keys) {
+		return fmt.Errorf("%v != %v", v, keys)
+	}
+	return nil
+}
+
+func testOverlay(db *DB, t *testing.T) {
+	for _, k := range []string{"o_a", "o_c", "o_e"} {
+		db.Put([]byte(k), []byte("v_"+k))
+	}
+	defer func() {
+		for _, k := range []string{"o_a", "o_c", "o_e"} {
+			db.Delete([]byte(k))
+		}
+	}()
+
+	o := NewOverlay(db)
+	o.Apply([]BatchItem{
+		{[]byte("o_e"), []byte("old")},
+		{[]byte("o_b"), []byte("v_o_b")},
+		{[]byte("o_c"), nil},
+		{[]byte("o_e"), []byte("v_o_e")},
+	})
+	o.Put([]byte("o_f"), []byte("v_o_f"))
+
+	if v, err := o.Get([]byte("o_c")); err != nil || v != nil {
+		t.Fatal(v, err)
+	} else if v, err = o.Get([]byte("o_a")); err != nil || string(v) != "v_o_a" {
+		t.Fatal(v, err)
+	} else if v, err = o.Get([]byte("o_e")); err != nil || string(v) != "v_o_e" {
+		t.Fatal(v, err)
+	} else if v, err = db.Get([]byte("o_b")); err != nil || v != nil {
+		t.Fatal("the overlay is not committed", v, err)
+	}
+
+	if items := o.Items(); len(items) != 4 || string(items[0].Key) != "o_b" || items[1].Value != nil {
+		t.Fatal(items)
+	}
+
+	it := o.NewIterator()
+	it.Seek([]byte("o_"))
+	if err := checkOverlayKeys(it, it.Next, "o_a", "o_b", "o_e", "o_f"); err != nil {
+		t.Fatal(err)
+	}
+
+	// no key after o_z, it goes from the last
+	rit := o.RevRangeIterator([]byte("o_"), []byte("o_z"), RangeClose)
+	if err := checkOverlayKeys(rit, rit.Next, "o_f", "o_e", "o_b", "o_a"); err != nil {
+		t.Fatal(err)
+	}
+	rit.Close()
+
+	// the iterator keeps the writes when it is created
+	o.Delete([]byte("o_a"))
+
+	// the switches of the direction
+	it.Seek([]byte("o_c"))
+	for i, move := range []func(){it.Prev, it.Next, it.Prev, it.Prev} {
+		if !it.Valid() || string(it.Key()) != []string{"o_e", "o_b", "o_e", "o_b"}[i] {
+			t.Fatal(i, string(it.Key()))
+		}
+		move()
+	}
+	if !it.Valid() || string(it.Key()) != "o_a" {
+		t.Fatal(string(it.Key()))
+	} else if it.Prev(); it.Valid() && bytes.HasPrefix(it.Key(), []byte("o_")) {
+		t.Fatal(string(it.Key()))
+	}
+	it.Close()
+
+	rit = o.RevRangeLimitIterator([]byte("o_a"), []byte("o_f"), RangeROpen, 0, -1)
+	if err := checkOverlayKeys(rit, rit.Next, "o_e", "o_b"); err != nil {
+		t.Fatal(err)
+	}
+	rit.Close()
+}
+
+func testReopen(db *DB, t *testing.T) {
+	if db.String() == "memory" {
+		if err := db.Reopen(time.Second); err == nil {
+			t.Fatal("must error for the memory store")
+		}
+		return
+	}
+
+	wb := db.NewWriteBatch()
+	wb.Put([]byte("reopen_a"), []byte("1"))
+	if err := wb.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	it := db.NewIterator()
+	if err := db.Reopen(50 * time.Millisecond); err == nil {
+		t.Fatal("must error with an open iterator")
+	}
+	it.Close()
+
+	if err := db.Reopen(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := db.Get([]byte("reopen_a")); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "1" {
+		t.Fatal(string(v))
+	}
+
+	// the batch moves to the new store
+	wb.Put([]byte("reopen_b"), []byte("2"))
+	if err := wb.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := db.Get([]byte("reopen_b")); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "2" {
+		t.Fatal(string(v))
+	}
+
+	db.Delete([]byte("reopen_a"))
+	db.Delete([]byte("reopen_b"))
+}
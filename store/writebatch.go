@@ -14,6 +14,8 @@ type WriteBatch struct {
 	putNum    int64
 	deleteNum int64
 	db        *DB
+	// the gen of db which wb belongs to
+	gen int64
 
 	data *BatchData
 }
@@ -22,17 +24,34 @@ func (wb *WriteBatch) Close() {
 	wb.wb.Close()
 }
 
+// rebind moves wb to the store opened again by Reopen, wb is empty since
+// the writes are stopped by then.
+func (wb *WriteBatch) rebind() {
+	if wb.db == nil || wb.gen == wb.db.gen.Get() {
+		return
+	}
+
+	wb.db.dbLock.RLock()
+	wb.wb.Close()
+	wb.wb = wb.db.db.NewWriteBatch()
+	wb.gen = wb.db.gen.Get()
+	wb.db.dbLock.RUnlock()
+}
+
 func (wb *WriteBatch) Put(key []byte, value []byte) {
+	wb.rebind()
 	wb.putNum++
 	wb.wb.Put(key, value)
 }
 
 func (wb *WriteBatch) Delete(key []byte) {
+	wb.rebind()
 	wb.deleteNum++
 	wb.wb.Delete(key)
 }
 
 func (wb *WriteBatch) Commit() error {
+	wb.rebind()
 	wb.st.BatchCommitNum.Add(1)
 	wb.st.PutNum.Add(wb.putNum)
 	wb.st.DeleteNum.Add(wb.deleteNum)
@@ -41,10 +60,16 @@ func (wb *WriteBatch) Commit() error {
 
 	var err error
 	t := time.Now()
-	if wb.db == nil || !wb.db.needSyncCommit() {
+	if wb.db == nil {
 		err = wb.wb.Commit()
 	} else {
-		err = wb.wb.SyncCommit()
+		wb.db.dbLock.RLock()
+		if !wb.db.needSyncCommit() {
+			err = wb.wb.Commit()
+		} else {
+			err = wb.wb.SyncCommit()
+		}
+		wb.db.dbLock.RUnlock()
 	}
 
 	wb.st.BatchCommitTotalTime.Add(time.Now().Sub(t))
@@ -53,6 +78,7 @@ func (wb *WriteBatch) Commit() error {
 }
 
 func (wb *WriteBatch) Rollback() error {
+	wb.rebind()
 	wb.putNum = 0
 	wb.deleteNum = 0
 
@@ -61,6 +87,7 @@ func (wb *WriteBatch) Rollback() error {
 
 // the data will be undefined after commit or rollback
 func (wb *WriteBatch) BatchData() *BatchData {
+	wb.rebind()
 	data := wb.wb.Data()
 	if wb.data == nil {
 		wb.data = new(BatchData)
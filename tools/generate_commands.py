@@ -4,6 +4,7 @@ import json
 import time
 import sys
 import os
+import re
 from collections import OrderedDict as dict
 
 
@@ -29,12 +30,112 @@ def json_to_go_array(json_path, go_path):
         generate_time(g_fp)
         g_fp.write("package main\n\nvar helpCommands = [][]string{\n")
         _json_sorted = dict(sorted(_json.items(), key=lambda x: x[0]))
-        for k, v in _json_sorted.iteritems():
+        for k, v in _json_sorted.items():
             g_fp.write('\t{"%s", "%s", "%s"},\n' % (k, v["arguments"], v["group"]))
         g_fp.write("}\n")
     g_fp.close()
 
 
+def command_arity(name, args):
+    """The redis arity of the command, negative for at least -arity arguments"""
+    n = len(name.split())
+    depth, word, variadic = 0, False, False
+    for ch in args.strip():
+        if ch == "[":
+            depth += 1
+            variadic = True
+        elif ch == "]":
+            depth -= 1
+        elif depth > 0:
+            continue
+        elif ch == " ":
+            word = False
+        elif ch == ".":
+            variadic = True
+        elif not word and args.strip() != "-":
+            n += 1
+            word = True
+    if variadic:
+        return -n
+    return n
+
+
+def md_command_name(heading, names):
+    """The command of a section heading, the longest leading words which are a command"""
+    words = heading.split()
+    for n in range(len(words), 0, -1):
+        name = " ".join(words[:n]).upper()
+        if name in names:
+            return name
+    return None
+
+
+def md_summaries(md_path, names):
+    """The first paragraph of every command section in `commands.md`, by the command"""
+    summaries = {}
+    name, lines = None, []
+
+    def add():
+        if name is not None and lines:
+            # the links to the other sections are kept as text
+            summaries.setdefault(name, re.sub(r"\[((?:[^\[\]]|\[[^\]]*\])*)\]\(#[^)]*\)", r"\1", " ".join(lines)))
+
+    with open(md_path) as fp:
+        for line in fp:
+            line = line.strip()
+            if line.startswith("#"):
+                # a section ends at the next heading of any level
+                add()
+                name, lines = None, []
+                if line.startswith("### "):
+                    name = md_command_name(line[4:], names)
+            elif name is None:
+                continue
+            elif line.startswith("**") or line.startswith("```"):
+                # no description before the return value or the examples
+                add()
+                name = None
+            elif line:
+                lines.append(line)
+            elif lines:
+                add()
+                name = None
+    add()
+    return summaries
+
+
+def json_to_go_docs(json_path, go_path):
+    md_path = os.path.join(os.path.dirname(json_path), "commands.md")
+
+    with open(json_path) as fp:
+        _json = json.load(fp)
+
+    summaries = md_summaries(md_path, set(k.upper() for k in _json))
+
+    # a command with only sub-commands takes at least the shortest of them
+    for k in list(_json.keys()):
+        parent = k.split()[0]
+        if parent != k and parent not in _json:
+            subs = [s for s in _json if s.split()[0] == parent]
+            _json[parent] = {"arguments": "subcommand [argument ...]", "group": _json[k]["group"],
+                             "arity": -min(abs(command_arity(s, _json[s]["arguments"])) for s in subs),
+                             "summary": "A container for the %s subcommands." % parent}
+
+    def summary(k, args):
+        return summaries.get(k.upper(), _json[k].get("summary", ""))
+
+    g_fp = open(go_path, "w")
+    generate_time(g_fp)
+    g_fp.write("\npackage server\n\nvar commandDocs = map[string]commandDoc{\n")
+    for k, v in sorted(_json.items(), key=lambda x: x[0]):
+        arity = v.get("arity", command_arity(k, v["arguments"]))
+        g_fp.write('\t%s: {%d, %s, %s, %s},\n' % (json.dumps(k.lower()), arity, json.dumps(v["group"]),
+                                                   json.dumps(v["arguments"].strip()),
+                                                   json.dumps(summary(k, v["arguments"].strip()))))
+    g_fp.write("}\n")
+    g_fp.close()
+
+
 def generate_time(fp):
     fp.write("//This file was generated by .tools/generate_commands.py on %s \n" %
              time.strftime('%a %b %d %Y %H:%M:%S %z'))
@@ -52,6 +153,10 @@ if __name__ == "__main__":
         
         python generate.py /path/to/commands.json /path/to/const.go
 
+    3. for server/command_docs.go, with the summaries in commands.md
+
+        python generate.py /path/to/commands.json /path/to/command_docs.go
+
     """
 
     if len(sys.argv) != 3:
@@ -66,5 +171,8 @@ if __name__ == "__main__":
     elif dst_path_base.startswith("const.go"):
         json_to_go_array(src_path, dst_path)
 
+    elif dst_path_base.startswith("command_docs.go"):
+        json_to_go_docs(src_path, dst_path)
+
     else:
-        print "Not support arguments"
+        print("Not support arguments")